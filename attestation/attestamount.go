@@ -0,0 +1,59 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Utility functions to assert that the staychain unspent spent by the next
+// attestation carries the amount expected from the previous attestation,
+// i.e. the staychain output amount of the previous attestation, which is the
+// previous staychain amount minus the fee paid. Guards against building the
+// attestation on a wrong or tampered unspent, e.g. an unexpected external
+// spend paying to the attestation address, if the wallet drifts
+
+// error - warning consts
+const (
+	ErrorAttestationAmountDrift   = "Staychain unspent amount differs from previous attestation amount"
+	WarningAttestationAmountDrift = "CRITICAL - Staychain unspent amount differs from previous attestation amount"
+)
+
+// Check staychain unspent amount against the previous attestation amount
+// Fails if the difference in satoshis exceeds the tolerance provided
+func checkUnspentAmount(unspent btcjson.ListUnspentResult, previousAmount int64, tolerance int64) error {
+	amount := int64(math.Round(unspent.Amount * Coin))
+	diff := amount - previousAmount
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > tolerance {
+		return errors.New(fmt.Sprintf("%s (%s:%d %d != %d)", ErrorAttestationAmountDrift,
+			unspent.TxID, unspent.Vout, amount, previousAmount))
+	}
+	return nil
+}
+
+// Verify the staychain unspent of the next attestation against the amount of
+// the previous attestation, if an amount tolerance is set and the previous
+// attestation amount is known. Returns true if attesting should not proceed
+func (s *AttestService) isUnspentAmountDrifted(unspent btcjson.ListUnspentResult) bool {
+	tolerance := s.config.AttestationConfig().AmountTolerance
+	if tolerance < 0 || s.attestedAmount <= 0 {
+		return false
+	}
+	if checkErr := checkUnspentAmount(unspent, s.attestedAmount, tolerance); checkErr != nil {
+		log.Printf("*AttestService* %s (%s:%d tolerance %d)\n", WarningAttestationAmountDrift,
+			unspent.TxID, unspent.Vout, tolerance)
+		s.setFailure(checkErr)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test staychain unspent amount check against previous attestation amount
+func TestCheckUnspentAmount(t *testing.T) {
+	unspent := btcjson.ListUnspentResult{TxID: "aaaa", Vout: 0, Amount: 1.0}
+
+	assert.Equal(t, nil, checkUnspentAmount(unspent, Coin, 0))
+	assert.Equal(t, nil, checkUnspentAmount(unspent, Coin+1000, 1000))
+	assert.Equal(t, nil, checkUnspentAmount(unspent, Coin-1000, 1000))
+	assert.Equal(t, errors.New(ErrorAttestationAmountDrift+" (aaaa:0 100000000 != 100001001)"),
+		checkUnspentAmount(unspent, Coin+1001, 1000))
+	assert.Equal(t, errors.New(ErrorAttestationAmountDrift+" (aaaa:0 100000000 != 99999999)"),
+		checkUnspentAmount(unspent, Coin-1, 0))
+
+	// test amounts not exactly representable in btc
+	unspent.Amount = 0.29
+	assert.Equal(t, nil, checkUnspentAmount(unspent, 29000000, 0))
+}
+
+// Test Attest Service refusing to attest on unexpected staychain unspent amount
+func TestAttestService_AmountDrift(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+	attestationConfig := config.AttestationConfig()
+	attestationConfig.AmountTolerance = 0
+	config.SetAttestationConfig(attestationConfig)
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// test attesting refused if unspent amount differs from previous attestation
+	_, unspents, _ := attestService.attester.findNextUnspents()
+	unspentAmount := int64(math.Round(unspents[0].Amount * Coin))
+	attestService.attestedAmount = unspentAmount + 1
+	attestService.doAttestation()
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, true, strings.HasPrefix(attestService.errorState.Error(), ErrorAttestationAmountDrift))
+
+	// test attesting proceeds if unspent amount matches previous attestation
+	attestService = NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	attestService.attestedAmount = unspentAmount
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+
+	// test check disabled by default
+	attestationConfig.AmountTolerance = -1
+	config.SetAttestationConfig(attestationConfig)
+	attestService.attestedAmount = unspentAmount + 1
+	assert.Equal(t, false, attestService.isUnspentAmountDrifted(unspents[0]))
+}
@@ -5,11 +5,17 @@
 package attestation
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	confpkg "mainstay/config"
 	"mainstay/crypto"
@@ -18,7 +24,7 @@ import (
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
@@ -31,6 +37,10 @@ const (
 	WarningTopupPkMissing               = `Warning - Topup Private Key not set in config`
 	WarningFailureImportingTopupAddress = `Could not import topup address`
 	WarningFailedDecodingTopupMultisig  = `Could not decode multisig topup script`
+	WarningSigUnknownSigner             = `Warning - Signature not from any expected signer pubkey`
+	WarningSigStaleTweak                = `Warning - Signature tweaked with previous commitment - signer lagging`
+	WarningFailedCalculatingSigHash     = `Could not calculate signature hash`
+	WarningSendAttestationRetry         = `Warning - Failed sending attestation - retrying`
 
 	ErrorInsufficientFunds          = `Insufficient unspent vout value (less than the maxFee target)`
 	ErrorMissingMultisig            = `No multisig used - Client must be signer and include private key`
@@ -41,13 +51,103 @@ const (
 	ErrorSigsMissingForTx           = `Missing signatures for transaction`
 	ErrorSigsMissingForVin          = `Missing signatures for transaction input`
 	ErrorInputMissingForTx          = `Missing input for transaction`
+	ErrorPrevOutNotFound            = `Previous output spent by transaction input not found`
+	ErrorInvalidSig                 = `Invalid signature received`
 	ErrorInvalidChaincode           = `Invalid chaincode provided`
 	ErrorMissingChaincodes          = `Missing chaincodes for pubkeys`
+	ErrorInitTxNotFound             = `Initial transaction not found in main client wallet - check that the client is connected to the correct network, that the wallet has been rescanned after importing the init address and that the init txid is correct`
+	ErrorInitTxNoUnspent            = `Initial transaction output spent and no unspent found on the attestation subchain - check that the init txid is correct and that the wallet has been rescanned`
+	ErrorGenesisNotConfirmed        = `Genesis transaction does not have enough confirmations`
+	ErrorGenesisAddressMismatch     = `Genesis transaction does not pay to the address of the init script`
+	ErrorCheckpointNotConfirmed     = `Checkpoint transaction not confirmed in main client wallet`
+	ErrorCheckpointNotOnSubchain    = `Checkpoint transaction not on the attestation subchain`
+	ErrorScanUtxoSetFailed          = `Failed scanning the utxo set for attestation unspents`
+	ErrorSweepAddressMismatch       = `Sweep transaction output does not pay to the attestation address of the commitment`
+	ErrorAttestationAddressMismatch = `Attestation transaction output does not pay to the attestation address of the commitment`
+	ErrorInvalidLocktime            = `Invalid attestation locktime config value`
+	ErrorImportDescriptorFailed     = `Failed importing address descriptor to descriptor wallet`
+	ErrorAddrNotWatched             = `Attestation address not watched by main client wallet after import`
+	ErrorSendAttestationRejected    = `Attestation transaction rejected by main client`
+	ErrorInvalidRedundantOutput     = `Invalid attestation redundant output type`
+	ErrorInvalidOutputValues        = `Attestation output values and fee do not match the input value`
+	ErrorSignIncomplete             = `Transaction not fully signed by main client - check the private key provided`
+	ErrorInvalidReserveConfig       = `Invalid attestation reserve config - both a positive attestation amount and a reserve address are required`
+	ErrorInvalidReserveAddress      = `Invalid attestation reserve address for the main client chain`
+	ErrorInvalidUnspentSelection    = `Invalid attestation unspent selection config value`
+	ErrorUnspentNotInTipSet         = `Selected unspent not in the subchain tip set`
+	ErrorWalletNotLoaded            = `Main client wallet not loaded - check that the wallet exists and has been loaded`
 )
 
+// minimum confirmations of genesis transaction on startup
+const DefaultGenesisMinConfirmations = 6
+
+// minimum confirmations of the staychain unspent the next attestation is built on
+const DefaultMinUnspentConfirmations = 1
+
 // coin in satoshis
 const Coin = 100000000
 
+// locktime config value to set attestation locktime to the current block height
+const LocktimeHeight = "height"
+
+// redundant output types encoding the attestation commitment
+const (
+	RedundantOutputOpReturn = "opreturn" // zero value OP_RETURN output pushing the commitment
+)
+
+// minimum value of the reserve output of two-output attestations
+// any lower excess over the attestation amount is kept in the staychain output
+const ReserveOutputDustLimit = 546
+
+// number of latest attestation addresses watched when scanning the utxo set
+// covers the latest confirmed, latest unconfirmed and new attestation address
+const MaxScanAddresses = 3
+
+// bitcoin rpc error codes of permanent transaction rejections
+// any other send errors, e.g. client not connected or in warmup, are transient
+const (
+	RpcErrorDeserialization = -22 // tx decode failed
+	RpcErrorVerify          = -25 // e.g. missing inputs
+	RpcErrorVerifyRejected  = -26 // e.g. insufficient fee, non standard
+	RpcErrorAlreadyInChain  = -27 // tx already in chain
+)
+
+// initial backoff between attestation send retries - doubled on each retry
+// up to the maximum backoff
+var sendRetryBackoff = 1 * time.Second
+var sendRetryBackoffMax = 30 * time.Second
+
+// SendRejectedError wraps errors of attestation transactions
+// permanently rejected by the main client and are not retried
+type SendRejectedError struct {
+	Err *btcjson.RPCError
+}
+
+// Implement error interface for SendRejectedError
+func (e SendRejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrorSendAttestationRejected, e.Err.Error())
+}
+
+// Check if an error is a SendRejectedError due to the transaction fee
+// i.e. fee below min relay fee, mempool min fee or the replaced tx fee
+func IsFeeRejectedError(err error) bool {
+	rejectedErr, ok := err.(SendRejectedError)
+	return ok && strings.Contains(strings.ToLower(rejectedErr.Err.Message), "fee")
+}
+
+// Check if a send error is a permanent rejection and return the rpc error
+func getSendRejection(err error) (*btcjson.RPCError, bool) {
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return nil, false
+	}
+	switch rpcErr.Code {
+	case RpcErrorDeserialization, RpcErrorVerify, RpcErrorVerifyRejected, RpcErrorAlreadyInChain:
+		return rpcErr, true
+	}
+	return nil, false
+}
+
 // AttestClient structure
 //
 // This struct maintains rpc connection to the main bitcoin client
@@ -66,14 +166,14 @@ const Coin = 100000000
 // In the case that no multisig is used, client must be a signer
 //
 type AttestClient struct {
-	// rpc client connection to main bitcoin client
-	MainClient *rpcclient.Client
+	// rpc client connection to main bitcoin client - rpcclient.Client in prod
+	MainClient AttestRpcClient
 
 	// chain config for main bitcoin client
 	MainChainCfg *chaincfg.Params
 
 	// fees interface for getting latest / bumping fees
-	Fees AttestFees
+	Fees *AttestFees
 
 	// init configuration parameters
 	// store information on initial keys and txid
@@ -87,6 +187,56 @@ type AttestClient struct {
 	addrTopup       string
 	scriptTopup     string
 
+	// staychain checkpoint txid trusted as subchain root instead of txid0
+	checkpoint string
+
+	// scan utxo set for attestation unspents instead of importing addresses
+	// latest attestation addresses are watched in memory for scanning
+	scanUtxoSet bool
+	scanAddrs   []string
+
+	// label prefix of attestation addresses imported to the wallet
+	addrLabelPrefix string
+
+	// attestation transaction locktime - fixed value
+	// or current block height for anti fee sniping
+	locktime       uint32
+	locktimeHeight bool
+
+	// main client wallet is a descriptor wallet - addresses are
+	// imported via importdescriptors instead of importaddress
+	descriptorWallet bool
+
+	// number of retries on transient attestation send errors
+	sendRetries int
+
+	// minimum confirmations of the staychain unspent to build on
+	// zero allows chaining on unconfirmed attestations
+	minUnspentConf int
+
+	// strategy selecting the subchain unspents spent by the
+	// next attestation when multiple subchain unspents exist
+	unspentSelection string
+
+	// additional outputs encoding the commitment for redundancy
+	redundantOutputs []string
+
+	// fixed staychain output amount with any excess paid to the
+	// reserve address - two-output mode disabled if reserve not set
+	attestationAmount int64
+	reserveAddr       btcutil.Address
+
+	// log a trace of attestation transactions created and signed
+	debug bool
+
+	// unspents reserved by the in-flight attestation transaction
+	inFlight inFlightGuard
+
+	// commitment hash of the current and the previous signing round
+	// sigs tweaked with the previous commitment are detected as stale
+	signHash     chainhash.Hash
+	prevSignHash chainhash.Hash
+
 	// states whether Attest Client struct is used for transaction
 	// signing or simply for address tweaking and transaction creation
 	// in signer case the wallet priv key of the signer is imported
@@ -106,16 +256,70 @@ func NewAttestClient(config *confpkg.Config, signerFlag ...bool) *AttestClient {
 	if len(signerFlag) > 0 {
 		isSigner = signerFlag[0]
 	}
+	return newAttestClient(config, isSigner, true)
+}
+
+// NewAttestClientReadOnly returns a pointer to a new non signer AttestClient
+// instance that does not modify the main client wallet, i.e. the top-up
+// address is not imported, for tools that only read the staychain state
+func NewAttestClientReadOnly(config *confpkg.Config) *AttestClient {
+	return newAttestClient(config, false, false)
+}
+
+// Return new AttestClient instance, importing the top-up address to the
+// main client wallet if importTopup is set
+func newAttestClient(config *confpkg.Config, isSigner bool, importTopup bool) *AttestClient {
+	locktimeHeight, locktime, locktimeErr := parseLocktime(config.AttestationConfig().Locktime)
+	if locktimeErr != nil {
+		log.Fatal(locktimeErr)
+	}
+	redundantErr := verifyRedundantOutputs(config.AttestationConfig().RedundantOutputs)
+	if redundantErr != nil {
+		log.Fatal(redundantErr)
+	}
+	reserveAddr, reserveErr := parseReserveAddress(config.AttestationConfig(), config.MainChainCfg())
+	if reserveErr != nil {
+		log.Fatal(reserveErr)
+	}
+	minUnspentConf := config.AttestationConfig().MinUnspentConfirmations
+	if minUnspentConf < 0 {
+		minUnspentConf = DefaultMinUnspentConfirmations
+	}
+	unspentSelection, selectionErr := parseUnspentSelection(config.AttestationConfig().UnspentSelection)
+	if selectionErr != nil {
+		log.Fatal(selectionErr)
+	}
+
+	// named wallet set in config must be loaded by the main client
+	if wallet := config.MainWallet(); wallet != "" {
+		if walletErr := verifyWalletLoaded(config.MainClient(), wallet); walletErr != nil {
+			log.Fatal(walletErr)
+		}
+		log.Printf("*Client* using wallet %s\n", wallet)
+	}
+
+	// descriptor wallet set in config or detected from wallet info
+	descriptorWallet := config.AttestationConfig().DescriptorWallet || isDescriptorWallet(config.MainClient())
+	if descriptorWallet {
+		log.Println("*Client* using descriptor wallet")
+	}
 
 	// top up config
 	topupAddrStr := config.TopupAddress()
 	topupScriptStr := config.TopupScript()
 	var pkWifTopup *btcutil.WIF
 	if topupAddrStr != "" && topupScriptStr != "" {
-		log.Printf("*Client* importing top-up addr: %s ...\n", topupAddrStr)
-		importErr := config.MainClient().ImportAddress(topupAddrStr)
-		if importErr != nil {
-			log.Printf("%s (%s)\n%v\n", WarningFailureImportingTopupAddress, topupAddrStr, importErr)
+		if importTopup {
+			log.Printf("*Client* importing top-up addr: %s ...\n", topupAddrStr)
+			var importErr error
+			if descriptorWallet {
+				importErr = importAddrDescriptor(config.MainClient(), topupAddrStr, "", true)
+			} else {
+				importErr = config.MainClient().ImportAddress(topupAddrStr)
+			}
+			if importErr != nil {
+				log.Printf("%s (%s)\n%v\n", WarningFailureImportingTopupAddress, topupAddrStr, importErr)
+			}
 		}
 		if isSigner {
 			pkTopup := config.TopupPK()
@@ -194,36 +398,93 @@ func NewAttestClient(config *confpkg.Config, signerFlag ...bool) *AttestClient {
 		}
 
 		return &AttestClient{
-			MainClient:      config.MainClient(),
-			MainChainCfg:    config.MainChainCfg(),
-			Fees:            NewAttestFees(config.FeesConfig()),
-			txid0:           config.InitTx(),
-			script0:         multisig,
-			pubkeysExtended: pubkeysExtended,
-			pubkeys:         pubkeys,
-			chaincodes:      chaincodes,
-			numOfSigs:       numOfSigs,
-			addrTopup:       topupAddrStr,
-			scriptTopup:     topupScriptStr,
-			WalletPriv:      pkWif,
-			WalletPrivTopup: pkWifTopup,
-			WalletChainCode: myChaincode}
+			MainClient:        config.MainClient(),
+			MainChainCfg:      config.MainChainCfg(),
+			Fees:              NewAttestFees(config.FeesConfig(), config.MainClient()),
+			txid0:             config.InitTx(),
+			script0:           multisig,
+			pubkeysExtended:   pubkeysExtended,
+			pubkeys:           pubkeys,
+			chaincodes:        chaincodes,
+			numOfSigs:         numOfSigs,
+			addrTopup:         topupAddrStr,
+			scriptTopup:       topupScriptStr,
+			scanUtxoSet:       config.AttestationConfig().ScanUtxoSet,
+			addrLabelPrefix:   config.AttestationConfig().AddressLabelPrefix,
+			locktime:          locktime,
+			locktimeHeight:    locktimeHeight,
+			descriptorWallet:  descriptorWallet,
+			sendRetries:       config.AttestationConfig().SendRetries,
+			minUnspentConf:    minUnspentConf,
+			unspentSelection:  unspentSelection,
+			redundantOutputs:  config.AttestationConfig().RedundantOutputs,
+			attestationAmount: config.AttestationConfig().AttestationAmount,
+			reserveAddr:       reserveAddr,
+			debug:             config.AttestationConfig().Debug,
+			WalletPriv:        pkWif,
+			WalletPrivTopup:   pkWifTopup,
+			WalletChainCode:   myChaincode}
 	}
 	return &AttestClient{
-		MainClient:      config.MainClient(),
-		MainChainCfg:    config.MainChainCfg(),
-		Fees:            NewAttestFees(config.FeesConfig()),
-		txid0:           config.InitTx(),
-		script0:         multisig,
-		pubkeysExtended: nil,
-		pubkeys:         nil,
-		chaincodes:      nil,
-		numOfSigs:       1,
-		addrTopup:       topupAddrStr,
-		scriptTopup:     topupScriptStr,
-		WalletPriv:      pkWif,
-		WalletPrivTopup: pkWifTopup,
-		WalletChainCode: []byte{}}
+		MainClient:        config.MainClient(),
+		MainChainCfg:      config.MainChainCfg(),
+		Fees:              NewAttestFees(config.FeesConfig(), config.MainClient()),
+		txid0:             config.InitTx(),
+		script0:           multisig,
+		pubkeysExtended:   nil,
+		pubkeys:           nil,
+		chaincodes:        nil,
+		numOfSigs:         1,
+		addrTopup:         topupAddrStr,
+		scriptTopup:       topupScriptStr,
+		scanUtxoSet:       config.AttestationConfig().ScanUtxoSet,
+		addrLabelPrefix:   config.AttestationConfig().AddressLabelPrefix,
+		locktime:          locktime,
+		locktimeHeight:    locktimeHeight,
+		descriptorWallet:  descriptorWallet,
+		sendRetries:       config.AttestationConfig().SendRetries,
+		minUnspentConf:    minUnspentConf,
+		unspentSelection:  unspentSelection,
+		redundantOutputs:  config.AttestationConfig().RedundantOutputs,
+		attestationAmount: config.AttestationConfig().AttestationAmount,
+		reserveAddr:       reserveAddr,
+		debug:             config.AttestationConfig().Debug,
+		WalletPriv:        pkWif,
+		WalletPrivTopup:   pkWifTopup,
+		WalletChainCode:   []byte{}}
+}
+
+// Parse locktime config value which is either empty, the current
+// block height option or a fixed locktime value
+func parseLocktime(locktimeStr string) (bool, uint32, error) {
+	if locktimeStr == "" {
+		return false, 0, nil
+	} else if locktimeStr == LocktimeHeight {
+		return true, 0, nil
+	}
+	locktime, parseErr := strconv.ParseUint(locktimeStr, 10, 32)
+	if parseErr != nil {
+		return false, 0, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidLocktime, locktimeStr))
+	}
+	return false, uint32(locktime), nil
+}
+
+// Parse reserve address of two-output attestations from the attestation config
+// Both the attestation amount and the reserve address are required to enable
+// two-output attestations, while a nil address is returned if neither is set
+func parseReserveAddress(attestationConfig confpkg.AttestationConfig, chainCfg *chaincfg.Params) (btcutil.Address, error) {
+	amountSet := attestationConfig.AttestationAmount > 0
+	addrSet := attestationConfig.ReserveAddress != ""
+	if !amountSet && !addrSet {
+		return nil, nil
+	} else if !amountSet || !addrSet {
+		return nil, errors.New(ErrorInvalidReserveConfig)
+	}
+	addr, addrErr := btcutil.DecodeAddress(attestationConfig.ReserveAddress, chainCfg)
+	if addrErr != nil || !addr.IsForNet(chainCfg) {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidReserveAddress, attestationConfig.ReserveAddress))
+	}
+	return addr, nil
 }
 
 // Get next attestation key by tweaking with latest commitment hash
@@ -316,8 +577,17 @@ func (w *AttestClient) GetNextAttestationAddr(key *btcutil.WIF, hash chainhash.H
 // Method to import address to client rpc wallet and report import error
 // This address is required to watch unspent and mempool transactions
 // IDEALLY would import the P2SH script as well, but not supported by btcsuite
+// Address is labeled using the label prefix and the commitment hash
 // Optional argument to set rescan flag for import - default value set to true
-func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address, rescan ...bool) error {
+// In scan utxo set mode the address is watched in memory instead of imported
+// After import the address is verified to be watched by the wallet so that
+// a failed import does not cause unspents of the address to be missed
+func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address, hash chainhash.Hash, rescan ...bool) error {
+
+	if w.scanUtxoSet {
+		w.watchScanAddr(addr.String())
+		return nil
+	}
 
 	// check if rescan is set - defaults to true
 	var isRescan = true
@@ -326,11 +596,149 @@ func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address, rescan ...boo
 	}
 
 	// import address for unspent watching
-	importErr := w.MainClient.ImportAddressRescan(addr.String(), "", isRescan)
+	// descriptor wallets do not support importaddress
+	var importErr error
+	if w.descriptorWallet {
+		importErr = importAddrDescriptor(w.MainClient, addr.String(), w.GetAttestationAddrLabel(hash), isRescan)
+	} else {
+		importErr = w.MainClient.ImportAddressRescan(addr.String(), w.GetAttestationAddrLabel(hash), isRescan)
+	}
 	if importErr != nil {
 		return importErr
 	}
 
+	return verifyAddrWatched(w.MainClient, addr.String())
+}
+
+// Get wallet label of the attestation address for a commitment hash
+// Label prefix is omitted if not set
+func (w *AttestClient) GetAttestationAddrLabel(hash chainhash.Hash) string {
+	if w.addrLabelPrefix == "" {
+		return hash.String()
+	}
+	return fmt.Sprintf("%s-%s", w.addrLabelPrefix, hash.String())
+}
+
+// getwalletinfo rpc result - only descriptor flag required
+type walletInfoResult struct {
+	Descriptors bool `json:"descriptors"`
+}
+
+// getdescriptorinfo rpc result - only descriptor with checksum required
+type descriptorInfoResult struct {
+	Descriptor string `json:"descriptor"`
+}
+
+// importdescriptors rpc request
+type importDescriptorRequest struct {
+	Desc      string      `json:"desc"`
+	Label     string      `json:"label,omitempty"`
+	Timestamp interface{} `json:"timestamp"`
+}
+
+// importdescriptors rpc result
+type importDescriptorResult struct {
+	Success bool `json:"success"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// getaddressinfo rpc result - only wallet ownership flags required
+type addressInfoResult struct {
+	IsMine      bool `json:"ismine"`
+	IsWatchOnly bool `json:"iswatchonly"`
+}
+
+// Verify address is watched by the main client wallet using getaddressinfo
+// Addresses imported to legacy wallets are watch only, while addresses imported
+// to descriptor wallets are reported as mine. Clients that do not support
+// getaddressinfo report the same flags with validateaddress
+func verifyAddrWatched(client AttestRpcClient, addr string) error {
+	addrParam, _ := json.Marshal(addr)
+	resp, respErr := client.RawRequest("getaddressinfo", []json.RawMessage{addrParam})
+	if respErr != nil {
+		resp, respErr = client.RawRequest("validateaddress", []json.RawMessage{addrParam})
+		if respErr != nil {
+			return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorAddrNotWatched, addr, respErr))
+		}
+	}
+	var info addressInfoResult
+	if unmarshalErr := json.Unmarshal(resp, &info); unmarshalErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorAddrNotWatched, addr, unmarshalErr))
+	}
+	if !info.IsMine && !info.IsWatchOnly {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorAddrNotWatched, addr))
+	}
+	return nil
+}
+
+// Check that the named wallet is loaded by the main client using listwallets
+func verifyWalletLoaded(client AttestRpcClient, wallet string) error {
+	resp, respErr := client.RawRequest("listwallets", nil)
+	if respErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorWalletNotLoaded, wallet, respErr))
+	}
+	var wallets []string
+	if unmarshalErr := json.Unmarshal(resp, &wallets); unmarshalErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorWalletNotLoaded, wallet, unmarshalErr))
+	}
+	for _, loaded := range wallets {
+		if loaded == wallet {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("%s (%s)", ErrorWalletNotLoaded, wallet))
+}
+
+// Check if the main client wallet is a descriptor wallet using getwalletinfo
+// Older clients that do not report the descriptors flag are legacy wallets
+func isDescriptorWallet(client AttestRpcClient) bool {
+	resp, respErr := client.RawRequest("getwalletinfo", nil)
+	if respErr != nil {
+		return false
+	}
+	var result walletInfoResult
+	if unmarshalErr := json.Unmarshal(resp, &result); unmarshalErr != nil {
+		return false
+	}
+	return result.Descriptors
+}
+
+// Import address to a descriptor wallet as a watch only addr() descriptor
+// The descriptor checksum required by importdescriptors is fetched from the client
+// Rescan is done from the genesis block if set, otherwise from the current time
+func importAddrDescriptor(client AttestRpcClient, addr string, label string, rescan bool) error {
+	descParam, _ := json.Marshal(fmt.Sprintf("addr(%s)", addr))
+	infoResp, infoErr := client.RawRequest("getdescriptorinfo", []json.RawMessage{descParam})
+	if infoErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorImportDescriptorFailed, addr, infoErr))
+	}
+	var info descriptorInfoResult
+	if unmarshalErr := json.Unmarshal(infoResp, &info); unmarshalErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorImportDescriptorFailed, addr, unmarshalErr))
+	}
+
+	var timestamp interface{} = "now"
+	if rescan {
+		timestamp = 0
+	}
+	requestsParam, _ := json.Marshal([]importDescriptorRequest{
+		importDescriptorRequest{Desc: info.Descriptor, Label: label, Timestamp: timestamp}})
+	importResp, importErr := client.RawRequest("importdescriptors", []json.RawMessage{requestsParam})
+	if importErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorImportDescriptorFailed, addr, importErr))
+	}
+	var results []importDescriptorResult
+	if unmarshalErr := json.Unmarshal(importResp, &results); unmarshalErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorImportDescriptorFailed, addr, unmarshalErr))
+	}
+	if len(results) != 1 || !results[0].Success {
+		if len(results) == 1 && results[0].Error != nil {
+			return errors.New(fmt.Sprintf("%s (%s)\n%s", ErrorImportDescriptorFailed, addr, results[0].Error.Message))
+		}
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorImportDescriptorFailed, addr))
+	}
 	return nil
 }
 
@@ -338,8 +746,8 @@ func (w *AttestClient) ImportAttestationAddr(addr btcutil.Address, rescan ...boo
 // Transaction inputs are generated using the previous attestation
 // unspent as well as any additional topup inputs paid to wallet
 // Fees are calculated using AttestFees interface and RBF flag is set manually
-func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []btcjson.ListUnspentResult) (
-	*wire.MsgTx, error) {
+func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, commitment chainhash.Hash,
+	unspent []btcjson.ListUnspentResult) (*wire.MsgTx, error) {
 
 	// add inputs and amount for each unspent tx
 	var inputs []btcjson.TransactionInput
@@ -361,12 +769,30 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []bt
 		return nil, errCreate
 	}
 
+	// add redundant outputs after the staychain output so that these
+	// are accounted for in the fee calculation of the transaction
+	redundantErr := w.addRedundantOutputs(msgTx, commitment)
+	if redundantErr != nil {
+		return nil, redundantErr
+	}
+
 	// set replace-by-fee flag
 	// TODO: ? - currently only set RBF flag for attestation vin
 	msgTx.TxIn[0].Sequence = uint32(math.Pow(2, float64(32))) - 3
 
+	// set locktime - enforced as the vin sequence set for RBF is not final
+	if w.locktimeHeight {
+		height, heightErr := w.MainClient.GetBlockCount()
+		if heightErr != nil {
+			return nil, heightErr
+		}
+		msgTx.LockTime = uint32(height)
+	} else {
+		msgTx.LockTime = w.locktime
+	}
+
 	// return error if txout value is less than maxFee target
-	maxFee := calcSignedTxFee(w.Fees.maxFee, msgTx.SerializeSize(), len(w.script0)/2, w.numOfSigs)
+	maxFee := w.calcSignedAttestationFee(w.Fees.maxFee, msgTx)
 	if msgTx.TxOut[0].Value < maxFee {
 		return nil, errors.New(ErrorInsufficientFunds)
 	}
@@ -378,12 +804,102 @@ func (w *AttestClient) createAttestation(paytoaddr btcutil.Address, unspent []bt
 
 	// add fees using best fee-per-byte estimate
 	feePerByte := w.Fees.GetFee()
-	fee := calcSignedTxFee(feePerByte, msgTx.SerializeSize(), len(w.script0)/2, w.numOfSigs)
+	fee := w.calcSignedAttestationFee(feePerByte, msgTx)
 	msgTx.TxOut[0].Value -= fee
 
+	// pay any excess over the attestation amount to the reserve address
+	if w.reserveAddr != nil {
+		var reserveErr error
+		fee, reserveErr = w.addReserveOutput(msgTx, feePerByte, fee)
+		if reserveErr != nil {
+			return nil, reserveErr
+		}
+	}
+
+	// verify that output values and fee add up to the input value
+	var outputsValue int64
+	for _, txOut := range msgTx.TxOut {
+		outputsValue += txOut.Value
+	}
+	if outputsValue+fee != int64(amounts[paytoaddr]) {
+		return nil, errors.New(fmt.Sprintf("%s (%d + %d != %d)",
+			ErrorInvalidOutputValues, outputsValue, fee, int64(amounts[paytoaddr])))
+	}
+
+	w.logTransactionTrace("created attestation", msgTx)
 	return msgTx, nil
 }
 
+// Verify redundant output types set in config
+func verifyRedundantOutputs(redundantOutputs []string) error {
+	for _, outputType := range redundantOutputs {
+		if outputType != RedundantOutputOpReturn {
+			return errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidRedundantOutput, outputType))
+		}
+	}
+	return nil
+}
+
+// Add configured redundant outputs encoding the attestation commitment
+// The staychain output remains first and holds the full transaction value
+func (w *AttestClient) addRedundantOutputs(msgTx *wire.MsgTx, commitment chainhash.Hash) error {
+	for _, outputType := range w.redundantOutputs {
+		switch outputType {
+		case RedundantOutputOpReturn:
+			script, scriptErr := txscript.NullDataScript(commitment.CloneBytes())
+			if scriptErr != nil {
+				return scriptErr
+			}
+			msgTx.AddTxOut(wire.NewTxOut(0, script))
+		default:
+			return errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidRedundantOutput, outputType))
+		}
+	}
+	return nil
+}
+
+// Add reserve output paying the excess over the attestation amount to the
+// reserve address, after all other outputs, and return the transaction fee
+// The fee is recalculated to account for the reserve output and the output
+// is only added if the excess after the fee is at least the dust limit
+func (w *AttestClient) addReserveOutput(msgTx *wire.MsgTx, feePerByte int, fee int64) (int64, error) {
+	script, scriptErr := txscript.PayToAddrScript(w.reserveAddr)
+	if scriptErr != nil {
+		return 0, scriptErr
+	}
+	reserveOut := wire.NewTxOut(0, script)
+	msgTx.AddTxOut(reserveOut)
+
+	// total value available after the fee of the two-output transaction
+	reserveFee := w.calcSignedAttestationFee(feePerByte, msgTx)
+	excess := msgTx.TxOut[0].Value + fee - reserveFee - w.attestationAmount
+	if excess < ReserveOutputDustLimit {
+		msgTx.TxOut = msgTx.TxOut[:len(msgTx.TxOut)-1]
+		return fee, nil
+	}
+	msgTx.TxOut[0].Value = w.attestationAmount
+	reserveOut.Value = excess
+	return reserveFee, nil
+}
+
+// Get the index of the reserve output of an attestation transaction
+// Returns -1 if two-output attestations are disabled or there is no
+// reserve output, as the staychain output holds the full value
+func (w *AttestClient) getReserveOutputIndex(msgTx *wire.MsgTx) int {
+	if w.reserveAddr == nil || len(msgTx.TxOut) < 2 {
+		return -1
+	}
+	script, scriptErr := txscript.PayToAddrScript(w.reserveAddr)
+	if scriptErr != nil {
+		return -1
+	}
+	last := len(msgTx.TxOut) - 1
+	if !bytes.Equal(script, msgTx.TxOut[last].PkScript) {
+		return -1
+	}
+	return last
+}
+
 // Create new attestation transaction by removing sigs and
 // bumping fee of existing transaction with incremented fee
 // The latest fee is fetched from the AttestFees API, which
@@ -399,18 +915,68 @@ func (w *AttestClient) bumpAttestationFees(msgTx *wire.MsgTx) error {
 	w.Fees.BumpFee()
 	feePerByteIncrement := w.Fees.GetFee() - prevFeePerByte
 
-	// increase tx fees by fee difference
-	feeIncrement := calcSignedTxFee(feePerByteIncrement, msgTx.SerializeSize(), len(w.script0)/2, w.numOfSigs)
-	msgTx.TxOut[0].Value -= feeIncrement
+	// increase tx fees by fee difference - paid from the reserve
+	// output while above the dust limit or the staychain output
+	feeIncrement := w.calcSignedAttestationFee(feePerByteIncrement, msgTx)
+	reserveIndex := w.getReserveOutputIndex(msgTx)
+	if reserveIndex > 0 && msgTx.TxOut[reserveIndex].Value-feeIncrement >= ReserveOutputDustLimit {
+		msgTx.TxOut[reserveIndex].Value -= feeIncrement
+	} else {
+		msgTx.TxOut[0].Value -= feeIncrement
+	}
 
 	return nil
 }
 
+// Calculate the size of the push data opcodes required to push data of given size
+func calcPushDataSize(dataSize int) int {
+	if dataSize < txscript.OP_PUSHDATA1 {
+		return 1
+	} else if dataSize <= math.MaxUint8 {
+		return 2
+	} else if dataSize <= math.MaxUint16 {
+		return 3
+	}
+	return 5
+}
+
+// Calculate the estimated size of the scriptsig of a signed transaction input
+// In the multisig case this includes the OP_0, the signatures and the redeem script
+// In the no multisig case this includes the signature and the compressed pubkey
+func calcSignedScriptSigSize(scriptSize int, numOfSigs int) int {
+	if scriptSize == 0 {
+		return /*sig size byte*/ 1 + 72 + /*pubkey size byte*/ 1 + 33
+	}
+	return /*00 scriptsig byte*/ 1 + numOfSigs*( /*sig size byte*/ 1+72) +
+		calcPushDataSize(scriptSize) + scriptSize
+}
+
 // Calculate the size of a signed transaction by summing the unsigned tx size
 // and the redeem script size and estimated signature size of the scriptsig
+// The unsigned tx size already includes the single byte of the empty scriptsig length
 func calcSignedTxSize(unsignedTxSize int, scriptSize int, numOfSigs int) int {
-	return unsignedTxSize + /*script size byte*/ 1 + scriptSize +
-		/*00 scriptsig byte*/ 1 + numOfSigs*( /*sig size byte*/ 1+72)
+	scriptSigSize := calcSignedScriptSigSize(scriptSize, numOfSigs)
+	return unsignedTxSize + scriptSigSize + wire.VarIntSerializeSize(uint64(scriptSigSize)) - 1
+}
+
+// Calculate the size of a signed attestation transaction from the unsigned transaction
+// Subchain inputs are signed with the attestation script and any other inputs
+// are assumed to be topup inputs that are signed with the topup script
+func (w *AttestClient) calcSignedAttestationSize(msgTx *wire.MsgTx) int {
+	signedTxSize := msgTx.SerializeSize()
+	for i := range msgTx.TxIn {
+		scriptSize := len(w.script0) / 2
+		if !w.isSubchainInput(msgTx, i) {
+			scriptSize = len(w.scriptTopup) / 2
+		}
+		signedTxSize = calcSignedTxSize(signedTxSize, scriptSize, w.numOfSigs)
+	}
+	return signedTxSize
+}
+
+// Calculate the fee of a signed attestation transaction from the unsigned transaction
+func (w *AttestClient) calcSignedAttestationFee(feePerByte int, msgTx *wire.MsgTx) int64 {
+	return int64(feePerByte * w.calcSignedAttestationSize(msgTx))
 }
 
 // Calculate the actual fee of an unsigned transaction by taking into consideration
@@ -454,8 +1020,9 @@ func (w *AttestClient) GetScriptFromHash(hash chainhash.Hash) (string, error) {
 // Given a bitcoin transaction generate and return the transaction pre-image for
 // each of the inputs in the transaction. For each pre-image set the signature script
 // of the corresponding transaction input to the redeem script for this input
-// The redeem script of the first input is the tweaked (with latest commitment) init
-// script and the redeem script of any excess input is set to the topup script
+// The redeem script of the first input and of any merged subchain input is the tweaked
+// (with latest commitment) init script and the redeem script of any excess input is set
+// to the topup script
 func (w *AttestClient) getTransactionPreImages(hash chainhash.Hash, msgTx *wire.MsgTx) ([]wire.MsgTx, error) {
 
 	// check tx in size first
@@ -476,24 +1043,21 @@ func (w *AttestClient) getTransactionPreImages(hash chainhash.Hash, msgTx *wire.
 		return []wire.MsgTx{},
 			errors.New(fmt.Sprintf("%s for init script:%s\n", ErrorFailedDecodingInitMultisig, script))
 	}
-	// add init script bytes to txin script
-	preImageTx0 := msgTx.Copy()
-	preImageTx0.TxIn[0].SignatureScript = scriptSer
-	preImageTxs = append(preImageTxs, *preImageTx0)
-
-	// Add topup script to tx pre-image
-	if len(msgTx.TxIn) > 1 {
-		topupScriptSer, topupDecodeErr := hex.DecodeString(w.scriptTopup)
-		if topupDecodeErr != nil {
-			log.Printf("%s %s\n", WarningFailedDecodingTopupMultisig, w.scriptTopup)
-			return preImageTxs, nil
-		}
-		for i := 1; i < len(msgTx.TxIn); i++ {
-			// add topup script bytes to txin script
-			preImageTxi := msgTx.Copy()
-			preImageTxi.TxIn[i].SignatureScript = topupScriptSer
-			preImageTxs = append(preImageTxs, *preImageTxi)
+	for i := range msgTx.TxIn {
+		// add init script bytes to txin script of subchain inputs
+		inputScriptSer := scriptSer
+		if !w.isSubchainInput(msgTx, i) {
+			// add topup script bytes to txin script of any other input
+			topupScriptSer, topupDecodeErr := hex.DecodeString(w.scriptTopup)
+			if topupDecodeErr != nil {
+				log.Printf("%s %s\n", WarningFailedDecodingTopupMultisig, w.scriptTopup)
+				return preImageTxs, nil
+			}
+			inputScriptSer = topupScriptSer
 		}
+		preImageTxi := msgTx.Copy()
+		preImageTxi.TxIn[i].SignatureScript = inputScriptSer
+		preImageTxs = append(preImageTxs, *preImageTxi)
 	}
 
 	return preImageTxs, nil
@@ -518,55 +1082,180 @@ func (w *AttestClient) SignTransaction(hash chainhash.Hash, msgTx wire.MsgTx) (
 		return nil, "", errors.New(ErrorInputMissingForTx)
 	}
 
-	// get prev outpoint hash in order to generate tx inputs for signing
-	prevTxId := msgTx.TxIn[0].PreviousOutPoint.Hash
-	prevTx, prevTxErr := w.MainClient.GetRawTransaction(&prevTxId)
-	if prevTxErr != nil {
-		return nil, "", prevTxErr
-	}
-
 	var inputs []btcjson.RawTxInput // new tx inputs
 	var keys []string               // keys to sign inputs
 
-	// add prev attestation tx input info and priv key
-	inputs = append(inputs, btcjson.RawTxInput{prevTxId.String(), 0,
-		hex.EncodeToString(prevTx.MsgTx().TxOut[0].PkScript), redeemScript})
-	keys = append(keys, key.String())
-
-	// for any remaining vins - sign with topup privkey
-	// this should be a very rare occasion
-	for i := 1; i < len(msgTx.TxIn); i++ {
-		// fetch previous attestation transaction
-		prevTxId = msgTx.TxIn[i].PreviousOutPoint.Hash
-		prevTx, prevTxErr = w.MainClient.GetRawTransaction(&prevTxId)
-		if prevTxErr != nil {
-			return nil, "", prevTxErr
+	for i := range msgTx.TxIn {
+		// add prev attestation tx input info and priv key for subchain inputs
+		inputScript := redeemScript
+		inputKey := key.String()
+		if !w.isSubchainInput(&msgTx, i) {
+			// for any remaining vins - sign with topup privkey
+			// this should be a very rare occasion
+			inputScript = w.scriptTopup
+			inputKey = w.WalletPrivTopup.String()
+		}
+		input, inputErr := w.getSignRawTxInput(msgTx.TxIn[i].PreviousOutPoint, inputScript)
+		if inputErr != nil {
+			return nil, "", inputErr
 		}
-		inputs = append(inputs, btcjson.RawTxInput{prevTxId.String(), 0,
-			hex.EncodeToString(prevTx.MsgTx().TxOut[0].PkScript), w.scriptTopup})
-		keys = append(keys, w.WalletPrivTopup.String())
+		inputs = append(inputs, input)
+		keys = append(keys, inputKey)
 	}
 
 	// attempt to sign transcation with provided inputs - keys
-	signedMsgTx, _, errSign := w.MainClient.SignRawTransaction3(
+	signedMsgTx, complete, errSign := w.MainClient.SignRawTransaction3(
 		&msgTx, inputs, keys)
 	if errSign != nil {
 		return nil, "", errSign
 	}
+
+	// in the multisig case signatures of the other signers are still required
+	// while in the single signer case the transaction should be fully signed
+	if !complete && w.script0 == "" {
+		return nil, "", errors.New(ErrorSignIncomplete)
+	}
 	return signedMsgTx, redeemScript, nil
 }
 
+// Get the raw transaction input info required for signing an input spending the
+// outpoint provided, using the script pubkey of the output at the outpoint index
+// as the attestation output is not necessarily the first output of the prev tx
+func (w *AttestClient) getSignRawTxInput(prevOutPoint wire.OutPoint, redeemScript string) (btcjson.RawTxInput, error) {
+	prevTx, prevTxErr := w.MainClient.GetRawTransaction(&prevOutPoint.Hash)
+	if prevTxErr != nil {
+		return btcjson.RawTxInput{}, prevTxErr
+	}
+	prevTxOuts := prevTx.MsgTx().TxOut
+	if int(prevOutPoint.Index) >= len(prevTxOuts) {
+		return btcjson.RawTxInput{}, errors.New(fmt.Sprintf("%s (%s)", ErrorPrevOutNotFound, prevOutPoint.String()))
+	}
+	return btcjson.RawTxInput{prevOutPoint.Hash.String(), prevOutPoint.Index,
+		hex.EncodeToString(prevTxOuts[prevOutPoint.Index].PkScript), redeemScript}, nil
+}
+
+// Verify encoding of the signatures received for each transaction input
+// Sigs are expected to be strictly DER encoded, low S and SIGHASH_ALL
+// Error returned names the input and the signer the invalid sig came from
+func verifySigsEncoding(sigs [][]crypto.Sig) error {
+	for i_in, inputSigs := range sigs {
+		for i_s, sig := range inputSigs {
+			if sigErr := crypto.VerifySigEncoding(sig); sigErr != nil {
+				return errors.New(fmt.Sprintf("%s for input %d from signer %d: %v", ErrorInvalidSig, i_in, i_s, sigErr))
+			}
+		}
+	}
+	return nil
+}
+
+// Filter the signatures received for each transaction input keeping only
+// the ones that verify against one of the signer pubkeys of the input script
+// Subchain vins, i.e. vin 0 and merged subchain unspents, are verified against the
+// tweaked pubkeys of the redeem script provided and any other vin against the
+// pubkeys of the topup script
+// Subchain vin sigs verifying against the pubkeys of the stale redeem script instead,
+// i.e. tweaked with the previous commitment by a lagging signer, are dropped
+// Signatures are expected to have already passed the encoding check
+func (w *AttestClient) filterSigs(msgtx *wire.MsgTx, sigs [][]crypto.Sig, redeemScript string,
+	staleRedeemScript string) [][]crypto.Sig {
+	filteredSigs := make([][]crypto.Sig, len(sigs))
+	for i_in, inputSigs := range sigs {
+		if i_in >= len(msgtx.TxIn) {
+			filteredSigs[i_in] = inputSigs
+			continue
+		}
+		subchainInput := w.isSubchainInput(msgtx, i_in)
+		script := redeemScript
+		if !subchainInput {
+			script = w.scriptTopup
+		}
+		// no multisig script to verify against
+		if script == "" {
+			filteredSigs[i_in] = inputSigs
+			continue
+		}
+		scriptBytes, _ := hex.DecodeString(script)
+		pubkeys, _ := crypto.ParseRedeemScript(script)
+		var stalePubkeys []*btcec.PublicKey
+		if subchainInput && staleRedeemScript != "" && staleRedeemScript != script {
+			stalePubkeys, _ = crypto.ParseRedeemScript(staleRedeemScript)
+		}
+		sigHash, sigHashErr := txscript.CalcSignatureHash(scriptBytes, txscript.SigHashAll, msgtx, i_in)
+		if sigHashErr != nil {
+			log.Printf("%s for input %d: %v\n", WarningFailedCalculatingSigHash, i_in, sigHashErr)
+			continue
+		}
+		filteredSigs[i_in] = []crypto.Sig{}
+		for i_s, sig := range inputSigs {
+			signature, _ := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+			if !verifySigPubkeys(signature, sigHash, pubkeys) {
+				if verifySigPubkeys(signature, sigHash, stalePubkeys) {
+					log.Printf("%s for input %d from signer %d (current commitment %s)\n",
+						WarningSigStaleTweak, i_in, i_s, w.signHash.String())
+				} else {
+					log.Printf("%s for input %d from signer %d\n", WarningSigUnknownSigner, i_in, i_s)
+				}
+				continue
+			}
+			filteredSigs[i_in] = append(filteredSigs[i_in], sig)
+		}
+	}
+	return filteredSigs
+}
+
+// Check if signature verifies against any of the pubkeys provided
+func verifySigPubkeys(signature *btcec.Signature, sigHash []byte, pubkeys []*btcec.PublicKey) bool {
+	if signature == nil {
+		return false
+	}
+	for _, pubkey := range pubkeys {
+		if signature.Verify(sigHash, pubkey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Update the commitment hash of the signing round, keeping
+// the hash of the previous round to detect stale tweak sigs
+// Return the redeem script of the previous round commitment
+func (w *AttestClient) updateSignHash(hash chainhash.Hash) string {
+	if !hash.IsEqual(&w.signHash) {
+		w.prevSignHash = w.signHash
+		w.signHash = hash
+	}
+	if w.prevSignHash.IsEqual(&hash) {
+		return ""
+	}
+	staleRedeemScript, staleErr := w.GetScriptFromHash(w.prevSignHash)
+	if staleErr != nil {
+		return ""
+	}
+	return staleRedeemScript
+}
+
 // Sign the attestation transaction provided with the received signatures
 // In the client signer case, client additionally adds sigs as well to the transaction
+// Received sigs not verifying against any of the expected signer pubkeys are dropped
 // Sigs are then combined and added to the attestation transaction inputs
 func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]crypto.Sig, hash chainhash.Hash) (
 	*wire.MsgTx, error) {
+	// check encoding of received sigs before combining
+	if sigsErr := verifySigsEncoding(sigs); sigsErr != nil {
+		return nil, sigsErr
+	}
+
 	// set tx pointer and redeem script
 	signedMsgTx := msgtx
 	redeemScript, redeemScriptErr := w.GetScriptFromHash(hash)
 	if redeemScriptErr != nil {
 		return nil, redeemScriptErr
 	}
+
+	// drop received sigs that are not from the expected signers
+	// or are tweaked with the commitment of the previous signing round
+	staleRedeemScript := w.updateSignHash(hash)
+	sigs = w.filterSigs(msgtx, sigs, redeemScript, staleRedeemScript)
 	if w.WalletPriv != nil { // sign transaction - signer case only
 		// sign generated transaction
 		var errSign error
@@ -603,8 +1292,8 @@ func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]crypto.Sig, h
 				}
 				// no mySigs - just use received client sigs and script
 				var redeemScriptBytes []byte
-				if i == 0 {
-					// for vin 0, use last attestation script
+				if w.isSubchainInput(signedMsgTx, i) {
+					// for vin 0 and merged subchain vins, use last attestation script
 					redeemScriptBytes, _ = hex.DecodeString(redeemScript)
 				} else {
 					// for any other vin, use topup script as we assume topup use only
@@ -616,24 +1305,195 @@ func (w *AttestClient) signAttestation(msgtx *wire.MsgTx, sigs [][]crypto.Sig, h
 		}
 	}
 
+	w.logTransactionTrace("signed attestation", signedMsgTx)
 	return signedMsgTx, nil
 }
 
+// Check if the transaction output provided pays to the attestation address
+// derived from the commitment hash, returning the expected address
+func (w *AttestClient) paysToAttestationAddr(txOut *wire.TxOut, hash chainhash.Hash) (btcutil.Address, bool, error) {
+	addr, _, addrErr := w.GetNextAttestationAddr((*btcutil.WIF)(nil), hash)
+	if addrErr != nil {
+		return nil, false, addrErr
+	}
+	pkScript, pkScriptErr := txscript.PayToAddrScript(addr)
+	if pkScriptErr != nil {
+		return nil, false, pkScriptErr
+	}
+	return addr, bytes.Equal(pkScript, txOut.PkScript), nil
+}
+
+// Verify the attestation transaction with the txid provided pays to the
+// attestation address derived from the commitment hash and the init keys
+// Used to audit stored attestations against the main chain
+func (w *AttestClient) VerifyAttestation(txid chainhash.Hash, hash chainhash.Hash) error {
+	txraw, txErr := w.MainClient.GetRawTransaction(&txid)
+	if txErr != nil {
+		return txErr
+	}
+	if len(txraw.MsgTx().TxOut) == 0 {
+		return errors.New(ErrorInputMissingForTx)
+	}
+	addr, paysToAddr, addrErr := w.paysToAttestationAddr(txraw.MsgTx().TxOut[0], hash)
+	if addrErr != nil {
+		return addrErr
+	}
+	if !paysToAddr {
+		return errors.New(fmt.Sprintf("%s (%s) %s", ErrorAttestationAddressMismatch, txid.String(), addr.String()))
+	}
+	return nil
+}
+
+// Create a sweep transaction spending the output of the attestation transaction
+// provided to the destination address, in order to recover the staychain funds
+// when the service is decommissioned. The output is verified to pay to the
+// attestation address derived from the commitment hash of the attestation
+func (w *AttestClient) CreateSweepTransaction(txid chainhash.Hash, hash chainhash.Hash,
+	paytoaddr btcutil.Address, feePerByte int) (*wire.MsgTx, error) {
+
+	txraw, txErr := w.MainClient.GetRawTransaction(&txid)
+	if txErr != nil {
+		return nil, txErr
+	}
+	if len(txraw.MsgTx().TxOut) == 0 {
+		return nil, errors.New(ErrorInputMissingForTx)
+	}
+	txOut := txraw.MsgTx().TxOut[0]
+
+	// verify output pays to the attestation address of the commitment
+	addr, paysToAddr, addrErr := w.paysToAttestationAddr(txOut, hash)
+	if addrErr != nil {
+		return nil, addrErr
+	}
+	if !paysToAddr {
+		return nil, errors.New(fmt.Sprintf("%s (%s) %s", ErrorSweepAddressMismatch, txid.String(), addr.String()))
+	}
+
+	// pay all funds minus the fee to the destination address
+	payToScript, payToScriptErr := txscript.PayToAddrScript(paytoaddr)
+	if payToScriptErr != nil {
+		return nil, payToScriptErr
+	}
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid, 0), nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(txOut.Value, payToScript))
+
+	fee := w.calcSignedAttestationFee(feePerByte, msgTx)
+	if txOut.Value <= fee {
+		return nil, errors.New(ErrorInsufficientFunds)
+	}
+	msgTx.TxOut[0].Value -= fee
+
+	return msgTx, nil
+}
+
+// Sign sweep transaction with the quorum of signer clients provided
+// The signatures of each signer are combined with the signature of
+// this client, with signers expected in the init script pubkey order
+func (w *AttestClient) SignSweepTransaction(msgTx *wire.MsgTx, hash chainhash.Hash,
+	signers []*AttestClient) (*wire.MsgTx, error) {
+
+	var sigs []crypto.Sig
+	for _, signer := range signers {
+		signedMsgTx, _, signErr := signer.SignTransaction(hash, *msgTx)
+		if signErr != nil {
+			return nil, signErr
+		}
+		signerSigs, _ := crypto.ParseScriptSig(signedMsgTx.TxIn[0].SignatureScript)
+		sigs = append(sigs, signerSigs...)
+	}
+	return w.signAttestation(msgTx.Copy(), [][]crypto.Sig{sigs}, hash)
+}
+
 // Send the latest attestation transaction through rpc bitcoin client connection
-func (w *AttestClient) sendAttestation(msgtx *wire.MsgTx) (chainhash.Hash, error) {
+// Transient errors are retried with backoff up to the configured number of retries
+// Permanent rejections are returned immediately as SendRejectedError
+// Retrying stops with the context error if the context is cancelled
+func (w *AttestClient) sendAttestation(ctx context.Context, msgtx *wire.MsgTx) (chainhash.Hash, error) {
 
 	// send signed attestation
-	txhash, errSend := w.MainClient.SendRawTransaction(msgtx, false)
-	if errSend != nil {
-		return chainhash.Hash{}, errSend
+	backoff := sendRetryBackoff
+	for retry := 0; ; retry++ {
+		txhash, errSend := w.MainClient.SendRawTransaction(msgtx, false)
+		if errSend == nil {
+			return *txhash, nil
+		}
+		if rpcErr, rejected := getSendRejection(errSend); rejected {
+			return chainhash.Hash{}, SendRejectedError{rpcErr}
+		}
+		if retry >= w.sendRetries {
+			return chainhash.Hash{}, errSend
+		}
+		log.Printf("%s (%d/%d) %v\n", WarningSendAttestationRetry, retry+1, w.sendRetries, errSend)
+		if backoff > sendRetryBackoffMax {
+			backoff = sendRetryBackoffMax
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return chainhash.Hash{}, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Set staychain checkpoint txid to be trusted as the subchain root
+// Zero hash is ignored and subchain verification reverts to genesis
+func (w *AttestClient) SetCheckpoint(txid chainhash.Hash) {
+	if (txid == chainhash.Hash{}) {
+		w.checkpoint = ""
+		return
 	}
+	w.checkpoint = txid.String()
+}
 
-	return *txhash, nil
+// Check if txid is a trusted subchain root, i.e. genesis or checkpoint
+func (w *AttestClient) isSubchainRoot(txid chainhash.Hash) bool {
+	return txid.String() == w.txid0 || (w.checkpoint != "" && txid.String() == w.checkpoint)
+}
+
+// Find checkpoint candidate that is depth attestations behind the tip txid
+// Returns false if a subchain root is reached before reaching this depth
+func (w *AttestClient) findCheckpoint(tipTxid chainhash.Hash, depth int) (bool, chainhash.Hash, error) {
+	txid := tipTxid
+	for i := 0; i < depth; i++ {
+		if w.isSubchainRoot(txid) {
+			return false, chainhash.Hash{}, nil
+		}
+		txraw, err := w.MainClient.GetRawTransaction(&txid)
+		if err != nil {
+			return false, chainhash.Hash{}, err
+		}
+		txid = txraw.MsgTx().TxIn[0].PreviousOutPoint.Hash
+	}
+	if w.isSubchainRoot(txid) {
+		return false, chainhash.Hash{}, nil
+	}
+	return true, txid, nil
+}
+
+// Verify a checkpoint transaction before it is recorded
+// The transaction must be confirmed and on the attestation subchain
+func (w *AttestClient) VerifyCheckpoint(txid chainhash.Hash) error {
+	tx, txErr := w.getTransaction(&txid)
+	if txErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorCheckpointNotConfirmed, txid.String(), txErr))
+	}
+	if tx.Confirmations < 1 {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorCheckpointNotConfirmed, txid.String()))
+	}
+	if !w.verifyTxOnSubchain(txid) {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorCheckpointNotOnSubchain, txid.String()))
+	}
+	return nil
 }
 
 // Verify that an unspent vout is on the tip of the subchain attestations
+// Walk back until either the genesis transaction or the checkpoint is found
 func (w *AttestClient) verifyTxOnSubchain(txid chainhash.Hash) bool {
-	if txid.String() == w.txid0 { // genesis transaction
+	if w.isSubchainRoot(txid) { // genesis or checkpoint transaction
 		return true
 	} else {
 		// might be better to store subchain on init
@@ -649,20 +1509,288 @@ func (w *AttestClient) verifyTxOnSubchain(txid chainhash.Hash) bool {
 	return false
 }
 
+// Check if a transaction input spends a subchain unspent - the first input always
+// does, while any other input is either a merged subchain unspent or a topup
+func (w *AttestClient) isSubchainInput(msgTx *wire.MsgTx, i int) bool {
+	return i == 0 || w.verifyTxOnSubchain(msgTx.TxIn[i].PreviousOutPoint.Hash)
+}
+
+// Verify the genesis transaction that funds the attestation staychain
+// Check that the transaction is confirmed deep enough in the main chain
+// and that one of its outputs pays to the address derived from the init
+// script pubkeys or the init key in the no multisig case
+func (w *AttestClient) VerifyGenesis(minConfirmations ...int64) error {
+	minConfs := int64(DefaultGenesisMinConfirmations)
+	if len(minConfirmations) > 0 {
+		minConfs = minConfirmations[0]
+	}
+
+	txid0Hash, hashErr := chainhash.NewHashFromStr(w.txid0)
+	if hashErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorInitTxNotFound, w.txid0, hashErr))
+	}
+	tx, txErr := w.getTransaction(txid0Hash)
+	if txErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorInitTxNotFound, w.txid0, txErr))
+	}
+	if tx.Confirmations < minConfs {
+		return errors.New(fmt.Sprintf("%s (%s) %d < %d", ErrorGenesisNotConfirmed, w.txid0, tx.Confirmations, minConfs))
+	}
+	_, voutErr := w.getGenesisVout(tx)
+	return voutErr
+}
+
+// Return vout of the genesis transaction output paying to the genesis
+// address, i.e. the address of the init script with no tweaking
+func (w *AttestClient) getGenesisVout(tx *btcjson.GetTransactionResult) (uint32, error) {
+	// get address of init script with no tweaking
+	genesisAddr, _, addrErr := w.GetNextAttestationAddr(w.WalletPriv, chainhash.Hash{})
+	if addrErr != nil {
+		return 0, addrErr
+	}
+	genesisPkScript, pkScriptErr := txscript.PayToAddrScript(genesisAddr)
+	if pkScriptErr != nil {
+		return 0, pkScriptErr
+	}
+
+	// decode genesis transaction and check for output paying to genesis address
+	txBytes, decodeErr := hex.DecodeString(tx.Hex)
+	if decodeErr != nil {
+		return 0, decodeErr
+	}
+	var msgTx wire.MsgTx
+	if deserializeErr := msgTx.Deserialize(bytes.NewReader(txBytes)); deserializeErr != nil {
+		return 0, deserializeErr
+	}
+	for i, txOut := range msgTx.TxOut {
+		if bytes.Equal(txOut.PkScript, genesisPkScript) {
+			return uint32(i), nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("%s (%s) %s", ErrorGenesisAddressMismatch, w.txid0, genesisAddr.String()))
+}
+
+// Verify the init transaction on startup
+// The init transaction must be found in the main client wallet and either
+// its output paying to the genesis address must be unspent or an unspent
+// must exist on the subchain tip
+func (w *AttestClient) verifyInitTx() error {
+	txid0Hash, hashErr := chainhash.NewHashFromStr(w.txid0)
+	if hashErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorInitTxNotFound, w.txid0, hashErr))
+	}
+	tx, txErr := w.getTransaction(txid0Hash)
+	if txErr != nil {
+		return errors.New(fmt.Sprintf("%s (%s)\n%v", ErrorInitTxNotFound, w.txid0, txErr))
+	}
+
+	// check if init output paying to the genesis address is still unspent, including mempool
+	vout, voutErr := w.getGenesisVout(tx)
+	if voutErr != nil {
+		return voutErr
+	}
+	txOut, txOutErr := w.MainClient.GetTxOut(txid0Hash, vout, true)
+	if txOutErr != nil {
+		return txOutErr
+	} else if txOut != nil {
+		return nil
+	}
+
+	// init output spent - verify that the subchain has an unspent or unconfirmed tip
+	found, _, unspentErr := w.findLastUnspent()
+	if unspentErr != nil {
+		return unspentErr
+	} else if found {
+		return nil
+	}
+	foundUnconfirmed, _, unconfirmedErr := w.getUnconfirmedTx()
+	if unconfirmedErr != nil {
+		return unconfirmedErr
+	} else if foundUnconfirmed {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("%s (%s)", ErrorInitTxNoUnspent, w.txid0))
+}
+
+// Add address to the latest attestation addresses watched for utxo set scanning
+// Only the latest MaxScanAddresses addresses are kept
+func (w *AttestClient) watchScanAddr(addr string) {
+	for _, scanAddr := range w.scanAddrs {
+		if scanAddr == addr {
+			return
+		}
+	}
+	w.scanAddrs = append(w.scanAddrs, addr)
+	if len(w.scanAddrs) > MaxScanAddresses {
+		w.scanAddrs = w.scanAddrs[len(w.scanAddrs)-MaxScanAddresses:]
+	}
+}
+
+// scantxoutset rpc result
+type scanTxOutSetResult struct {
+	Success  bool                  `json:"success"`
+	Height   int64                 `json:"height"`
+	Unspents []scanTxOutSetUnspent `json:"unspents"`
+}
+
+// scantxoutset rpc result unspent
+type scanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Amount       float64 `json:"amount"`
+	Height       int64   `json:"height"`
+}
+
+// Scan the main client utxo set for unspents of the watched attestation addresses
+// Uses address descriptors so that no addresses are imported to the wallet
+// Only confirmed unspents are returned as mempool is not part of the utxo set
+func (w *AttestClient) scanUnspent() ([]btcjson.ListUnspentResult, error) {
+	if len(w.scanAddrs) == 0 {
+		return nil, nil
+	}
+	var descriptors []string
+	for _, addr := range w.scanAddrs {
+		descriptors = append(descriptors, fmt.Sprintf("addr(%s)", addr))
+	}
+	actionParam, _ := json.Marshal("start")
+	descriptorsParam, _ := json.Marshal(descriptors)
+	resp, respErr := w.MainClient.RawRequest("scantxoutset",
+		[]json.RawMessage{actionParam, descriptorsParam})
+	if respErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s\n%v", ErrorScanUtxoSetFailed, respErr))
+	}
+	var result scanTxOutSetResult
+	if unmarshalErr := json.Unmarshal(resp, &result); unmarshalErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s\n%v", ErrorScanUtxoSetFailed, unmarshalErr))
+	}
+	if !result.Success {
+		return nil, errors.New(ErrorScanUtxoSetFailed)
+	}
+
+	var unspent []btcjson.ListUnspentResult
+	for _, u := range result.Unspents {
+		unspent = append(unspent, btcjson.ListUnspentResult{
+			TxID:          u.TxID,
+			Vout:          u.Vout,
+			ScriptPubKey:  u.ScriptPubKey,
+			Amount:        u.Amount,
+			Confirmations: result.Height - u.Height + 1})
+	}
+	return unspent, nil
+}
+
+// Get main client transaction information, i.e. confirmations and block
+// In scan utxo set mode the wallet may not be available, so the information
+// is retrieved with verbose getrawtransaction instead, which requires txindex
+func (w *AttestClient) getTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	if !w.scanUtxoSet {
+		return w.MainClient.GetTransaction(txid)
+	}
+	rawTx, rawErr := w.MainClient.GetRawTransactionVerbose(txid)
+	if rawErr != nil {
+		return nil, rawErr
+	}
+	return &btcjson.GetTransactionResult{
+		TxID:          rawTx.Txid,
+		Hex:           rawTx.Hex,
+		Confirmations: int64(rawTx.Confirmations),
+		BlockHash:     rawTx.BlockHash,
+		BlockTime:     rawTx.Blocktime,
+		Time:          rawTx.Time,
+		TimeReceived:  rawTx.Time}, nil
+}
+
 // Find the latest unspent vout that is on the tip of subchain attestations
 func (w *AttestClient) findLastUnspent() (bool, btcjson.ListUnspentResult, error) {
-	unspent, err := w.MainClient.ListUnspent()
+	subchainUnspent, err := w.findSubchainUnspents()
 	if err != nil {
 		return false, btcjson.ListUnspentResult{}, err
 	}
+	return w.selectLastUnspent(subchainUnspent)
+}
+
+// Find all unspent vouts on the subchain of attestations
+// In scan utxo set mode unspents of the watched addresses are scanned instead
+// Only unspents with at least the min unspent confirmations are considered
+func (w *AttestClient) findSubchainUnspents() ([]btcjson.ListUnspentResult, error) {
+	var unspent []btcjson.ListUnspentResult
+	var err error
+	if w.scanUtxoSet {
+		unspent, err = w.scanUnspent()
+	} else {
+		unspent, err = w.MainClient.ListUnspentMin(w.minUnspentConf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var subchainUnspent []btcjson.ListUnspentResult
 	for _, vout := range unspent {
+		if vout.Confirmations < int64(w.minUnspentConf) {
+			continue
+		}
 		txhash, _ := chainhash.NewHashFromStr(vout.TxID)
 		if w.verifyTxOnSubchain(*txhash) {
-			//theoretically only one unspent vout, but check anyway
-			return true, vout, nil
+			subchainUnspent = append(subchainUnspent, vout)
 		}
 	}
-	return false, btcjson.ListUnspentResult{}, nil
+	return subchainUnspent, nil
+}
+
+// Select the latest unspent out of the subchain unspents found
+func (w *AttestClient) selectLastUnspent(subchainUnspent []btcjson.ListUnspentResult) (bool, btcjson.ListUnspentResult, error) {
+	if len(subchainUnspent) == 0 {
+		return false, btcjson.ListUnspentResult{}, nil
+	} else if len(subchainUnspent) == 1 {
+		return true, subchainUnspent[0], nil
+	}
+
+	// multiple subchain unspents can exist transiently, i.e. after
+	// fee bumping or before the wallet has processed a mempool spend
+	log.Printf("*Client* Found %d subchain unspents\n", len(subchainUnspent))
+	return w.selectSubchainTip(subchainUnspent)
+}
+
+// Select the staychain tip out of multiple subchain unspents
+// Unspents already spent by a mempool transaction are excluded
+// and of the remaining the most recent unspent is selected, with
+// ties broken by txid order so that the selection is deterministic
+func (w *AttestClient) selectSubchainTip(unspent []btcjson.ListUnspentResult) (bool, btcjson.ListUnspentResult, error) {
+	var tip *btcjson.ListUnspentResult
+	for i, u := range unspent {
+		spent, spentErr := w.isSpentInMempool(u)
+		if spentErr != nil {
+			return false, btcjson.ListUnspentResult{}, spentErr
+		}
+		if spent {
+			log.Printf("*Client* Subchain unspent %s:%d spent in mempool\n", u.TxID, u.Vout)
+			continue
+		}
+		if tip == nil || u.Confirmations < tip.Confirmations ||
+			(u.Confirmations == tip.Confirmations && u.TxID < tip.TxID) {
+			tip = &unspent[i]
+		}
+	}
+	if tip == nil {
+		return false, btcjson.ListUnspentResult{}, nil
+	}
+	log.Printf("*Client* Selected subchain unspent %s:%d\n", tip.TxID, tip.Vout)
+	return true, *tip, nil
+}
+
+// Check if an unspent is spent by a transaction in the main client mempool
+// The unspent output is queried directly including the mempool, for which
+// no output is returned if spent, instead of fetching every mempool transaction
+func (w *AttestClient) isSpentInMempool(unspent btcjson.ListUnspentResult) (bool, error) {
+	txhash, hashErr := chainhash.NewHashFromStr(unspent.TxID)
+	if hashErr != nil {
+		return false, hashErr
+	}
+	txOut, txOutErr := w.MainClient.GetTxOut(txhash, unspent.Vout, true)
+	if txOutErr != nil {
+		return false, txOutErr
+	}
+	return txOut == nil, nil
 }
 
 // Find unspent vout for topup address specified in attestation client init
@@ -694,3 +1822,112 @@ func (w *AttestClient) getUnconfirmedTx() (bool, chainhash.Hash, error) {
 	}
 	return false, chainhash.Hash{}, nil
 }
+
+// CurrentTip structure
+// Authoritative staychain tip reconciled from the wallet
+// subchain unspents and the subchain mempool transactions
+type CurrentTip struct {
+	// tip found - false if neither unspent nor unconfirmed found
+	Found bool
+
+	// tip is an unconfirmed mempool transaction with txid Txid
+	// otherwise the tip is the confirmed unspent Unspent
+	Unconfirmed bool
+	Txid        chainhash.Hash
+	Unspent     btcjson.ListUnspentResult
+
+	// reasoning for the tip decision
+	Reason string
+}
+
+// Determine the current staychain tip out of confirmed subchain unspents
+// and subchain mempool transactions, e.g. after an unclean shutdown
+// The unconfirmed tip is the latest subchain mempool transaction, i.e. not
+// spent by any other mempool transaction, and is preferred if no confirmed
+// unspent is found or if the confirmed output it descends from is at least
+// as recent as the confirmed unspent, as the unconfirmed transaction then
+// extends the confirmed staychain
+func (w *AttestClient) determineCurrentTip() (CurrentTip, error) {
+	mempool, mempoolErr := w.MainClient.GetRawMempool()
+	if mempoolErr != nil {
+		return CurrentTip{}, mempoolErr
+	}
+
+	// find subchain mempool transactions and the outpoints spent by them
+	unconfirmed := make(map[chainhash.Hash]*wire.MsgTx)
+	mempoolSpent := make(map[wire.OutPoint]bool)
+	for _, hash := range mempool {
+		if !w.verifyTxOnSubchain(*hash) {
+			continue
+		}
+		tx, txErr := w.MainClient.GetRawTransaction(hash)
+		if txErr != nil {
+			return CurrentTip{}, txErr
+		}
+		unconfirmed[*hash] = tx.MsgTx()
+		for _, txIn := range tx.MsgTx().TxIn {
+			mempoolSpent[txIn.PreviousOutPoint] = true
+		}
+	}
+
+	// select latest unconfirmed transaction with ties broken by txid order
+	var unconfirmedTip *chainhash.Hash
+	for txid := range unconfirmed {
+		if mempoolSpent[*wire.NewOutPoint(&txid, 0)] {
+			continue
+		}
+		if unconfirmedTip == nil || txid.String() < unconfirmedTip.String() {
+			tipTxid := txid
+			unconfirmedTip = &tipTxid
+		}
+	}
+
+	found, unspent, unspentErr := w.findLastUnspent()
+	if unspentErr != nil {
+		return CurrentTip{}, unspentErr
+	}
+
+	var tip CurrentTip
+	switch {
+	case unconfirmedTip == nil && !found:
+		tip = CurrentTip{Reason: "no subchain unspent or unconfirmed transaction found"}
+	case unconfirmedTip == nil:
+		tip = CurrentTip{Found: true, Unspent: unspent,
+			Reason: "confirmed unspent with no unconfirmed transaction"}
+	case !found:
+		tip = CurrentTip{Found: true, Unconfirmed: true, Txid: *unconfirmedTip,
+			Reason: "unconfirmed transaction with no confirmed unspent"}
+	default:
+		// walk back the unconfirmed chain to the confirmed output it spends
+		root := unconfirmed[*unconfirmedTip].TxIn[0].PreviousOutPoint
+		for {
+			parent, ok := unconfirmed[root.Hash]
+			if !ok {
+				break
+			}
+			root = parent.TxIn[0].PreviousOutPoint
+		}
+		rootTx, rootErr := w.getTransaction(&root.Hash)
+		if rootErr != nil {
+			return CurrentTip{}, rootErr
+		}
+		if rootTx.Confirmations > 0 && rootTx.Confirmations <= unspent.Confirmations {
+			tip = CurrentTip{Found: true, Unconfirmed: true, Txid: *unconfirmedTip,
+				Reason: fmt.Sprintf("unconfirmed transaction spends confirmed output %s:%d at least as recent as unspent %s:%d",
+					root.Hash.String(), root.Index, unspent.TxID, unspent.Vout)}
+		} else {
+			tip = CurrentTip{Found: true, Unspent: unspent,
+				Reason: fmt.Sprintf("unconfirmed transaction %s spends output %s:%d older than confirmed unspent",
+					unconfirmedTip.String(), root.Hash.String(), root.Index)}
+		}
+	}
+
+	if tip.Unconfirmed {
+		log.Printf("*Client* Current tip unconfirmed %s - %s\n", tip.Txid.String(), tip.Reason)
+	} else if tip.Found {
+		log.Printf("*Client* Current tip unspent %s:%d - %s\n", tip.Unspent.TxID, tip.Unspent.Vout, tip.Reason)
+	} else {
+		log.Printf("*Client* Current tip not found - %s\n", tip.Reason)
+	}
+	return tip, nil
+}
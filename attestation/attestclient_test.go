@@ -5,10 +5,14 @@
 package attestation
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"mainstay/clients"
 	confpkg "mainstay/config"
@@ -16,10 +20,14 @@ import (
 	"mainstay/models"
 	testpkg "mainstay/test"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -87,7 +95,7 @@ func verifyKeysAndAddr(t *testing.T, client *AttestClient, hash chainhash.Hash)
 	assert.Equal(t, script, scriptTest)
 
 	// test importing address
-	importErr := client.ImportAttestationAddr(addr)
+	importErr := client.ImportAttestationAddr(addr, hash)
 	assert.Equal(t, nil, importErr)
 
 	return addr, script
@@ -189,6 +197,15 @@ func TestAttestClient_Signer(t *testing.T) {
 	client := NewAttestClient(test.Config, true) // set isSigner flag
 	txs := []string{client.txid0}
 
+	// Verify init transaction with init output unspent
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// Verify genesis transaction confirmations and address
+	assert.Equal(t, nil, client.VerifyGenesis(1))
+	genesisErr := client.VerifyGenesis()
+	assert.NotEqual(t, nil, genesisErr)
+	assert.Equal(t, true, strings.HasPrefix(genesisErr.Error(), ErrorGenesisNotConfirmed))
+
 	// Find unspent and verify is it the genesis transaction
 	unspent := verifyFirstUnspent(t, client)
 	assert.Equal(t, txs[0], unspent.TxID)
@@ -218,7 +235,7 @@ func TestAttestClient_Signer(t *testing.T) {
 		}
 
 		// test creating attestation transaction
-		tx, attestationErr := client.createAttestation(addr, unspentList)
+		tx, attestationErr := client.createAttestation(addr, chainhash.Hash{}, unspentList)
 		assert.Equal(t, nil, attestationErr)
 		assert.Equal(t, 1-1*int(math.Min(0, float64((i%(topupLevel+1)-1)))), len(tx.TxIn))
 		assert.Equal(t, 1, len(tx.TxOut))
@@ -234,7 +251,14 @@ func TestAttestClient_Signer(t *testing.T) {
 		// test signing and sending attestation
 		signedTx, signErr := client.signAttestation(tx, [][]crypto.Sig{}, lastHash)
 		assert.Equal(t, nil, signErr)
-		txid, sendErr := client.sendAttestation(signedTx)
+
+		// test estimated signed size is an upper bound close to the actual signed size
+		// signature sizes can vary by a couple of bytes due to DER encoding
+		estimatedSize := client.calcSignedAttestationSize(tx)
+		assert.Equal(t, true, signedTx.SerializeSize() <= estimatedSize)
+		assert.Equal(t, true, estimatedSize-signedTx.SerializeSize() <= 3*len(tx.TxIn)*client.numOfSigs)
+
+		txid, sendErr := client.sendAttestation(context.Background(), signedTx)
 		assert.Equal(t, nil, sendErr)
 
 		sideClientFake.Generate(1)
@@ -248,7 +272,7 @@ func TestAttestClient_Signer(t *testing.T) {
 			topupHash = createTopupUnspent(t, test.Config)
 		}
 
-		client.MainClient.Generate(1)
+		test.Config.MainClient().Generate(1)
 
 		// Verify no more unconfirmed transactions after new block generation
 		verifyNoUnconfirmed(t, client)
@@ -263,6 +287,41 @@ func TestAttestClient_Signer(t *testing.T) {
 	assert.Equal(t, len(txs), iterNum+1)
 
 	verifyTxs(t, client, txs)
+
+	// Verify init transaction with init output spent
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// Verify checkpoint search from the tip
+	tipTxid, _ := chainhash.NewHashFromStr(txs[iterNum])
+	found, checkpoint, checkpointErr := client.findCheckpoint(*tipTxid, iterNum)
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, false, found)
+	found, checkpoint, checkpointErr = client.findCheckpoint(*tipTxid, 2)
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txs[iterNum-2], checkpoint.String())
+	assert.Equal(t, nil, client.VerifyCheckpoint(checkpoint))
+
+	// Verify checkpoint is used as subchain root
+	client.SetCheckpoint(checkpoint)
+	found, _, checkpointErr = client.findCheckpoint(*tipTxid, 2)
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, false, found)
+	assert.Equal(t, true, client.verifyTxOnSubchain(*tipTxid))
+	verifyNewUnspent(t, client, *tipTxid)
+	client.SetCheckpoint(chainhash.Hash{})
+	assert.Equal(t, "", client.checkpoint)
+
+	// Verify checkpoint not on subchain
+	checkpointErr = client.VerifyCheckpoint(topupHash)
+	assert.NotEqual(t, nil, checkpointErr)
+	assert.Equal(t, true, strings.HasPrefix(checkpointErr.Error(), ErrorCheckpointNotOnSubchain))
+
+	// Verify init transaction not in wallet
+	client.txid0 = "1111111111111111111111111111111111111111111111111111111111111111"
+	initTxErr := client.verifyInitTx()
+	assert.NotEqual(t, nil, initTxErr)
+	assert.Equal(t, true, strings.HasPrefix(initTxErr.Error(), ErrorInitTxNotFound))
 }
 
 // Attest Client Test for AttestClient struct and methods
@@ -280,6 +339,7 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 	assert.Equal(t, txs[0], unspent.TxID)
 
 	lastHash := chainhash.Hash{}
+	prevHash := chainhash.Hash{}
 	topupHash := chainhash.Hash{}
 
 	// test that when attempting to generate a new address with
@@ -326,7 +386,7 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 		assert.Equal(t, script, scriptTest)
 
 		// test importing address
-		importErr := client.ImportAttestationAddr(addr, false)
+		importErr := client.ImportAttestationAddr(addr, oceanCommitmentHash, false)
 		assert.Equal(t, nil, importErr)
 
 		var unspentList []btcjson.ListUnspentResult
@@ -340,7 +400,7 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 		}
 
 		// test creating attestation transaction
-		tx, attestationErr := client.createAttestation(addr, unspentList)
+		tx, attestationErr := client.createAttestation(addr, chainhash.Hash{}, unspentList)
 		assert.Equal(t, nil, attestationErr)
 		assert.Equal(t, 1-1*int(math.Min(0, float64((i%(topupLevel+1)-1)))), len(tx.TxIn))
 		assert.Equal(t, 1, len(tx.TxOut))
@@ -357,7 +417,7 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 		signedTx, signErr := client.signAttestation(tx, [][]crypto.Sig{}, lastHash)
 		assert.Equal(t, errors.New(ErrorSigsMissingForTx), signErr)
 
-		txid, sendErr := client.sendAttestation(signedTx)
+		txid, sendErr := client.sendAttestation(context.Background(), signedTx)
 		assert.Equal(t, true, sendErr != nil)
 
 		// client can't sign - we need to sign using clientSigner
@@ -369,6 +429,38 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 		assert.Equal(t, signedScriptSigner, hex.EncodeToString(sigScript))
 		assert.Equal(t, 1, len(sigs))
 
+		// test invalid signature encoding rejected
+		invalidSig := append(crypto.Sig{}, sigs[0][:len(sigs[0])-1]...)
+		_, signErr = client.signAttestation(tx, [][]crypto.Sig{[]crypto.Sig{sigs[0], invalidSig}}, lastHash)
+		assert.NotEqual(t, nil, signErr)
+		assert.Equal(t, true, strings.HasPrefix(signErr.Error(), ErrorInvalidSig+" for input 0 from signer 1"))
+
+		// test signature not from an expected signer dropped
+		rogueKey, _ := btcec.NewPrivateKey(btcec.S256())
+		rogueSig, rogueSigErr := txscript.RawTxInSignature(tx, 0, sigScript, txscript.SigHashAll, rogueKey)
+		assert.Equal(t, nil, rogueSigErr)
+		assert.Equal(t, [][]crypto.Sig{[]crypto.Sig{sigs[0]}},
+			client.filterSigs(tx, [][]crypto.Sig{[]crypto.Sig{rogueSig, sigs[0]}}, hex.EncodeToString(sigScript), ""))
+		_, signErr = client.signAttestation(tx, [][]crypto.Sig{[]crypto.Sig{rogueSig}}, lastHash)
+		assert.NotEqual(t, nil, signErr)
+
+		// test signature tweaked with previous commitment dropped
+		if i > 1 {
+			staleScript, staleScriptErr := client.GetScriptFromHash(prevHash)
+			assert.Equal(t, nil, staleScriptErr)
+			assert.Equal(t, staleScript, client.updateSignHash(lastHash))
+
+			staleKey := clientSigner.GetKeyFromHash(prevHash)
+			staleSig, staleSigErr := txscript.RawTxInSignature(tx, 0, sigScript, txscript.SigHashAll, staleKey.PrivKey)
+			assert.Equal(t, nil, staleSigErr)
+			assert.Equal(t, [][]crypto.Sig{[]crypto.Sig{staleSig}},
+				client.filterSigs(tx, [][]crypto.Sig{[]crypto.Sig{staleSig}}, staleScript, ""))
+			assert.Equal(t, [][]crypto.Sig{[]crypto.Sig{sigs[0]}},
+				client.filterSigs(tx, [][]crypto.Sig{[]crypto.Sig{staleSig, sigs[0]}}, hex.EncodeToString(sigScript), staleScript))
+			_, signErr = client.signAttestation(tx, [][]crypto.Sig{[]crypto.Sig{staleSig}}, lastHash)
+			assert.NotEqual(t, nil, signErr)
+		}
+
 		// test signing and sending attestation again
 		signedTx, signErr = client.signAttestation(tx, [][]crypto.Sig{[]crypto.Sig{sigs[0]}}, lastHash)
 		// exceptional top-up case - need to include additional unspent + signatures
@@ -409,10 +501,11 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 		}
 		assert.Equal(t, true, len(signedTx.TxIn[0].SignatureScript) > 0)
 
-		txid, sendErr = client.sendAttestation(signedTx)
+		txid, sendErr = client.sendAttestation(context.Background(), signedTx)
 		assert.Equal(t, nil, sendErr)
 
 		sideClientFake.Generate(1)
+		prevHash = lastHash
 		lastHash = oceanCommitmentHash
 
 		// Verify getUnconfirmedTx gives the unconfirmed transaction just submitted
@@ -423,7 +516,7 @@ func TestAttestClient_SignerAndNoSigner(t *testing.T) {
 			topupHash = createTopupUnspent(t, test.Config)
 		}
 
-		client.MainClient.Generate(1)
+		test.Config.MainClient().Generate(1)
 
 		// Verify no more unconfirmed transactions after new block generation
 		verifyNoUnconfirmed(t, client)
@@ -469,7 +562,7 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 		addr, script := verifyKeysAndAddr(t, client, oceanCommitmentHash)
 
 		// test creating attestation transaction
-		tx, attestationErr := client.createAttestation(addr, []btcjson.ListUnspentResult{unspent})
+		tx, attestationErr := client.createAttestation(addr, oceanCommitmentHash, []btcjson.ListUnspentResult{unspent})
 		assert.Equal(t, nil, attestationErr)
 		assert.Equal(t, 1, len(tx.TxIn))
 		assert.Equal(t, 1, len(tx.TxOut))
@@ -482,13 +575,13 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 		// test signing and sending attestation
 		signedTx, signErr := client.signAttestation(tx, [][]crypto.Sig{}, lastHash)
 		assert.Equal(t, nil, signErr)
-		txid, sendErr := client.sendAttestation(signedTx)
+		txid, sendErr := client.sendAttestation(context.Background(), signedTx)
 		assert.Equal(t, nil, sendErr)
 
 		// test fees too high
 		prevMaxFee := client.Fees.maxFee
 		client.Fees.maxFee = 999999999999
-		tx2, attestationErr2 := client.createAttestation(addr, []btcjson.ListUnspentResult{unspent})
+		tx2, attestationErr2 := client.createAttestation(addr, oceanCommitmentHash, []btcjson.ListUnspentResult{unspent})
 		assert.Equal(t, errors.New(ErrorInsufficientFunds), attestationErr2)
 		client.Fees.maxFee = prevMaxFee
 
@@ -504,7 +597,7 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 			unspentAmount += topupUnspent.Amount
 		}
 
-		tx2, attestationErr2 = client.createAttestation(addr, unspentList)
+		tx2, attestationErr2 = client.createAttestation(addr, oceanCommitmentHash, unspentList)
 		assert.Equal(t, nil, attestationErr2)
 
 		// verify transaction pre-image generation
@@ -527,15 +620,15 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 
 		newFee := client.Fees.GetFee()
 		newValue := tx2.TxOut[0].Value
-		newTxFee := calcSignedTxFee(newFee, tx2.SerializeSize(), len(client.script0)/2, client.numOfSigs)
-		currentTxFee := calcSignedTxFee(currentFee, tx.SerializeSize(), len(client.script0)/2, client.numOfSigs)
+		newTxFee := client.calcSignedAttestationFee(newFee, tx2)
+		currentTxFee := client.calcSignedAttestationFee(currentFee, tx)
 		assert.Equal(t, newTxFee-currentTxFee, currentValue+topupValue-newValue)
 		assert.Equal(t, client.Fees.minFee+client.Fees.feeIncrement, newFee)
 
 		// test signing and sending attestation again
 		signedTx, signErr = client.signAttestation(tx2, [][]crypto.Sig{}, lastHash)
 		assert.Equal(t, nil, signErr)
-		txid, sendErr = client.sendAttestation(signedTx)
+		txid, sendErr = client.sendAttestation(context.Background(), signedTx)
 		assert.Equal(t, nil, sendErr)
 
 		sideClientFake.Generate(1)
@@ -549,7 +642,7 @@ func TestAttestClient_FeeBumping(t *testing.T) {
 			topupHash = createTopupUnspent(t, test.Config)
 		}
 
-		client.MainClient.Generate(1)
+		test.Config.MainClient().Generate(1)
 
 		// Verify no more unconfirmed transactions after new block generation
 		verifyNoUnconfirmed(t, client)
@@ -578,6 +671,776 @@ func TestAttestClient_feeCalculation(t *testing.T) {
 	script2 := "52210325bf82856a8fdcc7a2c08a933343d2c6332c4c252974d6b09b6232ea4080462621028ed149d77203c79d7524048689a80cc98f27e3427f2edaec52eae1f630978e08210254a548b59741ba35bfb085744373a8e10b1cf96e71f53356d7d97f807258d38c53ae"
 	scriptSize2 := len(script2) / 2
 	_, numOfSigs2 := crypto.ParseRedeemScript(script2)
-	assert.Equal(t, 336, calcSignedTxSize(unsignedTxSize, scriptSize2, numOfSigs2))
-	assert.Equal(t, int64(3360), calcSignedTxFee(feePerByte, unsignedTxSize, scriptSize2, numOfSigs2))
+	assert.Equal(t, 339, calcSignedTxSize(unsignedTxSize, scriptSize2, numOfSigs2))
+	assert.Equal(t, int64(3390), calcSignedTxFee(feePerByte, unsignedTxSize, scriptSize2, numOfSigs2))
+
+	// no multisig case
+	assert.Equal(t, 190, calcSignedTxSize(unsignedTxSize, 0, 1))
+
+	// push data and scriptsig length sizes
+	assert.Equal(t, 1, calcPushDataSize(75))
+	assert.Equal(t, 2, calcPushDataSize(76))
+	assert.Equal(t, 2, calcPushDataSize(255))
+	assert.Equal(t, 3, calcPushDataSize(256))
+	scriptSize3 := 250
+	assert.Equal(t, 1+3*73+2+250, calcSignedScriptSigSize(scriptSize3, 3))
+	assert.Equal(t, unsignedTxSize+1+3*73+2+250+2, calcSignedTxSize(unsignedTxSize, scriptSize3, 3))
+}
+
+// Test Attest Client transaction building and subchain logic
+// using the fake rpc client without the need of a bitcoin node
+func TestAttestClient_RpcFake(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction paying to init address
+	addr, addrErr := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, addrErr)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	pubkeys, pubkeysExtended, chaincodes := getTestInitKeys()
+	client := &AttestClient{
+		MainClient:      rpcFake,
+		MainChainCfg:    &chaincfg.RegressionNetParams,
+		Fees:            &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:           txid0.String(),
+		script0:         testpkg.Script,
+		pubkeysExtended: pubkeysExtended,
+		pubkeys:         pubkeys,
+		chaincodes:      chaincodes,
+		numOfSigs:       1}
+
+	// verify init transaction and genesis unspent
+	assert.Equal(t, nil, client.verifyInitTx())
+	verifyNoUnconfirmed(t, client)
+	unspent := verifyFirstUnspent(t, client)
+	assert.Equal(t, txid0.String(), unspent.TxID)
+
+	// test importing address with label
+	assert.Equal(t, nil, client.ImportAttestationAddr(addr, chainhash.Hash{}))
+	assert.Equal(t, []string{addr.String()}, rpcFake.ImportedAddresses())
+	assert.Equal(t, []string{chainhash.Hash{}.String()}, rpcFake.ImportedLabels())
+	client.addrLabelPrefix = "prefix"
+	assert.Equal(t, "prefix-"+chainhash.Hash{}.String(), client.GetAttestationAddrLabel(chainhash.Hash{}))
+
+	// test importing address to descriptor wallet
+	assert.Equal(t, false, isDescriptorWallet(rpcFake))
+	assert.NotEqual(t, nil, importAddrDescriptor(rpcFake, addr.String(), "", false))
+	rpcFake.SetDescriptorWallet(true)
+	assert.Equal(t, true, isDescriptorWallet(rpcFake))
+	client.descriptorWallet = true
+	assert.Equal(t, nil, client.ImportAttestationAddr(addr, chainhash.Hash{}))
+	assert.Equal(t, []string{addr.String(), addr.String()}, rpcFake.ImportedAddresses())
+	assert.Equal(t, []string{chainhash.Hash{}.String(), "prefix-" + chainhash.Hash{}.String()}, rpcFake.ImportedLabels())
+	client.descriptorWallet = false
+	rpcFake.SetDescriptorWallet(false)
+
+	// test named wallet verified as loaded
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorWalletNotLoaded, "mainstay")), verifyWalletLoaded(rpcFake, "mainstay"))
+	rpcFake.SetWallets("", "mainstay")
+	assert.Equal(t, nil, verifyWalletLoaded(rpcFake, "mainstay"))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorWalletNotLoaded, "other")), verifyWalletLoaded(rpcFake, "other"))
+
+	// test imported address verified as watched by the wallet
+	assert.Equal(t, nil, verifyAddrWatched(rpcFake, addr.String()))
+	watchErr := verifyAddrWatched(rpcFake, testpkg.TopupAddress)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorAddrNotWatched, testpkg.TopupAddress)), watchErr)
+
+	// test creating attestation transaction
+	tx, createErr := client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, 1, len(tx.TxIn))
+	assert.Equal(t, 1, len(tx.TxOut))
+	assert.Equal(t, txid0, tx.TxIn[0].PreviousOutPoint.Hash)
+	assert.Equal(t, uint32(math.Pow(2, float64(32)))-3, tx.TxIn[0].Sequence)
+	assert.Equal(t, int64(1*Coin)-client.calcSignedAttestationFee(10, tx), tx.TxOut[0].Value)
+	assert.Equal(t, pkScript, tx.TxOut[0].PkScript)
+	assert.Equal(t, uint32(0), tx.LockTime)
+
+	// test transaction trace of attestation transaction
+	trace := client.traceTransaction(tx)
+	assert.Equal(t, tx.TxHash().String(), trace.Txid)
+	assert.Equal(t, tx.SerializeSize(), trace.Size)
+	assert.Equal(t, client.calcSignedAttestationSize(tx), trace.SignedSize)
+	assert.Equal(t, client.calcSignedAttestationFee(10, tx), trace.Fee)
+	assert.Equal(t, 1, len(trace.Inputs))
+	assert.Equal(t, wire.NewOutPoint(&txid0, 0).String(), trace.Inputs[0].PreviousOutPoint)
+	assert.Equal(t, int64(1*Coin), trace.Inputs[0].Value)
+	assert.Equal(t, tx.TxIn[0].Sequence, trace.Inputs[0].Sequence)
+	assert.Equal(t, "", trace.Inputs[0].ScriptSig)
+	assert.Equal(t, 1, len(trace.Outputs))
+	assert.Equal(t, tx.TxOut[0].Value, trace.Outputs[0].Value)
+	assert.Equal(t, hex.EncodeToString(pkScript), trace.Outputs[0].PkScript)
+	assert.Equal(t, txscript.ScriptHashTy.String(), trace.Outputs[0].Class)
+	assert.Equal(t, []string{addr.String()}, trace.Outputs[0].Addresses)
+	assert.Equal(t, true, strings.Contains(trace.String(), trace.Hex))
+
+	// test incomplete signing allowed in multisig case only
+	client.WalletPriv, _ = btcutil.DecodeWIF(testpkg.PrivMain)
+	_, signedScript, signErr := client.SignTransaction(chainhash.Hash{}, *tx)
+	assert.Equal(t, nil, signErr)
+	assert.Equal(t, testpkg.Script, signedScript)
+	assert.Equal(t, []btcjson.RawTxInput{btcjson.RawTxInput{txid0.String(), 0,
+		hex.EncodeToString(pkScript), testpkg.Script}}, rpcFake.SignedInputs())
+	client.script0 = ""
+	_, _, signErr = client.SignTransaction(chainhash.Hash{}, *tx)
+	assert.Equal(t, errors.New(ErrorSignIncomplete), signErr)
+	client.script0 = testpkg.Script
+
+	// test signing input spending prev attestation output at nonzero vout
+	nullDataScript, _ := txscript.NullDataScript((&chainhash.Hash{}).CloneBytes())
+	prevTx := wire.NewMsgTx(wire.TxVersion)
+	prevTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 2), nil, nil))
+	prevTx.AddTxOut(wire.NewTxOut(0, nullDataScript))
+	prevTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	prevTxid := rpcFake.AddTransaction(prevTx)
+	voutTx := tx.Copy()
+	voutTx.TxIn[0].PreviousOutPoint = *wire.NewOutPoint(&prevTxid, 1)
+	_, _, signErr = client.SignTransaction(chainhash.Hash{}, *voutTx)
+	assert.Equal(t, nil, signErr)
+	assert.Equal(t, []btcjson.RawTxInput{btcjson.RawTxInput{prevTxid.String(), 1,
+		hex.EncodeToString(pkScript), testpkg.Script}}, rpcFake.SignedInputs())
+	voutTx.TxIn[0].PreviousOutPoint = *wire.NewOutPoint(&prevTxid, 2)
+	_, _, signErr = client.SignTransaction(chainhash.Hash{}, *voutTx)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorPrevOutNotFound, voutTx.TxIn[0].PreviousOutPoint.String())), signErr)
+	client.WalletPriv = nil
+
+	// test attestation locktime set to fixed value or current block height
+	client.locktimeHeight, client.locktime, _ = parseLocktime("500")
+	lockTx, createErr := client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, uint32(500), lockTx.LockTime)
+	assert.Equal(t, uint32(math.Pow(2, float64(32)))-3, lockTx.TxIn[0].Sequence)
+	client.locktimeHeight, client.locktime, _ = parseLocktime(LocktimeHeight)
+	lockTx, createErr = client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, uint32(FakeBlockHeight), lockTx.LockTime)
+	_, _, locktimeErr := parseLocktime("invalid")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidLocktime, "invalid")), locktimeErr)
+	client.locktimeHeight, client.locktime = false, 0
+
+	// test redundant op_return output encoding the commitment
+	commitment, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	client.redundantOutputs = []string{RedundantOutputOpReturn}
+	redundantTx, createErr := client.createAttestation(addr, *commitment, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, 2, len(redundantTx.TxOut))
+	assert.Equal(t, pkScript, redundantTx.TxOut[0].PkScript)
+	opReturnScript, _ := txscript.NullDataScript(commitment.CloneBytes())
+	assert.Equal(t, opReturnScript, redundantTx.TxOut[1].PkScript)
+	assert.Equal(t, int64(0), redundantTx.TxOut[1].Value)
+	assert.Equal(t, int64(1*Coin)-client.calcSignedAttestationFee(10, redundantTx), redundantTx.TxOut[0].Value)
+	assert.Equal(t, true, redundantTx.TxOut[0].Value < tx.TxOut[0].Value)
+	client.redundantOutputs = []string{"invalid"}
+	_, createErr = client.createAttestation(addr, *commitment, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidRedundantOutput, "invalid")), createErr)
+	assert.Equal(t, createErr, verifyRedundantOutputs(client.redundantOutputs))
+	assert.Equal(t, nil, verifyRedundantOutputs([]string{RedundantOutputOpReturn}))
+	client.redundantOutputs = nil
+
+	// test two-output attestation paying excess over the attestation amount to the reserve
+	reserveAddr, reserveErr := parseReserveAddress(confpkg.AttestationConfig{
+		AttestationAmount: Coin / 2, ReserveAddress: testpkg.TopupAddress}, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, reserveErr)
+	reserveScript, _ := txscript.PayToAddrScript(reserveAddr)
+	client.attestationAmount, client.reserveAddr = Coin/2, reserveAddr
+	reserveTx, createErr := client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, 2, len(reserveTx.TxOut))
+	assert.Equal(t, pkScript, reserveTx.TxOut[0].PkScript)
+	assert.Equal(t, int64(Coin/2), reserveTx.TxOut[0].Value)
+	assert.Equal(t, reserveScript, reserveTx.TxOut[1].PkScript)
+	assert.Equal(t, int64(Coin/2)-client.calcSignedAttestationFee(10, reserveTx), reserveTx.TxOut[1].Value)
+	assert.Equal(t, 1, client.getReserveOutputIndex(reserveTx))
+	assert.Equal(t, -1, client.getReserveOutputIndex(tx))
+
+	// test fee bump paid from the reserve output
+	bumpedReserveTx := reserveTx.Copy()
+	assert.Equal(t, nil, client.bumpAttestationFees(bumpedReserveTx))
+	assert.Equal(t, int64(Coin/2), bumpedReserveTx.TxOut[0].Value)
+	assert.Equal(t, int64(Coin/2)-client.calcSignedAttestationFee(15, reserveTx), bumpedReserveTx.TxOut[1].Value)
+	client.Fees.ResetFee(true)
+
+	// test excess below dust limit kept in the staychain output
+	client.attestationAmount = int64(Coin) - client.calcSignedAttestationFee(10, tx) - ReserveOutputDustLimit
+	dustTx, createErr := client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	assert.Equal(t, 1, len(dustTx.TxOut))
+	assert.Equal(t, tx.TxOut[0].Value, dustTx.TxOut[0].Value)
+	client.attestationAmount, client.reserveAddr = 0, nil
+
+	// test invalid reserve config
+	_, reserveErr = parseReserveAddress(confpkg.AttestationConfig{AttestationAmount: Coin}, &chaincfg.RegressionNetParams)
+	assert.Equal(t, errors.New(ErrorInvalidReserveConfig), reserveErr)
+	_, reserveErr = parseReserveAddress(confpkg.AttestationConfig{ReserveAddress: testpkg.TopupAddress}, &chaincfg.RegressionNetParams)
+	assert.Equal(t, errors.New(ErrorInvalidReserveConfig), reserveErr)
+	_, reserveErr = parseReserveAddress(confpkg.AttestationConfig{AttestationAmount: Coin, ReserveAddress: "invalid"}, &chaincfg.RegressionNetParams)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidReserveAddress, "invalid")), reserveErr)
+	reserveAddr, reserveErr = parseReserveAddress(confpkg.AttestationConfig{AttestationAmount: -1}, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, reserveErr)
+	assert.Equal(t, nil, reserveAddr)
+
+	// test unspents reserved by in-flight attestation
+	assert.Equal(t, nil, client.reserveUnspents(chainhash.Hash{}, tx))
+	reserveErr = client.reserveUnspents(*commitment, redundantTx)
+	assert.NotEqual(t, nil, reserveErr)
+	assert.Equal(t, true, strings.HasPrefix(reserveErr.Error(), ErrorInFlightAttestation))
+
+	// test fee bumped replacement keeps reservation
+	bumpedTx := tx.Copy()
+	assert.Equal(t, nil, client.bumpAttestationFees(bumpedTx))
+	assert.Equal(t, nil, client.reserveUnspents(chainhash.Hash{}, bumpedTx))
+	assert.Equal(t, bumpedTx.TxHash(), client.inFlight.txid)
+	client.Fees.ResetFee(true)
+
+	// test replacement spending unreserved unspents rejected
+	otherInputTx := tx.Copy()
+	otherInputTx.TxIn[0].PreviousOutPoint.Index = 1
+	reserveErr = client.reserveUnspents(chainhash.Hash{}, otherInputTx)
+	assert.NotEqual(t, nil, reserveErr)
+	assert.Equal(t, true, strings.HasPrefix(reserveErr.Error(), ErrorInFlightReplacement))
+
+	// test new attestation allowed after release
+	client.releaseUnspents()
+	assert.Equal(t, nil, client.reserveUnspents(*commitment, redundantTx))
+	assert.Equal(t, redundantTx.TxHash(), client.inFlight.txid)
+	client.releaseUnspents()
+	assert.Equal(t, 0, len(client.inFlight.outpoints))
+
+	// test permanent send rejections returned without retrying
+	sendRetryBackoff = time.Millisecond
+	client.sendRetries = 2
+	feeErr := &btcjson.RPCError{Code: RpcErrorVerifyRejected, Message: "min relay fee not met"}
+	rpcFake.SetSendErrors(feeErr)
+	_, sendErr := client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, SendRejectedError{feeErr}, sendErr)
+	assert.Equal(t, true, IsFeeRejectedError(sendErr))
+	inputsErr := &btcjson.RPCError{Code: RpcErrorVerify, Message: "bad-txns-inputs-missingorspent"}
+	rpcFake.SetSendErrors(inputsErr)
+	_, sendErr = client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, SendRejectedError{inputsErr}, sendErr)
+	assert.Equal(t, false, IsFeeRejectedError(sendErr))
+
+	// test transient send errors retried up to max retries
+	warmupErr := &btcjson.RPCError{Code: btcjson.ErrRPCInWarmup, Message: "Loading block index..."}
+	rpcFake.SetSendErrors(warmupErr, warmupErr, warmupErr)
+	_, sendErr = client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, warmupErr, sendErr)
+	verifyNoUnconfirmed(t, client)
+
+	// test transient send errors not retried once the context is cancelled
+	// and retry backoff capped at the maximum backoff
+	sendRetryBackoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rpcFake.SetSendErrors(warmupErr, warmupErr, warmupErr)
+	_, sendErr = client.sendAttestation(ctx, tx)
+	assert.Equal(t, context.Canceled, sendErr)
+	verifyNoUnconfirmed(t, client)
+	sendRetryBackoffMax = time.Millisecond
+	rpcFake.SetSendErrors(warmupErr, warmupErr, warmupErr)
+	_, sendErr = client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, warmupErr, sendErr)
+	sendRetryBackoff = time.Millisecond
+
+	// test sending attestation after transient errors and finding it unconfirmed
+	rpcFake.SetSendErrors(warmupErr, errors.New("connection refused"))
+	txid, sendErr := client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, nil, sendErr)
+	found, unconfirmedTxid, unconfirmedErr := client.getUnconfirmedTx()
+	assert.Equal(t, nil, unconfirmedErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txid, unconfirmedTxid)
+	found, _, unspentErr := client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// test attestation confirmed and new unspent on subchain
+	rpcFake.Generate(1)
+	verifyNoUnconfirmed(t, client)
+	verifyNewUnspent(t, client, txid)
+	assert.Equal(t, true, client.verifyTxOnSubchain(txid))
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// test transaction not on subchain
+	otherTx := wire.NewMsgTx(wire.TxVersion)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 1), nil, nil))
+	otherTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	otherTxid := rpcFake.AddTransaction(otherTx)
+	assert.Equal(t, false, client.verifyTxOnSubchain(otherTxid))
+	verifyNewUnspent(t, client, txid)
+
+	// test insufficient funds
+	_, createErr = client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{
+		btcjson.ListUnspentResult{TxID: txid.String(), Vout: 0, Amount: 0.00001}})
+	assert.Equal(t, errors.New(ErrorInsufficientFunds), createErr)
+}
+
+// Test attest client selecting the subchain tip out of multiple subchain unspents
+func TestAttestClient_MultipleUnspent(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction with two outputs paying to init address
+	addr, addrErr := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, addrErr)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	client := &AttestClient{
+		MainClient:   rpcFake,
+		MainChainCfg: &chaincfg.RegressionNetParams,
+		Fees:         &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:        txid0.String(),
+		script0:      testpkg.Script,
+		numOfSigs:    1}
+
+	// second subchain unspent confirmed
+	otherTx := wire.NewMsgTx(wire.TxVersion)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 1), nil, nil))
+	otherTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	otherTxid := rpcFake.AddTransaction(otherTx)
+	unspent, _ := rpcFake.ListUnspent()
+	assert.Equal(t, 2, len(unspent))
+
+	// test unspent spent by a mempool transaction excluded
+	mempoolTx := wire.NewMsgTx(wire.TxVersion)
+	mempoolTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&otherTxid, 0), nil, nil))
+	mempoolTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	mempoolTxid := mempoolTx.TxHash()
+	rpcFake.txs[mempoolTxid] = mempoolTx
+	rpcFake.mempool = append(rpcFake.mempool, &mempoolTxid)
+	found, tip, unspentErr := client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txid0.String(), tip.TxID)
+
+	// test most recent unspent selected
+	rpcFake.mempool = nil
+	rpcFake.unspent[0].Confirmations = 2
+	found, tip, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, otherTxid.String(), tip.TxID)
+
+	// test ties broken by txid order
+	rpcFake.unspent[0].Confirmations = 1
+	minTxid := txid0.String()
+	if otherTxid.String() < minTxid {
+		minTxid = otherTxid.String()
+	}
+	found, tip, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, minTxid, tip.TxID)
+
+	// test unspents below min confirmations excluded
+	rpcFake.unspent[0].Confirmations = 2
+	client.minUnspentConf = 2
+	found, tip, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txid0.String(), tip.TxID)
+	client.minUnspentConf = 3
+	found, _, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+	client.minUnspentConf = 0
+	rpcFake.unspent[0].Confirmations = 1
+
+	// test no unspent found when all spent in mempool
+	mempoolTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 0), nil, nil))
+	mempoolTxid = mempoolTx.TxHash()
+	rpcFake.txs[mempoolTxid] = mempoolTx
+	rpcFake.mempool = []*chainhash.Hash{&mempoolTxid}
+	found, _, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+}
+
+// Test attest client sizing and filtering sigs of a merged subchain unspent
+// with the attestation script, and of a topup unspent with a larger topup script
+func TestAttestClient_MergeWithTopup(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction with two outputs paying to init address
+	addr, _ := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	topupAddr, _ := btcutil.DecodeAddress(testpkg.AddressMulti, &chaincfg.RegressionNetParams)
+	topupPkScript, _ := txscript.PayToAddrScript(topupAddr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	// second subchain unspent and topup unspent not on the subchain
+	otherTx := wire.NewMsgTx(wire.TxVersion)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 1), nil, nil))
+	otherTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	otherTxid := rpcFake.AddTransaction(otherTx)
+	topupTx := wire.NewMsgTx(wire.TxVersion)
+	topupTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 1), nil, nil))
+	topupTx.AddTxOut(wire.NewTxOut(1*Coin, topupPkScript))
+	topupTxid := rpcFake.AddTransaction(topupTx)
+
+	walletPriv, _ := btcutil.DecodeWIF(testpkg.PrivMain)
+	walletPrivTopup, _ := btcutil.DecodeWIF(strings.Split(testpkg.PrivsMulti, ",")[0])
+	client := &AttestClient{
+		MainClient:   rpcFake,
+		MainChainCfg: &chaincfg.RegressionNetParams,
+		Fees:         &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:        txid0.String(),
+		script0:      testpkg.Script,
+		scriptTopup:  testpkg.ScriptMulti,
+		numOfSigs:    1}
+
+	// attestation merging both subchain unspents with the topup unspent last
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&otherTxid, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&topupTxid, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(3*Coin, pkScript))
+	assert.Equal(t, true, client.isSubchainInput(tx, 1))
+	assert.Equal(t, false, client.isSubchainInput(tx, 2))
+
+	// test merged subchain input sized with the attestation script
+	expectedSize := tx.SerializeSize()
+	expectedSize = calcSignedTxSize(expectedSize, len(testpkg.Script)/2, 1)
+	expectedSize = calcSignedTxSize(expectedSize, len(testpkg.Script)/2, 1)
+	expectedSize = calcSignedTxSize(expectedSize, len(testpkg.ScriptMulti)/2, 1)
+	assert.Equal(t, expectedSize, client.calcSignedAttestationSize(tx))
+
+	// test merged subchain input sigs verified against the attestation script
+	script, _ := hex.DecodeString(testpkg.Script)
+	topupScript, _ := hex.DecodeString(testpkg.ScriptMulti)
+	var sigs [][]crypto.Sig
+	for i, key := range []*btcutil.WIF{walletPriv, walletPriv, walletPrivTopup} {
+		inputScript := script
+		if i == 2 {
+			inputScript = topupScript
+		}
+		sig, sigErr := txscript.RawTxInSignature(tx, i, inputScript, txscript.SigHashAll, key.PrivKey)
+		assert.Equal(t, nil, sigErr)
+		sigs = append(sigs, []crypto.Sig{sig})
+	}
+	assert.Equal(t, sigs, client.filterSigs(tx, sigs, testpkg.Script, ""))
+
+	// test sigs of the wrong script for each input dropped
+	topupSig, _ := txscript.RawTxInSignature(tx, 1, topupScript, txscript.SigHashAll, walletPrivTopup.PrivKey)
+	attestSig, _ := txscript.RawTxInSignature(tx, 2, script, txscript.SigHashAll, walletPriv.PrivKey)
+	assert.Equal(t, [][]crypto.Sig{sigs[0], []crypto.Sig{}, []crypto.Sig{}},
+		client.filterSigs(tx, [][]crypto.Sig{sigs[0], []crypto.Sig{topupSig}, []crypto.Sig{attestSig}}, testpkg.Script, ""))
+
+	// test signed attestation with the script of each input
+	signedTx, signErr := client.signAttestation(tx, sigs, chainhash.Hash{})
+	assert.Equal(t, nil, signErr)
+	for i, inputScript := range [][]byte{script, script, topupScript} {
+		inputSigs, sigScript := crypto.ParseScriptSig(signedTx.TxIn[i].SignatureScript)
+		assert.Equal(t, sigs[i], inputSigs)
+		assert.Equal(t, inputScript, sigScript)
+	}
+}
+
+// Test attest client reconciling unspents and mempool into a single staychain tip
+func TestAttestClient_DetermineCurrentTip(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction with two outputs paying to init address
+	addr, addrErr := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, addrErr)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	client := &AttestClient{
+		MainClient:   rpcFake,
+		MainChainCfg: &chaincfg.RegressionNetParams,
+		Fees:         &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:        txid0.String(),
+		script0:      testpkg.Script,
+		numOfSigs:    1}
+
+	// test confirmed unspent with no unconfirmed transaction
+	tip, tipErr := client.determineCurrentTip()
+	assert.Equal(t, nil, tipErr)
+	assert.Equal(t, true, tip.Found)
+	assert.Equal(t, false, tip.Unconfirmed)
+	assert.Equal(t, txid0.String(), tip.Unspent.TxID)
+
+	// test unconfirmed child chain spending confirmed unspent preferred
+	mempoolTx1 := wire.NewMsgTx(wire.TxVersion)
+	mempoolTx1.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 0), nil, nil))
+	mempoolTx1.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	mempoolTxid1 := mempoolTx1.TxHash()
+	mempoolTx2 := wire.NewMsgTx(wire.TxVersion)
+	mempoolTx2.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&mempoolTxid1, 0), nil, nil))
+	mempoolTx2.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	mempoolTxid2 := mempoolTx2.TxHash()
+	rpcFake.txs[mempoolTxid1] = mempoolTx1
+	rpcFake.txs[mempoolTxid2] = mempoolTx2
+	rpcFake.mempool = []*chainhash.Hash{&mempoolTxid2, &mempoolTxid1}
+	tip, tipErr = client.determineCurrentTip()
+	assert.Equal(t, nil, tipErr)
+	assert.Equal(t, true, tip.Found)
+	assert.Equal(t, true, tip.Unconfirmed)
+	assert.Equal(t, mempoolTxid2, tip.Txid)
+
+	// test unconfirmed spending output older than confirmed unspent ignored
+	delete(rpcFake.txs, mempoolTxid2)
+	rpcFake.mempool = []*chainhash.Hash{&mempoolTxid1}
+	rpcFake.confirmations[txid0] = 2
+	rpcFake.unspent[0].Confirmations = 2
+	otherTx := wire.NewMsgTx(wire.TxVersion)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&txid0, 1), nil, nil))
+	otherTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	otherTxid := rpcFake.AddTransaction(otherTx)
+	tip, tipErr = client.determineCurrentTip()
+	assert.Equal(t, nil, tipErr)
+	assert.Equal(t, true, tip.Found)
+	assert.Equal(t, false, tip.Unconfirmed)
+	assert.Equal(t, otherTxid.String(), tip.Unspent.TxID)
+
+	// test unconfirmed transaction with no confirmed unspent
+	rpcFake.unspent = nil
+	tip, tipErr = client.determineCurrentTip()
+	assert.Equal(t, nil, tipErr)
+	assert.Equal(t, true, tip.Found)
+	assert.Equal(t, true, tip.Unconfirmed)
+	assert.Equal(t, mempoolTxid1, tip.Txid)
+
+	// test no tip found
+	rpcFake.mempool = nil
+	tip, tipErr = client.determineCurrentTip()
+	assert.Equal(t, nil, tipErr)
+	assert.Equal(t, false, tip.Found)
+}
+
+// Test attest client finding unspents by scanning the utxo set
+func TestAttestClient_ScanUtxoSet(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction paying to init address
+	addr, addrErr := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	assert.Equal(t, nil, addrErr)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	pubkeys, pubkeysExtended, chaincodes := getTestInitKeys()
+	client := &AttestClient{
+		MainClient:      rpcFake,
+		MainChainCfg:    &chaincfg.RegressionNetParams,
+		Fees:            &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:           txid0.String(),
+		script0:         testpkg.Script,
+		pubkeysExtended: pubkeysExtended,
+		pubkeys:         pubkeys,
+		chaincodes:      chaincodes,
+		numOfSigs:       1,
+		scanUtxoSet:     true}
+	rpcFake.SetWalletDisabled(true)
+
+	// test no unspent found when no addresses watched
+	found, _, unspentErr := client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+
+	// test address watched instead of imported to the wallet
+	assert.Equal(t, nil, client.ImportAttestationAddr(addr, chainhash.Hash{}))
+	assert.Equal(t, 0, len(rpcFake.ImportedAddresses()))
+	assert.Equal(t, []string{addr.String()}, client.scanAddrs)
+
+	// test genesis unspent found by scanning
+	found, unspent, unspentErr := client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txid0.String(), unspent.TxID)
+	assert.Equal(t, uint32(0), unspent.Vout)
+	assert.Equal(t, float64(1), unspent.Amount)
+	assert.Equal(t, int64(1), unspent.Confirmations)
+
+	// test unconfirmed attestation not in the utxo set
+	tx, createErr := client.createAttestation(addr, chainhash.Hash{}, []btcjson.ListUnspentResult{unspent})
+	assert.Equal(t, nil, createErr)
+	txid, sendErr := client.sendAttestation(context.Background(), tx)
+	assert.Equal(t, nil, sendErr)
+	found, _, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+
+	// test confirmed attestation found by scanning
+	rpcFake.Generate(1)
+	found, unspent, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	assert.Equal(t, txid.String(), unspent.TxID)
+	assert.Equal(t, int64(1), unspent.Confirmations)
+
+	// test transaction info retrieved without the wallet
+	_, walletErr := rpcFake.GetTransaction(&txid)
+	assert.Equal(t, errors.New(ErrorFakeWalletDisabled), walletErr)
+	txInfo, txInfoErr := client.getTransaction(&txid)
+	assert.Equal(t, nil, txInfoErr)
+	assert.Equal(t, txid.String(), txInfo.TxID)
+	assert.Equal(t, int64(1), txInfo.Confirmations)
+	assert.Equal(t, FakeBlockHash, txInfo.BlockHash)
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// test only latest addresses watched
+	client.watchScanAddr("addr1")
+	client.watchScanAddr("addr2")
+	client.watchScanAddr("addr2")
+	assert.Equal(t, []string{addr.String(), "addr1", "addr2"}, client.scanAddrs)
+	client.watchScanAddr("addr3")
+	assert.Equal(t, []string{"addr1", "addr2", "addr3"}, client.scanAddrs)
+	found, _, unspentErr = client.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, false, found)
+
+	// test unsupported raw request
+	_, rawErr := rpcFake.RawRequest("getmempoolinfo", nil)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeMethodNotFound, "getmempoolinfo")), rawErr)
+}
+
+// Return pubkeys, extended pubkeys and chaincodes of the test init script
+// for multisig attest clients built without a config
+func getTestInitKeys() ([]*btcec.PublicKey, []*hdkeychain.ExtendedKey, [][]byte) {
+	pubkeys, _ := crypto.ParseRedeemScript(testpkg.Script)
+	var chaincodes [][]byte
+	var pubkeysExtended []*hdkeychain.ExtendedKey
+	for i_c, chaincodeStr := range strings.Split(testpkg.InitChaincodes, ",") {
+		chaincode, _ := hex.DecodeString(chaincodeStr)
+		chaincodes = append(chaincodes, chaincode)
+		pubkeysExtended = append(pubkeysExtended, hdkeychain.NewExtendedKey(
+			[]byte{}, pubkeys[i_c].SerializeCompressed(), chaincode, []byte{}, 0, 0, false))
+	}
+	return pubkeys, pubkeysExtended, chaincodes
+}
+
+// Test attest client verifying the init transaction output paying to the
+// genesis address, which is not necessarily the first output
+func TestAttestClient_VerifyInitTx(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// create genesis transaction with change output before the init address output
+	addr, _ := btcutil.DecodeAddress(testpkg.Address, &chaincfg.RegressionNetParams)
+	pkScript, _ := txscript.PayToAddrScript(addr)
+	otherAddr, _ := btcutil.DecodeAddress(testpkg.TopupAddress, &chaincfg.RegressionNetParams)
+	otherPkScript, _ := txscript.PayToAddrScript(otherAddr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	genesisTx := wire.NewMsgTx(wire.TxVersion)
+	genesisTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	genesisTx.AddTxOut(wire.NewTxOut(2*Coin, otherPkScript))
+	genesisTx.AddTxOut(wire.NewTxOut(1*Coin, pkScript))
+	txid0 := rpcFake.AddTransaction(genesisTx)
+
+	pubkeys, pubkeysExtended, chaincodes := getTestInitKeys()
+	client := &AttestClient{
+		MainClient:      rpcFake,
+		MainChainCfg:    &chaincfg.RegressionNetParams,
+		Fees:            &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		txid0:           txid0.String(),
+		script0:         testpkg.Script,
+		pubkeysExtended: pubkeysExtended,
+		pubkeys:         pubkeys,
+		chaincodes:      chaincodes,
+		numOfSigs:       1}
+
+	// test init output found at vout 1 and unspent
+	tx, _ := client.getTransaction(&txid0)
+	vout, voutErr := client.getGenesisVout(tx)
+	assert.Equal(t, nil, voutErr)
+	assert.Equal(t, uint32(1), vout)
+	assert.Equal(t, nil, client.verifyInitTx())
+
+	// test init transaction not paying to the genesis address
+	otherTx := wire.NewMsgTx(wire.TxVersion)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 1), nil, nil))
+	otherTx.AddTxOut(wire.NewTxOut(1*Coin, otherPkScript))
+	otherTxid := rpcFake.AddTransaction(otherTx)
+	client.txid0 = otherTxid.String()
+	initTxErr := client.verifyInitTx()
+	assert.NotEqual(t, nil, initTxErr)
+	assert.Equal(t, true, strings.HasPrefix(initTxErr.Error(), ErrorGenesisAddressMismatch))
+}
+
+// Test attest client creating sweep transaction for the latest attestation
+func TestAttestClient_CreateSweepTransaction(t *testing.T) {
+	rpcFake := NewAttestRpcClientFake()
+
+	// multisig client without private keys
+	_, numOfSigs := crypto.ParseRedeemScript(testpkg.Script)
+	pubkeys, pubkeysExtended, chaincodes := getTestInitKeys()
+	client := &AttestClient{
+		MainClient:      rpcFake,
+		MainChainCfg:    &chaincfg.RegressionNetParams,
+		Fees:            &AttestFees{minFee: 10, maxFee: 100, feeIncrement: 5, currentFee: 10},
+		script0:         testpkg.Script,
+		pubkeysExtended: pubkeysExtended,
+		pubkeys:         pubkeys,
+		chaincodes:      chaincodes,
+		numOfSigs:       numOfSigs}
+
+	// attestation transaction paying to tweaked address of commitment
+	hash, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestAddr, _, addrErr := client.GetNextAttestationAddr((*btcutil.WIF)(nil), *hash)
+	assert.Equal(t, nil, addrErr)
+	attestPkScript, _ := txscript.PayToAddrScript(attestAddr)
+	fundingTxid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestTx := wire.NewMsgTx(wire.TxVersion)
+	attestTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 0), nil, nil))
+	attestTx.AddTxOut(wire.NewTxOut(1*Coin, attestPkScript))
+	txid := rpcFake.AddTransaction(attestTx)
+
+	// test sweep to destination address
+	destAddr, _ := btcutil.DecodeAddress(testpkg.TopupAddress, &chaincfg.RegressionNetParams)
+	destPkScript, _ := txscript.PayToAddrScript(destAddr)
+	sweepTx, sweepErr := client.CreateSweepTransaction(txid, *hash, destAddr, 20)
+	assert.Equal(t, nil, sweepErr)
+	assert.Equal(t, 1, len(sweepTx.TxIn))
+	assert.Equal(t, 1, len(sweepTx.TxOut))
+	assert.Equal(t, txid, sweepTx.TxIn[0].PreviousOutPoint.Hash)
+	assert.Equal(t, uint32(0), sweepTx.TxIn[0].PreviousOutPoint.Index)
+	assert.Equal(t, destPkScript, sweepTx.TxOut[0].PkScript)
+	assert.Equal(t, int64(1*Coin)-client.calcSignedAttestationFee(20, sweepTx), sweepTx.TxOut[0].Value)
+
+	// test commitment not matching attestation address
+	_, sweepErr = client.CreateSweepTransaction(txid, chainhash.Hash{}, destAddr, 20)
+	genesisAddr, _, _ := client.GetNextAttestationAddr((*btcutil.WIF)(nil), chainhash.Hash{})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s) %s", ErrorSweepAddressMismatch, txid.String(), genesisAddr.String())), sweepErr)
+
+	// test verifying attestation against commitment
+	assert.Equal(t, nil, client.VerifyAttestation(txid, *hash))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s) %s", ErrorAttestationAddressMismatch, txid.String(), genesisAddr.String())),
+		client.VerifyAttestation(txid, chainhash.Hash{}))
+
+	// test insufficient funds
+	attestTx = wire.NewMsgTx(wire.TxVersion)
+	attestTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(fundingTxid, 1), nil, nil))
+	attestTx.AddTxOut(wire.NewTxOut(1000, attestPkScript))
+	txid = rpcFake.AddTransaction(attestTx)
+	_, sweepErr = client.CreateSweepTransaction(txid, *hash, destAddr, 20)
+	assert.Equal(t, errors.New(ErrorInsufficientFunds), sweepErr)
+
+	// test attestation transaction not found
+	_, sweepErr = client.CreateSweepTransaction(*fundingTxid, *hash, destAddr, 20)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeTxNotFound, fundingTxid.String())), sweepErr)
 }
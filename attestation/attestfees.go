@@ -6,8 +6,10 @@ package attestation
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"sync"
 
 	"mainstay/config"
 )
@@ -25,9 +27,12 @@ const (
 
 // warnings for arguments
 const (
-	WarningInvalidMinFeeArg       = "Warning - Invalid min fee config value"
-	WarningInvalidMaxFeeArg       = "Warning - Invalid max fee config value"
-	WarningInvalidFeeIncrementArg = "Warning - Invalid fee increment config value"
+	WarningInvalidMinFeeArg        = "Warning - Invalid min fee config value"
+	WarningInvalidMaxFeeArg        = "Warning - Invalid max fee config value"
+	WarningInvalidFeeIncrementArg  = "Warning - Invalid fee increment config value"
+	WarningInvalidBlockFeeFloorArg = "Warning - Invalid block fee floor config value"
+
+	ErrorFeeApisFailed = "All fee APIs failed to return a plausible fee"
 )
 
 // fee api config
@@ -39,10 +44,39 @@ const (
 	// default fee type to use from response
 	// options: fastestFee, halfHourFee, hourFee
 	DefaultBestFeeType = "hourFee"
+
+	// maximum plausible fee per byte value returned from a fee API
+	// any values higher than this or non positive values are ignored
+	MaxPlausibleApiFee = 10 * DefaultMaxFee
+)
+
+// block fee floor options
+// feerate of the latest block to use as fee floor
+const (
+	BlockFeeFloorMedian = "median"
+	BlockFeeFloorMin    = "min"
 )
 
+// blockStatsResult struct
+// Feerate fields of the getblockstats response in sat/vbyte
+type blockStatsResult struct {
+	MinFeeRate         int64   `json:"minfeerate"`
+	FeeRatePercentiles []int64 `json:"feerate_percentiles"`
+}
+
+// feeApi struct
+// Fee API url and fee type field to use from the response
+type feeApi struct {
+	url     string
+	feeType string
+}
+
 // AttestFees struct
+// Safe for concurrent use - current fee access is guarded by a mutex
 type AttestFees struct {
+	// mutex guarding current fee access
+	mutex sync.Mutex
+
 	// minimum fee allowed for attestation transactions
 	minFee int
 
@@ -54,12 +88,25 @@ type AttestFees struct {
 
 	// current fee used for attestation transactions
 	currentFee int
+
+	// ordered list of fee APIs to get the best fee from
+	feeApis []feeApi
+
+	// feerate of the latest block to use as fee floor - empty if disabled
+	blockFeeFloor string
+
+	// main client rpc connection used to get the latest block feerate
+	mainClient AttestRpcClient
+
+	// fixed fee used instead of the fee APIs - non positive if disabled
+	fixedFee int
 }
 
 // New AttestFees instance
 // Limit values taken from configuration
 // Current fee value reset from api
-func NewAttestFees(feesConfig config.FeesConfig) AttestFees {
+// Main client optional and only used for the block fee floor
+func NewAttestFees(feesConfig config.FeesConfig, mainClient ...AttestRpcClient) *AttestFees {
 
 	// min fee with upper limit max_fee default
 	minFee := DefaultMinFee
@@ -88,41 +135,110 @@ func NewAttestFees(feesConfig config.FeesConfig) AttestFees {
 	}
 	log.Printf("*Fees* Fee increment set to: %d\n", feeIncrement)
 
-	attestFees := AttestFees{
-		minFee:       minFee,
-		maxFee:       maxFee,
-		feeIncrement: feeIncrement}
+	// fee apis in order with default fee type if type missing
+	var feeApis []feeApi
+	for i, url := range feesConfig.FeeApiUrls {
+		feeType := DefaultBestFeeType
+		if i < len(feesConfig.FeeApiTypes) && feesConfig.FeeApiTypes[i] != "" {
+			feeType = feesConfig.FeeApiTypes[i]
+		}
+		feeApis = append(feeApis, feeApi{url, feeType})
+	}
+	if len(feeApis) == 0 {
+		feeApis = append(feeApis, feeApi{FeeApiUrl, DefaultBestFeeType})
+	}
+	for _, api := range feeApis {
+		log.Printf("*Fees* Fee api set to: %s (%s)\n", api.url, api.feeType)
+	}
+
+	// block fee floor enabled only with valid option and main client
+	var blockFeeFloor string
+	var client AttestRpcClient
+	if feesConfig.BlockFeeFloor == BlockFeeFloorMedian || feesConfig.BlockFeeFloor == BlockFeeFloorMin {
+		if len(mainClient) > 0 && mainClient[0] != nil {
+			blockFeeFloor = feesConfig.BlockFeeFloor
+			client = mainClient[0]
+			log.Printf("*Fees* Block fee floor set to: %s\n", blockFeeFloor)
+		}
+	} else if feesConfig.BlockFeeFloor != "" {
+		log.Printf("%s (%s)\n", WarningInvalidBlockFeeFloorArg, feesConfig.BlockFeeFloor)
+	}
+
+	// fixed fee disables fee api requests
+	fixedFee := -1
+	if feesConfig.FixedFee > 0 {
+		fixedFee = feesConfig.FixedFee
+		log.Printf("*Fees* Fixed fee set to: %d\n", fixedFee)
+	}
+
+	attestFees := &AttestFees{
+		minFee:        minFee,
+		maxFee:        maxFee,
+		feeIncrement:  feeIncrement,
+		feeApis:       feeApis,
+		blockFeeFloor: blockFeeFloor,
+		mainClient:    client,
+		fixedFee:      fixedFee}
 
 	attestFees.ResetFee()
 	return attestFees
 }
 
 // Get current fee
-func (a AttestFees) GetFee() int {
+func (a *AttestFees) GetFee() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 	log.Printf("*Fees* Current fee value: %d\n", a.currentFee)
 	return a.currentFee
 }
 
+// Get current fee without logging, e.g. for status reporting
+func (a *AttestFees) currentFeeValue() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.currentFee
+}
+
 // Reset current fee, getting latest best value from API
+// If block fee floor is set, the latest block feerate is used
+// as floor to the API value, with the max fee limit still applied
+// If fixed fee is set, it is used without any API or block requests
 // Minimum option value to set current fee to minFee
 func (a *AttestFees) ResetFee(useMinimum ...bool) {
 	var fee int
 	if len(useMinimum) > 0 && useMinimum[0] {
 		fee = a.minFee
 	} else {
-		fee = getBestFee()
-		if fee < a.minFee {
-			fee = a.minFee
-		} else if fee > a.maxFee {
-			fee = a.maxFee
+		fee = a.getBestFee()
+		if a.fixedFee > 0 {
+			log.Printf("*Fees* Using fixed fee: %d\n", fee)
+		} else if floor := a.getBlockFeeFloor(); floor > fee {
+			log.Printf("*Fees* Using block fee floor: %d\n", floor)
+			fee = floor
 		}
+		fee = a.limitFee(fee)
 	}
+	// fee api request done before locking to avoid blocking readers
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 	a.currentFee = fee
 	log.Printf("*Fees* Current fee set to value: %d\n", a.currentFee)
 }
 
+// Limit fee value within the min and max fee values
+func (a *AttestFees) limitFee(fee int) int {
+	if fee < a.minFee {
+		return a.minFee
+	} else if fee > a.maxFee {
+		return a.maxFee
+	}
+	return fee
+}
+
 // Bump fee upon request using increment value and not allowing values higher than max configured fee
 func (a *AttestFees) BumpFee() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 	a.currentFee += a.feeIncrement
 	log.Printf("*Fees* Bumping fee value to: %d\n", a.currentFee)
 	if a.currentFee > a.maxFee {
@@ -131,35 +247,95 @@ func (a *AttestFees) BumpFee() {
 	}
 }
 
-// getBestFee returns the best fee for the type requested from the API
-func getBestFee(customFeeType ...string) int {
-	var feeType = DefaultBestFeeType
-	if len(customFeeType) > 0 {
-		feeType = customFeeType[0]
+// Check that at least one of the fee APIs returns a plausible fee
+// Returns the best fee found or an error if all fee APIs fail
+func (a *AttestFees) CheckFeeApis() (int, error) {
+	fee := a.getBestFee()
+	if fee == -1 {
+		return -1, errors.New(ErrorFeeApisFailed)
+	}
+	return fee, nil
+}
+
+// getBestFee returns the best fee from the first fee API in order
+// that returns a plausible value, or -1 if all fee APIs fail
+// The fixed fee is returned instead if set, skipping the fee APIs
+func (a *AttestFees) getBestFee() int {
+	if a.fixedFee > 0 {
+		return a.fixedFee
+	}
+	for _, api := range a.feeApis {
+		fee := getFeeFromAPI(api.url, api.feeType)
+		if fee > 0 && fee <= MaxPlausibleApiFee {
+			return fee
+		}
+		log.Printf("*Fees* API %s returned invalid fee: %d\n", api.url, fee)
 	}
+	return -1
+}
 
-	fee := getFeeFromAPI(feeType)
-	return fee
+// getBlockFeeFloor returns the median or min feerate of the latest block
+// from the main client, or -1 if disabled or the request fails
+func (a *AttestFees) getBlockFeeFloor() int {
+	if a.blockFeeFloor == "" {
+		return -1
+	}
+	height, heightErr := a.mainClient.GetBlockCount()
+	if heightErr != nil {
+		log.Printf("*Fees* Block count request failed: %v\n", heightErr)
+		return -1
+	}
+	heightParam, _ := json.Marshal(height)
+	statsParam, _ := json.Marshal([]string{"minfeerate", "feerate_percentiles"})
+	resp, respErr := a.mainClient.RawRequest("getblockstats", []json.RawMessage{heightParam, statsParam})
+	if respErr != nil {
+		log.Printf("*Fees* Block stats request failed: %v\n", respErr)
+		return -1
+	}
+	var stats blockStatsResult
+	if unmarshalErr := json.Unmarshal(resp, &stats); unmarshalErr != nil {
+		log.Println("*Fees* Block stats response decoding failed")
+		return -1
+	}
+
+	// feerate percentiles: 10th, 25th, 50th, 75th, 90th
+	fee := stats.MinFeeRate
+	if a.blockFeeFloor == BlockFeeFloorMedian {
+		if len(stats.FeeRatePercentiles) != 5 {
+			log.Println("*Fees* Block stats response incorrect format")
+			return -1
+		}
+		fee = stats.FeeRatePercentiles[2]
+	}
+	if fee <= 0 || fee > MaxPlausibleApiFee {
+		log.Printf("*Fees* Block stats returned invalid fee: %d\n", fee)
+		return -1
+	}
+	return int(fee)
 }
 
 // GetFeeFromAPI attempts to get the best bitcoinfee from the fee API specified
-func getFeeFromAPI(feeType string) int {
-	resp, getErr := http.Get(FeeApiUrl)
+func getFeeFromAPI(url string, feeType string) int {
+	resp, getErr := http.Get(url)
 	if getErr != nil {
 		log.Println("*Fees* API request failed")
 		return -1
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("*Fees* API response status %s\n", resp.Status)
+		return -1
+	}
 	dec := json.NewDecoder(resp.Body)
-	var respJson map[string]float64
+	var respJson map[string]interface{}
 	decErr := dec.Decode(&respJson)
 	if decErr != nil {
 		log.Println("*Fees* API response decoding failed")
 		return -1
 	}
 
-	fee, ok := respJson[feeType]
+	fee, ok := respJson[feeType].(float64)
 	if !ok {
 		log.Println("*Fees* API response incorrect format")
 		return -1
@@ -167,3 +343,57 @@ func getFeeFromAPI(feeType string) int {
 
 	return int(fee)
 }
+
+// FeeSimulation struct
+// Result of replaying the fee strategy against a fee history
+type FeeSimulation struct {
+	// fee of the pending attestation at each step of the fee history
+	Fees []int
+
+	// whether the pending attestation was confirmed at each step
+	Confirmed []bool
+
+	// number of attestations confirmed
+	NumOfConfirmed int
+
+	// whether any attestation remained unconfirmed at max fee
+	StuckAtMaxFee bool
+}
+
+// Simulate the fee strategy against a series of historical fee API values
+// Each value is the best fee returned by the fee API at each step of the
+// history, with a step lasting the handle unconfirmed waiting time
+// At each step the pending attestation is confirmed if its fee is no less
+// than the API value, otherwise its fee is bumped, and a new attestation
+// is initiated with the fee reset to the API value of that step
+// The current fee of the AttestFees instance is not modified
+func (a *AttestFees) SimulateFees(history []int) FeeSimulation {
+	var simulation FeeSimulation
+	fee := 0
+	pending := false
+	for _, apiFee := range history {
+		confirmed := false
+		if pending {
+			if fee >= apiFee {
+				confirmed = true
+				pending = false
+				simulation.NumOfConfirmed += 1
+			} else {
+				if fee == a.maxFee {
+					simulation.StuckAtMaxFee = true
+				}
+				fee += a.feeIncrement
+				if fee > a.maxFee {
+					fee = a.maxFee
+				}
+			}
+		}
+		if !pending {
+			fee = a.limitFee(apiFee)
+			pending = true
+		}
+		simulation.Fees = append(simulation.Fees, fee)
+		simulation.Confirmed = append(simulation.Confirmed, confirmed)
+	}
+	return simulation
+}
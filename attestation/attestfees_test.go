@@ -5,6 +5,10 @@
 package attestation
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"mainstay/config"
@@ -15,7 +19,7 @@ import (
 // Attest Fees test
 func TestAttestFees(t *testing.T) {
 
-	attestFees := NewAttestFees(config.FeesConfig{-1, -1, -1})
+	attestFees := NewAttestFees(config.FeesConfig{-1, -1, -1, nil, nil, "", -1})
 
 	// test reset to minimum
 	attestFees.ResetFee(true)
@@ -45,7 +49,7 @@ func TestAttestFees(t *testing.T) {
 func TestAttestFeesWithConfig(t *testing.T) {
 
 	// test attest fees with new config
-	attestFees := NewAttestFees(config.FeesConfig{0, 10, 20})
+	attestFees := NewAttestFees(config.FeesConfig{0, 10, 20, nil, nil, "", -1})
 	assert.Equal(t, DefaultMinFee, attestFees.minFee)
 	assert.Equal(t, DefaultMaxFee, attestFees.maxFee)
 	assert.Equal(t, 20, attestFees.feeIncrement)
@@ -54,7 +58,7 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	assert.Equal(t, DefaultMinFee, attestFees.GetFee())
 
 	// test attest fees with new config
-	attestFees = NewAttestFees(config.FeesConfig{10, 5, 20})
+	attestFees = NewAttestFees(config.FeesConfig{10, 5, 20, nil, nil, "", -1})
 	assert.Equal(t, 10, attestFees.minFee)
 	assert.Equal(t, DefaultMaxFee, attestFees.maxFee)
 	assert.Equal(t, 20, attestFees.feeIncrement)
@@ -63,7 +67,7 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	assert.Equal(t, 10, attestFees.GetFee())
 
 	// test attest fees with new config
-	attestFees = NewAttestFees(config.FeesConfig{10, 30, 0})
+	attestFees = NewAttestFees(config.FeesConfig{10, 30, 0, nil, nil, "", -1})
 	assert.Equal(t, 10, attestFees.minFee)
 	assert.Equal(t, 30, attestFees.maxFee)
 	assert.Equal(t, DefaultFeeIncrement, attestFees.feeIncrement)
@@ -72,7 +76,7 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	assert.Equal(t, 10, attestFees.GetFee())
 
 	// test attest fees with new config
-	attestFees = NewAttestFees(config.FeesConfig{10, 0, 40})
+	attestFees = NewAttestFees(config.FeesConfig{10, 0, 40, nil, nil, "", -1})
 	assert.Equal(t, 10, attestFees.minFee)
 	assert.Equal(t, DefaultMaxFee, attestFees.maxFee)
 	assert.Equal(t, 40, attestFees.feeIncrement)
@@ -81,7 +85,7 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	assert.Equal(t, 10, attestFees.GetFee())
 
 	// test attest fees with new config
-	attestFees = NewAttestFees(config.FeesConfig{110, 110, -30})
+	attestFees = NewAttestFees(config.FeesConfig{110, 110, -30, nil, nil, "", -1})
 	assert.Equal(t, DefaultMinFee, attestFees.minFee)
 	assert.Equal(t, DefaultMaxFee, attestFees.maxFee)
 	assert.Equal(t, DefaultFeeIncrement, attestFees.feeIncrement)
@@ -89,3 +93,225 @@ func TestAttestFeesWithConfig(t *testing.T) {
 	attestFees.ResetFee(true)
 	assert.Equal(t, DefaultMinFee, attestFees.GetFee())
 }
+
+// Attest Fees test with fee API failover
+func TestAttestFeesApiFailover(t *testing.T) {
+
+	// fee APIs returning various responses
+	apiZero := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"fastestFee": 0, "halfHourFee": 0, "hourFee": 0}`)
+	}))
+	defer apiZero.Close()
+	apiHigh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"fastestFee": 100000, "halfHourFee": 100000, "hourFee": 100000}`)
+	}))
+	defer apiHigh.Close()
+	apiError := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "error", http.StatusInternalServerError)
+	}))
+	defer apiError.Close()
+	apiValid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"estimates": "fees", "fastest_fee": 40, "hour_fee": 25}`)
+	}))
+	defer apiValid.Close()
+
+	// test first plausible fee is used
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiZero.URL, apiHigh.URL, apiError.URL, apiValid.URL},
+		[]string{"", "", "", "hour_fee"}, "", -1})
+	assert.Equal(t, 4, len(attestFees.feeApis))
+	assert.Equal(t, DefaultBestFeeType, attestFees.feeApis[0].feeType)
+	assert.Equal(t, "hour_fee", attestFees.feeApis[3].feeType)
+	assert.Equal(t, 25, attestFees.getBestFee())
+	assert.Equal(t, 25, attestFees.GetFee())
+
+	// test fee type missing from response
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, []string{"missing_fee"}, "", -1})
+	assert.Equal(t, -1, attestFees.getBestFee())
+	assert.Equal(t, 10, attestFees.GetFee())
+
+	// test all fee APIs failing
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiZero.URL, apiHigh.URL, apiError.URL}, nil, "", -1})
+	assert.Equal(t, -1, attestFees.getBestFee())
+	assert.Equal(t, 10, attestFees.GetFee())
+
+	// test default fee API used when none configured
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5, nil, nil, "", -1})
+	assert.Equal(t, []feeApi{feeApi{FeeApiUrl, DefaultBestFeeType}}, attestFees.feeApis)
+}
+
+// Attest Fees test with concurrent access
+// Run with the -race flag to detect data races
+func TestAttestFeesConcurrentAccess(t *testing.T) {
+
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5, nil, nil, "", -1})
+	attestFees.ResetFee(true)
+
+	// concurrently read, bump and reset fees
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			fee := attestFees.GetFee()
+			assert.Equal(t, true, fee >= attestFees.minFee && fee <= attestFees.maxFee)
+		}()
+		go func() {
+			defer wg.Done()
+			attestFees.BumpFee()
+		}()
+		go func() {
+			defer wg.Done()
+			attestFees.ResetFee(true)
+		}()
+	}
+	wg.Wait()
+
+	fee := attestFees.GetFee()
+	assert.Equal(t, true, fee >= attestFees.minFee && fee <= attestFees.maxFee)
+}
+
+// Attest Fees test for fee strategy simulation
+func TestAttestFeesSimulateFees(t *testing.T) {
+
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5, nil, nil, "", -1})
+	attestFees.ResetFee(true)
+
+	// test empty history
+	simulation := attestFees.SimulateFees([]int{})
+	assert.Equal(t, FeeSimulation{}, simulation)
+
+	// test falling fees confirmed on next step
+	simulation = attestFees.SimulateFees([]int{45, 20, 12})
+	assert.Equal(t, []int{45, 20, 12}, simulation.Fees)
+	assert.Equal(t, []bool{false, true, true}, simulation.Confirmed)
+	assert.Equal(t, 2, simulation.NumOfConfirmed)
+	assert.Equal(t, false, simulation.StuckAtMaxFee)
+
+	// test fees below min fee and failed api values use min fee
+	simulation = attestFees.SimulateFees([]int{1, -1})
+	assert.Equal(t, []int{10, 10}, simulation.Fees)
+	assert.Equal(t, []bool{false, true}, simulation.Confirmed)
+	assert.Equal(t, 1, simulation.NumOfConfirmed)
+
+	// test fee bumping until confirmed
+	simulation = attestFees.SimulateFees([]int{30, 40, 40, 20})
+	assert.Equal(t, []int{30, 35, 40, 20}, simulation.Fees)
+	assert.Equal(t, []bool{false, false, false, true}, simulation.Confirmed)
+	assert.Equal(t, 1, simulation.NumOfConfirmed)
+	assert.Equal(t, false, simulation.StuckAtMaxFee)
+
+	// test fees higher than max fee get stuck
+	simulation = attestFees.SimulateFees([]int{45, 60, 60, 60, 30})
+	assert.Equal(t, []int{45, 50, 50, 50, 30}, simulation.Fees)
+	assert.Equal(t, []bool{false, false, false, false, true}, simulation.Confirmed)
+	assert.Equal(t, 1, simulation.NumOfConfirmed)
+	assert.Equal(t, true, simulation.StuckAtMaxFee)
+
+	// test current fee not modified
+	assert.Equal(t, 10, attestFees.GetFee())
+}
+
+// Attest Fees test with latest block feerate used as fee floor
+func TestAttestFeesBlockFeeFloor(t *testing.T) {
+
+	apiValid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"fastestFee": 40, "halfHourFee": 30, "hourFee": 20}`)
+	}))
+	defer apiValid.Close()
+
+	rpcFake := NewAttestRpcClientFake()
+	rpcFake.SetBlockStats(12, []int64{12, 15, 30, 45, 60})
+
+	// test block fee floor disabled
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, "", -1}, rpcFake)
+	assert.Equal(t, -1, attestFees.getBlockFeeFloor())
+	assert.Equal(t, 20, attestFees.GetFee())
+
+	// test block fee floor disabled without main client
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, BlockFeeFloorMedian, -1})
+	assert.Equal(t, "", attestFees.blockFeeFloor)
+	assert.Equal(t, 20, attestFees.GetFee())
+
+	// test invalid block fee floor option ignored
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, "max", -1}, rpcFake)
+	assert.Equal(t, "", attestFees.blockFeeFloor)
+	assert.Equal(t, 20, attestFees.GetFee())
+
+	// test median feerate higher than api fee used
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, BlockFeeFloorMedian, -1}, rpcFake)
+	assert.Equal(t, 30, attestFees.getBlockFeeFloor())
+	assert.Equal(t, 30, attestFees.GetFee())
+
+	// test min feerate lower than api fee ignored
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, BlockFeeFloorMin, -1}, rpcFake)
+	assert.Equal(t, 12, attestFees.getBlockFeeFloor())
+	assert.Equal(t, 20, attestFees.GetFee())
+
+	// test block fee floor limited by max fee
+	rpcFake.SetBlockStats(12, []int64{12, 15, 80, 90, 100})
+	attestFees.blockFeeFloor = BlockFeeFloorMedian
+	attestFees.ResetFee()
+	assert.Equal(t, 50, attestFees.GetFee())
+
+	// test block fee floor used when fee apis fail
+	attestFees.feeApis = []feeApi{feeApi{"http://localhost:0", DefaultBestFeeType}}
+	rpcFake.SetBlockStats(12, []int64{12, 15, 35, 45, 60})
+	attestFees.ResetFee()
+	assert.Equal(t, 35, attestFees.GetFee())
+
+	// test block stats request failure ignored
+	rpcFake.SetBlockStats(0, nil)
+	attestFees.feeApis = []feeApi{feeApi{apiValid.URL, DefaultBestFeeType}}
+	assert.Equal(t, -1, attestFees.getBlockFeeFloor())
+	attestFees.ResetFee()
+	assert.Equal(t, 20, attestFees.GetFee())
+}
+
+// Attest Fees test with fixed fee used instead of the fee APIs
+func TestAttestFeesFixedFee(t *testing.T) {
+
+	apiCalled := false
+	apiValid := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalled = true
+		fmt.Fprint(w, `{"fastestFee": 40, "halfHourFee": 30, "hourFee": 20}`)
+	}))
+	defer apiValid.Close()
+
+	rpcFake := NewAttestRpcClientFake()
+	rpcFake.SetBlockStats(12, []int64{12, 15, 30, 45, 60})
+
+	// test fixed fee used without fee api or block fee floor
+	attestFees := NewAttestFees(config.FeesConfig{10, 50, 5,
+		[]string{apiValid.URL}, nil, BlockFeeFloorMedian, 15}, rpcFake)
+	assert.Equal(t, 15, attestFees.getBestFee())
+	assert.Equal(t, 15, attestFees.GetFee())
+	fee, feeErr := attestFees.CheckFeeApis()
+	assert.Equal(t, nil, feeErr)
+	assert.Equal(t, 15, fee)
+	assert.Equal(t, false, apiCalled)
+
+	// test fixed fee limited by min and max fee
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5, []string{apiValid.URL}, nil, "", 80})
+	assert.Equal(t, 50, attestFees.GetFee())
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5, []string{apiValid.URL}, nil, "", 2})
+	assert.Equal(t, 10, attestFees.GetFee())
+	assert.Equal(t, false, apiCalled)
+
+	// test fee bumping from fixed fee
+	attestFees.BumpFee()
+	assert.Equal(t, 15, attestFees.GetFee())
+
+	// test non positive fixed fee ignored
+	attestFees = NewAttestFees(config.FeesConfig{10, 50, 5, []string{apiValid.URL}, nil, "", 0})
+	assert.Equal(t, -1, attestFees.fixedFee)
+	assert.Equal(t, 20, attestFees.GetFee())
+	assert.Equal(t, true, apiCalled)
+}
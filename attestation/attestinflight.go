@@ -0,0 +1,92 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Utility functions to guard the unspents of the in-flight attestation
+// Once an attestation transaction is built its unspents are reserved until
+// the transaction confirms or the reservation is released, so that an
+// overlapping attestation cycle cannot build a conflicting transaction
+
+// error consts
+const (
+	ErrorInFlightAttestation = "Unspents reserved by another in-flight attestation"
+	ErrorInFlightReplacement = "Attestation replacement spends unspents not reserved"
+)
+
+// inFlightGuard structure
+// Reservation of the unspents spent by the in-flight attestation
+// Bounded to a single in-flight attestation at a time. The reservation
+// is keyed by the attestation commitment, not the txid, so that fee
+// bumped replacements spending the same unspents keep the reservation
+type inFlightGuard struct {
+	// mutex guarding reservation access
+	mutex sync.Mutex
+
+	// commitment and latest txid of the in-flight attestation
+	commitment chainhash.Hash
+	txid       chainhash.Hash
+
+	// unspents reserved by the in-flight attestation
+	outpoints map[wire.OutPoint]bool
+}
+
+// Reserve the unspents of an attestation transaction for the attestation commitment
+// A new attestation is rejected while another attestation is in-flight, while
+// a rebuilt or replacement transaction of the in-flight attestation must spend
+// only the reserved unspents and updates the in-flight txid
+func (w *AttestClient) reserveUnspents(commitment chainhash.Hash, msgTx *wire.MsgTx) error {
+	g := &w.inFlight
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	txid := msgTx.TxHash()
+	if len(g.outpoints) > 0 {
+		if g.commitment != commitment {
+			return errors.New(fmt.Sprintf("%s (%s)", ErrorInFlightAttestation, g.txid.String()))
+		}
+		for _, txIn := range msgTx.TxIn {
+			if !g.outpoints[txIn.PreviousOutPoint] {
+				return errors.New(fmt.Sprintf("%s (%s)", ErrorInFlightReplacement, txIn.PreviousOutPoint.String()))
+			}
+		}
+		g.txid = txid
+		log.Printf("*Client* In-flight attestation updated to txid: %s\n", txid.String())
+		return nil
+	}
+
+	g.commitment = commitment
+	g.txid = txid
+	g.outpoints = make(map[wire.OutPoint]bool)
+	for _, txIn := range msgTx.TxIn {
+		g.outpoints[txIn.PreviousOutPoint] = true
+	}
+	log.Printf("*Client* Reserved %d unspents for in-flight attestation txid: %s\n", len(g.outpoints), txid.String())
+	return nil
+}
+
+// Release the unspents reserved by the in-flight attestation
+// Called once the attestation confirms or is no longer in-flight
+func (w *AttestClient) releaseUnspents() {
+	g := &w.inFlight
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.outpoints) > 0 {
+		log.Printf("*Client* Released unspents of in-flight attestation txid: %s\n", g.txid.String())
+	}
+	g.commitment = chainhash.Hash{}
+	g.txid = chainhash.Hash{}
+	g.outpoints = nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to keep an optional encrypted local journal of the
+// attestation addresses generated, i.e. the commitment hash each address
+// was tweaked with along with the address and redeem script, so that
+// attestation funds can be recovered if the service db and wallet are lost
+// without re-deriving addresses from the entire commitment history
+// Each entry is encrypted with AES-256-GCM under the configured journal key
+// and appended to the journal file as a base64 encoded line
+
+// error - warning consts
+const (
+	ErrorJournalKeyInvalid   = "Invalid attestation journal key - 32 byte hex key required"
+	ErrorJournalEntryInvalid = "Invalid attestation journal entry"
+
+	WarningJournalRecordFailed = "Warning - Failed recording attestation address to journal"
+)
+
+// JournalEntry structure
+// Attestation address generated for a commitment hash
+// Redeem script is empty if attesting with a single key
+type JournalEntry struct {
+	Commitment string    `json:"commitment"`
+	Address    string    `json:"address"`
+	Script     string    `json:"script,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// AttestJournal structure
+// Append only journal file of encrypted attestation address entries
+type AttestJournal struct {
+	// mutex guarding journal file writes
+	mutex sync.Mutex
+
+	// path of the journal file
+	path string
+
+	// cipher used to encrypt journal entries
+	aead cipher.AEAD
+
+	// commitment hash of the latest entry recorded to skip
+	// recording the same address again on attestation retries
+	latest chainhash.Hash
+}
+
+// Return AEAD cipher for the hex encoded 32 byte journal key
+func newJournalCipher(keyHex string) (cipher.AEAD, error) {
+	key, keyErr := hex.DecodeString(keyHex)
+	if keyErr != nil || len(key) != 32 {
+		return nil, errors.New(ErrorJournalKeyInvalid)
+	}
+	block, blockErr := aes.NewCipher(key)
+	if blockErr != nil {
+		return nil, blockErr
+	}
+	return cipher.NewGCM(block)
+}
+
+// Return new attestation journal for the journal file path and key provided
+func NewAttestJournal(path string, keyHex string) (*AttestJournal, error) {
+	aead, aeadErr := newJournalCipher(keyHex)
+	if aeadErr != nil {
+		return nil, aeadErr
+	}
+	return &AttestJournal{path: path, aead: aead}, nil
+}
+
+// Return attestation journal from the attestation config
+// Journal is disabled and nil is returned if no journal path is set
+func newAttestJournalFromConfig(attestationConfig confpkg.AttestationConfig) *AttestJournal {
+	if attestationConfig.JournalPath == "" {
+		return nil
+	}
+	journal, journalErr := NewAttestJournal(attestationConfig.JournalPath, attestationConfig.JournalKey)
+	if journalErr != nil {
+		log.Fatal(journalErr)
+	}
+	log.Printf("Attestation journal set to: %s\n", attestationConfig.JournalPath)
+	return journal
+}
+
+// Record attestation address and redeem script generated for commitment hash
+// The entry is encrypted and appended to the journal file, which is synced
+// to disk before returning so that a recorded entry survives a crash
+func (j *AttestJournal) Record(hash chainhash.Hash, address string, script string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if hash == j.latest {
+		return nil
+	}
+
+	entry := JournalEntry{hash.String(), address, script, time.Now()}
+	data, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	nonce := make([]byte, j.aead.NonceSize())
+	if _, nonceErr := rand.Read(nonce); nonceErr != nil {
+		return nonceErr
+	}
+	sealed := j.aead.Seal(nonce, nonce, data, nil)
+
+	file, openErr := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	if _, writeErr := file.WriteString(base64.StdEncoding.EncodeToString(sealed) + "\n"); writeErr != nil {
+		return writeErr
+	}
+	if syncErr := file.Sync(); syncErr != nil {
+		return syncErr
+	}
+	j.latest = hash
+	return nil
+}
+
+// Record attestation address generated for the current commitment to the
+// journal if configured. Failures are logged without interrupting attestation
+func (s *AttestService) recordJournal(address string, script string) {
+	if s.journal == nil {
+		return
+	}
+	if recordErr := s.journal.Record(s.attestation.CommitmentHash(), address, script); recordErr != nil {
+		log.Printf("*AttestService* %s %v\n", WarningJournalRecordFailed, recordErr)
+	}
+}
+
+// Read and decrypt all entries of the journal file with the key provided
+// Used by recovery tooling to rebuild the attestation addresses and scripts
+func ReadAttestJournal(path string, keyHex string) ([]JournalEntry, error) {
+	aead, aeadErr := newJournalCipher(keyHex)
+	if aeadErr != nil {
+		return nil, aeadErr
+	}
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		sealed, decodeErr := base64.StdEncoding.DecodeString(scanner.Text())
+		if decodeErr != nil || len(sealed) < aead.NonceSize() {
+			return nil, errors.New(fmt.Sprintf("%s (line %d)", ErrorJournalEntryInvalid, line))
+		}
+		data, openErr := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if openErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s (line %d) %v", ErrorJournalEntryInvalid, line, openErr))
+		}
+		var entry JournalEntry
+		if unmarshalErr := json.Unmarshal(data, &entry); unmarshalErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s (line %d) %v", ErrorJournalEntryInvalid, line, unmarshalErr))
+		}
+		entries = append(entries, entry)
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
+	return entries, nil
+}
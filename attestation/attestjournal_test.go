@@ -0,0 +1,110 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJournalKey = "0a090f710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa"
+
+// Test attestation journal encrypted entries recording and reading
+func TestAttestJournal(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "mainstay-journal")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	// test invalid journal keys
+	_, journalErr := NewAttestJournal(path, "")
+	assert.Equal(t, errors.New(ErrorJournalKeyInvalid), journalErr)
+	_, journalErr = NewAttestJournal(path, "0a090f71")
+	assert.Equal(t, errors.New(ErrorJournalKeyInvalid), journalErr)
+
+	journal, journalErr := NewAttestJournal(path, testJournalKey)
+	assert.Equal(t, nil, journalErr)
+
+	// test entries recorded in order and encrypted at rest
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, nil, journal.Record(*hashX, "addrX", "scriptX"))
+	assert.Equal(t, nil, journal.Record(*hashX, "addrX", "scriptX")) // skipped on retry
+	assert.Equal(t, nil, journal.Record(*hashY, "addrY", ""))
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+	assert.Equal(t, false, strings.Contains(string(data), "addrX"))
+	assert.Equal(t, false, strings.Contains(string(data), hashX.String()))
+
+	entries, readErr := ReadAttestJournal(path, testJournalKey)
+	assert.Equal(t, nil, readErr)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, hashX.String(), entries[0].Commitment)
+	assert.Equal(t, "addrX", entries[0].Address)
+	assert.Equal(t, "scriptX", entries[0].Script)
+	assert.Equal(t, hashY.String(), entries[1].Commitment)
+	assert.Equal(t, "addrY", entries[1].Address)
+	assert.Equal(t, "", entries[1].Script)
+
+	// test entries appended to existing journal on restart
+	journal, _ = NewAttestJournal(path, testJournalKey)
+	assert.Equal(t, nil, journal.Record(*hashX, "addrX", "scriptX"))
+	entries, _ = ReadAttestJournal(path, testJournalKey)
+	assert.Equal(t, 3, len(entries))
+
+	// test reading with wrong key fails
+	_, readErr = ReadAttestJournal(path, strings.Repeat("ab", 32))
+	assert.Equal(t, true, strings.HasPrefix(readErr.Error(), ErrorJournalEntryInvalid+" (line 1)"))
+
+	// test reading corrupted journal fails
+	assert.Equal(t, nil, ioutil.WriteFile(path, append(data, []byte("invalid\n")...), 0600))
+	_, readErr = ReadAttestJournal(path, testJournalKey)
+	assert.Equal(t, errors.New(ErrorJournalEntryInvalid+" (line 3)"), readErr)
+}
+
+// Test Attest Service recording attestation addresses to journal
+func TestAttestService_Journal(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "mainstay-journal")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal")
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake(nil), config)
+	assert.Equal(t, (*AttestJournal)(nil), attestService.journal) // disabled by default
+	attestService.journal, _ = NewAttestJournal(path, testJournalKey)
+
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+
+	// test address and script of new attestation recorded
+	addr, script, _ := attestService.attester.GetNextAttestationAddr((*btcutil.WIF)(nil), attestService.attestation.CommitmentHash())
+	entries, readErr := ReadAttestJournal(path, testJournalKey)
+	assert.Equal(t, nil, readErr)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, attestService.attestation.CommitmentHash().String(), entries[0].Commitment)
+	assert.Equal(t, addr.String(), entries[0].Address)
+	assert.Equal(t, script, entries[0].Script)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Utility functions to compact the transaction pre-images sent to signers
+// Instead of a full transaction copy for each input, the compact format is
+// the transaction with empty signature scripts followed by the redeem script
+// of each input, from which signers rebuild the pre-image of each input
+
+// error consts
+const (
+	ErrorCompactPreImagesMissing = "Compact pre-images missing transaction"
+	ErrorCompactPreImagesInputs  = "Compact pre-images script count does not match transaction inputs"
+	ErrorCompactPreImagesTx      = "Pre-images do not share the same transaction"
+)
+
+// Get the serialized pre-images to send to signers
+// In compact mode the pre-images are compacted to the unsigned
+// transaction and the redeem script of each input, otherwise
+// the full pre-image transaction of each input is serialized
+func GetTxPreImageBytes(txPreImages []wire.MsgTx, compact bool) ([][]byte, error) {
+	if compact {
+		return CompactTxPreImages(txPreImages)
+	}
+	var txPreImageBytes [][]byte
+	for _, txPreImage := range txPreImages {
+		var txBytesBuffer bytes.Buffer
+		txPreImage.Serialize(&txBytesBuffer)
+		txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+	}
+	return txPreImageBytes, nil
+}
+
+// Compact transaction pre-images to the serialized transaction with empty
+// signature scripts followed by the redeem script of each input, as set
+// in the signature script of the input in its own pre-image
+func CompactTxPreImages(txPreImages []wire.MsgTx) ([][]byte, error) {
+	if len(txPreImages) == 0 {
+		return nil, errors.New(ErrorCompactPreImagesMissing)
+	}
+	if len(txPreImages) != len(txPreImages[0].TxIn) {
+		return nil, errors.New(fmt.Sprintf("%s (%d scripts, %d inputs)",
+			ErrorCompactPreImagesInputs, len(txPreImages), len(txPreImages[0].TxIn)))
+	}
+
+	// strip signature scripts to get the unsigned transaction
+	unsignedTx := txPreImages[0].Copy()
+	for _, txIn := range unsignedTx.TxIn {
+		txIn.SignatureScript = nil
+	}
+	unsignedTxHash := unsignedTx.TxHash()
+
+	var unsignedTxBuffer bytes.Buffer
+	unsignedTx.Serialize(&unsignedTxBuffer)
+	compact := [][]byte{unsignedTxBuffer.Bytes()}
+	for i, txPreImage := range txPreImages {
+		if len(txPreImage.TxIn) != len(unsignedTx.TxIn) {
+			return nil, errors.New(fmt.Sprintf("%s (pre-image %d)", ErrorCompactPreImagesTx, i))
+		}
+		script := txPreImage.TxIn[i].SignatureScript
+
+		// every pre-image must be the unsigned transaction with a single script set
+		preImageTx := txPreImage.Copy()
+		preImageTx.TxIn[i].SignatureScript = nil
+		if preImageTx.TxHash() != unsignedTxHash {
+			return nil, errors.New(fmt.Sprintf("%s (pre-image %d)", ErrorCompactPreImagesTx, i))
+		}
+		compact = append(compact, script)
+	}
+	return compact, nil
+}
+
+// Expand compact transaction pre-images to the serialized pre-image
+// of each input, setting the signature script of each input of the
+// unsigned transaction to the corresponding redeem script
+func ExpandTxPreImages(compact [][]byte) ([][]byte, error) {
+	if len(compact) == 0 {
+		return nil, errors.New(ErrorCompactPreImagesMissing)
+	}
+	var unsignedTx wire.MsgTx
+	if deserializeErr := unsignedTx.Deserialize(bytes.NewReader(compact[0])); deserializeErr != nil {
+		return nil, deserializeErr
+	}
+	scripts := compact[1:]
+	if len(scripts) != len(unsignedTx.TxIn) {
+		return nil, errors.New(fmt.Sprintf("%s (%d scripts, %d inputs)",
+			ErrorCompactPreImagesInputs, len(scripts), len(unsignedTx.TxIn)))
+	}
+
+	var txPreImageBytes [][]byte
+	for i, script := range scripts {
+		preImageTx := unsignedTx.Copy()
+		preImageTx.TxIn[i].SignatureScript = script
+		var txBytesBuffer bytes.Buffer
+		preImageTx.Serialize(&txBytesBuffer)
+		txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+	}
+	return txPreImageBytes, nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test compacting and expanding transaction pre-images
+func TestAttestPreImages_Compact(t *testing.T) {
+	// unsigned tx with two inputs and pre-image for each input
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash0, 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash1, 1), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0xa9, 0x14}))
+	scripts := [][]byte{{0x51, 0x21, 0xae}, {0x52, 0x21, 0xae}}
+	var txPreImages []wire.MsgTx
+	for i, script := range scripts {
+		txPreImage := tx.Copy()
+		txPreImage.TxIn[i].SignatureScript = script
+		txPreImages = append(txPreImages, *txPreImage)
+	}
+
+	// full pre-images
+	fullBytes, fullErr := GetTxPreImageBytes(txPreImages, false)
+	assert.Equal(t, nil, fullErr)
+	assert.Equal(t, 2, len(fullBytes))
+
+	// compact pre-images
+	compactBytes, compactErr := GetTxPreImageBytes(txPreImages, true)
+	assert.Equal(t, nil, compactErr)
+	assert.Equal(t, 3, len(compactBytes))
+	assert.Equal(t, scripts[0], compactBytes[1])
+	assert.Equal(t, scripts[1], compactBytes[2])
+	assert.Equal(t, true, len(SerializeBytes(compactBytes)) < len(SerializeBytes(fullBytes)))
+
+	// expanded compact pre-images match full pre-images
+	expandedBytes, expandErr := ExpandTxPreImages(compactBytes)
+	assert.Equal(t, nil, expandErr)
+	assert.Equal(t, fullBytes, expandedBytes)
+	expandedBytes, expandErr = ExpandTxPreImages(UnserializeBytes(SerializeBytes(compactBytes)))
+	assert.Equal(t, nil, expandErr)
+	assert.Equal(t, fullBytes, expandedBytes)
+
+	// test script count not matching inputs
+	_, compactErr = CompactTxPreImages(txPreImages[:1])
+	assert.Equal(t, true, strings.HasPrefix(compactErr.Error(), ErrorCompactPreImagesInputs))
+	_, expandErr = ExpandTxPreImages(compactBytes[:2])
+	assert.Equal(t, true, strings.HasPrefix(expandErr.Error(), ErrorCompactPreImagesInputs))
+
+	// test pre-images of different transactions
+	otherPreImage := txPreImages[1].Copy()
+	otherPreImage.TxOut[0].Value = 2000
+	_, compactErr = CompactTxPreImages([]wire.MsgTx{txPreImages[0], *otherPreImage})
+	assert.Equal(t, true, strings.HasPrefix(compactErr.Error(), ErrorCompactPreImagesTx))
+
+	// test missing or invalid transaction
+	_, compactErr = CompactTxPreImages([]wire.MsgTx{})
+	assert.Equal(t, errors.New(ErrorCompactPreImagesMissing), compactErr)
+	_, expandErr = ExpandTxPreImages([][]byte{})
+	assert.Equal(t, errors.New(ErrorCompactPreImagesMissing), expandErr)
+	_, expandErr = ExpandTxPreImages([][]byte{{0x01, 0x02}})
+	assert.NotEqual(t, nil, expandErr)
+}
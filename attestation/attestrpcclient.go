@@ -0,0 +1,38 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// AttestRpcClient interface
+//
+// Provides the interface for the rpc connection to the
+// main bitcoin client used by the attestation client for
+// wallet, transaction creation and broadcasting methods
+//
+// The interface is implemented by the btcd rpcclient
+// This interface allows building mock struct for testing
+type AttestRpcClient interface {
+	ListUnspent() ([]btcjson.ListUnspentResult, error)
+	ListUnspentMin(int) ([]btcjson.ListUnspentResult, error)
+	GetRawMempool() ([]*chainhash.Hash, error)
+	GetRawTransaction(*chainhash.Hash) (*btcutil.Tx, error)
+	GetRawTransactionVerbose(*chainhash.Hash) (*btcjson.TxRawResult, error)
+	GetTransaction(*chainhash.Hash) (*btcjson.GetTransactionResult, error)
+	GetTxOut(*chainhash.Hash, uint32, bool) (*btcjson.GetTxOutResult, error)
+	CreateRawTransaction([]btcjson.TransactionInput, map[btcutil.Address]btcutil.Amount, *int64) (*wire.MsgTx, error)
+	SignRawTransaction3(*wire.MsgTx, []btcjson.RawTxInput, []string) (*wire.MsgTx, bool, error)
+	SendRawTransaction(*wire.MsgTx, bool) (*chainhash.Hash, error)
+	ImportAddressRescan(string, string, bool) error
+	RawRequest(string, []json.RawMessage) (json.RawMessage, error)
+	GetBlockCount() (int64, error)
+}
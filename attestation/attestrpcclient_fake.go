@@ -0,0 +1,409 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// error consts
+const (
+	ErrorFakeTxNotFound     = "No information available about transaction"
+	ErrorFakeMethodNotFound = "Method not found"
+	ErrorFakeWalletDisabled = "Method not found (wallet disabled)"
+)
+
+// fake block hash set for confirmed transactions
+const FakeBlockHash = "0000000000000000000000000000000000000000000000000000000000000001"
+
+// fake block height of the chain tip
+const FakeBlockHeight = 100
+
+// AttestRpcClientFake struct
+//
+// Implements AttestRpcClient interface and provides
+// mock functionality of a bitcoin wallet, storing
+// transactions, unspents and mempool in memory
+type AttestRpcClientFake struct {
+	txs           map[chainhash.Hash]*wire.MsgTx
+	confirmations map[chainhash.Hash]int64
+	unspent       []btcjson.ListUnspentResult
+	mempool       []*chainhash.Hash
+	imported      []string
+	labels        []string
+	blockStats    blockStatsResult
+	descriptors   bool
+	wallets       []string
+	sendErrors    []error
+	signInputs    []btcjson.RawTxInput
+
+	// reject wallet methods to simulate a node without a wallet
+	walletDisabled bool
+}
+
+// Return new AttestRpcClientFake instance
+func NewAttestRpcClientFake() *AttestRpcClientFake {
+	return &AttestRpcClientFake{
+		txs:           map[chainhash.Hash]*wire.MsgTx{},
+		confirmations: map[chainhash.Hash]int64{}}
+}
+
+// Add confirmed transaction for testing
+// Output vout 0 is added to the wallet unspent list if it is unspent
+func (f *AttestRpcClientFake) AddTransaction(msgTx *wire.MsgTx) chainhash.Hash {
+	txid := msgTx.TxHash()
+	f.txs[txid] = msgTx
+	f.confirmations[txid] = 1
+	if !f.isSpent(txid, 0) {
+		f.unspent = append(f.unspent, btcjson.ListUnspentResult{
+			TxID:          txid.String(),
+			Vout:          0,
+			Amount:        float64(msgTx.TxOut[0].Value) / Coin,
+			Confirmations: 1})
+	}
+	return txid
+}
+
+// Confirm all mempool transactions for testing
+// Mimics block generation on regtest
+func (f *AttestRpcClientFake) Generate(numBlocks uint32) {
+	for txid := range f.confirmations {
+		if f.confirmations[txid] > 0 {
+			f.confirmations[txid] += int64(numBlocks)
+		}
+	}
+	mempool := f.mempool
+	f.mempool = nil
+	for _, txid := range mempool {
+		f.AddTransaction(f.txs[*txid])
+		f.confirmations[*txid] = int64(numBlocks)
+	}
+}
+
+// Set feerate stats returned by getblockstats for testing
+func (f *AttestRpcClientFake) SetBlockStats(minFeeRate int64, feeRatePercentiles []int64) {
+	f.blockStats = blockStatsResult{MinFeeRate: minFeeRate, FeeRatePercentiles: feeRatePercentiles}
+}
+
+// Set fake wallet as descriptor wallet for testing
+func (f *AttestRpcClientFake) SetDescriptorWallet(descriptors bool) {
+	f.descriptors = descriptors
+}
+
+// Set wallets loaded by the fake client for testing
+func (f *AttestRpcClientFake) SetWallets(wallets ...string) {
+	f.wallets = wallets
+}
+
+// Set errors returned by the next calls to SendRawTransaction for testing
+// Each error is returned once in order before sending succeeds again
+func (f *AttestRpcClientFake) SetSendErrors(errs ...error) {
+	f.sendErrors = errs
+}
+
+// Set fake wallet disabled for testing nodes without a wallet
+func (f *AttestRpcClientFake) SetWalletDisabled(disabled bool) {
+	f.walletDisabled = disabled
+}
+
+// Return addresses imported to the fake wallet
+func (f *AttestRpcClientFake) ImportedAddresses() []string {
+	return f.imported
+}
+
+// Return labels of addresses imported to the fake wallet
+func (f *AttestRpcClientFake) ImportedLabels() []string {
+	return f.labels
+}
+
+// Return inputs info of the latest transaction signed by the fake wallet
+func (f *AttestRpcClientFake) SignedInputs() []btcjson.RawTxInput {
+	return f.signInputs
+}
+
+// Check if transaction output has been spent by any stored transaction
+func (f *AttestRpcClientFake) isSpent(txid chainhash.Hash, vout uint32) bool {
+	for _, msgTx := range f.txs {
+		for _, txIn := range msgTx.TxIn {
+			if txIn.PreviousOutPoint.Hash == txid && txIn.PreviousOutPoint.Index == vout {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Check if transaction output has been spent by a confirmed transaction or,
+// if mempool is set, by a transaction in the mempool. Transactions evicted
+// from the mempool without confirming no longer spend the output
+func (f *AttestRpcClientFake) isSpentConfirmed(txid chainhash.Hash, vout uint32, mempool bool) bool {
+	inMempool := make(map[chainhash.Hash]bool)
+	if mempool {
+		for _, mempoolTxid := range f.mempool {
+			inMempool[*mempoolTxid] = true
+		}
+	}
+	for spendTxid, msgTx := range f.txs {
+		if f.confirmations[spendTxid] < 1 && !inMempool[spendTxid] {
+			continue
+		}
+		for _, txIn := range msgTx.TxIn {
+			if txIn.PreviousOutPoint.Hash == txid && txIn.PreviousOutPoint.Index == vout {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Return list of wallet unspents
+func (f *AttestRpcClientFake) ListUnspent() ([]btcjson.ListUnspentResult, error) {
+	if f.walletDisabled {
+		return nil, errors.New(ErrorFakeWalletDisabled)
+	}
+	return f.unspent, nil
+}
+
+// Return list of wallet unspents with at least min confirmations
+func (f *AttestRpcClientFake) ListUnspentMin(minConf int) ([]btcjson.ListUnspentResult, error) {
+	if f.walletDisabled {
+		return nil, errors.New(ErrorFakeWalletDisabled)
+	}
+	var unspent []btcjson.ListUnspentResult
+	for _, u := range f.unspent {
+		if u.Confirmations >= int64(minConf) {
+			unspent = append(unspent, u)
+		}
+	}
+	return unspent, nil
+}
+
+// Return list of mempool transaction ids
+func (f *AttestRpcClientFake) GetRawMempool() ([]*chainhash.Hash, error) {
+	return f.mempool, nil
+}
+
+// Return stored transaction
+func (f *AttestRpcClientFake) GetRawTransaction(txid *chainhash.Hash) (*btcutil.Tx, error) {
+	msgTx, ok := f.txs[*txid]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeTxNotFound, txid.String()))
+	}
+	return btcutil.NewTx(msgTx), nil
+}
+
+// Return stored transaction with verbose information
+func (f *AttestRpcClientFake) GetRawTransactionVerbose(txid *chainhash.Hash) (*btcjson.TxRawResult, error) {
+	msgTx, ok := f.txs[*txid]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeTxNotFound, txid.String()))
+	}
+	var txBuffer bytes.Buffer
+	msgTx.Serialize(&txBuffer)
+
+	blockHash := ""
+	if f.confirmations[*txid] > 0 {
+		blockHash = FakeBlockHash
+	}
+	return &btcjson.TxRawResult{
+		Txid:          txid.String(),
+		Hex:           hex.EncodeToString(txBuffer.Bytes()),
+		Confirmations: uint64(f.confirmations[*txid]),
+		BlockHash:     blockHash}, nil
+}
+
+// Return stored transaction wallet information
+func (f *AttestRpcClientFake) GetTransaction(txid *chainhash.Hash) (*btcjson.GetTransactionResult, error) {
+	if f.walletDisabled {
+		return nil, errors.New(ErrorFakeWalletDisabled)
+	}
+	msgTx, ok := f.txs[*txid]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeTxNotFound, txid.String()))
+	}
+	var txBuffer bytes.Buffer
+	msgTx.Serialize(&txBuffer)
+
+	blockHash := ""
+	if f.confirmations[*txid] > 0 {
+		blockHash = FakeBlockHash
+	}
+	return &btcjson.GetTransactionResult{
+		TxID:          txid.String(),
+		Hex:           hex.EncodeToString(txBuffer.Bytes()),
+		Confirmations: f.confirmations[*txid],
+		BlockHash:     blockHash}, nil
+}
+
+// Return transaction output if it is unspent
+func (f *AttestRpcClientFake) GetTxOut(txid *chainhash.Hash, vout uint32, mempool bool) (*btcjson.GetTxOutResult, error) {
+	msgTx, ok := f.txs[*txid]
+	if !ok || int(vout) >= len(msgTx.TxOut) || f.isSpentConfirmed(*txid, vout, mempool) {
+		return nil, nil
+	}
+	return &btcjson.GetTxOutResult{
+		Confirmations: f.confirmations[*txid],
+		Value:         float64(msgTx.TxOut[vout].Value) / Coin}, nil
+}
+
+// Create transaction spending inputs to the address amounts provided
+func (f *AttestRpcClientFake) CreateRawTransaction(inputs []btcjson.TransactionInput,
+	amounts map[btcutil.Address]btcutil.Amount, lockTime *int64) (*wire.MsgTx, error) {
+
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range inputs {
+		txid, hashErr := chainhash.NewHashFromStr(input.Txid)
+		if hashErr != nil {
+			return nil, hashErr
+		}
+		msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(txid, input.Vout), nil, nil))
+	}
+	for addr, amount := range amounts {
+		pkScript, pkScriptErr := txscript.PayToAddrScript(addr)
+		if pkScriptErr != nil {
+			return nil, pkScriptErr
+		}
+		msgTx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+	}
+	if lockTime != nil {
+		msgTx.LockTime = uint32(*lockTime)
+	}
+	return msgTx, nil
+}
+
+// Return transaction unchanged and incomplete as fake wallet does not sign
+// The inputs info provided for signing are kept for testing
+func (f *AttestRpcClientFake) SignRawTransaction3(msgTx *wire.MsgTx,
+	inputs []btcjson.RawTxInput, keys []string) (*wire.MsgTx, bool, error) {
+	f.signInputs = inputs
+	return msgTx.Copy(), false, nil
+}
+
+// Store transaction in mempool and remove spent unspents
+func (f *AttestRpcClientFake) SendRawTransaction(msgTx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	if len(f.sendErrors) > 0 {
+		sendErr := f.sendErrors[0]
+		f.sendErrors = f.sendErrors[1:]
+		return nil, sendErr
+	}
+
+	txid := msgTx.TxHash()
+	f.txs[txid] = msgTx
+	f.confirmations[txid] = 0
+	f.mempool = append(f.mempool, &txid)
+
+	var unspent []btcjson.ListUnspentResult
+	for _, u := range f.unspent {
+		unspentTxid, _ := chainhash.NewHashFromStr(u.TxID)
+		if !f.isSpent(*unspentTxid, u.Vout) {
+			unspent = append(unspent, u)
+		}
+	}
+	f.unspent = unspent
+	return &txid, nil
+}
+
+// Store imported address and label
+func (f *AttestRpcClientFake) ImportAddressRescan(address string, account string, rescan bool) error {
+	f.imported = append(f.imported, address)
+	f.labels = append(f.labels, account)
+	return nil
+}
+
+// Return fake block height of the chain tip
+func (f *AttestRpcClientFake) GetBlockCount() (int64, error) {
+	return FakeBlockHeight, nil
+}
+
+// Handle raw requests for methods not in the btcd rpcclient
+// Only getblockstats, if block stats are set, getwalletinfo, getaddressinfo,
+// addr() descriptor import, if the wallet is a descriptor wallet,
+// and scantxoutset with addr() descriptors of regtest addresses are supported
+func (f *AttestRpcClientFake) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method == "getblockstats" && len(f.blockStats.FeeRatePercentiles) > 0 {
+		return json.Marshal(f.blockStats)
+	}
+	if method == "getwalletinfo" {
+		return json.Marshal(walletInfoResult{Descriptors: f.descriptors})
+	}
+	if method == "listwallets" {
+		return json.Marshal(append([]string{}, f.wallets...))
+	}
+	if method == "getaddressinfo" && len(params) > 0 {
+		var addr string
+		if unmarshalErr := json.Unmarshal(params[0], &addr); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		watched := false
+		for _, imported := range f.imported {
+			watched = watched || imported == addr
+		}
+		return json.Marshal(addressInfoResult{IsMine: watched && f.descriptors, IsWatchOnly: watched && !f.descriptors})
+	}
+	if method == "getdescriptorinfo" && f.descriptors && len(params) > 0 {
+		var desc string
+		if unmarshalErr := json.Unmarshal(params[0], &desc); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		return json.Marshal(descriptorInfoResult{Descriptor: desc + "#fakechks"})
+	}
+	if method == "importdescriptors" && f.descriptors && len(params) > 0 {
+		var requests []importDescriptorRequest
+		if unmarshalErr := json.Unmarshal(params[0], &requests); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+		var results []importDescriptorResult
+		for _, request := range requests {
+			desc := strings.Split(request.Desc, "#")[0]
+			f.imported = append(f.imported, strings.TrimSuffix(strings.TrimPrefix(desc, "addr("), ")"))
+			f.labels = append(f.labels, request.Label)
+			results = append(results, importDescriptorResult{Success: true})
+		}
+		return json.Marshal(results)
+	}
+	if method != "scantxoutset" || len(params) < 2 {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorFakeMethodNotFound, method))
+	}
+	var descriptors []string
+	if unmarshalErr := json.Unmarshal(params[1], &descriptors); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	addrs := make(map[string]bool)
+	for _, desc := range descriptors {
+		addrs[strings.TrimSuffix(strings.TrimPrefix(desc, "addr("), ")")] = true
+	}
+
+	result := scanTxOutSetResult{Success: true, Height: FakeBlockHeight}
+	for txid, msgTx := range f.txs {
+		if f.confirmations[txid] < 1 {
+			continue
+		}
+		for vout, txOut := range msgTx.TxOut {
+			_, outAddrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, &chaincfg.RegressionNetParams)
+			if len(outAddrs) != 1 || !addrs[outAddrs[0].String()] || f.isSpent(txid, uint32(vout)) {
+				continue
+			}
+			result.Unspents = append(result.Unspents, scanTxOutSetUnspent{
+				TxID:         txid.String(),
+				Vout:         uint32(vout),
+				ScriptPubKey: hex.EncodeToString(txOut.PkScript),
+				Amount:       float64(txOut.Value) / Coin,
+				Height:       FakeBlockHeight - f.confirmations[txid] + 1})
+		}
+	}
+	return json.Marshal(result)
+}
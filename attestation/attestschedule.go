@@ -0,0 +1,99 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"mainstay/clock"
+	confpkg "mainstay/config"
+)
+
+// Utility functions to time new attestations on a cron-like schedule of
+// fixed UTC times instead of the new attestation interval, if configured
+// Schedule times missed, e.g. while an attestation was awaiting confirmation,
+// are either skipped until the next schedule time or caught up immediately
+
+// error - warning consts
+const (
+	ErrorInvalidMissedSchedule = "Invalid missed schedule config value"
+	ErrorScheduleNeverFires    = "Schedule never fires"
+
+	WarningScheduleMissed = "Warning - Attestation schedule time missed"
+)
+
+// Parse attestation schedule from timing config - nil if not set
+func parseAttestSchedule(timingConfig confpkg.TimingConfig, now time.Time) (*clock.Schedule, error) {
+	if timingConfig.Schedule == "" {
+		return nil, nil
+	}
+	switch timingConfig.MissedSchedule {
+	case "", confpkg.MissedScheduleSkip, confpkg.MissedScheduleCatchUp:
+	default:
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidMissedSchedule, timingConfig.MissedSchedule))
+	}
+	schedule, scheduleErr := clock.ParseSchedule(timingConfig.Schedule)
+	if scheduleErr != nil {
+		return nil, scheduleErr
+	}
+	if schedule.Next(now).IsZero() {
+		return nil, errors.New(fmt.Sprintf("%s (%s)", ErrorScheduleNeverFires, timingConfig.Schedule))
+	}
+	return schedule, nil
+}
+
+// Return the next schedule time after the current schedule time
+// and whether schedule times have been missed since, in which
+// case the next schedule time after now is returned instead
+// The current schedule time is returned if it has not passed yet
+func (s *AttestService) nextScheduleTime(now time.Time) (time.Time, bool) {
+	if s.scheduleTime.IsZero() {
+		return s.schedule.Next(now), false
+	} else if s.scheduleTime.After(now) {
+		return s.scheduleTime, false
+	}
+	next := s.schedule.Next(s.scheduleTime)
+	if next.After(now) {
+		return next, false
+	}
+	return s.schedule.Next(now), true
+}
+
+// Return the delay until the next attestation without advancing the schedule
+// i.e. the new attestation interval or the delay until the next schedule time
+func (s *AttestService) nextAttestationDelay() time.Duration {
+	if s.schedule == nil {
+		return s.atimeNewAttestation
+	}
+	now := s.clock.Now()
+	next, _ := s.nextScheduleTime(now)
+	return next.Sub(now)
+}
+
+// Return the delay until the next attestation and advance the schedule
+// In interval mode the elapsed time provided is subtracted from the delay,
+// while in schedule mode the delay lasts until the next schedule time
+// If schedule times were missed the next attestation is either made
+// immediately or at the next schedule time, depending on the config
+func (s *AttestService) newAttestationDelay(elapsed time.Duration) time.Duration {
+	if s.schedule == nil {
+		return s.atimeNewAttestation - elapsed
+	}
+	now := s.clock.Now()
+	next, missed := s.nextScheduleTime(now)
+	if missed {
+		log.Printf("*AttestService* %s (%s)\n", WarningScheduleMissed,
+			s.schedule.Next(s.scheduleTime).Format(time.RFC3339))
+		if s.config.TimingConfig().MissedSchedule == confpkg.MissedScheduleCatchUp {
+			s.scheduleTime = now
+			return 0
+		}
+	}
+	s.scheduleTime = next
+	return next.Sub(now)
+}
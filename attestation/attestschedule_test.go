@@ -0,0 +1,69 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"mainstay/clock"
+	confpkg "mainstay/config"
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Attest Service timing new attestations on a schedule
+func TestAttestService_Schedule(t *testing.T) {
+	now := time.Date(2019, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	// Test parsing schedule config
+	schedule, scheduleErr := parseAttestSchedule(confpkg.TimingConfig{-1, -1, -1, -1, "", ""}, now)
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, (*clock.Schedule)(nil), schedule)
+	_, scheduleErr = parseAttestSchedule(confpkg.TimingConfig{-1, -1, -1, -1, "0 * * * *", "invalid"}, now)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (invalid)", ErrorInvalidMissedSchedule)), scheduleErr)
+	_, scheduleErr = parseAttestSchedule(confpkg.TimingConfig{-1, -1, -1, -1, "0 0 30 2 *", ""}, now)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (0 0 30 2 *)", ErrorScheduleNeverFires)), scheduleErr)
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+	config.SetTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, "0 * * * *", confpkg.MissedScheduleSkip})
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	fakeClock := clock.NewClockFake(now)
+	attestService.SetClock(fakeClock)
+
+	// Test delay until the next schedule time regardless of the time elapsed
+	assert.Equal(t, 30*time.Minute, attestService.nextAttestationDelay())
+	assert.Equal(t, 30*time.Minute, attestService.newAttestationDelay(time.Minute))
+	assert.Equal(t, 30*time.Minute, attestService.newAttestationDelay(0))
+
+	// Test schedule advanced once the schedule time is reached
+	fakeClock.Advance(30 * time.Minute)
+	assert.Equal(t, time.Hour, attestService.nextAttestationDelay())
+	assert.Equal(t, time.Hour, attestService.newAttestationDelay(0))
+
+	// Test missed schedule times skipped
+	fakeClock.Advance(3*time.Hour + 20*time.Minute)
+	assert.Equal(t, 40*time.Minute, attestService.newAttestationDelay(0))
+
+	// Test missed schedule times caught up immediately
+	config.SetTimingConfig(confpkg.TimingConfig{-1, -1, -1, -1, "0 * * * *", confpkg.MissedScheduleCatchUp})
+	fakeClock.Advance(2*time.Hour + 50*time.Minute)
+	assert.Equal(t, time.Duration(0), attestService.newAttestationDelay(0))
+	assert.Equal(t, 50*time.Minute, attestService.newAttestationDelay(0))
+
+	// Test delay counted from the time elapsed without a schedule
+	attestService.schedule = nil
+	assert.Equal(t, attestService.atimeNewAttestation, attestService.nextAttestationDelay())
+	assert.Equal(t, attestService.atimeNewAttestation-time.Minute, attestService.newAttestationDelay(time.Minute))
+}
@@ -5,20 +5,23 @@
 package attestation
 
 import (
-	"bytes"
 	"context"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"mainstay/clock"
 	confpkg "mainstay/config"
 	"mainstay/models"
 	"mainstay/server"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	_ "github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -44,18 +47,40 @@ const (
 // error / warning consts
 const (
 	ErroUnspentNotFound = "No valid unspent found"
+	ErrorMaxFeeBumps    = "Attestation unconfirmed after maximum number of fee bumps"
 
 	WarningInvalidATimeNewAttestationArg    = "Warning - Invalid new attestation time config value"
 	WarningInvalidATimeHandleUnconfirmedArg = "Warning - Invalid handle unconfirmed time config value"
+	WarningInvalidATimeSigsArg              = "Warning - Invalid signatures time config value"
+	WarningMaxFeeBumpsReached               = "CRITICAL - Maximum number of fee bumps reached"
+	WarningTipCommitmentMismatch            = "Warning - Staychain tip does not encode latest confirmed commitment"
+	WarningStaleCommitment                  = "CRITICAL - Client commitment unchanged for too many attestation cycles"
 )
 
+// FatalError wraps errors that the attestation service cannot recover
+// from by re-initiating the attestation process, i.e. invalid configuration
+type FatalError struct {
+	Err error
+}
+
+// Implement error interface for FatalError
+func (e FatalError) Error() string {
+	return e.Err.Error()
+}
+
+// Check if an error is a FatalError
+func IsFatalError(err error) bool {
+	_, ok := err.(FatalError)
+	return ok
+}
+
 // waiting time schedules
 const (
 	// fixed waiting time between states
 	ATimeFixed = 5 * time.Second
 
-	// waiting time for sigs to arrive from multisig nodes
-	ATimeSigs = 1 * time.Minute
+	// waiting time for sigs to arrive from multisig nodes after sending tx pre-images
+	DefaultATimeSigs = 1 * time.Minute
 
 	// waiting time between attemps to check if an attestation has been confirmed
 	ATimeConfirmation = 15 * time.Minute
@@ -66,6 +91,9 @@ const (
 	// waiting time until we handle an attestation that has not been confirmed
 	// usually by increasing the fee of the previous transcation to speed up confirmation
 	DefaultATimeHandleUnconfirmed = 60 * time.Minute
+
+	// waiting time between checks of the paused flag while the service is paused
+	ATimePaused = 10 * time.Second
 )
 
 // AttestationService structure
@@ -95,15 +123,52 @@ type AttestService struct {
 	attestation *models.Attestation
 	errorState  error
 	isRegtest   bool
-}
 
-var (
+	// channel to surface fatal errors to the caller of Run
+	fatalErrors chan error
+
+	// paused flag set atomically - attestation state is kept while paused
+	paused int32
+
+	// timing schedules kept per service instance so that multiple
+	// services can run independently in the same process
 	atimeNewAttestation    time.Duration // delay between attestations - DEFAULTS to DefaultATimeNewAttestation
 	atimeHandleUnconfirmed time.Duration // delay until handling unconfirmed - DEFAULTS to DefaultATimeHandleUnconfirmed
+	atimeSigs              time.Duration // delay until collecting sigs - DEFAULTS to DefaultATimeSigs
+
+	// cron-like schedule of new attestations used instead of
+	// the new attestation delay, if set, and latest schedule time
+	schedule     *clock.Schedule
+	scheduleTime time.Time
 
 	attestDelay time.Duration // handle state delay
 	confirmTime time.Time     // handle confirmation timing
-)
+	feeBumps    int           // number of fee bumps of current attestation
+
+	// number of consecutive signing rounds with all signers unreachable
+	// and signer client with the local keys of the service, used to sign
+	// with local keys only if set by the signers unreachable policy
+	signersUnreachable int
+	localSigner        *AttestClient
+
+	// latest client commitment received and time it was first received
+	// used to detect a stuck commitment source that is not progressing
+	staleCommitment chainhash.Hash
+	staleSince      time.Time
+
+	// clock used for timing schedules - wall-clock unless set for testing
+	clock clock.Clock
+
+	// status snapshot updated after each attestation state
+	status *attestStatus
+
+	// encrypted journal of attestation addresses - nil if not configured
+	journal *AttestJournal
+
+	// staychain output amount of the latest confirmed attestation
+	// in satoshis, used to verify the next staychain unspent amount
+	attestedAmount int64
+}
 
 // NewAttestService returns a pointer to an AttestService instance
 // Initiates Attest Client and Attest Server
@@ -117,51 +182,147 @@ func NewAttestService(ctx context.Context, wg *sync.WaitGroup, server *server.Se
 	// initiate attestation client
 	attester := NewAttestClient(config)
 
+	// verify init transaction is in the wallet and attestations can continue
+	if initTxErr := attester.verifyInitTx(); initTxErr != nil {
+		log.Fatal(initTxErr)
+	}
+
+	// signer client with the local init key, if signing with local keys
+	// only is the signers unreachable policy - nil otherwise
+	var localSigner *AttestClient
+	if signersUnreachablePolicy(config.SignerConfig()) == confpkg.SignersUnreachableLocal {
+		if config.InitPK() != "" {
+			localSigner = newAttestClient(config, true, false)
+		} else {
+			log.Println(WarningLocalKeysMissing)
+		}
+	}
+
+	// verify genesis transaction confirmations and address - single confirmation in regtest
+	var genesisErr error
+	if config.Regtest() {
+		genesisErr = attester.VerifyGenesis(1)
+	} else {
+		genesisErr = attester.VerifyGenesis()
+	}
+	if genesisErr != nil {
+		log.Fatal(genesisErr)
+	}
+
 	// initiate timing schedules
-	atimeNewAttestation = DefaultATimeNewAttestation
+	atimeNewAttestation := DefaultATimeNewAttestation
 	if config.TimingConfig().NewAttestationMinutes > 0 {
 		atimeNewAttestation = time.Duration(config.TimingConfig().NewAttestationMinutes) * time.Minute
 	} else {
 		log.Printf("%s (%v)\n", WarningInvalidATimeNewAttestationArg, config.TimingConfig().NewAttestationMinutes)
 	}
 	log.Printf("Time new attestation set to: %v\n", atimeNewAttestation)
-	atimeHandleUnconfirmed = DefaultATimeHandleUnconfirmed
+	atimeHandleUnconfirmed := DefaultATimeHandleUnconfirmed
 	if config.TimingConfig().HandleUnconfirmedMinutes > 0 {
 		atimeHandleUnconfirmed = time.Duration(config.TimingConfig().HandleUnconfirmedMinutes) * time.Minute
 	} else {
 		log.Printf("%s (%v)\n", WarningInvalidATimeHandleUnconfirmedArg, config.TimingConfig().HandleUnconfirmedMinutes)
 	}
 	log.Printf("Time handle unconfirmed set to: %v\n", atimeHandleUnconfirmed)
+	atimeSigs := DefaultATimeSigs
+	if config.TimingConfig().SignaturesSeconds > 0 {
+		atimeSigs = time.Duration(config.TimingConfig().SignaturesSeconds) * time.Second
+	} else {
+		log.Printf("%s (%v)\n", WarningInvalidATimeSigsArg, config.TimingConfig().SignaturesSeconds)
+	}
+	log.Printf("Time signatures set to: %v\n", atimeSigs)
+	schedule, scheduleErr := parseAttestSchedule(config.TimingConfig(), time.Now())
+	if scheduleErr != nil {
+		log.Fatal(scheduleErr)
+	} else if schedule != nil {
+		log.Printf("Attestation schedule set to: %s (UTC)\n", config.TimingConfig().Schedule)
+	}
+
+	return &AttestService{ctx, wg, config, attester, server, signer, AStateInit, models.NewAttestationDefault(), nil, config.Regtest(), make(chan error, 1), 0,
+		atimeNewAttestation, atimeHandleUnconfirmed, atimeSigs, schedule, time.Time{}, 0, time.Time{}, 0, 0, localSigner, chainhash.Hash{}, time.Time{}, clock.NewClockReal(), newAttestStatus(),
+		newAttestJournalFromConfig(config.AttestationConfig()), 0}
+}
+
+// Errors returns a channel that receives the fatal error
+// that caused the attestation service to stop running
+func (s *AttestService) Errors() <-chan error {
+	return s.fatalErrors
+}
 
-	return &AttestService{ctx, wg, config, attester, server, signer, AStateInit, models.NewAttestationDefault(), nil, config.Regtest()}
+// Set clock used for timing schedules, e.g. a fake clock for testing
+func (s *AttestService) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // Run Attest Service
 func (s *AttestService) Run() {
 	defer s.wg.Done()
 
-	attestDelay = 10 * time.Second // add some delay for subscribers to have time to set up
+	s.attestDelay = 10 * time.Second // add some delay for subscribers to have time to set up
 
 	for { //Doing attestations using attestation client and waiting for transaction confirmation
-		timer := time.NewTimer(attestDelay)
 		select {
 		case <-s.ctx.Done():
 			log.Println("Shutting down Attestation Service...")
 			return
-		case <-timer.C:
+		case <-s.clock.After(s.attestDelay):
+			// idle while paused keeping current state and connections
+			if s.IsPaused() {
+				s.attestDelay = ATimePaused
+				continue
+			}
+
 			// do next attestation state
 			s.doAttestation()
 
+			// stop service on fatal error and notify caller
+			if s.state == AStateError && IsFatalError(s.errorState) {
+				log.Println("*AttestService* ATTESTATION SERVICE FATAL FAILURE")
+				log.Println(s.errorState)
+				s.fatalErrors <- s.errorState
+				return
+			}
+
 			// for testing - overwrite delay
 			if s.isRegtest {
-				attestDelay = 10 * time.Second
+				s.attestDelay = 10 * time.Second
 			}
 
-			log.Printf("********** sleeping for: %s ...\n", attestDelay.String())
+			log.Printf("********** sleeping for: %s ...\n", s.attestDelay.String())
 		}
 	}
 }
 
+// Pause attestations - the current attestation state is
+// kept and the service idles until it is resumed
+func (s *AttestService) Pause() {
+	if atomic.CompareAndSwapInt32(&s.paused, 0, 1) {
+		log.Println("*AttestService* ATTESTATION SERVICE PAUSED")
+	}
+}
+
+// Resume attestations from the state the service was paused at
+func (s *AttestService) Resume() {
+	if atomic.CompareAndSwapInt32(&s.paused, 1, 0) {
+		log.Println("*AttestService* ATTESTATION SERVICE RESUMED")
+	}
+}
+
+// Toggle paused state and return whether the service is now paused
+func (s *AttestService) TogglePause() bool {
+	if s.IsPaused() {
+		s.Resume()
+		return false
+	}
+	s.Pause()
+	return true
+}
+
+// Return whether the attestation service is paused
+func (s *AttestService) IsPaused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
 // AStateError
 // - Print error state and re-initiate attestation
 func (s *AttestService) doStateError() {
@@ -180,11 +341,18 @@ func (s *AttestService) stateInitUnconfirmed(unconfirmedTxid chainhash.Hash) {
 	}
 	log.Printf("********** found unconfirmed attestation: %s\n", unconfirmedTxid.String())
 	s.attestation = models.NewAttestation(unconfirmedTxid, &commitment) // initialise attestation
-	rawTx, _ := s.config.MainClient().GetRawTransaction(&unconfirmedTxid)
+	rawTx, _ := s.attester.MainClient.GetRawTransaction(&unconfirmedTxid)
 	s.attestation.Tx = *rawTx.MsgTx() // set msgTx
 
+	// the unconfirmed attestation found is the one in-flight
+	s.attester.releaseUnspents()
+	reserveErr := s.attester.reserveUnspents(s.attestation.CommitmentHash(), &s.attestation.Tx)
+	if s.setFailure(reserveErr) {
+		return // will rebound to init
+	}
+
 	s.state = AStateAwaitConfirmation // update attestation state
-	confirmTime = time.Now()
+	s.confirmTime = s.clock.Now()
 }
 
 // part of AStateInit
@@ -201,14 +369,31 @@ func (s *AttestService) stateInitUnspent(unspent btcjson.ListUnspentResult) {
 		s.attestation = models.NewAttestation(*unspentTxid, &commitment)
 		// update server with latest confirmed attestation
 		s.attestation.Confirmed = true
-		rawTx, _ := s.config.MainClient().GetRawTransaction(unspentTxid)
-		walletTx, _ := s.config.MainClient().GetTransaction(unspentTxid)
+		rawTx, rawTxErr := s.attester.MainClient.GetRawTransaction(unspentTxid)
+		if s.setFailure(rawTxErr) {
+			return // will rebound to init
+		}
+		walletTx, walletTxErr := s.attester.getTransaction(unspentTxid)
+		if s.setFailure(walletTxErr) {
+			return // will rebound to init
+		}
 		s.attestation.Tx = *rawTx.MsgTx()  // set msgTx
 		s.attestation.UpdateInfo(walletTx) // set tx info
+		if s.attestedAmount <= 0 {
+			s.attestedAmount = s.attestation.Info.Amount // previous amount unknown on start
+		}
 
-		errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
-		if s.setFailure(errUpdate) {
+		// skip updating server if the attestation is already recorded as confirmed
+		recorded, recordedErr := s.isTipRecorded(unspent, commitment.GetCommitmentHash())
+		if s.setFailure(recordedErr) {
 			return // will rebound to init
+		} else if recorded {
+			log.Printf("********** attestation already recorded as confirmed - resuming from: %s\n", unspentTxid.String())
+		} else {
+			errUpdate := s.server.UpdateLatestAttestation(*s.attestation)
+			if s.setFailure(errUpdate) {
+				return // will rebound to init
+			}
 		}
 
 		s.attester.Fees.ResetFee(s.isRegtest) // reset client fees
@@ -222,6 +407,34 @@ func (s *AttestService) stateInitUnspent(unspent btcjson.ListUnspentResult) {
 	s.state = AStateNextCommitment // update attestation state
 }
 
+// part of AStateInit
+// reconcile the confirmed staychain tip with the latest confirmed attestation
+// in the server, e.g. when the attestation confirmed while the service was down
+// and was already recorded as confirmed before. The tip is recorded if the
+// commitment encoded in the tip address is the latest confirmed commitment
+func (s *AttestService) isTipRecorded(unspent btcjson.ListUnspentResult, commitmentHash chainhash.Hash) (bool, error) {
+	latestHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
+	if latestErr != nil {
+		return false, latestErr
+	} else if latestHash != commitmentHash {
+		return false, nil
+	}
+	// compare scripts as the unspent address is not set in scan utxo set mode
+	script, scriptErr := hex.DecodeString(unspent.ScriptPubKey)
+	if scriptErr != nil {
+		return false, scriptErr
+	}
+	_, paysToTip, addrErr := s.attester.paysToAttestationAddr(wire.NewTxOut(0, script), commitmentHash)
+	if addrErr != nil {
+		return false, addrErr
+	}
+	if !paysToTip {
+		log.Printf("********** %s (%s)\n", WarningTipCommitmentMismatch, unspent.TxID)
+		return false, nil
+	}
+	return true, nil
+}
+
 // part of AStateInit
 // handles wallet failure when neither unconfirmed or unspent is found
 // above case should happen very rarely but when it does, import
@@ -242,7 +455,7 @@ func (s *AttestService) stateInitWalletFailure() {
 		return // will rebound to init
 	}
 	log.Printf("********** importing latest confirmed addr: %s ...\n", paytoaddr.String())
-	importErr := s.attester.ImportAttestationAddr(paytoaddr)
+	importErr := s.attester.ImportAttestationAddr(paytoaddr, lastCommitmentHash)
 	if s.setFailure(importErr) {
 		return // will rebound to init
 	}
@@ -259,7 +472,7 @@ func (s *AttestService) stateInitWalletFailure() {
 		return // will rebound to init
 	}
 	log.Printf("********** importing latest unconfirmed addr: %s ...\n", paytoaddr.String())
-	importErr = s.attester.ImportAttestationAddr(paytoaddr)
+	importErr = s.attester.ImportAttestationAddr(paytoaddr, lastCommitmentHash)
 	if s.setFailure(importErr) {
 		return // will rebound to init
 	}
@@ -275,37 +488,56 @@ func (s *AttestService) stateInitWalletFailure() {
 func (s *AttestService) doStateInit() {
 	log.Println("*AttestService* INITIATING ATTESTATION PROCESS")
 
-	// find the state of the attestation
-	unconfirmed, unconfirmedTxid, unconfirmedErr := s.attester.getUnconfirmedTx()
-	if s.setFailure(unconfirmedErr) {
+	// set latest checkpoint as subchain root to avoid walking back to genesis
+	checkpoint, checkpointErr := s.server.GetCheckpoint()
+	if s.setFailure(checkpointErr) {
 		return // will rebound to init
-	} else if unconfirmed { // check mempool for unconfirmed - added check in case something gets rejected
+	}
+	s.attester.SetCheckpoint(checkpoint)
+
+	// find the state of the attestation from the reconciled staychain tip
+	tip, tipErr := s.attester.determineCurrentTip()
+	if s.setFailure(tipErr) {
+		return // will rebound to init
+	} else if tip.Unconfirmed { // check mempool for unconfirmed - added check in case something gets rejected
 		// handle init unconfirmed case
-		s.stateInitUnconfirmed(unconfirmedTxid)
+		s.stateInitUnconfirmed(tip.Txid)
+	} else if tip.Found {
+		// no attestation in-flight as the staychain tip is unspent
+		s.attester.releaseUnspents()
+
+		// handle init unspent case
+		s.stateInitUnspent(tip.Unspent)
 	} else {
-		success, unspent, unspentErr := s.attester.findLastUnspent()
-		if s.setFailure(unspentErr) {
-			return // will rebound to init
-		} else if success {
-			// handle init unspent case
-			s.stateInitUnspent(unspent)
-		} else {
-			// handle wallet failure case
-			s.stateInitWalletFailure()
-		}
+		// handle wallet failure case
+		s.stateInitWalletFailure()
 	}
 }
 
 // AStateNextCommitment
+// - Update operator commitment in server, if set
 // - Get latest commitment from server
+// - Check if commitment source has stopped progressing
 // - Check if commitment has already been attested
 // - Send commitment to client signers
 // - Initialise new attestation
 func (s *AttestService) doStateNextCommitment() {
 	log.Println("*AttestService* NEW ATTESTATION COMMITMENT")
 
+	// inject operator commitment at the reserved position, if set
+	operatorErr := s.server.UpdateOperatorCommitment()
+	if s.setFailure(operatorErr) {
+		return // will rebound to init
+	}
+
 	// get latest commitment hash from server
+	// no client commitments are handled as a zero commitment if skip or sentinel is set
+	zeroCommitment := s.config.AttestationConfig().ZeroCommitment
 	latestCommitment, latestErr := s.server.GetClientCommitment()
+	if latestErr != nil && latestErr.Error() == models.ErrorCommitmentListEmpty &&
+		(zeroCommitment == confpkg.ZeroCommitmentSkip || zeroCommitment == confpkg.ZeroCommitmentSentinel) {
+		latestErr = nil
+	}
 	if s.setFailure(latestErr) {
 		return // will rebound to init
 	}
@@ -313,50 +545,134 @@ func (s *AttestService) doStateNextCommitment() {
 
 	// check if commitment has already been attested
 	log.Printf("********** received commitment hash: %s\n", latestCommitmentHash.String())
-	if latestCommitmentHash == s.attestation.CommitmentHash() {
+
+	// a commitment without any client commitment is ambiguous - skip or replace by sentinel if set
+	if latestCommitment.IsZero() {
+		switch zeroCommitment {
+		case confpkg.ZeroCommitmentSkip:
+			log.Printf("********** Skipping attestation - Client commitment zero")
+			s.attestDelay = s.newAttestationDelay(0) // sleep
+			s.updateSchedule(s.attestDelay)          // publish next attestation time
+			return                                   // will remain at the same state
+		case confpkg.ZeroCommitmentSentinel:
+			latestCommitment = *models.NewZeroCommitmentSentinel()
+			latestCommitmentHash = latestCommitment.GetCommitmentHash()
+			log.Printf("********** Client commitment zero - attesting sentinel: %s\n", latestCommitmentHash.String())
+		}
+	}
+
+	s.updatePendingCommitments(latestCommitment) // update pending client commitments
+
+	// pause attesting while the commitment source is not progressing
+	if s.isCommitmentStale(latestCommitmentHash) {
+		log.Printf("********** Skipping attestation - Client commitment stale")
+		s.attestDelay = s.newAttestationDelay(0) // sleep
+		s.updateSchedule(s.attestDelay)          // publish next attestation time
+		return                                   // will remain at the same state
+	}
+
+	// check if commitment has already been attested by the current attestation
+	// and if set also by the latest attestation recorded in the server
+	duplicate := latestCommitmentHash == s.attestation.CommitmentHash()
+	if !duplicate && s.config.AttestationConfig().SkipDuplicateCommitment {
+		latestAttestedHash, attested, latestAttestedErr := s.server.LookupLatestAttestationCommitmentHash()
+		if s.setFailure(latestAttestedErr) {
+			return // will rebound to init
+		}
+		duplicate = attested && latestCommitmentHash == latestAttestedHash
+	}
+	if duplicate {
 		log.Printf("********** Skipping attestation - Client commitment already attested")
-		attestDelay = atimeNewAttestation // sleep
-		return                            // will remain at the same state
+		s.attestDelay = s.newAttestationDelay(0) // sleep
+		s.updateSchedule(s.attestDelay)          // publish next attestation time
+		return                                   // will remain at the same state
+	}
+
+	// freeze the client commitments that produced the commitment for auditing
+	snapshotErr := s.server.SaveCommitmentSnapshot(latestCommitment)
+	if s.setFailure(snapshotErr) {
+		return // will rebound to init
 	}
 
 	// initialise new attestation with commitment
 	s.attestation = models.NewAttestationDefault()
 	s.attestation.SetCommitment(&latestCommitment)
 
+	// commitments from now on make the following attestation at the earliest
+	s.updateSchedule(s.nextAttestationDelay())
+
 	s.state = AStateNewAttestation // update attestation state
 }
 
+// Check whether the client commitment has not changed for the configured number
+// of attestation cycles, measured in new attestation intervals since the commitment
+// was first received, so that retries after failures do not count as cycles
+// A stale commitment is alerted and not attested unless the override flag is set
+func (s *AttestService) isCommitmentStale(commitmentHash chainhash.Hash) bool {
+	now := s.clock.Now()
+	if commitmentHash != s.staleCommitment || s.staleSince.IsZero() {
+		s.staleCommitment = commitmentHash
+		s.staleSince = now
+		return false
+	}
+
+	staleCycles := s.config.AttestationConfig().StaleCommitmentCycles
+	if staleCycles <= 0 {
+		return false
+	}
+	unchanged := now.Sub(s.staleSince)
+	if unchanged < time.Duration(staleCycles)*s.atimeNewAttestation {
+		return false
+	}
+
+	log.Printf("*AttestService* %s (%s unchanged for %s)\n", WarningStaleCommitment,
+		commitmentHash.String(), unchanged.String())
+	if s.config.AttestationConfig().AttestStaleCommitment {
+		log.Println("*AttestService* Stale commitment override set - attesting")
+		return false
+	}
+	return true
+}
+
 // AStateNewAttestation
 // - Generate new pay to address for attestation transaction using client commitment
 // - Create new unsigned transaction using the last unspent
 // - If a topup unspent exists, add this to the new attestation
+// - Reserve the unspents spent until the attestation confirms
 // - Publish unsigned transaction to signer clients
-// - add ATimeSigs waiting time
+// - add atimeSigs waiting time
 func (s *AttestService) doStateNewAttestation() {
 	log.Println("*AttestService* NEW ATTESTATION")
 
+	s.feeBumps = 0 // reset fee bumps for new attestation
+
 	// Get key and address for next attestation using client commitment
+	// failure to derive keys is due to invalid key or script config
 	key, keyErr := s.attester.GetNextAttestationKey(s.attestation.CommitmentHash())
-	if s.setFailure(keyErr) {
-		return // will rebound to init
+	if s.setFatal(keyErr) {
+		return // will stop service
 	}
-	paytoaddr, _, addrErr := s.attester.GetNextAttestationAddr(key, s.attestation.CommitmentHash())
-	if s.setFailure(addrErr) {
-		return // will rebound to init
+	paytoaddr, script, addrErr := s.attester.GetNextAttestationAddr(key, s.attestation.CommitmentHash())
+	if s.setFatal(addrErr) {
+		return // will stop service
 	}
+	s.recordJournal(paytoaddr.String(), script)
 	log.Printf("********** importing pay-to addr: %s ...\n", paytoaddr.String())
-	importErr := s.attester.ImportAttestationAddr(paytoaddr, false) // no rescan needed here
+	importErr := s.attester.ImportAttestationAddr(paytoaddr, s.attestation.CommitmentHash(), false) // no rescan needed here
 	if s.setFailure(importErr) {
 		return // will rebound to init
 	}
 
-	// Generate new unsigned attestation transaction from last unspent
-	success, unspent, unspentErr := s.attester.findLastUnspent()
+	// Generate new unsigned attestation transaction from the subchain unspents selected
+	success, unspentList, unspentErr := s.attester.findNextUnspents()
 	if s.setFailure(unspentErr) {
 		return // will rebound to init
 	} else if success {
-		var unspentList []btcjson.ListUnspentResult
-		unspentList = append(unspentList, unspent)
+
+		// verify staychain unspent amount against the previous attestation
+		if s.isUnspentAmountDrifted(unspentList[0]) {
+			return // will rebound to init
+		}
 
 		// search for topup unspent and add if it exists
 		topupFound, topupUnspent, topupUnspentErr := s.attester.findTopupUnspent()
@@ -368,11 +684,17 @@ func (s *AttestService) doStateNewAttestation() {
 		}
 
 		// create attestation transaction for the list of unspents paying to addr generated
-		newTx, createErr := s.attester.createAttestation(paytoaddr, unspentList)
+		newTx, createErr := s.attester.createAttestation(paytoaddr, s.attestation.CommitmentHash(), unspentList)
 		if s.setFailure(createErr) {
 			return // will rebound to init
 		}
 
+		// reserve unspents until the attestation confirms
+		reserveErr := s.attester.reserveUnspents(s.attestation.CommitmentHash(), newTx)
+		if s.setFailure(reserveErr) {
+			return // will rebound to init
+		}
+
 		s.attestation.Tx = *newTx
 		log.Printf("********** pre-sign txid: %s\n", s.attestation.Tx.TxHash().String())
 
@@ -388,16 +710,17 @@ func (s *AttestService) doStateNewAttestation() {
 			return // will rebound to init
 		}
 		// get pre image bytes
-		var txPreImageBytes [][]byte
-		for _, txPreImage := range txPreImages {
-			var txBytesBuffer bytes.Buffer
-			txPreImage.Serialize(&txBytesBuffer)
-			txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+		txPreImageBytes, preImageBytesErr := GetTxPreImageBytes(txPreImages, s.config.SignerConfig().CompactPreImages)
+		if s.setFailure(preImageBytesErr) {
+			return // will rebound to init
 		}
+		// send commitment of the signing round along the pre-images
+		// so that lagging signers update the tweak before signing
+		s.signer.SendConfirmedHash((&lastCommitmentHash).CloneBytes())
 		s.signer.SendTxPreImages(txPreImageBytes)
 
 		s.state = AStateSignAttestation // update attestation state
-		attestDelay = ATimeSigs         // add sigs waiting time
+		s.attestDelay = s.atimeSigs     // add sigs waiting time
 	} else {
 		s.setFailure(errors.New(ErroUnspentNotFound))
 		return // will rebound to init
@@ -416,6 +739,10 @@ func (s *AttestService) doStateSignAttestation() {
 		log.Printf("********** received %d signatures for input %d \n",
 			len(sigs[sigForInput]), sigForInput)
 	}
+	sigs, retry := s.handleSignersUnreachable(sigs)
+	if retry {
+		return // will collect sigs again
+	}
 
 	// get last confirmed commitment from server
 	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
@@ -457,8 +784,19 @@ func (s *AttestService) doStatePreSendStore() {
 func (s *AttestService) doStateSendAttestation() {
 	log.Println("*AttestService* SEND ATTESTATION")
 
+	// send retries are stopped on service cancellation
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background() // service not run through a context
+	}
+
 	// sign attestation with combined signatures and send through client to network
-	txid, attestationErr := s.attester.sendAttestation(&s.attestation.Tx)
+	txid, attestationErr := s.attester.sendAttestation(ctx, &s.attestation.Tx)
+	if IsFeeRejectedError(attestationErr) {
+		log.Printf("********** %v\n", attestationErr)
+		s.state = AStateHandleUnconfirmed // bump fees and re-sign attestation
+		return
+	}
 	if s.setFailure(attestationErr) {
 		return // will rebound to init
 	}
@@ -466,8 +804,8 @@ func (s *AttestService) doStateSendAttestation() {
 	log.Printf("********** attestation transaction committed with txid: (%s)\n", txid)
 
 	s.state = AStateAwaitConfirmation // update attestation state
-	attestDelay = ATimeConfirmation   // add confirmation waiting time
-	confirmTime = time.Now()          // set time for awaiting confirmation
+	s.attestDelay = ATimeConfirmation // add confirmation waiting time
+	s.confirmTime = s.clock.Now()     // set time for awaiting confirmation
 }
 
 // AStateAwaitConfirmation
@@ -479,13 +817,22 @@ func (s *AttestService) doStateAwaitConfirmation() {
 	log.Printf("*AttestService* AWAITING CONFIRMATION \ntxid: (%s)\ncommitment: (%s)\n", s.attestation.Txid.String(), s.attestation.CommitmentHash().String())
 
 	// if attestation has been unconfirmed for too long
-	// set to handle unconfirmed state
-	if time.Since(confirmTime) > atimeHandleUnconfirmed {
-		s.state = AStateHandleUnconfirmed
-		return
+	// set to handle unconfirmed state unless max fee bumps reached
+	if s.clock.Now().Sub(s.confirmTime) > s.atimeHandleUnconfirmed {
+		maxFeeBumps := s.config.AttestationConfig().MaxFeeBumps
+		if maxFeeBumps <= 0 || s.feeBumps < maxFeeBumps {
+			s.state = AStateHandleUnconfirmed
+			return
+		}
+		log.Printf("*AttestService* %s (%d) txid: (%s)\n", WarningMaxFeeBumpsReached, s.feeBumps, s.attestation.Txid.String())
+		if s.config.AttestationConfig().HaltOnMaxFeeBumps {
+			s.setFatal(errors.New(fmt.Sprintf("%s (%d)", ErrorMaxFeeBumps, s.feeBumps)))
+			return // will stop service
+		}
+		// stop bumping and keep awaiting confirmation
 	}
 
-	newTx, err := s.config.MainClient().GetTransaction(&s.attestation.Txid)
+	newTx, err := s.attester.getTransaction(&s.attestation.Txid)
 	if s.setFailure(err) {
 		return // will rebound to init
 	}
@@ -500,17 +847,90 @@ func (s *AttestService) doStateAwaitConfirmation() {
 		if s.setFailure(errUpdate) {
 			return // will rebound to init
 		}
+		s.attestedAmount = s.attestation.Info.Amount
+
+		s.attester.releaseUnspents() // attestation no longer in-flight
 
 		s.attester.Fees.ResetFee(s.isRegtest) // reset client fees
+		s.feeBumps = 0                        // reset fee bumps
+
+		s.updateCheckpoint()                 // record new checkpoint if enabled
+		s.updateReceipt(newTx.Confirmations) // generate signed receipt if enabled
 
 		confirmedHash := s.attestation.CommitmentHash()
 		s.signer.SendConfirmedHash((&confirmedHash).CloneBytes()) // update clients
 
-		s.state = AStateNextCommitment                              // update attestation state
-		attestDelay = atimeNewAttestation - time.Since(confirmTime) // add new attestation waiting time - subtract waiting time
+		s.state = AStateNextCommitment                                          // update attestation state
+		s.attestDelay = s.newAttestationDelay(s.clock.Now().Sub(s.confirmTime)) // add new attestation waiting time - subtract waiting time
+		s.updateSchedule(s.attestDelay)                                         // publish next attestation time
 	} else {
-		attestDelay = ATimeConfirmation // add confirmation waiting time
+		s.attestDelay = ATimeConfirmation // add confirmation waiting time
+	}
+}
+
+// part of AStateAwaitConfirmation
+// record a new checkpoint if the confirmed attestation is checkpoint depth
+// attestations ahead of the latest checkpoint or the genesis transaction
+// failures are logged as the checkpoint is only an optimisation
+func (s *AttestService) updateCheckpoint() {
+	depth := s.config.AttestationConfig().CheckpointDepth
+	if depth <= 0 {
+		return
 	}
+	found, checkpoint, checkpointErr := s.attester.findCheckpoint(s.attestation.Txid, depth)
+	if checkpointErr != nil {
+		log.Printf("********** checkpoint search failed: %v\n", checkpointErr)
+		return
+	} else if !found {
+		return
+	}
+	if saveErr := s.server.SaveCheckpoint(checkpoint); saveErr != nil {
+		log.Printf("********** checkpoint save failed: %v\n", saveErr)
+		return
+	}
+	s.attester.SetCheckpoint(checkpoint)
+	log.Printf("********** recorded new checkpoint: %s\n", checkpoint.String())
+}
+
+// part of AStateAwaitConfirmation
+// generate a signed receipt for the confirmed attestation if a receipt key is set
+// the block height is derived from the attestation confirmations and the chain tip
+// failures are logged as receipts do not affect the attestation process
+func (s *AttestService) updateReceipt(confirmations int64) {
+	if s.config.ServerConfig().ReceiptKey == "" {
+		return
+	}
+	height, heightErr := s.attester.MainClient.GetBlockCount()
+	if heightErr != nil {
+		log.Printf("********** receipt generation failed: %v\n", heightErr)
+		return
+	}
+	receipt, receiptErr := s.server.GenerateReceipt(*s.attestation, height-confirmations+1)
+	if receiptErr != nil {
+		log.Printf("********** receipt generation failed: %v\n", receiptErr)
+		return
+	}
+	log.Printf("********** generated receipt for txid: %s height: %d\n", receipt.Txid.String(), receipt.BlockHeight)
+}
+
+// publish the schedule of the next attestation, due after the delay provided,
+// with the acceptance cutoff of client commitments for the next attestation
+// failures are logged as the schedule is only informative for clients
+func (s *AttestService) updateSchedule(delay time.Duration) {
+	if delay < 0 {
+		delay = 0
+	}
+	var window time.Duration
+	if s.config.TimingConfig().AcceptanceWindowSeconds > 0 {
+		window = time.Duration(s.config.TimingConfig().AcceptanceWindowSeconds) * time.Second
+	}
+	now := s.clock.Now()
+	schedule := models.NewAttestationSchedule(now.Add(delay), window, now)
+	if saveErr := s.server.SaveAttestationSchedule(schedule); saveErr != nil {
+		log.Printf("********** schedule update failed: %v\n", saveErr)
+		return
+	}
+	log.Printf("********** next attestation at: %s\n", schedule.NextAttestation.Format(time.RFC3339))
 }
 
 // AStateHandleUnconfirmed
@@ -526,7 +946,14 @@ func (s *AttestService) doStateHandleUnconfirmed() {
 		return // will rebound to init
 	}
 
+	// replacement must spend the unspents reserved by the in-flight attestation
+	reserveErr := s.attester.reserveUnspents(s.attestation.CommitmentHash(), currentTx)
+	if s.setFailure(reserveErr) {
+		return // will rebound to init
+	}
+
 	s.attestation.Tx = *currentTx
+	s.feeBumps++ // count fee bump of current attestation
 	log.Printf("********** new pre-sign txid: %s\n", s.attestation.Tx.TxHash().String())
 
 	// get last confirmed commitment from server
@@ -541,16 +968,15 @@ func (s *AttestService) doStateHandleUnconfirmed() {
 		return // will rebound to init
 	}
 	// get pre image bytes
-	var txPreImageBytes [][]byte
-	for _, txPreImage := range txPreImages {
-		var txBytesBuffer bytes.Buffer
-		txPreImage.Serialize(&txBytesBuffer)
-		txPreImageBytes = append(txPreImageBytes, txBytesBuffer.Bytes())
+	txPreImageBytes, preImageBytesErr := GetTxPreImageBytes(txPreImages, s.config.SignerConfig().CompactPreImages)
+	if s.setFailure(preImageBytesErr) {
+		return // will rebound to init
 	}
+	s.signer.SendConfirmedHash((&lastCommitmentHash).CloneBytes())
 	s.signer.SendTxPreImages(txPreImageBytes)
 
 	s.state = AStateSignAttestation // update attestation state
-	attestDelay = ATimeSigs         // add sigs waiting time
+	s.attestDelay = s.atimeSigs     // add sigs waiting time
 }
 
 //Main attestation service method - cycles through AttestationStates
@@ -558,7 +984,7 @@ func (s *AttestService) doAttestation() {
 
 	// fixed waiting time between states specific states might
 	// re-write this to set specific waiting times
-	attestDelay = ATimeFixed
+	s.attestDelay = ATimeFixed
 
 	switch s.state {
 
@@ -589,6 +1015,8 @@ func (s *AttestService) doAttestation() {
 	case AStateHandleUnconfirmed:
 		s.doStateHandleUnconfirmed()
 	}
+
+	s.updateStatus() // update status snapshot
 }
 
 // Check if there is an error and set error state
@@ -600,3 +1028,12 @@ func (s *AttestService) setFailure(err error) bool {
 	}
 	return false
 }
+
+// Check if there is an unrecoverable error and set error state
+// Run stops the service when a fatal error state is set
+func (s *AttestService) setFatal(err error) bool {
+	if err != nil {
+		return s.setFailure(FatalError{err})
+	}
+	return false
+}
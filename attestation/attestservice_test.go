@@ -5,18 +5,24 @@
 package attestation
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	"mainstay/clock"
 	confpkg "mainstay/config"
 	"mainstay/models"
 	"mainstay/server"
 	"mainstay/test"
 
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -44,7 +50,7 @@ func verifyStateInitToNextCommitment(t *testing.T, attestService *AttestService)
 	assert.Equal(t, chainhash.Hash{}, attestService.attestation.Txid)
 	assert.Equal(t, false, attestService.attestation.Confirmed)
 	assert.Equal(t, models.AttestationInfo{}, attestService.attestation.Info)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStateInit to AStateAwaitConfirmation
@@ -65,7 +71,7 @@ func verifyStateNextCommitmentToNewAttestation(t *testing.T, attestService *Atte
 	attestService.doAttestation()
 	assert.Equal(t, AStateNewAttestation, attestService.state)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	return latestCommitment
 }
@@ -78,7 +84,7 @@ func verifyStateNewAttestationToSignAttestation(t *testing.T, attestService *Att
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxIn))
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
 }
 
 // verify AStateSignAttestation to AStatePreSendStore
@@ -86,21 +92,21 @@ func verifyStateSignAttestationToPreSendStore(t *testing.T, attestService *Attes
 	attestService.doAttestation()
 	assert.Equal(t, AStatePreSendStore, attestService.state)
 	assert.Equal(t, true, len(attestService.attestation.Tx.TxIn[0].SignatureScript) > 0)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStatePreSendStore to AStateSendAttestation
 func verifyStatePreSendStoreToSendAttestation(t *testing.T, attestService *AttestService) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateSendAttestation, attestService.state)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 }
 
 // verify AStateSendAttestation to AStateAwaitConfirmation
 func verifyStateSendAttestationToAwaitConfirmation(t *testing.T, attestService *AttestService) chainhash.Hash {
 	attestService.doAttestation()
 	assert.Equal(t, AStateAwaitConfirmation, attestService.state)
-	assert.Equal(t, ATimeConfirmation, attestDelay)
+	assert.Equal(t, ATimeConfirmation, attestService.attestDelay)
 	return attestService.attestation.Txid
 }
 
@@ -108,7 +114,7 @@ func verifyStateSendAttestationToAwaitConfirmation(t *testing.T, attestService *
 func verifyStateAwaitConfirmationToAwaitConfirmation(t *testing.T, attestService *AttestService) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateAwaitConfirmation, attestService.state)
-	assert.Equal(t, ATimeConfirmation, attestDelay)
+	assert.Equal(t, ATimeConfirmation, attestService.attestDelay)
 }
 
 // verify AStateAwaitConfirmation to AStateNextCommitment
@@ -121,8 +127,8 @@ func verifyStateAwaitConfirmationToNextCommitment(t *testing.T, attestService *A
 	assert.Equal(t, AStateNextCommitment, attestService.state)
 	assert.Equal(t, true, attestService.attestation.Confirmed)
 	assert.Equal(t, txid, attestService.attestation.Txid)
-	assert.Equal(t, true, attestDelay < timeNew)
-	assert.Equal(t, true, attestDelay > (timeNew-time.Since(confirmTime)))
+	assert.Equal(t, true, attestService.attestDelay < timeNew)
+	assert.Equal(t, true, attestService.attestDelay > (timeNew-time.Since(attestService.confirmTime)))
 	assert.Equal(t, models.AttestationInfo{
 		Txid:      txid.String(),
 		Blockhash: walletTx.BlockHash,
@@ -144,7 +150,7 @@ func verifyStateHandleUnconfirmedToSignAttestation(t *testing.T, attestService *
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxIn))
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee+attestService.attester.Fees.feeIncrement,
 		attestService.attester.Fees.GetFee())
 }
@@ -164,7 +170,7 @@ func TestAttestService_Multi(t *testing.T) {
 	// randomly test with invalid config here
 	// timing config no effect on server
 	for _, config := range configs {
-		timingConfig := confpkg.TimingConfig{-1, -1}
+		timingConfig := confpkg.TimingConfig{-1, -1, -1, -1, "", ""}
 		config.SetTimingConfig(timingConfig)
 	}
 
@@ -184,7 +190,7 @@ func TestAttestService_Multi(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateError, attestService.state)
 	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), attestService.errorState)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	// Test AStateError -> AStateInit -> AStateNextCommitment again
 	attestService.doAttestation()
@@ -204,7 +210,7 @@ func TestAttestService_Multi(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateError, attestService.state)
 	assert.Equal(t, errors.New(ErrorSigsMissingForVin), attestService.errorState)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	// set signer to the correct signerMulti that does multiple signings
 	// and observe that attestation creation and signing now succeeds
@@ -241,7 +247,7 @@ func TestAttestService_Multi(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateNextCommitment, attestService.state)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
-	assert.Equal(t, DefaultATimeNewAttestation, attestDelay)
+	assert.Equal(t, DefaultATimeNewAttestation, attestService.attestDelay)
 
 	// Test AStateNextCommitment -> AStateNewAttestation
 	// stuck in next commitment
@@ -273,7 +279,7 @@ func TestAttestService_Regular(t *testing.T) {
 
 	// randomly test with invalid config here
 	// timing config no effect on server
-	timingConfig := confpkg.TimingConfig{-1, -1}
+	timingConfig := confpkg.TimingConfig{-1, -1, -1, -1, "", ""}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := server.NewDbFake()
@@ -291,7 +297,7 @@ func TestAttestService_Regular(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateError, attestService.state)
 	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), attestService.errorState)
-	assert.Equal(t, ATimeFixed, attestDelay)
+	assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 	// Test AStateError -> AStateInit -> AStateNextCommitment again
 	attestService.doAttestation()
@@ -323,7 +329,7 @@ func TestAttestService_Regular(t *testing.T) {
 	attestService.doAttestation()
 	assert.Equal(t, AStateNextCommitment, attestService.state)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
-	assert.Equal(t, DefaultATimeNewAttestation, attestDelay)
+	assert.Equal(t, DefaultATimeNewAttestation, attestService.attestDelay)
 
 	// Test AStateNextCommitment -> AStateNewAttestation
 	// stuck in next commitment
@@ -342,6 +348,25 @@ func TestAttestService_Regular(t *testing.T) {
 	// Test AStateAwaitConfirmation -> AStateNextCommitment
 	config.MainClient().Generate(1)
 	verifyStateAwaitConfirmationToNextCommitment(t, attestService, config, txid, DefaultATimeNewAttestation)
+
+	// Test confirmed tip reconciliation with latest confirmed attestation
+	found, unspent, unspentErr := attestService.attester.findLastUnspent()
+	assert.Equal(t, nil, unspentErr)
+	assert.Equal(t, true, found)
+	recorded, recordedErr := attestService.isTipRecorded(unspent, latestCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, recordedErr)
+	assert.Equal(t, true, recorded)
+	recorded, recordedErr = attestService.isTipRecorded(unspent, *hashX)
+	assert.Equal(t, nil, recordedErr)
+	assert.Equal(t, false, recorded)
+
+	// Test restart AStateInit -> AStateNextCommitment resuming from recorded tip
+	attestService = NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	attestService.doAttestation()
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
+	assert.Equal(t, txid, attestService.attestation.Txid)
+	assert.Equal(t, true, attestService.attestation.Confirmed)
 }
 
 // Test Attest Service when Attestation remains unconfirmed
@@ -354,12 +379,14 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	// randomly test custom config here
 	customAtimeNewAttestation := 5
 	customAtimeHandleUnconfirmed := 10
-	timingConfig := confpkg.TimingConfig{customAtimeNewAttestation, customAtimeHandleUnconfirmed}
+	customAtimeSigs := 30
+	timingConfig := confpkg.TimingConfig{customAtimeNewAttestation, customAtimeHandleUnconfirmed, customAtimeSigs, -1, "", ""}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := server.NewDbFake()
 	server := server.NewServer(dbFake)
 	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	assert.Equal(t, time.Duration(customAtimeSigs)*time.Second, attestService.atimeSigs)
 
 	attestService.attester.Fees.ResetFee(true)
 
@@ -384,7 +411,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 	// set confirm time back to test what happens in handle unconfirmed case
-	confirmTime = confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
+	attestService.confirmTime = attestService.confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
 
 	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -412,7 +439,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 
 	// add also unspent this time
 	_ = createTopupUnspent(t, test.Config)
-	attestService.attester.MainClient.Generate(1)
+	test.Config.MainClient().Generate(1)
 
 	// Test AStateNewAttestation -> AStateSignAttestation
 	attestService.doAttestation()
@@ -422,7 +449,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee, attestService.attester.Fees.GetFee())
 	// Test AStateSignAttestation -> AStatePreSendStore
 	verifyStateSignAttestationToPreSendStore(t, attestService)
@@ -432,7 +459,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	txid = verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 	// set confirm time back to test what happens in handle unconfirmed case
-	confirmTime = confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
+	attestService.confirmTime = attestService.confirmTime.Add(-time.Duration(customAtimeHandleUnconfirmed) * time.Minute)
 
 	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -444,7 +471,7 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
 	assert.Equal(t, attestService.attester.Fees.minFee+attestService.attester.Fees.feeIncrement,
 		attestService.attester.Fees.GetFee())
 
@@ -461,6 +488,232 @@ func TestAttestService_Unconfirmed(t *testing.T) {
 	assert.Equal(t, attestService.attester.Fees.minFee, attestService.attester.Fees.GetFee())
 }
 
+// Test Attest Service when max fee bumps are reached
+func TestAttestService_MaxFeeBumps(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	// allow a single fee bump
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, 1, false, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1})
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+
+	attestService.attester.Fees.ResetFee(true)
+
+	// Test initial state of attest service
+	verifyStateInit(t, attestService)
+	// Test AStateInit -> AStateNextCommitment
+	verifyStateInitToNextCommitment(t, attestService)
+
+	// Test AStateNextCommitment -> AStateNewAttestation
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	_ = verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// Test AStateNewAttestation -> AStateSignAttestation
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+	assert.Equal(t, 0, attestService.feeBumps)
+	// Test AStateSignAttestation -> AStatePreSendStore
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	// Test AStatePreSendStore -> AStateSendAttestation
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	// Test AStateSendAttestation -> AStateAwaitConfirmation
+	_ = verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+
+	// set confirm time back to test what happens in handle unconfirmed case
+	attestService.confirmTime = attestService.confirmTime.Add(-attestService.atimeHandleUnconfirmed)
+
+	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
+	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
+	// Test AStateHandleUnconfirmed -> AStateSignAttestation
+	verifyStateHandleUnconfirmedToSignAttestation(t, attestService)
+	assert.Equal(t, 1, attestService.feeBumps)
+
+	// Test AStateSignAttestation -> AStatePreSendStore
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	// Test AStatePreSendStore -> AStateSendAttestation
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	// Test AStateSendAttestation -> AStateAwaitConfirmation
+	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+
+	// set confirm time back again - max fee bumps reached so no more bumping
+	attestService.confirmTime = attestService.confirmTime.Add(-attestService.atimeHandleUnconfirmed)
+
+	// Test AStateAwaitConfirmation -> AStateAwaitConfirmation
+	verifyStateAwaitConfirmationToAwaitConfirmation(t, attestService)
+	assert.Equal(t, 1, attestService.feeBumps)
+	assert.Equal(t, txid, attestService.attestation.Txid)
+
+	// Test AStateAwaitConfirmation -> AStateError when halting on max fee bumps
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, 1, true, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1})
+	attestService.doAttestation()
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, true, IsFatalError(attestService.errorState))
+
+	// Test confirmation resets fee bumps
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1})
+	attestService.state = AStateAwaitConfirmation
+	config.MainClient().Generate(1)
+	verifyStateAwaitConfirmationToNextCommitment(t, attestService, config, txid, attestService.atimeNewAttestation)
+	assert.Equal(t, 0, attestService.feeBumps)
+}
+
+// Test Attest Service timing schedules with a fake clock
+func TestAttestService_Clock(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+	config.SetTimingConfig(confpkg.TimingConfig{-1, -1, -1, 30, "", ""})
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	fakeClock := clock.NewClockFake(time.Now())
+	attestService.SetClock(fakeClock)
+
+	attestService.attester.Fees.ResetFee(true)
+
+	// Test AStateInit -> AStateAwaitConfirmation
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	_ = verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// schedule published with earliest next attestation once commitment taken
+	schedule, scheduleErr := server.GetAttestationSchedule()
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, fakeClock.Now().Add(attestService.atimeNewAttestation), schedule.NextAttestation)
+	assert.Equal(t, schedule.NextAttestation.Add(-30*time.Second), schedule.AcceptanceCutoff)
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	_ = verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+	assert.Equal(t, fakeClock.Now(), attestService.confirmTime)
+
+	// Test AStateAwaitConfirmation -> AStateAwaitConfirmation until handle unconfirmed time elapses
+	fakeClock.Advance(attestService.atimeHandleUnconfirmed)
+	verifyStateAwaitConfirmationToAwaitConfirmation(t, attestService)
+
+	// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed once elapsed
+	fakeClock.Advance(time.Second)
+	verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
+	verifyStateHandleUnconfirmedToSignAttestation(t, attestService)
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+	assert.Equal(t, fakeClock.Now(), attestService.confirmTime)
+
+	// Test AStateAwaitConfirmation -> AStateNextCommitment
+	// new attestation delay subtracts the time awaiting confirmation
+	fakeClock.Advance(time.Minute)
+	config.MainClient().Generate(1)
+	attestService.doAttestation()
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, txid, attestService.attestation.Txid)
+	assert.Equal(t, attestService.atimeNewAttestation-time.Minute, attestService.attestDelay)
+
+	// schedule published with next attestation time after confirmation
+	schedule, scheduleErr = server.GetAttestationSchedule()
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, fakeClock.Now().Add(attestService.attestDelay), schedule.NextAttestation)
+	assert.Equal(t, schedule.NextAttestation.Add(-30*time.Second), schedule.AcceptanceCutoff)
+	assert.Equal(t, true, schedule.IsAccepted(fakeClock.Now()))
+}
+
+// Test Attest Service pausing attestations when the client commitment is stale
+func TestAttestService_StaleCommitment(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+	config.SetAttestationConfig(confpkg.AttestationConfig{false, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, 2, false, "", "", "", "", -1})
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	fakeClock := clock.NewClockFake(time.Now())
+	attestService.SetClock(fakeClock)
+
+	// Test AStateInit -> AStateNextCommitment -> AStateNewAttestation
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	_ = verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// same commitment within the stale threshold is attested
+	attestService.state = AStateNextCommitment
+	fakeClock.Advance(2*attestService.atimeNewAttestation - time.Second)
+	_ = verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// Test AStateNextCommitment -> AStateNextCommitment once commitment is stale
+	attestService.state = AStateNextCommitment
+	fakeClock.Advance(time.Second)
+	attestService.doAttestation()
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, attestService.atimeNewAttestation, attestService.attestDelay)
+
+	// Test AStateNextCommitment -> AStateNewAttestation with override set
+	config.SetAttestationConfig(confpkg.AttestationConfig{false, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, 2, true, "", "", "", "", -1})
+	_ = verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+
+	// Test AStateNextCommitment -> AStateNewAttestation once commitment progresses
+	config.SetAttestationConfig(confpkg.AttestationConfig{false, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, 2, false, "", "", "", "", -1})
+	attestService.state = AStateNextCommitment
+	hashY, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latestCommitment := verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashY)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), attestService.staleCommitment)
+	assert.Equal(t, fakeClock.Now(), attestService.staleSince)
+}
+
+// Test Attest Service handling a zero client commitment merkle root
+func TestAttestService_ZeroCommitment(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, confpkg.ZeroCommitmentSkip, "", "", "", -1})
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{chainhash.Hash{}, 0}})
+
+	// Test AStateNextCommitment -> AStateNextCommitment with skip set
+	attestService.doAttestation()
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, attestService.atimeNewAttestation, attestService.attestDelay)
+
+	// Test AStateNextCommitment -> AStateNewAttestation with attest set
+	// zero commitment not skipped as a duplicate when there are no attestations
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, confpkg.ZeroCommitmentAttest, "", "", "", -1})
+	attestService.doAttestation()
+	assert.Equal(t, AStateNewAttestation, attestService.state)
+	zeroCommitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.Hash{}})
+	assert.Equal(t, zeroCommitment.GetCommitmentHash(), attestService.attestation.CommitmentHash())
+
+	// Test AStateNextCommitment -> AStateNewAttestation with sentinel set
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, confpkg.ZeroCommitmentSentinel, "", "", "", -1})
+	attestService.state = AStateNextCommitment
+	attestService.doAttestation()
+	assert.Equal(t, AStateNewAttestation, attestService.state)
+	assert.Equal(t, models.NewZeroCommitmentSentinel().GetCommitmentHash(), attestService.attestation.CommitmentHash())
+
+	// Test no client commitments handled as a zero commitment with skip set
+	config.SetAttestationConfig(confpkg.AttestationConfig{true, -1, false, confpkg.DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, confpkg.ZeroCommitmentSkip, "", "", "", -1})
+	dbFake.SetClientCommitments([]models.ClientCommitment{})
+	attestService.state = AStateNextCommitment
+	attestService.doAttestation()
+	assert.Equal(t, AStateNextCommitment, attestService.state)
+	assert.Equal(t, nil, attestService.errorState)
+}
+
 // Test Attest Service when dealing with topup Attestation
 func TestAttestService_WithTopup(t *testing.T) {
 
@@ -470,7 +723,7 @@ func TestAttestService_WithTopup(t *testing.T) {
 
 	// randomly test with invalid config here
 	// timing config no effect on server
-	timingConfig := confpkg.TimingConfig{-1, -1}
+	timingConfig := confpkg.TimingConfig{-1, -1, -1, -1, "", ""}
 	config.SetTimingConfig(timingConfig)
 
 	dbFake := server.NewDbFake()
@@ -511,7 +764,7 @@ func TestAttestService_WithTopup(t *testing.T) {
 
 	// create top up unspent
 	_ = createTopupUnspent(t, test.Config)
-	attestService.attester.MainClient.Generate(1)
+	test.Config.MainClient().Generate(1)
 
 	// Test AStateNewAttestation -> AStateSignAttestation
 	attestService.doAttestation()
@@ -521,7 +774,7 @@ func TestAttestService_WithTopup(t *testing.T) {
 	assert.Equal(t, 1, len(attestService.attestation.Tx.TxOut))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[0].SignatureScript))
 	assert.Equal(t, 0, len(attestService.attestation.Tx.TxIn[1].SignatureScript))
-	assert.Equal(t, ATimeSigs, attestDelay)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
 
 	// Test AStateSignAttestation -> AStatePreSendStore
 	verifyStateSignAttestationToPreSendStore(t, attestService)
@@ -804,7 +1057,7 @@ func TestAttestService_FailureSendAttestation(t *testing.T) {
 		assert.Equal(t, prevAttestation.Txid, attestService.attestation.Txid)
 		assert.Equal(t, prevAttestation.Confirmed, attestService.attestation.Confirmed)
 		assert.Equal(t, prevAttestation.Info, attestService.attestation.Info)
-		assert.Equal(t, ATimeFixed, attestDelay)
+		assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 		// Test AStateNextCommitment -> AStateNewAttestation
 		// set server commitment before creationg new attestation
@@ -964,7 +1217,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		assert.Equal(t, prevAttestation.Txid, attestService.attestation.Txid)
 		assert.Equal(t, prevAttestation.Confirmed, attestService.attestation.Confirmed)
 		assert.Equal(t, prevAttestation.Info, attestService.attestation.Info)
-		assert.Equal(t, ATimeFixed, attestDelay)
+		assert.Equal(t, ATimeFixed, attestService.attestDelay)
 
 		// Test AStateNextCommitment -> AStateNewAttestation
 		// set server commitment before creationg new attestation
@@ -982,7 +1235,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
 
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -1002,7 +1255,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		// Test AStateInit -> AStateAwaitConfirmation
 		verifyStateInitToAwaitConfirmation(t, attestService, latestCommitment, txid)
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -1027,7 +1280,7 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		// Test AStateInit -> AStateAwaitConfirmation
 		verifyStateInitToAwaitConfirmation(t, attestService, latestCommitment, txid)
 		// set confirm time back to test what happens in handle unconfirmed case
-		confirmTime = confirmTime.Add(-DefaultATimeHandleUnconfirmed)
+		attestService.confirmTime = attestService.confirmTime.Add(-DefaultATimeHandleUnconfirmed)
 
 		// Test AStateAwaitConfirmation -> AStateHandleUnconfirmed
 		verifyStateAwaitConfirmationToHandleUnconfirmed(t, attestService)
@@ -1073,3 +1326,111 @@ func TestAttestService_FailureHandleUnconfirmed(t *testing.T) {
 		prevAttestation = attestService.attestation
 	}
 }
+
+// Test Attest Service fatal errors
+// Fatal errors set the error state and are distinguished from recoverable ones
+func TestAttestService_FatalError(t *testing.T) {
+
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	verifyStateInit(t, attestService)
+
+	// test no errors
+	assert.Equal(t, false, attestService.setFatal(nil))
+	assert.Equal(t, false, attestService.setFailure(nil))
+	assert.Equal(t, AStateInit, attestService.state)
+
+	// test recoverable error
+	assert.Equal(t, true, attestService.setFailure(errors.New(ErroUnspentNotFound)))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, false, IsFatalError(attestService.errorState))
+	attestService.doAttestation()
+	assert.Equal(t, AStateInit, attestService.state)
+
+	// test fatal error
+	assert.Equal(t, true, attestService.setFatal(errors.New(ErrorMissingAddress)))
+	assert.Equal(t, AStateError, attestService.state)
+	assert.Equal(t, true, IsFatalError(attestService.errorState))
+	assert.Equal(t, ErrorMissingAddress, attestService.errorState.Error())
+	assert.Equal(t, 0, len(attestService.Errors()))
+}
+
+// Test pausing and resuming the attestation service
+func TestAttestService_Pause(t *testing.T) {
+
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+	verifyStateInit(t, attestService)
+	assert.Equal(t, false, attestService.IsPaused())
+
+	// test pause and resume
+	attestService.Pause()
+	assert.Equal(t, true, attestService.IsPaused())
+	attestService.Pause()
+	assert.Equal(t, true, attestService.IsPaused())
+	attestService.Resume()
+	assert.Equal(t, false, attestService.IsPaused())
+
+	// test toggle
+	assert.Equal(t, true, attestService.TogglePause())
+	assert.Equal(t, true, attestService.IsPaused())
+	assert.Equal(t, false, attestService.TogglePause())
+	assert.Equal(t, false, attestService.IsPaused())
+
+	// state kept while paused
+	assert.Equal(t, AStateInit, attestService.state)
+}
+
+// Test confirmed tip reconciliation with unspents found by scanning the utxo
+// set, which only set the unspent script and not the unspent address
+func TestAttestService_TipRecordedScanUtxoSet(t *testing.T) {
+	pubkeys, pubkeysExtended, chaincodes := getTestInitKeys()
+	client := &AttestClient{
+		MainClient:      NewAttestRpcClientFake(),
+		MainChainCfg:    &chaincfg.RegressionNetParams,
+		script0:         test.Script,
+		pubkeysExtended: pubkeysExtended,
+		pubkeys:         pubkeys,
+		chaincodes:      chaincodes,
+		numOfSigs:       1,
+		scanUtxoSet:     true}
+
+	// set latest confirmed attestation in server
+	dbFake := server.NewDbFake()
+	attestServer := server.NewServer(dbFake)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hashX, 0}})
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hashX})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest := models.NewAttestation(*txid, commitment)
+	latest.Confirmed = true
+	assert.Equal(t, nil, attestServer.UpdateLatestAttestation(*latest))
+	attestService := &AttestService{attester: client, server: attestServer}
+
+	// test tip paying to the latest attestation address recorded
+	tipAddr, _, _ := client.GetNextAttestationAddr((*btcutil.WIF)(nil), commitment.GetCommitmentHash())
+	tipScript, _ := txscript.PayToAddrScript(tipAddr)
+	unspent := btcjson.ListUnspentResult{TxID: txid.String(), ScriptPubKey: hex.EncodeToString(tipScript)}
+	recorded, recordedErr := attestService.isTipRecorded(unspent, commitment.GetCommitmentHash())
+	assert.Equal(t, nil, recordedErr)
+	assert.Equal(t, true, recorded)
+
+	// test tip not recorded for other commitments or other scripts
+	recorded, recordedErr = attestService.isTipRecorded(unspent, *hashX)
+	assert.Equal(t, nil, recordedErr)
+	assert.Equal(t, false, recorded)
+	otherAddr, _, _ := client.GetNextAttestationAddr((*btcutil.WIF)(nil), *hashX)
+	otherScript, _ := txscript.PayToAddrScript(otherAddr)
+	unspent.ScriptPubKey = hex.EncodeToString(otherScript)
+	recorded, recordedErr = attestService.isTipRecorded(unspent, commitment.GetCommitmentHash())
+	assert.Equal(t, nil, recordedErr)
+	assert.Equal(t, false, recorded)
+}
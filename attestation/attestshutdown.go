@@ -0,0 +1,137 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to report a final summary of the attestation service
+// state on shutdown, i.e. the latest confirmed attestation, any attestation
+// left in-flight, the current fee and signer status, so that a restart of
+// the service can be analysed post-mortem from the logs or a state file
+
+// warning consts
+const (
+	WarningShutdownInFlight = "Warning - Shutting down with attestation in-flight"
+)
+
+// ShutdownSummary structure
+// Final state of the attestation service on shutdown
+type ShutdownSummary struct {
+	// staychain init tx identifying the attestation service
+	Staychain string `json:"staychain"`
+
+	// status state on shutdown, whether paused and latest error
+	State  string `json:"state"`
+	Paused bool   `json:"paused"`
+	Error  string `json:"error,omitempty"`
+
+	// latest confirmed attestation txid, merkle root and confirmation time
+	LastTxid          string    `json:"last_txid"`
+	LastMerkleRoot    string    `json:"last_merkle_root"`
+	LastConfirmedTime time.Time `json:"last_confirmed_time"`
+
+	// whether an attestation is in-flight and its latest txid, if built
+	InFlight     bool   `json:"in_flight"`
+	InFlightTxid string `json:"in_flight_txid,omitempty"`
+
+	// current attestation fee and number of fee bumps
+	CurrentFee int `json:"current_fee"`
+	FeeBumps   int `json:"fee_bumps"`
+
+	// connectivity of individual signers, if reported by the signer
+	Signers []SignerStatus `json:"signers,omitempty"`
+
+	// time of shutdown
+	ShutdownTime time.Time `json:"shutdown_time"`
+}
+
+// Return txid of the in-flight attestation and whether any unspents are reserved
+func (w *AttestClient) inFlightTxid() (chainhash.Hash, bool) {
+	g := &w.inFlight
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.outpoints) == 0 {
+		return chainhash.Hash{}, false
+	}
+	return g.txid, true
+}
+
+// Return a summary of the attestation service state for shutdown
+// An attestation is in-flight if its unspents are still reserved or
+// the service stopped while signing or awaiting confirmation. Expected
+// to be called once the attestation service has stopped running
+func (s *AttestService) ShutdownSummary() ShutdownSummary {
+	status := s.Status()
+
+	// use the txid of the signed attestation if sent, as
+	// the reservation holds the txid of the pre-sign tx
+	inFlightTxid, reserved := s.attester.inFlightTxid()
+	inFlight := reserved || status.State == StatusSigning || status.State == StatusAwaitingConfirmation
+	if inFlight && (s.attestation.Txid != chainhash.Hash{}) {
+		inFlightTxid = s.attestation.Txid
+	}
+	var inFlightTxidStr string
+	if inFlight && (inFlightTxid != chainhash.Hash{}) {
+		inFlightTxidStr = inFlightTxid.String()
+	}
+
+	return ShutdownSummary{
+		Staychain:         s.config.InitTx(),
+		State:             status.State,
+		Paused:            status.Paused,
+		Error:             status.Error,
+		LastTxid:          status.LastTxid.String(),
+		LastMerkleRoot:    status.LastMerkleRoot.String(),
+		LastConfirmedTime: status.LastConfirmedTime,
+		InFlight:          inFlight,
+		InFlightTxid:      inFlightTxidStr,
+		CurrentFee:        status.CurrentFee,
+		FeeBumps:          status.FeeBumps,
+		Signers:           status.Signers,
+		ShutdownTime:      time.Now(),
+	}
+}
+
+// Log a summary of the attestation service state on shutdown
+// Warns if the service is shutting down with an attestation in-flight
+func (s *AttestService) LogShutdownSummary() ShutdownSummary {
+	summary := s.ShutdownSummary()
+
+	responding := 0
+	for _, signer := range summary.Signers {
+		if signer.Responding {
+			responding++
+		}
+	}
+
+	log.Printf("*AttestService* Shutdown summary: staychain=%s state=%s paused=%t last_txid=%s last_root=%s in_flight=%t fee=%d fee_bumps=%d signers=%d/%d\n",
+		summary.Staychain, summary.State, summary.Paused, summary.LastTxid, summary.LastMerkleRoot,
+		summary.InFlight, summary.CurrentFee, summary.FeeBumps, responding, len(summary.Signers))
+	if summary.Error != "" {
+		log.Printf("*AttestService* Shutdown with error: %s\n", summary.Error)
+	}
+	if summary.InFlight {
+		log.Printf("*AttestService* %s (state %s txid %s)\n", WarningShutdownInFlight, summary.State, summary.InFlightTxid)
+	}
+	return summary
+}
+
+// Write the shutdown summaries of all attestation services to a state file
+// in json format, overwriting the state file of any previous shutdown
+func WriteShutdownState(path string, summaries []ShutdownSummary) error {
+	data, marshalErr := json.MarshalIndent(summaries, "", "    ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Attest Service shutdown summary through an attestation cycle
+func TestAttestService_ShutdownSummary(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+
+	summary := attestService.LogShutdownSummary()
+	assert.Equal(t, config.InitTx(), summary.Staychain)
+	assert.Equal(t, StatusIdle, summary.State)
+	assert.Equal(t, false, summary.InFlight)
+	assert.Equal(t, "", summary.InFlightTxid)
+
+	// Test attestation in-flight while signing and awaiting confirmation
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latestCommitment := verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+	summary = attestService.LogShutdownSummary()
+	assert.Equal(t, StatusSigning, summary.State)
+	assert.Equal(t, true, summary.InFlight)
+	assert.Equal(t, attestService.attestation.Tx.TxHash().String(), summary.InFlightTxid)
+
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+	summary = attestService.LogShutdownSummary()
+	assert.Equal(t, StatusAwaitingConfirmation, summary.State)
+	assert.Equal(t, true, summary.InFlight)
+	assert.Equal(t, txid.String(), summary.InFlightTxid)
+	assert.Equal(t, (chainhash.Hash{}).String(), summary.LastTxid)
+
+	// Test no attestation in-flight after confirmation
+	config.MainClient().Generate(1)
+	verifyStateAwaitConfirmationToNextCommitment(t, attestService, config, txid, DefaultATimeNewAttestation)
+	summary = attestService.LogShutdownSummary()
+	assert.Equal(t, StatusIdle, summary.State)
+	assert.Equal(t, false, summary.InFlight)
+	assert.Equal(t, "", summary.InFlightTxid)
+	assert.Equal(t, txid.String(), summary.LastTxid)
+	assert.Equal(t, latestCommitment.GetCommitmentHash().String(), summary.LastMerkleRoot)
+	assert.Equal(t, attestService.attester.Fees.GetFee(), summary.CurrentFee)
+
+	// Test shutdown state file written and overwritten
+	dir, dirErr := ioutil.TempDir("", "mainstay-state")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	assert.Equal(t, nil, WriteShutdownState(path, []ShutdownSummary{summary, summary}))
+	assert.Equal(t, nil, WriteShutdownState(path, []ShutdownSummary{summary}))
+	data, _ := ioutil.ReadFile(path)
+	var summaries []ShutdownSummary
+	assert.Equal(t, nil, json.Unmarshal(data, &summaries))
+	assert.Equal(t, 1, len(summaries))
+	assert.Equal(t, summary.LastTxid, summaries[0].LastTxid)
+	assert.Equal(t, summary.LastMerkleRoot, summaries[0].LastMerkleRoot)
+	assert.Equal(t, false, summaries[0].InFlight)
+}
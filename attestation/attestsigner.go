@@ -18,7 +18,8 @@ import (
 // - getting the signatures from signers
 //
 // This interface allows building communication with
-// various ways - currently supporting zmq only
+// various ways - currently supporting zmq and an
+// external signer command over stdin/stdout
 // This interface allows building mock struct for testing
 type AttestSigner interface {
 	SendConfirmedHash([]byte)
@@ -0,0 +1,154 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// external signer command consts
+const (
+	DefaultSignerCommandTimeout = 30 * time.Second // timeout of each signer command run
+
+	WarningSignerCommandFailed  = "Warning - External signer command failed"
+	WarningSignerCommandTimeout = "Warning - External signer command timed out"
+	WarningSignerCommandOutput  = "Warning - Invalid external signer command output"
+)
+
+// request written to the stdin of the external signer command
+type signerCmdRequest struct {
+	CommitmentHash string   `json:"commitment_hash"`
+	TxPreImages    []string `json:"tx_pre_images"`
+	Compact        bool     `json:"compact,omitempty"`
+}
+
+// response read from the stdout of the external signer command
+// signatures are listed per transaction input
+type signerCmdResponse struct {
+	Signatures [][]string `json:"signatures"`
+}
+
+// AttestSignerCmd struct
+//
+// Implements AttestSigner interface and spawns an external
+// signer command, e.g. a command-line wrapper of an HSM,
+// writing the latest confirmed commitment hash and the tx
+// pre-images to its stdin and reading signatures from stdout
+type AttestSignerCmd struct {
+	// command path and arguments
+	args []string
+
+	// timeout of each command run
+	timeout time.Duration
+
+	// tx pre-images sent in compact format
+	compact bool
+
+	// latest confirmed hash and tx pre-images received
+	confirmedHash []byte
+	txPreImages   [][]byte
+}
+
+// Return new AttestSignerCmd instance
+func NewAttestSignerCmd(config confpkg.SignerConfig) *AttestSignerCmd {
+	timeout := DefaultSignerCommandTimeout
+	if config.CommandTimeoutSeconds > 0 {
+		timeout = time.Duration(config.CommandTimeoutSeconds) * time.Second
+	}
+	return &AttestSignerCmd{
+		args:    strings.Fields(config.Command),
+		timeout: timeout,
+		compact: config.CompactPreImages}
+}
+
+// Resubscribe - do nothing as a new process is spawned on each run
+func (c *AttestSignerCmd) ReSubscribe() {
+	return
+}
+
+// Store received confirmed hash for the next command run
+func (c *AttestSignerCmd) SendConfirmedHash(hash []byte) {
+	c.confirmedHash = hash
+}
+
+// Store received tx pre-images for the next command run
+func (c *AttestSignerCmd) SendTxPreImages(txs [][]byte) {
+	c.txPreImages = txs
+}
+
+// Run the external signer command and parse the signatures received
+// No signatures are returned on command failure, timeout or invalid output
+func (c *AttestSignerCmd) GetSigs() [][]crypto.Sig {
+	if len(c.args) == 0 || len(c.txPreImages) == 0 {
+		return [][]crypto.Sig{}
+	}
+
+	// build request from confirmed hash and pre-images
+	request := signerCmdRequest{TxPreImages: []string{}, Compact: c.compact}
+	if len(c.confirmedHash) > 0 {
+		hash, hashErr := chainhash.NewHash(c.confirmedHash)
+		if hashErr != nil {
+			log.Printf("%s %v\n", WarningSignerCommandFailed, hashErr)
+			return [][]crypto.Sig{}
+		}
+		request.CommitmentHash = hash.String()
+	}
+	for _, txPreImage := range c.txPreImages {
+		request.TxPreImages = append(request.TxPreImages, hex.EncodeToString(txPreImage))
+	}
+	requestBytes, marshalErr := json.Marshal(request)
+	if marshalErr != nil {
+		log.Printf("%s %v\n", WarningSignerCommandFailed, marshalErr)
+		return [][]crypto.Sig{}
+	}
+
+	// run command killing it on timeout
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stdin = bytes.NewReader(requestBytes)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("%s (%s)\n", WarningSignerCommandTimeout, c.timeout.String())
+		return [][]crypto.Sig{}
+	} else if runErr != nil {
+		log.Printf("%s %v %s\n", WarningSignerCommandFailed, runErr, strings.TrimSpace(stderr.String()))
+		return [][]crypto.Sig{}
+	}
+
+	// parse signatures for each tx input
+	var response signerCmdResponse
+	if unmarshalErr := json.Unmarshal(stdout.Bytes(), &response); unmarshalErr != nil {
+		log.Printf("%s %v\n", WarningSignerCommandOutput, unmarshalErr)
+		return [][]crypto.Sig{}
+	}
+	sigs := make([][]crypto.Sig, len(response.Signatures))
+	for i, inputSigs := range response.Signatures {
+		for _, sigStr := range inputSigs {
+			sig, sigErr := hex.DecodeString(sigStr)
+			if sigErr != nil {
+				log.Printf("%s %v\n", WarningSignerCommandOutput, sigErr)
+				return [][]crypto.Sig{}
+			}
+			sigs[i] = append(sigs[i], crypto.Sig(sig))
+		}
+	}
+	return sigs
+}
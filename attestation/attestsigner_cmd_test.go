@@ -0,0 +1,88 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// write executable signer script to temp dir for testing
+func writeSignerScript(t *testing.T, dir string, name string, script string) string {
+	path := filepath.Join(dir, name)
+	assert.Equal(t, nil, ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700))
+	return path
+}
+
+// Test external signer command request and signature parsing
+func TestAttestSignerCmd(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "signercmd")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+
+	hash, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txPreImages := [][]byte{[]byte{1, 2, 3}, []byte{4, 5}}
+
+	// test request written to stdin and signatures read from stdout
+	requestPath := filepath.Join(dir, "request.json")
+	signPath := writeSignerScript(t, dir, "sign.sh",
+		"cat > "+requestPath+"\necho '{\"signatures\": [[\"0a0b\", \"0c\"], [\"0d\"]]}'\n")
+	signer := NewAttestSignerCmd(confpkg.SignerConfig{Command: signPath})
+	assert.Equal(t, DefaultSignerCommandTimeout, signer.timeout)
+	assert.Equal(t, [][]crypto.Sig{}, signer.GetSigs()) // no pre-images yet
+
+	signer.SendConfirmedHash(hash.CloneBytes())
+	signer.SendTxPreImages(txPreImages)
+	sigs := signer.GetSigs()
+	assert.Equal(t, [][]crypto.Sig{
+		[]crypto.Sig{crypto.Sig{10, 11}, crypto.Sig{12}},
+		[]crypto.Sig{crypto.Sig{13}}}, sigs)
+
+	requestBytes, readErr := ioutil.ReadFile(requestPath)
+	assert.Equal(t, nil, readErr)
+	var request signerCmdRequest
+	assert.Equal(t, nil, json.Unmarshal(requestBytes, &request))
+	assert.Equal(t, hash.String(), request.CommitmentHash)
+	assert.Equal(t, []string{"010203", "0405"}, request.TxPreImages)
+
+	// test command arguments passed to command
+	argsPath := writeSignerScript(t, dir, "args.sh",
+		"cat > /dev/null\necho \"{\\\"signatures\\\": [[\\\"$1\\\"]]}\"\n")
+	signer = NewAttestSignerCmd(confpkg.SignerConfig{Command: argsPath + " 0e0f"})
+	signer.SendTxPreImages(txPreImages)
+	assert.Equal(t, [][]crypto.Sig{[]crypto.Sig{crypto.Sig{14, 15}}}, signer.GetSigs())
+
+	// test command failure
+	failPath := writeSignerScript(t, dir, "fail.sh", "echo 'hsm locked' >&2\nexit 1\n")
+	signer = NewAttestSignerCmd(confpkg.SignerConfig{Command: failPath})
+	signer.SendTxPreImages(txPreImages)
+	assert.Equal(t, [][]crypto.Sig{}, signer.GetSigs())
+
+	// test missing command
+	signer = NewAttestSignerCmd(confpkg.SignerConfig{Command: filepath.Join(dir, "missing.sh")})
+	signer.SendTxPreImages(txPreImages)
+	assert.Equal(t, [][]crypto.Sig{}, signer.GetSigs())
+
+	// test invalid output
+	invalidPath := writeSignerScript(t, dir, "invalid.sh", "echo '{\"signatures\": [[\"xyz\"]]}'\n")
+	signer = NewAttestSignerCmd(confpkg.SignerConfig{Command: invalidPath})
+	signer.SendTxPreImages(txPreImages)
+	assert.Equal(t, [][]crypto.Sig{}, signer.GetSigs())
+
+	// test command timeout
+	sleepPath := writeSignerScript(t, dir, "sleep.sh", "exec sleep 10\n")
+	signer = NewAttestSignerCmd(confpkg.SignerConfig{Command: sleepPath, CommandTimeoutSeconds: 1})
+	signer.SendTxPreImages(txPreImages)
+	assert.Equal(t, [][]crypto.Sig{}, signer.GetSigs())
+}
@@ -20,6 +20,9 @@ import (
 // mock functionality for receiving sigs from signers
 type AttestSignerFake struct {
 	clients []*AttestClient
+
+	// tx pre-images received in compact format
+	compact bool
 }
 
 // store latest hash and transaction
@@ -30,12 +33,14 @@ var signerConfirmedHashBytes []byte
 func NewAttestSignerFake(configs []*confpkg.Config) AttestSignerFake {
 
 	var clients []*AttestClient
+	compact := false
 	for _, config := range configs {
 		// isSigner flag set to allow signing transactions
 		clients = append(clients, NewAttestClient(config, true))
+		compact = compact || config.SignerConfig().CompactPreImages
 	}
 
-	return AttestSignerFake{clients: clients}
+	return AttestSignerFake{clients: clients, compact: compact}
 }
 
 // Resubscribe - do nothing
@@ -64,6 +69,14 @@ func (f AttestSignerFake) GetSigs() [][]crypto.Sig {
 
 	// get unserialized tx pre images
 	txPreImages := UnserializeBytes(signerTxPreImageBytes)
+	if f.compact {
+		var expandErr error
+		txPreImages, expandErr = ExpandTxPreImages(txPreImages)
+		if expandErr != nil {
+			log.Printf("%v\n", expandErr)
+			return nil
+		}
+	}
 
 	sigs := make([][]crypto.Sig, len(txPreImages)) // init sigs
 
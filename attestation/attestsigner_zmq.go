@@ -7,6 +7,7 @@ package attestation
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	confpkg "mainstay/config"
 	"mainstay/crypto"
@@ -23,6 +24,18 @@ const (
 	TopicNewTx         = "T"
 	TopicConfirmedHash = "C"
 	TopicSigs          = "S"
+
+	// prefix of new tx topics of individual signers in signer failover
+	// followed by the two digit signer index, i.e. P00, P01, etc
+	TopicNewTxSigner = "P"
+
+	// separator between a topic namespace and the topic, i.e. chain0/T
+	TopicNamespaceSeparator = "/"
+
+	// number of rounds a signer that failed to respond is skipped for
+	SignerFailoverRounds = 10
+
+	WarningSignerNoResponse = "Warning - Signer did not respond"
 )
 
 // AttestSignerZmq struct
@@ -39,10 +52,20 @@ type AttestSignerZmq struct {
 
 	// store config for future later use when resubscribing
 	config confpkg.SignerConfig
-}
 
-// poller to add all subscriber/publisher sockets
-var poller *zmq.Poller
+	// poller to add all subscriber/publisher sockets - one per
+	// signer instance so that signer connections are not shared
+	poller *zmq.Poller
+
+	// signer failover state - signers asked to sign in the current
+	// round and the round until which failed signers are skipped
+	round       int
+	asked       []int
+	failedUntil map[int]int
+
+	// whether each signer responded the latest round it was asked in
+	responding map[int]bool
+}
 
 // Return new AttestSignerZmq instance
 func NewAttestSignerZmq(config confpkg.SignerConfig) *AttestSignerZmq {
@@ -54,37 +77,83 @@ func NewAttestSignerZmq(config confpkg.SignerConfig) *AttestSignerZmq {
 
 	// Initialise publisher for sending new hashes and txs
 	// and subscribers to receive sig responses
-	poller = zmq.NewPoller()
+	poller := zmq.NewPoller()
 	publisher := messengers.NewPublisherZmq(publisherAddr, poller)
 	var subscribers []*messengers.SubscriberZmq
-	subtopics := []string{TopicSigs}
+	subtopics := []string{NamespaceTopic(config.TopicNamespace, TopicSigs)}
 	for _, nodeaddr := range config.Signers {
 		subscribers = append(subscribers, messengers.NewSubscriberZmq(nodeaddr, subtopics, poller))
 	}
 
-	return &AttestSignerZmq{publisher, subscribers, config}
+	return &AttestSignerZmq{publisher, subscribers, config, poller, 0, nil, map[int]int{}, map[int]bool{}}
+}
+
+// Get topic of new txs of an individual signer
+func SignerTopicNewTx(index int) string {
+	return fmt.Sprintf("%s%02d", TopicNewTxSigner, index)
+}
+
+// Get topic prefixed with the topic namespace, if the namespace is set
+// Zmq subscriptions match topic prefixes, so the separator ensures that
+// signers of one namespace never receive topics of another namespace
+func NamespaceTopic(namespace string, topic string) string {
+	if namespace == "" {
+		return topic
+	}
+	return namespace + TopicNamespaceSeparator + topic
+}
+
+// Get topic prefixed with the configured topic namespace
+func (z *AttestSignerZmq) topic(topic string) string {
+	return NamespaceTopic(z.config.TopicNamespace, topic)
+}
+
+// Check if signer failover is enabled, i.e. if there are backup
+// signers besides the number of primary signers configured
+func (z *AttestSignerZmq) isFailover() bool {
+	return z.config.Primaries > 0 && z.config.Primaries < len(z.config.Signers)
+}
+
+// Select the signers to ask in a round in order of priority
+// Signers that failed are skipped until the round they are retried
+// at, unless fewer than the number of primaries would be selected
+func selectSigners(numOfSigners int, primaries int, failedUntil map[int]int, round int) []int {
+	var selected []int
+	for i := 0; i < numOfSigners && len(selected) < primaries; i++ {
+		if failedUntil[i] <= round {
+			selected = append(selected, i)
+		}
+	}
+	// not enough signers available - ask failed signers again in priority order
+	for i := 0; i < numOfSigners && len(selected) < primaries; i++ {
+		if failedUntil[i] > round {
+			selected = append(selected, i)
+		}
+	}
+	sort.Ints(selected)
+	return selected
 }
 
 // Zmq Resubscribe to the transaction signers
 func (z *AttestSignerZmq) ReSubscribe() {
 	// close current sockets
 	for _, sub := range z.subscribers {
-		sub.Close(poller)
+		sub.Close(z.poller)
 	}
 	z.subscribers = nil // empty slice
 
 	// reconnect to signers
 	var subscribers []*messengers.SubscriberZmq
-	subtopics := []string{TopicSigs}
+	subtopics := []string{z.topic(TopicSigs)}
 	for _, nodeaddr := range z.config.Signers {
-		subscribers = append(subscribers, messengers.NewSubscriberZmq(nodeaddr, subtopics, poller))
+		subscribers = append(subscribers, messengers.NewSubscriberZmq(nodeaddr, subtopics, z.poller))
 	}
 	z.subscribers = subscribers
 }
 
 // Use zmq publisher to send confirmed hash
-func (z AttestSignerZmq) SendConfirmedHash(hash []byte) {
-	z.publisher.SendMessage(hash, TopicConfirmedHash)
+func (z *AttestSignerZmq) SendConfirmedHash(hash []byte) {
+	z.publisher.SendMessage(hash, z.topic(TopicConfirmedHash))
 }
 
 // Transform received list of bytes into a single byte
@@ -141,8 +210,41 @@ func UnserializeBytes(data []byte) [][]byte {
 }
 
 // Use zmq publisher to send new tx
-func (z AttestSignerZmq) SendTxPreImages(txs [][]byte) {
-	z.publisher.SendMessage(SerializeBytes(txs), TopicNewTx)
+// In signer failover only the selected signers are sent the new tx
+func (z *AttestSignerZmq) SendTxPreImages(txs [][]byte) {
+	if !z.isFailover() {
+		z.publisher.SendMessage(SerializeBytes(txs), z.topic(TopicNewTx))
+		return
+	}
+	z.round++
+	z.asked = selectSigners(len(z.config.Signers), z.config.Primaries, z.failedUntil, z.round)
+	for _, i := range z.asked {
+		log.Printf("*AttestSignerZmq* Sending tx pre-images to signer %s\n", z.config.Signers[i])
+		z.publisher.SendMessage(SerializeBytes(txs), z.topic(SignerTopicNewTx(i)))
+	}
+}
+
+// Return connectivity of each signer, i.e. whether each signer
+// responded the latest round it was asked to sign in
+func (z *AttestSignerZmq) SignerStatus() []SignerStatus {
+	var status []SignerStatus
+	for i, signer := range z.config.Signers {
+		status = append(status, SignerStatus{Signer: signer, Responding: z.responding[i]})
+	}
+	return status
+}
+
+// Check if signer was asked to sign in the current round
+func (z *AttestSignerZmq) isAsked(index int) bool {
+	if !z.isFailover() {
+		return true
+	}
+	for _, i := range z.asked {
+		if i == index {
+			return true
+		}
+	}
+	return false
 }
 
 // Parse all received messages and create a sigs slice
@@ -178,7 +280,9 @@ func updateNumOfTxInputs(msgSplit [][]byte, numOfInputs int) int {
 }
 
 // Listen to zmq subscribers to receive tx signatures
-func (z AttestSignerZmq) GetSigs() [][]crypto.Sig {
+// In signer failover signers asked that did not respond until
+// now are skipped in the next rounds so that backups are asked
+func (z *AttestSignerZmq) GetSigs() [][]crypto.Sig {
 
 	var msgs [][][]byte
 	numOfTxInputs := 0
@@ -186,14 +290,14 @@ func (z AttestSignerZmq) GetSigs() [][]crypto.Sig {
 	// Iterate through each subscriber to get the latest message sent
 	// If there is more than one message in the subscriber queue the
 	// last is retained by continuously polling the Poller to get that
-	for _, sub := range z.subscribers {
+	for subIndex, sub := range z.subscribers {
 
 		var subMsg [][]byte // store latest message
 
 		// continously poll to get latest message
 		// or stop if no message has been found
 		for {
-			sockets, pollErr := poller.Poll(-1)
+			sockets, pollErr := z.poller.Poll(-1)
 			if pollErr != nil {
 				log.Println(pollErr)
 			}
@@ -213,6 +317,18 @@ func (z AttestSignerZmq) GetSigs() [][]crypto.Sig {
 			}
 		}
 
+		// ignore signers not asked and record asked signers not responding
+		if !z.isAsked(subIndex) {
+			continue
+		}
+		z.responding[subIndex] = len(subMsg) > 0
+		if z.isFailover() && len(subMsg) == 0 {
+			log.Printf("%s %s\n", WarningSignerNoResponse, z.config.Signers[subIndex])
+			z.failedUntil[subIndex] = z.round + SignerFailoverRounds
+		} else if z.isFailover() {
+			delete(z.failedUntil, subIndex)
+		}
+
 		// update received messages only if a subscriber message has been found
 		// this check is probably unnecessary but better safe than sorry
 		if len(subMsg) > 0 {
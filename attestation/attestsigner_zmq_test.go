@@ -308,3 +308,37 @@ func TestAttestSigner_TxUtils(t *testing.T) {
 	serializedTxs = append(serializedTxs, []byte{2, 1, 1}...) // add non noise edge case
 	assert.Equal(t, [][]byte{tx1Bytes, []byte{1, 1}}, UnserializeBytes(serializedTxs))
 }
+
+// Test selection of signers asked to sign in signer failover
+func TestAttestSigner_SelectSigners(t *testing.T) {
+	failedUntil := map[int]int{}
+
+	// test primaries selected in order of priority
+	assert.Equal(t, []int{0, 1}, selectSigners(4, 2, failedUntil, 1))
+
+	// test backup selected when a primary fails
+	failedUntil[0] = 1 + SignerFailoverRounds
+	assert.Equal(t, []int{1, 2}, selectSigners(4, 2, failedUntil, 2))
+	failedUntil[2] = 2 + SignerFailoverRounds
+	assert.Equal(t, []int{1, 3}, selectSigners(4, 2, failedUntil, 3))
+
+	// test failed signers asked again when not enough signers available
+	failedUntil[3] = 3 + SignerFailoverRounds
+	assert.Equal(t, []int{0, 1}, selectSigners(4, 2, failedUntil, 4))
+	failedUntil[1] = 4 + SignerFailoverRounds
+	assert.Equal(t, []int{0, 1}, selectSigners(4, 2, failedUntil, 5))
+
+	// test failed primary retried after failover rounds
+	failedUntil = map[int]int{0: 1 + SignerFailoverRounds}
+	assert.Equal(t, []int{1, 2}, selectSigners(4, 2, failedUntil, SignerFailoverRounds))
+	assert.Equal(t, []int{0, 1}, selectSigners(4, 2, failedUntil, 1+SignerFailoverRounds))
+
+	// test signer topics
+	assert.Equal(t, "P00", SignerTopicNewTx(0))
+	assert.Equal(t, "P12", SignerTopicNewTx(12))
+
+	// test namespaced topics
+	assert.Equal(t, TopicNewTx, NamespaceTopic("", TopicNewTx))
+	assert.Equal(t, "chain0/T", NamespaceTopic("chain0", TopicNewTx))
+	assert.Equal(t, "chain0/P01", NamespaceTopic("chain0", SignerTopicNewTx(1)))
+}
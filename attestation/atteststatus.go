@@ -0,0 +1,179 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to report a snapshot of the attestation service status
+// The snapshot is updated by the attestation loop after each state and read
+// under a lock, so that it can be queried concurrently, e.g. by an API or a
+// dashboard, without touching the attestation state while the loop runs
+
+// attestation service status states
+const (
+	StatusIdle                 = "idle"
+	StatusBuilding             = "building"
+	StatusSigning              = "signing"
+	StatusAwaitingConfirmation = "awaiting-confirmation"
+	StatusError                = "error"
+)
+
+// SignerStatus structure
+// Connectivity of an individual signer
+type SignerStatus struct {
+	// signer address
+	Signer string
+
+	// whether the signer responded the latest round it was asked to sign in
+	Responding bool
+}
+
+// AttestSignerStatus interface
+//
+// Optionally implemented by signers to report the
+// connectivity of individual signers in the status
+type AttestSignerStatus interface {
+	SignerStatus() []SignerStatus
+}
+
+// AttestServiceStatus structure
+// Snapshot of the attestation service status
+type AttestServiceStatus struct {
+	// current status state and whether the service is paused
+	State  string
+	Paused bool
+
+	// latest error, if the service is recovering from a failure
+	Error string
+
+	// latest confirmed attestation txid, merkle root and confirmation time
+	LastTxid          chainhash.Hash
+	LastMerkleRoot    chainhash.Hash
+	LastConfirmedTime time.Time
+
+	// current attestation fee and number of fee bumps
+	CurrentFee int
+	FeeBumps   int
+
+	// number of client commitments received that are not
+	// in the latest confirmed attestation, as of the latest
+	// commitment received by the attestation service
+	PendingCommitments int
+
+	// connectivity of individual signers, if reported by the signer
+	Signers []SignerStatus
+}
+
+// attestStatus structure
+// Status snapshot guarded for concurrent access
+type attestStatus struct {
+	// mutex guarding status access
+	mutex sync.RWMutex
+
+	// latest status snapshot
+	status AttestServiceStatus
+
+	// client commitments of the latest confirmed attestation by position
+	confirmedLeaves map[int32]chainhash.Hash
+}
+
+// Return new status with the service idle
+func newAttestStatus() *attestStatus {
+	return &attestStatus{status: AttestServiceStatus{State: StatusIdle}}
+}
+
+// Return status state of an attestation state
+func statusState(state AttestationState) string {
+	switch state {
+	case AStateNewAttestation:
+		return StatusBuilding
+	case AStateSignAttestation, AStateHandleUnconfirmed:
+		return StatusSigning
+	case AStatePreSendStore, AStateSendAttestation, AStateAwaitConfirmation:
+		return StatusAwaitingConfirmation
+	case AStateError:
+		return StatusError
+	}
+	return StatusIdle
+}
+
+// Return the number of client commitments of the commitment
+// provided that differ from the confirmed client commitments
+func countPendingCommitments(commitment models.Commitment, confirmedLeaves map[int32]chainhash.Hash) int {
+	pending := 0
+	for _, c := range commitment.GetMerkleCommitments() {
+		if (c.Commitment == chainhash.Hash{}) {
+			continue
+		}
+		if confirmed, ok := confirmedLeaves[c.ClientPosition]; !ok || confirmed != c.Commitment {
+			pending++
+		}
+	}
+	return pending
+}
+
+// Return a snapshot of the attestation service status
+// Safe to call concurrently with the attestation service running
+func (s *AttestService) Status() AttestServiceStatus {
+	s.status.mutex.RLock()
+	defer s.status.mutex.RUnlock()
+
+	status := s.status.status
+	status.Paused = s.IsPaused()
+	status.Signers = append([]SignerStatus(nil), s.status.status.Signers...)
+	return status
+}
+
+// Update status snapshot from the current attestation state
+// Called by the attestation service after each attestation state
+func (s *AttestService) updateStatus() {
+	var signers []SignerStatus
+	if signerStatus, ok := s.signer.(AttestSignerStatus); ok {
+		signers = signerStatus.SignerStatus()
+	}
+	fee := s.attester.Fees.currentFeeValue()
+
+	s.status.mutex.Lock()
+	defer s.status.mutex.Unlock()
+
+	status := &s.status.status
+	status.State = statusState(s.state)
+	status.Error = ""
+	if s.state == AStateError && s.errorState != nil {
+		status.Error = s.errorState.Error()
+	}
+	status.CurrentFee = fee
+	status.FeeBumps = s.feeBumps
+	status.Signers = signers
+
+	// record latest confirmed attestation
+	if s.attestation.Confirmed && s.attestation.Txid != status.LastTxid {
+		status.LastTxid = s.attestation.Txid
+		status.LastMerkleRoot = s.attestation.CommitmentHash()
+		status.LastConfirmedTime = time.Unix(s.attestation.Info.Time, 0)
+
+		s.status.confirmedLeaves = make(map[int32]chainhash.Hash)
+		if commitment, commitmentErr := s.attestation.Commitment(); commitmentErr == nil {
+			for _, c := range commitment.GetMerkleCommitments() {
+				s.status.confirmedLeaves[c.ClientPosition] = c.Commitment
+			}
+		}
+		status.PendingCommitments = 0
+	}
+}
+
+// Update number of pending client commitments from the latest commitment received
+func (s *AttestService) updatePendingCommitments(commitment models.Commitment) {
+	s.status.mutex.Lock()
+	defer s.status.mutex.Unlock()
+	s.status.status.PendingCommitments = countPendingCommitments(commitment, s.status.confirmedLeaves)
+}
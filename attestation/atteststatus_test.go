@@ -0,0 +1,88 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/models"
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Attest Service status snapshot through an attestation cycle
+func TestAttestService_Status(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake([]*confpkg.Config{config}), config)
+
+	status := attestService.Status()
+	assert.Equal(t, StatusIdle, status.State)
+	assert.Equal(t, chainhash.Hash{}, status.LastTxid)
+	assert.Equal(t, []SignerStatus(nil), status.Signers)
+
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	assert.Equal(t, StatusIdle, attestService.Status().State)
+
+	// Test building status with pending client commitment
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latestCommitment := verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	status = attestService.Status()
+	assert.Equal(t, StatusBuilding, status.State)
+	assert.Equal(t, 1, status.PendingCommitments)
+
+	// Test signing and awaiting confirmation status
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+	assert.Equal(t, StatusSigning, attestService.Status().State)
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	assert.Equal(t, StatusAwaitingConfirmation, attestService.Status().State)
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	txid := verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+	status = attestService.Status()
+	assert.Equal(t, StatusAwaitingConfirmation, status.State)
+	assert.Equal(t, chainhash.Hash{}, status.LastTxid)
+	assert.Equal(t, 1, status.PendingCommitments)
+
+	// Test latest confirmed attestation recorded on confirmation
+	config.MainClient().Generate(1)
+	verifyStateAwaitConfirmationToNextCommitment(t, attestService, config, txid, DefaultATimeNewAttestation)
+	status = attestService.Status()
+	assert.Equal(t, StatusIdle, status.State)
+	assert.Equal(t, txid, status.LastTxid)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), status.LastMerkleRoot)
+	assert.Equal(t, time.Unix(attestService.attestation.Info.Time, 0), status.LastConfirmedTime)
+	assert.Equal(t, attestService.attester.Fees.GetFee(), status.CurrentFee)
+	assert.Equal(t, 0, status.PendingCommitments)
+
+	// Test pending client commitments counted against the confirmed attestation
+	hashY, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hashX, 0}, models.ClientCommitment{*hashY, 1}})
+	attestService.doAttestation()
+	assert.Equal(t, AStateNewAttestation, attestService.state)
+	assert.Equal(t, 1, attestService.Status().PendingCommitments)
+
+	// Test paused status
+	attestService.Pause()
+	assert.Equal(t, true, attestService.Status().Paused)
+	attestService.Resume()
+	assert.Equal(t, false, attestService.Status().Paused)
+
+	// Test status states of attestation states
+	assert.Equal(t, StatusIdle, statusState(AStateInit))
+	assert.Equal(t, StatusSigning, statusState(AStateHandleUnconfirmed))
+	assert.Equal(t, StatusError, statusState(AStateError))
+}
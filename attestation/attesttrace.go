@@ -0,0 +1,141 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Utility functions to trace the attestation transactions built and signed
+// Traces are only logged when debugging is enabled in the attestation config
+
+// TxTrace structure
+// Decoded attestation transaction along with its hex serialisation,
+// the fee paid and the unsigned and estimated signed transaction size
+type TxTrace struct {
+	Txid       string
+	Hex        string
+	Version    int32
+	LockTime   uint32
+	Size       int
+	SignedSize int
+	Fee        int64 // negative if the input values are not known
+	Inputs     []TxTraceInput
+	Outputs    []TxTraceOutput
+}
+
+// TxTraceInput structure
+// Decoded attestation transaction input
+type TxTraceInput struct {
+	PreviousOutPoint string
+	Value            int64 // negative if the previous output is not found
+	Sequence         uint32
+	ScriptSig        string
+	ScriptSigAsm     string
+}
+
+// TxTraceOutput structure
+// Decoded attestation transaction output
+type TxTraceOutput struct {
+	Value       int64
+	PkScript    string
+	PkScriptAsm string
+	Class       string
+	Addresses   []string
+}
+
+// Return trace of the transaction provided
+// Input values are fetched from the previous transactions in the main client
+func (w *AttestClient) traceTransaction(msgTx *wire.MsgTx) TxTrace {
+	var buf bytes.Buffer
+	msgTx.Serialize(&buf)
+
+	trace := TxTrace{
+		Txid:       msgTx.TxHash().String(),
+		Hex:        hex.EncodeToString(buf.Bytes()),
+		Version:    msgTx.Version,
+		LockTime:   msgTx.LockTime,
+		Size:       msgTx.SerializeSize(),
+		SignedSize: w.calcSignedAttestationSize(msgTx),
+	}
+
+	inputsValue := int64(0)
+	for _, txIn := range msgTx.TxIn {
+		input := TxTraceInput{
+			PreviousOutPoint: txIn.PreviousOutPoint.String(),
+			Value:            -1,
+			Sequence:         txIn.Sequence,
+			ScriptSig:        hex.EncodeToString(txIn.SignatureScript),
+		}
+		input.ScriptSigAsm, _ = txscript.DisasmString(txIn.SignatureScript)
+		prevTx, prevTxErr := w.MainClient.GetRawTransaction(&txIn.PreviousOutPoint.Hash)
+		if prevTxErr == nil && int(txIn.PreviousOutPoint.Index) < len(prevTx.MsgTx().TxOut) {
+			input.Value = prevTx.MsgTx().TxOut[txIn.PreviousOutPoint.Index].Value
+		}
+		if input.Value < 0 || inputsValue < 0 {
+			inputsValue = -1
+		} else {
+			inputsValue += input.Value
+		}
+		trace.Inputs = append(trace.Inputs, input)
+	}
+
+	outputsValue := int64(0)
+	for _, txOut := range msgTx.TxOut {
+		output := TxTraceOutput{
+			Value:    txOut.Value,
+			PkScript: hex.EncodeToString(txOut.PkScript),
+		}
+		output.PkScriptAsm, _ = txscript.DisasmString(txOut.PkScript)
+		class, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, w.MainChainCfg)
+		output.Class = class.String()
+		for _, addr := range addrs {
+			output.Addresses = append(output.Addresses, addr.String())
+		}
+		outputsValue += txOut.Value
+		trace.Outputs = append(trace.Outputs, output)
+	}
+
+	trace.Fee = -1
+	if inputsValue >= 0 {
+		trace.Fee = inputsValue - outputsValue
+	}
+	return trace
+}
+
+// Return multiline string representation of the transaction trace
+func (t TxTrace) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "txid: %s\n", t.Txid)
+	fmt.Fprintf(&b, "version: %d locktime: %d\n", t.Version, t.LockTime)
+	fmt.Fprintf(&b, "size: %d signed size (estimate): %d fee: %d\n", t.Size, t.SignedSize, t.Fee)
+	for i, input := range t.Inputs {
+		fmt.Fprintf(&b, "vin %d: %s value: %d sequence: %d\n", i, input.PreviousOutPoint, input.Value, input.Sequence)
+		fmt.Fprintf(&b, "    scriptSig: %s\n", input.ScriptSig)
+		fmt.Fprintf(&b, "    scriptSig asm: %s\n", input.ScriptSigAsm)
+	}
+	for i, output := range t.Outputs {
+		fmt.Fprintf(&b, "vout %d: value: %d %s %s\n", i, output.Value, output.Class, strings.Join(output.Addresses, ","))
+		fmt.Fprintf(&b, "    scriptPubKey: %s\n", output.PkScript)
+		fmt.Fprintf(&b, "    scriptPubKey asm: %s\n", output.PkScriptAsm)
+	}
+	fmt.Fprintf(&b, "hex: %s\n", t.Hex)
+	return b.String()
+}
+
+// Log trace of the transaction provided if debugging is enabled
+func (w *AttestClient) logTransactionTrace(stage string, msgTx *wire.MsgTx) {
+	if !w.debug {
+		return
+	}
+	log.Printf("*Client* DEBUG %s\n%s", stage, w.traceTransaction(msgTx).String())
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"log"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to handle signing rounds in which no signer responded,
+// e.g. when all signers are down, depending on the configured policy:
+// either keep retrying to collect signatures with an exponential backoff
+// or proceed with the local signatures of the service if they meet quorum,
+// falling back to retrying if the local keys do not meet quorum
+
+// warning consts
+const (
+	WarningSignersUnreachable        = "CRITICAL - All signers unreachable"
+	WarningInvalidSignersUnreachable = "Warning - Invalid signers unreachable config value"
+	WarningLocalKeysMissing          = "Warning - Init private key missing - signing with local keys disabled"
+	WarningLocalSigsNoQuorum         = "Warning - Local signatures do not meet quorum"
+)
+
+// maximum delay between retries of collecting sigs from unreachable signers
+const ATimeSignersUnreachableMax = 30 * time.Minute
+
+// Check if any signatures were received from signers
+func hasSigs(sigs [][]crypto.Sig) bool {
+	for _, inputSigs := range sigs {
+		if len(inputSigs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Return signers unreachable policy from signer config
+// Invalid values default to retrying with backoff
+func signersUnreachablePolicy(signerConfig confpkg.SignerConfig) string {
+	switch signerConfig.Unreachable {
+	case confpkg.SignersUnreachableLocal:
+		return confpkg.SignersUnreachableLocal
+	case "", confpkg.SignersUnreachableRetry:
+	default:
+		log.Printf("%s (%s)\n", WarningInvalidSignersUnreachable, signerConfig.Unreachable)
+	}
+	return confpkg.SignersUnreachableRetry
+}
+
+// Return delay until signatures are collected again after the number of
+// consecutive signing rounds provided with all signers unreachable
+// Delay doubles each round starting from the signatures delay
+func signersUnreachableDelay(atimeSigs time.Duration, rounds int) time.Duration {
+	delay := atimeSigs
+	for i := 1; i < rounds && delay < ATimeSignersUnreachableMax; i++ {
+		delay *= 2
+	}
+	if delay > ATimeSignersUnreachableMax {
+		return ATimeSignersUnreachableMax
+	}
+	return delay
+}
+
+// Return signatures of the local keys of the service for each input of the
+// attestation transaction, if the local keys meet the quorum of every input
+// The last return value is false if the local sigs do not meet quorum
+func (s *AttestService) getLocalSigs(hash chainhash.Hash) ([][]crypto.Sig, bool) {
+	if s.localSigner == nil {
+		return nil, false
+	}
+	msgTx := s.attestation.Tx
+	for i := range msgTx.TxIn {
+		// no local key for inputs signed with the topup key
+		if s.localSigner.WalletPrivTopup == nil && !s.localSigner.isSubchainInput(&msgTx, i) {
+			return nil, false
+		}
+	}
+	signedMsgTx, _, signErr := s.localSigner.SignTransaction(hash, msgTx)
+	if signErr != nil {
+		log.Printf("*AttestService* %v\n", signErr)
+		return nil, false
+	}
+
+	// local sigs are combined as received sigs when signing the attestation
+	sigs := make([][]crypto.Sig, len(signedMsgTx.TxIn))
+	for i, txIn := range signedMsgTx.TxIn {
+		sigs[i], _ = crypto.ParseScriptSig(txIn.SignatureScript)
+		if len(sigs[i]) < s.attester.numOfSigs {
+			return nil, false
+		}
+	}
+	return sigs, true
+}
+
+// Check if signers are unreachable, i.e. signers are configured but no
+// signatures were received, and handle according to the configured policy
+// Returns the sigs to sign the attestation with, i.e. the local sigs if
+// signing with local keys only, and true if sigs are collected again
+// instead, which is also the case if the local sigs do not meet quorum
+func (s *AttestService) handleSignersUnreachable(sigs [][]crypto.Sig) ([][]crypto.Sig, bool) {
+	signerConfig := s.config.SignerConfig()
+	if hasSigs(sigs) || (len(signerConfig.Signers) == 0 && signerConfig.Command == "") {
+		s.signersUnreachable = 0
+		return sigs, false
+	}
+	s.signersUnreachable++
+
+	lastCommitmentHash, latestErr := s.server.GetLatestAttestationCommitmentHash()
+	if s.setFailure(latestErr) {
+		return nil, true // will rebound to init
+	}
+
+	if signersUnreachablePolicy(signerConfig) == confpkg.SignersUnreachableLocal {
+		if localSigs, quorum := s.getLocalSigs(lastCommitmentHash); quorum {
+			log.Printf("*AttestService* %s (%d rounds) - signing with local keys only\n",
+				WarningSignersUnreachable, s.signersUnreachable)
+			return localSigs, false
+		}
+		log.Printf("*AttestService* %s - falling back to retry\n", WarningLocalSigsNoQuorum)
+	}
+
+	delay := signersUnreachableDelay(s.atimeSigs, s.signersUnreachable)
+	log.Printf("*AttestService* %s (%d rounds) - retrying in %s\n",
+		WarningSignersUnreachable, s.signersUnreachable, delay.String())
+
+	// re-publish pre signed transaction to signers that come back up
+	s.signer.ReSubscribe()
+	txPreImages, getPreImagesErr := s.attester.getTransactionPreImages(lastCommitmentHash, &s.attestation.Tx)
+	if s.setFailure(getPreImagesErr) {
+		return nil, true // will rebound to init
+	}
+	txPreImageBytes, preImageBytesErr := GetTxPreImageBytes(txPreImages, signerConfig.CompactPreImages)
+	if s.setFailure(preImageBytesErr) {
+		return nil, true // will rebound to init
+	}
+	s.signer.SendConfirmedHash((&lastCommitmentHash).CloneBytes())
+	s.signer.SendTxPreImages(txPreImageBytes)
+
+	s.state = AStateSignAttestation // collect sigs again
+	s.attestDelay = delay           // add backoff waiting time
+	return nil, true
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"testing"
+	"time"
+
+	confpkg "mainstay/config"
+	"mainstay/crypto"
+	"mainstay/server"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Attest Service signing rounds with all signers unreachable
+func TestAttestService_SignersUnreachable(t *testing.T) {
+
+	// Test INIT
+	test := test.NewTest(false, false)
+	config := test.Config
+
+	dbFake := server.NewDbFake()
+	server := server.NewServer(dbFake)
+	attestService := NewAttestService(nil, nil, server, NewAttestSignerFake(nil), config)
+
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+
+	// test sigs collected again with backoff by default
+	attestService.doAttestation()
+	assert.Equal(t, AStateSignAttestation, attestService.state)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
+	assert.Equal(t, 1, attestService.signersUnreachable)
+	attestService.doAttestation()
+	assert.Equal(t, AStateSignAttestation, attestService.state)
+	assert.Equal(t, 2*attestService.atimeSigs, attestService.attestDelay)
+	assert.Equal(t, 2, attestService.signersUnreachable)
+
+	// test signing proceeds once signers are reachable again
+	attestService.signer = NewAttestSignerFake([]*confpkg.Config{config})
+	verifyStateSignAttestationToPreSendStore(t, attestService)
+	assert.Equal(t, 0, attestService.signersUnreachable)
+
+	// test falling back to retry if the local sigs do not meet quorum
+	signerConfig := config.SignerConfig()
+	signerConfig.Unreachable = confpkg.SignersUnreachableLocal
+	config.SetSignerConfig(signerConfig)
+	attestService = NewAttestService(nil, nil, server, NewAttestSignerFake(nil), config)
+	assert.NotEqual(t, (*AttestClient)(nil), attestService.localSigner)
+	assert.Equal(t, (*btcutil.WIF)(nil), attestService.attester.WalletPriv)
+	verifyStateInit(t, attestService)
+	verifyStateInitToNextCommitment(t, attestService)
+	verifyStateNextCommitmentToNewAttestation(t, attestService, dbFake, hashX)
+	verifyStateNewAttestationToSignAttestation(t, attestService)
+	attestService.attester.numOfSigs = 2
+	attestService.doAttestation()
+	assert.Equal(t, AStateSignAttestation, attestService.state)
+	assert.Equal(t, attestService.atimeSigs, attestService.attestDelay)
+	assert.Equal(t, 1, attestService.signersUnreachable)
+
+	// test signing with local sigs only if they meet the 1 of 2 multisig quorum
+	attestService.attester.numOfSigs = 1
+	attestService.doAttestation()
+	assert.Equal(t, AStatePreSendStore, attestService.state)
+	assert.Equal(t, nil, attestService.errorState)
+	assert.Equal(t, 2, attestService.signersUnreachable)
+	for _, txIn := range attestService.attestation.Tx.TxIn {
+		sigs, _ := crypto.ParseScriptSig(txIn.SignatureScript)
+		assert.Equal(t, 1, len(sigs))
+	}
+	verifyStatePreSendStoreToSendAttestation(t, attestService)
+	verifyStateSendAttestationToAwaitConfirmation(t, attestService)
+
+	// test local signing disabled without the init private key
+	initPK := config.InitPK()
+	config.SetInitPK("")
+	attestService = NewAttestService(nil, nil, server, NewAttestSignerFake(nil), config)
+	assert.Equal(t, (*AttestClient)(nil), attestService.localSigner)
+	config.SetInitPK(initPK)
+
+	// test backoff delay capped
+	assert.Equal(t, time.Minute, signersUnreachableDelay(time.Minute, 1))
+	assert.Equal(t, 8*time.Minute, signersUnreachableDelay(time.Minute, 4))
+	assert.Equal(t, ATimeSignersUnreachableMax, signersUnreachableDelay(time.Minute, 10))
+}
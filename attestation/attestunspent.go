@@ -0,0 +1,135 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Utility functions to select the subchain unspents spent by the next attestation
+// Multiple subchain unspents can exist after topups or partial sweeps, in which
+// case the unspents spent are selected out of the subchain tip set, i.e. the
+// subchain unspents paying to the same attestation address as the latest
+// unspent, as all subchain inputs are signed with the latest commitment script
+
+// Parse unspent selection config value - defaults to the latest unspent
+func parseUnspentSelection(selection string) (string, error) {
+	switch selection {
+	case "":
+		return confpkg.UnspentSelectionLatest, nil
+	case confpkg.UnspentSelectionLatest, confpkg.UnspentSelectionLargest,
+		confpkg.UnspentSelectionOldest, confpkg.UnspentSelectionMerge:
+		return selection, nil
+	}
+	return "", errors.New(fmt.Sprintf("%s (%s)", ErrorInvalidUnspentSelection, selection))
+}
+
+// Find the subchain unspents spent by the next attestation using the unspent
+// selection strategy of the client, out of the subchain unspents not spent in
+// mempool that pay to the same attestation address as the latest unspent
+func (w *AttestClient) findNextUnspents() (bool, []btcjson.ListUnspentResult, error) {
+	subchainUnspent, err := w.findSubchainUnspents()
+	if err != nil {
+		return false, nil, err
+	}
+	found, tip, tipErr := w.selectLastUnspent(subchainUnspent)
+	if tipErr != nil || !found {
+		return false, nil, tipErr
+	}
+	if w.unspentSelection == confpkg.UnspentSelectionLatest || len(subchainUnspent) == 1 {
+		return true, []btcjson.ListUnspentResult{tip}, nil
+	}
+
+	var tipSet []btcjson.ListUnspentResult
+	for _, u := range subchainUnspent {
+		if u.ScriptPubKey != tip.ScriptPubKey {
+			continue
+		}
+		spent, spentErr := w.isSpentInMempool(u)
+		if spentErr != nil {
+			return false, nil, spentErr
+		}
+		if !spent {
+			tipSet = append(tipSet, u)
+		}
+	}
+
+	selected := selectUnspents(w.unspentSelection, tip, tipSet)
+	if verifyErr := verifyUnspentsInTipSet(selected, tipSet); verifyErr != nil {
+		return false, nil, verifyErr
+	}
+	log.Printf("*Client* Selected %d of %d subchain tip unspents (%s)\n",
+		len(selected), len(tipSet), w.unspentSelection)
+	return true, selected, nil
+}
+
+// Select unspents out of the subchain tip set using the selection strategy
+// Ties are broken by txid and vout order so that the selection is deterministic
+// When merging, the latest unspent is the first input followed by the rest of
+// the tip set, so that the staychain is followed through the first input
+func selectUnspents(selection string, tip btcjson.ListUnspentResult,
+	tipSet []btcjson.ListUnspentResult) []btcjson.ListUnspentResult {
+
+	if len(tipSet) == 0 {
+		return []btcjson.ListUnspentResult{tip}
+	}
+	sorted := make([]btcjson.ListUnspentResult, len(tipSet))
+	copy(sorted, tipSet)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TxID == sorted[j].TxID {
+			return sorted[i].Vout < sorted[j].Vout
+		}
+		return sorted[i].TxID < sorted[j].TxID
+	})
+
+	switch selection {
+	case confpkg.UnspentSelectionLargest:
+		largest := sorted[0]
+		for _, u := range sorted[1:] {
+			if u.Amount > largest.Amount {
+				largest = u
+			}
+		}
+		return []btcjson.ListUnspentResult{largest}
+	case confpkg.UnspentSelectionOldest:
+		oldest := sorted[0]
+		for _, u := range sorted[1:] {
+			if u.Confirmations > oldest.Confirmations {
+				oldest = u
+			}
+		}
+		return []btcjson.ListUnspentResult{oldest}
+	case confpkg.UnspentSelectionMerge:
+		merged := []btcjson.ListUnspentResult{tip}
+		for _, u := range sorted {
+			if u.TxID != tip.TxID || u.Vout != tip.Vout {
+				merged = append(merged, u)
+			}
+		}
+		return merged
+	}
+	return []btcjson.ListUnspentResult{tip}
+}
+
+// Verify that the selected unspents are all in the subchain tip set
+func verifyUnspentsInTipSet(selected []btcjson.ListUnspentResult, tipSet []btcjson.ListUnspentResult) error {
+	inTipSet := make(map[string]bool)
+	for _, u := range tipSet {
+		inTipSet[fmt.Sprintf("%s:%d", u.TxID, u.Vout)] = true
+	}
+	for _, u := range selected {
+		if !inTipSet[fmt.Sprintf("%s:%d", u.TxID, u.Vout)] {
+			return errors.New(fmt.Sprintf("%s (%s:%d)", ErrorUnspentNotInTipSet, u.TxID, u.Vout))
+		}
+	}
+	return nil
+}
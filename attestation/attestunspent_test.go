@@ -0,0 +1,63 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	confpkg "mainstay/config"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test selecting subchain unspents with each unspent selection strategy
+func TestAttestUnspents_Select(t *testing.T) {
+	// test parsing unspent selection config
+	selection, selectionErr := parseUnspentSelection("")
+	assert.Equal(t, nil, selectionErr)
+	assert.Equal(t, confpkg.UnspentSelectionLatest, selection)
+	selection, selectionErr = parseUnspentSelection(confpkg.UnspentSelectionMerge)
+	assert.Equal(t, nil, selectionErr)
+	assert.Equal(t, confpkg.UnspentSelectionMerge, selection)
+	_, selectionErr = parseUnspentSelection("invalid")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (invalid)", ErrorInvalidUnspentSelection)), selectionErr)
+
+	// subchain tip set with the latest unspent being neither largest nor oldest
+	tip := btcjson.ListUnspentResult{TxID: "cc", Vout: 0, Amount: 1, Confirmations: 1}
+	largest := btcjson.ListUnspentResult{TxID: "bb", Vout: 0, Amount: 5, Confirmations: 3}
+	oldest := btcjson.ListUnspentResult{TxID: "aa", Vout: 1, Amount: 2, Confirmations: 10}
+	tipSet := []btcjson.ListUnspentResult{largest, tip, oldest}
+
+	assert.Equal(t, []btcjson.ListUnspentResult{tip},
+		selectUnspents(confpkg.UnspentSelectionLatest, tip, tipSet))
+	assert.Equal(t, []btcjson.ListUnspentResult{largest},
+		selectUnspents(confpkg.UnspentSelectionLargest, tip, tipSet))
+	assert.Equal(t, []btcjson.ListUnspentResult{oldest},
+		selectUnspents(confpkg.UnspentSelectionOldest, tip, tipSet))
+	assert.Equal(t, []btcjson.ListUnspentResult{tip, oldest, largest},
+		selectUnspents(confpkg.UnspentSelectionMerge, tip, tipSet))
+
+	// test ties broken by txid order
+	equal := btcjson.ListUnspentResult{TxID: "ab", Vout: 0, Amount: 5, Confirmations: 10}
+	tipSet = append(tipSet, equal)
+	assert.Equal(t, []btcjson.ListUnspentResult{equal},
+		selectUnspents(confpkg.UnspentSelectionLargest, tip, tipSet))
+	assert.Equal(t, []btcjson.ListUnspentResult{oldest},
+		selectUnspents(confpkg.UnspentSelectionOldest, tip, tipSet))
+
+	// test selected unspents verified against the tip set
+	for _, s := range []string{confpkg.UnspentSelectionLatest, confpkg.UnspentSelectionLargest,
+		confpkg.UnspentSelectionOldest, confpkg.UnspentSelectionMerge} {
+		assert.Equal(t, nil, verifyUnspentsInTipSet(selectUnspents(s, tip, tipSet), tipSet))
+	}
+	other := btcjson.ListUnspentResult{TxID: "dd", Vout: 2}
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (dd:2)", ErrorUnspentNotInTipSet)),
+		verifyUnspentsInTipSet([]btcjson.ListUnspentResult{tip, other}, tipSet))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (cc:0)", ErrorUnspentNotInTipSet)),
+		verifyUnspentsInTipSet(selectUnspents(confpkg.UnspentSelectionMerge, tip, nil), nil))
+}
@@ -6,18 +6,19 @@ package clients
 
 import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
-	"github.com/btcsuite/btcd/wire"
 )
 
 // SidechainClient interface
 // Implements the interface for sidechain clients
-// Current logic includes getting latest block from sidechain
+// Only the methods actually used by mainstay are included:
+// - GetBestBlockHash: latest block committed by the commitment tool
+// - GetBlockHeight: height of committed block reported by the chain verifier
+// - Close: shut down the client connection
+//
+// Ocean implementation additionally provides further block and tx
+// methods, while the fake implementation is used for unit-testing
 type SidechainClient interface {
 	GetBestBlockHash() (*chainhash.Hash, error)
 	GetBlockHeight(*chainhash.Hash) (int32, error)
-	GetBlockHash(int64) (*chainhash.Hash, error)
-	GetBlock(*chainhash.Hash) (*wire.MsgBlock, error)
-	GetTxBlockHash(*chainhash.Hash) (string, error)
-	GetBlockCount() (int64, error)
 	Close()
 }
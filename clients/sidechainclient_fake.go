@@ -6,15 +6,28 @@ package clients
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 )
 
+// error consts
+const (
+	ErrorFakeBlockNotFound = "Block not found"
+	ErrorFakeTxNotFound    = "Tx not found"
+	ErrorFakeChainTip      = "Fake chain height beyond scripted blocks"
+)
+
 // SidechainClientFake structure
 // Implements fake implementation of SidechainClient for unit-testing
+// Blocks are scripted, either the default fake blocks or blocks set on
+// construction, and the chain progresses through them using Generate
+// Errors can be set to simulate sidechain client failures
 type SidechainClientFake struct {
 	height int32
+	blocks []string
+	err    error
 }
 
 // Fake chain of blocks for testing
@@ -57,7 +70,13 @@ var blockTxs = [][]string{
 
 // SidechainClientFake returns new instance of a fake SidechainClient
 func NewSidechainClientFake() *SidechainClientFake {
-	return &SidechainClientFake{0}
+	return &SidechainClientFake{0, blocks, nil}
+}
+
+// Return new instance of a fake SidechainClient with scripted blocks
+// Block hashes are in height order starting from height 0
+func NewSidechainClientFakeWithBlocks(scriptedBlocks []string) *SidechainClientFake {
+	return &SidechainClientFake{0, scriptedBlocks, nil}
 }
 
 // Close function - inherit - do nothing
@@ -70,39 +89,53 @@ func (f *SidechainClientFake) Generate(height int32) {
 	f.height += height
 }
 
-// GetBlockCount returns number of blocks in fake client
+// Set error returned by all fake client calls until reset with nil
+// Used to simulate sidechain client connection failures
+func (f *SidechainClientFake) SetError(err error) {
+	f.err = err
+}
+
+// GetBlockCount returns number of blocks up to the latest height in fake client
 func (f *SidechainClientFake) GetBlockCount() (int64, error) {
-	return int64(len(blocks)), nil
+	if f.err != nil {
+		return -1, f.err
+	}
+	return int64(f.height) + 1, nil
 }
 
 // GetBestBlockHash returns latest block from fake blocks
 func (f *SidechainClientFake) GetBestBlockHash() (*chainhash.Hash, error) {
-	hashstr := blocks[f.height]
-	hash, err := chainhash.NewHashFromStr(hashstr)
-	if err != nil {
-		return nil, err
-	}
-
-	return hash, nil
+	return f.GetBlockHash(int64(f.height))
 }
 
 // GetBlockHeight returns block height of block from fake blocks
 func (f *SidechainClientFake) GetBlockHeight(hash *chainhash.Hash) (int32, error) {
+	if f.err != nil {
+		return -1, f.err
+	}
 	hashstr := hash.String()
 
-	for i, val := range blocks {
-		if val == hashstr {
+	for i, val := range f.blocks {
+		if val == hashstr && int32(i) <= f.height {
 			return int32(i), nil
 		}
 	}
 
-	return -1, errors.New("Block not found")
+	return -1, errors.New(ErrorFakeBlockNotFound)
 }
 
 // GetBlockHash returns block hash of block from fake blocks
 func (f *SidechainClientFake) GetBlockHash(height int64) (*chainhash.Hash, error) {
-	hashstr := blocks[height]
-	hash, err := chainhash.NewHashFromStr(hashstr)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if height < 0 || height > int64(f.height) {
+		return nil, errors.New(ErrorFakeBlockNotFound)
+	}
+	if height >= int64(len(f.blocks)) {
+		return nil, errors.New(fmt.Sprintf("%s (%d)", ErrorFakeChainTip, height))
+	}
+	hash, err := chainhash.NewHashFromStr(f.blocks[height])
 	if err != nil {
 		return nil, err
 	}
@@ -117,17 +150,23 @@ func (f *SidechainClientFake) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, er
 
 // GetTxBlockHash returns block hash of fake block for fake tx
 func (f *SidechainClientFake) GetTxBlockHash(hash *chainhash.Hash) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
 	hashstr := hash.String()
 
-	for i, _ := range blocks {
+	for i := range f.blocks {
+		if i >= len(blockTxs) {
+			break
+		}
 		for _, valtx := range blockTxs[i] {
 			if valtx == hashstr {
-				return blocks[i], nil
+				return f.blocks[i], nil
 			}
 		}
 	}
 
-	return "", errors.New("Tx not found")
+	return "", errors.New(ErrorFakeTxNotFound)
 }
 
 // GetBlockTxs returns the fake txs for a fake block hash
@@ -136,6 +175,9 @@ func (f *SidechainClientFake) GetBlockTxs(hash *chainhash.Hash) ([]string, error
 	if err != nil {
 		return []string{}, err
 	}
+	if int(blockheight) >= len(blockTxs) {
+		return []string{}, nil
+	}
 
 	return blockTxs[blockheight], nil
 }
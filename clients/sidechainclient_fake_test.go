@@ -0,0 +1,78 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test fake sidechain client chain progression through scripted blocks
+func TestSidechainClientFake_Blocks(t *testing.T) {
+	scriptedBlocks := []string{
+		"1111111111111111111111111111111111111111111111111111111111111111",
+		"2222222222222222222222222222222222222222222222222222222222222222",
+	}
+	var client SidechainClient = NewSidechainClientFakeWithBlocks(scriptedBlocks)
+	fake := client.(*SidechainClientFake)
+
+	// test best block at initial height
+	hash, hashErr := client.GetBestBlockHash()
+	assert.Equal(t, nil, hashErr)
+	assert.Equal(t, scriptedBlocks[0], hash.String())
+	height, heightErr := client.GetBlockHeight(hash)
+	assert.Equal(t, nil, heightErr)
+	assert.Equal(t, int32(0), height)
+	count, countErr := fake.GetBlockCount()
+	assert.Equal(t, nil, countErr)
+	assert.Equal(t, int64(1), count)
+
+	// test block not found before chain progresses
+	nextHash, _ := chainhash.NewHashFromStr(scriptedBlocks[1])
+	_, heightErr = client.GetBlockHeight(nextHash)
+	assert.Equal(t, errors.New(ErrorFakeBlockNotFound), heightErr)
+
+	// test chain progression
+	fake.Generate(1)
+	hash, hashErr = client.GetBestBlockHash()
+	assert.Equal(t, nil, hashErr)
+	assert.Equal(t, scriptedBlocks[1], hash.String())
+	height, heightErr = client.GetBlockHeight(nextHash)
+	assert.Equal(t, nil, heightErr)
+	assert.Equal(t, int32(1), height)
+
+	// test progression beyond scripted blocks
+	fake.Generate(1)
+	_, hashErr = client.GetBestBlockHash()
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d)", ErrorFakeChainTip, 2)), hashErr)
+}
+
+// Test fake sidechain client simulating errors
+func TestSidechainClientFake_Errors(t *testing.T) {
+	fake := NewSidechainClientFake()
+	hash, hashErr := fake.GetBestBlockHash()
+	assert.Equal(t, nil, hashErr)
+	assert.Equal(t, blocks[0], hash.String())
+
+	// test all calls fail while error set
+	clientErr := errors.New("connection refused")
+	fake.SetError(clientErr)
+	_, hashErr = fake.GetBestBlockHash()
+	assert.Equal(t, clientErr, hashErr)
+	_, heightErr := fake.GetBlockHeight(hash)
+	assert.Equal(t, clientErr, heightErr)
+	_, countErr := fake.GetBlockCount()
+	assert.Equal(t, clientErr, countErr)
+
+	// test recovery after error reset
+	fake.SetError(nil)
+	height, heightErr := fake.GetBlockHeight(hash)
+	assert.Equal(t, nil, heightErr)
+	assert.Equal(t, int32(0), height)
+}
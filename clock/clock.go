@@ -0,0 +1,40 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"time"
+)
+
+// Clock interface
+//
+// Provides the current time and timers firing after a duration
+// This interface allows replacing wall-clock time in unit-tests
+type Clock interface {
+	// current time
+	Now() time.Time
+
+	// channel receiving the current time once the duration elapses
+	After(time.Duration) <-chan time.Time
+}
+
+// ClockReal structure
+// Implements Clock interface using wall-clock time
+type ClockReal struct{}
+
+// Return new ClockReal instance
+func NewClockReal() *ClockReal {
+	return &ClockReal{}
+}
+
+// Get current wall-clock time
+func (c *ClockReal) Now() time.Time {
+	return time.Now()
+}
+
+// Get channel receiving the time after the duration elapses
+func (c *ClockReal) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// fake clock timer waiting for a deadline
+type timerFake struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// ClockFake structure
+// Implements Clock interface with time that only moves when
+// advanced explicitly, for deterministic unit-testing
+type ClockFake struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []timerFake
+}
+
+// Return new ClockFake instance set to the time provided
+func NewClockFake(now time.Time) *ClockFake {
+	return &ClockFake{now: now}
+}
+
+// Get current fake time
+func (c *ClockFake) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Get channel receiving the fake time once advanced past the duration
+// Non positive durations fire immediately
+func (c *ClockFake) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, timerFake{c.now.Add(d), ch})
+	return ch
+}
+
+// Advance fake time by a duration firing all timers that expire
+func (c *ClockFake) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	var pending []timerFake
+	for _, timer := range c.timers {
+		if !timer.deadline.After(c.now) {
+			timer.ch <- c.now
+		} else {
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+}
+
+// Get number of timers waiting to fire
+func (c *ClockFake) Waiting() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test fake clock time and timers
+func TestClockFake(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClockFake(start)
+	assert.Equal(t, start, clock.Now())
+
+	// test non positive duration fires immediately
+	assert.Equal(t, start, <-clock.After(0))
+	assert.Equal(t, 0, clock.Waiting())
+
+	// test timers fire only when advanced past their deadline
+	after1 := clock.After(time.Minute)
+	after2 := clock.After(2 * time.Minute)
+	assert.Equal(t, 2, clock.Waiting())
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), clock.Now())
+	assert.Equal(t, 0, len(after1))
+	assert.Equal(t, 2, clock.Waiting())
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-after1)
+	assert.Equal(t, 0, len(after2))
+	assert.Equal(t, 1, clock.Waiting())
+
+	clock.Advance(5 * time.Minute)
+	assert.Equal(t, start.Add(6*time.Minute), <-after2)
+	assert.Equal(t, 0, clock.Waiting())
+}
@@ -0,0 +1,8 @@
+/*
+Package clock implements an interface for reading time and waiting on timers.
+
+Time based logic of the attestation service, the server and the tools uses the clock interface instead of wall-clock time directly.
+
+A controllable mock clock for unit-testing time based logic without sleeps is also implemented.
+*/
+package clock
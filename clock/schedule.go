@@ -0,0 +1,147 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Utility functions to parse cron-like schedules of fixed UTC times
+// A schedule consists of the five standard cron fields separated by spaces:
+// minute (0-59) hour (0-23) day of month (1-31) month (1-12) day of week (0-6)
+// Each field is either *, a value, a range a-b, a step */n or a-b/n, or a comma
+// separated list of these, e.g. "0 * * * *" for the top of every hour
+
+// error consts
+const (
+	ErrorScheduleInvalid = "Invalid schedule"
+)
+
+// number of years searched for the next schedule time
+// before the schedule is considered to never fire
+const scheduleSearchYears = 5
+
+// schedule field bounds
+var scheduleFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// Schedule structure
+// Parsed cron-like schedule of UTC times
+type Schedule struct {
+	// allowed values of each field
+	minutes    map[int]bool
+	hours      map[int]bool
+	daysOfMon  map[int]bool
+	months     map[int]bool
+	daysOfWeek map[int]bool
+
+	// day of month and day of week restricted
+	// in which case either of the two matching is enough
+	domRestricted bool
+	dowRestricted bool
+}
+
+// Return new Schedule parsed from the cron-like expression provided
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(scheduleFieldBounds) {
+		return nil, errors.New(fmt.Sprintf("%s (%s): expected %d fields", ErrorScheduleInvalid,
+			expr, len(scheduleFieldBounds)))
+	}
+
+	var values [5]map[int]bool
+	for i, field := range fields {
+		fieldValues, fieldErr := parseScheduleField(field, scheduleFieldBounds[i][0], scheduleFieldBounds[i][1])
+		if fieldErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s (%s): %v", ErrorScheduleInvalid, expr, fieldErr))
+		}
+		values[i] = fieldValues
+	}
+
+	return &Schedule{
+		minutes:       values[0],
+		hours:         values[1],
+		daysOfMon:     values[2],
+		months:        values[3],
+		daysOfWeek:    values[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// Parse a schedule field into the set of values allowed within the bounds
+func parseScheduleField(field string, min int, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangeStr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			stepInt, stepErr := strconv.Atoi(part[i+1:])
+			if stepErr != nil || stepInt <= 0 {
+				return nil, errors.New(fmt.Sprintf("invalid step %s", part))
+			}
+			rangeStr, step = part[:i], stepInt
+		}
+
+		start, end := min, max
+		if rangeStr != "*" {
+			bounds := strings.SplitN(rangeStr, "-", 2)
+			var startErr, endErr error
+			start, startErr = strconv.Atoi(bounds[0])
+			end = start
+			if len(bounds) == 2 {
+				end, endErr = strconv.Atoi(bounds[1])
+			}
+			if startErr != nil || endErr != nil || start < min || end > max || start > end {
+				return nil, errors.New(fmt.Sprintf("invalid value %s", part))
+			}
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Check whether the day of the time provided matches the schedule
+// If both the day of month and day of week are restricted,
+// as in cron, either of the two matching is enough
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := s.daysOfMon[t.Day()]
+	dow := s.daysOfWeek[int(t.Weekday())]
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Return the first schedule time strictly after the time provided in UTC
+// The zero time is returned if the schedule never fires, e.g. on 30 February
+func (s *Schedule) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(scheduleSearchYears, 0, 0)
+	for t.Before(limit) {
+		if !s.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
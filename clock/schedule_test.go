@@ -0,0 +1,72 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test parsing schedules and finding the next schedule time
+func TestSchedule(t *testing.T) {
+	start := time.Date(2019, 1, 1, 10, 30, 15, 0, time.UTC) // Tuesday
+
+	// test top of every hour
+	schedule, scheduleErr := ParseSchedule("0 * * * *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 1, 11, 0, 0, 0, time.UTC), schedule.Next(start))
+	assert.Equal(t, time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC),
+		schedule.Next(time.Date(2019, 1, 1, 11, 0, 0, 0, time.UTC)))
+
+	// test steps, ranges and lists
+	schedule, scheduleErr = ParseSchedule("*/15 9-17 * * *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 1, 10, 45, 0, 0, time.UTC), schedule.Next(start))
+	assert.Equal(t, time.Date(2019, 1, 2, 9, 0, 0, 0, time.UTC),
+		schedule.Next(time.Date(2019, 1, 1, 17, 45, 0, 0, time.UTC)))
+	schedule, scheduleErr = ParseSchedule("0,30 6,18 * * *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 1, 18, 0, 0, 0, time.UTC), schedule.Next(start))
+
+	// test times in other locations converted to UTC
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, scheduleErr = ParseSchedule("0 0 * * *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), schedule.Next(start.In(location)))
+
+	// test day of week, day of month and month
+	schedule, scheduleErr = ParseSchedule("0 12 * * 0")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 6, 12, 0, 0, 0, time.UTC), schedule.Next(start))
+	schedule, scheduleErr = ParseSchedule("0 0 1 3 *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC), schedule.Next(start))
+
+	// test either day of month or day of week matching when both restricted
+	schedule, scheduleErr = ParseSchedule("0 0 15 * 5")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Date(2019, 1, 4, 0, 0, 0, 0, time.UTC), schedule.Next(start))
+	assert.Equal(t, time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC),
+		schedule.Next(time.Date(2019, 1, 11, 0, 0, 0, 0, time.UTC)))
+
+	// test schedule never firing
+	schedule, scheduleErr = ParseSchedule("0 0 30 2 *")
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, time.Time{}, schedule.Next(start))
+
+	// test invalid schedules
+	_, scheduleErr = ParseSchedule("0 * * *")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (0 * * *): expected 5 fields", ErrorScheduleInvalid)), scheduleErr)
+	_, scheduleErr = ParseSchedule("60 * * * *")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (60 * * * *): invalid value 60", ErrorScheduleInvalid)), scheduleErr)
+	_, scheduleErr = ParseSchedule("*/0 * * * *")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (*/0 * * * *): invalid step */0", ErrorScheduleInvalid)), scheduleErr)
+	_, scheduleErr = ParseSchedule("0 5-2 * * *")
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (0 5-2 * * *): invalid value 5-2", ErrorScheduleInvalid)), scheduleErr)
+}
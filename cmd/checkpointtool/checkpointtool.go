@@ -0,0 +1,82 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Checkpoint tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const ConfPath = "/src/mainstay/cmd/checkpointtool/conf.json"
+
+var (
+	txid       string
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&txid, "txid", "", "Attestation txid to set as the new staychain checkpoint")
+	flag.Parse()
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	server := server.NewServer(dbMongo)
+
+	// print current checkpoint
+	checkpoint, checkpointErr := server.GetCheckpoint()
+	if checkpointErr != nil {
+		log.Fatal(checkpointErr)
+	}
+	if (checkpoint == chainhash.Hash{}) {
+		fmt.Println("no checkpoint recorded")
+	} else {
+		fmt.Printf("current checkpoint: %s\n", checkpoint.String())
+	}
+	if txid == "" {
+		return
+	}
+
+	// verify new checkpoint on the subchain from the current checkpoint
+	newCheckpoint, hashErr := chainhash.NewHashFromStr(txid)
+	if hashErr != nil {
+		log.Fatal(hashErr)
+	}
+	attester := attestation.NewAttestClient(mainConfig)
+	attester.SetCheckpoint(checkpoint)
+	if verifyErr := attester.VerifyCheckpoint(*newCheckpoint); verifyErr != nil {
+		log.Fatal(verifyErr)
+	}
+
+	if saveErr := server.SaveCheckpoint(*newCheckpoint); saveErr != nil {
+		log.Fatal(saveErr)
+	}
+	fmt.Printf("new checkpoint: %s\n", newCheckpoint.String())
+}
@@ -15,6 +15,7 @@ import (
 	"os"
 
 	"mainstay/config"
+	"mainstay/crypto/schnorr"
 	"mainstay/models"
 	"mainstay/server"
 
@@ -55,12 +56,20 @@ func printClientDetails() {
 		return
 	}
 	for _, client := range details {
-		fmt.Printf("client_position: %d pubkey: %s name: %s\n",
-			client.ClientPosition, client.Pubkey, client.ClientName)
+		fmt.Printf("client_position: %d pubkey: %s name: %s scheme: %s\n",
+			client.ClientPosition, client.Pubkey, client.ClientName, signatureScheme(client))
 	}
 	fmt.Println()
 }
 
+// get client signature scheme - ECDSA if not set
+func signatureScheme(client models.ClientDetails) string {
+	if client.SignatureScheme == "" {
+		return models.SignatureSchemeECDSA
+	}
+	return client.SignatureScheme
+}
+
 // read client details and get client position
 func clientPosition() int32 {
 	// Read existing clients and get next available client position
@@ -103,6 +112,12 @@ func main() {
 	fmt.Println("************ Client Pubkey Info *************")
 	fmt.Println("*********************************************")
 	fmt.Println()
+	fmt.Print("Insert signature scheme (ecdsa|schnorr) [ecdsa]: ")
+	var scheme string
+	fmt.Scanln(&scheme)
+	if scheme == "" {
+		scheme = models.SignatureSchemeECDSA
+	}
 	fmt.Print("Insert pubkey: ")
 	var pubKey string
 	fmt.Scanln(&pubKey)
@@ -110,11 +125,29 @@ func main() {
 	if pubKeyBytesErr != nil {
 		log.Fatal(pubKeyBytesErr)
 	}
-	_, errPub := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	var errPub error
+	switch scheme {
+	case models.SignatureSchemeECDSA:
+		_, errPub = btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+	case models.SignatureSchemeSchnorr:
+		_, errPub = schnorr.ParsePubKey(pubKeyBytes)
+	default:
+		log.Fatal(fmt.Sprintf("Invalid signature scheme ('%s'). 'ecdsa' and 'schnorr' allowed only.", scheme))
+	}
 	if errPub != nil {
 		log.Fatal(errPub)
 	}
 	fmt.Println("pubkey verified")
+	fmt.Print("Insert commitment kind (blockhash|custom) [custom]: ")
+	var kind string
+	fmt.Scanln(&kind)
+	switch kind {
+	case "":
+		kind = models.CommitmentKindCustom
+	case models.CommitmentKindBlockhash, models.CommitmentKindCustom:
+	default:
+		log.Fatal(fmt.Sprintf("Invalid commitment kind ('%s'). 'blockhash' and 'custom' allowed only.", kind))
+	}
 	fmt.Println()
 
 	// New auth token ID for client
@@ -142,10 +175,12 @@ func main() {
 	clientName := scanner.Text()
 
 	newClientDetails := models.ClientDetails{
-		ClientPosition: nextClientPosition,
-		AuthToken:      uuid.String(),
-		Pubkey:         pubKey,
-		ClientName:     clientName}
+		ClientPosition:  nextClientPosition,
+		AuthToken:       uuid.String(),
+		Pubkey:          pubKey,
+		ClientName:      clientName,
+		SignatureScheme: scheme,
+		CommitmentKind:  kind}
 	saveErr := dbMongo.SaveClientDetails(newClientDetails)
 	if saveErr != nil {
 		log.Fatal(saveErr)
@@ -154,6 +189,8 @@ func main() {
 	fmt.Printf("client_position: %d\n", newClientDetails.ClientPosition)
 	fmt.Printf("auth_token: %s\n", newClientDetails.AuthToken)
 	fmt.Printf("pubkey: %s\n", newClientDetails.Pubkey)
+	fmt.Printf("signature scheme: %s\n", newClientDetails.SignatureScheme)
+	fmt.Printf("commitment kind: %s\n", newClientDetails.CommitmentKind)
 	fmt.Println()
 	printClientDetails()
 }
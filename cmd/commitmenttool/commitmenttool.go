@@ -8,19 +8,28 @@ package main
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	b64 "encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"mainstay/clock"
 	"mainstay/config"
+	"mainstay/crypto/schnorr"
+	"mainstay/models"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -28,12 +37,28 @@ import (
 
 // consts
 const (
-	DefaultApiHost       = "https://mainstay.xyz"    // testnet mainstay url
-	ApiCommitmentSendUrl = "/api/v1/commitment/send" // url to send commitments to
+	DefaultApiHost        = "https://mainstay.xyz" // testnet mainstay url
+	DefaultApiBasePath    = "/api"                 // base path of the mainstay API
+	DefaultApiVersion     = "v1"                   // mainstay API version
+	ApiCommitmentSendPath = "/commitment/send"     // versioned path to send commitments to
+
+	// header carrying the API version of requests and responses
+	// used to check that the tool and the API agree on the version
+	ApiVersionHeader = "X-MAINSTAY-API-VERSION"
 
 	// config for sidechain connectivity (optional)
 	ClientChainName = "ocean"
 	ConfPath        = "/src/mainstay/cmd/commitmenttool/conf.json"
+
+	// commitment byte order options
+	// big endian is the display order of hashes, i.e. blockhash hex strings,
+	// and the order in which the Mainstay API parses received commitments
+	// little endian is the internal byte order of hashes, i.e. chainhash bytes
+	EndiannessBig    = "big"
+	EndiannessLittle = "little"
+
+	// timeout of blockhash requests to the sidechain HTTP API
+	BlockhashUrlTimeout = 30 * time.Second
 )
 
 // vars
@@ -42,32 +67,283 @@ var (
 	isInit  bool   // init flag
 	isOcean bool   // ocean flag
 	delay   int    // commitment delay
+	jitter  int    // commitment delay jitter percentage
+	isAlign bool   // align commitments to fixed schedule flag
+
+	scheduleExpr   string          // cron-like schedule of commitment times
+	missedSchedule string          // handling of missed schedule times
+	schedule       *clock.Schedule // parsed commitment schedule
+	scheduleTime   time.Time       // latest commitment schedule time
+
+	apiBasePath string // mainstay API base path
+	apiVersion  string // mainstay API version
+
+	endianness    string // commitment byte order
+	scheme        string // commitment signature scheme
+	messagePrefix string // commitment signature message prefix
 
 	position  int    // client position
 	authtoken string // client authorisation token
 	privkey   string // client private key
+
+	caCert     string // CA bundle for verifying the API host
+	clientCert string // client certificate for TLS authentication
+	clientKey  string // client certificate key for TLS authentication
+
+	blockhashUrl   string // sidechain HTTP API url returning the latest blockhash
+	blockhashField string // JSON field path of the blockhash in the HTTP API response
+
+	httpClient *http.Client // http client used to send commitments
+
+	toolClock clock.Clock = clock.NewClockReal() // clock timing commitments in ocean mode
 )
 
 // init
 func init() {
 	// basic configurations
 	flag.StringVar(&apiHost, "apiHost", DefaultApiHost, "Host address for mainstay API")
+	flag.StringVar(&apiBasePath, "apiBasePath", DefaultApiBasePath, "Base path of mainstay API endpoints")
+	flag.StringVar(&apiVersion, "apiVersion", DefaultApiVersion, "Version of mainstay API endpoints, e.g. v1")
 
 	// mode options
 	flag.BoolVar(&isInit, "init", false, "Init mode")
 	flag.BoolVar(&isOcean, "ocean", false, "Ocean mode")
 	flag.IntVar(&delay, "delay", 60, "Delay in minutes between commitments")
+	flag.IntVar(&jitter, "jitter", 0, "Random jitter in percentage of the delay added to or subtracted from the delay between commitments")
+	flag.BoolVar(&isAlign, "align", false, "Align commitments to a fixed schedule of multiples of the delay")
+	flag.StringVar(&scheduleExpr, "schedule", "", "Cron-like schedule of UTC commitment times used instead of the delay, e.g. '0 * * * *'")
+	flag.StringVar(&missedSchedule, "missedSchedule", config.MissedScheduleSkip, "Handling of missed schedule times (skip|catchup)")
+	flag.StringVar(&endianness, "endianness", EndiannessBig, "Commitment byte order for signing and sending (big|little)")
+	flag.StringVar(&scheme, "scheme", models.SignatureSchemeECDSA, "Commitment signature scheme (ecdsa|schnorr)")
+	flag.StringVar(&messagePrefix, "messagePrefix", "", "Prefix of the signed commitment message for domain separation, empty to sign the raw commitment")
 
 	// commitment variables
 	flag.IntVar(&position, "position", -1, "Client merkle commitment position")
 	flag.StringVar(&authtoken, "authtoken", "", "Client authorization token")
 	flag.StringVar(&privkey, "privkey", "", "Client private key for signing")
+
+	// http transport options
+	flag.StringVar(&caCert, "caCert", "", "Path to CA bundle for verifying the API host")
+	flag.StringVar(&clientCert, "clientCert", "", "Path to client certificate for TLS authentication")
+	flag.StringVar(&clientKey, "clientKey", "", "Path to client certificate key for TLS authentication")
+
+	// sidechain http api options
+	flag.StringVar(&blockhashUrl, "blockhashUrl", "", "Sidechain HTTP API url to fetch the latest blockhash from in ocean mode instead of RPC")
+	flag.StringVar(&blockhashField, "blockhashField", "", "Dot separated JSON field path of the blockhash in the HTTP API response, empty for plain text responses")
 	flag.Parse()
+
+	if endianness != EndiannessBig && endianness != EndiannessLittle {
+		log.Fatal(fmt.Sprintf("Invalid -endianness ('%s'). 'big' and 'little' allowed only.", endianness))
+	}
+	if !regexp.MustCompile(`^v[0-9]+$`).MatchString(apiVersion) {
+		log.Fatal(fmt.Sprintf("Invalid -apiVersion ('%s'). Version of the form 'v1' allowed only.", apiVersion))
+	}
+	if scheme != models.SignatureSchemeECDSA && scheme != models.SignatureSchemeSchnorr {
+		log.Fatal(fmt.Sprintf("Invalid -scheme ('%s'). 'ecdsa' and 'schnorr' allowed only.", scheme))
+	}
+	if isOcean && delay <= 0 {
+		log.Fatal(fmt.Sprintf("Invalid -delay (%d). Positive integer allowed only.", delay))
+	}
+	if jitter < 0 || jitter > 100 {
+		log.Fatal(fmt.Sprintf("Invalid -jitter (%d). Integer between 0 and 100 allowed only.", jitter))
+	}
+	if scheduleExpr != "" {
+		var scheduleErr error
+		schedule, scheduleErr = clock.ParseSchedule(scheduleExpr)
+		if scheduleErr != nil {
+			log.Fatal(scheduleErr)
+		}
+		if isAlign {
+			log.Fatal("Invalid -align. Not allowed with -schedule.")
+		}
+	}
+	if missedSchedule != config.MissedScheduleSkip && missedSchedule != config.MissedScheduleCatchUp {
+		log.Fatal(fmt.Sprintf("Invalid -missedSchedule ('%s'). 'skip' and 'catchup' allowed only.", missedSchedule))
+	}
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Get sleep time until the next commitment in ocean mode
+// If aligned, commitments are made at fixed wall clock times that are
+// multiples of the delay, if scheduled, at the next schedule time,
+// otherwise the delay is counted from now
+// A random jitter of up to the jitter percentage of the delay is then added
+// or subtracted so that commitments of multiple clients are spread out
+func nextSleepTime(now time.Time) time.Duration {
+	interval := time.Duration(delay) * time.Minute
+	sleepTime := interval
+	if schedule != nil {
+		sleepTime = nextScheduleSleepTime(now)
+	} else if isAlign {
+		sleepTime = now.Truncate(interval).Add(interval).Sub(now)
+	}
+	if jitter > 0 {
+		maxJitter := int64(interval) * int64(jitter) / 100
+		sleepTime += time.Duration(rand.Int63n(2*maxJitter+1) - maxJitter)
+	}
+	if sleepTime < 0 {
+		sleepTime = 0
+	}
+	return sleepTime
+}
+
+// Get sleep time until the next schedule time in ocean mode
+// Schedule times missed since the latest schedule time, e.g. due to a slow
+// commitment, are either skipped or caught up immediately once
+func nextScheduleSleepTime(now time.Time) time.Duration {
+	next := schedule.Next(now)
+	if !scheduleTime.IsZero() && !schedule.Next(scheduleTime).After(now) {
+		log.Printf("Schedule time missed: %s\n", schedule.Next(scheduleTime).Format(time.RFC3339))
+		if missedSchedule == config.MissedScheduleCatchUp {
+			scheduleTime = now
+			return 0
+		}
+	}
+	scheduleTime = next
+	return next.Sub(now)
+}
+
+// Get the url of a mainstay API endpoint path for the base path and version set
+// e.g. https://mainstay.xyz/api/v1/commitment/send for the default options
+func apiUrl(path string) string {
+	basePath := strings.Trim(apiBasePath, "/")
+	if basePath != "" {
+		basePath = "/" + basePath
+	}
+	return fmt.Sprintf("%s%s/%s%s", strings.TrimRight(apiHost, "/"), basePath, apiVersion, path)
+}
+
+// Check that the API serves the version requested by the tool
+// A versioned endpoint that is not found means the API does not serve the
+// version, while a version header in the response must match the version
+// requested, so that the tool and API can be upgraded independently
+func checkApiVersion(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New(fmt.Sprintf("API version %s not served at %s", apiVersion, resp.Request.URL.String()))
+	}
+	respVersion := resp.Header.Get(ApiVersionHeader)
+	if respVersion != "" && respVersion != apiVersion {
+		return errors.New(fmt.Sprintf("API version mismatch (tool %s, API %s)", apiVersion, respVersion))
+	}
+	return nil
+}
+
+// Get commitment bytes of hash in the byte order set by the endianness flag
+// The same bytes are signed and sent hex encoded to the Mainstay API
+func commitmentBytesFromHash(hash chainhash.Hash) []byte {
+	if endianness == EndiannessLittle {
+		return hash.CloneBytes()
+	}
+	// big endian - reverse of chainhash bytes as hashes are displayed
+	displayBytes, _ := hex.DecodeString(hash.String())
+	return displayBytes
+}
+
+// Construct the http client used to send commitments to Mainstay API
+// Proxy settings are taken from the standard HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY environment variables. Optionally a CA bundle and a
+// client certificate/key pair can be provided for TLS connections
+func newHttpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{}
+
+	if caCert != "" {
+		caCertBytes, caCertErr := ioutil.ReadFile(caCert)
+		if caCertErr != nil {
+			return nil, caCertErr
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCertBytes) {
+			return nil, errors.New(fmt.Sprintf("Invalid CA bundle %s", caCert))
+		}
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, errors.New("Need to provide both -clientCert and -clientKey")
+		}
+		cert, certErr := tls.LoadX509KeyPair(clientCert, clientKey)
+		if certErr != nil {
+			return nil, certErr
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// Get the blockhash field from a JSON response following a dot
+// separated field path, e.g. "data.tip.hash" or "blocks.0.id"
+func blockhashFromJson(body []byte, fieldPath string) (string, error) {
+	var value interface{}
+	if unmarshalErr := json.Unmarshal(body, &value); unmarshalErr != nil {
+		return "", unmarshalErr
+	}
+	for _, field := range strings.Split(fieldPath, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			fieldValue, ok := v[field]
+			if !ok {
+				return "", errors.New(fmt.Sprintf("Field %s missing from response", field))
+			}
+			value = fieldValue
+		case []interface{}:
+			index, indexErr := strconv.Atoi(field)
+			if indexErr != nil || index < 0 || index >= len(v) {
+				return "", errors.New(fmt.Sprintf("Invalid index %s in response", field))
+			}
+			value = v[index]
+		default:
+			return "", errors.New(fmt.Sprintf("Field %s missing from response", field))
+		}
+	}
+	blockhash, ok := value.(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Field %s not a string", fieldPath))
+	}
+	return blockhash, nil
+}
+
+// Get the latest sidechain blockhash from an HTTP API, e.g. a block explorer
+// The blockhash is read from the JSON field path set, or the whole
+// response body is used if no field path is set
+func getBlockhashFromUrl() (*chainhash.Hash, error) {
+	client := &http.Client{Timeout: BlockhashUrlTimeout}
+	resp, getErr := client.Get(blockhashUrl)
+	if getErr != nil {
+		return nil, getErr
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("Response status %s", resp.Status))
+	}
+	body, readErr := ioutil.ReadAll(resp.Body)
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	blockhash := strings.TrimSpace(string(body))
+	if blockhashField != "" {
+		var fieldErr error
+		blockhash, fieldErr = blockhashFromJson(body, blockhashField)
+		if fieldErr != nil {
+			return nil, fieldErr
+		}
+	}
+	if len(blockhash) != chainhash.MaxHashStringSize {
+		return nil, errors.New(fmt.Sprintf("Invalid blockhash ('%s')", blockhash))
+	}
+	return chainhash.NewHashFromStr(blockhash)
 }
 
 // Init mode
-// Generate new ECDSA priv-pub key pair for the client to use
+// Generate new priv-pub key pair for the client to use
 // when signing new commitments and sending to Mainstay API
+// The x-only pubkey is generated for the schnorr scheme
 func doInitMode() {
 	fmt.Println("****************************")
 	fmt.Println("****** Init mode ***********")
@@ -82,6 +358,9 @@ func doInitMode() {
 	newPrivBytesStr := hex.EncodeToString(newPriv.Serialize())
 	fmt.Printf("generated priv: %s\n", newPrivBytesStr)
 	newPubBytesStr := hex.EncodeToString(newPriv.PubKey().SerializeCompressed())
+	if scheme == models.SignatureSchemeSchnorr {
+		newPubBytesStr = hex.EncodeToString(schnorr.PubKey(newPriv.PubKey()))
+	}
 	fmt.Printf("generated pub: %s\n", newPubBytesStr)
 
 	fmt.Printf("The private key should be used for signing future client commitments\n")
@@ -95,7 +374,7 @@ func doInitMode() {
 // - pubkey (serialized hex format compressed or uncompressed)
 // - authtoken (authorization token generated on signup)
 // - msg (32 byte hash commitment in hex encoded string)
-// - signature (ECDSA signature encoded to base64)
+// - signature (ECDSA or schnorr signature encoded to base64)
 func send(sig []byte, msg string) error {
 
 	// construct payload and signature and bring to base64 format
@@ -107,11 +386,11 @@ func send(sig []byte, msg string) error {
 		payload64, sig64)
 
 	// send post request along with chunk as body
-	url := fmt.Sprintf("%s%s", apiHost, ApiCommitmentSendUrl)
+	url := apiUrl(ApiCommitmentSendPath)
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(chunk)))
+	req.Header.Set(ApiVersionHeader, apiVersion)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		panic(err)
 	}
@@ -119,6 +398,11 @@ func send(sig []byte, msg string) error {
 
 	fmt.Println("response Status:", resp.Status)
 
+	// check tool and API agree on the API version
+	if versionErr := checkApiVersion(resp); versionErr != nil {
+		return versionErr
+	}
+
 	// check status response
 	if resp.StatusCode == 200 {
 		dec := json.NewDecoder(resp.Body)
@@ -137,9 +421,13 @@ func send(sig []byte, msg string) error {
 	return errors.New(fmt.Sprintf("Response status %s", resp.Status))
 }
 
-// Decode private key and get btcec ECDSA key
+// Decode private key and get btcec key
 // Sign received byte message with private key
+// using the signature scheme set by the scheme flag
+// The message is prefixed if the message prefix flag is set
 func sign(msg []byte) []byte {
+	msg = models.CommitmentSignatureMessage(msg, messagePrefix)
+
 	// try key decoding
 	privkeyBytes, decodeErr := hex.DecodeString(privkey)
 	if decodeErr != nil {
@@ -148,6 +436,13 @@ func sign(msg []byte) []byte {
 	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privkeyBytes)
 
 	// sign message
+	if scheme == models.SignatureSchemeSchnorr {
+		sig, signErr := schnorr.Sign(privKey, msg)
+		if signErr != nil {
+			log.Fatal(fmt.Sprintf("Signing error: %v\n", signErr))
+		}
+		return sig
+	}
 	sig, signErr := privKey.Sign(msg)
 	if signErr != nil {
 		log.Fatal(fmt.Sprintf("Signing error: %v\n", signErr))
@@ -158,6 +453,8 @@ func sign(msg []byte) []byte {
 // Ocean mode
 // Recurrent commitments of Ocean blockhash to Mainstay API
 // At regular intervals, fetch commitment, sign and send
+// The blockhash is fetched via RPC or, if a blockhash url is set,
+// from the sidechain HTTP API without requiring access to a node
 func doOceanMode() {
 	fmt.Println("****************************")
 	fmt.Println("****** Ocean mode **********")
@@ -168,44 +465,46 @@ func doOceanMode() {
 		log.Fatal("Need to provide -privkey.")
 	}
 
-	// get conf file
-	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
-	if confErr != nil {
-		log.Fatal(confErr)
+	// get next blockhash from the sidechain HTTP API if set
+	// otherwise from the ocean sidechain client from config
+	getBlockhash := getBlockhashFromUrl
+	if blockhashUrl == "" {
+		confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+		if confErr != nil {
+			log.Fatal(confErr)
+		}
+		client := config.NewClientFromConfig(ClientChainName, false, confFile)
+		getBlockhash = client.GetBestBlockHash
 	}
 
-	// get ocean sidechain client from config
-	client := config.NewClientFromConfig(ClientChainName, false, confFile)
-
 	sleepTime := 0 * time.Second // start immediately
 	for {
-		timer := time.NewTimer(sleepTime)
 		select {
-		case <-timer.C:
+		case <-toolClock.After(sleepTime):
 			fmt.Println("Fetching next blockhash commitment...")
 
 			// get next blockhash
-			blockhash, blockhashErr := client.GetBestBlockHash()
+			blockhash, blockhashErr := getBlockhash()
 			if blockhashErr != nil {
 				log.Fatal(fmt.Sprintf("Client fetching error: %v\n", blockhashErr))
 			}
 			fmt.Println("Commitment: ", blockhash.String())
 
-			// get reverse blockhash bytes as this is how blockhashes are displayed
-			revBlockHashBytes, _ := hex.DecodeString(blockhash.String())
+			// get blockhash bytes in the byte order set - display order by default
+			blockHashBytes := commitmentBytesFromHash(*blockhash)
 
 			// sign commitment
-			sigBytes := sign(revBlockHashBytes)
+			sigBytes := sign(blockHashBytes)
 
 			// send signed commitment
-			sendErr := send(sigBytes, hex.EncodeToString(revBlockHashBytes))
+			sendErr := send(sigBytes, hex.EncodeToString(blockHashBytes))
 			if sendErr != nil {
 				log.Fatal(fmt.Sprintf("Commitment send error: %v\n", sendErr))
 			} else {
 				fmt.Println("Success!")
 			}
 
-			sleepTime = time.Duration(delay) * time.Minute
+			sleepTime = nextSleepTime(toolClock.Now())
 			fmt.Printf("********** sleeping for: %s ...\n", sleepTime.String())
 		}
 	}
@@ -224,16 +523,20 @@ func doStandardMode() {
 	var commitment string
 	fmt.Scanln(&commitment)
 
-	// try commitment decoding
-	commitmentBytes, decodeErr := hex.DecodeString(commitment)
+	// try commitment decoding - commitment inserted in display (big endian) order
+	_, decodeErr := hex.DecodeString(commitment)
 	if decodeErr != nil {
 		log.Fatal(fmt.Sprintf("Commitment ('%s') decode error: %v\n", commitment, decodeErr))
 	}
-	_, hashErr := chainhash.NewHash(commitmentBytes)
-	if hashErr != nil {
+	commitmentHash, hashErr := chainhash.NewHashFromStr(commitment)
+	if hashErr != nil || len(commitment) != chainhash.MaxHashStringSize {
 		log.Fatal(fmt.Sprintf("Commitment ('%s') to hash error: %v\n", commitment, hashErr))
 	}
 
+	// get commitment bytes in the byte order set - same bytes signed and sent
+	commitmentBytes := commitmentBytesFromHash(*commitmentHash)
+	commitment = hex.EncodeToString(commitmentBytes)
+
 	fmt.Println()
 	fmt.Print("Sign commitment, send commitment or both? ")
 	var whatToDo string
@@ -289,6 +592,13 @@ func doStandardMode() {
 
 // main
 func main() {
+	// set up http client for sending commitments
+	var httpClientErr error
+	httpClient, httpClientErr = newHttpClient()
+	if httpClientErr != nil {
+		log.Fatal(fmt.Sprintf("Http client error: %v\n", httpClientErr))
+	}
+
 	// choose mode to run on based on input parameters
 	if isInit {
 		doInitMode()
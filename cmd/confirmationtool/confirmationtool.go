@@ -30,6 +30,7 @@ var (
 	script      string
 	chaincodes  string
 	apiHost     string
+	reserveAddr string
 	position    int
 	showDetails bool
 	mainConfig  *config.Config
@@ -43,6 +44,7 @@ func init() {
 	flag.StringVar(&script, "script", "", "Redeem script of multisig used by attestaton service")
 	flag.StringVar(&chaincodes, "chaincodes", "", "Chaincodes for multisig pubkeys")
 	flag.StringVar(&apiHost, "apiHost", DefaultApiHost, "Host address for mainstay API")
+	flag.StringVar(&reserveAddr, "reserveAddress", "", "Reserve address of two-output attestations, if used by attestation service")
 	flag.IntVar(&position, "position", -1, "Client merkle commitment position")
 	flag.Parse()
 
@@ -72,17 +74,18 @@ func main() {
 	fetcher := staychain.NewChainFetcher(mainConfig.MainClient(), txraw)
 	chain := staychain.NewChain(fetcher)
 	verifier := staychain.NewChainVerifier(mainConfig.MainChainCfg(),
-		client, position, script, strings.Split(chaincodes, ","), apiHost)
+		map[int]clients.SidechainClient{position: client}, script, strings.Split(chaincodes, ","), apiHost)
+	verifier.SetReserveAddress(reserveAddr)
 
 	// await new attestations and verify
 	for transaction := range chain.Updates() {
 		log.Println("Verifying attestation")
 		log.Printf("txid: %s\n", transaction.Txid)
-		info, err := verifier.Verify(transaction)
+		infos, err := verifier.Verify(transaction)
 		if err != nil {
 			log.Fatal(err)
 		} else {
-			printAttestation(transaction, info)
+			printAttestation(transaction, infos[position])
 		}
 	}
 }
@@ -0,0 +1,142 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Regtest end-to-end tool
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"mainstay/attestation"
+	"mainstay/clients"
+	confpkg "mainstay/config"
+	"mainstay/models"
+	"mainstay/server"
+	"mainstay/staychain"
+	"mainstay/test"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Simulate a full attestation cycle against a fresh regtest bitcoin node
+// The genesis multisig is funded by the test init script, a random client
+// commitment is attested by the attestation service and the attestation
+// is verified with the staychain tools before the tool exits
+
+// interval for generating blocks while awaiting attestation
+const BlockInterval = 5 * time.Second
+
+var (
+	timeout time.Duration
+	logInit bool
+)
+
+// init - flag parse
+func init() {
+	flag.DurationVar(&timeout, "timeout", 10*time.Minute, "Time to wait for the attestation to confirm")
+	flag.BoolVar(&logInit, "logInit", false, "Log output of the regtest init script")
+	flag.Parse()
+}
+
+// Wait for an attestation including the commitment to confirm, generating
+// a block on each interval, and return the commitment proof of the attestation
+func awaitAttestation(config *confpkg.Config, attestServer *server.Server,
+	attestService *attestation.AttestService, commitment chainhash.Hash) (server.AttestationCommitmentProof, error) {
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(BlockInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline:
+			return server.AttestationCommitmentProof{}, errors.New(
+				fmt.Sprintf("attestation not confirmed within %s", timeout.String()))
+		case err := <-attestService.Errors():
+			return server.AttestationCommitmentProof{}, err
+		case <-ticker.C:
+			if _, genErr := config.MainClient().Generate(1); genErr != nil {
+				return server.AttestationCommitmentProof{}, genErr
+			}
+			proofs, proofsErr := attestServer.GetAttestationForCommitment(commitment)
+			if proofsErr != nil {
+				return server.AttestationCommitmentProof{}, proofsErr
+			}
+			if len(proofs) > 0 {
+				return proofs[0], nil
+			}
+		}
+	}
+}
+
+// Verify the attestation commitment proof and the attestation transaction
+// against the genesis multisig and chaincodes using the staychain verifier
+func verifyAttestation(config *confpkg.Config, proof server.AttestationCommitmentProof) error {
+	if !models.ProveMerkleProof(proof.Proof) {
+		return errors.New("commitment merkle proof invalid")
+	}
+
+	txraw, txrawErr := config.MainClient().GetRawTransactionVerbose(&proof.Txid)
+	if txrawErr != nil {
+		return txrawErr
+	}
+	if len(txraw.Vin) == 0 || txraw.Vin[0].Txid != config.InitTx() {
+		return errors.New(fmt.Sprintf("attestation %s does not spend genesis %s", proof.Txid.String(), config.InitTx()))
+	}
+
+	verifier := staychain.NewChainVerifier(config.MainChainCfg(), map[int]clients.SidechainClient{},
+		config.InitScript(), config.InitChaincodes(), "")
+	verifier.SetReserveAddress(config.AttestationConfig().ReserveAddress)
+	return verifier.VerifyChaincodes(staychain.Tx(*txraw), proof.Proof.MerkleRoot)
+}
+
+// main method
+func main() {
+	regtest := test.NewTest(logInit, false)
+	config := regtest.Config
+	defer config.MainClient().Shutdown()
+
+	// random client commitment at position 0
+	commitmentBytes := make([]byte, chainhash.HashSize)
+	if _, randErr := rand.Read(commitmentBytes); randErr != nil {
+		log.Fatal(randErr)
+	}
+	commitment, _ := chainhash.NewHash(commitmentBytes)
+	log.Printf("Attesting commitment: %s\n", commitment.String())
+
+	attestServer := server.NewServer(server.NewDbFake())
+	if _, saveErr := attestServer.SaveClientCommitment(
+		models.ClientCommitment{Commitment: *commitment, ClientPosition: 0}); saveErr != nil {
+		log.Fatal(saveErr)
+	}
+
+	// run attestation service until the commitment is attested
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	signer := attestation.NewAttestSignerFake([]*confpkg.Config{config})
+	attestService := attestation.NewAttestService(ctx, wg, attestServer, signer, config)
+	wg.Add(1)
+	go attestService.Run()
+
+	proof, proofErr := awaitAttestation(config, attestServer, attestService, *commitment)
+	cancel()
+	wg.Wait()
+	if proofErr != nil {
+		log.Fatal(fmt.Sprintf("FAILED: %v", proofErr))
+	}
+	log.Printf("Attestation confirmed txid: %s merkle root: %s\n",
+		proof.Txid.String(), proof.Proof.MerkleRoot.String())
+
+	if verifyErr := verifyAttestation(config, proof); verifyErr != nil {
+		log.Fatal(fmt.Sprintf("FAILED: %v", verifyErr))
+	}
+	log.Println("PASSED")
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Attestation export tool
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"mainstay/config"
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Export attestation records within a time range to CSV or JSON
+// Records are fetched from the mainstay db page by page and written
+// to stdout as they are received so that large ranges can be exported
+// Fee and confirmations are fetched from the main bitcoin client
+
+const ConfPath = "/src/mainstay/cmd/exporttool/conf.json"
+
+// export formats
+const (
+	FormatCsv  = "csv"
+	FormatJson = "json"
+)
+
+// accepted start/end time layouts
+const dateLayout = "2006-01-02"
+
+var timeLayouts = []string{time.RFC3339, dateLayout}
+
+var (
+	startStr   string
+	endStr     string
+	format     string
+	pageSize   int64
+	mainConfig *config.Config
+)
+
+// exported attestation record
+type attestationRecord struct {
+	Txid          string `json:"txid"`
+	Blockhash     string `json:"blockhash"`
+	MerkleRoot    string `json:"merkle_root"`
+	Time          int64  `json:"time"`
+	Amount        int64  `json:"amount"`
+	Fee           int64  `json:"fee"`
+	Confirmations int64  `json:"confirmations"`
+}
+
+// csv header and row of attestation record
+var csvHeader = []string{"txid", "blockhash", "merkle_root", "time", "amount", "fee", "confirmations"}
+
+func (r attestationRecord) csvRow() []string {
+	return []string{r.Txid, r.Blockhash, r.MerkleRoot,
+		strconv.FormatInt(r.Time, 10),
+		strconv.FormatInt(r.Amount, 10),
+		strconv.FormatInt(r.Fee, 10),
+		strconv.FormatInt(r.Confirmations, 10)}
+}
+
+// init
+func init() {
+	flag.StringVar(&startStr, "start", "", "Start time of export range (RFC3339 or YYYY-MM-DD)")
+	flag.StringVar(&endStr, "end", "", "End time of export range (RFC3339 or YYYY-MM-DD) - defaults to now")
+	flag.StringVar(&format, "format", FormatCsv, "Export format (csv/json)")
+	flag.Int64Var(&pageSize, "pageSize", 100, "Number of attestations fetched from the db per page")
+	flag.Parse()
+
+	if startStr == "" {
+		flag.PrintDefaults()
+		log.Fatalf("Need to provide -start argument.\n")
+	}
+	if format != FormatCsv && format != FormatJson {
+		log.Fatalf("Invalid -format argument %s. Use csv or json.\n", format)
+	}
+	if pageSize <= 0 {
+		log.Fatalf("Invalid -pageSize argument %d.\n", pageSize)
+	}
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// parse time using any of the accepted layouts
+// returns whether the time was parsed from a date only
+func parseTime(timeStr string) (time.Time, bool, error) {
+	var parseErr error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, parseErr = time.Parse(layout, timeStr)
+		if parseErr == nil {
+			return t, layout == dateLayout, nil
+		}
+	}
+	return time.Time{}, false, parseErr
+}
+
+// get attestation fee and confirmations from the main client
+// fee is calculated from the values of the attestation tx inputs and outputs
+func getTxDetails(txidStr string) (int64, int64, error) {
+	txid, hashErr := chainhash.NewHashFromStr(txidStr)
+	if hashErr != nil {
+		return 0, 0, hashErr
+	}
+	client := mainConfig.MainClient()
+	walletTx, walletTxErr := client.GetTransaction(txid)
+	if walletTxErr != nil {
+		return 0, 0, walletTxErr
+	}
+	tx, txErr := client.GetRawTransaction(txid)
+	if txErr != nil {
+		return 0, 0, txErr
+	}
+
+	var fee int64
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevTx, prevTxErr := client.GetRawTransaction(&txIn.PreviousOutPoint.Hash)
+		if prevTxErr != nil {
+			return 0, 0, prevTxErr
+		}
+		fee += prevTx.MsgTx().TxOut[txIn.PreviousOutPoint.Index].Value
+	}
+	for _, txOut := range tx.MsgTx().TxOut {
+		fee -= txOut.Value
+	}
+	return fee, walletTx.Confirmations, nil
+}
+
+// build export record from attestation info
+func getRecord(dbMongo *server.DbMongo, info models.AttestationInfo) (attestationRecord, error) {
+	txid, hashErr := chainhash.NewHashFromStr(info.Txid)
+	if hashErr != nil {
+		return attestationRecord{}, hashErr
+	}
+	merkleRoot, rootErr := dbMongo.GetAttestationMerkleRoot(*txid)
+	if rootErr != nil {
+		return attestationRecord{}, rootErr
+	}
+	fee, confirmations, detailsErr := getTxDetails(info.Txid)
+	if detailsErr != nil {
+		return attestationRecord{}, detailsErr
+	}
+	return attestationRecord{
+		Txid:          info.Txid,
+		Blockhash:     info.Blockhash,
+		MerkleRoot:    merkleRoot,
+		Time:          info.Time,
+		Amount:        info.Amount,
+		Fee:           fee,
+		Confirmations: confirmations}, nil
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	start, _, startErr := parseTime(startStr)
+	if startErr != nil {
+		log.Fatal(startErr)
+	}
+	end := time.Now()
+	if endStr != "" {
+		var endDate bool
+		var endErr error
+		end, endDate, endErr = parseTime(endStr)
+		if endErr != nil {
+			log.Fatal(endErr)
+		}
+		// include the whole end day
+		if endDate {
+			end = end.AddDate(0, 0, 1).Add(-time.Second)
+		}
+	}
+
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+
+	csvWriter := csv.NewWriter(os.Stdout)
+	if format == FormatCsv {
+		if writeErr := csvWriter.Write(csvHeader); writeErr != nil {
+			log.Fatal(writeErr)
+		}
+	} else {
+		fmt.Print("[")
+	}
+
+	// fetch and write attestations page by page
+	// each page follows the last attestation of the previous page
+	var count int64
+	var last models.AttestationInfo
+	for {
+		infos, infosErr := dbMongo.GetAttestationInfoPage(start.Unix(), end.Unix(), last, pageSize)
+		if infosErr != nil {
+			log.Fatal(infosErr)
+		}
+		for _, info := range infos {
+			record, recordErr := getRecord(dbMongo, info)
+			if recordErr != nil {
+				log.Fatal(recordErr)
+			}
+			if format == FormatCsv {
+				if writeErr := csvWriter.Write(record.csvRow()); writeErr != nil {
+					log.Fatal(writeErr)
+				}
+			} else {
+				recordBytes, marshalErr := json.Marshal(record)
+				if marshalErr != nil {
+					log.Fatal(marshalErr)
+				}
+				if count > 0 {
+					fmt.Print(",")
+				}
+				fmt.Printf("\n%s", recordBytes)
+			}
+			count++
+		}
+		csvWriter.Flush()
+		if int64(len(infos)) < pageSize {
+			break
+		}
+		last = infos[len(infos)-1]
+	}
+
+	if format == FormatJson {
+		fmt.Println("\n]")
+	}
+	log.Printf("exported %d attestations\n", count)
+}
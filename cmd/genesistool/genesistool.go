@@ -0,0 +1,196 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Genesis setup tool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"mainstay/crypto"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// Generate the genesis multisig setup for Mainstay from the signer pubkeys
+// and optionally the genesis funding transaction paying to the multisig
+// address from a P2PKH unspent, printing the config values for the service
+
+// default fee in satoshis for the genesis funding transaction
+const DefaultGenesisFee = 10000
+
+var (
+	chain    string
+	chainCfg *chaincfg.Params
+
+	keys       string
+	nSigs      int
+	chaincodes string
+
+	utxo       string
+	utxoAmount int64
+	utxoPk     string
+	fee        int64
+)
+
+// init - flag parse
+func init() {
+	flag.StringVar(&chain, "chain", "", "Bitcoin chain configuration (regtest, testnet or mainnet)")
+
+	flag.StringVar(&keys, "keys", "", "List of comma separated signer pubkeys")
+	flag.IntVar(&nSigs, "nSigs", 0, "Number of signatures")
+	flag.StringVar(&chaincodes, "chaincodes", "", "List of comma separated signer chaincodes (random if not set)")
+
+	flag.StringVar(&utxo, "utxo", "", "Unspent (txid:vout) paying to a P2PKH address to fund genesis with")
+	flag.Int64Var(&utxoAmount, "amount", 0, "Amount of the funding unspent in satoshis")
+	flag.StringVar(&utxoPk, "pk", "", "Private key (WIF) of the funding unspent address")
+	flag.Int64Var(&fee, "fee", DefaultGenesisFee, "Fee in satoshis for the genesis funding transaction")
+
+	flag.Parse()
+
+	if keys == "" {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide -keys argument.")
+	}
+}
+
+// Parse signer pubkeys from hex
+func parsePubkeys(keysStr string) []*btcec.PublicKey {
+	var pubkeys []*btcec.PublicKey
+	for _, key := range strings.Split(keysStr, ",") {
+		keyBytes, keyBytesErr := hex.DecodeString(strings.TrimSpace(key))
+		if keyBytesErr != nil {
+			log.Fatal(fmt.Sprintf("failed decoding pub %s %v", key, keyBytesErr))
+		}
+		pubkey, pubkeyErr := btcec.ParsePubKey(keyBytes, btcec.S256())
+		if pubkeyErr != nil {
+			log.Fatal(fmt.Sprintf("failed parsing pub %s %v", key, pubkeyErr))
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+	return pubkeys
+}
+
+// Parse signer chaincodes from hex or generate random chaincodes if not set
+func parseChaincodes(chaincodesStr string, nKeys int) []string {
+	if chaincodesStr == "" {
+		var randomChaincodes []string
+		for i := 0; i < nKeys; i++ {
+			seed, seedErr := hdkeychain.GenerateSeed(32)
+			if seedErr != nil {
+				log.Fatal(seedErr)
+			}
+			randomChaincodes = append(randomChaincodes, hex.EncodeToString(seed))
+		}
+		fmt.Println("WARNING: random chaincodes generated - each signer must be provided with their chaincode")
+		return randomChaincodes
+	}
+
+	chaincodesSplit := strings.Split(chaincodesStr, ",")
+	if len(chaincodesSplit) != nKeys {
+		log.Fatal(fmt.Sprintf("%d keys but %d chaincodes provided", nKeys, len(chaincodesSplit)))
+	}
+	for _, chaincode := range chaincodesSplit {
+		chaincodeBytes, chaincodeErr := hex.DecodeString(chaincode)
+		if chaincodeErr != nil || len(chaincodeBytes) != 32 {
+			log.Fatal(fmt.Sprintf("invalid chaincode %s", chaincode))
+		}
+	}
+	return chaincodesSplit
+}
+
+// Create and sign genesis funding transaction paying to the multisig address
+// The unspent is spent in full, minus the fee, using the P2PKH private key
+func fundGenesis(addr btcutil.Address) *wire.MsgTx {
+	utxoSplit := strings.Split(utxo, ":")
+	if len(utxoSplit) != 2 {
+		log.Fatal(fmt.Sprintf("invalid utxo %s - expected txid:vout", utxo))
+	}
+	utxoTxid, utxoTxidErr := chainhash.NewHashFromStr(utxoSplit[0])
+	if utxoTxidErr != nil {
+		log.Fatal(fmt.Sprintf("invalid utxo txid %s %v", utxoSplit[0], utxoTxidErr))
+	}
+	utxoVout, utxoVoutErr := strconv.ParseUint(utxoSplit[1], 10, 32)
+	if utxoVoutErr != nil {
+		log.Fatal(fmt.Sprintf("invalid utxo vout %s %v", utxoSplit[1], utxoVoutErr))
+	}
+	if utxoAmount <= fee {
+		log.Fatal(fmt.Sprintf("utxo amount (%d) not larger than fee (%d)", utxoAmount, fee))
+	}
+
+	wif, wifErr := crypto.GetWalletPrivKey(utxoPk)
+	if wifErr != nil {
+		log.Fatal(fmt.Sprintf("invalid private key %v", wifErr))
+	}
+	utxoAddr, utxoAddrErr := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(wif.SerializePubKey()), chainCfg)
+	if utxoAddrErr != nil {
+		log.Fatal(utxoAddrErr)
+	}
+	utxoPkScript, _ := txscript.PayToAddrScript(utxoAddr)
+	genesisPkScript, _ := txscript.PayToAddrScript(addr)
+
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(utxoTxid, uint32(utxoVout)), nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(utxoAmount-fee, genesisPkScript))
+
+	sigScript, sigScriptErr := txscript.SignatureScript(
+		msgTx, 0, utxoPkScript, txscript.SigHashAll, wif.PrivKey, wif.CompressPubKey)
+	if sigScriptErr != nil {
+		log.Fatal(sigScriptErr)
+	}
+	msgTx.TxIn[0].SignatureScript = sigScript
+	return msgTx
+}
+
+// main
+func main() {
+	if chain == "regtest" {
+		chainCfg = &chaincfg.RegressionNetParams
+	} else if chain == "testnet" {
+		chainCfg = &chaincfg.TestNet3Params
+	} else {
+		chainCfg = &chaincfg.MainNetParams
+	}
+
+	// multisig script creation supports single digit nSigs/nKeys only
+	pubkeys := parsePubkeys(keys)
+	if nSigs <= 0 || nSigs > 9 || len(pubkeys) > 9 || nSigs > len(pubkeys) {
+		log.Fatal(fmt.Sprintf("invalid nSigs(%d) or nKeys(%d)", nSigs, len(pubkeys)))
+	}
+	initChaincodes := parseChaincodes(chaincodes, len(pubkeys))
+
+	addr, script := crypto.CreateMultisig(pubkeys, nSigs, chainCfg)
+	fmt.Printf("%d-of-%d P2SH address: %s\n", nSigs, len(pubkeys), addr.String())
+	fmt.Printf("initScript: %s\n", script)
+	fmt.Printf("initChaincodes: %s\n", strings.Join(initChaincodes, ","))
+
+	if utxo == "" {
+		fmt.Println("no -utxo provided - pay to the P2SH address to fund genesis and use the txid as initTx")
+		return
+	}
+
+	genesisTx := fundGenesis(addr)
+	var txBuffer bytes.Buffer
+	if serializeErr := genesisTx.Serialize(&txBuffer); serializeErr != nil {
+		log.Fatal(serializeErr)
+	}
+	fmt.Printf("initTx: %s\n", genesisTx.TxHash().String())
+	fmt.Printf("genesis tx hex (broadcast with sendrawtransaction): %s\n", hex.EncodeToString(txBuffer.Bytes()))
+	fmt.Println()
+	fmt.Printf("confirmation tool arguments: -tx %s -script %s\n", genesisTx.TxHash().String(), script)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Attestation journal tool
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"mainstay/attestation"
+)
+
+// Decrypt and print the entries of the attestation journal kept by the
+// attestation service, i.e. the address and redeem script generated for
+// each commitment, in order to recover attestation funds if the service
+// db and wallet are lost
+
+var (
+	journalPath string // path of the journal file
+	journalKey  string // journal encryption key
+	isJson      bool   // print entries as json
+)
+
+// init - flag parse
+func init() {
+	flag.StringVar(&journalPath, "journal", "", "Path of the attestation journal file")
+	flag.StringVar(&journalKey, "key", "", "Hex encoded 32 byte journal key - read from JOURNAL_KEY if not set")
+	flag.BoolVar(&isJson, "json", false, "Print journal entries in json format")
+	flag.Parse()
+
+	if journalKey == "" {
+		journalKey = os.Getenv("JOURNAL_KEY")
+	}
+	if journalPath == "" || journalKey == "" {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide both -journal and -key arguments.")
+	}
+}
+
+// main
+func main() {
+	entries, readErr := attestation.ReadAttestJournal(journalPath, journalKey)
+	if readErr != nil {
+		log.Fatal(readErr)
+	}
+
+	if isJson {
+		entriesJson, jsonErr := json.MarshalIndent(entries, "", "    ")
+		if jsonErr != nil {
+			log.Fatal(jsonErr)
+		}
+		fmt.Println(string(entriesJson))
+		return
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s %s %s %s\n", entry.RecordedAt.UTC().Format("2006-01-02T15:04:05Z"),
+			entry.Commitment, entry.Address, entry.Script)
+	}
+	log.Printf("%d journal entries\n", len(entries))
+}
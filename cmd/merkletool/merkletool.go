@@ -0,0 +1,125 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Merkle commitment tool
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Build the commitment merkle tree for a list of client commitments
+// using the same hashing as the attestation service and print the
+// merkle root along with the merkle proof of each commitment
+
+var (
+	commitmentsStr  string // comma separated commitments
+	commitmentsFile string // file with one commitment per line
+	isJson          bool   // print merkle proofs as json
+)
+
+// init - flag parse
+func init() {
+	flag.StringVar(&commitmentsStr, "commitments", "", "List of comma separated commitment hashes in position order")
+	flag.StringVar(&commitmentsFile, "file", "", "File with one commitment hash per line in position order")
+	flag.BoolVar(&isJson, "json", false, "Print merkle proofs in json format")
+	flag.Parse()
+
+	if commitmentsStr == "" && commitmentsFile == "" {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide either -commitments or -file argument.")
+	}
+}
+
+// Read commitment strings from file, one per line in position order
+// Empty lines are kept so that they set their position to the zero hash
+func readCommitmentsFile(path string) []string {
+	file, fileErr := os.Open(path)
+	if fileErr != nil {
+		log.Fatal(fileErr)
+	}
+	defer file.Close()
+
+	var commitments []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		commitments = append(commitments, scanner.Text())
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		log.Fatal(scanErr)
+	}
+	return commitments
+}
+
+// Parse commitment strings to hashes
+// An empty string sets the position to the zero hash
+func parseCommitments(commitments []string) []chainhash.Hash {
+	var hashes []chainhash.Hash
+	for pos, commitment := range commitments {
+		commitment = strings.TrimSpace(commitment)
+		if commitment == "" {
+			hashes = append(hashes, chainhash.Hash{})
+			continue
+		}
+		hash, hashErr := chainhash.NewHashFromStr(commitment)
+		if hashErr != nil || len(commitment) != chainhash.MaxHashStringSize {
+			log.Fatal(fmt.Sprintf("Invalid commitment ('%s') at position %d", commitment, pos))
+		}
+		hashes = append(hashes, *hash)
+	}
+	return hashes
+}
+
+// main
+func main() {
+	var commitments []string
+	if commitmentsFile != "" {
+		commitments = readCommitmentsFile(commitmentsFile)
+	} else {
+		commitments = strings.Split(commitmentsStr, ",")
+	}
+
+	commitment, commitmentErr := models.NewCommitment(parseCommitments(commitments))
+	if commitmentErr != nil {
+		log.Fatal(commitmentErr)
+	}
+
+	// print only the json merkle proofs for external verifiers
+	if isJson {
+		proofsBytes, marshalErr := json.MarshalIndent(commitment.GetMerkleProofs(), "", "  ")
+		if marshalErr != nil {
+			log.Fatal(marshalErr)
+		}
+		fmt.Println(string(proofsBytes))
+		return
+	}
+
+	fmt.Println("COMMITMENTS")
+	for _, c := range commitment.GetMerkleCommitments() {
+		fmt.Printf("client_position: %d commitment: %s\n", c.ClientPosition, c.Commitment.String())
+	}
+	fmt.Println()
+
+	fmt.Printf("MERKLE ROOT: %s\n", commitment.GetCommitmentHash().String())
+	fmt.Println()
+
+	fmt.Println("MERKLE PROOFS")
+	for _, proof := range commitment.GetMerkleProofs() {
+		fmt.Printf("client_position: %d commitment: %s\n", proof.ClientPosition, proof.Commitment.String())
+		for _, op := range proof.Ops {
+			fmt.Printf("\tappend: %t commitment: %s\n", op.Append, op.Commitment.String())
+		}
+	}
+}
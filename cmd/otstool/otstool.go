@@ -0,0 +1,123 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// OTS proof export tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"mainstay/config"
+	"mainstay/models"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Export the proof of a client commitment included in a confirmed attestation
+// as an OTS proof. The commitment merkle proof is fetched from the mainstay db
+// and the attestation transaction and its block from the main bitcoin client
+
+const ConfPath = "/src/mainstay/cmd/otstool/conf.json"
+
+var (
+	commitmentStr string
+	txidStr       string
+	outPath       string
+	mainConfig    *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&commitmentStr, "commitment", "", "Client commitment to export the proof of")
+	flag.StringVar(&txidStr, "txid", "", "Attestation txid to export the proof for - defaults to the first attestation found")
+	flag.StringVar(&outPath, "out", "", "Path of the OTS proof file - defaults to <commitment>.ots")
+	flag.Parse()
+
+	if commitmentStr == "" {
+		log.Fatal("Missing -commitment argument.")
+	}
+	if outPath == "" {
+		outPath = commitmentStr + ".ots"
+	}
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// build proof bundle of the attestation proof from the attestation block
+func getProofBundle(proof server.AttestationCommitmentProof) (models.AttestationProofBundle, error) {
+	client := mainConfig.MainClient()
+	txraw, txrawErr := client.GetRawTransactionVerbose(&proof.Txid)
+	if txrawErr != nil {
+		return models.AttestationProofBundle{}, txrawErr
+	}
+	blockhash, blockhashErr := chainhash.NewHashFromStr(txraw.BlockHash)
+	if blockhashErr != nil {
+		return models.AttestationProofBundle{}, blockhashErr
+	}
+	header, headerErr := client.GetBlockHeaderVerbose(blockhash)
+	if headerErr != nil {
+		return models.AttestationProofBundle{}, headerErr
+	}
+	block, blockErr := client.GetBlock(blockhash)
+	if blockErr != nil {
+		return models.AttestationProofBundle{}, blockErr
+	}
+	return models.NewAttestationProofBundle(proof.Proof, proof.Txid, block, int64(header.Height))
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	commitment, commitmentErr := chainhash.NewHashFromStr(commitmentStr)
+	if commitmentErr != nil {
+		log.Fatal(commitmentErr)
+	}
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	proofs, proofsErr := server.NewServer(dbMongo).GetAttestationForCommitment(*commitment)
+	if proofsErr != nil {
+		log.Fatal(proofsErr)
+	}
+
+	// select attestation proof of the txid provided or the first one
+	var proof *server.AttestationCommitmentProof
+	for i := range proofs {
+		if txidStr == "" || proofs[i].Txid.String() == txidStr {
+			proof = &proofs[i]
+			break
+		}
+	}
+	if proof == nil {
+		log.Fatalf("No confirmed attestation found for commitment %s\n", commitmentStr)
+	}
+
+	bundle, bundleErr := getProofBundle(*proof)
+	if bundleErr != nil {
+		log.Fatal(bundleErr)
+	}
+	otsBytes, otsErr := bundle.MarshalOTS()
+	if otsErr != nil {
+		log.Fatal(otsErr)
+	}
+	if writeErr := ioutil.WriteFile(outPath, otsBytes, 0644); writeErr != nil {
+		log.Fatal(writeErr)
+	}
+	fmt.Printf("exported proof of commitment %s in attestation %s (block %d) to %s\n",
+		commitmentStr, proof.Txid.String(), bundle.BlockHeight, outPath)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Override commitment tool
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"mainstay/config"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Store an external commitment in the mainstay db at the client position
+// reserved for override commitments, to be included in the next attestation
+// Requires the mainstay db credentials of the operator
+
+const ConfPath = "/src/mainstay/cmd/overridetool/conf.json"
+
+var (
+	commitment string
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&commitment, "commitment", "", "32-byte commitment hash in hex")
+	flag.Parse()
+
+	if commitment == "" {
+		flag.PrintDefaults()
+		log.Fatalf("Need to provide -commitment argument.\n")
+	}
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	// validate commitment is a 32-byte hash
+	commitmentBytes, decodeErr := hex.DecodeString(commitment)
+	if decodeErr != nil || len(commitmentBytes) != chainhash.HashSize {
+		log.Fatalf("Invalid commitment %s - 32-byte hex hash required\n", commitment)
+	}
+	commitmentHash, hashErr := chainhash.NewHashFromStr(commitment)
+	if hashErr != nil {
+		log.Fatal(hashErr)
+	}
+
+	serverConfig := mainConfig.ServerConfig()
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	server := server.NewServer(dbMongo, serverConfig)
+
+	if saveErr := server.SaveOverrideCommitment(*commitmentHash); saveErr != nil {
+		log.Fatal(saveErr)
+	}
+
+	fmt.Printf("client_position: %d commitment: %s\n", serverConfig.OverridePosition, commitmentHash.String())
+	fmt.Println("Override commitment saved - to be included in the next attestation")
+}
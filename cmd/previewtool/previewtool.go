@@ -0,0 +1,95 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Attestation preview tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// Print the latest client commitments that the next attestation will
+// include, along with the merkle root and the derived attestation address
+// Read-only - nothing is stored in the db or broadcast to the network
+
+const ConfPath = "/src/mainstay/cmd/previewtool/conf.json"
+
+var (
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	server := server.NewServer(dbMongo, mainConfig.ServerConfig())
+
+	// get latest client commitments to be attested
+	commitment, commitmentErr := server.GetClientCommitment()
+	if commitmentErr != nil {
+		log.Fatal(commitmentErr)
+	}
+	commitmentHash := commitment.GetCommitmentHash()
+
+	fmt.Println("COMMITMENTS")
+	for _, c := range commitment.GetMerkleCommitments() {
+		fmt.Printf("client_position: %d commitment: %s\n", c.ClientPosition, c.Commitment.String())
+	}
+	fmt.Println()
+
+	fmt.Printf("MERKLE ROOT: %s\n", commitmentHash.String())
+	fmt.Println()
+
+	// derive attestation address without private keys or wallet changes
+	attester := attestation.NewAttestClientReadOnly(mainConfig)
+	addr, script, addrErr := attester.GetNextAttestationAddr((*btcutil.WIF)(nil), commitmentHash)
+	if addrErr != nil {
+		log.Fatal(addrErr)
+	}
+	fmt.Printf("ATTESTATION ADDRESS: %s\n", addr.String())
+	if script != "" {
+		fmt.Printf("ATTESTATION SCRIPT: %s\n", script)
+	}
+	fmt.Println()
+
+	// compare with the latest attested commitments
+	latestHash, latestErr := server.GetLatestAttestationCommitmentHash()
+	if latestErr != nil {
+		log.Fatal(latestErr)
+	}
+	fmt.Printf("LATEST ATTESTED MERKLE ROOT: %s\n", latestHash.String())
+	if latestHash == commitmentHash {
+		if mainConfig.AttestationConfig().SkipDuplicateCommitment {
+			fmt.Println("commitments already attested - next attestation will be skipped")
+		} else {
+			fmt.Println("commitments already attested - next attestation will attest them again")
+		}
+	} else {
+		fmt.Println("commitments will be included in the next attestation")
+	}
+}
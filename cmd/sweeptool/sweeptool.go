@@ -0,0 +1,132 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Sweep tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// Recover the staychain funds when decommissioning the service by sweeping
+// the latest attestation output to a destination address, signing with the
+// quorum keys tweaked with the commitment of the latest attestation
+
+const ConfPath = "/src/mainstay/cmd/sweeptool/conf.json"
+
+var (
+	tx         string
+	commitment string
+	pks        string
+	addr       string
+	feePerByte int
+	send       bool
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.StringVar(&tx, "tx", "", "Txid of the latest attestation transaction to sweep")
+	flag.StringVar(&commitment, "commitment", "", "Commitment hash of the latest attestation (fetched from db if not set)")
+	flag.StringVar(&pks, "pks", "", "List of comma separated quorum private keys in init script pubkey order")
+	flag.StringVar(&addr, "addr", "", "Destination address to sweep funds to")
+	flag.IntVar(&feePerByte, "fee", attestation.DefaultMinFee, "Fee per byte in satoshis for the sweep transaction")
+	flag.BoolVar(&send, "send", false, "Broadcast the sweep transaction")
+	flag.Parse()
+
+	if tx == "" || pks == "" || addr == "" {
+		flag.PrintDefaults()
+		log.Fatal("Need to provide -tx, -pks and -addr arguments.")
+	}
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// Get commitment hash of the latest attestation from flag or db
+func getCommitmentHash() chainhash.Hash {
+	if commitment != "" {
+		hash, hashErr := chainhash.NewHashFromStr(commitment)
+		if hashErr != nil {
+			log.Fatal(hashErr)
+		}
+		return *hash
+	}
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	hash, hashErr := server.NewServer(dbMongo).GetLatestAttestationCommitmentHash()
+	if hashErr != nil {
+		log.Fatal(hashErr)
+	}
+	return hash
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	txid, txidErr := chainhash.NewHashFromStr(tx)
+	if txidErr != nil {
+		log.Fatal(txidErr)
+	}
+	hash := getCommitmentHash()
+	fmt.Printf("sweeping attestation %s with commitment %s\n", txid.String(), hash.String())
+
+	paytoaddr, addrErr := btcutil.DecodeAddress(addr, mainConfig.MainChainCfg())
+	if addrErr != nil {
+		log.Fatal(addrErr)
+	}
+
+	// signer client for each quorum key
+	var signers []*attestation.AttestClient
+	for _, pk := range strings.Split(pks, ",") {
+		mainConfig.SetInitPK(pk)
+		signers = append(signers, attestation.NewAttestClient(mainConfig, true))
+	}
+
+	sweepTx, sweepErr := signers[0].CreateSweepTransaction(*txid, hash, paytoaddr, feePerByte)
+	if sweepErr != nil {
+		log.Fatal(sweepErr)
+	}
+	signedTx, signErr := signers[0].SignSweepTransaction(sweepTx, hash, signers[1:])
+	if signErr != nil {
+		log.Fatal(signErr)
+	}
+
+	var txBuffer bytes.Buffer
+	if serializeErr := signedTx.Serialize(&txBuffer); serializeErr != nil {
+		log.Fatal(serializeErr)
+	}
+	fmt.Printf("sweep txid: %s\n", signedTx.TxHash().String())
+	fmt.Printf("sweep tx hex: %s\n", hex.EncodeToString(txBuffer.Bytes()))
+
+	if send {
+		sentTxid, sendErr := mainConfig.MainClient().SendRawTransaction(signedTx, false)
+		if sendErr != nil {
+			log.Fatal(sendErr)
+		}
+		fmt.Printf("sweep tx sent: %s\n", sentTxid.String())
+	}
+}
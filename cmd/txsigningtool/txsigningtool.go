@@ -50,6 +50,10 @@ var (
 	poller   *zmq.Poller
 	host     string
 	hostMain string
+	index    int  // signer index in mainstay signers - negative if not set
+	compact  bool // tx pre-images received in compact format
+
+	namespace string // topic namespace of mainstay signer config
 
 	attestedHash chainhash.Hash // previous attested hash
 	nextHash     chainhash.Hash // next hash to sign with
@@ -73,6 +77,9 @@ func parseFlags() {
 	flag.StringVar(&host, "host", "*:5002", "Client host to publish signatures at")
 	hostMainDefault := fmt.Sprintf("127.0.0.1:%d", attestation.DefaultMainPublisherPort)
 	flag.StringVar(&hostMain, "hostMain", hostMainDefault, "Mainstay host for signer to subscribe to")
+	flag.IntVar(&index, "index", -1, "Signer index in mainstay signers config for signer failover")
+	flag.BoolVar(&compact, "compact", false, "Receive compact tx pre-images, i.e. unsigned tx and redeem script of each input")
+	flag.StringVar(&namespace, "topicNamespace", "", "Topic namespace in mainstay signer config, e.g. chain id")
 	flag.Parse()
 
 	if pk0 == "" && !isRegtest {
@@ -156,11 +163,25 @@ func init() {
 
 	// comms setup
 	poller = zmq.NewPoller()
-	topics := []string{attestation.TopicNewTx, attestation.TopicConfirmedHash}
-	sub = messengers.NewSubscriberZmq(hostMain, topics, poller)
+	sub = messengers.NewSubscriberZmq(hostMain, subscribeTopics(), poller)
 	pub = messengers.NewPublisherZmq(host, poller)
 }
 
+// Get topics to subscribe to - including the signer
+// new tx topic used in signer failover if index is set
+func subscribeTopics() []string {
+	topics := []string{namespacedTopic(attestation.TopicNewTx), namespacedTopic(attestation.TopicConfirmedHash)}
+	if index >= 0 {
+		topics = append(topics, namespacedTopic(attestation.SignerTopicNewTx(index)))
+	}
+	return topics
+}
+
+// Get topic prefixed with the topic namespace, if set
+func namespacedTopic(t string) string {
+	return attestation.NamespaceTopic(namespace, t)
+}
+
 func main() {
 	// delay to resubscribe
 	resubscribeDelay := 5 * time.Minute
@@ -172,8 +193,7 @@ func main() {
 			// remove socket and close
 			sub.Close(poller)
 			// re-assign subscriber socket
-			topics := []string{attestation.TopicNewTx, attestation.TopicConfirmedHash}
-			sub = messengers.NewSubscriberZmq(hostMain, topics, poller)
+			sub = messengers.NewSubscriberZmq(hostMain, subscribeTopics(), poller)
 			timer = time.NewTimer(resubscribeDelay)
 		default:
 			sockets, _ := poller.Poll(-1)
@@ -181,9 +201,11 @@ func main() {
 				if sub.Socket() == socket.Socket {
 					topic, msg := sub.ReadMessage()
 					switch topic {
-					case attestation.TopicNewTx:
+					case namespacedTopic(attestation.TopicNewTx):
+						processTx(msg)
+					case namespacedTopic(attestation.SignerTopicNewTx(index)):
 						processTx(msg)
-					case attestation.TopicConfirmedHash:
+					case namespacedTopic(attestation.TopicConfirmedHash):
 						attestedHash = processHash(msg)
 						log.Printf("attestedhash %s\n", attestedHash.String())
 					}
@@ -210,6 +232,13 @@ func processTx(msg []byte) {
 
 	// get tx pre images from message
 	txPreImages := attestation.UnserializeBytes(msg)
+	if compact {
+		var expandErr error
+		txPreImages, expandErr = attestation.ExpandTxPreImages(txPreImages)
+		if expandErr != nil {
+			log.Fatalf("%v\n", expandErr)
+		}
+	}
 
 	// process each pre image transaction and sign
 	for txIt, txPreImage := range txPreImages {
@@ -240,5 +269,5 @@ func processTx(msg []byte) {
 	}
 
 	serializedSigs := attestation.SerializeBytes(sigs)
-	pub.SendMessage(serializedSigs, attestation.TopicSigs)
+	pub.SendMessage(serializedSigs, namespacedTopic(attestation.TopicSigs))
 }
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+// Verify db tool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"mainstay/attestation"
+	"mainstay/config"
+	"mainstay/server"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Audit the mainstay db against the main chain by verifying that every stored
+// confirmed attestation transaction pays to the attestation address derived
+// from the stored merkle root and the init keys. Attestations failing to
+// reproduce the on-chain address indicate corrupted or tampered db data
+
+const ConfPath = "/src/mainstay/cmd/verifydbtool/conf.json"
+
+var (
+	pageSize   int64
+	mainConfig *config.Config
+)
+
+// init
+func init() {
+	flag.Int64Var(&pageSize, "pageSize", 100, "Number of attestations fetched from the db per page")
+	flag.Parse()
+
+	if pageSize <= 0 {
+		log.Fatalf("Invalid -pageSize argument %d.\n", pageSize)
+	}
+
+	confFile, confErr := config.GetConfFile(os.Getenv("GOPATH") + ConfPath)
+	if confErr != nil {
+		log.Fatal(confErr)
+	}
+	var mainConfigErr error
+	mainConfig, mainConfigErr = config.NewConfig(confFile)
+	if mainConfigErr != nil {
+		log.Fatal(mainConfigErr)
+	}
+}
+
+// verify attestation with stored txid and merkle root against the main chain
+func verifyAttestation(attester *attestation.AttestClient, txidStr string, merkleRootStr string) error {
+	txid, txidErr := chainhash.NewHashFromStr(txidStr)
+	if txidErr != nil {
+		return txidErr
+	}
+	merkleRoot, rootErr := chainhash.NewHashFromStr(merkleRootStr)
+	if rootErr != nil {
+		return rootErr
+	}
+	return attester.VerifyAttestation(*txid, *merkleRoot)
+}
+
+// main method
+func main() {
+	defer mainConfig.MainClient().Shutdown()
+
+	dbMongo := server.NewDbMongo(context.Background(), mainConfig.DbConfig())
+	attester := attestation.NewAttestClient(mainConfig)
+
+	// fetch and verify confirmed attestations page by page
+	var count, failed int64
+	for skip := int64(0); ; skip += pageSize {
+		attestations, attestationsErr := dbMongo.GetConfirmedAttestationPage(skip, pageSize)
+		if attestationsErr != nil {
+			log.Fatal(attestationsErr)
+		}
+		for _, attestation := range attestations {
+			if verifyErr := verifyAttestation(attester, attestation.Txid, attestation.MerkleRoot); verifyErr != nil {
+				fmt.Printf("FAILED attestation %s merkle root %s: %v\n",
+					attestation.Txid, attestation.MerkleRoot, verifyErr)
+				failed++
+			}
+			count++
+		}
+		if int64(len(attestations)) < pageSize {
+			break
+		}
+	}
+
+	fmt.Printf("verified %d attestations - %d failed\n", count, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
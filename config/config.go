@@ -5,6 +5,9 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -32,6 +35,12 @@ const (
 	StayChainTopupChaincodesName = "topupChaincodes"
 )
 
+// config array errors
+const (
+	ErrorBadDataConfigs         = "invalid value for config array. non empty array of config objects allowed only"
+	ErrorConfigsDuplicateInitTx = "duplicate staychain init tx in config array"
+)
+
 // Config struct
 // Client connections and other parameters required
 // by ocean attestation service and testing
@@ -39,6 +48,7 @@ type Config struct {
 	// main bitcoin rpc connectivity
 	mainClient   *rpcclient.Client
 	mainChainCfg *chaincfg.Params
+	mainWallet   string
 
 	// core staychain config parameters
 	regtest         bool
@@ -52,10 +62,13 @@ type Config struct {
 	topupChaincodes []string
 
 	// additional parameter categories
-	signerConfig SignerConfig
-	dbConfig     DbConfig
-	feesConfig   FeesConfig
-	timingConfig TimingConfig
+	signerConfig           SignerConfig
+	dbConfig               DbConfig
+	feesConfig             FeesConfig
+	timingConfig           TimingConfig
+	attestationConfig      AttestationConfig
+	serverConfig           ServerConfig
+	commitmentSourceConfig CommitmentSourceConfig
 }
 
 // Get Main Client
@@ -63,6 +76,11 @@ func (c Config) MainClient() *rpcclient.Client {
 	return c.mainClient
 }
 
+// Get Main Client wallet name - empty if the default wallet is used
+func (c Config) MainWallet() string {
+	return c.mainWallet
+}
+
 // Get Main Client Cfg
 func (c Config) MainChainCfg() *chaincfg.Params {
 	return c.mainChainCfg
@@ -73,6 +91,11 @@ func (c Config) SignerConfig() SignerConfig {
 	return c.signerConfig
 }
 
+// Set signer configuration
+func (c *Config) SetSignerConfig(signerConfig SignerConfig) {
+	c.signerConfig = signerConfig
+}
+
 // Get Database configuration
 func (c Config) DbConfig() DbConfig {
 	return c.dbConfig
@@ -93,6 +116,36 @@ func (c *Config) SetTimingConfig(timingConfig TimingConfig) {
 	c.timingConfig = timingConfig
 }
 
+// Get Attestation configuration
+func (c Config) AttestationConfig() AttestationConfig {
+	return c.attestationConfig
+}
+
+// Set attestation configuration
+func (c *Config) SetAttestationConfig(attestationConfig AttestationConfig) {
+	c.attestationConfig = attestationConfig
+}
+
+// Get Server configuration
+func (c Config) ServerConfig() ServerConfig {
+	return c.serverConfig
+}
+
+// Set server configuration
+func (c *Config) SetServerConfig(serverConfig ServerConfig) {
+	c.serverConfig = serverConfig
+}
+
+// Get commitment source configuration
+func (c Config) CommitmentSourceConfig() CommitmentSourceConfig {
+	return c.commitmentSourceConfig
+}
+
+// Set commitment source configuration
+func (c *Config) SetCommitmentSourceConfig(commitmentSourceConfig CommitmentSourceConfig) {
+	c.commitmentSourceConfig = commitmentSourceConfig
+}
+
 // Get regtest flag
 func (c Config) Regtest() bool {
 	return c.regtest
@@ -216,6 +269,9 @@ func NewConfig(customConf ...[]byte) (*Config, error) {
 
 	feesConfig := GetFeesConfig(conf)
 	timingConfig := GetTimingConfig(conf)
+	attestationConfig := GetAttestationConfig(conf)
+	serverConfig := GetServerConfig(conf)
+	commitmentSourceConfig := GetCommitmentSourceConfig(conf)
 
 	signerConfig, signerConfigErr := GetSignerConfig(conf)
 	if signerConfigErr != nil {
@@ -244,24 +300,66 @@ func NewConfig(customConf ...[]byte) (*Config, error) {
 	}
 
 	return &Config{
-		mainClient:      mainClient,
-		mainChainCfg:    mainClientCfg,
-		regtest:         (regtestStr == "1"),
-		initTX:          initTxStr,
-		initPK:          initPKStr,
-		initScript:      initScriptStr,
-		initChaincodes:  initChaincodes,
-		topupAddress:    topupAddrStr,
-		topupScript:     topupScriptStr,
-		topupPK:         topupPKStr,
-		topupChaincodes: topupChaincodes,
-		signerConfig:    signerConfig,
-		dbConfig:        dbConnectivity,
-		feesConfig:      feesConfig,
-		timingConfig:    timingConfig,
+		mainClient:             mainClient,
+		mainChainCfg:           mainClientCfg,
+		mainWallet:             TryGetParamFromConf(MainChainName, RpcClientWalletName, conf),
+		regtest:                (regtestStr == "1"),
+		initTX:                 initTxStr,
+		initPK:                 initPKStr,
+		initScript:             initScriptStr,
+		initChaincodes:         initChaincodes,
+		topupAddress:           topupAddrStr,
+		topupScript:            topupScriptStr,
+		topupPK:                topupPKStr,
+		topupChaincodes:        topupChaincodes,
+		signerConfig:           signerConfig,
+		dbConfig:               dbConnectivity,
+		feesConfig:             feesConfig,
+		timingConfig:           timingConfig,
+		attestationConfig:      attestationConfig,
+		serverConfig:           serverConfig,
+		commitmentSourceConfig: commitmentSourceConfig,
 	}, nil
 }
 
+// Return Config instances from a config array for running multiple
+// attestation services in one process. Each array entry has the format
+// of a single conf file and configures an independent attestation service
+// Entries must not share a staychain init tx
+func NewConfigs(conf []byte) ([]*Config, error) {
+	var confs []json.RawMessage
+	if unmarshalErr := json.Unmarshal(conf, &confs); unmarshalErr != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %v", ErrorBadDataConfigs, unmarshalErr))
+	}
+	if len(confs) == 0 {
+		return nil, errors.New(ErrorBadDataConfigs)
+	}
+
+	var configs []*Config
+	shutdown := func() {
+		for _, config := range configs {
+			config.MainClient().Shutdown()
+		}
+	}
+	initTxs := make(map[string]bool)
+	for i, entryConf := range confs {
+		config, configErr := NewConfig(entryConf)
+		if configErr != nil {
+			shutdown()
+			return nil, errors.New(fmt.Sprintf("%v (config %d)", configErr, i))
+		}
+		configs = append(configs, config)
+		if config.InitTx() != "" {
+			if initTxs[config.InitTx()] {
+				shutdown()
+				return nil, errors.New(fmt.Sprintf("%s: %s (config %d)", ErrorConfigsDuplicateInitTx, config.InitTx(), i))
+			}
+			initTxs[config.InitTx()] = true
+		}
+	}
+	return configs, nil
+}
+
 // Return SidechainClient depending on whether unit test config or actual config
 func NewClientFromConfig(chainName string, isTest bool, customConf ...[]byte) clients.SidechainClient {
 	// mock side client rpc for unit-test / regtest
@@ -296,21 +394,59 @@ const (
 	DbPortName     = "port"
 	DbNameName     = "name"
 	DbName         = "db"
+
+	DbMaxPoolSizeName          = "maxPoolSize"
+	DbSocketTimeoutSecondsName = "socketTimeoutSeconds"
+	DbReadConcernName          = "readConcern"
+	DbWriteConcernName         = "writeConcern"
+	DbReadPreferenceName       = "readPreference"
+)
+
+// default db config values
+const (
+	DefaultDbMaxPoolSize          = 100
+	DefaultDbSocketTimeoutSeconds = 30
+	DefaultDbReadConcern          = "local"
+	DefaultDbWriteConcern         = "1"
+	DefaultDbReadPreference       = "primary"
+)
+
+// db config errors
+const (
+	ErrorBadDataDbMaxPoolSize   = "invalid value for db max pool size. positive integer allowed only"
+	ErrorBadDataDbSocketTimeout = "invalid value for db socket timeout. positive integer allowed only"
+	ErrorBadDataDbReadConcern   = "invalid value for db read concern. 'local', 'available', 'majority', 'linearizable' and 'snapshot' allowed only"
+	ErrorBadDataDbWriteConcern  = "invalid value for db write concern. 'majority' or non negative integer allowed only"
+	ErrorBadDataDbReadPref      = "invalid value for db read preference. 'primary', 'primaryPreferred', 'secondary', 'secondaryPreferred' and 'nearest' allowed only"
 )
 
 // DbConfig struct
-// Database connectivity details
+// Database connectivity details and connection pool settings
 type DbConfig struct {
 	User     string
 	Password string
 	Host     string
 	Port     string
 	Name     string
+
+	// maximum number of connections in the client connection pool
+	MaxPoolSize uint64
+
+	// timeout in seconds for socket reads and writes
+	SocketTimeoutSeconds int
+
+	// read concern level and write concern acknowledgement
+	ReadConcern  string
+	WriteConcern string
+
+	// read preference routing reads to the primary or secondaries
+	ReadPreference string
 }
 
 // Return DbConfig from conf options
-// If DbName exists in the config, then all fields are compulsory
-// IF DbName does not exist, then all config fields are empty
+// If DbName exists in the config, then all connectivity fields are compulsory
+// IF DbName does not exist, then all connectivity fields are empty
+// Connection pool settings are optional and validated if set
 func GetDbConfig(conf []byte) (DbConfig, error) {
 
 	// db connectivity parameters
@@ -340,29 +476,119 @@ func GetDbConfig(conf []byte) (DbConfig, error) {
 		return DbConfig{}, nameErr
 	}
 
+	// db connection pool parameters
+
+	maxPoolSize := uint64(DefaultDbMaxPoolSize)
+	maxPoolSizeStr := TryGetParamFromConf(DbName, DbMaxPoolSizeName, conf)
+	if maxPoolSizeStr != "" {
+		maxPoolSizeInt, maxPoolSizeErr := strconv.ParseUint(maxPoolSizeStr, 10, 64)
+		if maxPoolSizeErr != nil || maxPoolSizeInt == 0 {
+			return DbConfig{}, errors.New(fmt.Sprintf("%s: %s", ErrorBadDataDbMaxPoolSize, maxPoolSizeStr))
+		}
+		maxPoolSize = maxPoolSizeInt
+	}
+
+	socketTimeout := DefaultDbSocketTimeoutSeconds
+	socketTimeoutStr := TryGetParamFromConf(DbName, DbSocketTimeoutSecondsName, conf)
+	if socketTimeoutStr != "" {
+		socketTimeoutInt, socketTimeoutErr := strconv.Atoi(socketTimeoutStr)
+		if socketTimeoutErr != nil || socketTimeoutInt <= 0 {
+			return DbConfig{}, errors.New(fmt.Sprintf("%s: %s", ErrorBadDataDbSocketTimeout, socketTimeoutStr))
+		}
+		socketTimeout = socketTimeoutInt
+	}
+
+	readConcern := DefaultDbReadConcern
+	readConcernStr := TryGetParamFromConf(DbName, DbReadConcernName, conf)
+	if readConcernStr != "" {
+		switch readConcernStr {
+		case "local", "available", "majority", "linearizable", "snapshot":
+			readConcern = readConcernStr
+		default:
+			return DbConfig{}, errors.New(fmt.Sprintf("%s: %s", ErrorBadDataDbReadConcern, readConcernStr))
+		}
+	}
+
+	writeConcern := DefaultDbWriteConcern
+	writeConcernStr := TryGetParamFromConf(DbName, DbWriteConcernName, conf)
+	if writeConcernStr != "" {
+		if writeConcernStr != "majority" {
+			writeConcernInt, writeConcernErr := strconv.Atoi(writeConcernStr)
+			if writeConcernErr != nil || writeConcernInt < 0 {
+				return DbConfig{}, errors.New(fmt.Sprintf("%s: %s", ErrorBadDataDbWriteConcern, writeConcernStr))
+			}
+		}
+		writeConcern = writeConcernStr
+	}
+
+	readPreference := DefaultDbReadPreference
+	readPreferenceStr := TryGetParamFromConf(DbName, DbReadPreferenceName, conf)
+	if readPreferenceStr != "" {
+		switch readPreferenceStr {
+		case "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+			readPreference = readPreferenceStr
+		default:
+			return DbConfig{}, errors.New(fmt.Sprintf("%s: %s", ErrorBadDataDbReadPref, readPreferenceStr))
+		}
+	}
+
 	return DbConfig{
-		User:     user,
-		Password: password,
-		Host:     host,
-		Port:     port,
-		Name:     name,
+		User:                 user,
+		Password:             password,
+		Host:                 host,
+		Port:                 port,
+		Name:                 name,
+		MaxPoolSize:          maxPoolSize,
+		SocketTimeoutSeconds: socketTimeout,
+		ReadConcern:          readConcern,
+		WriteConcern:         writeConcern,
+		ReadPreference:       readPreference,
 	}, nil
 }
 
 // fee config parameter names
 const (
-	FeesName             = "fees"
-	FeesMinFeeName       = "minFee"
-	FeesMaxFeeName       = "maxFee"
-	FeesFeeIncrementName = "feeIncrement"
+	FeesName              = "fees"
+	FeesMinFeeName        = "minFee"
+	FeesMaxFeeName        = "maxFee"
+	FeesFeeIncrementName  = "feeIncrement"
+	FeesFeeApiUrlsName    = "feeApiUrls"
+	FeesFeeApiTypesName   = "feeApiTypes"
+	FeesBlockFeeFloorName = "blockFeeFloor"
+	FeesFixedFeeName      = "fixedFee"
 )
 
 // FeeConfig struct
 // Configuration on fee limits for attestation service
+// and ordered list of fee APIs with the fee type field
+// to use from the response of each fee API
 type FeesConfig struct {
 	MinFee       int
 	MaxFee       int
 	FeeIncrement int
+	FeeApiUrls   []string
+	FeeApiTypes  []string
+
+	// feerate of the latest block (median/min) used as fee floor
+	// empty value disables the block fee floor
+	BlockFeeFloor string
+
+	// fixed fee per byte used instead of querying the fee APIs
+	// non positive values disable the fixed fee
+	FixedFee int
+}
+
+// Split comma separated config value to trimmed string slice
+// Empty config value returns a nil slice
+func splitConfigList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	values := strings.Split(value, ",")
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values
 }
 
 // Return FeeConfig from conf options
@@ -399,10 +625,27 @@ func GetFeesConfig(conf []byte) FeesConfig {
 		feeIncrement = feeIncrementInt
 	}
 
+	feeApiUrls := splitConfigList(TryGetParamFromConf(FeesName, FeesFeeApiUrlsName, conf))
+	feeApiTypes := splitConfigList(TryGetParamFromConf(FeesName, FeesFeeApiTypesName, conf))
+	blockFeeFloor := TryGetParamFromConf(FeesName, FeesBlockFeeFloorName, conf)
+
+	fixedFeeStr := TryGetParamFromConf(FeesName, FeesFixedFeeName, conf)
+	var fixedFee int
+	fixedFeeInt, fixedFeeErr := strconv.Atoi(fixedFeeStr)
+	if fixedFeeErr != nil {
+		fixedFee = -1
+	} else {
+		fixedFee = fixedFeeInt
+	}
+
 	return FeesConfig{
-		MinFee:       minFee,
-		MaxFee:       maxFee,
-		FeeIncrement: feeIncrement,
+		MinFee:        minFee,
+		MaxFee:        maxFee,
+		FeeIncrement:  feeIncrement,
+		FeeApiUrls:    feeApiUrls,
+		FeeApiTypes:   feeApiTypes,
+		BlockFeeFloor: blockFeeFloor,
+		FixedFee:      fixedFee,
 	}
 }
 
@@ -411,6 +654,16 @@ const (
 	TimingName                         = "timing"
 	TimingNewAttestationMinutesName    = "newAttestationMinutes"
 	TimingHandleUnconfirmedMinutesName = "handleUnconfirmedMinutes"
+	TimingSignaturesSecondsName        = "signaturesSeconds"
+	TimingAcceptanceWindowSecondsName  = "acceptanceWindowSeconds"
+	TimingScheduleName                 = "schedule"
+	TimingMissedScheduleName           = "missedSchedule"
+)
+
+// missed schedule options
+const (
+	MissedScheduleSkip    = "skip"    // wait for the next schedule time
+	MissedScheduleCatchUp = "catchup" // attest immediately once
 )
 
 // Timing config struct
@@ -418,6 +671,15 @@ const (
 type TimingConfig struct {
 	NewAttestationMinutes    int
 	HandleUnconfirmedMinutes int
+	SignaturesSeconds        int
+	AcceptanceWindowSeconds  int
+
+	// cron-like schedule of UTC attestation times used
+	// instead of the new attestation interval, if set
+	Schedule string
+
+	// handling of schedule times missed, e.g. while awaiting confirmation
+	MissedSchedule string
 }
 
 // Return TimingConfig from conf options
@@ -441,9 +703,507 @@ func GetTimingConfig(conf []byte) TimingConfig {
 		uncMin = uncMinInt
 	}
 
+	sigSecStr := TryGetParamFromConf(TimingName, TimingSignaturesSecondsName, conf)
+	var sigSec int
+	sigSecInt, sigSecIntErr := strconv.Atoi(sigSecStr)
+	if sigSecIntErr != nil {
+		sigSec = -1
+	} else {
+		sigSec = sigSecInt
+	}
+
+	windowSecStr := TryGetParamFromConf(TimingName, TimingAcceptanceWindowSecondsName, conf)
+	var windowSec int
+	windowSecInt, windowSecIntErr := strconv.Atoi(windowSecStr)
+	if windowSecIntErr != nil {
+		windowSec = -1
+	} else {
+		windowSec = windowSecInt
+	}
+
+	schedule := TryGetParamFromConf(TimingName, TimingScheduleName, conf)
+	missedSchedule := TryGetParamFromConf(TimingName, TimingMissedScheduleName, conf)
+
 	return TimingConfig{
 		NewAttestationMinutes:    attMin,
 		HandleUnconfirmedMinutes: uncMin,
+		SignaturesSeconds:        sigSec,
+		AcceptanceWindowSeconds:  windowSec,
+		Schedule:                 schedule,
+		MissedSchedule:           missedSchedule,
+	}
+}
+
+// attestation config parameter names
+const (
+	AttestationName                        = "attestation"
+	AttestationSkipDuplicateCommitmentName = "skipDuplicateCommitment"
+	AttestationCheckpointDepthName         = "checkpointDepth"
+	AttestationScanUtxoSetName             = "scanUtxoSet"
+	AttestationAddressLabelPrefixName      = "addressLabelPrefix"
+	AttestationMaxFeeBumpsName             = "maxFeeBumps"
+	AttestationHaltOnMaxFeeBumpsName       = "haltOnMaxFeeBumps"
+	AttestationLocktimeName                = "locktime"
+	AttestationDescriptorWalletName        = "descriptorWallet"
+	AttestationSendRetriesName             = "sendRetries"
+	AttestationRedundantOutputsName        = "redundantOutputs"
+	AttestationDebugName                   = "debug"
+	AttestationAmountName                  = "attestationAmount"
+	AttestationReserveAddressName          = "reserveAddress"
+	AttestationMinUnspentConfirmationsName = "minUnspentConfirmations"
+	AttestationStaleCommitmentCyclesName   = "staleCommitmentCycles"
+	AttestationAttestStaleCommitmentName   = "attestStaleCommitment"
+	AttestationZeroCommitmentName          = "zeroCommitment"
+	AttestationUnspentSelectionName        = "unspentSelection"
+	AttestationJournalPathName             = "journalPath"
+	AttestationJournalKeyName              = "journalKey"
+	AttestationAmountToleranceName         = "amountTolerance"
+)
+
+// zero commitment merkle root behaviours
+const (
+	ZeroCommitmentAttest   = "attest"   // attest the zero merkle root as is
+	ZeroCommitmentSkip     = "skip"     // skip attesting while the merkle root is zero
+	ZeroCommitmentSentinel = "sentinel" // attest the zero commitment sentinel instead
+)
+
+// attestation unspent selection strategies
+const (
+	UnspentSelectionLatest  = "latest"  // spend the most recent subchain unspent
+	UnspentSelectionLargest = "largest" // spend the largest value subchain unspent
+	UnspentSelectionOldest  = "oldest"  // spend the least recent subchain unspent
+	UnspentSelectionMerge   = "merge"   // spend and consolidate all subchain unspents
+)
+
+// default attestation config values
+const (
+	DefaultSkipDuplicateCommitment = true
+	DefaultScanUtxoSet             = false
+	DefaultAddressLabelPrefix      = "mainstay"
+	DefaultHaltOnMaxFeeBumps       = false
+	DefaultDescriptorWallet        = false
+	DefaultDebug                   = false
+	DefaultAttestStaleCommitment   = false
+)
+
+// Attestation config struct
+// Configuration on the behaviour of the attestation service
+type AttestationConfig struct {
+	// skip attesting client commitments that have already been attested
+	SkipDuplicateCommitment bool
+
+	// number of attestations behind the staychain tip at which
+	// a checkpoint is recorded - non positive values disable this
+	CheckpointDepth int
+
+	// scan the utxo set for attestation unspents instead of
+	// importing each attestation address to the wallet
+	ScanUtxoSet bool
+
+	// label prefix of attestation addresses imported to the wallet
+	AddressLabelPrefix string
+
+	// maximum number of fee bumps of an unconfirmed attestation
+	// before giving up - non positive values disable this
+	MaxFeeBumps int
+
+	// stop the attestation service when max fee bumps are reached
+	// instead of waiting for confirmation without bumping
+	HaltOnMaxFeeBumps bool
+
+	// attestation transaction locktime - either a fixed value
+	// or "height" to use the current block height
+	Locktime string
+
+	// import attestation addresses using importdescriptors
+	// descriptor wallets are also detected from the wallet info
+	DescriptorWallet bool
+
+	// number of retries when sending an attestation fails with a
+	// transient relay error - non positive values disable retrying
+	SendRetries int
+
+	// additional outputs encoding the attestation commitment
+	// for redundancy, e.g. opreturn - none by default
+	RedundantOutputs []string
+
+	// log a trace of the attestation transactions built and signed
+	Debug bool
+
+	// fixed staychain output amount in satoshis with any excess paid
+	// to the reserve address - non positive values disable this
+	AttestationAmount int64
+
+	// reserve address receiving the excess over the attestation amount
+	ReserveAddress string
+
+	// minimum confirmations of the staychain unspent the next attestation
+	// is built on - zero allows chaining on unconfirmed attestations
+	MinUnspentConfirmations int
+
+	// number of consecutive attestation cycles with an unchanged client
+	// commitment after which attesting is paused with an alert, as the
+	// commitment source is likely stuck - non positive values disable this
+	StaleCommitmentCycles int
+
+	// override the stale commitment pause and keep attesting, still alerting
+	AttestStaleCommitment bool
+
+	// behaviour when the client commitment merkle root is the zero hash,
+	// either attest, skip or sentinel - attested as is if not set
+	ZeroCommitment string
+
+	// strategy selecting the subchain unspents spent by the next attestation
+	// when multiple exist, either latest, largest, oldest or merge - latest
+	// if not set
+	UnspentSelection string
+
+	// path of the encrypted local journal recording the address and redeem
+	// script generated for each commitment, used for recovery if the db and
+	// wallet are lost - no journal is kept if not set
+	JournalPath string
+
+	// hex encoded 32 byte key encrypting journal entries
+	JournalKey string
+
+	// maximum difference in satoshis between the amount of the staychain
+	// unspent spent by the next attestation and the amount of the previous
+	// attestation before refusing to attest - negative values disable this
+	AmountTolerance int64
+}
+
+// Return AttestationConfig from conf options
+// All Attestation Config fields are optional
+func GetAttestationConfig(conf []byte) AttestationConfig {
+	skipStr := TryGetParamFromConf(AttestationName, AttestationSkipDuplicateCommitmentName, conf)
+	skip, skipErr := strconv.ParseBool(skipStr)
+	if skipErr != nil {
+		skip = DefaultSkipDuplicateCommitment
+	}
+
+	depthStr := TryGetParamFromConf(AttestationName, AttestationCheckpointDepthName, conf)
+	var depth int
+	depthInt, depthIntErr := strconv.Atoi(depthStr)
+	if depthIntErr != nil {
+		depth = -1
+	} else {
+		depth = depthInt
+	}
+
+	scanStr := TryGetParamFromConf(AttestationName, AttestationScanUtxoSetName, conf)
+	scan, scanErr := strconv.ParseBool(scanStr)
+	if scanErr != nil {
+		scan = DefaultScanUtxoSet
+	}
+
+	labelPrefix := TryGetParamFromConf(AttestationName, AttestationAddressLabelPrefixName, conf)
+	if labelPrefix == "" {
+		labelPrefix = DefaultAddressLabelPrefix
+	}
+
+	bumpsStr := TryGetParamFromConf(AttestationName, AttestationMaxFeeBumpsName, conf)
+	var bumps int
+	bumpsInt, bumpsIntErr := strconv.Atoi(bumpsStr)
+	if bumpsIntErr != nil {
+		bumps = -1
+	} else {
+		bumps = bumpsInt
+	}
+
+	haltStr := TryGetParamFromConf(AttestationName, AttestationHaltOnMaxFeeBumpsName, conf)
+	halt, haltErr := strconv.ParseBool(haltStr)
+	if haltErr != nil {
+		halt = DefaultHaltOnMaxFeeBumps
+	}
+
+	descriptorStr := TryGetParamFromConf(AttestationName, AttestationDescriptorWalletName, conf)
+	descriptorWallet, descriptorErr := strconv.ParseBool(descriptorStr)
+	if descriptorErr != nil {
+		descriptorWallet = DefaultDescriptorWallet
+	}
+
+	retriesStr := TryGetParamFromConf(AttestationName, AttestationSendRetriesName, conf)
+	var retries int
+	retriesInt, retriesIntErr := strconv.Atoi(retriesStr)
+	if retriesIntErr != nil {
+		retries = -1
+	} else {
+		retries = retriesInt
+	}
+
+	var redundantOutputs []string
+	redundantStr := TryGetParamFromConf(AttestationName, AttestationRedundantOutputsName, conf)
+	if redundantStr != "" {
+		redundantOutputs = strings.Split(redundantStr, ",")
+		for i := range redundantOutputs {
+			redundantOutputs[i] = strings.TrimSpace(redundantOutputs[i])
+		}
+	}
+
+	debugStr := TryGetParamFromConf(AttestationName, AttestationDebugName, conf)
+	debug, debugErr := strconv.ParseBool(debugStr)
+	if debugErr != nil {
+		debug = DefaultDebug
+	}
+
+	amountStr := TryGetParamFromConf(AttestationName, AttestationAmountName, conf)
+	var amount int64
+	amountInt, amountIntErr := strconv.ParseInt(amountStr, 10, 64)
+	if amountIntErr != nil {
+		amount = -1
+	} else {
+		amount = amountInt
+	}
+
+	minConfStr := TryGetParamFromConf(AttestationName, AttestationMinUnspentConfirmationsName, conf)
+	var minConf int
+	minConfInt, minConfIntErr := strconv.Atoi(minConfStr)
+	if minConfIntErr != nil {
+		minConf = -1
+	} else {
+		minConf = minConfInt
+	}
+
+	staleStr := TryGetParamFromConf(AttestationName, AttestationStaleCommitmentCyclesName, conf)
+	var stale int
+	staleInt, staleIntErr := strconv.Atoi(staleStr)
+	if staleIntErr != nil {
+		stale = -1
+	} else {
+		stale = staleInt
+	}
+
+	attestStaleStr := TryGetParamFromConf(AttestationName, AttestationAttestStaleCommitmentName, conf)
+	attestStale, attestStaleErr := strconv.ParseBool(attestStaleStr)
+	if attestStaleErr != nil {
+		attestStale = DefaultAttestStaleCommitment
+	}
+
+	toleranceStr := TryGetParamFromConf(AttestationName, AttestationAmountToleranceName, conf)
+	var tolerance int64
+	toleranceInt, toleranceIntErr := strconv.ParseInt(toleranceStr, 10, 64)
+	if toleranceIntErr != nil {
+		tolerance = -1
+	} else {
+		tolerance = toleranceInt
+	}
+
+	return AttestationConfig{
+		SkipDuplicateCommitment: skip,
+		CheckpointDepth:         depth,
+		ScanUtxoSet:             scan,
+		AddressLabelPrefix:      labelPrefix,
+		MaxFeeBumps:             bumps,
+		HaltOnMaxFeeBumps:       halt,
+		Locktime:                TryGetParamFromConf(AttestationName, AttestationLocktimeName, conf),
+		DescriptorWallet:        descriptorWallet,
+		SendRetries:             retries,
+		RedundantOutputs:        redundantOutputs,
+		Debug:                   debug,
+		AttestationAmount:       amount,
+		ReserveAddress:          TryGetParamFromConf(AttestationName, AttestationReserveAddressName, conf),
+		MinUnspentConfirmations: minConf,
+		StaleCommitmentCycles:   stale,
+		AttestStaleCommitment:   attestStale,
+		ZeroCommitment:          TryGetParamFromConf(AttestationName, AttestationZeroCommitmentName, conf),
+		UnspentSelection:        TryGetParamFromConf(AttestationName, AttestationUnspentSelectionName, conf),
+		JournalPath:             TryGetParamFromConf(AttestationName, AttestationJournalPathName, conf),
+		JournalKey:              TryGetParamFromConf(AttestationName, AttestationJournalKeyName, conf),
+		AmountTolerance:         tolerance,
+	}
+}
+
+// server config parameter names
+const (
+	ServerName                          = "server"
+	ServerCommitmentIntervalSecondsName = "commitmentIntervalSeconds"
+	ServerOverridePositionName          = "overridePosition"
+	ServerTreeWidthName                 = "treeWidth"
+	ServerCommitmentExpirySecondsName   = "commitmentExpirySeconds"
+	ServerMaxPayloadBytesName           = "maxPayloadBytes"
+	ServerRetentionSecondsName          = "retentionSeconds"
+	ServerCompactionIntervalSecondsName = "compactionIntervalSeconds"
+	ServerOperatorPositionName          = "operatorPosition"
+	ServerReceiptKeyName                = "receiptKey"
+	ServerLeafOrderingName              = "leafOrdering"
+	ServerCommitmentMessagePrefixName   = "commitmentMessagePrefix"
+	ServerWriteBufferPathName           = "writeBufferPath"
+	ServerMaxClientPositionName         = "maxClientPosition"
+	ServerReadCacheSecondsName          = "readCacheSeconds"
+)
+
+// commitment tree leaf orderings
+const (
+	LeafOrderingPosition   = "position"   // leaf index is the client position
+	LeafOrderingSubmission = "submission" // leaves ordered by client commitment update time
+)
+
+// Server config struct
+// Configuration on handling client commitments received by the server
+type ServerConfig struct {
+	CommitmentIntervalSeconds int
+
+	// client position reserved for external override commitments
+	// negative values disable override commitments
+	OverridePosition int
+
+	// fixed number of commitment tree leaves with missing positions
+	// padded with the zero hash - non positive values disable this
+	TreeWidth int
+
+	// age after which client commitments are expired and excluded
+	// from the commitment tree - non positive values disable this
+	CommitmentExpirySeconds int
+
+	// maximum size of signed client commitment messages received
+	MaxPayloadBytes int
+
+	// age after which merkle commitment records of unconfirmed
+	// attestations are compacted - non positive values disable this
+	RetentionSeconds int
+
+	// interval between compaction runs
+	CompactionIntervalSeconds int
+
+	// client position reserved for the operator commitment injected
+	// each attestation cycle - negative values disable this
+	OperatorPosition int
+
+	// operator private key in WIF format used to sign receipts
+	// of confirmed attestations - empty value disables receipts
+	ReceiptKey string
+
+	// ordering of client commitments in the commitment tree leaves
+	// position or submission - empty value defaults to position
+	LeafOrdering string
+
+	// prefix of the message signed by clients for each commitment
+	// empty value verifies signatures over the raw commitment
+	CommitmentMessagePrefix string
+
+	// path of the local file buffering db writes that failed while
+	// the db is unavailable - empty value disables write buffering
+	WriteBufferPath string
+
+	// maximum client position accepted, bounding the size of the
+	// commitment tree - negative values default to the server default
+	MaxClientPosition int
+
+	// age after which cached reads of the latest attestation and client
+	// commitment are refreshed from the db - non positive values disable this
+	ReadCacheSeconds int
+}
+
+// Return ServerConfig from conf options
+// All Server Config fields are optional
+func GetServerConfig(conf []byte) ServerConfig {
+	intervalStr := TryGetParamFromConf(ServerName, ServerCommitmentIntervalSecondsName, conf)
+	var interval int
+	intervalInt, intervalIntErr := strconv.Atoi(intervalStr)
+	if intervalIntErr != nil {
+		interval = -1
+	} else {
+		interval = intervalInt
+	}
+
+	overrideStr := TryGetParamFromConf(ServerName, ServerOverridePositionName, conf)
+	var override int
+	overrideInt, overrideIntErr := strconv.Atoi(overrideStr)
+	if overrideIntErr != nil {
+		override = -1
+	} else {
+		override = overrideInt
+	}
+
+	widthStr := TryGetParamFromConf(ServerName, ServerTreeWidthName, conf)
+	var width int
+	widthInt, widthIntErr := strconv.Atoi(widthStr)
+	if widthIntErr != nil {
+		width = -1
+	} else {
+		width = widthInt
+	}
+
+	expiryStr := TryGetParamFromConf(ServerName, ServerCommitmentExpirySecondsName, conf)
+	var expiry int
+	expiryInt, expiryIntErr := strconv.Atoi(expiryStr)
+	if expiryIntErr != nil {
+		expiry = -1
+	} else {
+		expiry = expiryInt
+	}
+
+	maxPayloadStr := TryGetParamFromConf(ServerName, ServerMaxPayloadBytesName, conf)
+	var maxPayload int
+	maxPayloadInt, maxPayloadIntErr := strconv.Atoi(maxPayloadStr)
+	if maxPayloadIntErr != nil {
+		maxPayload = -1
+	} else {
+		maxPayload = maxPayloadInt
+	}
+
+	retentionStr := TryGetParamFromConf(ServerName, ServerRetentionSecondsName, conf)
+	var retention int
+	retentionInt, retentionIntErr := strconv.Atoi(retentionStr)
+	if retentionIntErr != nil {
+		retention = -1
+	} else {
+		retention = retentionInt
+	}
+
+	compactionStr := TryGetParamFromConf(ServerName, ServerCompactionIntervalSecondsName, conf)
+	var compaction int
+	compactionInt, compactionIntErr := strconv.Atoi(compactionStr)
+	if compactionIntErr != nil {
+		compaction = -1
+	} else {
+		compaction = compactionInt
+	}
+
+	operatorStr := TryGetParamFromConf(ServerName, ServerOperatorPositionName, conf)
+	var operator int
+	operatorInt, operatorIntErr := strconv.Atoi(operatorStr)
+	if operatorIntErr != nil {
+		operator = -1
+	} else {
+		operator = operatorInt
+	}
+
+	receiptKey := TryGetParamFromConf(ServerName, ServerReceiptKeyName, conf)
+
+	maxPositionStr := TryGetParamFromConf(ServerName, ServerMaxClientPositionName, conf)
+	var maxPosition int
+	maxPositionInt, maxPositionIntErr := strconv.Atoi(maxPositionStr)
+	if maxPositionIntErr != nil {
+		maxPosition = -1
+	} else {
+		maxPosition = maxPositionInt
+	}
+
+	readCacheStr := TryGetParamFromConf(ServerName, ServerReadCacheSecondsName, conf)
+	var readCache int
+	readCacheInt, readCacheIntErr := strconv.Atoi(readCacheStr)
+	if readCacheIntErr != nil {
+		readCache = -1
+	} else {
+		readCache = readCacheInt
+	}
+
+	return ServerConfig{
+		CommitmentIntervalSeconds: interval,
+		OverridePosition:          override,
+		TreeWidth:                 width,
+		CommitmentExpirySeconds:   expiry,
+		MaxPayloadBytes:           maxPayload,
+		RetentionSeconds:          retention,
+		CompactionIntervalSeconds: compaction,
+		OperatorPosition:          operator,
+		ReceiptKey:                receiptKey,
+		LeafOrdering:              TryGetParamFromConf(ServerName, ServerLeafOrderingName, conf),
+		CommitmentMessagePrefix:   TryGetParamFromConf(ServerName, ServerCommitmentMessagePrefixName, conf),
+		WriteBufferPath:           TryGetParamFromConf(ServerName, ServerWriteBufferPathName, conf),
+		MaxClientPosition:         maxPosition,
+		ReadCacheSeconds:          readCache,
 	}
 }
 
@@ -452,6 +1212,22 @@ const (
 	SignerName          = "signer"
 	SignerPublisherName = "publisher"
 	SignerSignersName   = "signers"
+	SignerPrimariesName = "primaries"
+
+	SignerCommandName               = "command"
+	SignerCommandTimeoutSecondsName = "commandTimeoutSeconds"
+
+	SignerCompactPreImagesName = "compactPreImages"
+
+	SignerTopicNamespaceName = "topicNamespace"
+
+	SignerUnreachableName = "unreachable"
+)
+
+// signers unreachable policies
+const (
+	SignersUnreachableRetry = "retry" // retry collecting sigs with backoff
+	SignersUnreachableLocal = "local" // proceed with local sigs if they meet quorum
 )
 
 // Signer config struct
@@ -463,25 +1239,123 @@ type SignerConfig struct {
 
 	// signer addresses
 	Signers []string
+
+	// number of primary signers, i.e. the first signers in order of
+	// priority, that are asked to sign before any backup signers
+	Primaries int
+
+	// external signer command used instead of zmq signers
+	Command string
+
+	// timeout of external signer command
+	CommandTimeoutSeconds int
+
+	// send signers the unsigned transaction and the redeem script
+	// of each input instead of the full pre-image of each input
+	CompactPreImages bool
+
+	// namespace, e.g. a chain id, prefixed to zmq topics so that
+	// signers of different services can share zmq hosts
+	TopicNamespace string
+
+	// policy when no signer responds with signatures - retry by default
+	Unreachable string
 }
 
 // Return SignerConfig from conf options
 // If SignerName exists in conf, SignerSignersName is compsulsory
+// unless an external signer SignerCommandName is set
 // Every other Signer Config field is optional
 func GetSignerConfig(conf []byte) (SignerConfig, error) {
+	command := TryGetParamFromConf(SignerName, SignerCommandName, conf)
+
+	timeoutStr := TryGetParamFromConf(SignerName, SignerCommandTimeoutSecondsName, conf)
+	var timeout int
+	timeoutInt, timeoutIntErr := strconv.Atoi(timeoutStr)
+	if timeoutIntErr != nil {
+		timeout = -1
+	} else {
+		timeout = timeoutInt
+	}
+
 	// get signer node addresses
+	var signers []string
 	signersStr, signersErr := GetParamFromConf(SignerName, SignerSignersName, conf)
 	if signersErr != nil {
-		return SignerConfig{}, signersErr
-	}
-	signers := strings.Split(signersStr, ",")
-	for i := range signers {
-		signers[i] = strings.TrimSpace(signers[i])
+		if command == "" {
+			return SignerConfig{}, signersErr
+		}
+	} else {
+		signers = strings.Split(signersStr, ",")
+		for i := range signers {
+			signers[i] = strings.TrimSpace(signers[i])
+		}
 	}
 	publisher := TryGetParamFromConf(SignerName, SignerPublisherName, conf)
 
+	primariesStr := TryGetParamFromConf(SignerName, SignerPrimariesName, conf)
+	var primaries int
+	primariesInt, primariesIntErr := strconv.Atoi(primariesStr)
+	if primariesIntErr != nil {
+		primaries = -1
+	} else {
+		primaries = primariesInt
+	}
+
+	compactStr := TryGetParamFromConf(SignerName, SignerCompactPreImagesName, conf)
+	compact, compactErr := strconv.ParseBool(compactStr)
+	if compactErr != nil {
+		compact = false
+	}
+
+	topicNamespace := TryGetParamFromConf(SignerName, SignerTopicNamespaceName, conf)
+
 	return SignerConfig{
-		Publisher: publisher,
-		Signers:   signers,
+		Publisher:             publisher,
+		Signers:               signers,
+		Primaries:             primaries,
+		Command:               command,
+		CommandTimeoutSeconds: timeout,
+		CompactPreImages:      compact,
+		TopicNamespace:        topicNamespace,
+		Unreachable:           TryGetParamFromConf(SignerName, SignerUnreachableName, conf),
 	}, nil
 }
+
+// commitment source config parameter names
+const (
+	CommitmentSourceName        = "commitmentSource"
+	CommitmentSourceAddressName = "address"
+	CommitmentSourceTopicName   = "topic"
+	CommitmentSourceTypeName    = "type"
+)
+
+// commitment source message queue types
+const (
+	CommitmentSourceZmq  = "zmq"  // subscribe to a zmq publisher topic
+	CommitmentSourceNats = "nats" // subscribe to a nats server subject
+)
+
+// CommitmentSource config struct
+// Configuration of the message queue that signed client
+// commitments are received from - disabled if no address set
+type CommitmentSourceConfig struct {
+	// host:port of the zmq publisher or nats server url
+	Address string
+
+	// topic or subject that signed client commitments are published to
+	Topic string
+
+	// message queue type - zmq if not set
+	Type string
+}
+
+// Return CommitmentSourceConfig from conf options
+// All CommitmentSource Config fields are optional
+func GetCommitmentSourceConfig(conf []byte) CommitmentSourceConfig {
+	return CommitmentSourceConfig{
+		Address: TryGetParamFromConf(CommitmentSourceName, CommitmentSourceAddressName, conf),
+		Topic:   TryGetParamFromConf(CommitmentSourceName, CommitmentSourceTopicName, conf),
+		Type:    TryGetParamFromConf(CommitmentSourceName, CommitmentSourceTypeName, conf),
+	}
+}
@@ -7,6 +7,9 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -215,14 +218,152 @@ func TestConfigActual(t *testing.T) {
 	assert.Equal(t, &chaincfg.RegressionNetParams, config.MainChainCfg())
 	assert.Equal(t, []string{"127.0.0.1:12345", "127.0.0.1:12346"}, config.SignerConfig().Signers)
 	assert.Equal(t, DbConfig{
-		User:     "username1",
-		Password: "password2",
-		Host:     "localhost",
-		Port:     "27017",
-		Name:     "mainstay",
+		User:                 "username1",
+		Password:             "password2",
+		Host:                 "localhost",
+		Port:                 "27017",
+		Name:                 "mainstay",
+		MaxPoolSize:          DefaultDbMaxPoolSize,
+		SocketTimeoutSeconds: DefaultDbSocketTimeoutSeconds,
+		ReadConcern:          DefaultDbReadConcern,
+		WriteConcern:         DefaultDbWriteConcern,
+		ReadPreference:       DefaultDbReadPreference,
 	}, config.DbConfig())
 }
 
+// Test Config rpc cookie authentication
+func TestConfigRpcCookie(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "mainstay-cookie")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	cookiePath := filepath.Join(dir, ".cookie")
+	confFmt := `
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpccookie": "%s",
+            "chain": "regtest"
+        }
+    }
+    `
+
+	// test missing cookie file
+	_, configErr := NewConfig([]byte(fmt.Sprintf(confFmt, cookiePath)))
+	assert.NotEqual(t, nil, configErr)
+
+	// test malformed cookie file
+	assert.Equal(t, nil, ioutil.WriteFile(cookiePath, []byte("cookie"), 0600))
+	_, configErr = NewConfig([]byte(fmt.Sprintf(confFmt, cookiePath)))
+	assert.NotEqual(t, nil, configErr)
+
+	// test cookie used instead of user and password
+	assert.Equal(t, nil, ioutil.WriteFile(cookiePath, []byte("__cookie__:abcdef0123\n"), 0600))
+	user, pass, cookieErr := readCookieFile(cookiePath)
+	assert.Equal(t, nil, cookieErr)
+	assert.Equal(t, "__cookie__", user)
+	assert.Equal(t, "abcdef0123", pass)
+	config, configErr := NewConfig([]byte(fmt.Sprintf(confFmt, cookiePath)))
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, true, config.MainClient() != nil)
+	assert.Equal(t, &chaincfg.RegressionNetParams, config.MainChainCfg())
+}
+
+// Test Config rpc wallet selection
+func TestConfigRpcWallet(t *testing.T) {
+	var testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest",
+            "wallet": "mainstay"
+        }
+    }
+    `)
+	config, configErr := NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, "mainstay", config.MainWallet())
+
+	// test wallet endpoint path
+	assert.Equal(t, "localhost:18443", walletHost("localhost:18443", ""))
+	assert.Equal(t, "localhost:18443/wallet/mainstay", walletHost("localhost:18443", "mainstay"))
+	assert.Equal(t, "localhost:18443/wallet/main%20wallet", walletHost("localhost:18443/", "main wallet"))
+}
+
+// Test config for Optional db connection pool parameters
+func TestConfigDbPool(t *testing.T) {
+	var configErr error
+	var config *Config
+	var testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "db": {
+            "user":"username1",
+            "password":"password2",
+            "host":"localhost",
+            "port":"27017",
+            "name":"mainstay",
+            "maxPoolSize":"20",
+            "socketTimeoutSeconds":"10",
+            "readConcern":"majority",
+            "writeConcern":"majority",
+            "readPreference":"secondaryPreferred"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, uint64(20), config.DbConfig().MaxPoolSize)
+	assert.Equal(t, 10, config.DbConfig().SocketTimeoutSeconds)
+	assert.Equal(t, "majority", config.DbConfig().ReadConcern)
+	assert.Equal(t, "majority", config.DbConfig().WriteConcern)
+	assert.Equal(t, "secondaryPreferred", config.DbConfig().ReadPreference)
+
+	// test invalid values for each connection pool parameter
+	invalidParams := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{DbMaxPoolSizeName, "0", ErrorBadDataDbMaxPoolSize},
+		{DbMaxPoolSizeName, "-5", ErrorBadDataDbMaxPoolSize},
+		{DbSocketTimeoutSecondsName, "0", ErrorBadDataDbSocketTimeout},
+		{DbSocketTimeoutSecondsName, "ten", ErrorBadDataDbSocketTimeout},
+		{DbReadConcernName, "strong", ErrorBadDataDbReadConcern},
+		{DbWriteConcernName, "-1", ErrorBadDataDbWriteConcern},
+		{DbWriteConcernName, "all", ErrorBadDataDbWriteConcern},
+		{DbReadPreferenceName, "secondaries", ErrorBadDataDbReadPref},
+	}
+	for _, param := range invalidParams {
+		testConf = []byte(fmt.Sprintf(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "db": {
+            "user":"username1",
+            "password":"password2",
+            "host":"localhost",
+            "port":"27017",
+            "name":"mainstay",
+            "%s":"%s"
+        }
+    }
+    `, param.name, param.value))
+		config, configErr = NewConfig(testConf)
+		assert.Equal(t, errors.New(fmt.Sprintf("%s: %s", param.err, param.value)), configErr)
+	}
+}
+
 // Test config for Optional staychain parameters
 func TestConfigStaychain(t *testing.T) {
 	var configErr error
@@ -333,7 +474,7 @@ func TestConfigFees(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, FeesConfig{-1, -1, -1}, config.FeesConfig())
+	assert.Equal(t, FeesConfig{-1, -1, -1, nil, nil, "", -1}, config.FeesConfig())
 
 	testConf = []byte(`
     {
@@ -350,7 +491,7 @@ func TestConfigFees(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, FeesConfig{1, -1, -1}, config.FeesConfig())
+	assert.Equal(t, FeesConfig{1, -1, -1, nil, nil, "", -1}, config.FeesConfig())
 
 	testConf = []byte(`
     {
@@ -367,7 +508,7 @@ func TestConfigFees(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, FeesConfig{-1, -1, -1}, config.FeesConfig())
+	assert.Equal(t, FeesConfig{-1, -1, -1, nil, nil, "", -1}, config.FeesConfig())
 
 	testConf = []byte(`
     {
@@ -381,13 +522,15 @@ func TestConfigFees(t *testing.T) {
             "maxFee": "10",
             "minFee": "5",
             "feeIncrement": "11",
+            "blockFeeFloor": "median",
+            "fixedFee": "8",
             "something-else": "nice-value"
         }
     }
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, FeesConfig{5, 10, 11}, config.FeesConfig())
+	assert.Equal(t, FeesConfig{5, 10, 11, nil, nil, "median", 8}, config.FeesConfig())
 }
 
 // Test config for Optional timing parameters
@@ -408,7 +551,7 @@ func TestConfigTiming(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, TimingConfig{-1, -1}, config.TimingConfig())
+	assert.Equal(t, TimingConfig{-1, -1, -1, -1, "", ""}, config.TimingConfig())
 
 	testConf = []byte(`
     {
@@ -425,7 +568,7 @@ func TestConfigTiming(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, TimingConfig{0, -1}, config.TimingConfig())
+	assert.Equal(t, TimingConfig{0, -1, -1, -1, "", ""}, config.TimingConfig())
 
 	testConf = []byte(`
     {
@@ -442,7 +585,7 @@ func TestConfigTiming(t *testing.T) {
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, TimingConfig{-1, 0}, config.TimingConfig())
+	assert.Equal(t, TimingConfig{-1, 0, -1, -1, "", ""}, config.TimingConfig())
 
 	testConf = []byte(`
     {
@@ -454,13 +597,17 @@ func TestConfigTiming(t *testing.T) {
         },
         "timing": {
             "newAttestationMinutes": "10",
-            "handleUnconfirmedMinutes": "60"
+            "handleUnconfirmedMinutes": "60",
+            "signaturesSeconds": "120",
+            "acceptanceWindowSeconds": "30",
+            "schedule": "0 * * * *",
+            "missedSchedule": "catchup"
         }
     }
     `)
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
-	assert.Equal(t, TimingConfig{10, 60}, config.TimingConfig())
+	assert.Equal(t, TimingConfig{10, 60, 120, 30, "0 * * * *", MissedScheduleCatchUp}, config.TimingConfig())
 }
 
 // Test config for Optional signer parameters
@@ -516,4 +663,369 @@ func TestConfigSigner(t *testing.T) {
 	config, configErr = NewConfig(testConf)
 	assert.Equal(t, nil, configErr)
 	assert.Equal(t, "*:5000", config.SignerConfig().Publisher)
+	assert.Equal(t, "", config.SignerConfig().Command)
+	assert.Equal(t, -1, config.SignerConfig().CommandTimeoutSeconds)
+	assert.Equal(t, -1, config.SignerConfig().Primaries)
+	assert.Equal(t, false, config.SignerConfig().CompactPreImages)
+	assert.Equal(t, "", config.SignerConfig().TopicNamespace)
+	assert.Equal(t, "", config.SignerConfig().Unreachable)
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "",
+            "rpcuser": "",
+            "rpcpass": "",
+            "chain": ""
+        },
+        "signer": {
+            "signers": "host0,host1,host2",
+            "primaries": "2",
+            "compactPreImages": "true",
+            "topicNamespace": "chain0",
+            "unreachable": "local"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, []string{"host0", "host1", "host2"}, config.SignerConfig().Signers)
+	assert.Equal(t, 2, config.SignerConfig().Primaries)
+	assert.Equal(t, true, config.SignerConfig().CompactPreImages)
+	assert.Equal(t, "chain0", config.SignerConfig().TopicNamespace)
+	assert.Equal(t, SignersUnreachableLocal, config.SignerConfig().Unreachable)
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "",
+            "rpcuser": "",
+            "rpcpass": "",
+            "chain": ""
+        },
+        "signer": {
+            "command": "/usr/local/bin/hsm-sign --slot 0",
+            "commandTimeoutSeconds": "10"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, []string(nil), config.SignerConfig().Signers)
+	assert.Equal(t, "/usr/local/bin/hsm-sign --slot 0", config.SignerConfig().Command)
+	assert.Equal(t, 10, config.SignerConfig().CommandTimeoutSeconds)
+}
+
+// Test config for Optional attestation parameters
+func TestConfigAttestation(t *testing.T) {
+	var configErr error
+	var config *Config
+	var testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, AttestationConfig{true, -1, false, DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1}, config.AttestationConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "attestation": {
+            "skipDuplicateCommitment": "invalid"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, AttestationConfig{true, -1, false, DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1}, config.AttestationConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "attestation": {
+            "skipDuplicateCommitment": "false"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, AttestationConfig{false, -1, false, DefaultAddressLabelPrefix, -1, false, "", false, -1, nil, false, -1, "", -1, -1, false, "", "", "", "", -1}, config.AttestationConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "attestation": {
+            "skipDuplicateCommitment": "true",
+            "checkpointDepth": "1000",
+            "scanUtxoSet": "true",
+            "addressLabelPrefix": "attestation",
+            "maxFeeBumps": "3",
+            "haltOnMaxFeeBumps": "true",
+            "locktime": "height",
+            "descriptorWallet": "true",
+            "sendRetries": "2",
+            "redundantOutputs": "opreturn",
+            "debug": "true",
+            "attestationAmount": "100000",
+            "reserveAddress": "2MxBi6eodnuoVCw8McGrf1nuoVhastqoBXB",
+            "minUnspentConfirmations": "0",
+            "staleCommitmentCycles": "3",
+            "attestStaleCommitment": "true",
+            "zeroCommitment": "sentinel",
+            "unspentSelection": "merge",
+            "journalPath": "/tmp/journal",
+            "journalKey": "aa",
+            "amountTolerance": "1000"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, AttestationConfig{true, 1000, true, "attestation", 3, true, "height", true, 2, []string{"opreturn"}, true,
+		100000, "2MxBi6eodnuoVCw8McGrf1nuoVhastqoBXB", 0, 3, true, ZeroCommitmentSentinel, UnspentSelectionMerge,
+		"/tmp/journal", "aa", 1000}, config.AttestationConfig())
+}
+
+// Test config for Optional server parameters
+func TestConfigServer(t *testing.T) {
+	var configErr error
+	var config *Config
+	var testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "server": {
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, -1}, config.ServerConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "server": {
+            "commitmentIntervalSeconds": "30",
+            "overridePosition": "5",
+            "treeWidth": "16",
+            "commitmentExpirySeconds": "86400",
+            "maxPayloadBytes": "2048",
+            "retentionSeconds": "604800",
+            "compactionIntervalSeconds": "3600",
+            "operatorPosition": "0",
+            "receiptKey": "cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+            "leafOrdering": "submission",
+            "commitmentMessagePrefix": "mainstay-commitment:",
+            "writeBufferPath": "/var/lib/mainstay/writebuffer.json",
+            "maxClientPosition": "1000",
+            "readCacheSeconds": "5"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, ServerConfig{30, 5, 16, 86400, 2048, 604800, 3600, 0,
+		"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz", LeafOrderingSubmission, "mainstay-commitment:",
+		"/var/lib/mainstay/writebuffer.json", 1000, 5}, config.ServerConfig())
+}
+
+// Test config for Optional commitment source parameters
+func TestConfigCommitmentSource(t *testing.T) {
+	var configErr error
+	var config *Config
+	var testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, CommitmentSourceConfig{"", "", ""}, config.CommitmentSourceConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "commitmentSource": {
+            "address": "localhost:5555",
+            "topic": "commitments"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, CommitmentSourceConfig{"localhost:5555", "commitments", ""}, config.CommitmentSourceConfig())
+
+	testConf = []byte(`
+    {
+        "main": {
+            "rpcurl": "localhost:18443",
+            "rpcuser": "user",
+            "rpcpass": "pass",
+            "chain": "regtest"
+        },
+        "commitmentSource": {
+            "address": "nats://localhost:4222",
+            "topic": "commitments",
+            "type": "nats"
+        }
+    }
+    `)
+	config, configErr = NewConfig(testConf)
+	assert.Equal(t, nil, configErr)
+	assert.Equal(t, CommitmentSourceConfig{"nats://localhost:4222", "commitments", CommitmentSourceNats}, config.CommitmentSourceConfig())
+}
+
+// Test config array for multiple attestation services
+func TestConfigs(t *testing.T) {
+	var testConf = []byte(`
+    {
+    }
+    `)
+	configs, configsErr := NewConfigs(testConf)
+	assert.Equal(t, true, configsErr != nil)
+	assert.Equal(t, 0, len(configs))
+
+	testConf = []byte(`
+    [
+    ]
+    `)
+	configs, configsErr = NewConfigs(testConf)
+	assert.Equal(t, errors.New(ErrorBadDataConfigs), configsErr)
+	assert.Equal(t, 0, len(configs))
+
+	testConf = []byte(`
+    [
+        {
+            "main": {
+                "rpcurl": "localhost:18443",
+                "rpcuser": "user",
+                "rpcpass": "pass",
+                "chain": "regtest"
+            }
+        },
+        {
+            "main": {
+            }
+        }
+    ]
+    `)
+	configs, configsErr = NewConfigs(testConf)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s: %s (config 1)", ErrorConfigValueNotFound, RpcClientUrlName)), configsErr)
+	assert.Equal(t, 0, len(configs))
+
+	testConf = []byte(`
+    [
+        {
+            "main": {
+                "rpcurl": "localhost:18443",
+                "rpcuser": "user",
+                "rpcpass": "pass",
+                "chain": "regtest"
+            },
+            "staychain": {
+                "initTx": "87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1"
+            }
+        },
+        {
+            "main": {
+                "rpcurl": "localhost:18444",
+                "rpcuser": "user",
+                "rpcpass": "pass",
+                "chain": "regtest"
+            },
+            "staychain": {
+                "initTx": "87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1"
+            }
+        }
+    ]
+    `)
+	configs, configsErr = NewConfigs(testConf)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s: %s (config 1)", ErrorConfigsDuplicateInitTx,
+		"87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1")), configsErr)
+	assert.Equal(t, 0, len(configs))
+
+	testConf = []byte(`
+    [
+        {
+            "main": {
+                "rpcurl": "localhost:18443",
+                "rpcuser": "user",
+                "rpcpass": "pass",
+                "chain": "regtest"
+            },
+            "staychain": {
+                "initTx": "87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1"
+            },
+            "signer": {
+                "publisher": "*:5000",
+                "signers": "127.0.0.1:5001"
+            }
+        },
+        {
+            "main": {
+                "rpcurl": "localhost:18444",
+                "rpcuser": "user",
+                "rpcpass": "pass",
+                "chain": "regtest"
+            },
+            "staychain": {
+                "initTx": "97e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1"
+            },
+            "signer": {
+                "publisher": "*:6000",
+                "signers": "127.0.0.1:6001"
+            }
+        }
+    ]
+    `)
+	configs, configsErr = NewConfigs(testConf)
+	assert.Equal(t, nil, configsErr)
+	assert.Equal(t, 2, len(configs))
+	assert.Equal(t, "87e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1", configs[0].InitTx())
+	assert.Equal(t, "97e56bda501ba6a022f12e178e9f1ac03fb2c07f04e1dfa62ac9e1d83cd840e1", configs[1].InitTx())
+	assert.Equal(t, "*:5000", configs[0].SignerConfig().Publisher)
+	assert.Equal(t, "*:6000", configs[1].SignerConfig().Publisher)
+	assert.Equal(t, true, configs[0].MainClient() != configs[1].MainClient())
 }
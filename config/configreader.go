@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"strings"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/rpcclient"
@@ -22,7 +24,11 @@ const (
 	RpcClientPassName  = "rpcpass"
 	RpcClientChainName = "chain"
 
+	RpcClientCookieName = "rpccookie"
+	RpcClientWalletName = "wallet"
+
 	ErrorRpcConnectionFailure = "failed connecting to rpc client"
+	ErrorRpcCookieInvalid     = "invalid rpc cookie file"
 
 	ErrorBadDataClientChain = "invalid value for client chain. 'main', 'testnet' and 'regtest' allowed only"
 )
@@ -37,6 +43,8 @@ func GetConfFile(filepath string) ([]byte, error) {
 }
 
 // Get RPC connection for a client name from a conf file
+// The client authenticates with a cookie file if one is set
+// or with the user and password set otherwise
 func GetRPC(name string, conf []byte) (*rpcclient.Client, error) {
 	// get client from config
 	cfg, cfgErr := getCfg(name, conf)
@@ -54,33 +62,49 @@ func GetRPC(name string, conf []byte) (*rpcclient.Client, error) {
 		host = urlValue
 	}
 
-	// get client user value
-	userValue, userValueErr := cfg.getValue(RpcClientUserName)
-	if userValueErr != nil {
-		return nil, errors.New(fmt.Sprintf("%s: %s", userValueErr, RpcClientUserName))
-	}
-	user := os.Getenv(userValue)
-	if user == "" {
-		user = userValue
-	}
-
-	// get client password value
-	passValue, passValueErr := cfg.getValue(RpcClientPassName)
-	if passValueErr != nil {
-		return nil, errors.New(fmt.Sprintf("%s: %s", passValueErr, RpcClientPassName))
-	}
-	pass := os.Getenv(passValue)
-	if pass == "" {
-		pass = passValue
-	}
-
+	// target a named wallet of a multi-wallet node if set
 	connCfg := &rpcclient.ConnConfig{
-		Host:         host,
-		User:         user,
-		Pass:         pass,
+		Host:         walletHost(host, TryGetParamFromConf(name, RpcClientWalletName, conf)),
 		HTTPPostMode: true,
 		DisableTLS:   true,
 	}
+
+	// authenticate with cookie file instead of user and password if set
+	// the cookie is re-read by the rpc client whenever the file changes
+	cookieValue := cfg.tryGetValue(RpcClientCookieName)
+	if cookieValue != "" {
+		cookiePath := os.Getenv(cookieValue)
+		if cookiePath == "" {
+			cookiePath = cookieValue
+		}
+		if _, _, cookieErr := readCookieFile(cookiePath); cookieErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", ErrorRpcCookieInvalid, cookieErr))
+		}
+		connCfg.CookiePath = cookiePath
+	} else {
+		// get client user value
+		userValue, userValueErr := cfg.getValue(RpcClientUserName)
+		if userValueErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", userValueErr, RpcClientUserName))
+		}
+		user := os.Getenv(userValue)
+		if user == "" {
+			user = userValue
+		}
+
+		// get client password value
+		passValue, passValueErr := cfg.getValue(RpcClientPassName)
+		if passValueErr != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", passValueErr, RpcClientPassName))
+		}
+		pass := os.Getenv(passValue)
+		if pass == "" {
+			pass = passValue
+		}
+		connCfg.User = user
+		connCfg.Pass = pass
+	}
+
 	client, rpcErr := rpcclient.New(connCfg, nil)
 	if rpcErr != nil {
 		return nil, errors.New(fmt.Sprintf("%s: %s", rpcErr, ErrorRpcConnectionFailure))
@@ -88,6 +112,29 @@ func GetRPC(name string, conf []byte) (*rpcclient.Client, error) {
 	return client, nil
 }
 
+// Return rpc host with the wallet endpoint path of the wallet name provided
+// All rpc calls, including wallet calls, are then handled by the named wallet
+func walletHost(host string, wallet string) string {
+	if wallet == "" {
+		return host
+	}
+	return fmt.Sprintf("%s/wallet/%s", strings.TrimSuffix(host, "/"), url.PathEscape(wallet))
+}
+
+// Read rpc user and password from a bitcoind cookie file
+// The cookie file contains a single line of the form user:password
+func readCookieFile(path string) (string, string, error) {
+	cookie, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return "", "", readErr
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(cookie)), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", errors.New(fmt.Sprintf("malformed cookie in %s", path))
+	}
+	return parts[0], parts[1], nil
+}
+
 // Chain configuration parameters from btcsuite for main bitcoin client only
 func GetChainCfgParams(name string, conf []byte) (*chaincfg.Params, error) {
 	cfg, cfgErr := getCfg(name, conf)
@@ -0,0 +1,7 @@
+/*
+Package schnorr contains utilities for signing and verifying BIP-340
+schnorr signatures over secp256k1 with x-only public keys. This is kept
+apart from package crypto so that the server can verify client signatures
+without depending on the attestation key tweaking and script utilities
+*/
+package schnorr
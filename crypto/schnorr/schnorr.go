@@ -0,0 +1,176 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+// Schnorr signatures over secp256k1 under BIP-340
+// Public keys are 32 byte x-only keys and signatures are 64 bytes
+
+// schnorr error consts
+const (
+	ErrorPubKeySize       = "Schnorr pubkey must be 32 bytes"
+	ErrorPubKeyNotOnCurve = "Schnorr pubkey not on curve"
+	ErrorNonceZero        = "Schnorr nonce is zero"
+	ErrorSigInvalid       = "Schnorr signature produced is invalid"
+)
+
+// schnorr size consts
+const (
+	PubKeySize = 32
+	SigSize    = 64
+)
+
+// BIP-340 tagged hash tags
+const (
+	tagAux       = "BIP0340/aux"
+	tagNonce     = "BIP0340/nonce"
+	tagChallenge = "BIP0340/challenge"
+)
+
+// Tagged hash of the message parts as defined in BIP-340
+// SHA256(SHA256(tag) || SHA256(tag) || parts...)
+func taggedHash(tag string, parts ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// Get 32 byte big endian serialisation of an integer
+func intTo32Bytes(val *big.Int) []byte {
+	valBytes := val.Bytes()
+	padded := make([]byte, 32)
+	copy(padded[32-len(valBytes):], valBytes)
+	return padded
+}
+
+// Get the x-only serialisation of a public key used in schnorr signatures
+func PubKey(pubkey *btcec.PublicKey) []byte {
+	return intTo32Bytes(pubkey.X)
+}
+
+// Parse x-only public key to the curve point with even y coordinate
+func ParsePubKey(pubkeyBytes []byte) (*btcec.PublicKey, error) {
+	if len(pubkeyBytes) != PubKeySize {
+		return nil, errors.New(ErrorPubKeySize)
+	}
+	curve := btcec.S256()
+	x := new(big.Int).SetBytes(pubkeyBytes)
+	if x.Cmp(curve.P) >= 0 {
+		return nil, errors.New(ErrorPubKeyNotOnCurve)
+	}
+
+	// y^2 = x^3 + 7 with square root c^((p+1)/4) as p = 3 mod 4
+	c := new(big.Int).Exp(x, big.NewInt(3), curve.P)
+	c.Add(c, big.NewInt(7))
+	c.Mod(c, curve.P)
+	exp := new(big.Int).Add(curve.P, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(c, exp, curve.P)
+	if new(big.Int).Exp(y, big.NewInt(2), curve.P).Cmp(c) != 0 {
+		return nil, errors.New(ErrorPubKeyNotOnCurve)
+	}
+	if y.Bit(0) == 1 {
+		y.Sub(curve.P, y)
+	}
+	return &btcec.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// Verify schnorr signature of message with x-only public key
+func Verify(pubkeyBytes []byte, msg []byte, sig []byte) bool {
+	if len(sig) != SigSize {
+		return false
+	}
+	pubkey, pubkeyErr := ParsePubKey(pubkeyBytes)
+	if pubkeyErr != nil {
+		return false
+	}
+	curve := btcec.S256()
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(curve.P) >= 0 || s.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// R = s*G - e*P
+	e := new(big.Int).SetBytes(taggedHash(tagChallenge, sig[:32], pubkeyBytes, msg))
+	e.Mod(e, curve.N)
+	e.Sub(curve.N, e)
+	e.Mod(e, curve.N)
+	sGx, sGy := curve.ScalarBaseMult(intTo32Bytes(s))
+	ePx, ePy := curve.ScalarMult(pubkey.X, pubkey.Y, intTo32Bytes(e))
+	rx, ry := curve.Add(sGx, sGy, ePx, ePy)
+
+	// fail if R is infinity, has odd y or x different from r
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	return ry.Bit(0) == 0 && rx.Cmp(r) == 0
+}
+
+// Sign message with private key producing a schnorr signature
+// Fresh auxiliary randomness is used for nonce generation
+func Sign(privKey *btcec.PrivateKey, msg []byte) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, randErr := rand.Read(aux); randErr != nil {
+		return nil, randErr
+	}
+	return signAux(privKey, msg, aux)
+}
+
+// Sign message with private key and auxiliary randomness as in BIP-340
+func signAux(privKey *btcec.PrivateKey, msg []byte, aux []byte) ([]byte, error) {
+	curve := btcec.S256()
+	pubkey := privKey.PubKey()
+	pubkeyBytes := PubKey(pubkey)
+
+	// negate secret key if public key has odd y
+	d := new(big.Int).Set(privKey.D)
+	if pubkey.Y.Bit(0) == 1 {
+		d.Sub(curve.N, d)
+	}
+
+	// derive nonce from masked secret key, public key and message
+	t := intTo32Bytes(d)
+	auxHash := taggedHash(tagAux, aux)
+	for i := range t {
+		t[i] ^= auxHash[i]
+	}
+	k := new(big.Int).SetBytes(taggedHash(tagNonce, t, pubkeyBytes, msg))
+	k.Mod(k, curve.N)
+	if k.Sign() == 0 {
+		return nil, errors.New(ErrorNonceZero)
+	}
+	rx, ry := curve.ScalarBaseMult(intTo32Bytes(k))
+	if ry.Bit(0) == 1 {
+		k.Sub(curve.N, k)
+	}
+
+	// s = k + e*d
+	rBytes := intTo32Bytes(rx)
+	e := new(big.Int).SetBytes(taggedHash(tagChallenge, rBytes, pubkeyBytes, msg))
+	e.Mod(e, curve.N)
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, curve.N)
+
+	sig := append(rBytes, intTo32Bytes(s)...)
+	if !Verify(pubkeyBytes, msg, sig) {
+		return nil, errors.New(ErrorSigInvalid)
+	}
+	return sig, nil
+}
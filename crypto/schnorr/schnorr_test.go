@@ -0,0 +1,81 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package schnorr
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test schnorr signing and verification against BIP-340 test vectors
+func TestSchnorr(t *testing.T) {
+	vectors := []struct {
+		privKey string
+		pubKey  string
+		aux     string
+		msg     string
+		sig     string
+	}{
+		{"0000000000000000000000000000000000000000000000000000000000000003",
+			"f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0"},
+		{"b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+			"dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+			"6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a"},
+		{"c90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9",
+			"dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+			"c87aa53824b4d7ae2eb035a2b5bbbccc080e76cdc6d1692c4b0b62d798e6d906",
+			"7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+			"5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7"},
+	}
+	for _, vector := range vectors {
+		privKeyBytes, _ := hex.DecodeString(vector.privKey)
+		pubKeyBytes, _ := hex.DecodeString(vector.pubKey)
+		auxBytes, _ := hex.DecodeString(vector.aux)
+		msgBytes, _ := hex.DecodeString(vector.msg)
+		sigBytes, _ := hex.DecodeString(vector.sig)
+
+		// test x-only pubkey and signature with aux randomness
+		privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), privKeyBytes)
+		assert.Equal(t, pubKeyBytes, PubKey(privKey.PubKey()))
+		sig, sigErr := signAux(privKey, msgBytes, auxBytes)
+		assert.Equal(t, nil, sigErr)
+		assert.Equal(t, sigBytes, sig)
+		assert.Equal(t, true, Verify(pubKeyBytes, msgBytes, sigBytes))
+
+		// test signature with fresh randomness
+		sig, sigErr = Sign(privKey, msgBytes)
+		assert.Equal(t, nil, sigErr)
+		assert.Equal(t, true, Verify(pubKeyBytes, msgBytes, sig))
+
+		// test invalid signature and message
+		sigBytes[SigSize-1] ^= 1
+		assert.Equal(t, false, Verify(pubKeyBytes, msgBytes, sigBytes))
+		assert.Equal(t, false, Verify(pubKeyBytes, msgBytes, sigBytes[:SigSize-1]))
+		sigBytes[SigSize-1] ^= 1
+		msgBytes[0] ^= 1
+		assert.Equal(t, false, Verify(pubKeyBytes, msgBytes, sigBytes))
+	}
+
+	// test pubkey not on curve
+	pubKeyBytes, _ := hex.DecodeString("eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34")
+	msgBytes, _ := hex.DecodeString("243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89")
+	sigBytes, _ := hex.DecodeString("6cff5c3ba86c69ea4b7376f31a9bcb4f74c1976089b2d9963da2e5543e17776969e89b4c5564d00349106b8497785dd7d1d713a8ae82b32fa79d5f7fc407d39b")
+	_, parseErr := ParsePubKey(pubKeyBytes)
+	assert.Equal(t, ErrorPubKeyNotOnCurve, parseErr.Error())
+	assert.Equal(t, false, Verify(pubKeyBytes, msgBytes, sigBytes))
+
+	// test invalid pubkey size
+	_, parseErr = ParsePubKey(pubKeyBytes[1:])
+	assert.Equal(t, true, strings.HasPrefix(parseErr.Error(), ErrorPubKeySize))
+}
@@ -6,15 +6,26 @@ package crypto
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"strconv"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
 )
 
+// signature encoding error consts
+const (
+	ErrorSigEmpty        = "Signature empty"
+	ErrorSigHashType     = "Signature sighash type is not SIGHASH_ALL"
+	ErrorSigNotStrictDER = "Signature not strictly DER encoded"
+	ErrorSigHighS        = "Signature S value is higher than half the curve order"
+)
+
 // Various utility functions concerning multisig and scripts
 
 // Raw method to parse a multisig script and get pubkeys and num of sigs
@@ -80,6 +91,29 @@ func CreateMultisig(pubkeys []*btcec.PublicKey, nSigs int, chainCfg *chaincfg.Pa
 // type def for signature
 type Sig []byte
 
+// half of the secp256k1 curve order for low S checks
+var sigHalfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+// Verify signature encoding before adding to a scriptSig
+// Signature must be strictly DER encoded with a low S value
+// and have the SIGHASH_ALL byte appended, as per BIP-66/BIP-62
+func VerifySigEncoding(sig Sig) error {
+	if len(sig) == 0 {
+		return errors.New(ErrorSigEmpty)
+	}
+	if txscript.SigHashType(sig[len(sig)-1]) != txscript.SigHashAll {
+		return errors.New(fmt.Sprintf("%s (%d)", ErrorSigHashType, sig[len(sig)-1]))
+	}
+	signature, sigErr := btcec.ParseDERSignature(sig[:len(sig)-1], btcec.S256())
+	if sigErr != nil {
+		return errors.New(fmt.Sprintf("%s: %v", ErrorSigNotStrictDER, sigErr))
+	}
+	if signature.S.Cmp(sigHalfOrder) > 0 {
+		return errors.New(ErrorSigHighS)
+	}
+	return nil
+}
+
 // Parse scriptSig and return sigs and redeemScript
 func ParseScriptSig(scriptSig []byte) ([]Sig, []byte) {
 
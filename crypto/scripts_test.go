@@ -6,6 +6,9 @@ package crypto
 
 import (
 	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
 	"testing"
 
 	"mainstay/clients"
@@ -125,3 +128,50 @@ func TestScript_withOpPushData1(t *testing.T) {
 	scriptSigTest := CreateScriptSig([]Sig{sig1Bytes, sig2Bytes}, redeemScriptBytes)
 	assert.Equal(t, scriptSig, hex.EncodeToString(scriptSigTest))
 }
+
+// Serialize signature values to DER without enforcing low S
+func serializeDERSig(r *big.Int, s *big.Int) []byte {
+	canonical := func(x *big.Int) []byte {
+		b := x.Bytes()
+		if b[0]&0x80 != 0 {
+			b = append([]byte{0x00}, b...)
+		}
+		return b
+	}
+	rb := canonical(r)
+	sb := canonical(s)
+	der := []byte{0x30, byte(4 + len(rb) + len(sb)), 0x02, byte(len(rb))}
+	der = append(der, rb...)
+	der = append(der, 0x02, byte(len(sb)))
+	return append(der, sb...)
+}
+
+// Test signature encoding verification
+func TestVerifySigEncoding(t *testing.T) {
+	sigBytes, _ := hex.DecodeString("3044022077607e068a5e4570f28430e723a3292d2c01d798df0758978a8cbc1d045aa230022000d5f85d071e697369c7c4d6e3520aa719f728ed5b511f8aa4eb93ceb615ba6501")
+
+	// valid signature
+	assert.Equal(t, nil, VerifySigEncoding(Sig(sigBytes)))
+
+	// empty signature
+	assert.Equal(t, errors.New(ErrorSigEmpty), VerifySigEncoding(Sig{}))
+
+	// invalid sighash type
+	sigBytesNoAll := append([]byte{}, sigBytes[:len(sigBytes)-1]...)
+	sigErr := VerifySigEncoding(Sig(append(sigBytesNoAll, 0x02)))
+	assert.Equal(t, true, strings.HasPrefix(sigErr.Error(), ErrorSigHashType))
+	sigErr = VerifySigEncoding(Sig(sigBytesNoAll))
+	assert.Equal(t, true, strings.HasPrefix(sigErr.Error(), ErrorSigHashType))
+
+	// not DER encoded
+	sigErr = VerifySigEncoding(Sig(append(append([]byte{}, sigBytes[:20]...), 0x01)))
+	assert.Equal(t, true, strings.HasPrefix(sigErr.Error(), ErrorSigNotStrictDER))
+
+	// high S value
+	signature, parseErr := btcec.ParseDERSignature(sigBytesNoAll, btcec.S256())
+	assert.Equal(t, nil, parseErr)
+	assert.Equal(t, sigBytesNoAll, serializeDERSig(signature.R, signature.S))
+	highS := new(big.Int).Sub(btcec.S256().N, signature.S)
+	sigHighS := append(serializeDERSig(signature.R, highS), 0x01)
+	assert.Equal(t, errors.New(ErrorSigHighS), VerifySigEncoding(Sig(sigHighS)))
+}
@@ -73,6 +73,7 @@ func tweakValWithPathChild(child derivationPathChild, val *big.Int) *big.Int {
 }
 
 // Tweak a private key by adding the tweak to it's integer representation
+// Attestation keys are instead derived with TweakExtendedKey using chaincodes
 func TweakPrivKey(walletPrivKey *btcutil.WIF, tweak []byte, chainCfg *chaincfg.Params) (*btcutil.WIF, error) {
 
 	// set initial value to big int of priv key bytes
@@ -124,6 +125,9 @@ func tweakPubWithPathChild(child derivationPathChild, x *big.Int, y *big.Int) (*
 
 // Tweak a bip-32 extended key (public or private) with tweak hash
 // Tweak takes the form of bip-32 child derivation using tweak as index
+// Deriving from the private and the public extended key of a keypair with
+// the same chaincode results in the same tweaked keypair, which allows the
+// verifier to re-derive attestation addresses from pubkeys and chaincodes
 // Under the assumed conditions this method should never return an error
 // but we are including the error check for any 100% completeness
 func TweakExtendedKey(extndPubKey *hdkeychain.ExtendedKey, tweak []byte) (*hdkeychain.ExtendedKey, error) {
@@ -150,6 +154,7 @@ func TweakExtendedKey(extndPubKey *hdkeychain.ExtendedKey, tweak []byte) (*hdkey
 }
 
 // Tweak a pub key by adding the elliptic curve representation of the tweak to the pub key
+// Attestation keys are instead derived with TweakExtendedKey using chaincodes
 func TweakPubKey(pubKey *btcec.PublicKey, tweak []byte) *btcec.PublicKey {
 
 	path := getDerivationPathFromTweak(tweak) // get derivation path for tweak
@@ -13,7 +13,9 @@ import (
 	"mainstay/clients"
 
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/stretchr/testify/assert"
 )
@@ -186,3 +188,157 @@ func TestTweaking_extendedKey(t *testing.T) {
 	assert.Equal(t, "YZ7jREvsw9bHjkqVtQJDgKJCvthBSXbvXDmB6wSxDhm8xxWKmX94MmTDNSHMcSTojdiHtQ1UnhEvW5sUf4xuL4SCPirMeJdVwEJ3BZ74CS",
 		pubTweaked.String())
 }
+
+// Test tweaking against fixed vectors of keys, tweak hashes and
+// expected tweaked keys and addresses, verifying that tweaking the
+// private key and tweaking the public key result in the same keypair
+func TestTweaking_vectors(t *testing.T) {
+	vectors := []struct {
+		privKey        string
+		tweak          string
+		tweakedPrivKey string
+		tweakedPubKey  string
+		tweakedAddr    string
+	}{
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"abcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+			"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo2kJ4gBAX4RE",
+			"039ea0bc9f2b1baefd6f70fa0e59a90ed3212fd711c20bd9a3c41ef4d1574c0777",
+			"mmdmFzFU8uzNTF91gQDmKuWbNgf7RBCztK"},
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+			"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo2XQUs8qChQu",
+			"0304e438b979d4db627e1283356f4170ec77eb5b1cee9d681b043825bbbc5872c7",
+			"mhUEBanz8ytATniaVvVNyERkHP9Vc9rpHj"},
+		// zero tweak leaves key unchanged
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33",
+			"mw7fcJJZ5kBNLNC2JAZGee8MWnyYXsQ2oA"},
+		// private key 1 with maximum path children
+		{"cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA",
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			"cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMQoKvsPPJXKE",
+			"0275a17f280f0183362b7a5e109b729969c488e53dd7eb227ebd3189f45da186d8",
+			"myZtAgb3tkXf7hfyxig7ujFCBECVQrpPvo"},
+		// private key n-1 overflowing the curve order
+		{"cWALDjUu1tszsCBMjBjL4mhYj2wHUWYDR8Q8aSjLKzjkW5eBtpzu",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN89V6hqi9DP",
+			"031b38903a43f7f114ed4500b4eac7083fdefece1cf29c63528d563446f972c180",
+			"msm4qu9F8YETJg3BFNCzpiN8aoMeGGfZZi"},
+	}
+
+	chainCfg := &chaincfg.RegressionNetParams
+	for _, vector := range vectors {
+		privKey, privKeyErr := GetWalletPrivKey(vector.privKey)
+		assert.Equal(t, nil, privKeyErr)
+		tweak, _ := chainhash.NewHashFromStr(vector.tweak)
+
+		// test tweaked private key and address
+		tweakedPrivKey, tweakErr := TweakPrivKey(privKey, tweak.CloneBytes(), chainCfg)
+		assert.Equal(t, nil, tweakErr)
+		assert.Equal(t, vector.tweakedPrivKey, tweakedPrivKey.String())
+		addr, addrErr := GetAddressFromPrivKey(tweakedPrivKey, chainCfg)
+		assert.Equal(t, nil, addrErr)
+		assert.Equal(t, vector.tweakedAddr, addr.String())
+		assert.Equal(t, true, IsAddrTweakedFromHash(vector.tweakedAddr, tweak.CloneBytes(), privKey, chainCfg))
+
+		// test tweaked public key matching tweaked private key
+		tweakedPubKey := TweakPubKey(privKey.PrivKey.PubKey(), tweak.CloneBytes())
+		assert.Equal(t, vector.tweakedPubKey, hex.EncodeToString(tweakedPubKey.SerializeCompressed()))
+		assert.Equal(t, tweakedPrivKey.PrivKey.PubKey().SerializeCompressed(), tweakedPubKey.SerializeCompressed())
+		pubKeyAddr, pubKeyAddrErr := GetAddressFromPubKey(tweakedPubKey, chainCfg)
+		assert.Equal(t, nil, pubKeyAddrErr)
+		assert.Equal(t, vector.tweakedAddr, pubKeyAddr.String())
+	}
+}
+
+// Test bip-32 extended key tweaking against fixed vectors of keys, chaincodes,
+// tweak hashes and expected tweaked keys and addresses, verifying that
+// deriving from the extended private key, as done by signers, and deriving
+// from the extended public key, as done by the verifier, result in the same keypair
+func TestTweaking_extendedKeyVectors(t *testing.T) {
+	vectors := []struct {
+		privKey        string
+		chaincode      string
+		tweak          string
+		tweakedPrivKey string
+		tweakedPubKey  string
+		tweakedAddr    string
+	}{
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"abcdef710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa",
+			"abcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+			"cP24V8M8fJFfbayhhugKmCextRUjQ26wrTewNanea3CVDpQaPv1e",
+			"02814e8eb62f9c0aa25e9cf84e59ea387698136bc355a07f9191628561a0f67cd3",
+			"mfXPPwAuMK8HayHd183aZoNGmRP4TmEDQy"},
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"abcdef710e47968aee906804f211cf10cde9a11e14908ca0f78cc55dd190ceaa",
+			"1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+			"cVvPyh5T98e8sagW9srGkeA44J3C8SZV6B6FtpYWyH3mD2RxxrbC",
+			"02d6a429df93e2c3d7c055db60f9f711d79466e330bebfe4eda46f9a69c7b85e94",
+			"n1dZH5D37wRp7WMKGfE9b8PiXEWP9RA6Ro"},
+		// zero chaincode
+		{"cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abcadae1214d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+			"cQ2unwZycQXLDPNFKjPXzJid3tDPNBVsn6M33YSf6Cg67gURmNnH",
+			"02ae87df610d2f59e97d13c4796bf694e8da0ce65debe570d1eb8bdab3870894e9",
+			"mtdqMauWkmjDSjqKYSvh2ULYHSGE6WJzwe"},
+		// private key 1 with maximum non-hardened path children
+		{"cMahea7zqjxrtgAbB7LSGbcQUr1uX1ojuat9jZodMN87JcbXMTcA",
+			"14df7ece79e83f0f479a37832d770294014edc6884b0c8bfa2e0aaf51fb00229",
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			"cUxdGApZSSc5PeAuzet7kG7q8U6yDX1tK5YSDEXYvorCQ314bMQD",
+			"03ba8a86ac4b933d4843bfcfcbff251ab48ac1286eaacd9070353c8bd520b3d8d0",
+			"mw7fb3N24RTQVBAzp96QM9q1TqGGmLi9UN"},
+		// private key n-1
+		{"cWALDjUu1tszsCBMjBjL4mhYj2wHUWYDR8Q8aSjLKzjkW5eBtpzu",
+			"14df7ece79e83f0f479a37832d770294014edc6884b0c8bfa2e0aaf51fb00229",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"cSQ5fLxP38KGMgDzaH3aHPrnVwkPtwtfuA6cqY5Ej54sencL6auY",
+			"02b42307fddb8d3decb90f692a63e844500d591f4e7827e7ef651691266fd02d0e",
+			"mkaMMXfjm9bP94ERfLymjvggkJjGLCgmrx"},
+	}
+
+	chainCfg := &chaincfg.RegressionNetParams
+	for _, vector := range vectors {
+		privKey, privKeyErr := GetWalletPrivKey(vector.privKey)
+		assert.Equal(t, nil, privKeyErr)
+		chainCodeBytes, _ := hex.DecodeString(vector.chaincode)
+		tweak, _ := chainhash.NewHashFromStr(vector.tweak)
+
+		// test tweaked extended private key
+		privExtended := hdkeychain.NewExtendedKey([]byte{}, privKey.PrivKey.Serialize(), chainCodeBytes, []byte{}, 0, 0, true)
+		privTweaked, privTweakErr := TweakExtendedKey(privExtended, tweak.CloneBytes())
+		assert.Equal(t, nil, privTweakErr)
+		privTweakedEC, privECErr := privTweaked.ECPrivKey()
+		assert.Equal(t, nil, privECErr)
+		tweakedPrivKey, wifErr := btcutil.NewWIF(privTweakedEC, chainCfg, privKey.CompressPubKey)
+		assert.Equal(t, nil, wifErr)
+		assert.Equal(t, vector.tweakedPrivKey, tweakedPrivKey.String())
+
+		// test tweaked extended public key matching tweaked private key
+		pubExtended := hdkeychain.NewExtendedKey([]byte{}, privKey.PrivKey.PubKey().SerializeCompressed(), chainCodeBytes, []byte{}, 0, 0, false)
+		pubTweaked, pubTweakErr := TweakExtendedKey(pubExtended, tweak.CloneBytes())
+		assert.Equal(t, nil, pubTweakErr)
+		tweakedPubKey, pubECErr := pubTweaked.ECPubKey()
+		assert.Equal(t, nil, pubECErr)
+		assert.Equal(t, vector.tweakedPubKey, hex.EncodeToString(tweakedPubKey.SerializeCompressed()))
+		assert.Equal(t, tweakedPrivKey.PrivKey.PubKey().SerializeCompressed(), tweakedPubKey.SerializeCompressed())
+
+		// test tweaked addresses
+		addr, addrErr := GetAddressFromPrivKey(tweakedPrivKey, chainCfg)
+		assert.Equal(t, nil, addrErr)
+		assert.Equal(t, vector.tweakedAddr, addr.String())
+		pubKeyAddr, pubKeyAddrErr := GetAddressFromPubKey(tweakedPubKey, chainCfg)
+		assert.Equal(t, nil, pubKeyAddrErr)
+		assert.Equal(t, vector.tweakedAddr, pubKeyAddr.String())
+
+		// test bip-32 derivation differs from additive tweaking
+		additivePrivKey, _ := TweakPrivKey(privKey, tweak.CloneBytes(), chainCfg)
+		assert.NotEqual(t, vector.tweakedPrivKey, additivePrivKey.String())
+	}
+}
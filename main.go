@@ -7,12 +7,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"os/exec"
 	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"mainstay/attestation"
 	"mainstay/config"
@@ -26,10 +32,16 @@ var (
 	chaincodes  string
 	addrTopup   string
 	scriptTopup string
+	configsPath string
+	statePath   string
 	isRegtest   bool
-	mainConfig  *config.Config
+	isCheck     bool
+	mainConfigs []*config.Config
 )
 
+// timeout for connectivity checks to db and signers
+const checkTimeout = 5 * time.Second
+
 func parseFlags() {
 	flag.BoolVar(&isRegtest, "regtest", false, "Use regtest wallet configuration instead of user wallet")
 	flag.StringVar(&tx0, "tx", "", "Tx id for genesis attestation transaction")
@@ -37,6 +49,9 @@ func parseFlags() {
 	flag.StringVar(&chaincodes, "chaincodes", "", "Chaincodes for multisig pubkeys")
 	flag.StringVar(&addrTopup, "addrTopup", "", "Address for topup transaction")
 	flag.StringVar(&scriptTopup, "scriptTopup", "", "Redeem script for topup")
+	flag.BoolVar(&isCheck, "check", false, "Check connectivity to all service dependencies and exit")
+	flag.StringVar(&configsPath, "configs", "", "Path to config array for running multiple attestation services")
+	flag.StringVar(&statePath, "stateFile", "", "Path to write the final state of attestation services on shutdown")
 	flag.Parse()
 }
 
@@ -45,11 +60,30 @@ func init() {
 
 	if isRegtest {
 		test := test.NewTest(true, true)
-		mainConfig = test.Config
-		log.Printf("Running regtest mode with -tx=%s\n", mainConfig.InitTx())
+		mainConfigs = []*config.Config{test.Config}
+		log.Printf("Running regtest mode with -tx=%s\n", test.Config.InitTx())
+	} else if configsPath != "" {
+		// each config array entry sets its own staychain
+		if tx0 != "" || script0 != "" || chaincodes != "" || addrTopup != "" || scriptTopup != "" {
+			log.Fatal("Staychain arguments cannot be used with -configs. Set staychain config per config array entry.")
+		}
+		conf, confErr := config.GetConfFile(configsPath)
+		if confErr != nil {
+			log.Fatal(confErr)
+		}
+		var mainConfigsErr error
+		mainConfigs, mainConfigsErr = config.NewConfigs(conf)
+		if mainConfigsErr != nil {
+			log.Fatal(mainConfigsErr)
+		}
+		for i, mainConfig := range mainConfigs {
+			if mainConfig.InitTx() == "" || mainConfig.InitScript() == "" || len(mainConfig.InitChaincodes()) == 0 {
+				log.Fatalf("Need to provide all staychain initTx, initScript and initChaincodes for config %d.", i)
+			}
+		}
+		log.Printf("Running %d attestation services\n", len(mainConfigs))
 	} else {
-		var mainConfigErr error
-		mainConfig, mainConfigErr = config.NewConfig()
+		mainConfig, mainConfigErr := config.NewConfig()
 		if mainConfigErr != nil {
 			log.Fatal(mainConfigErr)
 		}
@@ -76,23 +110,176 @@ func init() {
 			mainConfig.SetTopupScript(scriptTopup)
 		}
 		mainConfig.SetRegtest(isRegtest)
+		mainConfigs = []*config.Config{mainConfig}
+	}
+}
+
+// Shutdown main rpc clients of all attestation services
+func shutdownClients() {
+	for _, mainConfig := range mainConfigs {
+		mainConfig.MainClient().Shutdown()
 	}
 }
 
+// Print pass/fail line for a dependency check and return whether it passed
+func printCheck(name string, err error) bool {
+	if err != nil {
+		fmt.Printf("[FAIL] %s: %v\n", name, err)
+		return false
+	}
+	fmt.Printf("[PASS] %s\n", name)
+	return true
+}
+
+// Check connectivity and configuration of all service dependencies
+// without starting the attestation service. Returns false on any failure
+func runChecks(mainConfig *config.Config) bool {
+	passed := true
+
+	// bitcoin rpc reachable and synced
+	info, infoErr := mainConfig.MainClient().GetBlockChainInfo()
+	if infoErr == nil && info.Blocks < info.Headers {
+		infoErr = errors.New(fmt.Sprintf("not synced - blocks %d < headers %d", info.Blocks, info.Headers))
+	}
+	passed = printCheck("bitcoin rpc reachable and synced", infoErr) && passed
+
+	// wallet contains the confirmed init tx paying to the init script
+	if infoErr == nil {
+		attester := attestation.NewAttestClientReadOnly(mainConfig)
+		passed = printCheck("bitcoin wallet contains init tx", attester.VerifyGenesis()) && passed
+	} else {
+		passed = printCheck("bitcoin wallet contains init tx", errors.New("bitcoin rpc unreachable")) && passed
+	}
+
+	// db reachable
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	passed = printCheck("db reachable", server.CheckDbMongo(ctx, mainConfig.DbConfig())) && passed
+
+	// fee api reachable
+	_, feeErr := attestation.NewAttestFees(mainConfig.FeesConfig()).CheckFeeApis()
+	passed = printCheck("fee api reachable", feeErr) && passed
+
+	// zmq signers reachable
+	for _, signerAddr := range mainConfig.SignerConfig().Signers {
+		conn, connErr := net.DialTimeout("tcp", signerAddr, checkTimeout)
+		if connErr == nil {
+			conn.Close()
+		}
+		passed = printCheck(fmt.Sprintf("signer %s reachable", signerAddr), connErr) && passed
+	}
+
+	// external signer command found
+	if command := strings.Fields(mainConfig.SignerConfig().Command); len(command) > 0 {
+		_, lookErr := exec.LookPath(command[0])
+		passed = printCheck(fmt.Sprintf("signer command %s found", command[0]), lookErr) && passed
+	}
+
+	// sidechain clients are only used by tools and not by the service
+	fmt.Println("[SKIP] sidechain client - not used by the attestation service")
+	return passed
+}
+
+// Start an attestation service and the server routines for a config
+// The service shares the context and waitgroup of all services but has its
+// own db connection, server, signer connections and attestation client fees
+func startService(ctx context.Context, wg *sync.WaitGroup, mainConfig *config.Config) *attestation.AttestService {
+	dbInterface := server.NewDbMongo(ctx, mainConfig.DbConfig())
+	var commitmentSource server.CommitmentSource
+	if sourceConfig := mainConfig.CommitmentSourceConfig(); sourceConfig.Address != "" {
+		if sourceConfig.Type == config.CommitmentSourceNats {
+			commitmentSource = server.NewCommitmentSourceNats(sourceConfig)
+		} else {
+			commitmentSource = server.NewCommitmentSourceZmq(sourceConfig)
+		}
+	}
+	attestServer := server.NewServer(dbInterface, mainConfig.ServerConfig())
+	var signer attestation.AttestSigner
+	if mainConfig.SignerConfig().Command != "" {
+		signer = attestation.NewAttestSignerCmd(mainConfig.SignerConfig())
+	} else {
+		signer = attestation.NewAttestSignerZmq(mainConfig.SignerConfig())
+	}
+	attestService := attestation.NewAttestService(ctx, wg, attestServer, signer, mainConfig)
+
+	wg.Add(1)
+	go attestService.Run()
+
+	// listen to signed client commitments from message queue, if set
+	if commitmentSource != nil {
+		wg.Add(1)
+		go attestServer.ListenCommitments(ctx, wg, commitmentSource)
+	}
+
+	// compact merkle commitments of stale unconfirmed attestations, if set
+	if mainConfig.ServerConfig().RetentionSeconds > 0 {
+		wg.Add(1)
+		go attestServer.RunCompaction(ctx, wg)
+	}
+
+	// retry db writes buffered while the db was unavailable, if set
+	if mainConfig.ServerConfig().WriteBufferPath != "" {
+		wg.Add(1)
+		go attestServer.RunWriteBuffer(ctx, wg)
+	}
+
+	// In regtest demo mode do block generation work
+	// Also auto commitment to ClientCommitment to
+	// allow easier testing without db intervention
+	if isRegtest {
+		wg.Add(1)
+		go test.DoRegtestWork(dbInterface, mainConfig, wg, ctx)
+	}
+	return attestService
+}
+
 func main() {
-	defer mainConfig.MainClient().Shutdown()
+	defer shutdownClients()
+
+	// run dependency checks only and exit
+	if isCheck {
+		passed := true
+		for i, mainConfig := range mainConfigs {
+			if len(mainConfigs) > 1 {
+				fmt.Printf("attestation service %d (%s)\n", i, mainConfig.InitTx())
+			}
+			passed = runChecks(mainConfig) && passed
+		}
+		if !passed {
+			shutdownClients()
+			os.Exit(1)
+		}
+		return
+	}
 
 	wg := &sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 
-	dbInterface := server.NewDbMongo(ctx, mainConfig.DbConfig())
-	server := server.NewServer(dbInterface)
-	signer := attestation.NewAttestSignerZmq(mainConfig.SignerConfig())
-	attestService := attestation.NewAttestService(ctx, wg, server, signer, mainConfig)
-
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt)
 
+	// start all attestation services under the shared context
+	var attestServices []*attestation.AttestService
+	for _, mainConfig := range mainConfigs {
+		attestServices = append(attestServices, startService(ctx, wg, mainConfig))
+	}
+
+	// collect fatal errors of all attestation services
+	fatalErrors := make(chan error, len(attestServices))
+	for i := range attestServices {
+		wg.Add(1)
+		go func(index int, errs <-chan error) {
+			defer wg.Done()
+			select {
+			case err := <-errs:
+				fatalErrors <- errors.New(fmt.Sprintf("attestation service %d: %v", index, err))
+			case <-ctx.Done():
+			}
+		}(i, attestServices[i].Errors())
+	}
+
+	// cancel all services on fatal attestation service error
+	var fatalErr error
 	wg.Add(1)
 	go func() {
 		defer cancel()
@@ -100,20 +287,50 @@ func main() {
 		select {
 		case sig := <-c:
 			log.Printf("Got %s signal. Aborting...\n", sig)
+		case fatalErr = <-fatalErrors:
+			log.Printf("Attestation service failed with fatal error: %v. Aborting...\n", fatalErr)
+			signal.Stop(c)
 		case <-ctx.Done():
 			signal.Stop(c)
 		}
 	}()
 
+	// toggle pausing attestations on SIGUSR1, e.g. during node maintenance
+	pauseSig := make(chan os.Signal, 1)
+	signal.Notify(pauseSig, syscall.SIGUSR1)
 	wg.Add(1)
-	go attestService.Run()
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-pauseSig:
+				for _, attestService := range attestServices {
+					attestService.TogglePause()
+				}
+			case <-ctx.Done():
+				signal.Stop(pauseSig)
+				return
+			}
+		}
+	}()
+	wg.Wait()
 
-	// In regtest demo mode do block generation work
-	// Also auto commitment to ClientCommitment to
-	// allow easier testing without db intervention
-	if isRegtest {
-		wg.Add(1)
-		go test.DoRegtestWork(dbInterface, mainConfig, wg, ctx)
+	// log final state of all attestation services for post-mortem analysis
+	summaries := make([]attestation.ShutdownSummary, len(attestServices))
+	for i, attestService := range attestServices {
+		summaries[i] = attestService.LogShutdownSummary()
+	}
+	if statePath != "" {
+		if stateErr := attestation.WriteShutdownState(statePath, summaries); stateErr != nil {
+			log.Printf("Failed writing shutdown state to %s: %v\n", statePath, stateErr)
+		} else {
+			log.Printf("Shutdown state written to: %s\n", statePath)
+		}
+	}
+
+	// exit with nonzero status to allow process supervisors to restart
+	if fatalErr != nil {
+		shutdownClients()
+		os.Exit(1)
 	}
-	wg.Wait()
 }
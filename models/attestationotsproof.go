@@ -0,0 +1,459 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// error consts
+const (
+	ErrorOTSHeaderInvalid      = "OTS proof header invalid"
+	ErrorOTSVersionInvalid     = "OTS proof version not supported"
+	ErrorOTSOpInvalid          = "OTS proof op not supported"
+	ErrorOTSAttestationInvalid = "OTS proof attestation is not a mainstay attestation"
+	ErrorOTSTruncated          = "OTS proof truncated"
+	ErrorOTSTrailingData       = "OTS proof has trailing data"
+	ErrorOTSBitcoinInvalid     = "OTS proof bitcoin attestation does not match the mainstay attestation"
+	ErrorOTSTxNotInBlock       = "Attestation transaction not found in block"
+	ErrorOTSBlockRootInvalid   = "Block merkle root does not match the block header"
+)
+
+// OTS serialization consts
+// See doc/merkleproof.md for the mapping of mainstay proofs to OTS proofs
+const (
+	OTSMajorVersion = 1
+
+	otsOpSha256       = 0x08
+	otsOpAppend       = 0xf0
+	otsOpPrepend      = 0xf1
+	otsAttestationTag = 0x00
+	otsForkTag        = 0xff
+
+	otsMaxOpArgLength  = 4096
+	otsMaxPayloadSize  = 8192
+	otsMaxBlockPathOps = 64
+)
+
+// magic bytes at the start of every OTS proof file
+var OTSHeaderMagic = []byte("\x00OpenTimestamps\x00\x00Proof\x00\xbf\x89\xe2\xe8\x84\xe8\x92\x94")
+
+// tag of the mainstay attestation terminating exported OTS proofs
+var OTSMainstayAttestationTag = []byte("mainstay")
+
+// tag of the OTS bitcoin block header attestation
+var OTSBitcoinAttestationTag = []byte{0x05, 0x88, 0x96, 0x0d, 0x73, 0xd7, 0x19, 0x01}
+
+// AttestationProofBundle structure
+// Proof of a client commitment included in a confirmed attestation, i.e.
+// the commitment merkle proof, the attestation transaction, the height of
+// the block including it and the merkle path of the txid to the block
+// merkle root, which can be exported to and imported from OTS proofs
+type AttestationProofBundle struct {
+	Proof       CommitmentMerkleProof
+	Tx          wire.MsgTx
+	BlockHeight int64
+	BlockPath   []CommitmentMerkleProofOp
+}
+
+// Return new proof bundle of the commitment merkle proof attested by the
+// transaction with the txid provided in the block at the height provided
+// The block path of the txid is built from the block transactions and is
+// checked against the merkle root of the block header
+func NewAttestationProofBundle(proof CommitmentMerkleProof, txid chainhash.Hash, block *wire.MsgBlock, blockHeight int64) (AttestationProofBundle, error) {
+	var hashes []chainhash.Hash
+	txIndex := -1
+	for i, tx := range block.Transactions {
+		hashes = append(hashes, tx.TxHash())
+		if hashes[i] == txid {
+			txIndex = i
+		}
+	}
+	if txIndex < 0 {
+		return AttestationProofBundle{}, errors.New(fmt.Sprintf("%s (%s)", ErrorOTSTxNotInBlock, txid.String()))
+	}
+
+	// build path as in the bitcoin merkle tree, where the
+	// last node of a tree height with odd width is duplicated
+	var blockPath []CommitmentMerkleProofOp
+	index := txIndex
+	for len(hashes) > 1 {
+		if len(hashes)%2 == 1 {
+			hashes = append(hashes, hashes[len(hashes)-1])
+		}
+		if index%2 == 0 {
+			blockPath = append(blockPath, CommitmentMerkleProofOp{true, hashes[index+1]})
+		} else {
+			blockPath = append(blockPath, CommitmentMerkleProofOp{false, hashes[index-1]})
+		}
+		var nextHashes []chainhash.Hash
+		for i := 0; i < len(hashes); i += 2 {
+			nextHashes = append(nextHashes, *hashLeaves(hashes[i], hashes[i+1]))
+		}
+		hashes = nextHashes
+		index /= 2
+	}
+
+	bundle := AttestationProofBundle{
+		Proof:       proof,
+		Tx:          *block.Transactions[txIndex],
+		BlockHeight: blockHeight,
+		BlockPath:   blockPath}
+	if bundle.BlockMerkleRoot() != block.Header.MerkleRoot {
+		return AttestationProofBundle{}, errors.New(fmt.Sprintf("%s (%s)",
+			ErrorOTSBlockRootInvalid, block.Header.MerkleRoot.String()))
+	}
+	return bundle, nil
+}
+
+// Get block merkle root by applying the block path to the attestation txid
+// Block path ops are applied as in commitment merkle proofs, as bitcoin also
+// double SHA256 hashes the concatenation of the two nodes at each tree height
+func (b AttestationProofBundle) BlockMerkleRoot() chainhash.Hash {
+	return applyMerkleOps(b.Tx.TxHash(), b.BlockPath)
+}
+
+// Serialize proof bundle as an OTS proof
+// Commitment merkle proof ops are mapped to OTS append/prepend and sha256 ops
+// from the client commitment to the merkle root, which is attested by a
+// mainstay attestation carrying the remaining bundle fields in its payload
+// If the attestation transaction includes the merkle root in an OP_RETURN
+// output, the merkle root is also attested by a bitcoin attestation forking
+// from the mainstay attestation, which OTS tooling can verify by itself
+func (b AttestationProofBundle) MarshalOTS() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(OTSHeaderMagic)
+	writeOTSVarUint(&buf, OTSMajorVersion)
+	buf.WriteByte(otsOpSha256)
+	buf.Write(b.Proof.Commitment.CloneBytes())
+
+	for _, op := range b.Proof.Ops {
+		if op.Append {
+			buf.WriteByte(otsOpAppend)
+		} else {
+			buf.WriteByte(otsOpPrepend)
+		}
+		writeOTSVarBytes(&buf, op.Commitment.CloneBytes())
+		buf.WriteByte(otsOpSha256)
+		buf.WriteByte(otsOpSha256)
+	}
+
+	var txBuf bytes.Buffer
+	if txErr := b.Tx.Serialize(&txBuf); txErr != nil {
+		return nil, txErr
+	}
+	var payload bytes.Buffer
+	writeOTSVarUint(&payload, uint64(b.Proof.ClientPosition))
+	writeOTSVarBytes(&payload, txBuf.Bytes())
+	writeOTSVarUint(&payload, uint64(b.BlockHeight))
+	writeOTSVarUint(&payload, uint64(len(b.BlockPath)))
+	for _, op := range b.BlockPath {
+		if op.Append {
+			payload.WriteByte(1)
+		} else {
+			payload.WriteByte(0)
+		}
+		payload.Write(op.Commitment.CloneBytes())
+	}
+	if payload.Len() > otsMaxPayloadSize {
+		return nil, errors.New(fmt.Sprintf("%s (%d bytes)", ErrorOTSAttestationInvalid, payload.Len()))
+	}
+
+	bitcoinBranch, hasBitcoinBranch := b.bitcoinBranchOTS()
+	if hasBitcoinBranch {
+		buf.WriteByte(otsForkTag)
+	}
+	buf.WriteByte(otsAttestationTag)
+	buf.Write(OTSMainstayAttestationTag)
+	writeOTSVarBytes(&buf, payload.Bytes())
+	buf.Write(bitcoinBranch)
+	return buf.Bytes(), nil
+}
+
+// Serialize OTS ops from the merkle root to a bitcoin attestation of the block
+// including the attestation transaction, i.e. prepend and append of the
+// serialized transaction around the merkle root in the OP_RETURN output and
+// sha256 twice to the txid, followed by the block path ops to the block
+// merkle root attested by the block header at the block height
+// Returns false if the transaction has no OP_RETURN output of the merkle root
+func (b AttestationProofBundle) bitcoinBranchOTS() ([]byte, bool) {
+	script, scriptErr := txscript.NullDataScript(b.Proof.MerkleRoot.CloneBytes())
+	if scriptErr != nil {
+		return nil, false
+	}
+	var txBuf bytes.Buffer
+	if txErr := b.Tx.SerializeNoWitness(&txBuf); txErr != nil || txBuf.Len() > otsMaxOpArgLength {
+		return nil, false
+	}
+	rootIndex := -1
+	for _, txOut := range b.Tx.TxOut {
+		if bytes.Equal(txOut.PkScript, script) {
+			// the script is serialized after its length, which is a single byte
+			scriptIndex := bytes.Index(txBuf.Bytes(), append([]byte{byte(len(script))}, script...))
+			if scriptIndex >= 0 {
+				rootIndex = scriptIndex + 1 + len(script) - chainhash.HashSize
+			}
+			break
+		}
+	}
+	if rootIndex < 0 {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	txBytes := txBuf.Bytes()
+	buf.WriteByte(otsOpPrepend)
+	writeOTSVarBytes(&buf, txBytes[:rootIndex])
+	buf.WriteByte(otsOpAppend)
+	writeOTSVarBytes(&buf, txBytes[rootIndex+chainhash.HashSize:])
+	buf.WriteByte(otsOpSha256)
+	buf.WriteByte(otsOpSha256)
+	for _, op := range b.BlockPath {
+		if op.Append {
+			buf.WriteByte(otsOpAppend)
+		} else {
+			buf.WriteByte(otsOpPrepend)
+		}
+		writeOTSVarBytes(&buf, op.Commitment.CloneBytes())
+		buf.WriteByte(otsOpSha256)
+		buf.WriteByte(otsOpSha256)
+	}
+
+	var payload bytes.Buffer
+	writeOTSVarUint(&payload, uint64(b.BlockHeight))
+	buf.WriteByte(otsAttestationTag)
+	buf.Write(OTSBitcoinAttestationTag)
+	writeOTSVarBytes(&buf, payload.Bytes())
+	return buf.Bytes(), true
+}
+
+// Deserialize proof bundle from an OTS proof exported by MarshalOTS
+// The merkle root is recomputed from the commitment and the OTS ops
+// A bitcoin attestation forking from the mainstay attestation is
+// checked against the bitcoin attestation of the imported bundle
+func (b *AttestationProofBundle) UnmarshalOTS(data []byte) error {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(OTSHeaderMagic))
+	if _, readErr := r.Read(magic); readErr != nil || !bytes.Equal(magic, OTSHeaderMagic) {
+		return errors.New(ErrorOTSHeaderInvalid)
+	}
+	version, versionErr := readOTSVarUint(r)
+	if versionErr != nil {
+		return versionErr
+	} else if version != OTSMajorVersion {
+		return errors.New(fmt.Sprintf("%s (%d)", ErrorOTSVersionInvalid, version))
+	}
+	if opErr := readOTSOp(r, otsOpSha256); opErr != nil {
+		return opErr
+	}
+	commitment, commitmentErr := readOTSHash(r)
+	if commitmentErr != nil {
+		return commitmentErr
+	}
+
+	// read merkle proof ops until the attestation or the fork to it is reached
+	var ops []CommitmentMerkleProofOp
+	forked := false
+	for {
+		tag, tagErr := r.ReadByte()
+		if tagErr != nil {
+			return errors.New(ErrorOTSTruncated)
+		}
+		if tag == otsAttestationTag {
+			break
+		} else if tag == otsForkTag {
+			if opErr := readOTSOp(r, otsAttestationTag); opErr != nil {
+				return opErr
+			}
+			forked = true
+			break
+		} else if tag != otsOpAppend && tag != otsOpPrepend {
+			return errors.New(fmt.Sprintf("%s (0x%02x)", ErrorOTSOpInvalid, tag))
+		}
+		arg, argErr := readOTSVarBytes(r, otsMaxOpArgLength)
+		if argErr != nil {
+			return argErr
+		} else if len(arg) != chainhash.HashSize {
+			return errors.New(fmt.Sprintf("%s (%d byte arg)", ErrorOTSOpInvalid, len(arg)))
+		}
+		for i := 0; i < 2; i++ {
+			if opErr := readOTSOp(r, otsOpSha256); opErr != nil {
+				return opErr
+			}
+		}
+		opCommitment, _ := chainhash.NewHash(arg)
+		ops = append(ops, CommitmentMerkleProofOp{tag == otsOpAppend, *opCommitment})
+	}
+
+	// read mainstay attestation payload
+	attestationTag := make([]byte, len(OTSMainstayAttestationTag))
+	if _, readErr := r.Read(attestationTag); readErr != nil {
+		return errors.New(ErrorOTSTruncated)
+	} else if !bytes.Equal(attestationTag, OTSMainstayAttestationTag) {
+		return errors.New(fmt.Sprintf("%s (%x)", ErrorOTSAttestationInvalid, attestationTag))
+	}
+	payloadBytes, payloadErr := readOTSVarBytes(r, otsMaxPayloadSize)
+	if payloadErr != nil {
+		return payloadErr
+	} else if !forked && r.Len() > 0 {
+		return errors.New(fmt.Sprintf("%s (%d bytes)", ErrorOTSTrailingData, r.Len()))
+	}
+	payload := bytes.NewReader(payloadBytes)
+	position, positionErr := readOTSVarUint(payload)
+	if positionErr != nil {
+		return positionErr
+	}
+	txBytes, txBytesErr := readOTSVarBytes(payload, otsMaxPayloadSize)
+	if txBytesErr != nil {
+		return txBytesErr
+	}
+	var tx wire.MsgTx
+	if txErr := tx.Deserialize(bytes.NewReader(txBytes)); txErr != nil {
+		return txErr
+	}
+	height, heightErr := readOTSVarUint(payload)
+	if heightErr != nil {
+		return heightErr
+	}
+	numOfOps, numOfOpsErr := readOTSVarUint(payload)
+	if numOfOpsErr != nil {
+		return numOfOpsErr
+	} else if numOfOps > otsMaxBlockPathOps {
+		return errors.New(fmt.Sprintf("%s (%d block path ops)", ErrorOTSAttestationInvalid, numOfOps))
+	}
+	var blockPath []CommitmentMerkleProofOp
+	for i := uint64(0); i < numOfOps; i++ {
+		appendFlag, flagErr := payload.ReadByte()
+		if flagErr != nil {
+			return errors.New(ErrorOTSTruncated)
+		}
+		opCommitment, opErr := readOTSHash(payload)
+		if opErr != nil {
+			return opErr
+		}
+		blockPath = append(blockPath, CommitmentMerkleProofOp{appendFlag == 1, opCommitment})
+	}
+	if payload.Len() > 0 {
+		return errors.New(fmt.Sprintf("%s (%d bytes)", ErrorOTSTrailingData, payload.Len()))
+	}
+
+	bundle := AttestationProofBundle{
+		Proof: CommitmentMerkleProof{
+			MerkleRoot:     applyMerkleOps(commitment, ops),
+			ClientPosition: int32(position),
+			Commitment:     commitment,
+			Ops:            ops,
+			LeafIndex:      leafIndexFromOps(ops)},
+		Tx:          tx,
+		BlockHeight: int64(height),
+		BlockPath:   blockPath}
+
+	// check the bitcoin attestation branch after the fork
+	if forked {
+		bitcoinBranch, hasBitcoinBranch := bundle.bitcoinBranchOTS()
+		if !hasBitcoinBranch || !bytes.Equal(bitcoinBranch, data[len(data)-r.Len():]) {
+			return errors.New(ErrorOTSBitcoinInvalid)
+		}
+	}
+	*b = bundle
+	return nil
+}
+
+// Return the leaf index of a merkle proof from its ops, as the sibling of
+// each op is on the left, i.e. prepended, if the index bit at its height is set
+func leafIndexFromOps(ops []CommitmentMerkleProofOp) int32 {
+	var leafIndex int32
+	for i, op := range ops {
+		if !op.Append {
+			leafIndex |= 1 << uint(i)
+		}
+	}
+	return leafIndex
+}
+
+// Apply merkle proof ops to the hash provided and return the resulting hash
+func applyMerkleOps(hash chainhash.Hash, ops []CommitmentMerkleProofOp) chainhash.Hash {
+	for _, op := range ops {
+		if op.Append {
+			hash = *hashLeaves(hash, op.Commitment)
+		} else {
+			hash = *hashLeaves(op.Commitment, hash)
+		}
+	}
+	return hash
+}
+
+// Write OTS variable length unsigned integer, i.e. LEB128
+func writeOTSVarUint(buf *bytes.Buffer, value uint64) {
+	for value >= 0x80 {
+		buf.WriteByte(byte(value) | 0x80)
+		value >>= 7
+	}
+	buf.WriteByte(byte(value))
+}
+
+// Write OTS variable length bytes prefixed with their length
+func writeOTSVarBytes(buf *bytes.Buffer, data []byte) {
+	writeOTSVarUint(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+// Read OTS variable length unsigned integer
+func readOTSVarUint(r *bytes.Reader) (uint64, error) {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, readErr := r.ReadByte()
+		if readErr != nil {
+			return 0, errors.New(ErrorOTSTruncated)
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, errors.New(ErrorOTSTruncated)
+}
+
+// Read OTS variable length bytes up to the maximum length provided
+func readOTSVarBytes(r *bytes.Reader, maxLength int) ([]byte, error) {
+	length, lengthErr := readOTSVarUint(r)
+	if lengthErr != nil {
+		return nil, lengthErr
+	} else if length > uint64(maxLength) {
+		return nil, errors.New(fmt.Sprintf("%s (%d bytes)", ErrorOTSOpInvalid, length))
+	} else if length > uint64(r.Len()) {
+		return nil, errors.New(ErrorOTSTruncated)
+	}
+	data := make([]byte, length)
+	r.Read(data)
+	return data, nil
+}
+
+// Read OTS op expected next
+func readOTSOp(r *bytes.Reader, op byte) error {
+	tag, tagErr := r.ReadByte()
+	if tagErr != nil {
+		return errors.New(ErrorOTSTruncated)
+	} else if tag != op {
+		return errors.New(fmt.Sprintf("%s (0x%02x)", ErrorOTSOpInvalid, tag))
+	}
+	return nil
+}
+
+// Read 32 byte hash
+func readOTSHash(r *bytes.Reader) (chainhash.Hash, error) {
+	var hash chainhash.Hash
+	if r.Len() < chainhash.HashSize {
+		return hash, errors.New(ErrorOTSTruncated)
+	}
+	r.Read(hash[:])
+	return hash, nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test attestation proof bundle OTS export and import round trip
+func TestAttestationProofBundle_OTS(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	proof := commitment.GetMerkleProofs()[2]
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash0, 0), []byte{0x51}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+	bundle := AttestationProofBundle{
+		Proof:       proof,
+		Tx:          *tx,
+		BlockHeight: 600000,
+		BlockPath:   []CommitmentMerkleProofOp{CommitmentMerkleProofOp{false, *hash1}, CommitmentMerkleProofOp{true, *hash2}}}
+
+	// test export starts with the OTS header and the client commitment
+	otsBytes, exportErr := bundle.MarshalOTS()
+	assert.Equal(t, nil, exportErr)
+	assert.Equal(t, true, bytes.HasPrefix(otsBytes, OTSHeaderMagic))
+	assert.Equal(t, append([]byte{OTSMajorVersion, otsOpSha256}, hash2.CloneBytes()...),
+		otsBytes[len(OTSHeaderMagic):len(OTSHeaderMagic)+2+chainhash.HashSize])
+
+	// test OTS ops replayed with plain sha256 reach the merkle root
+	msg := hash2.CloneBytes()
+	for _, op := range proof.Ops {
+		if op.Append {
+			msg = append(msg, op.Commitment.CloneBytes()...)
+		} else {
+			msg = append(op.Commitment.CloneBytes(), msg...)
+		}
+		first := sha256.Sum256(msg)
+		second := sha256.Sum256(first[:])
+		msg = second[:]
+	}
+	root := commitment.GetCommitmentHash()
+	assert.Equal(t, root.CloneBytes(), msg)
+
+	// test import round trip
+	var imported AttestationProofBundle
+	assert.Equal(t, nil, imported.UnmarshalOTS(otsBytes))
+	assert.Equal(t, proof, imported.Proof)
+	assert.Equal(t, true, ProveMerkleProof(imported.Proof))
+	assert.Equal(t, tx.TxHash(), imported.Tx.TxHash())
+	assert.Equal(t, int64(600000), imported.BlockHeight)
+	assert.Equal(t, bundle.BlockPath, imported.BlockPath)
+	assert.Equal(t, *hashLeaves(*hashLeaves(*hash1, tx.TxHash()), *hash2), imported.BlockMerkleRoot())
+
+	// test invalid OTS proofs
+	assert.Equal(t, errors.New(ErrorOTSHeaderInvalid), imported.UnmarshalOTS([]byte("invalid")))
+	assert.Equal(t, errors.New(ErrorOTSTruncated), imported.UnmarshalOTS(otsBytes[:len(otsBytes)-1]))
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (1 bytes)", ErrorOTSTrailingData)),
+		imported.UnmarshalOTS(append(append([]byte{}, otsBytes...), 0x00)))
+
+	tagIndex := bytes.Index(otsBytes, OTSMainstayAttestationTag)
+	bitcoinTag := OTSBitcoinAttestationTag
+	otherBytes := append(append(append([]byte{}, otsBytes[:tagIndex]...), bitcoinTag...),
+		otsBytes[tagIndex+len(bitcoinTag):]...)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%x)", ErrorOTSAttestationInvalid, bitcoinTag)),
+		imported.UnmarshalOTS(otherBytes))
+
+	forkIndex := len(OTSHeaderMagic) + 2 + chainhash.HashSize
+	otherBytes = append(append([]byte{}, otsBytes[:forkIndex]...), 0xff, 0xff)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (0xff)", ErrorOTSOpInvalid)), imported.UnmarshalOTS(otherBytes))
+}
+
+// Replay OTS ops on the message provided with plain sha256 until an attestation
+// is reached and return the resulting message and the attestation bytes
+func replayOTSOps(t *testing.T, msg []byte, data []byte) ([]byte, []byte) {
+	r := bytes.NewReader(data)
+	for {
+		tag, _ := r.ReadByte()
+		switch tag {
+		case otsOpSha256:
+			hash := sha256.Sum256(msg)
+			msg = hash[:]
+		case otsOpAppend, otsOpPrepend:
+			arg, argErr := readOTSVarBytes(r, otsMaxOpArgLength)
+			assert.Equal(t, nil, argErr)
+			if tag == otsOpAppend {
+				msg = append(append([]byte{}, msg...), arg...)
+			} else {
+				msg = append(append([]byte{}, arg...), msg...)
+			}
+		case otsAttestationTag:
+			return msg, data[len(data)-r.Len():]
+		default:
+			t.Fatalf("unexpected OTS op 0x%02x", tag)
+		}
+	}
+}
+
+// Test attestation proof bundle built from a block and exported with a
+// bitcoin attestation for attestation transactions with an OP_RETURN output
+func TestAttestationProofBundle_OTSBitcoinAttestation(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	proof := commitment.GetMerkleProofs()[1]
+	root := commitment.GetCommitmentHash()
+
+	// block with the attestation tx last, so that it is duplicated in the block merkle tree
+	var txs []*wire.MsgTx
+	for i, hash := range []*chainhash.Hash{hash0, hash1, hash2} {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, uint32(i)), []byte{0x51}, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+		txs = append(txs, tx)
+	}
+	opReturn, _ := txscript.NullDataScript(root.CloneBytes())
+	txs[2].AddTxOut(wire.NewTxOut(0, opReturn))
+	blockRoot := *hashLeaves(*hashLeaves(txs[0].TxHash(), txs[1].TxHash()), *hashLeaves(txs[2].TxHash(), txs[2].TxHash()))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, hash0, &blockRoot, 0, 0))
+	for _, tx := range txs {
+		block.AddTransaction(tx)
+	}
+
+	// test bundle block path from block
+	bundle, bundleErr := NewAttestationProofBundle(proof, txs[2].TxHash(), block, 600000)
+	assert.Equal(t, nil, bundleErr)
+	assert.Equal(t, []CommitmentMerkleProofOp{CommitmentMerkleProofOp{true, txs[2].TxHash()},
+		CommitmentMerkleProofOp{false, *hashLeaves(txs[0].TxHash(), txs[1].TxHash())}}, bundle.BlockPath)
+	assert.Equal(t, blockRoot, bundle.BlockMerkleRoot())
+
+	_, bundleErr = NewAttestationProofBundle(proof, *hash0, block, 600000)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorOTSTxNotInBlock, hash0.String())), bundleErr)
+	block.Header.MerkleRoot = *hash1
+	_, bundleErr = NewAttestationProofBundle(proof, txs[2].TxHash(), block, 600000)
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%s)", ErrorOTSBlockRootInvalid, hash1.String())), bundleErr)
+
+	// test OTS ops replayed from the merkle root reach the txid and the block
+	// merkle root, which is attested by a bitcoin attestation at the block height
+	otsBytes, exportErr := bundle.MarshalOTS()
+	assert.Equal(t, nil, exportErr)
+	branch, hasBranch := bundle.bitcoinBranchOTS()
+	assert.Equal(t, true, hasBranch)
+	assert.Equal(t, true, bytes.HasSuffix(otsBytes, branch))
+	assert.Equal(t, byte(otsForkTag), otsBytes[bytes.Index(otsBytes, OTSMainstayAttestationTag)-2])
+
+	msg, attestation := replayOTSOps(t, root.CloneBytes(), branch)
+	assert.Equal(t, blockRoot.CloneBytes(), msg)
+	assert.Equal(t, append(append([]byte{}, OTSBitcoinAttestationTag...), 0x03, 0xc0, 0xcf, 0x24), attestation)
+
+	// test import round trip
+	var imported AttestationProofBundle
+	assert.Equal(t, nil, imported.UnmarshalOTS(otsBytes))
+	assert.Equal(t, proof, imported.Proof)
+	assert.Equal(t, txs[2].TxHash(), imported.Tx.TxHash())
+	assert.Equal(t, bundle.BlockPath, imported.BlockPath)
+	assert.Equal(t, int64(600000), imported.BlockHeight)
+
+	// test bitcoin attestation not matching the mainstay attestation
+	otherBytes := append([]byte{}, otsBytes...)
+	otherBytes[len(otherBytes)-1] ^= 0x01
+	assert.Equal(t, errors.New(ErrorOTSBitcoinInvalid), imported.UnmarshalOTS(otherBytes))
+	assert.Equal(t, errors.New(ErrorOTSBitcoinInvalid), imported.UnmarshalOTS(otsBytes[:len(otsBytes)-1]))
+
+	// test no bitcoin attestation without the OP_RETURN output
+	bundle.Tx.TxOut = bundle.Tx.TxOut[:1]
+	_, hasBranch = bundle.bitcoinBranchOTS()
+	assert.Equal(t, false, hasBranch)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// error consts
+const (
+	ErrorReceiptSignatureInvalid = "Attestation receipt signature invalid"
+	ErrorReceiptPubkeyMissing    = "Attestation receipt operator pubkey missing"
+)
+
+// AttestationReceipt structure
+// Receipt of a confirmed attestation signed by the operator key
+// binding the attested merkle root to the attestation txid, the
+// height of the bitcoin block including it and the attestation time
+// Clients can store receipts and verify them without the server
+type AttestationReceipt struct {
+	MerkleRoot  chainhash.Hash
+	Txid        chainhash.Hash
+	BlockHeight int64
+	Time        int64
+	Signature   []byte
+}
+
+// Get receipt hash signed by the operator key
+// Double SHA256 of merkle root || txid || block height || time
+// with block height and time serialised as 8 byte little endian
+func (r AttestationReceipt) Hash() chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Write(r.MerkleRoot.CloneBytes())
+	buf.Write(r.Txid.CloneBytes())
+	binary.Write(&buf, binary.LittleEndian, r.BlockHeight)
+	binary.Write(&buf, binary.LittleEndian, r.Time)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Sign receipt hash with the operator private key
+func (r *AttestationReceipt) Sign(operatorKey *btcec.PrivateKey) error {
+	hash := r.Hash()
+	sig, sigErr := operatorKey.Sign(hash.CloneBytes())
+	if sigErr != nil {
+		return sigErr
+	}
+	r.Signature = sig.Serialize()
+	return nil
+}
+
+// Verify receipt signature against the operator pubkey
+// Any modification of the receipt fields invalidates the signature
+func VerifyReceipt(receipt AttestationReceipt, operatorPubkey *btcec.PublicKey) error {
+	if operatorPubkey == nil {
+		return errors.New(ErrorReceiptPubkeyMissing)
+	}
+	sig, sigErr := btcec.ParseDERSignature(receipt.Signature, btcec.S256())
+	if sigErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorReceiptSignatureInvalid, sigErr))
+	}
+	hash := receipt.Hash()
+	if !sig.Verify(hash.CloneBytes(), operatorPubkey) {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorReceiptSignatureInvalid, receipt.Txid.String()))
+	}
+	return nil
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (r AttestationReceipt) MarshalBSON() ([]byte, error) {
+	receiptBSON := AttestationReceiptBSON{
+		MerkleRoot:  r.MerkleRoot.String(),
+		Txid:        r.Txid.String(),
+		BlockHeight: r.BlockHeight,
+		Time:        r.Time,
+		Signature:   hex.EncodeToString(r.Signature)}
+	return bson.Marshal(receiptBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (r *AttestationReceipt) UnmarshalBSON(b []byte) error {
+	var receiptBSON AttestationReceiptBSON
+	if err := bson.Unmarshal(b, &receiptBSON); err != nil {
+		return err
+	}
+	merkleRoot, rootErr := chainhash.NewHashFromStr(receiptBSON.MerkleRoot)
+	if rootErr != nil {
+		return rootErr
+	}
+	txid, txidErr := chainhash.NewHashFromStr(receiptBSON.Txid)
+	if txidErr != nil {
+		return txidErr
+	}
+	sig, sigErr := hex.DecodeString(receiptBSON.Signature)
+	if sigErr != nil {
+		return sigErr
+	}
+	r.MerkleRoot = *merkleRoot
+	r.Txid = *txid
+	r.BlockHeight = receiptBSON.BlockHeight
+	r.Time = receiptBSON.Time
+	r.Signature = sig
+	return nil
+}
+
+// AttestationReceipt field names
+const (
+	AttestationReceiptMerkleRootName  = "merkle_root"
+	AttestationReceiptTxidName        = "txid"
+	AttestationReceiptBlockHeightName = "block_height"
+	AttestationReceiptTimeName        = "time"
+	AttestationReceiptSignatureName   = "signature"
+)
+
+// AttestationReceiptBSON structure for mongoDB
+type AttestationReceiptBSON struct {
+	MerkleRoot  string `bson:"merkle_root"`
+	Txid        string `bson:"txid"`
+	BlockHeight int64  `bson:"block_height"`
+	Time        int64  `bson:"time"`
+	Signature   string `bson:"signature"`
+}
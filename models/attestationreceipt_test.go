@@ -0,0 +1,86 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test AttestationReceipt signing and verification
+func TestAttestationReceiptVerify(t *testing.T) {
+	root, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	operatorKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), root.CloneBytes())
+	otherKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), txid.CloneBytes())
+
+	receipt := AttestationReceipt{
+		MerkleRoot:  *root,
+		Txid:        *txid,
+		BlockHeight: 580000,
+		Time:        1542121293}
+	assert.Equal(t, nil, receipt.Sign(operatorKey))
+
+	// test valid receipt
+	assert.Equal(t, nil, VerifyReceipt(receipt, operatorKey.PubKey()))
+
+	// test invalid operator pubkey
+	verifyErr := VerifyReceipt(receipt, otherKey.PubKey())
+	assert.NotEqual(t, nil, verifyErr)
+	assert.Equal(t, true, strings.HasPrefix(verifyErr.Error(), ErrorReceiptSignatureInvalid))
+	verifyErr = VerifyReceipt(receipt, nil)
+	assert.NotEqual(t, nil, verifyErr)
+	assert.Equal(t, ErrorReceiptPubkeyMissing, verifyErr.Error())
+
+	// test tampering with any receipt field
+	tampered := []AttestationReceipt{receipt, receipt, receipt, receipt, receipt}
+	tampered[0].MerkleRoot = *txid
+	tampered[1].Txid = *root
+	tampered[2].BlockHeight++
+	tampered[3].Time++
+	tampered[4].Signature = append([]byte{}, receipt.Signature...)
+	tampered[4].Signature[len(tampered[4].Signature)-1] ^= 0x01
+	for _, tamperedReceipt := range tampered {
+		verifyErr = VerifyReceipt(tamperedReceipt, operatorKey.PubKey())
+		assert.NotEqual(t, nil, verifyErr)
+		assert.Equal(t, true, strings.HasPrefix(verifyErr.Error(), ErrorReceiptSignatureInvalid))
+	}
+
+	// test missing signature
+	receipt.Signature = nil
+	verifyErr = VerifyReceipt(receipt, operatorKey.PubKey())
+	assert.NotEqual(t, nil, verifyErr)
+	assert.Equal(t, true, strings.HasPrefix(verifyErr.Error(), ErrorReceiptSignatureInvalid))
+}
+
+// Test AttestationReceipt BSON interface
+func TestAttestationReceiptBSON(t *testing.T) {
+	root, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	receipt := AttestationReceipt{*root, *txid, 580000, 1542121293, []byte{1, 2, 3}}
+
+	// test marshal and unmarshal receipt model
+	bytes, errBytes := receipt.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testReceipt := &AttestationReceipt{}
+	assert.Equal(t, nil, testReceipt.UnmarshalBSON(bytes))
+	assert.Equal(t, receipt, *testReceipt)
+
+	// test receipt model to document
+	doc, docErr := GetDocumentFromModel(receipt)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, txid.String(), doc.Lookup(AttestationReceiptTxidName).StringValue())
+	assert.Equal(t, "010203", doc.Lookup(AttestationReceiptSignatureName).StringValue())
+
+	// test reverse document to receipt model
+	testtestReceipt := &AttestationReceipt{}
+	docErr = GetModelFromDocument(doc, testtestReceipt)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, receipt, *testtestReceipt)
+}
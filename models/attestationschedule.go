@@ -0,0 +1,83 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AttestationSchedule structure
+// Schedule of the next attestation published by the attestation service
+// Client commitments received before the acceptance cutoff are included in
+// the next attestation, while later commitments may have to wait for the
+// following attestation. Used by the API to let clients time submissions
+type AttestationSchedule struct {
+	NextAttestation  time.Time
+	AcceptanceCutoff time.Time
+	UpdatedAt        time.Time
+}
+
+// Return new AttestationSchedule for the next attestation time with
+// the acceptance cutoff set acceptance window before the attestation
+func NewAttestationSchedule(nextAttestation time.Time, acceptanceWindow time.Duration, updatedAt time.Time) AttestationSchedule {
+	return AttestationSchedule{
+		NextAttestation:  nextAttestation,
+		AcceptanceCutoff: nextAttestation.Add(-acceptanceWindow),
+		UpdatedAt:        updatedAt}
+}
+
+// Get time until the next attestation - zero if already due
+func (s AttestationSchedule) TimeUntilNext(now time.Time) time.Duration {
+	if now.After(s.NextAttestation) {
+		return 0
+	}
+	return s.NextAttestation.Sub(now)
+}
+
+// Check if a commitment received at the time provided
+// makes the next attestation, i.e. before the cutoff
+func (s AttestationSchedule) IsAccepted(received time.Time) bool {
+	if s.NextAttestation.IsZero() {
+		return false
+	}
+	return received.Before(s.AcceptanceCutoff)
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (s AttestationSchedule) MarshalBSON() ([]byte, error) {
+	scheduleBSON := AttestationScheduleBSON{
+		NextAttestation:  s.NextAttestation,
+		AcceptanceCutoff: s.AcceptanceCutoff,
+		UpdatedAt:        s.UpdatedAt}
+	return bson.Marshal(scheduleBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (s *AttestationSchedule) UnmarshalBSON(b []byte) error {
+	var scheduleBSON AttestationScheduleBSON
+	if err := bson.Unmarshal(b, &scheduleBSON); err != nil {
+		return err
+	}
+	s.NextAttestation = scheduleBSON.NextAttestation
+	s.AcceptanceCutoff = scheduleBSON.AcceptanceCutoff
+	s.UpdatedAt = scheduleBSON.UpdatedAt
+	return nil
+}
+
+// AttestationSchedule field names
+const (
+	AttestationScheduleNextAttestationName  = "next_attestation"
+	AttestationScheduleAcceptanceCutoffName = "acceptance_cutoff"
+	AttestationScheduleUpdatedAtName        = "updated_at"
+)
+
+// AttestationScheduleBSON structure for mongoDB
+type AttestationScheduleBSON struct {
+	NextAttestation  time.Time `bson:"next_attestation"`
+	AcceptanceCutoff time.Time `bson:"acceptance_cutoff"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test AttestationSchedule acceptance window
+func TestAttestationSchedule(t *testing.T) {
+	now := time.Unix(1542121293, 0)
+	schedule := NewAttestationSchedule(now.Add(10*time.Minute), time.Minute, now)
+	assert.Equal(t, now.Add(9*time.Minute), schedule.AcceptanceCutoff)
+
+	// test time until next attestation
+	assert.Equal(t, 10*time.Minute, schedule.TimeUntilNext(now))
+	assert.Equal(t, time.Minute, schedule.TimeUntilNext(now.Add(9*time.Minute)))
+	assert.Equal(t, time.Duration(0), schedule.TimeUntilNext(now.Add(11*time.Minute)))
+
+	// test commitments accepted before cutoff only
+	assert.Equal(t, true, schedule.IsAccepted(now))
+	assert.Equal(t, true, schedule.IsAccepted(now.Add(9*time.Minute-time.Second)))
+	assert.Equal(t, false, schedule.IsAccepted(now.Add(9*time.Minute)))
+	assert.Equal(t, false, schedule.IsAccepted(now.Add(10*time.Minute)))
+
+	// test no acceptance window
+	schedule = NewAttestationSchedule(now.Add(10*time.Minute), 0, now)
+	assert.Equal(t, true, schedule.IsAccepted(now.Add(10*time.Minute-time.Second)))
+	assert.Equal(t, false, schedule.IsAccepted(now.Add(10*time.Minute)))
+
+	// test no schedule published
+	assert.Equal(t, false, AttestationSchedule{}.IsAccepted(now))
+}
+
+// Test AttestationSchedule BSON interface
+func TestAttestationScheduleBSON(t *testing.T) {
+	now := time.Unix(1542121293, 0)
+	schedule := NewAttestationSchedule(now.Add(10*time.Minute), time.Minute, now)
+
+	// test marshal and unmarshal schedule model
+	bytes, errBytes := schedule.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testSchedule := &AttestationSchedule{}
+	assert.Equal(t, nil, testSchedule.UnmarshalBSON(bytes))
+	assert.Equal(t, schedule.NextAttestation.Unix(), testSchedule.NextAttestation.Unix())
+	assert.Equal(t, schedule.AcceptanceCutoff.Unix(), testSchedule.AcceptanceCutoff.Unix())
+	assert.Equal(t, schedule.UpdatedAt.Unix(), testSchedule.UpdatedAt.Unix())
+
+	// test schedule model to document
+	doc, docErr := GetDocumentFromModel(schedule)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, schedule.NextAttestation.Unix(), doc.Lookup(AttestationScheduleNextAttestationName).Time().Unix())
+
+	// test reverse document to schedule model
+	testtestSchedule := &AttestationSchedule{}
+	docErr = GetModelFromDocument(doc, testtestSchedule)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, schedule.AcceptanceCutoff.Unix(), testtestSchedule.AcceptanceCutoff.Unix())
+}
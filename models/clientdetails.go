@@ -5,21 +5,59 @@
 package models
 
 import (
+	"crypto/sha256"
+
 	_ "go.mongodb.org/mongo-driver/bson"
 )
 
 // struct for db ClientDetails
+// SignatureScheme is the scheme of the client pubkey used to verify
+// client commitment signatures - ECDSA if not set
+// CommitmentKind is the kind of value committed by the client used to
+// validate client commitments on submission - custom if not set
 type ClientDetails struct {
-	ClientPosition int32  `bson:"client_position"`
-	AuthToken      string `bson:"auth_token"`
-	Pubkey         string `bson:"pubkey"`
-	ClientName     string `bson:"client_name"`
+	ClientPosition  int32  `bson:"client_position"`
+	AuthToken       string `bson:"auth_token"`
+	Pubkey          string `bson:"pubkey"`
+	ClientName      string `bson:"client_name"`
+	SignatureScheme string `bson:"signature_scheme,omitempty"`
+	CommitmentKind  string `bson:"commitment_kind,omitempty"`
+}
+
+// ClientDetails signature schemes
+const (
+	SignatureSchemeECDSA   = "ecdsa"   // secp256k1 ECDSA with DER signatures
+	SignatureSchemeSchnorr = "schnorr" // secp256k1 BIP-340 schnorr with x-only pubkeys
+)
+
+// Commitment signature message prefix recommended for domain separation
+// so that client signatures cannot be reused by a different protocol
+const DefaultCommitmentMessagePrefix = "mainstay-commitment:"
+
+// Get the message signed by clients for the commitment bytes provided
+// In raw mode, with no prefix set, the commitment bytes are signed as is
+// for backward compatibility, otherwise the sha256 hash of the prefix
+// followed by the commitment bytes is signed
+func CommitmentSignatureMessage(commitment []byte, prefix string) []byte {
+	if prefix == "" {
+		return commitment
+	}
+	msg := sha256.Sum256(append([]byte(prefix), commitment...))
+	return msg[:]
 }
 
+// ClientDetails commitment kinds
+const (
+	CommitmentKindBlockhash = "blockhash" // sidechain block hashes
+	CommitmentKindCustom    = "custom"    // arbitrary 32 byte hashes
+)
+
 // ClientDetails field names
 const (
-	ClientDetailsClientPositionName = "client_position"
-	ClientDetailsAuthTokenName      = "auth_token"
-	ClientDetailsPubkeyName         = "pubkey"
-	ClientDetailsClientNameName     = "client_name"
+	ClientDetailsClientPositionName  = "client_position"
+	ClientDetailsAuthTokenName       = "auth_token"
+	ClientDetailsPubkeyName          = "pubkey"
+	ClientDetailsClientNameName      = "client_name"
+	ClientDetailsSignatureSchemeName = "signature_scheme"
+	ClientDetailsCommitmentKindName  = "commitment_kind"
 )
@@ -13,16 +13,17 @@ import (
 
 // Test ClientDetails high level interface
 func TestClientDetails(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock"}
+	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock", "", ""}
 	assert.Equal(t, int32(0), clientDetails.ClientPosition)
 	assert.Equal(t, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", clientDetails.AuthToken)
 	assert.Equal(t, "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", clientDetails.Pubkey)
 	assert.Equal(t, "CommerceBlock", clientDetails.ClientName)
+	assert.Equal(t, "", clientDetails.SignatureScheme)
 }
 
 // Test ClientDetails BSON interface
 func TestClientDetailsBSON(t *testing.T) {
-	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock"}
+	clientDetails := ClientDetails{0, "04ddb0d6-ed74-4cc6-b9dc-72f2a809525b", "03e52cf15e0a5cf6612314f077bb65cf9a6596b76c0fcb34b682f673a8314c7b33", "CommerceBlock", "", ""}
 
 	// test marshal clientDetails model
 	bytes, errBytes := bson.Marshal(clientDetails)
@@ -53,4 +54,14 @@ func TestClientDetailsBSON(t *testing.T) {
 	assert.Equal(t, clientDetails.Pubkey, testtestClientDetails.Pubkey)
 	assert.Equal(t, clientDetails.ClientPosition, testtestClientDetails.ClientPosition)
 	assert.Equal(t, clientDetails.ClientName, testtestClientDetails.ClientName)
+
+	// test signature scheme stored when set
+	clientDetails.SignatureScheme = SignatureSchemeSchnorr
+	doc, docErr = GetDocumentFromModel(clientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, SignatureSchemeSchnorr, doc.Lookup(ClientDetailsSignatureSchemeName).StringValue())
+	testtestClientDetails = &ClientDetails{}
+	docErr = GetModelFromDocument(doc, testtestClientDetails)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, SignatureSchemeSchnorr, testtestClientDetails.SignatureScheme)
 }
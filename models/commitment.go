@@ -16,9 +16,16 @@ const (
 	ErrorCommitmentListEmpty = "List of commitments is empty"
 )
 
+// Sentinel commitment attested in place of a zero commitment merkle root
+// so that an attested zero hash can never be confused with no attestation
+var ZeroCommitmentSentinel = chainhash.HashH([]byte("mainstay-zero-commitment"))
+
 // Commitment structure
 type Commitment struct {
 	tree CommitmentMerkleTree
+
+	// client position of each leaf - nil if leaves ordered by position
+	leafPositions []int32
 }
 
 // Return new Commitment instance
@@ -28,38 +35,87 @@ func NewCommitment(commitments []chainhash.Hash) (*Commitment, error) {
 		return nil, errors.New(ErrorCommitmentListEmpty)
 	}
 	commitmentTree := NewCommitmentMerkleTree(commitments)
-	return &Commitment{commitmentTree}, nil
+	return &Commitment{commitmentTree, nil}, nil
+}
+
+// Return new sentinel Commitment with the zero commitment sentinel
+// as the single leaf of the commitment merkle tree
+func NewZeroCommitmentSentinel() *Commitment {
+	commitment, _ := NewCommitment([]chainhash.Hash{ZeroCommitmentSentinel})
+	return commitment
+}
+
+// Return true if the Commitment has no commitment leaves or all leaves are
+// the zero hash, i.e. does not commit to any client commitment. The merkle
+// root of such a commitment is not the zero hash unless there are no leaves
+func (c Commitment) IsZero() bool {
+	for _, commitment := range c.tree.getMerkleCommitments() {
+		if (commitment != chainhash.Hash{}) {
+			return false
+		}
+	}
+	return true
 }
 
 // Get merkle proofs for Commitment
+// The client position of each proof is mapped from its leaf index
 func (c Commitment) GetMerkleProofs() []CommitmentMerkleProof {
-	return c.tree.getMerkleProofs()
+	proofs := c.tree.getMerkleProofs()
+	for i := range proofs {
+		proofs[i].ClientPosition = c.GetLeafPosition(proofs[i].LeafIndex)
+	}
+	return proofs
 }
 
 // Get merkle commitments for Commitment
+// The client position of each commitment is mapped from its leaf index
 func (c Commitment) GetMerkleCommitments() []CommitmentMerkleCommitment {
 	var commitments []CommitmentMerkleCommitment
 	for pos, commitment := range c.tree.getMerkleCommitments() {
-		commitments = append(commitments, CommitmentMerkleCommitment{c.GetCommitmentHash(), int32(pos), commitment})
+		commitments = append(commitments, CommitmentMerkleCommitment{
+			c.GetCommitmentHash(), c.GetLeafPosition(int32(pos)), commitment, int32(pos)})
 	}
 	return commitments
 }
 
+// Set client position of each commitment leaf when the leaves
+// are not ordered by client position, e.g. by submission time
+func (c *Commitment) SetLeafPositions(positions []int32) {
+	c.leafPositions = positions
+}
+
+// Get client position of the commitment leaf at the index provided
+// Returns -1 for padding leaves not belonging to any client position
+func (c Commitment) GetLeafPosition(leaf int32) int32 {
+	if c.leafPositions == nil {
+		return leaf
+	}
+	if leaf < 0 || int(leaf) >= len(c.leafPositions) {
+		return -1
+	}
+	return c.leafPositions[leaf]
+}
+
 // Get merkle root hash for Commitment
 func (c Commitment) GetCommitmentHash() chainhash.Hash {
 	return c.tree.getMerkleRoot()
 }
 
 // struct for db CommitmentMerkleCommitment
+// The leaf index is the index of the commitment in the commitment tree
+// leaves, which differs from the client position if the leaves are not
+// ordered by client position, e.g. by submission time
 type CommitmentMerkleCommitment struct {
 	MerkleRoot     chainhash.Hash
 	ClientPosition int32
 	Commitment     chainhash.Hash
+	LeafIndex      int32
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (c CommitmentMerkleCommitment) MarshalBSON() ([]byte, error) {
-	commitmentBSON := CommitmentMerkleCommitmentBSON{c.MerkleRoot.String(), c.ClientPosition, c.Commitment.String()}
+	leafIndex := c.LeafIndex
+	commitmentBSON := CommitmentMerkleCommitmentBSON{c.MerkleRoot.String(), c.ClientPosition, c.Commitment.String(), &leafIndex}
 	return bson.Marshal(commitmentBSON)
 
 }
@@ -82,6 +138,11 @@ func (c *CommitmentMerkleCommitment) UnmarshalBSON(b []byte) error {
 	c.MerkleRoot = *rootHash
 	c.ClientPosition = commitmentBSON.ClientPosition
 	c.Commitment = *commitHash
+	// commitments stored without a leaf index are ordered by client position
+	c.LeafIndex = commitmentBSON.ClientPosition
+	if commitmentBSON.LeafIndex != nil {
+		c.LeafIndex = *commitmentBSON.LeafIndex
+	}
 	return nil
 }
 
@@ -90,6 +151,7 @@ const (
 	CommitmentMerkleRootName     = "merkle_root"
 	CommitmentClientPositionName = "client_position"
 	CommitmentCommitmentName     = "commitment"
+	CommitmentLeafIndexName      = "leaf_index"
 )
 
 //CommitmentMerkleCommitmentBSON structure for mongoDB
@@ -97,4 +159,5 @@ type CommitmentMerkleCommitmentBSON struct {
 	MerkleRoot     string `bson:"merkle_root"`
 	ClientPosition int32  `bson:"client_position"`
 	Commitment     string `bson:"commitment"`
+	LeafIndex      *int32 `bson:"leaf_index,omitempty"`
 }
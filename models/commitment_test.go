@@ -10,6 +10,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 // Test Commitment high level interface
@@ -44,6 +45,38 @@ func TestCommitment(t *testing.T) {
 
 	merkleProofs := commitment.GetMerkleProofs()
 	assert.Equal(t, proofs, merkleProofs)
+
+	// test leaf positions default to leaf index
+	assert.Equal(t, int32(2), commitment.GetLeafPosition(2))
+
+	// test leaf positions set for leaves not ordered by position
+	commitment.SetLeafPositions([]int32{5, 0, -1})
+	assert.Equal(t, int32(5), commitment.GetLeafPosition(0))
+	assert.Equal(t, int32(0), commitment.GetLeafPosition(1))
+	assert.Equal(t, int32(-1), commitment.GetLeafPosition(2))
+	assert.Equal(t, int32(-1), commitment.GetLeafPosition(3))
+	assert.Equal(t, *root, commitment.GetCommitmentHash())
+
+	// test merkle commitments and proofs mapped to client positions
+	merkleCommitments = commitment.GetMerkleCommitments()
+	merkleProofs = commitment.GetMerkleProofs()
+	for i, position := range []int32{5, 0, -1} {
+		assert.Equal(t, position, merkleCommitments[i].ClientPosition)
+		assert.Equal(t, int32(i), merkleCommitments[i].LeafIndex)
+		assert.Equal(t, position, merkleProofs[i].ClientPosition)
+		assert.Equal(t, int32(i), merkleProofs[i].LeafIndex)
+		assert.Equal(t, true, ProveMerkleProof(merkleProofs[i]))
+	}
+
+	// test zero commitment with zero hash leaves only has non zero root
+	assert.Equal(t, false, commitment.IsZero())
+	assert.Equal(t, true, Commitment{}.IsZero())
+	zeroCommitment, _ := NewCommitment([]chainhash.Hash{chainhash.Hash{}, chainhash.Hash{}})
+	assert.Equal(t, true, zeroCommitment.IsZero())
+	assert.NotEqual(t, chainhash.Hash{}, zeroCommitment.GetCommitmentHash())
+	zeroCommitment, _ = NewCommitment([]chainhash.Hash{chainhash.Hash{}, *hash0})
+	assert.Equal(t, false, zeroCommitment.IsZero())
+	assert.Equal(t, false, NewZeroCommitmentSentinel().IsZero())
 }
 
 // Test Commitment BSON interface
@@ -62,7 +95,7 @@ func TestCommitmentBSON(t *testing.T) {
 	assert.Equal(t, *root, commitment0.MerkleRoot)
 
 	bytes, errBytes := commitment0.MarshalBSON()
-	assert.Equal(t, []uint8([]byte{0xbd, 0x0, 0x0, 0x0, 0x2, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x62, 0x62, 0x30, 0x38, 0x38, 0x63, 0x31, 0x30, 0x36, 0x62, 0x33, 0x33, 0x37, 0x39, 0x62, 0x36, 0x34, 0x32, 0x34, 0x33, 0x63, 0x31, 0x61, 0x34, 0x39, 0x31, 0x35, 0x66, 0x37, 0x32, 0x61, 0x38, 0x34, 0x37, 0x64, 0x34, 0x35, 0x63, 0x37, 0x35, 0x31, 0x33, 0x62, 0x31, 0x35, 0x32, 0x63, 0x61, 0x64, 0x35, 0x38, 0x33, 0x65, 0x62, 0x33, 0x63, 0x30, 0x61, 0x31, 0x30, 0x36, 0x33, 0x63, 0x32, 0x0, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x0}), bytes)
+	assert.Equal(t, []uint8([]byte{0xcd, 0x0, 0x0, 0x0, 0x2, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x62, 0x62, 0x30, 0x38, 0x38, 0x63, 0x31, 0x30, 0x36, 0x62, 0x33, 0x33, 0x37, 0x39, 0x62, 0x36, 0x34, 0x32, 0x34, 0x33, 0x63, 0x31, 0x61, 0x34, 0x39, 0x31, 0x35, 0x66, 0x37, 0x32, 0x61, 0x38, 0x34, 0x37, 0x64, 0x34, 0x35, 0x63, 0x37, 0x35, 0x31, 0x33, 0x62, 0x31, 0x35, 0x32, 0x63, 0x61, 0x64, 0x35, 0x38, 0x33, 0x65, 0x62, 0x33, 0x63, 0x30, 0x61, 0x31, 0x30, 0x36, 0x33, 0x63, 0x32, 0x0, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x10, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}), bytes)
 	assert.Equal(t, nil, errBytes)
 
 	// test unmarshal commitment model and verify reverse works
@@ -86,4 +119,25 @@ func TestCommitmentBSON(t *testing.T) {
 	assert.Equal(t, commitment0.MerkleRoot, testtestCommitment0.MerkleRoot)
 	assert.Equal(t, commitment0.ClientPosition, testtestCommitment0.ClientPosition)
 	assert.Equal(t, commitment0.Commitment, testtestCommitment0.Commitment)
+	assert.Equal(t, commitment0.LeafIndex, testtestCommitment0.LeafIndex)
+
+	// test leaf index stored separately from client position
+	commitment.SetLeafPositions([]int32{5, 0, 2})
+	commitment1 := commitment.GetMerkleCommitments()[1]
+	assert.Equal(t, int32(0), commitment1.ClientPosition)
+	assert.Equal(t, int32(1), commitment1.LeafIndex)
+	doc, docErr = GetDocumentFromModel(commitment1)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, int32(0), doc.Lookup(CommitmentClientPositionName).Int32())
+	assert.Equal(t, int32(1), doc.Lookup(CommitmentLeafIndexName).Int32())
+	testCommitment1 := &CommitmentMerkleCommitment{}
+	assert.Equal(t, nil, GetModelFromDocument(doc, testCommitment1))
+	assert.Equal(t, commitment1, *testCommitment1)
+
+	// test leaf index of commitments stored without a leaf index is the client position
+	legacyBytes, _ := bson.Marshal(bson.M{CommitmentMerkleRootName: root.String(),
+		CommitmentClientPositionName: int32(2), CommitmentCommitmentName: hash2.String()})
+	legacyCommitment := &CommitmentMerkleCommitment{}
+	assert.Equal(t, nil, legacyCommitment.UnmarshalBSON(legacyBytes))
+	assert.Equal(t, CommitmentMerkleCommitment{*root, 2, *hash2, 2}, *legacyCommitment)
 }
@@ -5,6 +5,7 @@
 package models
 
 import (
+	"encoding/json"
 	"log"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -23,6 +24,7 @@ func buildMerkleProof(position int, tree []*chainhash.Hash) CommitmentMerkleProo
 	// add base commitment in proof
 	var proof CommitmentMerkleProof
 	proof.ClientPosition = int32(position)
+	proof.LeafIndex = int32(position)
 	proof.Commitment = *tree[position]
 
 	// find all intermediarey commitment ops
@@ -65,6 +67,7 @@ func buildMerkleProof(position int, tree []*chainhash.Hash) CommitmentMerkleProo
 func ProveMerkleProof(proof CommitmentMerkleProof) bool {
 	hash := proof.Commitment
 	log.Printf("client position: %d\n", proof.ClientPosition)
+	log.Printf("leaf index: %d\n", proof.LeafIndex)
 	log.Printf("client commitment: %s\n", hash.String())
 	for i := range proof.Ops {
 		if proof.Ops[i].Append {
@@ -82,6 +85,9 @@ func ProveMerkleProof(proof CommitmentMerkleProof) bool {
 }
 
 // CommitmentMerkleProofOps structure
+// Sibling commitment hashed with the current hash at each tree height
+// Append set if the sibling is on the right, i.e. hash(current || sibling),
+// otherwise the sibling is on the left, i.e. hash(sibling || current)
 type CommitmentMerkleProofOp struct {
 	Append     bool
 	Commitment chainhash.Hash
@@ -100,16 +106,22 @@ const (
 )
 
 // CommitmentMerkleProof structure
+// The leaf index is the index of the commitment in the commitment tree
+// leaves, which differs from the client position if the leaves are not
+// ordered by client position, e.g. by submission time
 type CommitmentMerkleProof struct {
 	MerkleRoot     chainhash.Hash
 	ClientPosition int32
 	Commitment     chainhash.Hash
 	Ops            []CommitmentMerkleProofOp
+	LeafIndex      int32
 }
 
 // Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
 func (c CommitmentMerkleProof) MarshalBSON() ([]byte, error) {
-	proofBson := CommitmentMerkleProofBSON{MerkleRoot: c.MerkleRoot.String(), ClientPosition: c.ClientPosition, Commitment: c.Commitment.String()}
+	leafIndex := c.LeafIndex
+	proofBson := CommitmentMerkleProofBSON{MerkleRoot: c.MerkleRoot.String(), ClientPosition: c.ClientPosition,
+		Commitment: c.Commitment.String(), LeafIndex: &leafIndex}
 
 	var opsBson []CommitmentMerkleProofOpBSON
 	for _, op := range c.Ops {
@@ -125,7 +137,69 @@ func (c *CommitmentMerkleProof) UnmarshalBSON(b []byte) error {
 	if err := bson.Unmarshal(b, &proofBSON); err != nil {
 		return err
 	}
-	// TODO : not implemented as not required anywhere at the moment
+	proofJSON := CommitmentMerkleProofJSON{
+		MerkleRoot:     proofBSON.MerkleRoot,
+		ClientPosition: proofBSON.ClientPosition,
+		Commitment:     proofBSON.Commitment,
+		LeafIndex:      proofBSON.LeafIndex}
+	for _, op := range proofBSON.Ops {
+		proofJSON.Ops = append(proofJSON.Ops, CommitmentMerkleProofOpJSON{op.Append, op.Commitment})
+	}
+	return c.fromJSONModel(proofJSON)
+}
+
+// Implement json.Marshaler MarshalJSON() method for external verifiers
+// Hashes are hex encoded in reverse byte order as chainhash strings
+func (c CommitmentMerkleProof) MarshalJSON() ([]byte, error) {
+	leafIndex := c.LeafIndex
+	proofJSON := CommitmentMerkleProofJSON{
+		MerkleRoot:     c.MerkleRoot.String(),
+		ClientPosition: c.ClientPosition,
+		Commitment:     c.Commitment.String(),
+		Ops:            []CommitmentMerkleProofOpJSON{},
+		LeafIndex:      &leafIndex}
+	for _, op := range c.Ops {
+		proofJSON.Ops = append(proofJSON.Ops, CommitmentMerkleProofOpJSON{op.Append, op.Commitment.String()})
+	}
+	return json.Marshal(proofJSON)
+}
+
+// Implement json.Unmarshaler UnmarshalJSON() method for external verifiers
+func (c *CommitmentMerkleProof) UnmarshalJSON(b []byte) error {
+	var proofJSON CommitmentMerkleProofJSON
+	if err := json.Unmarshal(b, &proofJSON); err != nil {
+		return err
+	}
+	return c.fromJSONModel(proofJSON)
+}
+
+// Set merkle proof from hex encoded hashes of json model
+func (c *CommitmentMerkleProof) fromJSONModel(proofJSON CommitmentMerkleProofJSON) error {
+	merkleRoot, rootErr := chainhash.NewHashFromStr(proofJSON.MerkleRoot)
+	if rootErr != nil {
+		return rootErr
+	}
+	commitment, commitmentErr := chainhash.NewHashFromStr(proofJSON.Commitment)
+	if commitmentErr != nil {
+		return commitmentErr
+	}
+	var ops []CommitmentMerkleProofOp
+	for _, op := range proofJSON.Ops {
+		opCommitment, opErr := chainhash.NewHashFromStr(op.Commitment)
+		if opErr != nil {
+			return opErr
+		}
+		ops = append(ops, CommitmentMerkleProofOp{op.Append, *opCommitment})
+	}
+	c.MerkleRoot = *merkleRoot
+	c.ClientPosition = proofJSON.ClientPosition
+	c.Commitment = *commitment
+	c.Ops = ops
+	// proofs without a leaf index are of leaves ordered by client position
+	c.LeafIndex = proofJSON.ClientPosition
+	if proofJSON.LeafIndex != nil {
+		c.LeafIndex = *proofJSON.LeafIndex
+	}
 	return nil
 }
 
@@ -135,6 +209,7 @@ const (
 	ProofClientPositionName = "client_position"
 	ProofCommitmentName     = "commitment"
 	ProofOpsName            = "ops"
+	ProofLeafIndexName      = "leaf_index"
 )
 
 // CommitmentMerkleProofBSON structure for mongoDB
@@ -143,4 +218,21 @@ type CommitmentMerkleProofBSON struct {
 	ClientPosition int32                         `bson:"client_position"`
 	Commitment     string                        `bson:"commitment"`
 	Ops            []CommitmentMerkleProofOpBSON `bson:"ops"`
+	LeafIndex      *int32                        `bson:"leaf_index,omitempty"`
+}
+
+// CommitmentMerkleProofOpJSON structure for external verifiers
+type CommitmentMerkleProofOpJSON struct {
+	Append     bool   `json:"append"`
+	Commitment string `json:"commitment"`
+}
+
+// CommitmentMerkleProofJSON structure for external verifiers
+// See doc/merkleproof.md for the format and hashing order
+type CommitmentMerkleProofJSON struct {
+	MerkleRoot     string                        `json:"merkle_root"`
+	ClientPosition int32                         `json:"client_position"`
+	Commitment     string                        `json:"commitment"`
+	Ops            []CommitmentMerkleProofOpJSON `json:"ops"`
+	LeafIndex      *int32                        `json:"leaf_index,omitempty"`
 }
@@ -5,6 +5,7 @@
 package models
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -317,7 +318,7 @@ func TestMerkleProof_BSON(t *testing.T) {
 
 	// test marshal proof model
 	bytes, errBytes := proof0.MarshalBSON()
-	assert.Equal(t, []byte{0x8b, 0x1, 0x0, 0x0, 0x2, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x62, 0x62, 0x30, 0x38, 0x38, 0x63, 0x31, 0x30, 0x36, 0x62, 0x33, 0x33, 0x37, 0x39, 0x62, 0x36, 0x34, 0x32, 0x34, 0x33, 0x63, 0x31, 0x61, 0x34, 0x39, 0x31, 0x35, 0x66, 0x37, 0x32, 0x61, 0x38, 0x34, 0x37, 0x64, 0x34, 0x35, 0x63, 0x37, 0x35, 0x31, 0x33, 0x62, 0x31, 0x35, 0x32, 0x63, 0x61, 0x64, 0x35, 0x38, 0x33, 0x65, 0x62, 0x33, 0x63, 0x30, 0x61, 0x31, 0x30, 0x36, 0x33, 0x63, 0x32, 0x0, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x4, 0x6f, 0x70, 0x73, 0x0, 0xc9, 0x0, 0x0, 0x0, 0x3, 0x30, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x32, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x0, 0x3, 0x31, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x64, 0x37, 0x32, 0x63, 0x63, 0x32, 0x38, 0x38, 0x37, 0x65, 0x62, 0x34, 0x30, 0x32, 0x64, 0x34, 0x35, 0x34, 0x62, 0x31, 0x39, 0x39, 0x32, 0x64, 0x62, 0x30, 0x61, 0x64, 0x61, 0x36, 0x32, 0x30, 0x61, 0x66, 0x66, 0x64, 0x62, 0x37, 0x37, 0x61, 0x34, 0x36, 0x38, 0x34, 0x35, 0x36, 0x31, 0x37, 0x35, 0x32, 0x33, 0x65, 0x38, 0x34, 0x36, 0x62, 0x62, 0x62, 0x63, 0x39, 0x33, 0x35, 0x0, 0x0, 0x0, 0x0}, bytes)
+	assert.Equal(t, []byte{0x9b, 0x1, 0x0, 0x0, 0x2, 0x6d, 0x65, 0x72, 0x6b, 0x6c, 0x65, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x62, 0x62, 0x30, 0x38, 0x38, 0x63, 0x31, 0x30, 0x36, 0x62, 0x33, 0x33, 0x37, 0x39, 0x62, 0x36, 0x34, 0x32, 0x34, 0x33, 0x63, 0x31, 0x61, 0x34, 0x39, 0x31, 0x35, 0x66, 0x37, 0x32, 0x61, 0x38, 0x34, 0x37, 0x64, 0x34, 0x35, 0x63, 0x37, 0x35, 0x31, 0x33, 0x62, 0x31, 0x35, 0x32, 0x63, 0x61, 0x64, 0x35, 0x38, 0x33, 0x65, 0x62, 0x33, 0x63, 0x30, 0x61, 0x31, 0x30, 0x36, 0x33, 0x63, 0x32, 0x0, 0x10, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x0, 0x0, 0x0, 0x0, 0x0, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x4, 0x6f, 0x70, 0x73, 0x0, 0xc9, 0x0, 0x0, 0x0, 0x3, 0x30, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x32, 0x61, 0x33, 0x39, 0x65, 0x33, 0x34, 0x65, 0x38, 0x38, 0x31, 0x64, 0x39, 0x61, 0x31, 0x65, 0x36, 0x63, 0x64, 0x63, 0x33, 0x34, 0x31, 0x38, 0x62, 0x35, 0x34, 0x61, 0x61, 0x35, 0x37, 0x37, 0x34, 0x37, 0x31, 0x30, 0x36, 0x62, 0x63, 0x37, 0x35, 0x65, 0x39, 0x65, 0x38, 0x34, 0x34, 0x32, 0x36, 0x36, 0x36, 0x31, 0x66, 0x32, 0x37, 0x66, 0x39, 0x38, 0x61, 0x64, 0x61, 0x33, 0x62, 0x37, 0x0, 0x0, 0x3, 0x31, 0x0, 0x5f, 0x0, 0x0, 0x0, 0x8, 0x61, 0x70, 0x70, 0x65, 0x6e, 0x64, 0x0, 0x1, 0x2, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x6d, 0x65, 0x6e, 0x74, 0x0, 0x41, 0x0, 0x0, 0x0, 0x31, 0x61, 0x64, 0x37, 0x32, 0x63, 0x63, 0x32, 0x38, 0x38, 0x37, 0x65, 0x62, 0x34, 0x30, 0x32, 0x64, 0x34, 0x35, 0x34, 0x62, 0x31, 0x39, 0x39, 0x32, 0x64, 0x62, 0x30, 0x61, 0x64, 0x61, 0x36, 0x32, 0x30, 0x61, 0x66, 0x66, 0x64, 0x62, 0x37, 0x37, 0x61, 0x34, 0x36, 0x38, 0x34, 0x35, 0x36, 0x31, 0x37, 0x35, 0x32, 0x33, 0x65, 0x38, 0x34, 0x36, 0x62, 0x62, 0x62, 0x63, 0x39, 0x33, 0x35, 0x0, 0x0, 0x0, 0x10, 0x6c, 0x65, 0x61, 0x66, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}, bytes)
 	assert.Equal(t, nil, errBytes)
 
 	// test proof model to document
@@ -333,4 +334,64 @@ func TestMerkleProof_BSON(t *testing.T) {
 		assert.Equal(t, proof0.Ops[pos].Append, docOp.Lookup(ProofOpAppendName).Boolean())
 		assert.Equal(t, proof0.Ops[pos].Commitment.String(), docOp.Lookup(ProofOpCommitmentName).StringValue())
 	}
+
+	// test unmarshal proof model
+	testProof := &CommitmentMerkleProof{}
+	assert.Equal(t, nil, testProof.UnmarshalBSON(bytes))
+	assert.Equal(t, proof0, *testProof)
+	assert.Equal(t, true, ProveMerkleProof(*testProof))
+}
+
+// Test merkle proof json format round trip and verification
+func TestMerkleProof_JSON(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// build merkle tree
+	commitments := []chainhash.Hash{*hash0, *hash1, *hash2}
+	commitmentMerkleTree := CommitmentMerkleTree{}
+	commitmentMerkleTree.commitments = commitments
+	commitmentMerkleTree.updateTreeStore()
+
+	// test documented proof format of position 2
+	proof2 := commitmentMerkleTree.getMerkleProofs()[2]
+	proofBytes, marshalErr := json.Marshal(proof2)
+	assert.Equal(t, nil, marshalErr)
+	assert.Equal(t, `{"merkle_root":"bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2",`+
+		`"client_position":2,"commitment":"3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",`+
+		`"ops":[{"append":true,"commitment":"3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"},`+
+		`{"append":false,"commitment":"2b6689ee13e50cb4d79392fdd8ac71aa451823ae521964e069aad8810369ef5a"}],`+
+		`"leaf_index":2}`,
+		string(proofBytes))
+
+	// test leaf index of proofs without a leaf index is the client position
+	var legacyProof CommitmentMerkleProof
+	assert.Equal(t, nil, json.Unmarshal([]byte(`{"merkle_root":"bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2",`+
+		`"client_position":2,"commitment":"3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",`+
+		`"ops":[{"append":true,"commitment":"3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"},`+
+		`{"append":false,"commitment":"2b6689ee13e50cb4d79392fdd8ac71aa451823ae521964e069aad8810369ef5a"}]}`), &legacyProof))
+	assert.Equal(t, proof2, legacyProof)
+
+	// test round trip and verification of all proofs
+	for _, proof := range commitmentMerkleTree.getMerkleProofs() {
+		proofBytes, marshalErr := json.Marshal(proof)
+		assert.Equal(t, nil, marshalErr)
+		var testProof CommitmentMerkleProof
+		assert.Equal(t, nil, json.Unmarshal(proofBytes, &testProof))
+		assert.Equal(t, proof, testProof)
+		assert.Equal(t, true, ProveMerkleProof(testProof))
+	}
+
+	// test tampered proofs not verified
+	var testProof CommitmentMerkleProof
+	assert.Equal(t, nil, json.Unmarshal(proofBytes, &testProof))
+	testProof.Ops[1].Append = true
+	assert.Equal(t, false, ProveMerkleProof(testProof))
+	testProof.Ops[1].Append = false
+	testProof.Commitment = *hash1
+	assert.Equal(t, false, ProveMerkleProof(testProof))
+
+	// test invalid json hashes
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"merkle_root":"zz","client_position":0,"commitment":"","ops":[]}`), &testProof))
 }
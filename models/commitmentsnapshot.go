@@ -0,0 +1,99 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CommitmentSnapshot structure
+// Immutable snapshot of the client commitments that produced
+// an attestation merkle root, along with the time each client
+// commitment was last updated, so that the root can be reproduced
+// The leaf ordering of the commitment tree is recorded, with client
+// commitments listed in leaf order, so that proofs remain verifiable
+type CommitmentSnapshot struct {
+	MerkleRoot   chainhash.Hash
+	Commitments  []ClientCommitmentSnapshot
+	CreatedAt    time.Time
+	LeafOrdering string
+}
+
+// ClientCommitmentSnapshot structure
+// Client commitment at a position at the time of the snapshot
+type ClientCommitmentSnapshot struct {
+	Commitment     chainhash.Hash
+	ClientPosition int32
+	UpdatedAt      time.Time
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (c CommitmentSnapshot) MarshalBSON() ([]byte, error) {
+	snapshotBSON := CommitmentSnapshotBSON{
+		MerkleRoot:   c.MerkleRoot.String(),
+		CreatedAt:    c.CreatedAt,
+		LeafOrdering: c.LeafOrdering}
+	for _, commitment := range c.Commitments {
+		snapshotBSON.Commitments = append(snapshotBSON.Commitments, ClientCommitmentSnapshotBSON{
+			Commitment:     commitment.Commitment.String(),
+			ClientPosition: commitment.ClientPosition,
+			UpdatedAt:      commitment.UpdatedAt})
+	}
+	return bson.Marshal(snapshotBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (c *CommitmentSnapshot) UnmarshalBSON(b []byte) error {
+	var snapshotBSON CommitmentSnapshotBSON
+	if err := bson.Unmarshal(b, &snapshotBSON); err != nil {
+		return err
+	}
+	merkleRoot, rootErr := chainhash.NewHashFromStr(snapshotBSON.MerkleRoot)
+	if rootErr != nil {
+		return rootErr
+	}
+	var commitments []ClientCommitmentSnapshot
+	for _, commitmentBSON := range snapshotBSON.Commitments {
+		commitment, commitmentErr := chainhash.NewHashFromStr(commitmentBSON.Commitment)
+		if commitmentErr != nil {
+			return commitmentErr
+		}
+		commitments = append(commitments, ClientCommitmentSnapshot{
+			Commitment:     *commitment,
+			ClientPosition: commitmentBSON.ClientPosition,
+			UpdatedAt:      commitmentBSON.UpdatedAt})
+	}
+	c.MerkleRoot = *merkleRoot
+	c.Commitments = commitments
+	c.CreatedAt = snapshotBSON.CreatedAt
+	c.LeafOrdering = snapshotBSON.LeafOrdering
+	return nil
+}
+
+// CommitmentSnapshot field names
+const (
+	CommitmentSnapshotMerkleRootName   = "merkle_root"
+	CommitmentSnapshotCommitmentsName  = "commitments"
+	CommitmentSnapshotCreatedAtName    = "created_at"
+	CommitmentSnapshotLeafOrderingName = "leaf_ordering"
+)
+
+// CommitmentSnapshotBSON structure for mongoDB
+type CommitmentSnapshotBSON struct {
+	MerkleRoot   string                         `bson:"merkle_root"`
+	Commitments  []ClientCommitmentSnapshotBSON `bson:"commitments"`
+	CreatedAt    time.Time                      `bson:"created_at"`
+	LeafOrdering string                         `bson:"leaf_ordering"`
+}
+
+// ClientCommitmentSnapshotBSON structure for mongoDB
+type ClientCommitmentSnapshotBSON struct {
+	Commitment     string    `bson:"commitment"`
+	ClientPosition int32     `bson:"client_position"`
+	UpdatedAt      time.Time `bson:"updated_at"`
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test CommitmentSnapshot BSON interface
+func TestCommitmentSnapshotBSON(t *testing.T) {
+	hash0, _ := chainhash.NewHashFromStr("1a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("2a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	root, _ := chainhash.NewHashFromStr("3a39e34e881d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	snapshot := CommitmentSnapshot{
+		MerkleRoot: *root,
+		Commitments: []ClientCommitmentSnapshot{
+			ClientCommitmentSnapshot{*hash0, int32(0), time.Unix(1542121293, 0)},
+			ClientCommitmentSnapshot{*hash1, int32(2), time.Unix(1542121393, 0)}},
+		CreatedAt:    time.Unix(1542121493, 0),
+		LeafOrdering: "submission"}
+
+	// test marshal and unmarshal snapshot model
+	bytes, errBytes := snapshot.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testSnapshot := &CommitmentSnapshot{}
+	assert.Equal(t, nil, testSnapshot.UnmarshalBSON(bytes))
+	assert.Equal(t, snapshot.MerkleRoot, testSnapshot.MerkleRoot)
+	assert.Equal(t, snapshot.CreatedAt.Unix(), testSnapshot.CreatedAt.Unix())
+	assert.Equal(t, snapshot.LeafOrdering, testSnapshot.LeafOrdering)
+	assert.Equal(t, 2, len(testSnapshot.Commitments))
+	for i := range snapshot.Commitments {
+		assert.Equal(t, snapshot.Commitments[i].Commitment, testSnapshot.Commitments[i].Commitment)
+		assert.Equal(t, snapshot.Commitments[i].ClientPosition, testSnapshot.Commitments[i].ClientPosition)
+		assert.Equal(t, snapshot.Commitments[i].UpdatedAt.Unix(), testSnapshot.Commitments[i].UpdatedAt.Unix())
+	}
+
+	// test snapshot model to document
+	doc, docErr := GetDocumentFromModel(snapshot)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, root.String(), doc.Lookup(CommitmentSnapshotMerkleRootName).StringValue())
+	assert.Equal(t, 2, len(doc.Lookup(CommitmentSnapshotCommitmentsName).Array()))
+	assert.Equal(t, "submission", doc.Lookup(CommitmentSnapshotLeafOrderingName).StringValue())
+
+	// test reverse document to snapshot model
+	testtestSnapshot := &CommitmentSnapshot{}
+	docErr = GetModelFromDocument(doc, testtestSnapshot)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, snapshot.MerkleRoot, testtestSnapshot.MerkleRoot)
+	assert.Equal(t, 2, len(testtestSnapshot.Commitments))
+}
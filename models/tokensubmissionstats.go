@@ -0,0 +1,74 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TokenSubmissionStats structure
+// Number of signed commitment submissions authenticated with a client auth
+// token and the times of the first and latest submission. Used by operators
+// to spot clients submitting abusively often or repeatedly due to bugs
+type TokenSubmissionStats struct {
+	Token           string
+	ClientPosition  int32
+	Count           int64
+	FirstSubmission time.Time
+	LastSubmission  time.Time
+}
+
+// Get average interval between submissions - zero if less than two submissions
+func (s TokenSubmissionStats) AverageInterval() time.Duration {
+	if s.Count < 2 {
+		return 0
+	}
+	return s.LastSubmission.Sub(s.FirstSubmission) / time.Duration(s.Count-1)
+}
+
+// Implement bson.Marshaler MarshalBSON() method for use with db_mongo interface
+func (s TokenSubmissionStats) MarshalBSON() ([]byte, error) {
+	statsBSON := TokenSubmissionStatsBSON{
+		Token:           s.Token,
+		ClientPosition:  s.ClientPosition,
+		Count:           s.Count,
+		FirstSubmission: s.FirstSubmission,
+		LastSubmission:  s.LastSubmission}
+	return bson.Marshal(statsBSON)
+}
+
+// Implement bson.Unmarshaler UnmarshalJSON() method for use with db_mongo interface
+func (s *TokenSubmissionStats) UnmarshalBSON(b []byte) error {
+	var statsBSON TokenSubmissionStatsBSON
+	if err := bson.Unmarshal(b, &statsBSON); err != nil {
+		return err
+	}
+	s.Token = statsBSON.Token
+	s.ClientPosition = statsBSON.ClientPosition
+	s.Count = statsBSON.Count
+	s.FirstSubmission = statsBSON.FirstSubmission
+	s.LastSubmission = statsBSON.LastSubmission
+	return nil
+}
+
+// TokenSubmissionStats field names
+const (
+	TokenSubmissionStatsTokenName           = "token"
+	TokenSubmissionStatsClientPositionName  = "client_position"
+	TokenSubmissionStatsCountName           = "count"
+	TokenSubmissionStatsFirstSubmissionName = "first_submission"
+	TokenSubmissionStatsLastSubmissionName  = "last_submission"
+)
+
+// TokenSubmissionStatsBSON structure for mongoDB
+type TokenSubmissionStatsBSON struct {
+	Token           string    `bson:"token"`
+	ClientPosition  int32     `bson:"client_position"`
+	Count           int64     `bson:"count"`
+	FirstSubmission time.Time `bson:"first_submission"`
+	LastSubmission  time.Time `bson:"last_submission"`
+}
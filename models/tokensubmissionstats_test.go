@@ -0,0 +1,52 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test TokenSubmissionStats average interval
+func TestTokenSubmissionStats(t *testing.T) {
+	now := time.Unix(1542121293, 0)
+	stats := TokenSubmissionStats{"token", 1, 1, now, now}
+	assert.Equal(t, time.Duration(0), stats.AverageInterval())
+
+	stats.Count = 5
+	stats.LastSubmission = now.Add(time.Hour)
+	assert.Equal(t, 15*time.Minute, stats.AverageInterval())
+}
+
+// Test TokenSubmissionStats BSON interface
+func TestTokenSubmissionStatsBSON(t *testing.T) {
+	now := time.Unix(1542121293, 0)
+	stats := TokenSubmissionStats{"token", 1, 3, now, now.Add(time.Minute)}
+
+	// test marshal and unmarshal stats model
+	bytes, errBytes := stats.MarshalBSON()
+	assert.Equal(t, nil, errBytes)
+	testStats := &TokenSubmissionStats{}
+	assert.Equal(t, nil, testStats.UnmarshalBSON(bytes))
+	assert.Equal(t, stats.Token, testStats.Token)
+	assert.Equal(t, stats.ClientPosition, testStats.ClientPosition)
+	assert.Equal(t, stats.Count, testStats.Count)
+	assert.Equal(t, stats.FirstSubmission.Unix(), testStats.FirstSubmission.Unix())
+	assert.Equal(t, stats.LastSubmission.Unix(), testStats.LastSubmission.Unix())
+
+	// test stats model to document
+	doc, docErr := GetDocumentFromModel(stats)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, stats.Token, doc.Lookup(TokenSubmissionStatsTokenName).StringValue())
+	assert.Equal(t, stats.Count, doc.Lookup(TokenSubmissionStatsCountName).Int64())
+
+	// test reverse document to stats model
+	testtestStats := &TokenSubmissionStats{}
+	docErr = GetModelFromDocument(doc, testtestStats)
+	assert.Equal(t, nil, docErr)
+	assert.Equal(t, stats.LastSubmission.Unix(), testtestStats.LastSubmission.Unix())
+}
@@ -27,6 +27,7 @@ db.createCollection("ClientCommitment")
 db.createCollection("ClientDetails")
 db.createCollection("MerkleCommitment")
 db.createCollection("MerkleProof")
+db.createCollection("CommitmentSnapshot")
 print(db.getCollectionNames())
 
 // Create roles
@@ -47,6 +48,7 @@ db.createRole(
         { resource: { db: db_name, collection: "MerkleProof" }, actions: [ "find"] },
         { resource: { db: db_name, collection: "ClientCommitment" }, actions: [ "find", "update", "insert"] },
         { resource: { db: db_name, collection: "ClientDetails" }, actions: [ "find", "update", "insert"] },
+        { resource: { db: db_name, collection: "CommitmentSnapshot" }, actions: [ "find"] },
     ],
     roles: []
 }
@@ -65,6 +67,7 @@ db.createRole(
         { resource: { db: db_name, collection: "MerkleProof" }, actions: ["find", "update", "insert"] },
         { resource: { db: db_name, collection: "ClientCommitment" }, actions: ["find"] },
         { resource: { db: db_name, collection: "ClientDetails" }, actions: ["find", "update", "insert"] },
+        { resource: { db: db_name, collection: "CommitmentSnapshot" }, actions: ["find", "update", "insert"] },
     ],
     roles: []
 }
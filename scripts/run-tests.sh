@@ -5,3 +5,6 @@ alias dir="$GOPATH/src/mainstay"
 # run tests sequentially
 cd $GOPATH/src/mainstay
 go test -v=0 -p=1 ./...
+
+# run tests for concurrent access with the race detector
+go test -v=0 -race -run TestAttestFeesConcurrentAccess ./attestation
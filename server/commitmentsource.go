@@ -0,0 +1,232 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"mainstay/crypto/schnorr"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// error consts
+const (
+	ErrorSignedCommitmentFormat    = "invalid signed client commitment format"
+	ErrorSignedCommitmentEnvelope  = "invalid signed client commitment envelope"
+	ErrorSignedCommitmentEncoding  = "signed client commitment field is not valid base64"
+	ErrorSignedCommitmentPayload   = "invalid signed client commitment payload"
+	ErrorSignedCommitmentMissing   = "signed client commitment payload missing required field"
+	ErrorSignedCommitmentHex       = "client commitment must be hex encoded"
+	ErrorSignedCommitmentClient    = "client position not found in client details"
+	ErrorSignedCommitmentToken     = "invalid client auth token"
+	ErrorSignedCommitmentPubkey    = "invalid client pubkey"
+	ErrorSignedCommitmentSignature = "invalid client commitment signature"
+	ErrorSignedCommitmentScheme    = "unsupported client signature scheme"
+	ErrorSignedCommitmentLength    = "client commitment must be a 32 byte hash"
+	ErrorSignedCommitmentKind      = "unsupported client commitment kind"
+	ErrorSignedCommitmentInvalid   = "invalid client commitment for commitment kind"
+)
+
+// CommitmentSource interface
+//
+// Provides the interface for ingesting signed client commitments
+// Commitments can be received by various sources, i.e. the HTTP API
+// or a message queue subscriber - currently supporting zmq and nats
+// This interface allows building mock struct for testing
+type CommitmentSource interface {
+	// read next signed commitment message - false if none received
+	ReadCommitment() ([]byte, bool)
+	Close()
+}
+
+// SignedClientCommitment struct
+// Signed client commitment message format, same as the HTTP API body
+// Payload and signature are base64 encoded
+type SignedClientCommitment struct {
+	Payload   string `json:"X-MAINSTAY-PAYLOAD"`
+	Signature string `json:"X-MAINSTAY-SIGNATURE"`
+}
+
+// SignedClientCommitmentPayload struct
+// Payload of signed client commitment with the commitment in hex
+type SignedClientCommitmentPayload struct {
+	Commitment string `json:"commitment"`
+	Position   int32  `json:"position"`
+	Token      string `json:"token"`
+}
+
+// Verify a signed client commitment message and save the client commitment
+// The auth token and the signature of the commitment are verified against
+// the client details of the client position stored in the db
+// Messages exceeding the max payload size are rejected before decoding
+// Returns true if the commitment was already recorded for the client position
+func (s *Server) SaveSignedClientCommitment(msg []byte) (bool, error) {
+	if len(msg) > s.maxPayloadSize {
+		return false, errors.New(fmt.Sprintf("%s (%d > %d)", ErrorSignedCommitmentSize, len(msg), s.maxPayloadSize))
+	}
+	payload, sigBytes, parseErr := parseSignedClientCommitment(msg)
+	if parseErr != nil {
+		return false, parseErr
+	}
+	commitmentBytes, commitmentErr := hex.DecodeString(payload.Commitment)
+	if commitmentErr != nil {
+		return false, errors.New(fmt.Sprintf("%s (%s)", ErrorSignedCommitmentHex, payload.Commitment))
+	}
+	if len(commitmentBytes) != chainhash.HashSize {
+		return false, errors.New(fmt.Sprintf("%s (%d bytes)", ErrorSignedCommitmentLength, len(commitmentBytes)))
+	}
+
+	// reject out of range client positions before any lookups
+	if positionErr := s.checkClientPosition(payload.Position); positionErr != nil {
+		return false, positionErr
+	}
+
+	// get client details of client position
+	clientDetails, detailsErr := s.dbInterface.getClientDetails()
+	if detailsErr != nil {
+		return false, detailsErr
+	}
+	var details *models.ClientDetails
+	for i := range clientDetails {
+		if clientDetails[i].ClientPosition == payload.Position {
+			details = &clientDetails[i]
+			break
+		}
+	}
+	if details == nil {
+		return false, errors.New(fmt.Sprintf("%s (%d)", ErrorSignedCommitmentClient, payload.Position))
+	}
+	if details.AuthToken != payload.Token {
+		return false, errors.New(fmt.Sprintf("%s (%d)", ErrorSignedCommitmentToken, payload.Position))
+	}
+	s.recordTokenSubmission(payload.Token, payload.Position)
+
+	// verify commitment signature with client pubkey over the
+	// commitment message, prefixed if a message prefix is set
+	signedMsg := models.CommitmentSignatureMessage(commitmentBytes, s.messagePrefix)
+	if verifyErr := verifyClientSignature(*details, signedMsg, sigBytes); verifyErr != nil {
+		return false, verifyErr
+	}
+
+	commitmentHash, hashErr := chainhash.NewHashFromStr(payload.Commitment)
+	if hashErr != nil {
+		return false, errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentFormat, hashErr))
+	}
+	commitment := models.ClientCommitment{
+		Commitment:     *commitmentHash,
+		ClientPosition: payload.Position}
+	if validateErr := s.validateCommitmentKind(details.CommitmentKind, commitment); validateErr != nil {
+		return false, validateErr
+	}
+	return s.saveClientCommitment(commitment, payload.Token)
+}
+
+// Parse signed client commitment message, i.e. the JSON envelope with the
+// base64 encoded payload and signature, and the JSON payload it contains
+// Returns the payload and the decoded signature, rejecting messages with a
+// malformed envelope, fields that are not valid base64 or a payload that
+// is malformed or missing any of the commitment, position and token fields
+func parseSignedClientCommitment(msg []byte) (SignedClientCommitmentPayload, []byte, error) {
+	var signed SignedClientCommitment
+	if unmarshalErr := json.Unmarshal(msg, &signed); unmarshalErr != nil {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentEnvelope, unmarshalErr))
+	}
+	if signed.Payload == "" || signed.Signature == "" {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s (missing payload or signature)", ErrorSignedCommitmentEnvelope))
+	}
+	payloadBytes, payloadErr := b64.StdEncoding.DecodeString(signed.Payload)
+	if payloadErr != nil {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s (payload) %v", ErrorSignedCommitmentEncoding, payloadErr))
+	}
+	sigBytes, sigErr := b64.StdEncoding.DecodeString(signed.Signature)
+	if sigErr != nil {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s (signature) %v", ErrorSignedCommitmentEncoding, sigErr))
+	}
+
+	// check required payload fields are set, as position 0 cannot
+	// be told apart from a missing position after unmarshalling
+	var fields map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal(payloadBytes, &fields); unmarshalErr != nil {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentPayload, unmarshalErr))
+	}
+	for _, field := range []string{"commitment", "position", "token"} {
+		if value, ok := fields[field]; !ok || string(value) == "null" {
+			return SignedClientCommitmentPayload{}, nil,
+				errors.New(fmt.Sprintf("%s (%s)", ErrorSignedCommitmentMissing, field))
+		}
+	}
+	var payload SignedClientCommitmentPayload
+	if unmarshalErr := json.Unmarshal(payloadBytes, &payload); unmarshalErr != nil {
+		return SignedClientCommitmentPayload{}, nil,
+			errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentPayload, unmarshalErr))
+	}
+	return payload, sigBytes, nil
+}
+
+// Verify client commitment signature with the client pubkey
+// dispatching on the signature scheme registered for the client
+func verifyClientSignature(details models.ClientDetails, msg []byte, sigBytes []byte) error {
+	pubkeyBytes, pubkeyErr := hex.DecodeString(details.Pubkey)
+	if pubkeyErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentPubkey, pubkeyErr))
+	}
+	switch details.SignatureScheme {
+	case "", models.SignatureSchemeECDSA:
+		pubkey, parsePubkeyErr := btcec.ParsePubKey(pubkeyBytes, btcec.S256())
+		if parsePubkeyErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentPubkey, parsePubkeyErr))
+		}
+		sig, parseSigErr := btcec.ParseDERSignature(sigBytes, btcec.S256())
+		if parseSigErr != nil || !sig.Verify(msg, pubkey) {
+			return errors.New(fmt.Sprintf("%s (%d)", ErrorSignedCommitmentSignature, details.ClientPosition))
+		}
+	case models.SignatureSchemeSchnorr:
+		if _, parsePubkeyErr := schnorr.ParsePubKey(pubkeyBytes); parsePubkeyErr != nil {
+			return errors.New(fmt.Sprintf("%s %v", ErrorSignedCommitmentPubkey, parsePubkeyErr))
+		}
+		if !schnorr.Verify(pubkeyBytes, msg, sigBytes) {
+			return errors.New(fmt.Sprintf("%s (%d)", ErrorSignedCommitmentSignature, details.ClientPosition))
+		}
+	default:
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorSignedCommitmentScheme, details.SignatureScheme))
+	}
+	return nil
+}
+
+// Listen for signed client commitments from a commitment source
+// until the context is cancelled. Invalid commitments are logged and dropped
+func (s *Server) ListenCommitments(ctx context.Context, wg *sync.WaitGroup, source CommitmentSource) {
+	defer wg.Done()
+	defer source.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			msg, ok := source.ReadCommitment()
+			if !ok {
+				continue
+			}
+			if _, saveErr := s.SaveSignedClientCommitment(msg); saveErr != nil {
+				log.Printf("*Server* Rejected client commitment: %v\n", saveErr)
+			}
+		}
+	}
+}
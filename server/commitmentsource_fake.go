@@ -0,0 +1,46 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"time"
+)
+
+// time waiting for a published message before returning
+const fakeReadTimeout = 10 * time.Millisecond
+
+// CommitmentSourceFake struct
+//
+// Implements CommitmentSource interface and provides
+// mock functionality for receiving signed commitments
+type CommitmentSourceFake struct {
+	msgs   chan []byte
+	closed bool
+}
+
+// Return new CommitmentSourceFake instance
+func NewCommitmentSourceFake() *CommitmentSourceFake {
+	return &CommitmentSourceFake{msgs: make(chan []byte, 10)}
+}
+
+// Publish signed commitment message for testing
+func (f *CommitmentSourceFake) Publish(msg []byte) {
+	f.msgs <- msg
+}
+
+// Return next published message if any
+func (f *CommitmentSourceFake) ReadCommitment() ([]byte, bool) {
+	select {
+	case msg := <-f.msgs:
+		return msg, true
+	case <-time.After(fakeReadTimeout):
+		return nil, false
+	}
+}
+
+// Mark source as closed
+func (f *CommitmentSourceFake) Close() {
+	f.closed = true
+}
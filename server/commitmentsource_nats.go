@@ -0,0 +1,63 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"log"
+
+	"mainstay/config"
+
+	"github.com/nats-io/nats.go"
+)
+
+// CommitmentSourceNats struct
+//
+// Implements CommitmentSource interface and subscribes
+// via nats to the subject that clients publish their
+// latest signed commitments to
+type CommitmentSourceNats struct {
+	// nats connection and subscription to receive signed commitments
+	conn         *nats.Conn
+	subscription *nats.Subscription
+}
+
+// Return new CommitmentSourceNats instance
+// The address is the nats server url and the topic the subject subscribed to
+// Connection failures are retried in the background by the nats client
+func NewCommitmentSourceNats(sourceConfig config.CommitmentSourceConfig) *CommitmentSourceNats {
+	subject := DefaultCommitmentSourceTopic
+	if sourceConfig.Topic != "" {
+		subject = sourceConfig.Topic
+	}
+	conn, connErr := nats.Connect(sourceConfig.Address,
+		nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if connErr != nil {
+		log.Fatal(connErr)
+	}
+	subscription, subErr := conn.SubscribeSync(subject)
+	if subErr != nil {
+		log.Fatal(subErr)
+	}
+	return &CommitmentSourceNats{conn, subscription}
+}
+
+// Wait for the next signed commitment message on the subscription
+// Returns false if no message is received within the poll timeout
+func (n *CommitmentSourceNats) ReadCommitment() ([]byte, bool) {
+	msg, msgErr := n.subscription.NextMsg(CommitmentSourcePollTimeout)
+	if msgErr != nil {
+		if msgErr != nats.ErrTimeout {
+			log.Println(msgErr)
+		}
+		return nil, false
+	}
+	return msg.Data, true
+}
+
+// Close subscription and nats connection
+func (n *CommitmentSourceNats) Close() {
+	n.subscription.Unsubscribe()
+	n.conn.Close()
+}
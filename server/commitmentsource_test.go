@@ -0,0 +1,439 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mainstay/clock"
+	"mainstay/config"
+	"mainstay/crypto/schnorr"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Create signed client commitment message for testing
+func signedCommitmentMsg(privKey *btcec.PrivateKey, commitment string, position int32, token string) []byte {
+	commitmentBytes, _ := hex.DecodeString(commitment)
+	sig, _ := privKey.Sign(commitmentBytes)
+	return commitmentMsg(sig.Serialize(), commitment, position, token)
+}
+
+// Create schnorr signed client commitment message for testing
+func schnorrSignedCommitmentMsg(privKey *btcec.PrivateKey, commitment string, position int32, token string) []byte {
+	commitmentBytes, _ := hex.DecodeString(commitment)
+	sig, _ := schnorr.Sign(privKey, commitmentBytes)
+	return commitmentMsg(sig, commitment, position, token)
+}
+
+// Create client commitment message with signature for testing
+func commitmentMsg(sig []byte, commitment string, position int32, token string) []byte {
+	payload := fmt.Sprintf("{\"commitment\": \"%s\", \"position\": %d, \"token\": \"%s\"}",
+		commitment, position, token)
+	return []byte(fmt.Sprintf("{\"X-MAINSTAY-PAYLOAD\": \"%s\", \"X-MAINSTAY-SIGNATURE\": \"%s\"}",
+		b64.StdEncoding.EncodeToString([]byte(payload)), b64.StdEncoding.EncodeToString(sig)))
+}
+
+// Test Server signed client commitment verification and save
+func TestServerSaveSignedClientCommitment(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	otherKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 1,
+			AuthToken:      "token",
+			Pubkey:         hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+			ClientName:     "client"}})
+
+	commitment := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	hash, _ := chainhash.NewHashFromStr(commitment)
+
+	// test invalid message format
+	_, saveErr := server.SaveSignedClientCommitment([]byte("invalid"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentEnvelope))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, "zz", 1, "token"))
+	assert.Equal(t, ErrorSignedCommitmentHex+" (zz)", saveErr.Error())
+
+	// test non 32 byte commitment rejected
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, "aaaa", 1, "token"))
+	assert.Equal(t, ErrorSignedCommitmentLength+" (2 bytes)", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment+"aa", 1, "token"))
+	assert.Equal(t, ErrorSignedCommitmentLength+" (33 bytes)", saveErr.Error())
+
+	// test unknown client position
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 2, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentClient))
+
+	// test invalid auth token
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "other"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentToken))
+
+	// test invalid signature
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(otherKey, commitment, 1, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSignature))
+
+	// test oversized message rejected
+	oversizedMsg := signedCommitmentMsg(privKey, commitment, 1, "token")
+	oversizedMsg = append(oversizedMsg[:len(oversizedMsg)-1],
+		[]byte(fmt.Sprintf(", \"padding\": \"%s\"}", strings.Repeat("a", DefaultMaxPayloadSize)))...)
+	_, saveErr = server.SaveSignedClientCommitment(oversizedMsg)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSize))
+
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{}, latestCommitments)
+
+	// test valid signed commitment saved
+	recorded, saveErr := server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, false, recorded)
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{*hash, 1}}, latestCommitments)
+
+	// test resubmitted signed commitment reported as already recorded
+	recorded, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, true, recorded)
+
+	// test configured max payload size
+	msg := signedCommitmentMsg(privKey, commitment, 1, "token")
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, len(msg) - 1, -1, -1, -1, "", "", "", "", -1, -1})
+	_, saveErr = server.SaveSignedClientCommitment(msg)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSize))
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, len(msg), -1, -1, -1, "", "", "", "", -1, -1})
+	_, saveErr = server.SaveSignedClientCommitment(msg)
+	assert.Equal(t, nil, saveErr)
+
+	// test raw commitment signature rejected with message prefix set
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", models.DefaultCommitmentMessagePrefix, "", -1, -1})
+	_, saveErr = server.SaveSignedClientCommitment(msg)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSignature))
+
+	// test prefixed commitment signature verified with message prefix set
+	commitmentBytes, _ := hex.DecodeString(commitment)
+	prefixedMsg := models.CommitmentSignatureMessage(commitmentBytes, models.DefaultCommitmentMessagePrefix)
+	assert.Equal(t, chainhash.HashSize, len(prefixedMsg))
+	assert.NotEqual(t, commitmentBytes, prefixedMsg)
+	sig, _ := privKey.Sign(prefixedMsg)
+	_, saveErr = server.SaveSignedClientCommitment(commitmentMsg(sig.Serialize(), commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, commitmentBytes, models.CommitmentSignatureMessage(commitmentBytes, ""))
+}
+
+// Test Server rejecting malformed signed client commitment messages
+// with a distinct error for each malformed part of the message
+func TestServerSaveSignedClientCommitment_Malformed(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 0,
+			AuthToken:      "token",
+			Pubkey:         hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+			ClientName:     "client"}})
+
+	commitment := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	commitmentBytes, _ := hex.DecodeString(commitment)
+	sig, _ := privKey.Sign(commitmentBytes)
+	sigB64 := b64.StdEncoding.EncodeToString(sig.Serialize())
+	envelope := func(payload string) []byte {
+		return []byte(fmt.Sprintf("{\"X-MAINSTAY-PAYLOAD\": \"%s\", \"X-MAINSTAY-SIGNATURE\": \"%s\"}",
+			b64.StdEncoding.EncodeToString([]byte(payload)), sigB64))
+	}
+
+	// test malformed envelope
+	for _, msg := range []string{
+		"",
+		"{\"X-MAINSTAY-PAYLOAD\": ",
+		"[\"payload\", \"signature\"]",
+		"{\"X-MAINSTAY-PAYLOAD\": 1, \"X-MAINSTAY-SIGNATURE\": \"" + sigB64 + "\"}",
+	} {
+		_, saveErr := server.SaveSignedClientCommitment([]byte(msg))
+		assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentEnvelope), msg)
+	}
+	_, saveErr := server.SaveSignedClientCommitment([]byte("{\"X-MAINSTAY-SIGNATURE\": \"" + sigB64 + "\"}"))
+	assert.Equal(t, ErrorSignedCommitmentEnvelope+" (missing payload or signature)", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment([]byte("{\"X-MAINSTAY-PAYLOAD\": \"e30=\"}"))
+	assert.Equal(t, ErrorSignedCommitmentEnvelope+" (missing payload or signature)", saveErr.Error())
+
+	// test payload and signature not valid base64
+	_, saveErr = server.SaveSignedClientCommitment([]byte(
+		"{\"X-MAINSTAY-PAYLOAD\": \"not base64!\", \"X-MAINSTAY-SIGNATURE\": \"" + sigB64 + "\"}"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentEncoding+" (payload)"))
+	_, saveErr = server.SaveSignedClientCommitment([]byte(
+		"{\"X-MAINSTAY-PAYLOAD\": \"e30=\", \"X-MAINSTAY-SIGNATURE\": \"not base64!\"}"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentEncoding+" (signature)"))
+
+	// test decoded payload not valid json
+	_, saveErr = server.SaveSignedClientCommitment(envelope("{\"commitment\": "))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentPayload))
+	_, saveErr = server.SaveSignedClientCommitment(envelope("[1, 2]"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentPayload))
+	_, saveErr = server.SaveSignedClientCommitment(envelope(
+		fmt.Sprintf("{\"commitment\": \"%s\", \"position\": \"0\", \"token\": \"token\"}", commitment)))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentPayload))
+
+	// test decoded payload missing required fields
+	_, saveErr = server.SaveSignedClientCommitment(envelope("{\"position\": 0, \"token\": \"token\"}"))
+	assert.Equal(t, ErrorSignedCommitmentMissing+" (commitment)", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment(envelope(
+		fmt.Sprintf("{\"commitment\": \"%s\", \"token\": \"token\"}", commitment)))
+	assert.Equal(t, ErrorSignedCommitmentMissing+" (position)", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment(envelope(
+		fmt.Sprintf("{\"commitment\": \"%s\", \"position\": 0, \"token\": null}", commitment)))
+	assert.Equal(t, ErrorSignedCommitmentMissing+" (token)", saveErr.Error())
+
+	// test commitment not 32 byte hex
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, "zz"+commitment[2:], 0, "token"))
+	assert.Equal(t, ErrorSignedCommitmentHex+" (zz"+commitment[2:]+")", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment[1:], 0, "token"))
+	assert.Equal(t, ErrorSignedCommitmentHex+" ("+commitment[1:]+")", saveErr.Error())
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment[:62], 0, "token"))
+	assert.Equal(t, ErrorSignedCommitmentLength+" (31 bytes)", saveErr.Error())
+
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{}, latestCommitments)
+
+	// test well formed payload of position 0 saved
+	_, saveErr = server.SaveSignedClientCommitment(envelope(
+		fmt.Sprintf("{\"commitment\": \"%s\", \"position\": 0, \"token\": \"token\"}", commitment)))
+	assert.Equal(t, nil, saveErr)
+	hash, _ := chainhash.NewHashFromStr(commitment)
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{*hash, 0}}, latestCommitments)
+}
+
+// Test Server per token submission stats of signed client commitments
+func TestServerTokenSubmissionStats(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+	start := time.Unix(1542121293, 0)
+	fakeClock := clock.NewClockFake(start)
+	server.SetClock(fakeClock)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	otherKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 1,
+			AuthToken:      "token1",
+			Pubkey:         hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+			ClientName:     "client1"},
+		models.ClientDetails{
+			ClientPosition: 2,
+			AuthToken:      "token2",
+			Pubkey:         hex.EncodeToString(otherKey.PubKey().SerializeCompressed()),
+			ClientName:     "client2"}})
+	commitment := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+
+	// test no stats before any submission
+	stats, statsErr := server.GetTokenSubmissionStats()
+	assert.Equal(t, nil, statsErr)
+	assert.Equal(t, 0, len(stats))
+
+	// test submissions with invalid token not counted
+	_, saveErr := server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token2"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentToken))
+	stats, _ = server.GetTokenSubmissionStats()
+	assert.Equal(t, 0, len(stats))
+
+	// test authenticated submissions counted, including rejected ones
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token1"))
+	assert.Equal(t, nil, saveErr)
+	fakeClock.Advance(time.Minute)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(otherKey, commitment, 1, "token1"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSignature))
+	fakeClock.Advance(time.Minute)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token1"))
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(otherKey, commitment, 2, "token2"))
+	assert.Equal(t, nil, saveErr)
+
+	stats, statsErr = server.GetTokenSubmissionStats()
+	assert.Equal(t, nil, statsErr)
+	assert.Equal(t, []models.TokenSubmissionStats{
+		models.TokenSubmissionStats{"token1", 1, 3, start, start.Add(2 * time.Minute)},
+		models.TokenSubmissionStats{"token2", 2, 1, start.Add(2 * time.Minute), start.Add(2 * time.Minute)}}, stats)
+	assert.Equal(t, time.Minute, stats[0].AverageInterval())
+
+	// test stats update failures only logged
+	dbFake.SetUnavailable(true)
+	server.recordTokenSubmission("token1", 1)
+	dbFake.SetUnavailable(false)
+	stats, _ = server.GetTokenSubmissionStats()
+	assert.Equal(t, int64(3), stats[0].Count)
+}
+
+// Test Server signed client commitment verification per signature scheme
+func TestServerSaveSignedClientCommitment_Schemes(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	ecdsaKey, _ := btcec.NewPrivateKey(btcec.S256())
+	schnorrKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition:  0,
+			AuthToken:       "token",
+			Pubkey:          hex.EncodeToString(ecdsaKey.PubKey().SerializeCompressed()),
+			ClientName:      "ecdsa",
+			SignatureScheme: models.SignatureSchemeECDSA},
+		models.ClientDetails{
+			ClientPosition:  1,
+			AuthToken:       "token",
+			Pubkey:          hex.EncodeToString(schnorr.PubKey(schnorrKey.PubKey())),
+			ClientName:      "schnorr",
+			SignatureScheme: models.SignatureSchemeSchnorr},
+		models.ClientDetails{
+			ClientPosition:  2,
+			AuthToken:       "token",
+			Pubkey:          hex.EncodeToString(ecdsaKey.PubKey().SerializeCompressed()),
+			ClientName:      "schnorr invalid pubkey",
+			SignatureScheme: models.SignatureSchemeSchnorr},
+		models.ClientDetails{
+			ClientPosition:  3,
+			AuthToken:       "token",
+			Pubkey:          hex.EncodeToString(ecdsaKey.PubKey().SerializeCompressed()),
+			ClientName:      "unknown",
+			SignatureScheme: "ed25519"}})
+
+	commitment := "ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	hash, _ := chainhash.NewHashFromStr(commitment)
+
+	// test signature of other scheme rejected
+	_, saveErr := server.SaveSignedClientCommitment(schnorrSignedCommitmentMsg(ecdsaKey, commitment, 0, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSignature))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(schnorrKey, commitment, 1, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentSignature))
+
+	// test invalid schnorr pubkey and unsupported scheme
+	_, saveErr = server.SaveSignedClientCommitment(schnorrSignedCommitmentMsg(ecdsaKey, commitment, 2, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentPubkey))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(ecdsaKey, commitment, 3, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentScheme))
+
+	// test valid signed commitments saved for each scheme
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(ecdsaKey, commitment, 0, "token"))
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveSignedClientCommitment(schnorrSignedCommitmentMsg(schnorrKey, commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash, 0},
+		models.ClientCommitment{*hash, 1}}, latestCommitments)
+}
+
+// Test Server signed client commitment validation per commitment kind
+func TestServerSaveSignedClientCommitment_Kinds(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	pubkey := hex.EncodeToString(privKey.PubKey().SerializeCompressed())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 0,
+			AuthToken:      "token",
+			Pubkey:         pubkey,
+			ClientName:     "blockhash",
+			CommitmentKind: models.CommitmentKindBlockhash},
+		models.ClientDetails{
+			ClientPosition: 1,
+			AuthToken:      "token",
+			Pubkey:         pubkey,
+			ClientName:     "custom",
+			CommitmentKind: models.CommitmentKindCustom},
+		models.ClientDetails{
+			ClientPosition: 2,
+			AuthToken:      "token",
+			Pubkey:         pubkey,
+			ClientName:     "unknown",
+			CommitmentKind: "sha3"}})
+
+	commitment := "ddddddd1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	zeroCommitment := (&chainhash.Hash{}).String()
+	hash, _ := chainhash.NewHashFromStr(commitment)
+
+	// test zero hash rejected for blockhash kind only
+	_, saveErr := server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, zeroCommitment, 0, "token"))
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorSignedCommitmentInvalid))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, zeroCommitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+
+	// test unsupported commitment kind
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 2, "token"))
+	assert.Equal(t, ErrorSignedCommitmentKind+" (sha3)", saveErr.Error())
+	assert.Equal(t, nil, CommitmentValidatorBlockhash{}.ValidateCommitment(models.ClientCommitment{*hash, 0}))
+
+	// test registering and removing commitment kind validators
+	server.RegisterKindCommitmentValidator("sha3", CommitmentValidatorFunc(
+		func(c models.ClientCommitment) error { return errors.New("not a sha3 hash") }))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 2, "token"))
+	assert.Equal(t, ErrorSignedCommitmentInvalid+" sha3: not a sha3 hash", saveErr.Error())
+	server.RegisterKindCommitmentValidator("sha3", nil)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 2, "token"))
+	assert.Equal(t, ErrorSignedCommitmentKind+" (sha3)", saveErr.Error())
+
+	// test valid commitments saved
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 0, "token"))
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash, 0},
+		models.ClientCommitment{*hash, 1}}, latestCommitments)
+}
+
+// Test Server listening to commitment source
+func TestServerListenCommitments(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 0,
+			AuthToken:      "token",
+			Pubkey:         hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+			ClientName:     "client"}})
+
+	commitment := "bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	hash, _ := chainhash.NewHashFromStr(commitment)
+
+	source := NewCommitmentSourceFake()
+	source.Publish([]byte("invalid"))
+	source.Publish(signedCommitmentMsg(privKey, commitment, 0, "token"))
+
+	wg := &sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go server.ListenCommitments(ctx, wg, source)
+
+	// wait for published commitments to be processed
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// test invalid commitment dropped and valid commitment saved
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{*hash, 0}}, latestCommitments)
+	assert.Equal(t, true, source.closed)
+}
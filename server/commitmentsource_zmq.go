@@ -0,0 +1,70 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"log"
+	"time"
+
+	"mainstay/config"
+	"mainstay/messengers"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// zmq commitment source consts
+const (
+	// default topic that signed client commitments are published to
+	DefaultCommitmentSourceTopic = "commitment"
+
+	// maximum time waiting for a commitment before returning
+	CommitmentSourcePollTimeout = 1 * time.Second
+)
+
+// CommitmentSourceZmq struct
+//
+// Implements CommitmentSource interface and subscribes
+// via zmq to the topic that clients publish their
+// latest signed commitments to
+type CommitmentSourceZmq struct {
+	// zmq subscribe interface to receive signed commitments
+	subscriber *messengers.SubscriberZmq
+
+	// poller with the subscriber socket
+	poller *zmq.Poller
+}
+
+// Return new CommitmentSourceZmq instance
+func NewCommitmentSourceZmq(sourceConfig config.CommitmentSourceConfig) *CommitmentSourceZmq {
+	topic := DefaultCommitmentSourceTopic
+	if sourceConfig.Topic != "" {
+		topic = sourceConfig.Topic
+	}
+	poller := zmq.NewPoller()
+	subscriber := messengers.NewSubscriberZmq(sourceConfig.Address, []string{topic}, poller)
+	return &CommitmentSourceZmq{subscriber, poller}
+}
+
+// Poll subscriber for the next signed commitment message
+// Returns false if no message is received within the poll timeout
+func (z *CommitmentSourceZmq) ReadCommitment() ([]byte, bool) {
+	sockets, pollErr := z.poller.Poll(CommitmentSourcePollTimeout)
+	if pollErr != nil {
+		log.Println(pollErr)
+		return nil, false
+	}
+	for _, socket := range sockets {
+		if socket.Socket == z.subscriber.Socket() {
+			_, msg := z.subscriber.ReadMessage()
+			return msg, true
+		}
+	}
+	return nil, false
+}
+
+// Close subscriber socket
+func (z *CommitmentSourceZmq) Close() {
+	z.subscriber.Close(z.poller)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// CommitmentValidator interface
+//
+// Provides the interface for custom validation of client commitments
+// Validators are invoked before a client commitment is accepted and
+// allow operators to plug in business rules, e.g. the commitment being
+// a valid blockhash of a known chain or monotonically increasing
+// The error of a validator rejecting a commitment is returned to the client
+type CommitmentValidator interface {
+	ValidateCommitment(commitment models.ClientCommitment) error
+}
+
+// CommitmentValidatorFunc type
+// Adapter to use ordinary functions as commitment validators
+type CommitmentValidatorFunc func(commitment models.ClientCommitment) error
+
+// Validate commitment by calling the validator function
+func (f CommitmentValidatorFunc) ValidateCommitment(commitment models.ClientCommitment) error {
+	return f(commitment)
+}
+
+// CommitmentValidatorNoop structure
+// Default commitment validator accepting every client commitment
+type CommitmentValidatorNoop struct{}
+
+// Accept client commitment
+func (v CommitmentValidatorNoop) ValidateCommitment(commitment models.ClientCommitment) error {
+	return nil
+}
+
+// CommitmentValidatorBlockhash structure
+// Validator of client commitments of the blockhash commitment kind
+// rejecting the zero hash, which is never a valid block hash
+type CommitmentValidatorBlockhash struct{}
+
+// Reject zero block hash commitment
+func (v CommitmentValidatorBlockhash) ValidateCommitment(commitment models.ClientCommitment) error {
+	if commitment.Commitment == (chainhash.Hash{}) {
+		return errors.New("zero block hash")
+	}
+	return nil
+}
+
+// commitmentValidators structure
+// Commitment validators registered in the server, guarded
+// so that validators can be registered while receiving commitments
+type commitmentValidators struct {
+	// mutex guarding validator access
+	mutex sync.RWMutex
+
+	// validator invoked for every client commitment
+	defaultValidator CommitmentValidator
+
+	// validators invoked for client commitments of a client position
+	positionValidators map[int32]CommitmentValidator
+
+	// validators invoked for client commitments authenticated with an auth token
+	tokenValidators map[string]CommitmentValidator
+
+	// validators invoked for signed client commitments of the commitment
+	// kind registered for the client, which is rejected if not supported
+	kindValidators map[string]CommitmentValidator
+}
+
+// Return new commitment validators with the no-op default validator
+// and the validators of the supported client commitment kinds
+func newCommitmentValidators() *commitmentValidators {
+	return &commitmentValidators{
+		defaultValidator:   CommitmentValidatorNoop{},
+		positionValidators: make(map[int32]CommitmentValidator),
+		tokenValidators:    make(map[string]CommitmentValidator),
+		kindValidators: map[string]CommitmentValidator{
+			models.CommitmentKindBlockhash: CommitmentValidatorBlockhash{},
+			models.CommitmentKindCustom:    CommitmentValidatorNoop{}}}
+}
+
+// Set the validator invoked for every client commitment
+func (s *Server) SetDefaultCommitmentValidator(validator CommitmentValidator) {
+	s.validators.mutex.Lock()
+	defer s.validators.mutex.Unlock()
+	s.validators.defaultValidator = validator
+}
+
+// Register a validator invoked for client commitments of the client position
+// Registering a nil validator removes the validator of the client position
+func (s *Server) RegisterPositionCommitmentValidator(position int32, validator CommitmentValidator) {
+	s.validators.mutex.Lock()
+	defer s.validators.mutex.Unlock()
+	if validator == nil {
+		delete(s.validators.positionValidators, position)
+		return
+	}
+	s.validators.positionValidators[position] = validator
+}
+
+// Register a validator invoked for signed client commitments authenticated
+// with the client auth token. Registering a nil validator removes the validator
+func (s *Server) RegisterTokenCommitmentValidator(token string, validator CommitmentValidator) {
+	s.validators.mutex.Lock()
+	defer s.validators.mutex.Unlock()
+	if validator == nil {
+		delete(s.validators.tokenValidators, token)
+		return
+	}
+	s.validators.tokenValidators[token] = validator
+}
+
+// Register a validator invoked for signed client commitments of clients with
+// the commitment kind, adding support for the kind if not supported already
+// Registering a nil validator removes support for the commitment kind
+func (s *Server) RegisterKindCommitmentValidator(kind string, validator CommitmentValidator) {
+	s.validators.mutex.Lock()
+	defer s.validators.mutex.Unlock()
+	if validator == nil {
+		delete(s.validators.kindValidators, kind)
+		return
+	}
+	s.validators.kindValidators[kind] = validator
+}
+
+// Validate client commitment with the validator of the commitment kind
+// registered for the client - the custom kind is used if no kind is set
+func (s *Server) validateCommitmentKind(kind string, commitment models.ClientCommitment) error {
+	if kind == "" {
+		kind = models.CommitmentKindCustom
+	}
+	s.validators.mutex.RLock()
+	validator, ok := s.validators.kindValidators[kind]
+	s.validators.mutex.RUnlock()
+	if !ok {
+		return errors.New(fmt.Sprintf("%s (%s)", ErrorSignedCommitmentKind, kind))
+	}
+	if validateErr := validator.ValidateCommitment(commitment); validateErr != nil {
+		return errors.New(fmt.Sprintf("%s %s: %v", ErrorSignedCommitmentInvalid, kind, validateErr))
+	}
+	return nil
+}
+
+// Validate client commitment with the default validator followed by the
+// validators of the client position and of the auth token, if registered
+// The error of the first validator rejecting the commitment is returned
+func (s *Server) validateCommitment(commitment models.ClientCommitment, token string) error {
+	s.validators.mutex.RLock()
+	validators := []CommitmentValidator{s.validators.defaultValidator}
+	if validator, ok := s.validators.positionValidators[commitment.ClientPosition]; ok {
+		validators = append(validators, validator)
+	}
+	if validator, ok := s.validators.tokenValidators[token]; ok && token != "" {
+		validators = append(validators, validator)
+	}
+	s.validators.mutex.RUnlock()
+
+	for _, validator := range validators {
+		if validator == nil {
+			continue
+		}
+		if validateErr := validator.ValidateCommitment(commitment); validateErr != nil {
+			return validateErr
+		}
+	}
+	return nil
+}
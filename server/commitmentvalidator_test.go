@@ -0,0 +1,83 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Server custom commitment validators per position and token
+func TestServerCommitmentValidators(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	privKey, _ := btcec.NewPrivateKey(btcec.S256())
+	dbFake.SetClientDetails([]models.ClientDetails{
+		models.ClientDetails{
+			ClientPosition: 1,
+			AuthToken:      "token",
+			Pubkey:         hex.EncodeToString(privKey.PubKey().SerializeCompressed()),
+			ClientName:     "client"}})
+
+	commitment := "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+	hash, _ := chainhash.NewHashFromStr(commitment)
+	otherHash, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// test no-op default validator accepts commitments
+	assert.Equal(t, nil, CommitmentValidatorNoop{}.ValidateCommitment(models.ClientCommitment{*hash, 0}))
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash, 0})
+	assert.Equal(t, nil, saveErr)
+
+	// test position validator rejects commitments of its position only
+	errOther := errors.New("commitment not allowed")
+	server.RegisterPositionCommitmentValidator(0, CommitmentValidatorFunc(
+		func(c models.ClientCommitment) error {
+			if c.Commitment != *hash {
+				return errOther
+			}
+			return nil
+		}))
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*otherHash, 0})
+	assert.Equal(t, errOther, saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*otherHash, 2})
+	assert.Equal(t, nil, saveErr)
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash, 0}, models.ClientCommitment{*otherHash, 2}}, latestCommitments)
+
+	// test removing position validator
+	server.RegisterPositionCommitmentValidator(0, nil)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*otherHash, 0})
+	assert.Equal(t, nil, saveErr)
+
+	// test token validator invoked for signed commitments of the token
+	errToken := errors.New("token commitments paused")
+	server.RegisterTokenCommitmentValidator("token", CommitmentValidatorFunc(
+		func(c models.ClientCommitment) error { return errToken }))
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, errToken, saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash, 1})
+	assert.Equal(t, nil, saveErr)
+	server.RegisterTokenCommitmentValidator("token", nil)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, nil, saveErr)
+
+	// test default validator invoked for every commitment
+	errDefault := errors.New("commitments paused")
+	server.SetDefaultCommitmentValidator(CommitmentValidatorFunc(
+		func(c models.ClientCommitment) error { return errDefault }))
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash, 3})
+	assert.Equal(t, errDefault, saveErr)
+	_, saveErr = server.SaveSignedClientCommitment(signedCommitmentMsg(privKey, commitment, 1, "token"))
+	assert.Equal(t, errDefault, saveErr)
+}
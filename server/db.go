@@ -5,6 +5,8 @@
 package server
 
 import (
+	"time"
+
 	"mainstay/models"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -20,13 +22,33 @@ type Db interface {
 	saveAttestationInfo(models.AttestationInfo) error
 	saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error
 	saveMerkleProofs(proofs []models.CommitmentMerkleProof) error
+	saveClientCommitment(models.ClientCommitment) (bool, error)
+	saveCheckpoint(chainhash.Hash) error
+	saveCommitmentSnapshot(models.CommitmentSnapshot) error
+	saveAttestationReceipt(models.AttestationReceipt) error
+	saveAttestationSchedule(models.AttestationSchedule) error
+	saveTokenSubmission(string, int32, time.Time) error
 
 	// util methods
 	getAttestationCount(...bool) (int64, error)
 	getAttestationMerkleRoot(chainhash.Hash) (string, error)
+	getClientCommitmentUpdateTime(int32) (time.Time, error)
+	getClientCommitmentSeenTime(int32) (time.Time, error)
+	getCheckpoint() (chainhash.Hash, error)
+	getCommitmentSnapshot(chainhash.Hash) (models.CommitmentSnapshot, error)
+	getAttestationReceipt(chainhash.Hash) (models.AttestationReceipt, error)
+	getAttestationSchedule() (models.AttestationSchedule, error)
 
 	// get methods required by server
 	getLatestAttestationMerkleRoot(bool) (string, error)
 	getClientCommitments() ([]models.ClientCommitment, error)
+	getClientDetails() ([]models.ClientDetails, error)
 	getAttestationMerkleCommitments(chainhash.Hash) ([]models.CommitmentMerkleCommitment, error)
+	getMerkleCommitmentsForCommitment(chainhash.Hash) ([]models.CommitmentMerkleCommitment, error)
+	getAttestationTxidsForMerkleRoot(chainhash.Hash, bool) ([]chainhash.Hash, error)
+	getConfirmedAttestationsForWindow(time.Time, time.Time) ([]models.AttestationBSON, error)
+	getTokenSubmissionStats() ([]models.TokenSubmissionStats, error)
+
+	// compaction methods
+	compactMerkleCommitments(time.Time) (int64, error)
 }
@@ -7,34 +7,74 @@ package server
 import (
 	"errors"
 	"mainstay/models"
+	"sort"
+	"time"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
+// error consts
+const (
+	ErrorDbFakeUnavailable = "Fake db unavailable"
+	ErrorDbFakeRejected    = "Fake db rejected write"
+)
+
 // DbFake struct
 // Implements all the high level Db methods used by attestation server
 // Minimizing as much as possible reliance to mongo and testing as much
 // as possible without the need for a proper mongo mock for testing
 type DbFake struct {
 	attestations      []models.Attestation
+	attestationTimes  map[chainhash.Hash]time.Time
 	attestationsInfo  []models.AttestationInfo
 	merkleCommitments []models.CommitmentMerkleCommitment
 	merkleProofs      []models.CommitmentMerkleProof
 	latestCommitments []models.ClientCommitment
+	commitmentTimes   map[int32]time.Time
+	commitmentSeen    map[int32]time.Time
+	checkpoint        chainhash.Hash
+	clientDetails     []models.ClientDetails
+	snapshots         map[chainhash.Hash]models.CommitmentSnapshot
+	receipts          map[chainhash.Hash]models.AttestationReceipt
+	schedule          models.AttestationSchedule
+	tokenStats        map[string]models.TokenSubmissionStats
+
+	// fail writes and latest attestation reads to simulate an outage
+	unavailable bool
+
+	// fail attestation and client commitment writes with the db available
+	rejectWrites bool
 }
 
 // Return new DbFake instance
 func NewDbFake() *DbFake {
 	return &DbFake{
 		[]models.Attestation{},
+		map[chainhash.Hash]time.Time{},
 		[]models.AttestationInfo{},
 		[]models.CommitmentMerkleCommitment{},
 		[]models.CommitmentMerkleProof{},
-		[]models.ClientCommitment{}}
+		[]models.ClientCommitment{},
+		map[int32]time.Time{},
+		map[int32]time.Time{},
+		chainhash.Hash{},
+		[]models.ClientDetails{},
+		map[chainhash.Hash]models.CommitmentSnapshot{},
+		map[chainhash.Hash]models.AttestationReceipt{},
+		models.AttestationSchedule{},
+		map[string]models.TokenSubmissionStats{},
+		false,
+		false}
 }
 
 // Save latest attestation to attestations
 func (d *DbFake) saveAttestation(attestation models.Attestation) error {
+	if d.unavailable {
+		return errors.New(ErrorDbFakeUnavailable)
+	} else if d.rejectWrites {
+		return errors.New(ErrorDbFakeRejected)
+	}
+	d.attestationTimes[attestation.Txid] = time.Now()
 	for i, a := range d.attestations {
 		if a.Txid == attestation.Txid {
 			d.attestations[i] = attestation
@@ -47,6 +87,9 @@ func (d *DbFake) saveAttestation(attestation models.Attestation) error {
 
 // Save latest attestation info to attestationsInfo
 func (d *DbFake) saveAttestationInfo(attestationInfo models.AttestationInfo) error {
+	if d.unavailable {
+		return errors.New(ErrorDbFakeUnavailable)
+	}
 	for i, a := range d.attestationsInfo {
 		if a.Txid == attestationInfo.Txid {
 			d.attestationsInfo[i] = attestationInfo
@@ -59,6 +102,9 @@ func (d *DbFake) saveAttestationInfo(attestationInfo models.AttestationInfo) err
 
 // Save merkle commitments to the MerkleCommitment collection
 func (d *DbFake) saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
+	if d.unavailable {
+		return errors.New(ErrorDbFakeUnavailable)
+	}
 	var newCommitments []models.CommitmentMerkleCommitment
 	for _, commitment := range commitments {
 		found := false
@@ -79,12 +125,15 @@ func (d *DbFake) saveMerkleCommitments(commitments []models.CommitmentMerkleComm
 
 // Save merkle proofs to the MerkleProof collection
 func (d *DbFake) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
+	if d.unavailable {
+		return errors.New(ErrorDbFakeUnavailable)
+	}
 	var newProofs []models.CommitmentMerkleProof
 	for _, proof := range proofs {
 		found := false
 		for i, p := range d.merkleProofs {
 			if p.Commitment == proof.Commitment &&
-				p.ClientPosition == proof.ClientPosition &&
+				p.LeafIndex == proof.LeafIndex &&
 				p.MerkleRoot == proof.MerkleRoot {
 				found = true
 				d.merkleProofs[i] = proof
@@ -99,6 +148,77 @@ func (d *DbFake) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error {
 	return nil
 }
 
+// Save client commitment to latest commitments keeping client position order
+// Returns true if the commitment is already recorded, only updating the
+// time the client was last seen and not the commitment update time
+func (d *DbFake) saveClientCommitment(commitment models.ClientCommitment) (bool, error) {
+	if d.unavailable {
+		return false, errors.New(ErrorDbFakeUnavailable)
+	} else if d.rejectWrites {
+		return false, errors.New(ErrorDbFakeRejected)
+	}
+	now := time.Now()
+	d.commitmentSeen[commitment.ClientPosition] = now
+	for i, c := range d.latestCommitments {
+		if c.ClientPosition == commitment.ClientPosition {
+			if c.Commitment == commitment.Commitment {
+				return true, nil
+			}
+			d.commitmentTimes[commitment.ClientPosition] = now
+			d.latestCommitments[i] = commitment
+			return false, nil
+		}
+	}
+	d.commitmentTimes[commitment.ClientPosition] = now
+	d.latestCommitments = append(d.latestCommitments, commitment)
+	sort.Slice(d.latestCommitments, func(i, j int) bool {
+		return d.latestCommitments[i].ClientPosition < d.latestCommitments[j].ClientPosition
+	})
+	return false, nil
+}
+
+// Save staychain checkpoint txid
+func (d *DbFake) saveCheckpoint(txid chainhash.Hash) error {
+	d.checkpoint = txid
+	return nil
+}
+
+// Save commitment snapshot if none exists for the merkle root
+func (d *DbFake) saveCommitmentSnapshot(snapshot models.CommitmentSnapshot) error {
+	if _, ok := d.snapshots[snapshot.MerkleRoot]; !ok {
+		d.snapshots[snapshot.MerkleRoot] = snapshot
+	}
+	return nil
+}
+
+// Save attestation receipt replacing any receipt for the attestation txid
+func (d *DbFake) saveAttestationReceipt(receipt models.AttestationReceipt) error {
+	d.receipts[receipt.Txid] = receipt
+	return nil
+}
+
+// Save attestation schedule replacing any previous schedule
+func (d *DbFake) saveAttestationSchedule(schedule models.AttestationSchedule) error {
+	d.schedule = schedule
+	return nil
+}
+
+// Increment submission count of token and update latest submission time
+func (d *DbFake) saveTokenSubmission(token string, position int32, submitted time.Time) error {
+	if d.unavailable {
+		return errors.New(ErrorDbFakeUnavailable)
+	}
+	stats, ok := d.tokenStats[token]
+	if !ok {
+		stats = models.TokenSubmissionStats{Token: token, FirstSubmission: submitted}
+	}
+	stats.ClientPosition = position
+	stats.Count++
+	stats.LastSubmission = submitted
+	d.tokenStats[token] = stats
+	return nil
+}
+
 // Return attestation count with optional confirmed flag
 func (d *DbFake) getAttestationCount(confirmed ...bool) (int64, error) {
 	if len(confirmed) > 0 {
@@ -115,6 +235,9 @@ func (d *DbFake) getAttestationCount(confirmed ...bool) (int64, error) {
 
 // Return latest attestation commitment hash
 func (d *DbFake) getLatestAttestationMerkleRoot(confirmed bool) (string, error) {
+	if d.unavailable {
+		return "", errors.New(ErrorDbFakeUnavailable)
+	}
 	count, _ := d.getAttestationCount(confirmed)
 	if count == 0 {
 		return "", nil
@@ -165,6 +288,169 @@ func (d *DbFake) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models.
 	return merkleCommitments, nil
 }
 
+// Return merkle commitments with commitment value matching the given commitment
+func (d *DbFake) getMerkleCommitmentsForCommitment(commitment chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	var merkleCommitments []models.CommitmentMerkleCommitment
+	for _, merkleCommitment := range d.merkleCommitments {
+		if merkleCommitment.Commitment == commitment {
+			merkleCommitments = append(merkleCommitments, merkleCommitment)
+		}
+	}
+	return merkleCommitments, nil
+}
+
+// Return txids of attestations with the given merkle root and confirmed flag
+func (d *DbFake) getAttestationTxidsForMerkleRoot(merkleRoot chainhash.Hash, confirmed bool) ([]chainhash.Hash, error) {
+	var txids []chainhash.Hash
+	for _, attestation := range d.attestations {
+		if attestation.CommitmentHash() == merkleRoot && attestation.Confirmed == confirmed {
+			txids = append(txids, attestation.Txid)
+		}
+	}
+	return txids, nil
+}
+
+// Return confirmed attestations inserted within the time window [from, to)
+func (d *DbFake) getConfirmedAttestationsForWindow(from time.Time, to time.Time) ([]models.AttestationBSON, error) {
+	var attestations []models.AttestationBSON
+	for _, attestation := range d.attestations {
+		insertedAt := d.attestationTimes[attestation.Txid]
+		if !attestation.Confirmed || insertedAt.Before(from) || !insertedAt.Before(to) {
+			continue
+		}
+		attestations = append(attestations, models.AttestationBSON{
+			Txid:       attestation.Txid.String(),
+			MerkleRoot: attestation.CommitmentHash().String(),
+			Confirmed:  attestation.Confirmed,
+			InsertedAt: insertedAt})
+	}
+	sort.SliceStable(attestations, func(i, j int) bool {
+		return attestations[i].InsertedAt.Before(attestations[j].InsertedAt)
+	})
+	return attestations, nil
+}
+
+// Return time of latest client commitment update for client position
+func (d *DbFake) getClientCommitmentUpdateTime(position int32) (time.Time, error) {
+	return d.commitmentTimes[position], nil
+}
+
+// Return time client commitment for client position was last submitted
+// Falls back to the update time if the client has not been seen since
+func (d *DbFake) getClientCommitmentSeenTime(position int32) (time.Time, error) {
+	if seenTime, ok := d.commitmentSeen[position]; ok {
+		return seenTime, nil
+	}
+	return d.commitmentTimes[position], nil
+}
+
+// Return staychain checkpoint txid
+func (d *DbFake) getCheckpoint() (chainhash.Hash, error) {
+	return d.checkpoint, nil
+}
+
+// Return commitment snapshot for merkle root
+func (d *DbFake) getCommitmentSnapshot(merkleRoot chainhash.Hash) (models.CommitmentSnapshot, error) {
+	return d.snapshots[merkleRoot], nil
+}
+
+// Return attestation receipt for attestation txid
+func (d *DbFake) getAttestationReceipt(txid chainhash.Hash) (models.AttestationReceipt, error) {
+	return d.receipts[txid], nil
+}
+
+// Return latest attestation schedule
+func (d *DbFake) getAttestationSchedule() (models.AttestationSchedule, error) {
+	return d.schedule, nil
+}
+
+// Return submission stats of all tokens ordered by descending submission count
+func (d *DbFake) getTokenSubmissionStats() ([]models.TokenSubmissionStats, error) {
+	var stats []models.TokenSubmissionStats
+	for _, s := range d.tokenStats {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count == stats[j].Count {
+			return stats[i].Token < stats[j].Token
+		}
+		return stats[i].Count > stats[j].Count
+	})
+	return stats, nil
+}
+
+// Delete merkle commitments, proofs and snapshots of merkle roots only
+// attested by unconfirmed attestations saved before the time provided
+// along with these attestations, keeping the latest unconfirmed root
+func (d *DbFake) compactMerkleCommitments(before time.Time) (int64, error) {
+	latestRoot, _ := d.getLatestAttestationMerkleRoot(false)
+	staleRoots := make(map[chainhash.Hash]bool)
+	for _, a := range d.attestations {
+		if !a.Confirmed && d.attestationTimes[a.Txid].Before(before) && a.CommitmentHash().String() != latestRoot {
+			staleRoots[a.CommitmentHash()] = true
+		}
+	}
+	for _, a := range d.attestations {
+		if a.Confirmed {
+			delete(staleRoots, a.CommitmentHash())
+		}
+	}
+
+	var attestations []models.Attestation
+	for _, a := range d.attestations {
+		if !staleRoots[a.CommitmentHash()] {
+			attestations = append(attestations, a)
+		}
+	}
+	d.attestations = attestations
+
+	var deleted int64
+	var merkleCommitments []models.CommitmentMerkleCommitment
+	for _, c := range d.merkleCommitments {
+		if staleRoots[c.MerkleRoot] {
+			deleted++
+			continue
+		}
+		merkleCommitments = append(merkleCommitments, c)
+	}
+	d.merkleCommitments = merkleCommitments
+
+	var merkleProofs []models.CommitmentMerkleProof
+	for _, p := range d.merkleProofs {
+		if !staleRoots[p.MerkleRoot] {
+			merkleProofs = append(merkleProofs, p)
+		}
+	}
+	d.merkleProofs = merkleProofs
+
+	for root := range staleRoots {
+		delete(d.snapshots, root)
+	}
+	return deleted, nil
+}
+
+// Set attestation insert time for testing
+func (d *DbFake) SetAttestationInsertTime(txid chainhash.Hash, insertTime time.Time) {
+	d.attestationTimes[txid] = insertTime
+}
+
+// Set client commitment update time for testing
+// The client is also treated as last seen at the update time
+func (d *DbFake) SetClientCommitmentUpdateTime(position int32, updateTime time.Time) {
+	d.commitmentTimes[position] = updateTime
+	delete(d.commitmentSeen, position)
+}
+
+// Set db unavailable for testing db outages
+func (d *DbFake) SetUnavailable(unavailable bool) {
+	d.unavailable = unavailable
+}
+
+// Set attestation and client commitment writes rejected for testing
+func (d *DbFake) SetRejectWrites(reject bool) {
+	d.rejectWrites = reject
+}
+
 // Set latest commitments for testing
 func (d *DbFake) SetClientCommitments(latestCommitments []models.ClientCommitment) {
 	d.latestCommitments = latestCommitments
@@ -174,3 +460,13 @@ func (d *DbFake) SetClientCommitments(latestCommitments []models.ClientCommitmen
 func (d *DbFake) getClientCommitments() ([]models.ClientCommitment, error) {
 	return d.latestCommitments, nil
 }
+
+// Set client details for testing
+func (d *DbFake) SetClientDetails(clientDetails []models.ClientDetails) {
+	d.clientDetails = clientDetails
+}
+
+// Return fake client details
+func (d *DbFake) getClientDetails() ([]models.ClientDetails, error) {
+	return d.clientDetails, nil
+}
@@ -9,6 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"mainstay/config"
 	"mainstay/models"
@@ -16,22 +18,31 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 	"go.mongodb.org/mongo-driver/x/bsonx"
 )
 
 const (
 	// collection names
-	ColNameAttestation      = "Attestation"
-	ColNameAttestationInfo  = "AttestationInfo"
-	ColNameMerkleCommitment = "MerkleCommitment"
-	ColNameMerkleProof      = "MerkleProof"
-	ColNameClientCommitment = "ClientCommitment"
-	ColNameClientDetails    = "ClientDetails"
+	ColNameAttestation         = "Attestation"
+	ColNameAttestationInfo     = "AttestationInfo"
+	ColNameMerkleCommitment    = "MerkleCommitment"
+	ColNameMerkleProof         = "MerkleProof"
+	ColNameClientCommitment    = "ClientCommitment"
+	ColNameClientDetails       = "ClientDetails"
+	ColNameCheckpoint          = "Checkpoint"
+	ColNameCommitmentSnapshot  = "CommitmentSnapshot"
+	ColNameAttestationReceipt  = "AttestationReceipt"
+	ColNameAttestationSchedule = "AttestationSchedule"
+	ColNameTokenSubmission     = "TokenSubmission"
 
 	// error messages
 	ErrorMongoClient  = "could not create mongoDB client"
 	ErrorMongoConnect = "could not connect to mongoDB client"
 	ErrorMongoPing    = "could not ping mongoDB database"
+	ErrorMongoIndex   = "could not create mongoDB index"
 
 	ErrorAttestationSave      = "could not save attestation"
 	ErrorAttestationInfoSave  = "could not save attestation info"
@@ -39,16 +50,36 @@ const (
 	ErrorMerkleProofSave      = "could not save merkle proof"
 	ErrorClientDetailsSave    = "could not save client details"
 	ErrorClientCommitmentSave = "could not save client commitment"
+	ErrorCheckpointSave       = "could not save checkpoint"
+	ErrorSnapshotSave         = "could not save commitment snapshot"
+	ErrorReceiptSave          = "could not save attestation receipt"
+	ErrorScheduleSave         = "could not save attestation schedule"
+	ErrorTokenSubmissionSave  = "could not save token submission"
 
 	ErrorAttestationGet      = "could not get attestation"
+	ErrorAttestationInfoGet  = "could not get attestation info"
 	ErrorMerkleCommitmentGet = "could not get merkle commitment"
 	ErrorMerkleProofGet      = "could not get merkle proof"
 	ErrorClientCommitmentGet = "could not get client commitment"
 	ErrorClientDetailsGet    = "could not get client details"
+	ErrorCheckpointGet       = "could not get checkpoint"
+	ErrorSnapshotGet         = "could not get commitment snapshot"
+	ErrorReceiptGet          = "could not get attestation receipt"
+	ErrorScheduleGet         = "could not get attestation schedule"
+	ErrorTokenSubmissionGet  = "could not get token submission stats"
+
+	ErrorAttestationDelete      = "could not delete attestation"
+	ErrorMerkleCommitmentDelete = "could not delete merkle commitment"
+	ErrorMerkleProofDelete      = "could not delete merkle proof"
+	ErrorSnapshotDelete         = "could not delete commitment snapshot"
 
 	BadDataClientCommitmentCol = "bad data in client commitment collection"
 	BadDataMerkleCommitmentCol = "bad data in merkle commitment collection"
 	BadDataClientDetailsCol    = "bad data in client details collection"
+	BadDataCheckpointCol       = "bad data in checkpoint collection"
+	BadDataAttestationInfoCol  = "bad data in attestation info collection"
+	BadDataAttestationCol      = "bad data in attestation collection"
+	BadDataTokenSubmissionCol  = "bad data in token submission collection"
 
 	BadDataAttestationModel      = "bad data in attestation model"
 	BadDataAttestationInfoModel  = "bad data in attestation info model"
@@ -56,11 +87,26 @@ const (
 	BadDataMerkleProofModel      = "bad data in merkle proof model"
 	BadDataClientDetailsModel    = "bad data in client details model"
 	BadDataClientCommitmentModel = "bad data in client commitment model"
+	BadDataSnapshotModel         = "bad data in commitment snapshot model"
+	BadDataReceiptModel          = "bad data in attestation receipt model"
+	BadDataScheduleModel         = "bad data in attestation schedule model"
+
+	// client commitment update and last seen time field names
+	ClientCommitmentUpdatedAtName = "updated_at"
+	ClientCommitmentSeenAtName    = "seen_at"
+
+	// mongoDB duplicate key error code
+	mongoDuplicateKeyCode = 11000
+
+	// checkpoint field names
+	CheckpointTxidName      = "txid"
+	CheckpointUpdatedAtName = "updated_at"
 )
 
-// Method to connect to mongo database through config
-func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Database, error) {
-	// get this from config
+// Method to build mongo client options from config
+// Connection pool settings are applied only if set
+// Reads are routed to the primary unless a read preference is set
+func dbClientOptions(dbConnectivity config.DbConfig) *options.ClientOptions {
 	uri := fmt.Sprintf(`mongodb://%s:%s@%s:%s/%s`,
 		dbConnectivity.User,
 		dbConnectivity.Password,
@@ -68,8 +114,33 @@ func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Data
 		dbConnectivity.Port,
 		dbConnectivity.Name,
 	)
+	clientOptions := options.Client().ApplyURI(uri)
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri))
+	if dbConnectivity.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(dbConnectivity.MaxPoolSize)
+	}
+	if dbConnectivity.SocketTimeoutSeconds > 0 {
+		clientOptions.SetSocketTimeout(time.Duration(dbConnectivity.SocketTimeoutSeconds) * time.Second)
+	}
+	if dbConnectivity.ReadConcern != "" {
+		clientOptions.SetReadConcern(readconcern.New(readconcern.Level(dbConnectivity.ReadConcern)))
+	}
+	if dbConnectivity.WriteConcern == "majority" {
+		clientOptions.SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+	} else if w, wErr := strconv.Atoi(dbConnectivity.WriteConcern); wErr == nil {
+		clientOptions.SetWriteConcern(writeconcern.New(writeconcern.W(w)))
+	}
+	if mode, modeErr := readpref.ModeFromString(dbConnectivity.ReadPreference); modeErr == nil {
+		if readPref, readPrefErr := readpref.New(mode); readPrefErr == nil {
+			clientOptions.SetReadPreference(readPref)
+		}
+	}
+	return clientOptions
+}
+
+// Method to connect to mongo database through config
+func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Database, error) {
+	client, err := mongo.NewClient(dbClientOptions(dbConnectivity))
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("%s %v", ErrorMongoClient, err))
 	}
@@ -87,6 +158,16 @@ func dbConnect(ctx context.Context, dbConnectivity config.DbConfig) (*mongo.Data
 	return client.Database(dbConnectivity.Name), nil
 }
 
+// Check connectivity to the mongo database through config
+// The connection is closed after the database is reached
+func CheckDbMongo(ctx context.Context, dbConnectivity config.DbConfig) error {
+	db, errConnect := dbConnect(ctx, dbConnectivity)
+	if errConnect != nil {
+		return errConnect
+	}
+	return db.Client().Disconnect(ctx)
+}
+
 // DbMongo struct
 type DbMongo struct {
 	// context required by mongo interface
@@ -106,7 +187,84 @@ func NewDbMongo(ctx context.Context, dbConnectivity config.DbConfig) *DbMongo {
 		log.Fatal(errConnect)
 	}
 
-	return &DbMongo{ctx, dbConnectivity, db}
+	d := &DbMongo{ctx, dbConnectivity, db}
+	if indexErr := d.createIndexes(); indexErr != nil {
+		log.Printf("%v\n", indexErr)
+	}
+	return d
+}
+
+// Create indexes required for commitment lookups
+// Existing indexes with the same keys are left unchanged
+func (d *DbMongo) createIndexes() error {
+	commitmentIndex := mongo.IndexModel{
+		Keys: bsonx.Doc{{models.CommitmentCommitmentName, bsonx.Int32(1)}},
+	}
+	_, indexErr := d.db.Collection(ColNameMerkleCommitment).Indexes().CreateOne(d.ctx, commitmentIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+
+	merkleRootIndex := mongo.IndexModel{
+		Keys: bsonx.Doc{{models.AttestationMerkleRootName, bsonx.Int32(1)}},
+	}
+	_, indexErr = d.db.Collection(ColNameAttestation).Indexes().CreateOne(d.ctx, merkleRootIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+
+	// attestation insert time index used for attestation time window lookups
+	insertedAtIndex := mongo.IndexModel{
+		Keys: bsonx.Doc{{models.AttestationInsertedAtName, bsonx.Int32(1)}},
+	}
+	_, indexErr = d.db.Collection(ColNameAttestation).Indexes().CreateOne(d.ctx, insertedAtIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+
+	snapshotRootIndex := mongo.IndexModel{
+		Keys: bsonx.Doc{{models.CommitmentSnapshotMerkleRootName, bsonx.Int32(1)}},
+	}
+	_, indexErr = d.db.Collection(ColNameCommitmentSnapshot).Indexes().CreateOne(d.ctx, snapshotRootIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+
+	// unique client position index used for idempotent commitment upserts
+	clientPositionIndex := mongo.IndexModel{
+		Keys:    bsonx.Doc{{models.ClientCommitmentClientPositionName, bsonx.Int32(1)}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, indexErr = d.db.Collection(ColNameClientCommitment).Indexes().CreateOne(d.ctx, clientPositionIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+
+	// unique token index used for token submission upserts
+	tokenIndex := mongo.IndexModel{
+		Keys:    bsonx.Doc{{models.TokenSubmissionStatsTokenName, bsonx.Int32(1)}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, indexErr = d.db.Collection(ColNameTokenSubmission).Indexes().CreateOne(d.ctx, tokenIndex)
+	if indexErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorMongoIndex, indexErr))
+	}
+	return nil
+}
+
+// Check if error is a mongoDB duplicate key error
+func isDuplicateKeyError(err error) bool {
+	switch e := err.(type) {
+	case mongo.CommandError:
+		return e.Code == mongoDuplicateKeyCode
+	case mongo.WriteException:
+		for _, writeErr := range e.WriteErrors {
+			if writeErr.Code == mongoDuplicateKeyCode {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // Save latest attestation to the Attestation collection
@@ -171,6 +329,26 @@ func (d *DbMongo) saveAttestationInfo(attestationInfo models.AttestationInfo) er
 	return nil
 }
 
+// Return filter of the MerkleCommitment or MerkleProof document of the leaf
+// index of a merkle root - both collections share the field names filtered on
+// Documents stored without a leaf index are matched by client position, as
+// their leaves were ordered by client position, so that these are updated
+// with the leaf index instead of being duplicated
+func merkleLeafFilter(merkleRoot string, leafIndex int32) bsonx.Doc {
+	return bsonx.Doc{
+		{models.CommitmentMerkleRootName, bsonx.String(merkleRoot)},
+		{"$or", bsonx.Array(bsonx.Arr{
+			bsonx.Document(bsonx.Doc{
+				{models.CommitmentLeafIndexName, bsonx.Int32(leafIndex)},
+			}),
+			bsonx.Document(bsonx.Doc{
+				{models.CommitmentLeafIndexName, bsonx.Document(bsonx.Doc{{"$exists", bsonx.Boolean(false)}})},
+				{models.CommitmentClientPositionName, bsonx.Int32(leafIndex)},
+			}),
+		})},
+	}
+}
+
 // Save merkle commitments to the MerkleCommitment collection
 func (d *DbMongo) saveMerkleCommitments(commitments []models.CommitmentMerkleCommitment) error {
 	for pos := range commitments {
@@ -186,12 +364,9 @@ func (d *DbMongo) saveMerkleCommitments(commitments []models.CommitmentMerkleCom
 		}
 
 		// search if merkle commitment already exists
-		filterMerkleCommitment := bsonx.Doc{
-			{models.CommitmentMerkleRootName,
-				bsonx.String(docCommitment.Lookup(models.CommitmentMerkleRootName).StringValue())},
-			{models.CommitmentClientPositionName,
-				bsonx.Int32(docCommitment.Lookup(models.CommitmentClientPositionName).Int32())},
-		}
+		filterMerkleCommitment := merkleLeafFilter(
+			docCommitment.Lookup(models.CommitmentMerkleRootName).StringValue(),
+			docCommitment.Lookup(models.CommitmentLeafIndexName).Int32())
 
 		// insert or update merkle commitment
 		var t bsonx.Doc
@@ -220,12 +395,9 @@ func (d *DbMongo) saveMerkleProofs(proofs []models.CommitmentMerkleProof) error
 		}
 
 		// search if merkle proof already exists
-		filterMerkleProof := bsonx.Doc{
-			{models.ProofMerkleRootName,
-				bsonx.String(docProof.Lookup(models.ProofMerkleRootName).StringValue())},
-			{models.ProofClientPositionName,
-				bsonx.Int32(docProof.Lookup(models.ProofClientPositionName).Int32())},
-		}
+		filterMerkleProof := merkleLeafFilter(
+			docProof.Lookup(models.ProofMerkleRootName).StringValue(),
+			docProof.Lookup(models.ProofLeafIndexName).Int32())
 
 		// insert or update merkle proof
 		var t bsonx.Doc
@@ -271,21 +443,38 @@ func (d *DbMongo) SaveClientDetails(details models.ClientDetails) error {
 }
 
 // Save client commitment to ClientCommitment collection
+// Exported for use by tools and regtest work
 func (d *DbMongo) SaveClientCommitment(commitment models.ClientCommitment) error {
+	_, saveErr := d.saveClientCommitment(commitment)
+	return saveErr
+}
+
+// Save client commitment to ClientCommitment collection
+// Along with the commitment store the time of the update
+// The upsert only matches the client position if the commitment differs,
+// so resubmitting the same commitment hits the unique client position
+// index and is reported as already recorded without updating the time
+// The time the client was last seen is updated on every submission
+func (d *DbMongo) saveClientCommitment(commitment models.ClientCommitment) (bool, error) {
 	// get document representation of client details
 	docCommitment, docErr := models.GetDocumentFromModel(commitment)
 	if docErr != nil {
-		return errors.New(fmt.Sprintf("%s %v", BadDataClientCommitmentModel, docErr))
+		return false, errors.New(fmt.Sprintf("%s %v", BadDataClientCommitmentModel, docErr))
 	}
+	now := time.Now()
+	*docCommitment = docCommitment.Append(ClientCommitmentUpdatedAtName, bsonx.Time(now))
+	*docCommitment = docCommitment.Append(ClientCommitmentSeenAtName, bsonx.Time(now))
 
 	newCommitment := bsonx.Doc{
 		{"$set", bsonx.Document(*docCommitment)},
 	}
 
-	// search if client details for position already exists
+	// search if client commitment for position already exists with a different commitment
 	filterClientCommitment := bsonx.Doc{
 		{models.ClientCommitmentClientPositionName,
 			bsonx.Int32(docCommitment.Lookup(models.ClientCommitmentClientPositionName).Int32())},
+		{models.ClientCommitmentCommitmentName, bsonx.Document(bsonx.Doc{
+			{"$ne", bsonx.String(docCommitment.Lookup(models.ClientCommitmentCommitmentName).StringValue())}})},
 	}
 
 	// insert or update client details
@@ -295,11 +484,328 @@ func (d *DbMongo) SaveClientCommitment(commitment models.ClientCommitment) error
 	res := d.db.Collection(ColNameClientCommitment).FindOneAndUpdate(d.ctx, filterClientCommitment, newCommitment, opts)
 	resErr := res.Decode(&t)
 	if resErr != nil && resErr != mongo.ErrNoDocuments {
-		return errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentSave, resErr))
+		if isDuplicateKeyError(resErr) {
+			return true, d.saveClientCommitmentSeenTime(*docCommitment, now)
+		}
+		return false, errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentSave, resErr))
+	}
+	return false, nil
+}
+
+// Update time the client was last seen for an already recorded commitment
+func (d *DbMongo) saveClientCommitmentSeenTime(docCommitment bsonx.Doc, seenTime time.Time) error {
+	filterClientCommitment := bsonx.Doc{
+		{models.ClientCommitmentClientPositionName,
+			bsonx.Int32(docCommitment.Lookup(models.ClientCommitmentClientPositionName).Int32())},
+		{models.ClientCommitmentCommitmentName,
+			bsonx.String(docCommitment.Lookup(models.ClientCommitmentCommitmentName).StringValue())},
+	}
+	seenCommitment := bsonx.Doc{
+		{"$set", bsonx.Document(bsonx.Doc{{ClientCommitmentSeenAtName, bsonx.Time(seenTime)}})},
+	}
+	_, updateErr := d.db.Collection(ColNameClientCommitment).UpdateOne(d.ctx, filterClientCommitment, seenCommitment)
+	if updateErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentSave, updateErr))
+	}
+	return nil
+}
+
+// Get time of latest ClientCommitment update for client position
+// Returns zero time if no commitment has been received for the position
+func (d *DbMongo) getClientCommitmentUpdateTime(position int32) (time.Time, error) {
+	return d.getClientCommitmentTime(position, ClientCommitmentUpdatedAtName)
+}
+
+// Get time ClientCommitment for client position was last submitted
+// Falls back to the update time for commitments saved without a seen time
+func (d *DbMongo) getClientCommitmentSeenTime(position int32) (time.Time, error) {
+	return d.getClientCommitmentTime(position, ClientCommitmentSeenAtName, ClientCommitmentUpdatedAtName)
+}
+
+// Get ClientCommitment time for client position from the first time field found
+// Returns zero time if no commitment has been received for the position
+func (d *DbMongo) getClientCommitmentTime(position int32, fieldNames ...string) (time.Time, error) {
+	filterClientCommitment := bsonx.Doc{
+		{models.ClientCommitmentClientPositionName, bsonx.Int32(position)},
+	}
+
+	var commitmentDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameClientCommitment).FindOne(d.ctx, filterClientCommitment).Decode(&commitmentDoc)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return time.Time{}, nil
+		}
+		return time.Time{}, errors.New(fmt.Sprintf("%s %v", ErrorClientCommitmentGet, resErr))
+	}
+
+	for _, fieldName := range fieldNames {
+		if fieldTime, lookupErr := commitmentDoc.LookupErr(fieldName); lookupErr == nil {
+			return fieldTime.Time(), nil
+		}
+	}
+	return time.Time{}, nil // commitment saved without time
+}
+
+// Save staychain checkpoint txid to the Checkpoint collection
+// A single checkpoint document is maintained and replaced on each update
+func (d *DbMongo) saveCheckpoint(txid chainhash.Hash) error {
+	newCheckpoint := bsonx.Doc{
+		{"$set", bsonx.Document(bsonx.Doc{
+			{CheckpointTxidName, bsonx.String(txid.String())},
+			{CheckpointUpdatedAtName, bsonx.Time(time.Now())},
+		})},
+	}
+
+	// insert or update checkpoint
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameCheckpoint).FindOneAndUpdate(d.ctx, bsonx.Doc{}, newCheckpoint, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorCheckpointSave, resErr))
+	}
+	return nil
+}
+
+// Save commitment snapshot to the CommitmentSnapshot collection
+// Snapshots are immutable and only inserted if none exists for the merkle root
+func (d *DbMongo) saveCommitmentSnapshot(snapshot models.CommitmentSnapshot) error {
+	// get document representation of commitment snapshot
+	docSnapshot, docErr := models.GetDocumentFromModel(snapshot)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataSnapshotModel, docErr))
+	}
+
+	newSnapshot := bsonx.Doc{
+		{"$setOnInsert", bsonx.Document(*docSnapshot)},
+	}
+
+	// search if snapshot for merkle root already exists
+	filterSnapshot := bsonx.Doc{
+		{models.CommitmentSnapshotMerkleRootName, bsonx.String(snapshot.MerkleRoot.String())},
+	}
+
+	// insert snapshot if it does not exist
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameCommitmentSnapshot).FindOneAndUpdate(d.ctx, filterSnapshot, newSnapshot, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorSnapshotSave, resErr))
+	}
+	return nil
+}
+
+// Save attestation receipt to the AttestationReceipt collection
+// Any existing receipt for the attestation txid is replaced
+func (d *DbMongo) saveAttestationReceipt(receipt models.AttestationReceipt) error {
+	// get document representation of attestation receipt
+	docReceipt, docErr := models.GetDocumentFromModel(receipt)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataReceiptModel, docErr))
+	}
+
+	newReceipt := bsonx.Doc{
+		{"$set", bsonx.Document(*docReceipt)},
+	}
+
+	// search if receipt for attestation txid already exists
+	filterReceipt := bsonx.Doc{
+		{models.AttestationReceiptTxidName, bsonx.String(receipt.Txid.String())},
+	}
+
+	// insert or update attestation receipt
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameAttestationReceipt).FindOneAndUpdate(d.ctx, filterReceipt, newReceipt, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorReceiptSave, resErr))
+	}
+	return nil
+}
+
+// Save attestation schedule to the AttestationSchedule collection
+// A single schedule document is maintained and replaced on each update
+func (d *DbMongo) saveAttestationSchedule(schedule models.AttestationSchedule) error {
+	// get document representation of attestation schedule
+	docSchedule, docErr := models.GetDocumentFromModel(schedule)
+	if docErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", BadDataScheduleModel, docErr))
+	}
+
+	newSchedule := bsonx.Doc{
+		{"$set", bsonx.Document(*docSchedule)},
+	}
+
+	// insert or update schedule
+	var t bsonx.Doc
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetUpsert(true)
+	res := d.db.Collection(ColNameAttestationSchedule).FindOneAndUpdate(d.ctx, bsonx.Doc{}, newSchedule, opts)
+	resErr := res.Decode(&t)
+	if resErr != nil && resErr != mongo.ErrNoDocuments {
+		return errors.New(fmt.Sprintf("%s %v", ErrorScheduleSave, resErr))
 	}
 	return nil
 }
 
+// Save token submission to the TokenSubmission collection
+// Increments the submission count of the token and updates the latest
+// submission time, setting the first submission time on first insert
+func (d *DbMongo) saveTokenSubmission(token string, position int32, submitted time.Time) error {
+	filterToken := bsonx.Doc{{models.TokenSubmissionStatsTokenName, bsonx.String(token)}}
+	newSubmission := bsonx.Doc{
+		{"$inc", bsonx.Document(bsonx.Doc{{models.TokenSubmissionStatsCountName, bsonx.Int64(1)}})},
+		{"$set", bsonx.Document(bsonx.Doc{
+			{models.TokenSubmissionStatsClientPositionName, bsonx.Int32(position)},
+			{models.TokenSubmissionStatsLastSubmissionName, bsonx.Time(submitted)}})},
+		{"$setOnInsert", bsonx.Document(bsonx.Doc{
+			{models.TokenSubmissionStatsFirstSubmissionName, bsonx.Time(submitted)}})},
+	}
+
+	// insert or update token submission
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+	_, resErr := d.db.Collection(ColNameTokenSubmission).UpdateOne(d.ctx, filterToken, newSubmission, opts)
+	if resErr != nil {
+		return errors.New(fmt.Sprintf("%s %v", ErrorTokenSubmissionSave, resErr))
+	}
+	return nil
+}
+
+// Get attestation schedule from the AttestationSchedule collection
+// Returns empty schedule if no schedule has been published
+func (d *DbMongo) getAttestationSchedule() (models.AttestationSchedule, error) {
+	var scheduleDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameAttestationSchedule).FindOne(d.ctx, bsonx.Doc{}).Decode(&scheduleDoc)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return models.AttestationSchedule{}, nil
+		}
+		return models.AttestationSchedule{}, errors.New(fmt.Sprintf("%s %v", ErrorScheduleGet, resErr))
+	}
+
+	scheduleModel := &models.AttestationSchedule{}
+	modelErr := models.GetModelFromDocument(&scheduleDoc, scheduleModel)
+	if modelErr != nil {
+		return models.AttestationSchedule{}, errors.New(fmt.Sprintf("%s %v", BadDataScheduleModel, modelErr))
+	}
+	return *scheduleModel, nil
+}
+
+// Get submission stats of all tokens from the TokenSubmission collection
+// sorted by descending submission count
+func (d *DbMongo) getTokenSubmissionStats() ([]models.TokenSubmissionStats, error) {
+	sortFilter := bsonx.Doc{
+		{models.TokenSubmissionStatsCountName, bsonx.Int32(-1)},
+		{models.TokenSubmissionStatsTokenName, bsonx.Int32(1)}}
+	res, resErr := d.db.Collection(ColNameTokenSubmission).Find(d.ctx, bsonx.Doc{}, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.TokenSubmissionStats{},
+			errors.New(fmt.Sprintf("%s %v", ErrorTokenSubmissionGet, resErr))
+	}
+
+	// iterate through token submissions
+	var stats []models.TokenSubmissionStats
+	for res.Next(d.ctx) {
+		var statsDoc bsonx.Doc
+		if err := res.Decode(&statsDoc); err != nil {
+			return []models.TokenSubmissionStats{},
+				errors.New(fmt.Sprintf("%s %v", BadDataTokenSubmissionCol, err))
+		}
+		statsModel := &models.TokenSubmissionStats{}
+		modelErr := models.GetModelFromDocument(&statsDoc, statsModel)
+		if modelErr != nil {
+			return []models.TokenSubmissionStats{}, errors.New(fmt.Sprintf("%s %v", BadDataTokenSubmissionCol, modelErr))
+		}
+		stats = append(stats, *statsModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.TokenSubmissionStats{}, errors.New(fmt.Sprintf("%s %v", BadDataTokenSubmissionCol, err))
+	}
+	return stats, nil
+}
+
+// Get attestation receipt for txid from the AttestationReceipt collection
+// Returns empty receipt if no receipt has been recorded
+func (d *DbMongo) getAttestationReceipt(txid chainhash.Hash) (models.AttestationReceipt, error) {
+	filterReceipt := bsonx.Doc{
+		{models.AttestationReceiptTxidName, bsonx.String(txid.String())},
+	}
+
+	var receiptDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameAttestationReceipt).FindOne(d.ctx, filterReceipt).Decode(&receiptDoc)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return models.AttestationReceipt{}, nil
+		}
+		return models.AttestationReceipt{}, errors.New(fmt.Sprintf("%s %v", ErrorReceiptGet, resErr))
+	}
+
+	receiptModel := &models.AttestationReceipt{}
+	modelErr := models.GetModelFromDocument(&receiptDoc, receiptModel)
+	if modelErr != nil {
+		return models.AttestationReceipt{}, errors.New(fmt.Sprintf("%s %v", BadDataReceiptModel, modelErr))
+	}
+	return *receiptModel, nil
+}
+
+// Get commitment snapshot for merkle root from the CommitmentSnapshot collection
+// Returns empty snapshot if no snapshot has been recorded
+func (d *DbMongo) getCommitmentSnapshot(merkleRoot chainhash.Hash) (models.CommitmentSnapshot, error) {
+	filterSnapshot := bsonx.Doc{
+		{models.CommitmentSnapshotMerkleRootName, bsonx.String(merkleRoot.String())},
+	}
+
+	var snapshotDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameCommitmentSnapshot).FindOne(d.ctx, filterSnapshot).Decode(&snapshotDoc)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return models.CommitmentSnapshot{}, nil
+		}
+		return models.CommitmentSnapshot{}, errors.New(fmt.Sprintf("%s %v", ErrorSnapshotGet, resErr))
+	}
+
+	snapshotModel := &models.CommitmentSnapshot{}
+	modelErr := models.GetModelFromDocument(&snapshotDoc, snapshotModel)
+	if modelErr != nil {
+		return models.CommitmentSnapshot{}, errors.New(fmt.Sprintf("%s %v", BadDataSnapshotModel, modelErr))
+	}
+	return *snapshotModel, nil
+}
+
+// Get staychain checkpoint txid from the Checkpoint collection
+// Returns zero hash if no checkpoint has been recorded
+func (d *DbMongo) getCheckpoint() (chainhash.Hash, error) {
+	var checkpointDoc bsonx.Doc
+	resErr := d.db.Collection(ColNameCheckpoint).FindOne(d.ctx, bsonx.Doc{}).Decode(&checkpointDoc)
+	if resErr != nil {
+		if resErr == mongo.ErrNoDocuments {
+			return chainhash.Hash{}, nil
+		}
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", ErrorCheckpointGet, resErr))
+	}
+
+	txidVal, lookupErr := checkpointDoc.LookupErr(CheckpointTxidName)
+	if lookupErr != nil {
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", BadDataCheckpointCol, lookupErr))
+	}
+	txid, hashErr := chainhash.NewHashFromStr(txidVal.StringValue())
+	if hashErr != nil {
+		return chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", BadDataCheckpointCol, hashErr))
+	}
+	return *txid, nil
+}
+
+// Get client details for verifying signed client commitments
+func (d *DbMongo) getClientDetails() ([]models.ClientDetails, error) {
+	return d.GetClientDetails()
+}
+
 // Get latest ClientDetails document
 func (d *DbMongo) GetClientDetails() ([]models.ClientDetails, error) {
 	// sort by client position
@@ -398,6 +904,108 @@ func (d *DbMongo) getAttestationMerkleRoot(txid chainhash.Hash) (string, error)
 	return attestationDoc.Lookup(models.CommitmentMerkleRootName).StringValue(), nil
 }
 
+// Return merkle root of attestation with given txid hash
+func (d *DbMongo) GetAttestationMerkleRoot(txid chainhash.Hash) (string, error) {
+	return d.getAttestationMerkleRoot(txid)
+}
+
+// Return filter of AttestationInfo documents with time in the range [start, end]
+// that follow the last document of the previous page in (time, txid) order
+// No previous page is set if the txid of the last document is empty
+func attestationInfoPageFilter(start int64, end int64, after models.AttestationInfo) bsonx.Doc {
+	filterTime := bsonx.Doc{
+		{models.AttestationInfoTimeName, bsonx.Document(bsonx.Doc{
+			{"$gte", bsonx.Int64(start)},
+			{"$lte", bsonx.Int64(end)},
+		})},
+	}
+	if after.Txid == "" {
+		return filterTime
+	}
+	return append(filterTime, bsonx.Elem{"$or", bsonx.Array(bsonx.Arr{
+		bsonx.Document(bsonx.Doc{
+			{models.AttestationInfoTimeName, bsonx.Document(bsonx.Doc{{"$gt", bsonx.Int64(after.Time)}})},
+		}),
+		bsonx.Document(bsonx.Doc{
+			{models.AttestationInfoTimeName, bsonx.Int64(after.Time)},
+			{models.AttestationInfoTxidName, bsonx.Document(bsonx.Doc{{"$gt", bsonx.String(after.Txid)}})},
+		}),
+	})})
+}
+
+// Return a page of AttestationInfo documents with time in the range [start, end]
+// following the last document of the previous page, or from the start if unset
+// Results are sorted by time and txid and paginated by the last document instead
+// of skipping documents, so that large ranges can be iterated without loading
+// all documents in memory or rescanning previous pages on each query
+func (d *DbMongo) GetAttestationInfoPage(start int64, end int64, after models.AttestationInfo, limit int64) ([]models.AttestationInfo, error) {
+	sortFilter := bsonx.Doc{
+		{models.AttestationInfoTimeName, bsonx.Int32(1)},
+		{models.AttestationInfoTxidName, bsonx.Int32(1)},
+	}
+	opts := &options.FindOptions{Sort: sortFilter}
+	opts.SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameAttestationInfo).Find(d.ctx, attestationInfoPageFilter(start, end, after), opts)
+	if resErr != nil {
+		return []models.AttestationInfo{},
+			errors.New(fmt.Sprintf("%s %v", ErrorAttestationInfoGet, resErr))
+	}
+
+	// iterate through attestation info documents
+	var infos []models.AttestationInfo
+	for res.Next(d.ctx) {
+		var infoDoc bsonx.Doc
+		if err := res.Decode(&infoDoc); err != nil {
+			return []models.AttestationInfo{},
+				errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoCol, err))
+		}
+		infoModel := &models.AttestationInfo{}
+		modelErr := models.GetModelFromDocument(&infoDoc, infoModel)
+		if modelErr != nil {
+			return []models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoCol, modelErr))
+		}
+		infos = append(infos, *infoModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.AttestationInfo{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationInfoCol, err))
+	}
+	return infos, nil
+}
+
+// Return a page of confirmed Attestation documents with txid and merkle root
+// Results are sorted by insertion time and paginated using skip and limit so
+// that all confirmed attestations can be iterated, e.g. for auditing the db
+func (d *DbMongo) GetConfirmedAttestationPage(skip int64, limit int64) ([]models.AttestationBSON, error) {
+	confirmedFilter := bsonx.Doc{{models.AttestationConfirmedName, bsonx.Boolean(true)}}
+	sortFilter := bsonx.Doc{
+		{models.AttestationInsertedAtName, bsonx.Int32(1)},
+		{models.AttestationTxidName, bsonx.Int32(1)},
+	}
+	opts := &options.FindOptions{Sort: sortFilter}
+	opts.SetSkip(skip)
+	opts.SetLimit(limit)
+	res, resErr := d.db.Collection(ColNameAttestation).Find(d.ctx, confirmedFilter, opts)
+	if resErr != nil {
+		return []models.AttestationBSON{},
+			errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+
+	// iterate through attestation documents
+	var attestations []models.AttestationBSON
+	for res.Next(d.ctx) {
+		var attestation models.AttestationBSON
+		if err := res.Decode(&attestation); err != nil {
+			return []models.AttestationBSON{},
+				errors.New(fmt.Sprintf("%s %v", BadDataAttestationCol, err))
+		}
+		attestations = append(attestations, attestation)
+	}
+	if err := res.Err(); err != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationCol, err))
+	}
+	return attestations, nil
+}
+
 // Return Commitment from MerkleCommitment commitments for attestation with given txid hash
 func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
 	// get merkle root of attestation
@@ -408,8 +1016,12 @@ func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 		return []models.CommitmentMerkleCommitment{}, nil
 	}
 
-	// filter MerkleCommitment collection by merkle_root and sort for client position
-	sortFilter := bsonx.Doc{{models.CommitmentClientPositionName, bsonx.Int32(1)}}
+	// filter MerkleCommitment collection by merkle_root and sort for leaf index
+	// commitments stored without a leaf index are sorted by client position
+	sortFilter := bsonx.Doc{
+		{models.CommitmentLeafIndexName, bsonx.Int32(1)},
+		{models.CommitmentClientPositionName, bsonx.Int32(1)},
+	}
 	filterMerkleRoot := bsonx.Doc{{models.CommitmentMerkleRootName, bsonx.String(merkleRoot)}}
 	res, resErr := d.db.Collection(ColNameMerkleCommitment).Find(d.ctx, filterMerkleRoot, &options.FindOptions{Sort: sortFilter})
 	if resErr != nil {
@@ -441,6 +1053,102 @@ func (d *DbMongo) getAttestationMerkleCommitments(txid chainhash.Hash) ([]models
 	return merkleCommitments, nil
 }
 
+// Return merkle commitments from MerkleCommitment collection with commitment value
+// matching the given commitment, using the index on the commitment field
+func (d *DbMongo) getMerkleCommitmentsForCommitment(commitment chainhash.Hash) ([]models.CommitmentMerkleCommitment, error) {
+	filterCommitment := bsonx.Doc{{models.CommitmentCommitmentName, bsonx.String(commitment.String())}}
+	res, resErr := d.db.Collection(ColNameMerkleCommitment).Find(d.ctx, filterCommitment)
+	if resErr != nil {
+		return []models.CommitmentMerkleCommitment{},
+			errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentGet, resErr))
+	}
+
+	// fetch commitments
+	var merkleCommitments []models.CommitmentMerkleCommitment
+	for res.Next(d.ctx) {
+		var commitmentDoc bsonx.Doc
+		if err := res.Decode(&commitmentDoc); err != nil {
+			return []models.CommitmentMerkleCommitment{},
+				errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, err))
+		}
+		commitmentModel := &models.CommitmentMerkleCommitment{}
+		modelErr := models.GetModelFromDocument(&commitmentDoc, commitmentModel)
+		if modelErr != nil {
+			return []models.CommitmentMerkleCommitment{},
+				errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, modelErr))
+		}
+		merkleCommitments = append(merkleCommitments, *commitmentModel)
+	}
+	if err := res.Err(); err != nil {
+		return []models.CommitmentMerkleCommitment{},
+			errors.New(fmt.Sprintf("%s %v", BadDataMerkleCommitmentCol, err))
+	}
+	return merkleCommitments, nil
+}
+
+// Return txids of attestations from Attestation collection with the given
+// merkle root and confirmed flag, ordered by insertion time
+func (d *DbMongo) getAttestationTxidsForMerkleRoot(merkleRoot chainhash.Hash, confirmed bool) ([]chainhash.Hash, error) {
+	sortFilter := bsonx.Doc{{models.AttestationInsertedAtName, bsonx.Int32(1)}}
+	filterAttestation := bsonx.Doc{
+		{models.AttestationMerkleRootName, bsonx.String(merkleRoot.String())},
+		{models.AttestationConfirmedName, bsonx.Boolean(confirmed)},
+	}
+	res, resErr := d.db.Collection(ColNameAttestation).Find(d.ctx, filterAttestation, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+
+	// fetch attestation txids
+	var txids []chainhash.Hash
+	for res.Next(d.ctx) {
+		var attestationDoc bsonx.Doc
+		if err := res.Decode(&attestationDoc); err != nil {
+			return []chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+		}
+		attestationModel := &models.Attestation{}
+		modelErr := models.GetModelFromDocument(&attestationDoc, attestationModel)
+		if modelErr != nil {
+			return []chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, modelErr))
+		}
+		txids = append(txids, attestationModel.Txid)
+	}
+	if err := res.Err(); err != nil {
+		return []chainhash.Hash{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+	}
+	return txids, nil
+}
+
+// Return confirmed attestations inserted within the time window [from, to)
+// sorted by insert time, used to find the attestations covering a period
+func (d *DbMongo) getConfirmedAttestationsForWindow(from time.Time, to time.Time) ([]models.AttestationBSON, error) {
+	sortFilter := bsonx.Doc{{models.AttestationInsertedAtName, bsonx.Int32(1)}}
+	filterAttestation := bsonx.Doc{
+		{models.AttestationConfirmedName, bsonx.Boolean(true)},
+		{models.AttestationInsertedAtName, bsonx.Document(bsonx.Doc{
+			{"$gte", bsonx.Time(from)},
+			{"$lt", bsonx.Time(to)},
+		})},
+	}
+	res, resErr := d.db.Collection(ColNameAttestation).Find(d.ctx, filterAttestation, &options.FindOptions{Sort: sortFilter})
+	if resErr != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+
+	var attestations []models.AttestationBSON
+	for res.Next(d.ctx) {
+		var attestation models.AttestationBSON
+		if err := res.Decode(&attestation); err != nil {
+			return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationCol, err))
+		}
+		attestations = append(attestations, attestation)
+	}
+	if err := res.Err(); err != nil {
+		return []models.AttestationBSON{}, errors.New(fmt.Sprintf("%s %v", BadDataAttestationCol, err))
+	}
+	return attestations, nil
+}
+
 // Return latest commitments from MerkleCommitment collection
 func (d *DbMongo) getClientCommitments() ([]models.ClientCommitment, error) {
 
@@ -472,3 +1180,85 @@ func (d *DbMongo) getClientCommitments() ([]models.ClientCommitment, error) {
 	}
 	return latestCommitments, nil
 }
+
+// Delete merkle commitments, merkle proofs and commitment snapshots of merkle
+// roots that have only been attested by unconfirmed attestations inserted
+// before the time provided, along with these unconfirmed attestations
+// Merkle roots of confirmed attestations and of the latest unconfirmed
+// attestation are always kept. Returns the number of merkle commitments deleted
+func (d *DbMongo) compactMerkleCommitments(before time.Time) (int64, error) {
+	// keep latest unconfirmed attestation that might still be confirmed
+	latestRoot, latestErr := d.getLatestAttestationMerkleRoot(false)
+	if latestErr != nil {
+		return 0, latestErr
+	}
+
+	// get merkle roots of stale unconfirmed attestations
+	filterStale := bsonx.Doc{
+		{models.AttestationConfirmedName, bsonx.Boolean(false)},
+		{models.AttestationInsertedAtName, bsonx.Document(bsonx.Doc{{"$lt", bsonx.Time(before)}})},
+	}
+	res, resErr := d.db.Collection(ColNameAttestation).Find(d.ctx, filterStale)
+	if resErr != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", ErrorAttestationGet, resErr))
+	}
+	staleRoots := make(map[string]bool)
+	for res.Next(d.ctx) {
+		var attestationDoc bsonx.Doc
+		if err := res.Decode(&attestationDoc); err != nil {
+			return 0, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+		}
+		staleRoots[attestationDoc.Lookup(models.AttestationMerkleRootName).StringValue()] = true
+	}
+	if err := res.Err(); err != nil {
+		return 0, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, err))
+	}
+
+	var deleted int64
+	for root := range staleRoots {
+		if root == latestRoot {
+			continue
+		}
+
+		// skip merkle roots that have been confirmed by any attestation
+		rootHash, rootErr := chainhash.NewHashFromStr(root)
+		if rootErr != nil {
+			return deleted, errors.New(fmt.Sprintf("%s %v", BadDataAttestationModel, rootErr))
+		}
+		txids, txidsErr := d.getAttestationTxidsForMerkleRoot(*rootHash, true)
+		if txidsErr != nil {
+			return deleted, txidsErr
+		} else if len(txids) > 0 {
+			continue
+		}
+
+		// delete merkle root records
+		commitmentRes, commitmentErr := d.db.Collection(ColNameMerkleCommitment).DeleteMany(d.ctx,
+			bsonx.Doc{{models.CommitmentMerkleRootName, bsonx.String(root)}})
+		if commitmentErr != nil {
+			return deleted, errors.New(fmt.Sprintf("%s %v", ErrorMerkleCommitmentDelete, commitmentErr))
+		}
+		deleted += commitmentRes.DeletedCount
+
+		_, proofErr := d.db.Collection(ColNameMerkleProof).DeleteMany(d.ctx,
+			bsonx.Doc{{models.ProofMerkleRootName, bsonx.String(root)}})
+		if proofErr != nil {
+			return deleted, errors.New(fmt.Sprintf("%s %v", ErrorMerkleProofDelete, proofErr))
+		}
+
+		_, snapshotErr := d.db.Collection(ColNameCommitmentSnapshot).DeleteMany(d.ctx,
+			bsonx.Doc{{models.CommitmentSnapshotMerkleRootName, bsonx.String(root)}})
+		if snapshotErr != nil {
+			return deleted, errors.New(fmt.Sprintf("%s %v", ErrorSnapshotDelete, snapshotErr))
+		}
+
+		_, attestationErr := d.db.Collection(ColNameAttestation).DeleteMany(d.ctx, bsonx.Doc{
+			{models.AttestationMerkleRootName, bsonx.String(root)},
+			{models.AttestationConfirmedName, bsonx.Boolean(false)},
+		})
+		if attestationErr != nil {
+			return deleted, errors.New(fmt.Sprintf("%s %v", ErrorAttestationDelete, attestationErr))
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+// Test mongo client options are set from db config
+func TestDbMongoClientOptions(t *testing.T) {
+	dbConfig := config.DbConfig{
+		User:                 "user",
+		Password:             "pass",
+		Host:                 "localhost",
+		Port:                 "27017",
+		Name:                 "mainstay",
+		MaxPoolSize:          20,
+		SocketTimeoutSeconds: 10,
+		ReadConcern:          "majority",
+		WriteConcern:         "majority",
+		ReadPreference:       "secondaryPreferred",
+	}
+	clientOptions := dbClientOptions(dbConfig)
+	assert.Equal(t, []string{"localhost:27017"}, clientOptions.Hosts)
+	assert.Equal(t, uint64(20), *clientOptions.MaxPoolSize)
+	assert.Equal(t, 10*time.Second, *clientOptions.SocketTimeout)
+	assert.Equal(t, readconcern.New(readconcern.Level("majority")), clientOptions.ReadConcern)
+	assert.Equal(t, writeconcern.New(writeconcern.WMajority()), clientOptions.WriteConcern)
+	assert.Equal(t, readpref.SecondaryPreferred(), clientOptions.ReadPreference)
+
+	// test numeric write concern
+	dbConfig.WriteConcern = "2"
+	clientOptions = dbClientOptions(dbConfig)
+	assert.Equal(t, writeconcern.New(writeconcern.W(2)), clientOptions.WriteConcern)
+
+	// test unset connection pool settings use driver defaults
+	clientOptions = dbClientOptions(config.DbConfig{
+		User:     "user",
+		Password: "pass",
+		Host:     "localhost",
+		Port:     "27017",
+		Name:     "mainstay",
+	})
+	assert.Equal(t, (*uint64)(nil), clientOptions.MaxPoolSize)
+	assert.Equal(t, (*time.Duration)(nil), clientOptions.SocketTimeout)
+	assert.Equal(t, (*readconcern.ReadConcern)(nil), clientOptions.ReadConcern)
+	assert.Equal(t, (*writeconcern.WriteConcern)(nil), clientOptions.WriteConcern)
+	assert.Equal(t, (*readpref.ReadPref)(nil), clientOptions.ReadPreference)
+}
+
+// Test attestation info page filter follows the last document of the previous page
+func TestDbMongoAttestationInfoPageFilter(t *testing.T) {
+	filterTime := bsonx.Doc{
+		{models.AttestationInfoTimeName, bsonx.Document(bsonx.Doc{
+			{"$gte", bsonx.Int64(1546300800)},
+			{"$lte", bsonx.Int64(1554163199)},
+		})},
+	}
+
+	// test first page filtered by time range only
+	filter := attestationInfoPageFilter(1546300800, 1554163199, models.AttestationInfo{})
+	assert.Equal(t, filterTime, filter)
+
+	// test next page starts after the time and txid of the last document
+	last := models.AttestationInfo{
+		Txid: "11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7",
+		Time: 1546300900,
+	}
+	filter = attestationInfoPageFilter(1546300800, 1554163199, last)
+	assert.Equal(t, 2, len(filter))
+	assert.Equal(t, filterTime[0], filter[0])
+	assert.Equal(t, bsonx.Elem{"$or", bsonx.Array(bsonx.Arr{
+		bsonx.Document(bsonx.Doc{
+			{models.AttestationInfoTimeName, bsonx.Document(bsonx.Doc{{"$gt", bsonx.Int64(1546300900)}})},
+		}),
+		bsonx.Document(bsonx.Doc{
+			{models.AttestationInfoTimeName, bsonx.Int64(1546300900)},
+			{models.AttestationInfoTxidName, bsonx.Document(bsonx.Doc{{"$gt", bsonx.String(last.Txid)}})},
+		}),
+	})}, filter[1])
+}
+
+// Test merkle leaf filter matching documents by leaf index, or by client
+// position for documents stored without a leaf index
+func TestDbMongoMerkleLeafFilter(t *testing.T) {
+	root := "bb088c106b3379b64243c1a4915f72a847d45c7513b152cad583eb3c0a1063c2"
+	assert.Equal(t, bsonx.Doc{
+		{models.CommitmentMerkleRootName, bsonx.String(root)},
+		{"$or", bsonx.Array(bsonx.Arr{
+			bsonx.Document(bsonx.Doc{
+				{models.CommitmentLeafIndexName, bsonx.Int32(2)},
+			}),
+			bsonx.Document(bsonx.Doc{
+				{models.CommitmentLeafIndexName, bsonx.Document(bsonx.Doc{{"$exists", bsonx.Boolean(false)}})},
+				{models.CommitmentClientPositionName, bsonx.Int32(2)},
+			}),
+		})},
+	}, merkleLeafFilter(root, 2))
+
+	// test proof field names filtered on match the commitment field names
+	assert.Equal(t, models.CommitmentMerkleRootName, models.ProofMerkleRootName)
+	assert.Equal(t, models.CommitmentClientPositionName, models.ProofClientPositionName)
+	assert.Equal(t, models.CommitmentLeafIndexName, models.ProofLeafIndexName)
+}
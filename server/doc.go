@@ -3,5 +3,12 @@ Package attestation implements the MainStay server
 
 Implemented using an Server structure that runs a main process Server that handles
 responding to requests from Attestation service and storing latest Attestations / Commitments through a Db interface
+
+Signed client commitments can also be received through a CommitmentSource interface - currently supporting zmq and nats
+and client commitments can be checked against custom rules by registering CommitmentValidator implementations per client position, auth token or client commitment kind,
+while submission counts and times of each auth token are tracked in the Db for operators to spot abusive or broken clients
+
+Reads of the latest attestation and client commitment can optionally be cached in memory to reduce the load on the Db,
+with the cached latest attestation updated on each attestation stored through the Server
 */
 package server
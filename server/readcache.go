@@ -0,0 +1,181 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to cache server reads of the latest attestation and
+// client commitment in memory, so that frequent reads, e.g. by an API
+// serving many clients, do not hit the db on every call
+// The latest attestation merkle root is cached per confirmed flag and
+// written through on each attestation saved by the server, while the
+// commitment of the latest confirmed attestation is cached by txid as it
+// no longer changes once confirmed. The client commitment is invalidated on
+// client commitment writes by the server, but as client commitments may also
+// be written to the db directly, all cached reads except the confirmed
+// commitment are refreshed from the db once older than the maximum age
+
+// cachedRoot structure
+// Latest attestation merkle root cached for a confirmed flag
+type cachedRoot struct {
+	// merkle root and whether an attestation was found
+	root  chainhash.Hash
+	found bool
+
+	// time the merkle root was cached
+	cachedAt time.Time
+}
+
+// readCache structure
+// In memory cache of the server reads - nil if disabled
+type readCache struct {
+	// mutex guarding cache access
+	mutex sync.Mutex
+
+	// age after which cached reads are refreshed from the db
+	maxAge time.Duration
+
+	// latest attestation merkle root by confirmed flag
+	latestRoots map[bool]cachedRoot
+
+	// txid and commitment of the latest confirmed attestation
+	confirmedTxid       chainhash.Hash
+	confirmedCommitment *models.Commitment
+
+	// latest client commitment and time it was cached
+	clientCommitment   *models.Commitment
+	clientCommitmentAt time.Time
+}
+
+// Return new read cache with the maximum age provided
+func newReadCache(maxAge time.Duration) *readCache {
+	return &readCache{maxAge: maxAge, latestRoots: make(map[bool]cachedRoot)}
+}
+
+// Return cached latest attestation merkle root for the confirmed flag
+// The last return value is false on a cache miss or if the cache is disabled
+func (c *readCache) getLatestRoot(confirmed bool, now time.Time) (chainhash.Hash, bool, bool) {
+	if c == nil {
+		return chainhash.Hash{}, false, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.latestRoots[confirmed]
+	if !ok || now.Sub(cached.cachedAt) >= c.maxAge {
+		return chainhash.Hash{}, false, false
+	}
+	return cached.root, cached.found, true
+}
+
+// Cache latest attestation merkle root read from the db for the confirmed flag
+func (c *readCache) setLatestRoot(confirmed bool, root chainhash.Hash, found bool, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.latestRoots[confirmed] = cachedRoot{root, found, now}
+}
+
+// Update cache with an attestation saved to the db
+// A confirmed attestation becomes the latest confirmed attestation and is
+// no longer the latest unconfirmed attestation, which is unknown until read
+// from the db again, while an unconfirmed attestation becomes the latest
+// unconfirmed attestation without affecting the latest confirmed one
+// The confirmed commitment is cached as read back from the db merkle
+// commitments, i.e. from the leaves in leaf order with their client positions
+func (c *readCache) updateLatestAttestation(attestation models.Attestation, commitment models.Commitment, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	root := commitment.GetCommitmentHash()
+	if attestation.Confirmed {
+		c.latestRoots[true] = cachedRoot{root, true, now}
+		delete(c.latestRoots, false)
+
+		confirmedCommitment, commitmentErr := commitmentFromMerkleCommitments(commitment.GetMerkleCommitments())
+		if commitmentErr != nil {
+			c.confirmedCommitment = nil
+			return
+		}
+		c.confirmedTxid = attestation.Txid
+		c.confirmedCommitment = &confirmedCommitment
+	} else {
+		c.latestRoots[false] = cachedRoot{root, true, now}
+	}
+}
+
+// Invalidate cached latest attestation merkle roots, e.g. when
+// an attestation write fails and the db state is not known
+func (c *readCache) invalidateLatestAttestation() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.latestRoots = make(map[bool]cachedRoot)
+}
+
+// Return cached commitment of the attestation txid, if this is the latest
+// confirmed attestation. The last return value is false on a cache miss
+func (c *readCache) getAttestationCommitment(txid chainhash.Hash) (models.Commitment, bool) {
+	if c == nil {
+		return models.Commitment{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.confirmedCommitment == nil || c.confirmedTxid != txid {
+		return models.Commitment{}, false
+	}
+	return *c.confirmedCommitment, true
+}
+
+// Return cached latest client commitment
+// The last return value is false on a cache miss or if the cache is disabled
+func (c *readCache) getClientCommitment(now time.Time) (models.Commitment, bool) {
+	if c == nil {
+		return models.Commitment{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.clientCommitment == nil || now.Sub(c.clientCommitmentAt) >= c.maxAge {
+		return models.Commitment{}, false
+	}
+	return *c.clientCommitment, true
+}
+
+// Cache latest client commitment read from the db
+func (c *readCache) setClientCommitment(commitment models.Commitment, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clientCommitment = &commitment
+	c.clientCommitmentAt = now
+}
+
+// Invalidate cached latest client commitment on client commitment writes
+func (c *readCache) invalidateClientCommitment() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clientCommitment = nil
+}
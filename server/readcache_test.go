@@ -0,0 +1,129 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mainstay/clock"
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Server read cache of latest attestation and client commitment
+func TestServerReadCache(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, 60})
+	fakeClock := clock.NewClockFake(time.Unix(1542121293, 0))
+	server.SetClock(fakeClock)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// Test no attestation yet cached
+	_, attested, lookupErr := server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, false, attested)
+	dbFake.SetUnavailable(true)
+	_, attested, lookupErr = server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, false, attested)
+	dbFake.SetUnavailable(false)
+
+	// Test unconfirmed attestation cached as latest unconfirmed only
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	dbFake.SetUnavailable(true)
+	hash, lookupErr := server.GetLatestAttestationCommitmentHash(false)
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), hash)
+	_, attested, lookupErr = server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, false, attested)
+	_, cached := server.cache.getAttestationCommitment(*txid)
+	assert.Equal(t, false, cached)
+
+	// Test confirmed attestation cached as latest confirmed and
+	// latest unconfirmed read from the db again
+	attestation.Confirmed = true
+	dbFake.SetUnavailable(false)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	dbFake.SetUnavailable(true)
+	hash, lookupErr = server.GetLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), hash)
+	_, lookupErr = server.GetLatestAttestationCommitmentHash(false)
+	assert.Equal(t, errors.New(ErrorDbFakeUnavailable), lookupErr)
+	dbFake.SetUnavailable(false)
+	hash, lookupErr = server.GetLatestAttestationCommitmentHash(false)
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, chainhash.Hash{}, hash)
+
+	// Test confirmed attestation commitment served from the cache
+	merkleCommitments := dbFake.merkleCommitments
+	dbFake.merkleCommitments = nil
+	cachedCommitment, commitmentErr := server.GetAttestationCommitment(*txid)
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, *commitment, cachedCommitment)
+	otherTxid, _ := chainhash.NewHashFromStr("21111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	cachedCommitment, commitmentErr = server.GetAttestationCommitment(*otherTxid)
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, models.Commitment{}, cachedCommitment)
+	dbFake.merkleCommitments = merkleCommitments
+
+	// Test latest attestation refreshed from the db after the cache age
+	fakeClock.Advance(60 * time.Second)
+	dbFake.SetUnavailable(true)
+	_, lookupErr = server.GetLatestAttestationCommitmentHash()
+	assert.Equal(t, errors.New(ErrorDbFakeUnavailable), lookupErr)
+	dbFake.SetUnavailable(false)
+
+	// Test client commitment cached until saved through the server
+	commitment0, _ := models.NewCommitment([]chainhash.Hash{*hash0})
+	commitment1, _ := models.NewCommitment([]chainhash.Hash{*hash1})
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash0, 0}})
+	latestCommitment, latestErr := server.GetClientCommitment()
+	assert.Equal(t, nil, latestErr)
+	assert.Equal(t, commitment0.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash1, 0}})
+	latestCommitment, _ = server.GetClientCommitment()
+	assert.Equal(t, commitment0.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash2, 1})
+	assert.Equal(t, nil, saveErr)
+	latestCommitment, _ = server.GetClientCommitment()
+	expectedCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash1, *hash2})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+
+	// Test client commitment written to the db directly refreshed after the cache age
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash0, 0}})
+	fakeClock.Advance(59 * time.Second)
+	latestCommitment, _ = server.GetClientCommitment()
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+	fakeClock.Advance(time.Second)
+	latestCommitment, _ = server.GetClientCommitment()
+	assert.Equal(t, commitment0.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+
+	// Test reads not cached by default
+	server = NewServer(dbFake)
+	latestCommitment, _ = server.GetClientCommitment()
+	assert.Equal(t, commitment0.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash1, 0}})
+	latestCommitment, _ = server.GetClientCommitment()
+	assert.Equal(t, commitment1.GetCommitmentHash(), latestCommitment.GetCommitmentHash())
+	assert.Equal(t, (*readCache)(nil), server.cache)
+}
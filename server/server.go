@@ -5,22 +5,243 @@
 package server
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"mainstay/clock"
+	"mainstay/config"
 	"mainstay/models"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// error consts
+const (
+	ErrorClientCommitmentTooFrequent = "client commitment received too soon after previous commitment"
+	ErrorClientPositionDuplicate     = "duplicate client position in client commitments"
+	ErrorClientPositionInvalid       = "invalid client position in client commitments"
+	ErrorClientPositionReserved      = "client position reserved for override commitments"
+	ErrorClientPositionOperator      = "client position reserved for operator commitments"
+	ErrorOverrideDisabled            = "override commitments disabled - no override position set"
+	ErrorClientPositionTreeWidth     = "client position exceeds commitment tree width"
+	ErrorClientPositionMax           = "client position exceeds maximum client position"
+	ErrorSignedCommitmentSize        = "signed client commitment exceeds max payload size"
+	ErrorReceiptKeyMissing           = "attestation receipts disabled - no receipt key set"
+	ErrorReceiptUnconfirmed          = "attestation receipts require a confirmed attestation"
+
+	InfoClientCommitmentRecorded = "client commitment already recorded"
+
+	WarningClientCommitmentExpired = "Warning - Client commitment expired - excluded from commitment tree"
+	WarningCompactionFailed        = "Warning - Merkle commitment compaction failed"
+	WarningReceiptKeyInvalid       = "Warning - Invalid receipt key - attestation receipts disabled"
+	WarningLeafOrderingInvalid     = "Warning - Invalid leaf ordering - leaves ordered by client position"
+	WarningLeafOrderingChanged     = "Warning - Leaf ordering changed since latest attestation - merkle root and proofs not comparable"
+	WarningTokenSubmissionFailed   = "Warning - Token submission stats not updated"
+	WarningClientPositionSkipped   = "Warning - Invalid client position - excluded from commitment tree"
+	WarningOverridePositionMax     = "Warning - Override position above max client position - override disabled"
+	WarningOperatorPositionMax     = "Warning - Operator position above max client position - operator commitments disabled"
+)
+
+// default server config values
+const (
+	// minimum interval between commitments of a client - disabled by default
+	DefaultCommitmentInterval = 0 * time.Second
+
+	// maximum size in bytes of signed client commitment messages
+	DefaultMaxPayloadSize = 4096
+
+	// interval between merkle commitment compaction runs
+	DefaultCompactionInterval = 1 * time.Hour
+
+	// maximum client position accepted - bounds the commitment tree size
+	DefaultMaxClientPosition = 65535
 )
 
+// AttestationCommitmentProof structure
+// Attestation txid along with the merkle proof of a
+// commitment included in the attestation merkle root
+type AttestationCommitmentProof struct {
+	Txid  chainhash.Hash
+	Proof models.CommitmentMerkleProof
+}
+
+// AttestationPositionCommitment structure
+// Confirmed attestation along with the client commitment at a position
+// in the commitment snapshot of the attestation, zero hash if the position
+// had no commitment. Snapshot is false if no snapshot of the attestation
+// was recorded and the commitment at the position cannot be shown
+type AttestationPositionCommitment struct {
+	Txid       chainhash.Hash
+	MerkleRoot chainhash.Hash
+	InsertedAt time.Time
+	Snapshot   bool
+	Commitment chainhash.Hash
+	UpdatedAt  time.Time
+}
+
+// Check whether a commitment is excluded from all the attestations provided
+// Exclusion can only be shown if every attestation has a commitment snapshot
+// and none of the snapshots has the commitment at the position
+func IsCommitmentExcluded(attestations []AttestationPositionCommitment, commitment chainhash.Hash) bool {
+	for _, attestation := range attestations {
+		if !attestation.Snapshot || attestation.Commitment == commitment {
+			return false
+		}
+	}
+	return true
+}
+
 // Server structure
 // Stores information on the latest attestation and commitment
 // Methods to get latest state by attestation service
 type Server struct {
 	// underlying database interface
 	dbInterface Db
+
+	// minimum interval between consecutive commitments of a client
+	commitmentInterval time.Duration
+
+	// client position reserved for override commitments - negative if disabled
+	overridePosition int32
+
+	// fixed number of commitment tree leaves - zero if disabled
+	treeWidth int32
+
+	// maximum client position accepted
+	maxClientPosition int32
+
+	// age after which client commitments are excluded - zero if disabled
+	commitmentExpiry time.Duration
+
+	// maximum size in bytes of signed client commitment messages
+	maxPayloadSize int
+
+	// age after which records of unconfirmed merkle roots are compacted - zero if disabled
+	retention time.Duration
+
+	// interval between compaction runs
+	compactionInterval time.Duration
+
+	// client position reserved for the operator commitment - negative if disabled
+	operatorPosition int32
+
+	// operator key signing attestation receipts - nil if disabled
+	receiptKey *btcec.PrivateKey
+
+	// ordering of client commitments in the commitment tree leaves
+	leafOrdering string
+
+	// prefix of the message signed by clients for each commitment
+	messagePrefix string
+
+	// durable buffer of db writes that failed - nil if disabled
+	writeBuffer *writeBuffer
+
+	// clock used for commitment timing - wall-clock unless set for testing
+	clock clock.Clock
+
+	// custom validators of client commitments - no-op by default
+	validators *commitmentValidators
+
+	// in memory cache of latest attestation and commitment reads - nil if disabled
+	cache *readCache
 }
 
 // NewServer returns a pointer to an Server instance
-func NewServer(dbInterface Db) *Server {
-	return &Server{dbInterface}
+// Optionally server config can be provided to set commitment handling options
+func NewServer(dbInterface Db, serverConfig ...config.ServerConfig) *Server {
+	commitmentInterval := DefaultCommitmentInterval
+	overridePosition := int32(-1)
+	treeWidth := int32(0)
+	maxClientPosition := int32(DefaultMaxClientPosition)
+	commitmentExpiry := time.Duration(0)
+	maxPayloadSize := DefaultMaxPayloadSize
+	retention := time.Duration(0)
+	compactionInterval := DefaultCompactionInterval
+	operatorPosition := int32(-1)
+	var receiptKey *btcec.PrivateKey
+	leafOrdering := config.LeafOrderingPosition
+	messagePrefix := ""
+	var buffer *writeBuffer
+	var cache *readCache
+	if len(serverConfig) > 0 {
+		if serverConfig[0].CommitmentIntervalSeconds > 0 {
+			commitmentInterval = time.Duration(serverConfig[0].CommitmentIntervalSeconds) * time.Second
+		}
+		if serverConfig[0].OverridePosition >= 0 {
+			overridePosition = int32(serverConfig[0].OverridePosition)
+		}
+		if serverConfig[0].TreeWidth > 0 {
+			treeWidth = int32(serverConfig[0].TreeWidth)
+		}
+		if serverConfig[0].MaxClientPosition >= 0 {
+			maxClientPosition = int32(serverConfig[0].MaxClientPosition)
+		}
+		if serverConfig[0].CommitmentExpirySeconds > 0 {
+			commitmentExpiry = time.Duration(serverConfig[0].CommitmentExpirySeconds) * time.Second
+		}
+		if serverConfig[0].MaxPayloadBytes > 0 {
+			maxPayloadSize = serverConfig[0].MaxPayloadBytes
+		}
+		if serverConfig[0].RetentionSeconds > 0 {
+			retention = time.Duration(serverConfig[0].RetentionSeconds) * time.Second
+		}
+		if serverConfig[0].CompactionIntervalSeconds > 0 {
+			compactionInterval = time.Duration(serverConfig[0].CompactionIntervalSeconds) * time.Second
+		}
+		if serverConfig[0].OperatorPosition >= 0 {
+			operatorPosition = int32(serverConfig[0].OperatorPosition)
+		}
+		if serverConfig[0].ReceiptKey != "" {
+			wif, wifErr := btcutil.DecodeWIF(serverConfig[0].ReceiptKey)
+			if wifErr != nil {
+				log.Printf("%s %v\n", WarningReceiptKeyInvalid, wifErr)
+			} else {
+				receiptKey = wif.PrivKey
+			}
+		}
+		if serverConfig[0].LeafOrdering == config.LeafOrderingSubmission {
+			leafOrdering = config.LeafOrderingSubmission
+		} else if serverConfig[0].LeafOrdering != "" && serverConfig[0].LeafOrdering != config.LeafOrderingPosition {
+			log.Printf("%s (%s)\n", WarningLeafOrderingInvalid, serverConfig[0].LeafOrdering)
+		}
+		messagePrefix = serverConfig[0].CommitmentMessagePrefix
+		if serverConfig[0].WriteBufferPath != "" {
+			var bufferErr error
+			buffer, bufferErr = newWriteBuffer(serverConfig[0].WriteBufferPath)
+			if bufferErr != nil {
+				log.Printf("%s (%s) %v\n", WarningWriteBufferLoad, serverConfig[0].WriteBufferPath, bufferErr)
+			}
+		}
+		if serverConfig[0].ReadCacheSeconds > 0 {
+			cache = newReadCache(time.Duration(serverConfig[0].ReadCacheSeconds) * time.Second)
+		}
+	}
+	// reserved positions are client positions and cannot exceed the maximum
+	if overridePosition > maxClientPosition {
+		log.Printf("%s (%d > %d)\n", WarningOverridePositionMax, overridePosition, maxClientPosition)
+		overridePosition = -1
+	}
+	if operatorPosition > maxClientPosition {
+		log.Printf("%s (%d > %d)\n", WarningOperatorPositionMax, operatorPosition, maxClientPosition)
+		operatorPosition = -1
+	}
+	return &Server{dbInterface, commitmentInterval, overridePosition, treeWidth, maxClientPosition, commitmentExpiry, maxPayloadSize,
+		retention, compactionInterval, operatorPosition, receiptKey, leafOrdering, messagePrefix, buffer, clock.NewClockReal(),
+		newCommitmentValidators(), cache}
+}
+
+// Set clock used for commitment timing, e.g. a fake clock for testing
+func (s *Server) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // Handle saving Commitment underlying components to the database
@@ -43,7 +264,39 @@ func (s *Server) updateAttestationCommitment(commitment models.Commitment) error
 }
 
 // Update latest Attestation in the server
+// If write buffering is set, a failed db write is buffered and replayed
+// once the db recovers, after any previously buffered writes
 func (s *Server) UpdateLatestAttestation(attestation models.Attestation) error {
+	commitment, errCommitment := attestation.Commitment()
+	if errCommitment != nil {
+		return errCommitment
+	}
+	if _, flushErr := s.FlushWriteBuffer(); flushErr != nil {
+		return s.bufferWrite(newAttestationWrite(attestation, commitment), flushErr)
+	}
+	if errSave := s.saveLatestAttestation(attestation); errSave != nil {
+		return s.bufferWrite(newAttestationWrite(attestation, commitment), errSave)
+	}
+	return nil
+}
+
+// Save latest Attestation to the db and update the read cache if set
+// The cached latest attestation is invalidated if any db write fails
+func (s *Server) saveLatestAttestation(attestation models.Attestation) error {
+	if errSave := s.writeLatestAttestation(attestation); errSave != nil {
+		s.cache.invalidateLatestAttestation()
+		return errSave
+	}
+	commitment, errCommitment := attestation.Commitment()
+	if errCommitment != nil {
+		return errCommitment
+	}
+	s.cache.updateLatestAttestation(attestation, *commitment, s.clock.Now())
+	return nil
+}
+
+// Write latest Attestation along with its commitment and info to the db
+func (s *Server) writeLatestAttestation(attestation models.Attestation) error {
 	errSave := s.dbInterface.saveAttestation(attestation)
 	if errSave != nil {
 		return errSave
@@ -68,45 +321,158 @@ func (s *Server) UpdateLatestAttestation(attestation models.Attestation) error {
 }
 
 // Return Commitment hash of latest Attestation stored in the server
+// The zero hash is returned if there are no attestations yet, which
+// is ambiguous with an attested zero commitment merkle root - use
+// LookupLatestAttestationCommitmentHash to distinguish the two
 func (s *Server) GetLatestAttestationCommitmentHash(confirmed ...bool) (chainhash.Hash, error) {
+	commitmentHash, _, err := s.LookupLatestAttestationCommitmentHash(confirmed...)
+	return commitmentHash, err
+}
+
+// Return Commitment hash of latest Attestation stored in the server along
+// with a flag set if an attestation was found, so that no attestation yet
+// is unambiguously distinguished from an attested zero commitment hash
+func (s *Server) LookupLatestAttestationCommitmentHash(confirmed ...bool) (chainhash.Hash, bool, error) {
 	// optional param to set confirmed flag - looks for confirmed only by default
 	confirmedParam := true
 	if len(confirmed) > 0 {
 		confirmedParam = confirmed[0]
 	}
 
+	// replay any buffered writes first so that the latest attestation is not stale
+	if _, flushErr := s.FlushWriteBuffer(); flushErr != nil {
+		return chainhash.Hash{}, false, flushErr
+	}
+
+	// get attestation merkle root from read cache if set
+	if cachedHash, cachedFound, cached := s.cache.getLatestRoot(confirmedParam, s.clock.Now()); cached {
+		return cachedHash, cachedFound, nil
+	}
+
 	// get attestation merkle root from db
 	merkleRoot, rootErr := s.dbInterface.getLatestAttestationMerkleRoot(confirmedParam)
 	if rootErr != nil {
-		return chainhash.Hash{}, rootErr
+		return chainhash.Hash{}, false, rootErr
 	} else if merkleRoot == "" { // no attestations yet
-		return chainhash.Hash{}, nil
+		s.cache.setLatestRoot(confirmedParam, chainhash.Hash{}, false, s.clock.Now())
+		return chainhash.Hash{}, false, nil
 	}
 	commitmentHash, errHash := chainhash.NewHashFromStr(merkleRoot)
 	if errHash != nil {
-		return chainhash.Hash{}, errHash
+		return chainhash.Hash{}, false, errHash
+	}
+	s.cache.setLatestRoot(confirmedParam, *commitmentHash, true, s.clock.Now())
+	return *commitmentHash, true, nil
+}
+
+// Check if the client commitment at a position has expired based on the
+// time it was last submitted, including resubmissions of the same commitment,
+// so that a live client keeps its commitment in the commitment tree
+// Commitments without a time never expire
+func (s *Server) isClientCommitmentExpired(position int32) (bool, error) {
+	if s.commitmentExpiry <= 0 {
+		return false, nil
 	}
-	return *commitmentHash, nil
+	seenTime, seenErr := s.dbInterface.getClientCommitmentSeenTime(position)
+	if seenErr != nil {
+		return false, seenErr
+	}
+	return !seenTime.IsZero() && s.clock.Now().Sub(seenTime) > s.commitmentExpiry, nil
 }
 
 // Return latest commitment stored in the server
+// Expired client commitments are replaced by the zero hash if expiry is set
+// Leaves are ordered by client position, or by client commitment update
+// time if submission leaf ordering is set, in which case the client
+// position of each leaf is set in the commitment returned
+// Client positions above the maximum client position are rejected
+// before the commitment tree leaves are allocated
+// If the read cache is set the commitment is served from the cache
+// until a client commitment is saved or the cached commitment expires
 func (s *Server) GetClientCommitment() (models.Commitment, error) {
 
+	// get latest commitment from read cache if set
+	if cachedCommitment, cached := s.cache.getClientCommitment(s.clock.Now()); cached {
+		return cachedCommitment, nil
+	}
+
 	// get latest commitments from db
 	latestCommitments, errLatest := s.dbInterface.getClientCommitments()
 	if errLatest != nil {
 		return models.Commitment{}, errLatest
 	}
 
+	// sort a copy of the commitments by client position (ASC)
+	// instead of relying on the db result ordering
+	sortedCommitments := make([]models.ClientCommitment, len(latestCommitments))
+	copy(sortedCommitments, latestCommitments)
+	sort.Slice(sortedCommitments, func(i, j int) bool {
+		return sortedCommitments[i].ClientPosition < sortedCommitments[j].ClientPosition
+	})
+
+	// skip out of range client positions stored before the maximum
+	// client position was set, so that other clients are still attested
+	var validCommitments []models.ClientCommitment
+	for _, c := range sortedCommitments {
+		if positionErr := s.checkClientPosition(c.ClientPosition); positionErr != nil {
+			log.Printf("*Server* %s (%v)\n", WarningClientPositionSkipped, positionErr)
+			continue
+		}
+		validCommitments = append(validCommitments, c)
+	}
+	sortedCommitments = validCommitments
+
 	var commitmentHashes []chainhash.Hash
-	if len(latestCommitments) > 0 {
-		// initialise hash slice with the maximum position returned from the commitment results
-		// asume latestCommitments ordered (ASC) by client position
-		commitmentHashes = make([]chainhash.Hash, latestCommitments[len(latestCommitments)-1].ClientPosition+1)
-		// set commitments in ordered position for resulting slice
-		// missing positions have been initialized to zero hash
-		for _, c := range latestCommitments {
-			commitmentHashes[c.ClientPosition] = c.Commitment
+	var leafPositions []int32
+	if len(sortedCommitments) > 0 {
+		// validate positions and find the maximum position explicitly
+		var maxPosition int32
+		for i, c := range sortedCommitments {
+			if i > 0 && c.ClientPosition == sortedCommitments[i-1].ClientPosition {
+				return models.Commitment{}, errors.New(fmt.Sprintf("%s (%d)",
+					ErrorClientPositionDuplicate, c.ClientPosition))
+			}
+			if s.treeWidth > 0 && c.ClientPosition >= s.treeWidth {
+				return models.Commitment{}, errors.New(fmt.Sprintf("%s (%d)",
+					ErrorClientPositionTreeWidth, c.ClientPosition))
+			}
+			if c.ClientPosition > maxPosition {
+				maxPosition = c.ClientPosition
+			}
+		}
+
+		// exclude expired client commitments from the commitment tree
+		var activeCommitments []models.ClientCommitment
+		for _, c := range sortedCommitments {
+			expired, expiredErr := s.isClientCommitmentExpired(c.ClientPosition)
+			if expiredErr != nil {
+				return models.Commitment{}, expiredErr
+			}
+			if expired {
+				log.Printf("*Server* %s (%d) %s\n", WarningClientCommitmentExpired, c.ClientPosition, c.Commitment.String())
+				continue
+			}
+			activeCommitments = append(activeCommitments, c)
+		}
+
+		if s.leafOrdering == config.LeafOrderingSubmission {
+			var leavesErr error
+			commitmentHashes, leafPositions, leavesErr = s.getSubmissionOrderedLeaves(activeCommitments)
+			if leavesErr != nil {
+				return models.Commitment{}, leavesErr
+			}
+		} else {
+			// initialise hash slice with the maximum position returned from the commitment results
+			// or with the fixed tree width if set to keep the tree shape stable
+			if s.treeWidth > 0 {
+				maxPosition = s.treeWidth - 1
+			}
+			commitmentHashes = make([]chainhash.Hash, maxPosition+1)
+			// set commitments in ordered position for resulting slice
+			// missing and expired positions have been initialized to zero hash
+			for _, c := range activeCommitments {
+				commitmentHashes[c.ClientPosition] = c.Commitment
+			}
 		}
 	}
 
@@ -115,11 +481,334 @@ func (s *Server) GetClientCommitment() (models.Commitment, error) {
 	if errCommitment != nil {
 		return models.Commitment{}, errCommitment
 	}
+	if leafPositions != nil {
+		commitment.SetLeafPositions(leafPositions)
+	}
+	s.cache.setClientCommitment(*commitment, s.clock.Now())
 
 	// db interface
 	return *commitment, nil
 }
 
+// Order client commitments by update time, with ties broken by client position
+// Returns the leaf hashes and the client position of each leaf, padded with the
+// zero hash up to the fixed tree width if set - padding leaves have position -1
+func (s *Server) getSubmissionOrderedLeaves(commitments []models.ClientCommitment) ([]chainhash.Hash, []int32, error) {
+	updateTimes := make(map[int32]time.Time)
+	for _, c := range commitments {
+		updateTime, updateErr := s.dbInterface.getClientCommitmentUpdateTime(c.ClientPosition)
+		if updateErr != nil {
+			return nil, nil, updateErr
+		}
+		updateTimes[c.ClientPosition] = updateTime
+	}
+	orderedCommitments := make([]models.ClientCommitment, len(commitments))
+	copy(orderedCommitments, commitments)
+	sort.SliceStable(orderedCommitments, func(i, j int) bool {
+		ti := updateTimes[orderedCommitments[i].ClientPosition]
+		tj := updateTimes[orderedCommitments[j].ClientPosition]
+		if ti.Equal(tj) {
+			return orderedCommitments[i].ClientPosition < orderedCommitments[j].ClientPosition
+		}
+		return ti.Before(tj)
+	})
+
+	// at least one leaf is required if all client commitments expired
+	numLeaves := len(orderedCommitments)
+	if s.treeWidth > 0 {
+		numLeaves = int(s.treeWidth)
+	} else if numLeaves == 0 {
+		numLeaves = 1
+	}
+	hashes := make([]chainhash.Hash, numLeaves)
+	positions := make([]int32, numLeaves)
+	for i := range positions {
+		positions[i] = -1
+	}
+	for i, c := range orderedCommitments {
+		hashes[i] = c.Commitment
+		positions[i] = c.ClientPosition
+	}
+	return hashes, positions, nil
+}
+
+// Save a new client commitment received by the server
+// Commitments received within the minimum commitment interval of the
+// previous commitment of the same client position are rejected
+// Commitments for the override and operator positions are rejected if set
+// Commitments for positions outside the fixed tree width are rejected if it is set
+// Commitments for negative positions or above the maximum client position are rejected
+// Resubmitting the latest commitment of a client position, e.g. on a client
+// retry after a timeout, is a no-op success that does not update the position
+// and returns true to report the commitment as already recorded
+// Commitments rejected by a registered commitment validator return its error
+func (s *Server) SaveClientCommitment(commitment models.ClientCommitment) (bool, error) {
+	return s.saveClientCommitment(commitment, "")
+}
+
+// Save a new client commitment authenticated with the auth token provided
+// so that validators registered for the auth token are also invoked
+// Returns true if the commitment was already recorded for the client position
+func (s *Server) saveClientCommitment(commitment models.ClientCommitment, token string) (bool, error) {
+	if positionErr := s.checkClientPosition(commitment.ClientPosition); positionErr != nil {
+		return false, positionErr
+	}
+	if s.overridePosition >= 0 && commitment.ClientPosition == s.overridePosition {
+		return false, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionReserved, commitment.ClientPosition))
+	}
+	if s.operatorPosition >= 0 && commitment.ClientPosition == s.operatorPosition {
+		return false, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionOperator, commitment.ClientPosition))
+	}
+	if s.treeWidth > 0 && commitment.ClientPosition >= s.treeWidth {
+		return false, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionTreeWidth, commitment.ClientPosition))
+	}
+	if s.commitmentInterval > 0 {
+		updateTime, updateErr := s.dbInterface.getClientCommitmentUpdateTime(commitment.ClientPosition)
+		if updateErr != nil {
+			return false, updateErr
+		}
+		if !updateTime.IsZero() {
+			elapsed := s.clock.Now().Sub(updateTime)
+			if elapsed < s.commitmentInterval {
+				recorded, recordedErr := s.isClientCommitmentRecorded(commitment)
+				if recordedErr != nil {
+					return false, recordedErr
+				} else if recorded {
+					log.Printf("*Server* %s (%d)\n", InfoClientCommitmentRecorded, commitment.ClientPosition)
+					return true, nil
+				}
+				retryAfter := (s.commitmentInterval - elapsed).Round(time.Second)
+				return false, errors.New(fmt.Sprintf("%s - retry after %s", ErrorClientCommitmentTooFrequent, retryAfter.String()))
+			}
+		}
+	}
+	if validateErr := s.validateCommitment(commitment, token); validateErr != nil {
+		return false, validateErr
+	}
+	if _, flushErr := s.FlushWriteBuffer(); flushErr != nil {
+		return false, s.bufferWrite(newClientCommitmentWrite(commitment), flushErr)
+	}
+	recorded, saveErr := s.dbInterface.saveClientCommitment(commitment)
+	if saveErr != nil {
+		return false, s.bufferWrite(newClientCommitmentWrite(commitment), saveErr)
+	}
+	s.cache.invalidateClientCommitment()
+	if recorded {
+		log.Printf("*Server* %s (%d)\n", InfoClientCommitmentRecorded, commitment.ClientPosition)
+	}
+	return recorded, nil
+}
+
+// Check client position is not negative and does not exceed the maximum client
+// position, so that positions are rejected before the commitment tree is allocated
+// Override and operator positions are never above the maximum client position
+func (s *Server) checkClientPosition(position int32) error {
+	if position < 0 {
+		return errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionInvalid, position))
+	}
+	if position > s.maxClientPosition {
+		return errors.New(fmt.Sprintf("%s (%d > %d)", ErrorClientPositionMax, position, s.maxClientPosition))
+	}
+	return nil
+}
+
+// Check if commitment is the latest commitment recorded for the client position
+func (s *Server) isClientCommitmentRecorded(commitment models.ClientCommitment) (bool, error) {
+	latestCommitments, latestErr := s.dbInterface.getClientCommitments()
+	if latestErr != nil {
+		return false, latestErr
+	}
+	for _, c := range latestCommitments {
+		if c.ClientPosition == commitment.ClientPosition {
+			return c.Commitment == commitment.Commitment, nil
+		}
+	}
+	return false, nil
+}
+
+// Save an external override commitment, e.g. a one-off document root,
+// to be included in the next attestation at the reserved override position
+// The commitment is persisted like a normal client commitment
+func (s *Server) SaveOverrideCommitment(commitment chainhash.Hash) error {
+	if s.overridePosition < 0 {
+		return errors.New(ErrorOverrideDisabled)
+	}
+	if s.treeWidth > 0 && s.overridePosition >= s.treeWidth {
+		return errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionTreeWidth, s.overridePosition))
+	}
+	_, saveErr := s.dbInterface.saveClientCommitment(models.ClientCommitment{
+		Commitment:     commitment,
+		ClientPosition: s.overridePosition})
+	s.cache.invalidateClientCommitment()
+	return saveErr
+}
+
+// Update the operator commitment at the reserved operator position, if set
+// The operator commitment is an attestation sequence counter that is
+// advanced once the current counter has been included in the latest
+// confirmed attestation. The counter is stored little endian so that
+// the commitment hex string ends with the counter, e.g. 00...0002
+func (s *Server) UpdateOperatorCommitment() error {
+	if s.operatorPosition < 0 {
+		return nil
+	}
+	if s.treeWidth > 0 && s.operatorPosition >= s.treeWidth {
+		return errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionTreeWidth, s.operatorPosition))
+	}
+
+	// get current operator commitment
+	latestCommitments, latestErr := s.dbInterface.getClientCommitments()
+	if latestErr != nil {
+		return latestErr
+	}
+	var current chainhash.Hash
+	for _, c := range latestCommitments {
+		if c.ClientPosition == s.operatorPosition {
+			current = c.Commitment
+		}
+	}
+
+	// advance counter if current counter included in latest confirmed attestation
+	sequence := binary.LittleEndian.Uint64(current[:8])
+	if sequence > 0 {
+		latestRoot, rootErr := s.GetLatestAttestationCommitmentHash()
+		if rootErr != nil {
+			return rootErr
+		}
+		merkleCommitments, merkleCommitmentsErr := s.dbInterface.getMerkleCommitmentsForCommitment(current)
+		if merkleCommitmentsErr != nil {
+			return merkleCommitmentsErr
+		}
+		attested := false
+		for _, c := range merkleCommitments {
+			if c.MerkleRoot == latestRoot && c.ClientPosition == s.operatorPosition {
+				attested = true
+				break
+			}
+		}
+		if !attested {
+			return nil
+		}
+	}
+
+	var operatorCommitment chainhash.Hash
+	binary.LittleEndian.PutUint64(operatorCommitment[:8], sequence+1)
+	_, saveErr := s.dbInterface.saveClientCommitment(models.ClientCommitment{
+		Commitment:     operatorCommitment,
+		ClientPosition: s.operatorPosition})
+	s.cache.invalidateClientCommitment()
+	return saveErr
+}
+
+// Save an immutable snapshot of the client commitments of a Commitment
+// along with the time each client commitment was last updated
+// Zero hash positions without a client commitment are omitted
+// Client commitments are listed in leaf order and the leaf ordering
+// is recorded, so that the merkle root can be reproduced
+func (s *Server) SaveCommitmentSnapshot(commitment models.Commitment) error {
+	if orderingErr := s.checkLeafOrdering(); orderingErr != nil {
+		return orderingErr
+	}
+	snapshot := models.CommitmentSnapshot{
+		MerkleRoot:   commitment.GetCommitmentHash(),
+		CreatedAt:    s.clock.Now(),
+		LeafOrdering: s.leafOrdering}
+	for _, c := range commitment.GetMerkleCommitments() {
+		if (c.Commitment == chainhash.Hash{}) || c.Commitment == models.ZeroCommitmentSentinel {
+			continue
+		}
+		if c.ClientPosition < 0 {
+			continue
+		}
+		updateTime, updateErr := s.dbInterface.getClientCommitmentUpdateTime(c.ClientPosition)
+		if updateErr != nil {
+			return updateErr
+		}
+		snapshot.Commitments = append(snapshot.Commitments, models.ClientCommitmentSnapshot{
+			Commitment:     c.Commitment,
+			ClientPosition: c.ClientPosition,
+			UpdatedAt:      updateTime})
+	}
+	return s.dbInterface.saveCommitmentSnapshot(snapshot)
+}
+
+// Check the leaf ordering is consistent with the leaf ordering recorded
+// for the latest confirmed attestation, logging a warning if it changed
+// Snapshots recorded before the ordering was configurable are position ordered
+func (s *Server) checkLeafOrdering() error {
+	latestRoot, latestErr := s.dbInterface.getLatestAttestationMerkleRoot(true)
+	if latestErr != nil {
+		return latestErr
+	} else if latestRoot == "" {
+		return nil
+	}
+	latestRootHash, hashErr := chainhash.NewHashFromStr(latestRoot)
+	if hashErr != nil {
+		return hashErr
+	}
+	latestSnapshot, snapshotErr := s.dbInterface.getCommitmentSnapshot(*latestRootHash)
+	if snapshotErr != nil {
+		return snapshotErr
+	} else if (latestSnapshot.MerkleRoot == chainhash.Hash{}) {
+		return nil
+	}
+	latestOrdering := latestSnapshot.LeafOrdering
+	if latestOrdering == "" {
+		latestOrdering = config.LeafOrderingPosition
+	}
+	if latestOrdering != s.leafOrdering {
+		log.Printf("*Server* %s (%s to %s)\n", WarningLeafOrderingChanged, latestOrdering, s.leafOrdering)
+	}
+	return nil
+}
+
+// Generate a receipt for a confirmed attestation signed by the operator receipt key
+// The receipt binds the attested merkle root to the attestation txid, the height
+// of the bitcoin block including the attestation and the attestation time
+// Receipts are stored so that they can be served to clients
+func (s *Server) GenerateReceipt(attestation models.Attestation, blockHeight int64) (models.AttestationReceipt, error) {
+	if s.receiptKey == nil {
+		return models.AttestationReceipt{}, errors.New(ErrorReceiptKeyMissing)
+	}
+	if !attestation.Confirmed {
+		return models.AttestationReceipt{}, errors.New(fmt.Sprintf("%s (%s)", ErrorReceiptUnconfirmed, attestation.Txid.String()))
+	}
+	receipt := models.AttestationReceipt{
+		MerkleRoot:  attestation.CommitmentHash(),
+		Txid:        attestation.Txid,
+		BlockHeight: blockHeight,
+		Time:        attestation.Info.Time}
+	if signErr := receipt.Sign(s.receiptKey); signErr != nil {
+		return models.AttestationReceipt{}, signErr
+	}
+	if saveErr := s.dbInterface.saveAttestationReceipt(receipt); saveErr != nil {
+		return models.AttestationReceipt{}, saveErr
+	}
+	return receipt, nil
+}
+
+// Return the receipt of the Attestation with given txid
+// Returns an empty receipt if no receipt has been generated
+func (s *Server) GetAttestationReceipt(attestationTxid chainhash.Hash) (models.AttestationReceipt, error) {
+	return s.dbInterface.getAttestationReceipt(attestationTxid)
+}
+
+// Return the client commitment snapshot of the Attestation with given txid
+// Returns an empty snapshot if the attestation or snapshot are not found
+func (s *Server) GetAttestationCommitmentSnapshot(attestationTxid chainhash.Hash) (models.CommitmentSnapshot, error) {
+	merkleRoot, rootErr := s.dbInterface.getAttestationMerkleRoot(attestationTxid)
+	if rootErr != nil {
+		return models.CommitmentSnapshot{}, rootErr
+	} else if merkleRoot == "" {
+		return models.CommitmentSnapshot{}, nil
+	}
+	merkleRootHash, hashErr := chainhash.NewHashFromStr(merkleRoot)
+	if hashErr != nil {
+		return models.CommitmentSnapshot{}, hashErr
+	}
+	return s.dbInterface.getCommitmentSnapshot(*merkleRootHash)
+}
+
 // Return Commitment for a particular Attestation transaction id
 func (s *Server) GetAttestationCommitment(attestationTxid chainhash.Hash, confirmed ...bool) (models.Commitment, error) {
 	// optional param to set confirmed flag - looks for confirmed only by default
@@ -128,6 +817,11 @@ func (s *Server) GetAttestationCommitment(attestationTxid chainhash.Hash, confir
 		confirmedParam = confirmed[0]
 	}
 
+	// get commitment of latest confirmed attestation from read cache if set
+	if cachedCommitment, cached := s.cache.getAttestationCommitment(attestationTxid); cached {
+		return cachedCommitment, nil
+	}
+
 	// get merkle commitments from db
 	merkleCommitments, merkleCommitmentsErr := s.dbInterface.getAttestationMerkleCommitments(attestationTxid)
 	if merkleCommitmentsErr != nil {
@@ -138,16 +832,194 @@ func (s *Server) GetAttestationCommitment(attestationTxid chainhash.Hash, confir
 		}
 	}
 
-	// construct Commitment from MerkleCommitment commitments
+	return commitmentFromMerkleCommitments(merkleCommitments)
+}
+
+// Construct Commitment from MerkleCommitment commitments in leaf order
+// The client position of each leaf is set if the leaves are not ordered
+// by client position, e.g. by submission time
+func commitmentFromMerkleCommitments(merkleCommitments []models.CommitmentMerkleCommitment) (models.Commitment, error) {
+	sortedCommitments := make([]models.CommitmentMerkleCommitment, len(merkleCommitments))
+	copy(sortedCommitments, merkleCommitments)
+	sort.SliceStable(sortedCommitments, func(i, j int) bool {
+		return sortedCommitments[i].LeafIndex < sortedCommitments[j].LeafIndex
+	})
+
 	var commitmentHashes []chainhash.Hash
-	for _, c := range merkleCommitments {
+	var leafPositions []int32
+	positionOrdered := true
+	for _, c := range sortedCommitments {
 		commitmentHashes = append(commitmentHashes, c.Commitment)
+		leafPositions = append(leafPositions, c.ClientPosition)
+		if c.ClientPosition != c.LeafIndex {
+			positionOrdered = false
+		}
 	}
 
 	commitment, errCommitment := models.NewCommitment(commitmentHashes)
 	if errCommitment != nil {
 		return models.Commitment{}, errCommitment
 	}
+	if !positionOrdered {
+		commitment.SetLeafPositions(leafPositions)
+	}
 
 	return *commitment, nil
 }
+
+// Return confirmed attestations whose merkle root includes the given commitment
+// For each attestation the merkle proof of the commitment is also returned
+func (s *Server) GetAttestationForCommitment(commitment chainhash.Hash) ([]AttestationCommitmentProof, error) {
+
+	// get merkle commitments matching the commitment from db
+	merkleCommitments, merkleCommitmentsErr := s.dbInterface.getMerkleCommitmentsForCommitment(commitment)
+	if merkleCommitmentsErr != nil {
+		return []AttestationCommitmentProof{}, merkleCommitmentsErr
+	}
+
+	var attestationProofs []AttestationCommitmentProof
+	for _, merkleCommitment := range merkleCommitments {
+		// get confirmed attestations for merkle root
+		txids, txidsErr := s.dbInterface.getAttestationTxidsForMerkleRoot(merkleCommitment.MerkleRoot, true)
+		if txidsErr != nil {
+			return []AttestationCommitmentProof{}, txidsErr
+		} else if len(txids) == 0 {
+			continue
+		}
+
+		// rebuild attestation commitment to get the commitment proof
+		attestationCommitment, commitmentErr := s.GetAttestationCommitment(txids[0])
+		if commitmentErr != nil {
+			return []AttestationCommitmentProof{}, commitmentErr
+		}
+		proofs := attestationCommitment.GetMerkleProofs()
+		if merkleCommitment.LeafIndex < 0 || int(merkleCommitment.LeafIndex) >= len(proofs) {
+			continue
+		}
+		for _, txid := range txids {
+			attestationProofs = append(attestationProofs,
+				AttestationCommitmentProof{txid, proofs[merkleCommitment.LeafIndex]})
+		}
+	}
+	return attestationProofs, nil
+}
+
+// Return confirmed attestations inserted within the time window [from, to) along
+// with the client commitment at the position in the snapshot of each attestation
+// This allows a client to show that a commitment was not attested at a position
+// during the window, e.g. to detect censorship, as the commitment snapshots
+// reproduce the merkle root attested by each attestation
+func (s *Server) GetAttestationsForPosition(position int32, from time.Time, to time.Time) ([]AttestationPositionCommitment, error) {
+	attestations, attestationsErr := s.dbInterface.getConfirmedAttestationsForWindow(from, to)
+	if attestationsErr != nil {
+		return []AttestationPositionCommitment{}, attestationsErr
+	}
+
+	var positionCommitments []AttestationPositionCommitment
+	for _, attestation := range attestations {
+		txid, txidErr := chainhash.NewHashFromStr(attestation.Txid)
+		if txidErr != nil {
+			return []AttestationPositionCommitment{}, txidErr
+		}
+		merkleRoot, rootErr := chainhash.NewHashFromStr(attestation.MerkleRoot)
+		if rootErr != nil {
+			return []AttestationPositionCommitment{}, rootErr
+		}
+		positionCommitment := AttestationPositionCommitment{
+			Txid:       *txid,
+			MerkleRoot: *merkleRoot,
+			InsertedAt: attestation.InsertedAt}
+
+		snapshot, snapshotErr := s.dbInterface.getCommitmentSnapshot(*merkleRoot)
+		if snapshotErr != nil {
+			return []AttestationPositionCommitment{}, snapshotErr
+		}
+		if snapshot.MerkleRoot == *merkleRoot {
+			positionCommitment.Snapshot = true
+			for _, commitment := range snapshot.Commitments {
+				if commitment.ClientPosition == position {
+					positionCommitment.Commitment = commitment.Commitment
+					positionCommitment.UpdatedAt = commitment.UpdatedAt
+					break
+				}
+			}
+		}
+		positionCommitments = append(positionCommitments, positionCommitment)
+	}
+	return positionCommitments, nil
+}
+
+// Return latest staychain checkpoint txid stored in the server
+// Zero hash is returned if no checkpoint has been recorded
+func (s *Server) GetCheckpoint() (chainhash.Hash, error) {
+	return s.dbInterface.getCheckpoint()
+}
+
+// Store new staychain checkpoint txid in the server
+func (s *Server) SaveCheckpoint(txid chainhash.Hash) error {
+	return s.dbInterface.saveCheckpoint(txid)
+}
+
+// Store the schedule of the next attestation published by the attestation service
+func (s *Server) SaveAttestationSchedule(schedule models.AttestationSchedule) error {
+	return s.dbInterface.saveAttestationSchedule(schedule)
+}
+
+// Return the latest attestation schedule, used by the API to report the
+// time until the next attestation and whether a commitment submitted now
+// makes it. Empty schedule is returned if none has been published
+func (s *Server) GetAttestationSchedule() (models.AttestationSchedule, error) {
+	return s.dbInterface.getAttestationSchedule()
+}
+
+// Record submission of a signed commitment authenticated with the token
+// Failures are logged without rejecting the commitment, as stats are only
+// used for visibility into how often each client submits
+func (s *Server) recordTokenSubmission(token string, position int32) {
+	if saveErr := s.dbInterface.saveTokenSubmission(token, position, s.clock.Now()); saveErr != nil {
+		log.Printf("*Server* %s (%d) %v\n", WarningTokenSubmissionFailed, position, saveErr)
+	}
+}
+
+// Return submission stats of all client auth tokens ordered by descending
+// submission count, used by operators to spot abusive or broken clients
+// Submissions are counted once authenticated, including rejected commitments
+func (s *Server) GetTokenSubmissionStats() ([]models.TokenSubmissionStats, error) {
+	return s.dbInterface.getTokenSubmissionStats()
+}
+
+// Compact merkle commitment records of merkle roots only attested by
+// unconfirmed attestations older than the retention period, e.g. replaced
+// attestations that never confirmed. Confirmed records are kept indefinitely
+// Client commitments are not compacted as only the latest per position is stored
+// Returns the number of merkle commitments deleted - none if retention not set
+func (s *Server) CompactCommitments() (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	return s.dbInterface.compactMerkleCommitments(s.clock.Now().Add(-s.retention))
+}
+
+// Run merkle commitment compaction periodically until the context is cancelled
+// Returns immediately if retention is not set. Failures are logged and retried
+func (s *Server) RunCompaction(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if s.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.compactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, compactErr := s.CompactCommitments()
+			if compactErr != nil {
+				log.Printf("*Server* %s %v\n", WarningCompactionFailed, compactErr)
+			} else if deleted > 0 {
+				log.Printf("*Server* Compacted %d merkle commitments older than %s\n", deleted, s.retention.String())
+			}
+		}
+	}
+}
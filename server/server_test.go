@@ -6,11 +6,18 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
+	"mainstay/clock"
+	"mainstay/config"
 	"mainstay/models"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -154,6 +161,45 @@ func TestServerUpdateLatestAttestation_1ClientCommitments(t *testing.T) {
 	assert.Equal(t, latestCommitment2.GetCommitmentHash(), respAttestationHash)
 }
 
+// Test looking up the latest attestation distinguishes no attestation from an attested zero commitment
+func TestServerLookupLatestAttestationCommitmentHash_ZeroCommitment(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{chainhash.Hash{}, 0}})
+
+	// Test no attestation yet
+	respAttestationHash, attested, errAttestation := server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, errAttestation)
+	assert.Equal(t, false, attested)
+	assert.Equal(t, chainhash.Hash{}, respAttestationHash)
+
+	// Test attested zero commitment
+	respClientCommitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	zeroCommitment, _ := models.NewCommitment([]chainhash.Hash{chainhash.Hash{}})
+	assert.Equal(t, zeroCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, true, respClientCommitment.IsZero())
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest := models.NewAttestation(*txid, &respClientCommitment)
+	latest.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest))
+
+	respAttestationHash, attested, errAttestation = server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, errAttestation)
+	assert.Equal(t, true, attested)
+	assert.Equal(t, zeroCommitment.GetCommitmentHash(), respAttestationHash)
+
+	// Test sentinel commitment snapshot has no client commitments
+	sentinelCommitment := models.NewZeroCommitmentSentinel()
+	assert.Equal(t, false, sentinelCommitment.IsZero())
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(*sentinelCommitment))
+	snapshot, snapshotErr := dbFake.getCommitmentSnapshot(sentinelCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, snapshotErr)
+	assert.Equal(t, sentinelCommitment.GetCommitmentHash(), snapshot.MerkleRoot)
+	assert.Equal(t, 0, len(snapshot.Commitments))
+}
+
 // Test Server UpdateLatestAttestation with 3 latest commitment
 func TestServerUpdateLatestAttestation_3ClientCommitments(t *testing.T) {
 	// TEST INIT
@@ -377,6 +423,229 @@ func TestServerGetClientCommitment(t *testing.T) {
 	respClientCommitment, err = server.GetClientCommitment()
 	assert.Equal(t, nil, err)
 	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// update server with unordered latest commitment and test server
+	latestCommitments = []models.ClientCommitment{
+		models.ClientCommitment{*hash2, 2},
+		models.ClientCommitment{*hash0, 0},
+		models.ClientCommitment{*hash1, 1}}
+	dbFake.SetClientCommitments(latestCommitments)
+
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, latestCommitment.GetMerkleCommitments(), respClientCommitment.GetMerkleCommitments())
+	assert.Equal(t, int32(2), latestCommitments[0].ClientPosition) // db result not modified
+
+	// update server with unordered latest commitment with missing position and test server
+	latestCommitments = []models.ClientCommitment{
+		models.ClientCommitment{*hash2, 2}, models.ClientCommitment{*hash0, 0}}
+	dbFake.SetClientCommitments(latestCommitments)
+
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, err2 = models.NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, *hash2})
+	assert.Equal(t, nil, err2)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// update server with duplicate position and test error
+	latestCommitments = []models.ClientCommitment{
+		models.ClientCommitment{*hash1, 1}, models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 1}}
+	dbFake.SetClientCommitments(latestCommitments)
+
+	_, err = server.GetClientCommitment()
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionDuplicate, 1)), err)
+
+	// update server with negative position and test position skipped
+	latestCommitments = []models.ClientCommitment{
+		models.ClientCommitment{*hash1, 1}, models.ClientCommitment{*hash0, -1}}
+	dbFake.SetClientCommitments(latestCommitments)
+
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{chainhash.Hash{}, *hash1})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+}
+
+// Test Server GetClientCommitment with fixed tree width
+func TestServerGetClientCommitment_TreeWidth(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, config.ServerConfig{-1, -1, 4, -1, -1, -1, -1, -1, "", "", "", "", -1, -1})
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// tree always padded to the fixed width
+	dbFake.SetClientCommitments([]models.ClientCommitment{models.ClientCommitment{*hash0, 0}})
+	respClientCommitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{
+		*hash0, chainhash.Hash{}, chainhash.Hash{}, chainhash.Hash{}})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, 4, len(respClientCommitment.GetMerkleCommitments()))
+
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 2}})
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{
+		*hash0, chainhash.Hash{}, *hash2, chainhash.Hash{}})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// position outside tree width
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 4}})
+	_, err = server.GetClientCommitment()
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionTreeWidth, 4)), err)
+
+	// commitment for position outside tree width rejected
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash2, 4})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionTreeWidth, 4)), saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash2, 3})
+	assert.Equal(t, nil, saveErr)
+}
+
+// Test Server GetClientCommitment with submission leaf ordering
+func TestServerGetClientCommitment_SubmissionOrdering(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", config.LeafOrderingSubmission, "", "", -1, -1})
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	time0 := time.Unix(1542121293, 0)
+	dbFake.SetClientCommitmentUpdateTime(0, time0)
+	dbFake.SetClientCommitmentUpdateTime(1, time0.Add(time.Minute))
+	dbFake.SetClientCommitmentUpdateTime(2, time0.Add(-time.Minute))
+
+	// leaves ordered by update time instead of position
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash1, 1}, models.ClientCommitment{*hash2, 2}})
+	respClientCommitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash2, *hash0, *hash1})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, int32(2), respClientCommitment.GetLeafPosition(0))
+	assert.Equal(t, int32(0), respClientCommitment.GetLeafPosition(1))
+	assert.Equal(t, int32(1), respClientCommitment.GetLeafPosition(2))
+
+	// snapshot records leaf ordering and client positions in leaf order
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(respClientCommitment))
+	snapshot, _ := dbFake.getCommitmentSnapshot(respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, config.LeafOrderingSubmission, snapshot.LeafOrdering)
+	assert.Equal(t, []models.ClientCommitmentSnapshot{
+		models.ClientCommitmentSnapshot{*hash2, 2, time0.Add(-time.Minute)},
+		models.ClientCommitmentSnapshot{*hash0, 0, time0},
+		models.ClientCommitmentSnapshot{*hash1, 1, time0.Add(time.Minute)}}, snapshot.Commitments)
+
+	// ties broken by client position
+	dbFake.SetClientCommitmentUpdateTime(2, time0)
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{*hash0, *hash2, *hash1})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// tree padded to the fixed width with padding leaves not in snapshot
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, 4, -1, -1, -1, -1, -1, "", config.LeafOrderingSubmission, "", "", -1, -1})
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{*hash0, *hash2, *hash1, chainhash.Hash{}})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+	assert.Equal(t, int32(-1), respClientCommitment.GetLeafPosition(3))
+
+	// leaf ordering change from latest confirmed attestation does not fail snapshots
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, &respClientCommitment)
+	attestation.Confirmed = true
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(respClientCommitment))
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+
+	// merkle commitments store client positions separately from leaf indices
+	merkleCommitments, _ := dbFake.getMerkleCommitmentsForCommitment(*hash2)
+	assert.Equal(t, []models.CommitmentMerkleCommitment{models.CommitmentMerkleCommitment{
+		respClientCommitment.GetCommitmentHash(), 2, *hash2, 1}}, merkleCommitments)
+	for _, proof := range dbFake.merkleProofs {
+		assert.Equal(t, respClientCommitment.GetLeafPosition(proof.LeafIndex), proof.ClientPosition)
+	}
+
+	// submission ordered proofs resolve to the client position of the commitment
+	for _, c := range []models.ClientCommitment{{*hash0, 0}, {*hash1, 1}, {*hash2, 2}} {
+		proofs, proofsErr := server.GetAttestationForCommitment(c.Commitment)
+		assert.Equal(t, nil, proofsErr)
+		assert.Equal(t, 1, len(proofs))
+		assert.Equal(t, *txid, proofs[0].Txid)
+		assert.Equal(t, c.ClientPosition, proofs[0].Proof.ClientPosition)
+		assert.Equal(t, c.Commitment, proofs[0].Proof.Commitment)
+		assert.Equal(t, true, models.ProveMerkleProof(proofs[0].Proof))
+	}
+
+	// attestation commitment read from the db maps leaves to client positions
+	attestationCommitment, err := NewServer(dbFake).GetAttestationCommitment(*txid)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, respClientCommitment.GetCommitmentHash(), attestationCommitment.GetCommitmentHash())
+	for leaf, position := range []int32{0, 2, 1, -1} {
+		assert.Equal(t, position, attestationCommitment.GetLeafPosition(int32(leaf)))
+	}
+
+	server = NewServer(dbFake)
+	positionCommitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.NotEqual(t, respClientCommitment.GetCommitmentHash(), positionCommitment.GetCommitmentHash())
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(positionCommitment))
+	snapshot, _ = dbFake.getCommitmentSnapshot(positionCommitment.GetCommitmentHash())
+	assert.Equal(t, config.LeafOrderingPosition, snapshot.LeafOrdering)
+}
+
+// Test Server GetClientCommitment with commitment expiry set
+func TestServerGetClientCommitment_Expiry(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, config.ServerConfig{-1, -1, -1, 3600, -1, -1, -1, -1, "", "", "", "", -1, -1})
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// fresh commitments included
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash0, 0})
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash2, 2})
+	assert.Equal(t, nil, saveErr)
+	respClientCommitment, err := server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// stale commitments treated as zero hash keeping the tree size
+	dbFake.SetClientCommitmentUpdateTime(1, time.Now().Add(-2*time.Hour))
+	dbFake.SetClientCommitmentUpdateTime(2, time.Now().Add(-2*time.Hour))
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, chainhash.Hash{}})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// commitments without update time never expire
+	dbFake.SetClientCommitmentUpdateTime(2, time.Time{})
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{*hash0, chainhash.Hash{}, *hash2})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// resubmitted commitment of stale client included again
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	latestCommitment, _ = models.NewCommitment([]chainhash.Hash{*hash0, *hash1, *hash2})
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
+
+	// expiry disabled
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, -1})
+	dbFake.SetClientCommitmentUpdateTime(0, time.Now().Add(-2*time.Hour))
+	respClientCommitment, err = server.GetClientCommitment()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, latestCommitment.GetCommitmentHash(), respClientCommitment.GetCommitmentHash())
 }
 
 // Test Server GetAttestationCommitment
@@ -482,3 +751,584 @@ func TestServerGetAttestationCommitment(t *testing.T) {
 	commitment, err = server.GetAttestationCommitment(chainhash.Hash{}, false)
 	assert.Equal(t, errors.New(models.ErrorCommitmentListEmpty), err)
 }
+
+// Test Server SaveClientCommitment with minimum commitment interval
+func TestServerSaveClientCommitment(t *testing.T) {
+	// TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("ccccccc1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no commitment interval set - consecutive commitments accepted
+	recorded, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, false, recorded)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 0})
+	assert.Equal(t, nil, saveErr)
+	recorded, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash2, 1})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, false, recorded)
+
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0},
+		models.ClientCommitment{*hash2, 1}}, latestCommitments)
+
+	// commitment interval set - consecutive commitments rejected
+	server = NewServer(dbFake, config.ServerConfig{60, -1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, -1})
+	recorded, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.NotEqual(t, nil, saveErr)
+	assert.Equal(t, false, recorded)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorClientCommitmentTooFrequent))
+
+	// resubmitted commitment within commitment interval accepted as no-op
+	updateTime, _ := dbFake.getClientCommitmentUpdateTime(1)
+	recorded, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash2, 1})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, true, recorded)
+	resubmitTime, _ := dbFake.getClientCommitmentUpdateTime(1)
+	assert.Equal(t, updateTime, resubmitTime)
+
+	// commitment for different position accepted
+	recorded, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 2})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, false, recorded)
+
+	// resubmitted commitment without commitment interval does not update time
+	server = NewServer(dbFake)
+	dbFake.SetClientCommitmentUpdateTime(2, time.Now().Add(-61*time.Second))
+	updateTime, _ = dbFake.getClientCommitmentUpdateTime(2)
+	recorded, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 2})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, true, recorded)
+	resubmitTime, _ = dbFake.getClientCommitmentUpdateTime(2)
+	assert.Equal(t, updateTime, resubmitTime)
+	seenTime, _ := dbFake.getClientCommitmentSeenTime(2)
+	assert.Equal(t, true, seenTime.After(updateTime))
+	server = NewServer(dbFake, config.ServerConfig{60, -1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, -1})
+
+	// commitment after commitment interval has passed accepted
+	dbFake.SetClientCommitmentUpdateTime(1, time.Now().Add(-61*time.Second))
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0},
+		models.ClientCommitment{*hash1, 1},
+		models.ClientCommitment{*hash1, 2}}, latestCommitments)
+
+	// commitment interval with fake clock - rejected until interval elapses
+	fakeClock := clock.NewClockFake(time.Now())
+	server.SetClock(fakeClock)
+	dbFake.SetClientCommitmentUpdateTime(1, fakeClock.Now())
+	fakeClock.Advance(59 * time.Second)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 1})
+	assert.Equal(t, ErrorClientCommitmentTooFrequent+" - retry after 1s", saveErr.Error())
+	fakeClock.Advance(time.Second)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 1})
+	assert.Equal(t, nil, saveErr)
+}
+
+// Test Server rejecting client positions above the maximum client position
+func TestServerSaveClientCommitment_MaxPosition(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// huge position rejected by default before any allocation
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash0, math.MaxInt32})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d > %d)", ErrorClientPositionMax,
+		int32(math.MaxInt32), DefaultMaxClientPosition)), saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, -1})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (%d)", ErrorClientPositionInvalid, -1)), saveErr)
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, 0, len(latestCommitments))
+
+	// huge and negative positions in the db skipped when building the commitment
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash1, math.MaxInt32},
+		models.ClientCommitment{*hash1, -1}})
+	commitment, commitmentErr := server.GetClientCommitment()
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, 1, len(commitment.GetMerkleCommitments()))
+	assert.Equal(t, *hash0, commitment.GetMerkleCommitments()[0].Commitment)
+
+	// configured maximum client position
+	dbFake = NewDbFake()
+	server = NewServer(dbFake, config.ServerConfig{-1, 10, -1, -1, -1, -1, -1, -1, "", "", "", "", 10, -1})
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 9})
+	assert.Equal(t, nil, saveErr)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 11})
+	assert.Equal(t, errors.New(fmt.Sprintf("%s (11 > 10)", ErrorClientPositionMax)), saveErr)
+
+	// override position at the maximum client position included
+	assert.Equal(t, nil, server.SaveOverrideCommitment(*hash1))
+	commitment, commitmentErr = server.GetClientCommitment()
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, 11, len(commitment.GetMerkleCommitments()))
+
+	// override and operator positions above the maximum client position rejected
+	server = NewServer(dbFake, config.ServerConfig{-1, 100, -1, -1, -1, -1, -1, 100, "", "", "", "", 10, -1})
+	assert.Equal(t, errors.New(ErrorOverrideDisabled), server.SaveOverrideCommitment(*hash1))
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 9}, models.ClientCommitment{*hash1, 10}}, latestCommitments)
+}
+
+// Test Server override commitment save
+func TestServerSaveOverrideCommitment(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no override position set - override rejected
+	saveErr := server.SaveOverrideCommitment(*hash1)
+	assert.NotEqual(t, nil, saveErr)
+	assert.Equal(t, ErrorOverrideDisabled, saveErr.Error())
+
+	// override position set - override saved at reserved position
+	server = NewServer(dbFake, config.ServerConfig{60, 1, -1, -1, -1, -1, -1, -1, "", "", "", "", -1, -1})
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 0})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, nil, server.SaveOverrideCommitment(*hash1))
+
+	// override not subject to commitment interval
+	assert.Equal(t, nil, server.SaveOverrideCommitment(*hash0))
+
+	// client commitment for reserved position rejected
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.NotEqual(t, nil, saveErr)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorClientPositionReserved))
+
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0},
+		models.ClientCommitment{*hash0, 1}}, latestCommitments)
+}
+
+// Test Server operator commitment update
+func TestServerUpdateOperatorCommitment(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	sequence1, _ := chainhash.NewHashFromStr("0000000000000000000000000000000000000000000000000000000000000001")
+	sequence2, _ := chainhash.NewHashFromStr("0000000000000000000000000000000000000000000000000000000000000002")
+
+	// no operator position set - no operator commitment
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ := dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{}, latestCommitments)
+
+	// operator position set - initial counter saved at reserved position
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, 0, "", "", "", "", -1, -1})
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, []models.ClientCommitment{
+		models.ClientCommitment{*sequence1, 0},
+		models.ClientCommitment{*hash1, 1}}, latestCommitments)
+
+	// client commitment for reserved position rejected
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash1, 0})
+	assert.NotEqual(t, nil, saveErr)
+	assert.Equal(t, true, strings.HasPrefix(saveErr.Error(), ErrorClientPositionOperator))
+
+	// counter not advanced until attested
+	commitment, _ := server.GetClientCommitment()
+	expectedCommitment, _ := models.NewCommitment([]chainhash.Hash{*sequence1, *hash1})
+	assert.Equal(t, expectedCommitment.GetCommitmentHash(), commitment.GetCommitmentHash())
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, *sequence1, latestCommitments[0].Commitment)
+
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, &commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, *sequence1, latestCommitments[0].Commitment)
+
+	// counter advanced after attestation confirmed
+	attestation.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, *sequence2, latestCommitments[0].Commitment)
+	assert.Equal(t, nil, server.UpdateOperatorCommitment())
+	latestCommitments, _ = dbFake.getClientCommitments()
+	assert.Equal(t, *sequence2, latestCommitments[0].Commitment)
+
+	// operator position outside tree width rejected
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, 2, -1, -1, -1, -1, 2, "", "", "", "", -1, -1})
+	updateErr := server.UpdateOperatorCommitment()
+	assert.NotEqual(t, nil, updateErr)
+	assert.Equal(t, true, strings.HasPrefix(updateErr.Error(), ErrorClientPositionTreeWidth))
+}
+
+// Test Server commitment snapshot save and get
+func TestServerCommitmentSnapshot(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash2, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// no attestation - empty snapshot
+	snapshot, snapshotErr := server.GetAttestationCommitmentSnapshot(*txid)
+	assert.Equal(t, nil, snapshotErr)
+	assert.Equal(t, models.CommitmentSnapshot{}, snapshot)
+
+	// save snapshot of commitment with missing position
+	time0 := time.Unix(1542121293, 0)
+	time2 := time.Unix(1542121393, 0)
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hash0, 0}, models.ClientCommitment{*hash2, 2}})
+	dbFake.SetClientCommitmentUpdateTime(0, time0)
+	dbFake.SetClientCommitmentUpdateTime(2, time2)
+	commitment, _ := server.GetClientCommitment()
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(commitment))
+
+	// snapshot not modified by later client commitment updates
+	dbFake.SetClientCommitmentUpdateTime(0, time.Now())
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(commitment))
+
+	// get snapshot of attestation with commitment
+	attestation := models.NewAttestation(*txid, &commitment)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	snapshot, snapshotErr = server.GetAttestationCommitmentSnapshot(*txid)
+	assert.Equal(t, nil, snapshotErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), snapshot.MerkleRoot)
+	assert.Equal(t, []models.ClientCommitmentSnapshot{
+		models.ClientCommitmentSnapshot{*hash0, 0, time0},
+		models.ClientCommitmentSnapshot{*hash2, 2, time2}}, snapshot.Commitments)
+
+	// reproduce attestation commitment from snapshot
+	commitmentHashes := make([]chainhash.Hash, 3)
+	for _, c := range snapshot.Commitments {
+		commitmentHashes[c.ClientPosition] = c.Commitment
+	}
+	snapshotCommitment, _ := models.NewCommitment(commitmentHashes)
+	assert.Equal(t, commitment.GetCommitmentHash(), snapshotCommitment.GetCommitmentHash())
+}
+
+// Test Server Checkpoint save and get
+func TestServerCheckpoint(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	// no checkpoint recorded
+	checkpoint, checkpointErr := server.GetCheckpoint()
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, chainhash.Hash{}, checkpoint)
+
+	// checkpoint recorded and advanced
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("bbbbbbb1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	assert.Equal(t, nil, server.SaveCheckpoint(*hash0))
+	checkpoint, checkpointErr = server.GetCheckpoint()
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, *hash0, checkpoint)
+	assert.Equal(t, nil, server.SaveCheckpoint(*hash1))
+	checkpoint, checkpointErr = server.GetCheckpoint()
+	assert.Equal(t, nil, checkpointErr)
+	assert.Equal(t, *hash1, checkpoint)
+}
+
+// Test Server attestation schedule save and get
+func TestServerAttestationSchedule(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	// no schedule published
+	schedule, scheduleErr := server.GetAttestationSchedule()
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, models.AttestationSchedule{}, schedule)
+
+	// schedule published and replaced
+	now := time.Now()
+	schedule0 := models.NewAttestationSchedule(now.Add(time.Hour), time.Minute, now)
+	schedule1 := models.NewAttestationSchedule(now.Add(2*time.Hour), time.Minute, now.Add(time.Hour))
+	assert.Equal(t, nil, server.SaveAttestationSchedule(schedule0))
+	schedule, scheduleErr = server.GetAttestationSchedule()
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, schedule0, schedule)
+	assert.Equal(t, nil, server.SaveAttestationSchedule(schedule1))
+	schedule, scheduleErr = server.GetAttestationSchedule()
+	assert.Equal(t, nil, scheduleErr)
+	assert.Equal(t, schedule1, schedule)
+}
+
+// Test Server attestation receipt generation and verification
+func TestServerGenerateReceipt(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0})
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Info = models.AttestationInfo{Txid: txid.String(), Time: int64(1542121293)}
+
+	// no receipt key set - receipts disabled
+	_, receiptErr := server.GenerateReceipt(*attestation, 580000)
+	assert.Equal(t, errors.New(ErrorReceiptKeyMissing), receiptErr)
+
+	// invalid receipt key - receipts disabled
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "invalid", "", "", "", -1, -1})
+	_, receiptErr = server.GenerateReceipt(*attestation, 580000)
+	assert.Equal(t, errors.New(ErrorReceiptKeyMissing), receiptErr)
+
+	// unconfirmed attestation rejected
+	receiptKey := "cQca2KvrBnJJUCYa2tD4RXhiQshWLNMSK2A96ZKWo1SZkHhh3YLz"
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, receiptKey, "", "", "", -1, -1})
+	_, receiptErr = server.GenerateReceipt(*attestation, 580000)
+	assert.NotEqual(t, nil, receiptErr)
+	assert.Equal(t, true, strings.HasPrefix(receiptErr.Error(), ErrorReceiptUnconfirmed))
+
+	// receipt generated, stored and verified with operator pubkey
+	attestation.Confirmed = true
+	receipt, receiptErr := server.GenerateReceipt(*attestation, 580000)
+	assert.Equal(t, nil, receiptErr)
+	assert.Equal(t, commitment.GetCommitmentHash(), receipt.MerkleRoot)
+	assert.Equal(t, *txid, receipt.Txid)
+	assert.Equal(t, int64(580000), receipt.BlockHeight)
+	assert.Equal(t, int64(1542121293), receipt.Time)
+
+	wif, _ := btcutil.DecodeWIF(receiptKey)
+	assert.Equal(t, nil, models.VerifyReceipt(receipt, wif.PrivKey.PubKey()))
+
+	storedReceipt, storedErr := server.GetAttestationReceipt(*txid)
+	assert.Equal(t, nil, storedErr)
+	assert.Equal(t, receipt, storedReceipt)
+
+	// tampered receipt fails verification
+	receipt.BlockHeight = 580001
+	assert.NotEqual(t, nil, models.VerifyReceipt(receipt, wif.PrivKey.PubKey()))
+
+	// no receipt for unknown attestation
+	storedReceipt, storedErr = server.GetAttestationReceipt(*hash0)
+	assert.Equal(t, nil, storedErr)
+	assert.Equal(t, models.AttestationReceipt{}, storedReceipt)
+}
+
+// Test Server CompactCommitments of stale unconfirmed merkle roots
+func TestServerCompactCommitments(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txidA, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txidB, _ := chainhash.NewHashFromStr("21111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txidC, _ := chainhash.NewHashFromStr("31111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txidD, _ := chainhash.NewHashFromStr("41111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitmentA, _ := models.NewCommitment([]chainhash.Hash{*hash0})
+	commitmentB, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	commitmentC, _ := models.NewCommitment([]chainhash.Hash{*hash1})
+
+	// confirmed attestation, replaced attestation of the confirmed root,
+	// stale unconfirmed attestation and latest unconfirmed attestation
+	attestationA := models.NewAttestation(*txidA, commitmentA)
+	attestationA.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestationA))
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*models.NewAttestation(*txidB, commitmentA)))
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*models.NewAttestation(*txidC, commitmentB)))
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(*commitmentB))
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*models.NewAttestation(*txidD, commitmentC)))
+	for _, txid := range []*chainhash.Hash{txidA, txidB, txidC, txidD} {
+		dbFake.SetAttestationInsertTime(*txid, time.Now().Add(-2*time.Hour))
+	}
+	assert.Equal(t, 4, len(dbFake.attestations))
+	assert.Equal(t, 4, len(dbFake.merkleCommitments))
+	assert.Equal(t, 4, len(dbFake.merkleProofs))
+	assert.Equal(t, 1, len(dbFake.snapshots))
+
+	// test compaction disabled by default
+	deleted, compactErr := server.CompactCommitments()
+	assert.Equal(t, nil, compactErr)
+	assert.Equal(t, int64(0), deleted)
+	assert.Equal(t, 4, len(dbFake.merkleCommitments))
+
+	// test records within retention period kept
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, 3 * 3600, -1, -1, "", "", "", "", -1, -1})
+	assert.Equal(t, 3600*time.Second, server.compactionInterval)
+	deleted, compactErr = server.CompactCommitments()
+	assert.Equal(t, nil, compactErr)
+	assert.Equal(t, int64(0), deleted)
+	assert.Equal(t, 4, len(dbFake.merkleCommitments))
+
+	// test only stale unconfirmed merkle root compacted
+	server = NewServer(dbFake, config.ServerConfig{-1, -1, -1, -1, -1, 3600, 60, -1, "", "", "", "", -1, -1})
+	assert.Equal(t, 60*time.Second, server.compactionInterval)
+	deleted, compactErr = server.CompactCommitments()
+	assert.Equal(t, nil, compactErr)
+	assert.Equal(t, int64(2), deleted)
+	assert.Equal(t, 3, len(dbFake.attestations))
+	assert.Equal(t, 2, len(dbFake.merkleCommitments))
+	assert.Equal(t, 2, len(dbFake.merkleProofs))
+	assert.Equal(t, 0, len(dbFake.snapshots))
+	for _, c := range dbFake.merkleCommitments {
+		assert.NotEqual(t, commitmentB.GetCommitmentHash(), c.MerkleRoot)
+	}
+
+	// confirmed attestation commitment still available
+	commitment, commitmentErr := server.GetAttestationCommitment(*txidA)
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, commitmentA.GetCommitmentHash(), commitment.GetCommitmentHash())
+	commitment, commitmentErr = server.GetAttestationCommitment(*txidD, false)
+	assert.Equal(t, nil, commitmentErr)
+	assert.Equal(t, commitmentC.GetCommitmentHash(), commitment.GetCommitmentHash())
+}
+
+// Test Server GetAttestationForCommitment
+func TestServerGetAttestationForCommitment(t *testing.T) {
+	//TEST INIT
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashW, _ := chainhash.NewHashFromStr("daaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// check no attestations
+	proofs, err := server.GetAttestationForCommitment(*hashX)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(proofs))
+
+	// add confirmed attestation
+	latestCommitment0, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashY, *hashZ})
+	txid0, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest0 := models.NewAttestation(*txid0, latestCommitment0)
+	latest0.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest0))
+
+	// add unconfirmed attestation
+	latestCommitment1, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashW})
+	txid1, _ := chainhash.NewHashFromStr("21111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	latest1 := models.NewAttestation(*txid1, latestCommitment1)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest1))
+
+	// check commitment in confirmed attestation
+	proofs, err = server.GetAttestationForCommitment(*hashY)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(proofs))
+	assert.Equal(t, *txid0, proofs[0].Txid)
+	assert.Equal(t, latestCommitment0.GetMerkleProofs()[1], proofs[0].Proof)
+	assert.Equal(t, true, models.ProveMerkleProof(proofs[0].Proof))
+
+	// check commitment in unconfirmed attestation ignored
+	proofs, err = server.GetAttestationForCommitment(*hashW)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(proofs))
+	proofs, err = server.GetAttestationForCommitment(*hashX)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(proofs))
+	assert.Equal(t, *txid0, proofs[0].Txid)
+
+	// check commitment in both attestations once confirmed
+	latest1.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest1))
+	proofs, err = server.GetAttestationForCommitment(*hashX)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(proofs))
+	assert.Equal(t, *txid0, proofs[0].Txid)
+	assert.Equal(t, latestCommitment0.GetMerkleProofs()[0], proofs[0].Proof)
+	assert.Equal(t, *txid1, proofs[1].Txid)
+	assert.Equal(t, latestCommitment1.GetMerkleProofs()[0], proofs[1].Proof)
+	assert.Equal(t, true, models.ProveMerkleProof(proofs[1].Proof))
+
+	// check unknown commitment
+	proofs, err = server.GetAttestationForCommitment(chainhash.Hash{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(proofs))
+}
+
+// Test Server GetAttestationsForPosition for commitment non-inclusion
+func TestServerGetAttestationsForPosition(t *testing.T) {
+	dbFake := NewDbFake()
+	server := NewServer(dbFake)
+
+	hashX, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashY, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashZ, _ := chainhash.NewHashFromStr("caaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hashW, _ := chainhash.NewHashFromStr("daaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid0, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid1, _ := chainhash.NewHashFromStr("21111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	txid2, _ := chainhash.NewHashFromStr("31111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	time0 := time.Unix(1542121293, 0)
+	time1 := time0.Add(time.Hour)
+	time2 := time1.Add(time.Hour)
+
+	// check no attestations
+	attestations, err := server.GetAttestationsForPosition(1, time0, time2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(attestations))
+
+	// confirmed attestation with commitment at position
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hashX, 0}, models.ClientCommitment{*hashY, 1}})
+	dbFake.SetClientCommitmentUpdateTime(1, time0)
+	commitment0, _ := server.GetClientCommitment()
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(commitment0))
+	latest0 := models.NewAttestation(*txid0, &commitment0)
+	latest0.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest0))
+	dbFake.SetAttestationInsertTime(*txid0, time0)
+
+	// confirmed attestation without commitment at position
+	dbFake.SetClientCommitments([]models.ClientCommitment{
+		models.ClientCommitment{*hashX, 0}, models.ClientCommitment{*hashZ, 2}})
+	commitment1, _ := server.GetClientCommitment()
+	assert.Equal(t, nil, server.SaveCommitmentSnapshot(commitment1))
+	latest1 := models.NewAttestation(*txid1, &commitment1)
+	latest1.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest1))
+	dbFake.SetAttestationInsertTime(*txid1, time1)
+
+	// confirmed attestation without snapshot
+	commitment2, _ := models.NewCommitment([]chainhash.Hash{*hashX, *hashW})
+	latest2 := models.NewAttestation(*txid2, commitment2)
+	latest2.Confirmed = true
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest2))
+	dbFake.SetAttestationInsertTime(*txid2, time2)
+
+	// check attestations in window with commitment at position
+	attestations, err = server.GetAttestationsForPosition(1, time0, time2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []AttestationPositionCommitment{
+		AttestationPositionCommitment{*txid0, commitment0.GetCommitmentHash(), time0, true, *hashY, time0},
+		AttestationPositionCommitment{*txid1, commitment1.GetCommitmentHash(), time1, true, chainhash.Hash{}, time.Time{}},
+	}, attestations)
+	assert.Equal(t, true, IsCommitmentExcluded(attestations, *hashW))
+	assert.Equal(t, false, IsCommitmentExcluded(attestations, *hashY))
+	assert.Equal(t, true, IsCommitmentExcluded(attestations[1:], *hashY))
+
+	// check exclusion cannot be shown for attestation without snapshot
+	attestations, err = server.GetAttestationsForPosition(1, time1, time2.Add(time.Second))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(attestations))
+	assert.Equal(t, *txid2, attestations[1].Txid)
+	assert.Equal(t, false, attestations[1].Snapshot)
+	assert.Equal(t, false, IsCommitmentExcluded(attestations, *hashW))
+
+	// check unconfirmed attestations ignored
+	latest2.Confirmed = false
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*latest2))
+	dbFake.SetAttestationInsertTime(*txid2, time2)
+	attestations, err = server.GetAttestationsForPosition(1, time2, time2.Add(time.Second))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(attestations))
+}
@@ -0,0 +1,363 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// Utility functions to buffer server db writes that fail while the db is
+// temporarily unavailable. Failed attestation and client commitment writes
+// are appended to a local durable buffer file and replayed in order once the
+// db recovers, so that e.g. an attestation that confirmed on-chain is never
+// lost from the db due to a momentary outage. Db writes are upserts, so a
+// partially applied write can be safely replayed
+// Writes that can never be replayed, i.e. corrupt writes or writes rejected
+// by the db while it is available, are moved to a quarantine file next to
+// the buffer file for manual recovery, so that they do not block the writes
+// buffered after them
+
+// error - warning consts
+const (
+	ErrorWriteBufferPersist = "Failed persisting db write buffer"
+	ErrorWriteBufferInvalid = "Invalid buffered db write"
+
+	WarningWriteBuffered     = "Warning - Db write failed - buffered for retry"
+	WarningWriteBufferFlush  = "Warning - Buffered db writes not flushed - db still unavailable"
+	WarningWriteBufferLoad   = "Warning - Failed loading db write buffer - write buffering disabled"
+	WarningWriteBufferFailed = "Warning - Failed buffering db write"
+	WarningWriteQuarantined  = "Warning - Buffered db write cannot be replayed - moved to quarantine"
+)
+
+// interval between retries of buffered db writes
+const DefaultWriteBufferInterval = 10 * time.Second
+
+// number of replays rejected while the db is available before a write is quarantined
+const DefaultWriteBufferMaxRejects = 3
+
+// suffix of the quarantine file path appended to the buffer file path
+const writeBufferQuarantineSuffix = ".quarantine"
+
+// buffered db write types
+const (
+	bufferedWriteAttestation      = "attestation"
+	bufferedWriteClientCommitment = "client_commitment"
+)
+
+// bufferedWrite structure
+// Db write persisted in the write buffer file until replayed
+type bufferedWrite struct {
+	Type       string    `json:"type"`
+	BufferedAt time.Time `json:"buffered_at"`
+
+	// attestation write - serialized tx and commitment leaves
+	Txid          string                 `json:"txid,omitempty"`
+	Tx            string                 `json:"tx,omitempty"`
+	Confirmed     bool                   `json:"confirmed,omitempty"`
+	Info          models.AttestationInfo `json:"info"`
+	Leaves        []string               `json:"leaves,omitempty"`
+	LeafPositions []int32                `json:"leaf_positions,omitempty"`
+
+	// client commitment write
+	Commitment     string `json:"commitment,omitempty"`
+	ClientPosition int32  `json:"client_position,omitempty"`
+
+	// number of replays rejected while the db was available
+	Rejects int `json:"rejects,omitempty"`
+}
+
+// Return buffered write of an attestation along with its commitment
+func newAttestationWrite(attestation models.Attestation, commitment *models.Commitment) bufferedWrite {
+	write := bufferedWrite{
+		Type:      bufferedWriteAttestation,
+		Txid:      attestation.Txid.String(),
+		Confirmed: attestation.Confirmed,
+		Info:      attestation.Info}
+	if len(attestation.Tx.TxIn) > 0 {
+		var txBuffer bytes.Buffer
+		attestation.Tx.Serialize(&txBuffer)
+		write.Tx = hex.EncodeToString(txBuffer.Bytes())
+	}
+	for _, c := range commitment.GetMerkleCommitments() {
+		write.Leaves = append(write.Leaves, c.Commitment.String())
+		write.LeafPositions = append(write.LeafPositions, c.ClientPosition)
+	}
+	return write
+}
+
+// Return buffered write of a client commitment
+func newClientCommitmentWrite(commitment models.ClientCommitment) bufferedWrite {
+	return bufferedWrite{
+		Type:           bufferedWriteClientCommitment,
+		Commitment:     commitment.Commitment.String(),
+		ClientPosition: commitment.ClientPosition}
+}
+
+// Rebuild attestation along with its commitment from a buffered write
+func (b bufferedWrite) attestation() (models.Attestation, error) {
+	txid, txidErr := chainhash.NewHashFromStr(b.Txid)
+	if txidErr != nil {
+		return models.Attestation{}, txidErr
+	}
+	var leaves []chainhash.Hash
+	for _, leaf := range b.Leaves {
+		hash, hashErr := chainhash.NewHashFromStr(leaf)
+		if hashErr != nil {
+			return models.Attestation{}, hashErr
+		}
+		leaves = append(leaves, *hash)
+	}
+	commitment, commitmentErr := models.NewCommitment(leaves)
+	if commitmentErr != nil {
+		return models.Attestation{}, commitmentErr
+	}
+	commitment.SetLeafPositions(b.LeafPositions)
+
+	attestation := models.NewAttestation(*txid, commitment)
+	txBytes, txBytesErr := hex.DecodeString(b.Tx)
+	if txBytesErr != nil {
+		return models.Attestation{}, txBytesErr
+	}
+	if len(txBytes) > 0 {
+		if txErr := attestation.Tx.Deserialize(bytes.NewReader(txBytes)); txErr != nil {
+			return models.Attestation{}, txErr
+		}
+	}
+	attestation.Confirmed = b.Confirmed
+	attestation.Info = b.Info
+	return *attestation, nil
+}
+
+// Rebuild client commitment from a buffered write
+func (b bufferedWrite) clientCommitment() (models.ClientCommitment, error) {
+	hash, hashErr := chainhash.NewHashFromStr(b.Commitment)
+	if hashErr != nil {
+		return models.ClientCommitment{}, hashErr
+	}
+	return models.ClientCommitment{Commitment: *hash, ClientPosition: b.ClientPosition}, nil
+}
+
+// writeBuffer structure
+// Ordered db writes pending replay, mirrored to the buffer file
+type writeBuffer struct {
+	// mutex guarding buffer access
+	mutex sync.Mutex
+
+	// path of the buffer file
+	path string
+
+	// buffered writes in the order received
+	writes []bufferedWrite
+}
+
+// Return new write buffer for the buffer file path provided
+// Writes buffered before a restart are loaded from the buffer file
+func newWriteBuffer(path string) (*writeBuffer, error) {
+	b := &writeBuffer{path: path}
+	data, readErr := ioutil.ReadFile(path)
+	if os.IsNotExist(readErr) {
+		return b, nil
+	} else if readErr != nil {
+		return nil, readErr
+	}
+	if len(data) > 0 {
+		if unmarshalErr := json.Unmarshal(data, &b.writes); unmarshalErr != nil {
+			return nil, unmarshalErr
+		}
+	}
+	if len(b.writes) > 0 {
+		log.Printf("*Server* Loaded %d buffered db writes from %s\n", len(b.writes), path)
+	}
+	return b, nil
+}
+
+// Persist buffered writes to the buffer file
+// The file is replaced atomically so that a crash never leaves it partially written
+func (b *writeBuffer) persist() error {
+	data, marshalErr := json.Marshal(b.writes)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	tmpPath := b.path + ".tmp"
+	if writeErr := ioutil.WriteFile(tmpPath, data, 0600); writeErr != nil {
+		return writeErr
+	}
+	return os.Rename(tmpPath, b.path)
+}
+
+// Append a write that cannot be replayed to the quarantine file
+func (b *writeBuffer) quarantine(write bufferedWrite) error {
+	path := b.path + writeBufferQuarantineSuffix
+	var writes []bufferedWrite
+	data, readErr := ioutil.ReadFile(path)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		return readErr
+	}
+	if len(data) > 0 {
+		if unmarshalErr := json.Unmarshal(data, &writes); unmarshalErr != nil {
+			return unmarshalErr
+		}
+	}
+	data, marshalErr := json.Marshal(append(writes, write))
+	if marshalErr != nil {
+		return marshalErr
+	}
+	tmpPath := path + ".tmp"
+	if writeErr := ioutil.WriteFile(tmpPath, data, 0600); writeErr != nil {
+		return writeErr
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Buffer a db write that failed with the error provided
+// The write error is returned if buffering is disabled or
+// the write could not be persisted to the buffer file
+func (s *Server) bufferWrite(write bufferedWrite, writeErr error) error {
+	if s.writeBuffer == nil {
+		return writeErr
+	}
+	b := s.writeBuffer
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	write.BufferedAt = s.clock.Now()
+	b.writes = append(b.writes, write)
+	if persistErr := b.persist(); persistErr != nil {
+		b.writes = b.writes[:len(b.writes)-1]
+		log.Printf("*Server* %s (%s) %v\n", WarningWriteBufferFailed, write.Type, persistErr)
+		return writeErr
+	}
+	log.Printf("*Server* %s (%s) %v\n", WarningWriteBuffered, write.Type, writeErr)
+	return nil
+}
+
+// Replay buffered db writes in order, removing each write once saved
+// Replaying stops at the first write that fails, e.g. while the db is
+// still unavailable, and the number of writes flushed is returned
+// Invalid writes are quarantined straight away, while writes failing with
+// the db available are quarantined once rejected the maximum number of times
+func (s *Server) FlushWriteBuffer() (int, error) {
+	if s.writeBuffer == nil {
+		return 0, nil
+	}
+	b := s.writeBuffer
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	flushed := 0
+	changed := false
+	var replayErr error
+	for len(b.writes) > 0 {
+		invalid, err := s.replayWrite(b.writes[0])
+		if err == nil {
+			b.writes = b.writes[1:]
+			flushed++
+			changed = true
+			continue
+		}
+		replayErr = err
+		if !invalid {
+			if !s.isDbAvailable() {
+				break // db unavailable - retry later
+			}
+			b.writes[0].Rejects++
+			changed = true
+			if b.writes[0].Rejects < DefaultWriteBufferMaxRejects {
+				break
+			}
+		}
+		if quarantineErr := b.quarantine(b.writes[0]); quarantineErr != nil {
+			log.Printf("*Server* %s %v\n", ErrorWriteBufferPersist, quarantineErr)
+			break
+		}
+		log.Printf("*Server* %s (%s) %v\n", WarningWriteQuarantined, b.writes[0].Type, replayErr)
+		b.writes = b.writes[1:]
+		changed = true
+		replayErr = nil
+	}
+	if changed {
+		if persistErr := b.persist(); persistErr != nil {
+			return flushed, errors.New(fmt.Sprintf("%s %v", ErrorWriteBufferPersist, persistErr))
+		}
+	}
+	if flushed > 0 {
+		log.Printf("*Server* Flushed %d buffered db writes\n", flushed)
+	}
+	return flushed, replayErr
+}
+
+// Check if the db is available by reading the latest attestation, so that
+// a buffered write failing while the db is available is known to be rejected
+func (s *Server) isDbAvailable() bool {
+	_, latestErr := s.dbInterface.getLatestAttestationMerkleRoot(true)
+	return latestErr == nil
+}
+
+// Return the number of buffered db writes pending replay
+func (s *Server) BufferedWrites() int {
+	if s.writeBuffer == nil {
+		return 0
+	}
+	s.writeBuffer.mutex.Lock()
+	defer s.writeBuffer.mutex.Unlock()
+	return len(s.writeBuffer.writes)
+}
+
+// Replay a buffered db write
+// Returns true along with the error if the write is invalid and can never be replayed
+func (s *Server) replayWrite(write bufferedWrite) (bool, error) {
+	switch write.Type {
+	case bufferedWriteAttestation:
+		attestation, attestationErr := write.attestation()
+		if attestationErr != nil {
+			return true, errors.New(fmt.Sprintf("%s (%s) %v", ErrorWriteBufferInvalid, write.Type, attestationErr))
+		}
+		return false, s.saveLatestAttestation(attestation)
+	case bufferedWriteClientCommitment:
+		commitment, commitmentErr := write.clientCommitment()
+		if commitmentErr != nil {
+			return true, errors.New(fmt.Sprintf("%s (%s) %v", ErrorWriteBufferInvalid, write.Type, commitmentErr))
+		}
+		_, saveErr := s.dbInterface.saveClientCommitment(commitment)
+		s.cache.invalidateClientCommitment()
+		return false, saveErr
+	}
+	return true, errors.New(fmt.Sprintf("%s (%s)", ErrorWriteBufferInvalid, write.Type))
+}
+
+// Retry buffered db writes periodically until the context is cancelled
+// Returns immediately if write buffering is not set. Failures are logged and retried
+func (s *Server) RunWriteBuffer(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if s.writeBuffer == nil {
+		return
+	}
+	ticker := time.NewTicker(DefaultWriteBufferInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, flushErr := s.FlushWriteBuffer(); flushErr != nil {
+				log.Printf("*Server* %s (%d pending) %v\n", WarningWriteBufferFlush, s.BufferedWrites(), flushErr)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mainstay/config"
+	"mainstay/models"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Server buffering db writes while the db is unavailable
+func TestServerWriteBuffer(t *testing.T) {
+	// TEST INIT
+	dir, dirErr := ioutil.TempDir("", "mainstay-writebuffer")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "writebuffer.json")
+	serverConfig := config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", "", path, -1, -1}
+
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, serverConfig)
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	commitment, _ := models.NewCommitment([]chainhash.Hash{*hash0, *hash1})
+	txid, _ := chainhash.NewHashFromStr("11111111111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	attestation := models.NewAttestation(*txid, commitment)
+	attestation.Confirmed = true
+	attestation.Info = models.AttestationInfo{Txid: txid.String(), Blockhash: "abcd", Amount: 1, Time: 1542121293}
+
+	// Test writes buffered while the db is unavailable
+	dbFake.SetUnavailable(true)
+	assert.Equal(t, nil, server.UpdateLatestAttestation(*attestation))
+	assert.Equal(t, 1, server.BufferedWrites())
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, 2, server.BufferedWrites())
+	assert.Equal(t, 0, len(dbFake.attestations))
+
+	// Test latest attestation reads fail until buffered writes are flushed
+	_, _, lookupErr := server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, errors.New(ErrorDbFakeUnavailable), lookupErr)
+	flushed, flushErr := server.FlushWriteBuffer()
+	assert.Equal(t, 0, flushed)
+	assert.Equal(t, errors.New(ErrorDbFakeUnavailable), flushErr)
+
+	// Test buffered writes loaded from the buffer file on restart
+	server = NewServer(dbFake, serverConfig)
+	assert.Equal(t, 2, server.BufferedWrites())
+
+	// Test buffered writes replayed in order once the db recovers
+	dbFake.SetUnavailable(false)
+	hash, attested, lookupErr := server.LookupLatestAttestationCommitmentHash()
+	assert.Equal(t, nil, lookupErr)
+	assert.Equal(t, true, attested)
+	assert.Equal(t, commitment.GetCommitmentHash(), hash)
+	assert.Equal(t, 0, server.BufferedWrites())
+
+	assert.Equal(t, 1, len(dbFake.attestations))
+	assert.Equal(t, attestation.Info, dbFake.attestationsInfo[0])
+	assert.Equal(t, commitment.GetMerkleCommitments(), dbFake.merkleCommitments)
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{*hash1, 1}}, dbFake.latestCommitments)
+
+	// Test empty buffer persisted after flushing
+	server = NewServer(dbFake, serverConfig)
+	assert.Equal(t, 0, server.BufferedWrites())
+
+	// Test write errors returned with write buffering disabled
+	dbFake.SetUnavailable(true)
+	server = NewServer(dbFake)
+	assert.Equal(t, errors.New(ErrorDbFakeUnavailable), server.UpdateLatestAttestation(*attestation))
+	assert.Equal(t, 0, server.BufferedWrites())
+}
+
+// Test Server quarantining buffered db writes that can never be replayed
+func TestServerWriteBuffer_Quarantine(t *testing.T) {
+	// TEST INIT
+	dir, dirErr := ioutil.TempDir("", "mainstay-writebuffer")
+	assert.Equal(t, nil, dirErr)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "writebuffer.json")
+	quarantinePath := path + writeBufferQuarantineSuffix
+	serverConfig := config.ServerConfig{-1, -1, -1, -1, -1, -1, -1, -1, "", "", "", path, -1, -1}
+
+	hash0, _ := chainhash.NewHashFromStr("aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+	hash1, _ := chainhash.NewHashFromStr("baaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7")
+
+	// Test invalid and corrupt writes quarantined without blocking later writes
+	data, _ := json.Marshal([]bufferedWrite{
+		bufferedWrite{Type: "invalid"},
+		bufferedWrite{Type: bufferedWriteClientCommitment, Commitment: "corrupt"},
+		newClientCommitmentWrite(models.ClientCommitment{*hash0, 0})})
+	assert.Equal(t, nil, ioutil.WriteFile(path, data, 0600))
+
+	dbFake := NewDbFake()
+	server := NewServer(dbFake, serverConfig)
+	assert.Equal(t, 3, server.BufferedWrites())
+	flushed, flushErr := server.FlushWriteBuffer()
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, nil, flushErr)
+	assert.Equal(t, 0, server.BufferedWrites())
+	assert.Equal(t, []models.ClientCommitment{models.ClientCommitment{*hash0, 0}}, dbFake.latestCommitments)
+
+	var quarantined []bufferedWrite
+	data, _ = ioutil.ReadFile(quarantinePath)
+	assert.Equal(t, nil, json.Unmarshal(data, &quarantined))
+	assert.Equal(t, 2, len(quarantined))
+	assert.Equal(t, "invalid", quarantined[0].Type)
+	assert.Equal(t, "corrupt", quarantined[1].Commitment)
+
+	// Test writes failing while the db is unavailable never quarantined
+	dbFake.SetUnavailable(true)
+	_, saveErr := server.SaveClientCommitment(models.ClientCommitment{*hash1, 1})
+	assert.Equal(t, nil, saveErr)
+	for i := 0; i < DefaultWriteBufferMaxRejects+1; i++ {
+		_, flushErr = server.FlushWriteBuffer()
+		assert.Equal(t, errors.New(ErrorDbFakeUnavailable), flushErr)
+	}
+	assert.Equal(t, 1, server.BufferedWrites())
+	dbFake.SetUnavailable(false)
+	flushed, flushErr = server.FlushWriteBuffer()
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, nil, flushErr)
+
+	// Test write rejected with the db available quarantined after max rejects
+	dbFake.SetRejectWrites(true)
+	_, saveErr = server.SaveClientCommitment(models.ClientCommitment{*hash0, 2})
+	assert.Equal(t, nil, saveErr)
+	assert.Equal(t, 1, server.BufferedWrites())
+	for i := 0; i < DefaultWriteBufferMaxRejects-1; i++ {
+		_, flushErr = server.FlushWriteBuffer()
+		assert.Equal(t, errors.New(ErrorDbFakeRejected), flushErr)
+		assert.Equal(t, 1, server.BufferedWrites())
+	}
+	server = NewServer(dbFake, serverConfig)
+	flushed, flushErr = server.FlushWriteBuffer()
+	assert.Equal(t, 0, flushed)
+	assert.Equal(t, nil, flushErr)
+	assert.Equal(t, 0, server.BufferedWrites())
+
+	data, _ = ioutil.ReadFile(quarantinePath)
+	assert.Equal(t, nil, json.Unmarshal(data, &quarantined))
+	assert.Equal(t, 3, len(quarantined))
+	assert.Equal(t, newClientCommitmentWrite(models.ClientCommitment{*hash0, 2}).Commitment, quarantined[2].Commitment)
+	assert.Equal(t, DefaultWriteBufferMaxRejects, quarantined[2].Rejects)
+	dbFake.SetRejectWrites(false)
+}
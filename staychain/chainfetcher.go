@@ -11,6 +11,10 @@ import (
 	"github.com/btcsuite/btcd/rpcclient"
 )
 
+// default number of blocks fetched per batch of asynchronous rpc requests
+// all blocks of a batch are held in memory until the batch is searched
+const DefaultFetchBatchSize = 10
+
 // ChainFetcher struct
 // Struct that handles fetching transactions of the attestation
 // chain by searching each main client block and trying to match
@@ -20,6 +24,7 @@ type ChainFetcher struct {
 	txid0        string
 	latestTx     Tx
 	latestHeight int64
+	batchSize    int64
 }
 
 // Get initial tx from main client and return fetcher instance
@@ -27,11 +32,20 @@ func NewChainFetcher(main *rpcclient.Client, tx Tx) ChainFetcher {
 	blockhash, _ := chainhash.NewHashFromStr(tx.BlockHash)
 	blockheader, _ := main.GetBlockHeaderVerbose(blockhash)
 
-	return ChainFetcher{main, tx.Txid, tx, int64(blockheader.Height)}
+	return ChainFetcher{main, tx.Txid, tx, int64(blockheader.Height), DefaultFetchBatchSize}
+}
+
+// Set number of blocks fetched per batch - values below 1 are ignored
+func (f *ChainFetcher) SetBatchSize(batchSize int64) {
+	if batchSize > 0 {
+		f.batchSize = batchSize
+	}
 }
 
-// Main method that tries to fetch the next transaction in the chan
+// Main method that tries to fetch the next transactions in the chain
 // and updates the latest main client block height that was tested
+// Blocks are fetched in batches of asynchronous rpc requests to reduce
+// round trips and all transactions found in a batch are returned in order
 func (f *ChainFetcher) Fetch() []Tx {
 	blockcount, errCount := f.mainClient.GetBlockCount()
 	if errCount != nil {
@@ -40,41 +54,77 @@ func (f *ChainFetcher) Fetch() []Tx {
 
 	height := f.latestHeight
 	for height < blockcount { // iterate through all blocks until latest
-		height += 1
-		tx, found := f.txInBlock(height)
-		if found { // if next tx found update latest and return
+		batchEnd := height + f.batchSize
+		if batchEnd > blockcount {
+			batchEnd = blockcount
+		}
+		txhashes := f.txsInBlocks(height+1, batchEnd)
+		height = batchEnd
+		if len(txhashes) > 0 { // if next txs found update latest and return
+			txs := f.getRawTxs(txhashes)
 			f.latestHeight = height
-			f.latestTx = tx
-			return []Tx{tx}
+			f.latestTx = txs[len(txs)-1]
+			return txs
 		}
 	}
 	f.latestHeight = height
 	return nil
 }
 
-// Search for a transaction in a block in which the vin hash
-// matches the hash of the previous transcaction in the chain
-func (f *ChainFetcher) txInBlock(height int64) (Tx, bool) {
-	// Get block for height specified
-	blockhash, errHash := f.mainClient.GetBlockHash(height)
-	if errHash != nil {
-		log.Fatal(errHash)
+// Search for transactions in blocks of the height range in which the vin
+// spends the staychain output of the previous transaction in the chain,
+// so that transactions spending the reserve output of two-output
+// attestations are not mistaken for the next attestation
+// Blocks are searched in height order to keep the chain order deterministic
+func (f *ChainFetcher) txsInBlocks(startHeight int64, endHeight int64) []chainhash.Hash {
+	// Get block hashes for heights specified
+	var hashFutures []rpcclient.FutureGetBlockHashResult
+	for height := startHeight; height <= endHeight; height++ {
+		hashFutures = append(hashFutures, f.mainClient.GetBlockHashAsync(height))
 	}
-	block, errBlock := f.mainClient.GetBlock(blockhash)
-	if errBlock != nil {
-		log.Fatal(errBlock)
+	var blockFutures []rpcclient.FutureGetBlockResult
+	for _, hashFuture := range hashFutures {
+		blockhash, errHash := hashFuture.Receive()
+		if errHash != nil {
+			log.Fatal(errHash)
+		}
+		blockFutures = append(blockFutures, f.mainClient.GetBlockAsync(blockhash))
 	}
 
-	// Iterate through block transactions searching for the next tx in the chain
-	for _, tx := range block.Transactions {
-		if tx.TxIn[0].PreviousOutPoint.Hash.String() == f.latestTx.Txid {
-			txhash := tx.TxHash()
-			txraw, errGet := f.mainClient.GetRawTransactionVerbose(&txhash)
-			if errGet != nil {
-				log.Fatal(errGet)
+	// Iterate through block transactions searching for the next txs in the chain
+	var txhashes []chainhash.Hash
+	latestTxid := f.latestTx.Txid
+	for _, blockFuture := range blockFutures {
+		block, errBlock := blockFuture.Receive()
+		if errBlock != nil {
+			log.Fatal(errBlock)
+		}
+		for _, tx := range block.Transactions {
+			// the staychain output is always vout 0 of the latest tx
+			prevOut := tx.TxIn[0].PreviousOutPoint
+			if prevOut.Index == 0 && prevOut.Hash.String() == latestTxid {
+				txhash := tx.TxHash()
+				txhashes = append(txhashes, txhash)
+				latestTxid = txhash.String()
 			}
-			return Tx(*txraw), true
 		}
 	}
-	return Tx{}, false
+	return txhashes
+}
+
+// Get verbose raw transactions for the transaction hashes in the same order
+func (f *ChainFetcher) getRawTxs(txhashes []chainhash.Hash) []Tx {
+	var txFutures []rpcclient.FutureGetRawTransactionVerboseResult
+	for i := range txhashes {
+		txFutures = append(txFutures, f.mainClient.GetRawTransactionVerboseAsync(&txhashes[i]))
+	}
+	var txs []Tx
+	for _, txFuture := range txFutures {
+		txraw, errGet := txFuture.Receive()
+		if errGet != nil {
+			log.Fatal(errGet)
+		}
+		txs = append(txs, Tx(*txraw))
+	}
+	return txs
 }
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package staychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// latency of each request served by the fake rpc server
+const fakeRpcLatency = 200 * time.Microsecond
+
+// fakeRpcServer struct
+// Serves the rpc requests of the chain fetcher for a staychain
+// of one transaction per block, with the init tx in block 0
+type fakeRpcServer struct {
+	blocks  []*wire.MsgBlock
+	heights map[string]int
+	txs     map[string]*wire.MsgTx
+	txids   []string
+}
+
+// Return fake rpc server with a staychain of length transactions after the init tx
+func newFakeRpcServer(length int) *fakeRpcServer {
+	f := &fakeRpcServer{heights: make(map[string]int), txs: make(map[string]*wire.MsgTx)}
+	var prevHash chainhash.Hash
+	for height := 0; height <= length; height++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&prevHash, 0), nil, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+		block := wire.NewMsgBlock(wire.NewBlockHeader(1, &chainhash.Hash{}, &chainhash.Hash{}, 0, uint32(height)))
+		block.AddTransaction(tx)
+
+		prevHash = tx.TxHash()
+		f.blocks = append(f.blocks, block)
+		f.heights[block.BlockHash().String()] = height
+		f.txs[prevHash.String()] = tx
+		f.txids = append(f.txids, prevHash.String())
+	}
+	return f
+}
+
+// Return result of a single rpc request
+func (f *fakeRpcServer) result(method string, params []json.RawMessage) interface{} {
+	var param string
+	if len(params) > 0 {
+		json.Unmarshal(params[0], &param)
+	}
+	switch method {
+	case "getblockcount":
+		return len(f.blocks) - 1
+	case "getblockhash":
+		var height int
+		json.Unmarshal(params[0], &height)
+		return f.blocks[height].BlockHash().String()
+	case "getblockheader":
+		if height, ok := f.heights[param]; ok {
+			return btcjson.GetBlockHeaderVerboseResult{Hash: param, Height: int32(height)}
+		}
+	case "getblock":
+		if height, ok := f.heights[param]; ok {
+			var buf bytes.Buffer
+			f.blocks[height].Serialize(&buf)
+			return hex.EncodeToString(buf.Bytes())
+		}
+	case "getrawtransaction":
+		if tx, ok := f.txs[param]; ok {
+			return btcjson.TxRawResult{Txid: param, Vout: []btcjson.Vout{{N: 0, Value: 0.00001}},
+				Vin: []btcjson.Vin{{Txid: tx.TxIn[0].PreviousOutPoint.Hash.String()}}}
+		}
+	}
+	return nil
+}
+
+// Serve single rpc requests after the fake latency
+func (f *fakeRpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(fakeRpcLatency)
+	var req struct {
+		ID     interface{}       `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id": req.ID, "result": f.result(req.Method, req.Params), "error": nil})
+}
+
+// Return fetcher of the fake rpc server staychain and the server to close
+func newTestFetcher(tb testing.TB, f *fakeRpcServer) (ChainFetcher, *httptest.Server) {
+	server := httptest.NewServer(f)
+	client, clientErr := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         strings.TrimPrefix(server.URL, "http://"),
+		User:         "user",
+		Pass:         "pass",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if clientErr != nil {
+		tb.Fatal(clientErr)
+	}
+	initTx := Tx{Txid: f.txids[0], BlockHash: f.blocks[0].BlockHash().String()}
+	return NewChainFetcher(client, initTx), server
+}
+
+// Test chain fetcher returns staychain transactions in order for any batch size
+func TestChainFetcher_Fetch(t *testing.T) {
+	f := newFakeRpcServer(25)
+	for _, batchSize := range []int64{1, 7, DefaultFetchBatchSize, 100} {
+		fetcher, server := newTestFetcher(t, f)
+		fetcher.SetBatchSize(batchSize)
+
+		var txids []string
+		for txs := fetcher.Fetch(); len(txs) > 0; txs = fetcher.Fetch() {
+			for _, tx := range txs {
+				txids = append(txids, tx.Txid)
+			}
+			if fetcher.latestTx.Txid != txids[len(txids)-1] {
+				t.Fatalf("batch size %d: latest tx %s not last fetched", batchSize, fetcher.latestTx.Txid)
+			}
+		}
+		if strings.Join(txids, ",") != strings.Join(f.txids[1:], ",") {
+			t.Fatalf("batch size %d: staychain txs fetched out of order", batchSize)
+		}
+		if fetcher.latestHeight != int64(len(f.blocks)-1) {
+			t.Fatalf("batch size %d: latest height %d", batchSize, fetcher.latestHeight)
+		}
+		server.Close()
+	}
+}
+
+// Test chain fetcher skipping transactions that spend outputs of the latest
+// staychain transaction other than the staychain output, e.g. the reserve output
+func TestChainFetcher_FetchSkipsReserveSpend(t *testing.T) {
+	f := newFakeRpcServer(5)
+	for height := 2; height < len(f.blocks); height++ {
+		prevHash, _ := chainhash.NewHashFromStr(f.txids[height-1])
+		reserveTx := wire.NewMsgTx(wire.TxVersion)
+		reserveTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(prevHash, 1), nil, nil))
+		reserveTx.AddTxOut(wire.NewTxOut(500, []byte{0x51}))
+		f.txs[reserveTx.TxHash().String()] = reserveTx
+		f.blocks[height].Transactions = append([]*wire.MsgTx{reserveTx}, f.blocks[height].Transactions...)
+	}
+	fetcher, server := newTestFetcher(t, f)
+	defer server.Close()
+
+	var txids []string
+	for txs := fetcher.Fetch(); len(txs) > 0; txs = fetcher.Fetch() {
+		for _, tx := range txs {
+			txids = append(txids, tx.Txid)
+		}
+	}
+	if strings.Join(txids, ",") != strings.Join(f.txids[1:], ",") {
+		t.Fatalf("reserve spending txs fetched as staychain txs: %v", txids)
+	}
+}
+
+// Benchmark fetching a 1000 tx staychain with and without batching
+func benchmarkChainFetcher(b *testing.B, batchSize int64) {
+	f := newFakeRpcServer(1000)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		fetcher, server := newTestFetcher(b, f)
+		fetcher.SetBatchSize(batchSize)
+		b.StartTimer()
+		for txs := fetcher.Fetch(); len(txs) > 0; txs = fetcher.Fetch() {
+		}
+		b.StopTimer()
+		server.Close()
+	}
+}
+
+func BenchmarkChainFetcher_Unbatched(b *testing.B) { benchmarkChainFetcher(b, 1) }
+func BenchmarkChainFetcher_Batched(b *testing.B)   { benchmarkChainFetcher(b, DefaultFetchBatchSize) }
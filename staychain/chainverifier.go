@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 
 	"mainstay/clients"
 	"mainstay/crypto"
@@ -18,6 +19,8 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
 )
 
@@ -28,6 +31,11 @@ const (
 	ApiCommitmentProofUrl = "/api/v1/commitment/proof"
 )
 
+// chain verifier error consts
+const (
+	ErrorChaincodesMismatch = "Tweaked address does not match the transaction address - chaincodes provided are wrong"
+)
+
 // Helper function to get response from mainstay api for url provided
 func getApiResponse(url string) (map[string]interface{}, error) {
 	resp, getErr := http.Get(url)
@@ -82,18 +90,23 @@ func (e *ChainVerifierError) Error() string {
 // Verifies that attestations are part of the staychain
 // Does basic validation checks and address tweaking checks
 // Verify client commitment included in attestation by proving SPV merkle proof
+// Multiple sidechains can be verified, each with a client at a different
+// position of the commitment merkle tree
 type ChainVerifier struct {
-	sideClient   clients.SidechainClient
+	sideClients  map[int]clients.SidechainClient
 	apiHost      string
 	cfgMain      *chaincfg.Params
-	position     int
 	pubkeys      []*hdkeychain.ExtendedKey
 	numOfSigs    int
 	latestHeight int64
+
+	// reserve address of two-output attestations - disabled if not set
+	reserveAddress string
 }
 
-// Return new Chain Verifier instance that verifies attestations on the side chain
-func NewChainVerifier(cfgMain *chaincfg.Params, side clients.SidechainClient, position int, script string, chaincodesStr []string, host string) ChainVerifier {
+// Return new Chain Verifier instance that verifies attestations on the side chains
+// Side chain clients are provided in a map of client position to sidechain client
+func NewChainVerifier(cfgMain *chaincfg.Params, sides map[int]clients.SidechainClient, script string, chaincodesStr []string, host string) ChainVerifier {
 
 	// parse base pubkeys from multisig redeemscript of attestation service
 	pubkeys, numOfSigs := crypto.ParseRedeemScript(script)
@@ -124,65 +137,137 @@ func NewChainVerifier(cfgMain *chaincfg.Params, side clients.SidechainClient, po
 			hdkeychain.NewExtendedKey([]byte{}, pub.SerializeCompressed(), chaincodes[i_p], []byte{}, 0, 0, false))
 	}
 
-	return ChainVerifier{side, host, cfgMain, position, pubkeysExtended, numOfSigs, 0}
+	return ChainVerifier{sides, host, cfgMain, pubkeysExtended, numOfSigs, 0, ""}
+}
+
+// Set the reserve address that the last vout of two-output attestations
+// pays the excess over the attestation amount to
+func (v *ChainVerifier) SetReserveAddress(reserveAddress string) {
+	v.reserveAddress = reserveAddress
+}
+
+// Check if the vout is the reserve output of a two-output attestation,
+// i.e. the last vout paying to the reserve address if one is set
+func (v *ChainVerifier) isReserveOutput(tx Tx, i int) bool {
+	addresses := tx.Vout[i].ScriptPubKey.Addresses
+	return v.reserveAddress != "" && i > 0 && i == len(tx.Vout)-1 &&
+		len(addresses) == 1 && addresses[0] == v.reserveAddress
 }
 
 // Basic verification for vout size and number of addresses
-func verifyTxBasic(tx Tx) error {
-	if len(tx.Vout) != 1 {
-		return &ChainVerifierError{"Attestation TX does not have a single vout."}
+// Any vouts after the first are redundant OP_RETURN commitment outputs,
+// apart from the last vout that may be the reserve output
+func (v *ChainVerifier) verifyTxBasic(tx Tx) error {
+	if len(tx.Vout) == 0 {
+		return &ChainVerifierError{"Attestation TX does not have a vout."}
 	}
 
 	if len(tx.Vout[0].ScriptPubKey.Addresses) != 1 {
 		return &ChainVerifierError{"Attestation TX does not have a single address."}
 	}
 
+	for i, vout := range tx.Vout {
+		if i == 0 || v.isReserveOutput(tx, i) {
+			continue
+		}
+		if vout.ScriptPubKey.Type != txscript.NullDataTy.String() {
+			return &ChainVerifierError{"Attestation TX has additional vout that is not OP_RETURN or reserve."}
+		}
+	}
+
 	return nil
 }
 
-// Verify that the transaction destination address has been generated by
-// tweaking the initial multisig public keys with the correct commitment hash
-// This commitment hash is provided via the mainstay API and we confirmed tweaking
-func (v *ChainVerifier) verifyTxAddr(tx Tx, root string) error {
-	// get target destination address from transaction
-	txaddr := tx.Vout[0].ScriptPubKey.Addresses[0]
-	log.Printf("txaddr: %s\n", txaddr)
+// Verify that redundant OP_RETURN vouts push the attestation commitment
+func (v *ChainVerifier) verifyTxRedundantOutputs(tx Tx, root string) error {
+	rootHash, rootErr := chainhash.NewHashFromStr(root)
+	if rootErr != nil {
+		return &ChainVerifierError{rootErr.Error()}
+	}
+	script, scriptErr := txscript.NullDataScript(rootHash.CloneBytes())
+	if scriptErr != nil {
+		return &ChainVerifierError{scriptErr.Error()}
+	}
+	for i, vout := range tx.Vout {
+		if i == 0 || v.isReserveOutput(tx, i) {
+			continue
+		}
+		if vout.ScriptPubKey.Hex != hex.EncodeToString(script) {
+			return &ChainVerifierError{"OP_RETURN vout does not match the attestation commitment"}
+		}
+	}
+	return nil
+}
 
-	rootHash, _ := chainhash.NewHashFromStr(root)
+// Derive the expected multisig address of an attestation by tweaking the
+// base multisig pubkeys with the attestation commitment hash
+// Pseudo bip-32 child derivation is used so chaincodes of pubkeys are required
+func (v *ChainVerifier) DeriveAddress(commitment chainhash.Hash) (btcutil.Address, error) {
 	var tweakedPubs []*btcec.PublicKey
-	commitmentBytes := rootHash.CloneBytes()
+	commitmentBytes := commitment.CloneBytes()
 
-	// tweak base pubkey with commitment from api
+	// tweak base pubkey with commitment
 	for _, pub := range v.pubkeys {
 		// tweak extended pubkeys
-		// pseudo bip-32 child derivation to do pub key tweaking
 		tweakedKey, tweakErr := crypto.TweakExtendedKey(pub, commitmentBytes)
 		if tweakErr != nil {
-			return &ChainVerifierError{tweakErr.Error()}
+			return nil, &ChainVerifierError{tweakErr.Error()}
 		}
 		tweakedPub, tweakPubErr := tweakedKey.ECPubKey()
 		if tweakPubErr != nil {
-			return &ChainVerifierError{tweakPubErr.Error()}
+			return nil, &ChainVerifierError{tweakPubErr.Error()}
 		}
 		tweakedPubs = append(tweakedPubs, tweakedPub)
 	}
 	tweakedAddr, _ := crypto.CreateMultisig(tweakedPubs, v.numOfSigs, v.cfgMain)
+	return tweakedAddr, nil
+}
+
+// Verify that the chaincodes of the verifier derive the transaction destination
+// address from the base multisig pubkeys and the attestation commitment hash
+// As the commitment is the one attested, a mismatch means the chaincodes are wrong
+func (v *ChainVerifier) VerifyChaincodes(tx Tx, commitment chainhash.Hash) error {
+	errBasic := v.verifyTxBasic(tx)
+	if errBasic != nil {
+		return errBasic
+	}
+
+	// get target destination address from transaction
+	txaddr := tx.Vout[0].ScriptPubKey.Addresses[0]
+	log.Printf("txaddr: %s\n", txaddr)
+
+	tweakedAddr, tweakedAddrErr := v.DeriveAddress(commitment)
+	if tweakedAddrErr != nil {
+		return tweakedAddrErr
+	}
 
 	// verify tweaked addr is the same as the addr in the transaction
 	if tweakedAddr.String() == txaddr {
 		return nil
 	}
 
-	return &ChainVerifierError{"Tweaked address does not match the transaction address"}
+	return &ChainVerifierError{fmt.Sprintf("%s (commitment %s derived address %s != tx address %s)",
+		ErrorChaincodesMismatch, commitment.String(), tweakedAddr.String(), txaddr)}
+}
+
+// Verify that the transaction destination address has been generated by
+// tweaking the initial multisig public keys with the correct commitment hash
+// This commitment hash is provided via the mainstay API and we confirmed tweaking
+func (v *ChainVerifier) verifyTxAddr(tx Tx, root string) error {
+	rootHash, rootErr := chainhash.NewHashFromStr(root)
+	if rootErr != nil {
+		return &ChainVerifierError{rootErr.Error()}
+	}
+	return v.VerifyChaincodes(tx, *rootHash)
 }
 
 // Verify that the commitment used to generate the destination address
 // includes the client commitment in the designated client position
 // Proof this using an SPV merkle proof via an API call to mainstay service
-func (v *ChainVerifier) verifyCommitmentProof(commitment string, root string) error {
+func (v *ChainVerifier) verifyCommitmentProof(position int, commitment string, root string) error {
 	// get client commitment proof via api call
 	respProof, respProofErr := getApiResponse(fmt.Sprintf("%s%s?position=%d&merkle_root=%s",
-		v.apiHost, ApiCommitmentProofUrl, v.position, root))
+		v.apiHost, ApiCommitmentProofUrl, position, root))
 	if respProofErr != nil {
 		return respProofErr
 	}
@@ -195,7 +280,7 @@ func (v *ChainVerifier) verifyCommitmentProof(commitment string, root string) er
 	rootHash, _ := chainhash.NewHashFromStr(root)
 	proof := models.CommitmentMerkleProof{
 		MerkleRoot:     *rootHash,
-		ClientPosition: int32(v.position),
+		ClientPosition: int32(position),
 		Commitment:     *commitmentHash,
 	}
 	var ops []models.CommitmentMerkleProofOp
@@ -220,49 +305,73 @@ func (v *ChainVerifier) verifyCommitmentProof(commitment string, root string) er
 	return &ChainVerifierError{fmt.Sprintf("Could not prove client merkle commitment %s\n", commitment)}
 }
 
+// Verify the client commitment of a single position against its sidechain client
+func (v *ChainVerifier) verifyPosition(position int, sideClient clients.SidechainClient, root string) (ChainVerifierInfo, error) {
+	// get client commitment via api call
+	respCommitment, respCommitmentErr := getApiResponse(fmt.Sprintf("%s%s?merkle_root=%s&position=%d",
+		v.apiHost, ApiCommitmentUrl, root, position))
+	if respCommitmentErr != nil { // assume no client commitment for current attestation
+		return ChainVerifierInfo{}, nil
+	}
+	commitment := respCommitment["commitment"].(string)
+
+	// verify commitment proof if there was a commitment
+	// for this client in the current attestation transaction
+	errProof := v.verifyCommitmentProof(position, commitment, root)
+	if errProof != nil {
+		return ChainVerifierInfo{}, errProof
+	}
+
+	// add commitment info in case all verification checks passed
+	commitmentHash, _ := chainhash.NewHashFromStr(commitment)
+	blockHeight, blockHeightErr := sideClient.GetBlockHeight(commitmentHash)
+	if blockHeightErr != nil {
+		return ChainVerifierInfo{}, blockHeightErr
+	}
+	return ChainVerifierInfo{*commitmentHash, int64(blockHeight)}, nil
+}
+
 // Main chainverifier method wrapping the verification process
-func (v *ChainVerifier) Verify(tx Tx) (ChainVerifierInfo, error) {
-	errBasic := verifyTxBasic(tx)
+// Returns verification info for each client position verified
+func (v *ChainVerifier) Verify(tx Tx) (map[int]ChainVerifierInfo, error) {
+	errBasic := v.verifyTxBasic(tx)
 	if errBasic != nil {
-		return ChainVerifierInfo{}, errBasic
+		return nil, errBasic
 	}
 
 	// get attestation root commitment via api call
 	respAttestation, respAttestationErr := getApiResponse(fmt.Sprintf("%s%s?txid=%s",
 		v.apiHost, ApiAttestationUrl, tx.Txid))
 	if respAttestationErr != nil {
-		return ChainVerifierInfo{}, respAttestationErr
+		return nil, respAttestationErr
 	}
 	root := respAttestation["merkle_root"].(string)
 
 	// first verify tx address
 	errAddr := v.verifyTxAddr(tx, root)
 	if errAddr != nil {
-		return ChainVerifierInfo{}, errAddr
+		return nil, errAddr
 	}
-
-	// get client commitment via api call
-	respCommitment, respCommitmentErr := getApiResponse(fmt.Sprintf("%s%s?merkle_root=%s&position=%d",
-		v.apiHost, ApiCommitmentUrl, root, v.position))
-	if respCommitmentErr != nil { // assume no client commitment for current attestation
-		return ChainVerifierInfo{}, nil
+	errRedundant := v.verifyTxRedundantOutputs(tx, root)
+	if errRedundant != nil {
+		return nil, errRedundant
 	}
-	commitment := respCommitment["commitment"].(string)
 
-	// verify commitment proof if there was a commitment
-	// for this client in the current attestation transaction
-	errProof := v.verifyCommitmentProof(commitment, root)
-	if errProof != nil {
-		return ChainVerifierInfo{}, errProof
+	// verify positions in order
+	var positions []int
+	for position := range v.sideClients {
+		positions = append(positions, position)
 	}
+	sort.Ints(positions)
 
-	// add commitment info in case all verification checks passed
-	commitmentHash, _ := chainhash.NewHashFromStr(commitment)
-	blockHeight, blockHeightErr := v.sideClient.GetBlockHeight(commitmentHash)
-	if blockHeightErr != nil {
-		return ChainVerifierInfo{}, blockHeightErr
+	infos := make(map[int]ChainVerifierInfo)
+	for _, position := range positions {
+		info, infoErr := v.verifyPosition(position, v.sideClients[position], root)
+		if infoErr != nil {
+			return nil, infoErr
+		}
+		infos[position] = info
 	}
-	info := ChainVerifierInfo{*commitmentHash, int64(blockHeight)}
 
-	return info, nil
+	return infos, nil
 }
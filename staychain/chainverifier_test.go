@@ -0,0 +1,89 @@
+// Copyright (c) 2018 CommerceBlock Team
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+
+package staychain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	testAttestationAddress = "2N8AAQy6SH5HGoAtzwr5xp4LTicqJ3fic8d"
+	testReserveAddress     = "2NG3LCHuausgrYEsJQjYqhmgDVjRPYYrB5w"
+	testRoot               = "aaaaaaa1111d9a1e6cdc3418b54aa57747106bc75e9e84426661f27f98ada3b7"
+)
+
+// Return attestation tx vout paying to the address provided
+func addressVout(n uint32, address string) btcjson.Vout {
+	return btcjson.Vout{N: n, ScriptPubKey: btcjson.ScriptPubKeyResult{
+		Type: txscript.ScriptHashTy.String(), Addresses: []string{address}}}
+}
+
+// Return attestation tx vout pushing the root provided with OP_RETURN
+func nullDataVout(n uint32, root string) btcjson.Vout {
+	rootHash, _ := chainhash.NewHashFromStr(root)
+	script, _ := txscript.NullDataScript(rootHash.CloneBytes())
+	return btcjson.Vout{N: n, ScriptPubKey: btcjson.ScriptPubKeyResult{
+		Type: txscript.NullDataTy.String(), Hex: hex.EncodeToString(script)}}
+}
+
+// Test chain verifier basic and redundant output checks with and
+// without a reserve address set for two-output attestations
+func TestChainVerifier_ReserveOutput(t *testing.T) {
+	verifier := ChainVerifier{}
+	attestationVout := addressVout(0, testAttestationAddress)
+	reserveVout := addressVout(2, testReserveAddress)
+
+	// test redundant OP_RETURN output accepted
+	tx := Tx{Vout: []btcjson.Vout{attestationVout, nullDataVout(1, testRoot)}}
+	if err := verifier.verifyTxBasic(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.verifyTxRedundantOutputs(tx, testRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	// test reserve output rejected without a reserve address set
+	tx.Vout = append(tx.Vout, reserveVout)
+	if err := verifier.verifyTxBasic(tx); err == nil {
+		t.Fatal("reserve output accepted without reserve address")
+	}
+
+	// test reserve output accepted as the last vout with the reserve address set
+	verifier.SetReserveAddress(testReserveAddress)
+	if err := verifier.verifyTxBasic(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.verifyTxRedundantOutputs(tx, testRoot); err != nil {
+		t.Fatal(err)
+	}
+	reserveTx := Tx{Vout: []btcjson.Vout{attestationVout, addressVout(1, testReserveAddress)}}
+	if err := verifier.verifyTxBasic(reserveTx); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifier.verifyTxRedundantOutputs(reserveTx, testRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	// test reserve output rejected before other vouts or paying to another address
+	tx.Vout = []btcjson.Vout{attestationVout, reserveVout, nullDataVout(2, testRoot)}
+	if err := verifier.verifyTxBasic(tx); err == nil {
+		t.Fatal("reserve output accepted before the last vout")
+	}
+	tx.Vout = []btcjson.Vout{attestationVout, addressVout(1, testAttestationAddress)}
+	if err := verifier.verifyTxBasic(tx); err == nil {
+		t.Fatal("output paying to address other than reserve accepted")
+	}
+
+	// test OP_RETURN output with a different root rejected
+	tx.Vout = []btcjson.Vout{attestationVout, nullDataVout(1, testRoot[1:]+"0"), reserveVout}
+	if err := verifier.verifyTxRedundantOutputs(tx, testRoot); err == nil {
+		t.Fatal("OP_RETURN output of a different root accepted")
+	}
+}